@@ -1,42 +1,153 @@
 package mediadevices
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
+	"sync/atomic"
 	"time"
 )
 
 // AudioReader reads raw audio chunks from an FFmpeg subprocess.
 // Each call to Read() returns one chunk of interleaved PCM S16LE samples.
 type AudioReader struct {
-	proc              *ffmpegProcess
+	proc              *captureSource
 	buf               []byte
 	channels          int
 	sampleRate        int
 	samplesPerChannel int
+
+	// next is the capture time of the first sample of the next chunk. It
+	// advances by the chunk duration so that timestamps stay continuous,
+	// and is re-anchored to the arrival time when it drifts by more than
+	// audioGapThreshold. With wallclock, chunks are stamped with it.
+	wallclock bool
+	next      time.Time
+
+	// position is the number of samples per channel returned so far. When
+	// the capture restarts, align is set and the first chunk of the new
+	// process is placed on the timeline of next: the gap is filled with
+	// silence samples, or skip samples that overlap it are dropped. pending
+	// holds the samples read from the new process that follow the silence.
+	position int64
+	align    bool
+	silence  int
+	skip     int
+	pending  []byte
+
+	// clock measures the delivered sample rate. rebuild returns the capture
+	// arguments for a device that really runs at inputRate; it is nil for
+	// captures that cannot be corrected.
+	clock        clockMonitor
+	lastReturn   time.Time
+	mismatched   bool
+	inputRate    int
+	rebuild      func(inputRate int) []string
+	measuredRate atomic.Uint64 // math.Float64bits
+}
+
+// AudioConfig configures an AudioReader created by NewAudioReader.
+type AudioConfig struct {
+	// Device is the device to capture, as returned by EnumerateDevices or
+	// AudioInputDevices. If it is the zero value, DeviceID is used.
+	Device MediaDeviceInfo
+	// DeviceID selects the device by its MediaDeviceInfo.DeviceID. If both
+	// Device and DeviceID are empty, the default audio input is used.
+	DeviceID string
+	// SampleRate is the sampling rate in Hz. Defaults to 48000.
+	SampleRate int
+	// Channels is the number of channels. Defaults to 2.
+	Channels int
+	// Latency is the duration of the chunks Read returns, and so the delay
+	// chunking adds before samples reach the caller. Defaults to the audio
+	// chunk of Config.LatencyProfile, or 20ms without one.
+	Latency time.Duration
+	// ArgsHook, if set, can inspect and modify the FFmpeg arguments of
+	// this reader. It runs after Config.ArgsHook.
+	ArgsHook func(args []string) []string
+}
+
+// NewAudioReader opens an audio input device and returns a reader of its
+// PCM samples. It is the low-level counterpart of GetUserMedia for code
+// that does not need tracks and streams. The caller must Close the reader.
+func NewAudioReader(cfg AudioConfig) (*AudioReader, error) {
+	return defaultMediaDevices.NewAudioReader(cfg)
+}
+
+// NewAudioReader is like the package-level NewAudioReader, but selects the
+// device among those of m and captures with the configuration of m.
+func (m *MediaDevices) NewAudioReader(cfg AudioConfig) (*AudioReader, error) {
+	name, err := m.resolveCaptureDevice(context.Background(), MediaDeviceKindAudioInput, cfg.Device, cfg.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	return m.newAudioReaderInternal(name, cfg)
 }
 
-// newAudioReaderInternal starts an FFmpeg subprocess to capture audio from the given device.
-// This is an internal function used by MediaStreamTrack.
-func newAudioReaderInternal(deviceID string, sampleRate, channels int) (*AudioReader, error) {
+// newAudioReaderInternal starts an FFmpeg subprocess to capture audio from
+// the given device with the format, latency and hook of cfg; its device
+// fields are ignored. This is an internal function used by MediaStreamTrack.
+func (m *MediaDevices) newAudioReaderInternal(deviceID string, cfg AudioConfig) (*AudioReader, error) {
+	sampleRate, channels := cfg.SampleRate, cfg.Channels
 	if sampleRate <= 0 {
 		sampleRate = 48000
 	}
 	if channels <= 0 {
 		channels = 2
 	}
-	latency := 20 * time.Millisecond
+	if !m.isPrewarmed(MediaDeviceKindAudioInput, deviceID) {
+		if err := checkDeviceAccess(MediaDeviceKindAudioInput, deviceID); err != nil {
+			return nil, err
+		}
+	}
+	params := m.audioCaptureParams(deviceID, sampleRate, channels)
+	args := m.audioCaptureArgs(params)
+	r, err := m.newAudioReaderFromArgs(deviceID, args, sampleRate, channels, cfg.Latency, cfg.ArgsHook)
+	if err != nil {
+		return nil, err
+	}
+	r.rebuild = func(inputRate int) []string {
+		p := params
+		p.InputRate = inputRate
+		return m.audioCaptureArgs(p)
+	}
+	return r, nil
+}
 
-	params := AudioCaptureParams{
-		DeviceID:   deviceID,
-		SampleRate: sampleRate,
-		Channels:   channels,
+// audioCaptureParams returns the parameters of a capture of the device at
+// the given rate and channel count under the configuration of m.
+func (m *MediaDevices) audioCaptureParams(deviceID string, sampleRate, channels int) AudioCaptureParams {
+	gcfg := m.Config()
+	return AudioCaptureParams{
+		DeviceID:               deviceID,
+		SampleRate:             sampleRate,
+		Channels:               channels,
+		Profile:                gcfg.LatencyProfile,
+		UseWallclockTimestamps: gcfg.UseWallclockTimestamps,
 	}
+}
 
-	args := buildAudioCaptureArgs(params)
-	gcfg := GetConfig()
+// audioCaptureArgs returns the FFmpeg arguments of a capture with params,
+// in the input format configured for the device.
+func (m *MediaDevices) audioCaptureArgs(params AudioCaptureParams) []string {
+	return m.overrideInput(context.Background(), MediaDeviceKindAudioInput, params.DeviceID, "", buildAudioCaptureArgs(params))
+}
 
-	proc, err := startProcess(gcfg.FFmpegPath, args)
+// newAudioReaderFromArgs starts an FFmpeg subprocess with args, which must
+// output interleaved S16LE samples of the given rate and channel count,
+// read in chunks of latency (0 for the default). hook may be nil.
+func (m *MediaDevices) newAudioReaderFromArgs(deviceID string, args []string, sampleRate, channels int, latency time.Duration, hook func([]string) []string) (*AudioReader, error) {
+	cfg := m.Config()
+	latency, err := audioChunkDuration(cfg.LatencyProfile, latency, sampleRate)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	proc, err := m.openCapture(cfg, MediaDeviceKindAudioInput, deviceID, args, hook)
 	if err != nil {
 		return nil, fmt.Errorf("ffmpeg: start audio capture: %w", err)
 	}
@@ -52,28 +163,221 @@ func newAudioReaderInternal(deviceID string, sampleRate, channels int) (*AudioRe
 		channels:          channels,
 		sampleRate:        sampleRate,
 		samplesPerChannel: samplesPerChannel,
+		wallclock:         cfg.UseWallclockTimestamps,
+		inputRate:         sampleRate,
 	}, nil
 }
 
 // Read reads one audio chunk from the capture.
 // Returns an *AudioChunk with interleaved S16LE samples.
-// Returns io.EOF when the stream ends.
+// Returns io.EOF when the stream ends after Close or when FFmpeg finishes
+// on its own, and a *CaptureError when FFmpeg fails mid-stream.
+// If the stall watchdog restarts the capture, the partial chunk is dropped
+// and the read continues on the new process. The samples the device
+// delivered in the meantime are replaced by silence, so that the chunk
+// positions and timestamps keep following the wall clock. A gap of more
+// than half a second is not filled; the timestamps jump instead.
 func (r *AudioReader) Read() (*AudioChunk, error) {
-	_, err := io.ReadFull(r.proc, r.buf)
+	chunkDuration := time.Duration(r.samplesPerChannel) * time.Second / time.Duration(r.sampleRate)
+	if !r.lastReturn.IsZero() && time.Since(r.lastReturn) > chunkDuration {
+		// The caller fell behind; the pipe now holds a backlog.
+		r.clock.invalidate()
+	}
+	defer func() { r.lastReturn = time.Now() }()
+
+	begin := time.Now()
+	padded, err := r.readChunk()
 	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			return nil, io.EOF
+		if endOfOutput(err) {
+			return nil, r.proc.streamEnd("read audio chunk")
 		}
-		return nil, fmt.Errorf("ffmpeg: read audio chunk: %w\nstderr: %s", err, r.proc.LastStderr())
+		return nil, r.proc.captureError(fmt.Errorf("ffmpeg: read audio chunk: %w", err))
 	}
 
 	chunk, err := parseS16LEChunk(r.buf, r.channels, r.sampleRate)
 	if err != nil {
 		return nil, err
 	}
+	chunk.Position = r.position
+	r.position += int64(chunk.SamplesPerChannel)
+
+	now := time.Now()
+	var ts time.Time
+	if padded {
+		// Silence is returned as fast as it is read; it is not measured.
+		ts = r.next
+		r.next = r.next.Add(chunkDuration)
+		r.clock.restart()
+	} else {
+		ts = r.stamp(now, chunk.SamplesPerChannel)
+		r.checkClock(now, chunk.SamplesPerChannel, now.Sub(begin) >= chunkDuration/2)
+	}
+	if r.wallclock {
+		chunk.Timestamp = ts
+	}
 	return chunk, nil
 }
 
+// readChunk fills r.buf with the next chunk: pending silence, then pending
+// samples, then samples read from the capture. It reports whether the
+// chunk holds silence inserted after a restart.
+func (r *AudioReader) readChunk() (padded bool, err error) {
+	frame := r.channels * 2
+	off := 0
+	for off < len(r.buf) {
+		if r.silence > 0 {
+			n := min(r.silence, (len(r.buf)-off)/frame)
+			clear(r.buf[off : off+n*frame])
+			r.silence -= n
+			off += n * frame
+			padded = true
+			continue
+		}
+		if len(r.pending) > 0 {
+			n := copy(r.buf[off:], r.pending)
+			r.pending = r.pending[n:]
+			off += n
+			continue
+		}
+
+		var n int
+		if r.align {
+			// The first samples of a restarted capture are taken as soon
+			// as they arrive, so that realign knows when they were read.
+			n, err = readFrames(r.proc, r.buf[off:], frame)
+		} else {
+			n, err = io.ReadFull(r.proc, r.buf[off:])
+		}
+		restarted := errors.Is(err, errCaptureRestarted)
+		if err != nil && !restarted {
+			return false, err
+		}
+		// The chunk keeps the whole samples read before a restart; realign
+		// places the new process after them.
+		n -= n % frame
+		if r.align {
+			if n == 0 {
+				continue
+			}
+			r.align = false
+			r.realign(time.Now(), r.buf[off:off+n], off/frame)
+		} else {
+			drop := min(r.skip, n/frame)
+			r.skip -= drop
+			copy(r.buf[off:], r.buf[off+drop*frame:off+n])
+			off += n - drop*frame
+		}
+		if restarted {
+			r.align = true
+			r.clock.restart()
+		}
+	}
+	return padded, nil
+}
+
+// readFrames reads at least one whole frame of frame bytes from src into
+// buf, returning as soon as src delivers data.
+func readFrames(src io.Reader, buf []byte, frame int) (int, error) {
+	n, err := src.Read(buf)
+	if err == nil && n%frame != 0 {
+		var m int
+		m, err = io.ReadFull(src, buf[n:n+frame-n%frame])
+		n += m
+	}
+	return n, err
+}
+
+// realign places data, the first samples of a restarted capture read
+// completely at now, on the timeline of the chunks returned before the
+// restart, following the filled samples per channel of the chunk being
+// read, and queues them as pending. If they start after the timeline
+// ends, the gap is queued as silence; if they start before, the overlap
+// is skipped. A gap or overlap of more than audioGapThreshold is left to
+// stamp, which re-anchors the timeline.
+func (r *AudioReader) realign(now time.Time, data []byte, filled int) {
+	frame := r.channels * 2
+	r.pending = bytes.Clone(data)
+	r.silence, r.skip = 0, 0
+	if r.next.IsZero() {
+		return
+	}
+	n := len(data) / frame
+	end := r.next.Add(time.Duration(filled) * time.Second / time.Duration(r.sampleRate))
+	gap := now.Add(-time.Duration(n) * time.Second / time.Duration(r.sampleRate)).Sub(end)
+	if gap < -audioGapThreshold || gap > audioGapThreshold {
+		return
+	}
+	samples := int(math.Round(gap.Seconds() * float64(r.sampleRate)))
+	if samples >= 0 {
+		r.silence = samples
+		return
+	}
+	drop := min(-samples, n)
+	r.pending = r.pending[drop*frame:]
+	r.skip = -samples - drop
+}
+
+// checkClock feeds the clock monitor and handles a mismatch at the end of
+// a measurement: it is reported once through Config.OnClockMismatch and,
+// with Config.CorrectClockMismatch, corrected by restarting the capture.
+func (r *AudioReader) checkClock(now time.Time, n int, blocked bool) {
+	measured, ok := r.clock.observe(now, n, blocked)
+	if !ok {
+		return
+	}
+	r.measuredRate.Store(math.Float64bits(measured))
+	if !clockMismatched(measured, r.sampleRate) {
+		r.mismatched = false
+		return
+	}
+	if r.mismatched {
+		return
+	}
+	r.mismatched = true
+
+	cfg := r.proc.cfg
+	ev := ClockMismatchEvent{DeviceID: r.proc.deviceID, RequestedRate: r.sampleRate, MeasuredRate: measured}
+	if cfg.CorrectClockMismatch && r.rebuild != nil {
+		// A corrected capture delivers the requested rate only if the
+		// input rate is right; scale the one in use by the error.
+		inputRate := nominalSampleRate(float64(r.inputRate) * measured / float64(r.sampleRate))
+		if ev.Err = r.proc.reopenWith(r.rebuild(inputRate)); ev.Err == nil {
+			ev.InputRate = inputRate
+			r.inputRate = inputRate
+			r.mismatched = false
+			r.align = true
+			r.clock.restart()
+		}
+	}
+	if cfg.Verbose {
+		log.Printf("ffmpeg: audio capture %q delivers %.0f Hz instead of %d Hz (corrected from %d Hz, err=%v)", ev.DeviceID, measured, r.sampleRate, ev.InputRate, ev.Err)
+	}
+	if cfg.OnClockMismatch != nil {
+		cfg.OnClockMismatch(ev)
+	}
+}
+
+// MeasuredSampleRate returns the number of samples per channel the device
+// delivered per second of wall-clock time over the last 10 second window,
+// or 0 before the first measurement. It differs from SampleRate by more
+// than 2% when the device runs at a different rate than requested.
+func (r *AudioReader) MeasuredSampleRate() float64 {
+	return math.Float64frombits(r.measuredRate.Load())
+}
+
+// stamp returns the capture time of a chunk of n samples per channel that
+// was fully read at now, and advances r.next past it.
+func (r *AudioReader) stamp(now time.Time, n int) time.Time {
+	d := time.Duration(n) * time.Second / time.Duration(r.sampleRate)
+	start := now.Add(-d)
+	if drift := start.Sub(r.next); r.next.IsZero() || drift > audioGapThreshold || drift < -audioGapThreshold {
+		r.next = start
+	}
+	ts := r.next
+	r.next = r.next.Add(d)
+	return ts
+}
+
 // Close stops the FFmpeg subprocess and releases resources.
 func (r *AudioReader) Close() error {
 	if r.proc != nil {
@@ -91,3 +395,56 @@ func (r *AudioReader) SampleRate() int {
 func (r *AudioReader) Channels() int {
 	return r.channels
 }
+
+// Latency returns the duration of the chunks Read returns.
+func (r *AudioReader) Latency() time.Duration {
+	return time.Duration(r.samplesPerChannel) * time.Second / time.Duration(r.sampleRate)
+}
+
+// audioChunkDuration returns the chunk duration of a capture at sampleRate:
+// latency if it is set, otherwise the audio chunk of the latency profile
+// or 20ms. A chunk must hold at least one sample.
+func audioChunkDuration(profile LatencyProfile, latency time.Duration, sampleRate int) (time.Duration, error) {
+	if latency < 0 {
+		return 0, fmt.Errorf("audio latency must not be negative (got %v)", latency)
+	}
+	if latency == 0 {
+		settings, err := profile.settings()
+		if err != nil {
+			return 0, err
+		}
+		latency = 20 * time.Millisecond
+		if settings.audioChunk > 0 {
+			latency = settings.audioChunk
+		}
+	}
+	if int(float64(sampleRate)*latency.Seconds()) < 1 {
+		return 0, fmt.Errorf("audio latency %v is shorter than one sample at %d Hz", latency, sampleRate)
+	}
+	return latency, nil
+}
+
+// Restarts returns how many times the stall watchdog restarted the capture.
+func (r *AudioReader) Restarts() int {
+	return r.proc.Restarts()
+}
+
+// StderrLines returns up to the last n lines of FFmpeg's stderr, oldest
+// first, or all lines kept if n <= 0. How much stderr is kept is set by
+// Config.StderrBufferSize. After a watchdog restart, only lines of the new
+// process are returned.
+func (r *AudioReader) StderrLines(n int) []string {
+	return r.proc.StderrLines(n)
+}
+
+// OnStderrLine calls fn with every line FFmpeg writes to stderr from now
+// on, until cancel is called. Subscriptions carry over watchdog restarts.
+// fn runs on the goroutine draining stderr and must not block.
+func (r *AudioReader) OnStderrLine(fn func(line string)) (cancel func()) {
+	return r.proc.OnStderrLine(fn)
+}
+
+// warnings returns the stderr warning count of the capture, for health scoring.
+func (r *AudioReader) warnings() int64 {
+	return r.proc.Warnings()
+}
@@ -0,0 +1,103 @@
+package mediadevices
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// clockCheckWarmup is ignored at the start of a capture, while devices
+	// and FFmpeg fill their buffers.
+	clockCheckWarmup = 2 * time.Second
+	// clockCheckWindow is the span over which the sample rate is measured.
+	// Pipe buffering makes shorter windows inaccurate.
+	clockCheckWindow = 10 * time.Second
+	// clockMismatchTolerance is the relative deviation from the requested
+	// rate above which a capture is considered mismatched. Sound card
+	// crystals are off by well under 0.1%.
+	clockMismatchTolerance = 0.02
+)
+
+// standardSampleRates are the rates a mismatched device most likely runs
+// at; a measured rate within 1% of one of them is snapped to it.
+var standardSampleRates = []int{8000, 11025, 16000, 22050, 24000, 32000, 44100, 48000, 88200, 96000}
+
+// ClockMismatchEvent reports an audio capture whose device delivers samples
+// at a different rate than requested without telling FFmpeg, as some ALSA
+// and DirectShow drivers do. Recordings of such a capture play back too
+// fast or too slow.
+type ClockMismatchEvent struct {
+	// DeviceID is the FFmpeg device name the capture was opened with.
+	DeviceID string
+	// RequestedRate is the sample rate the capture was opened with.
+	RequestedRate int
+	// MeasuredRate is the number of samples per channel delivered per
+	// second of wall-clock time.
+	MeasuredRate float64
+	// InputRate is the rate the capture was restarted to resample from,
+	// with Config.CorrectClockMismatch; 0 if it was not corrected.
+	InputRate int
+	// Err is non-nil if the corrected capture could not be started. The
+	// original capture keeps running then.
+	Err error
+}
+
+// clockMonitor measures the rate at which a capture delivers samples
+// against the wall clock.
+type clockMonitor struct {
+	opened  time.Time
+	start   time.Time
+	samples int64
+}
+
+// observe records n samples per channel that arrived at now. blocked tells
+// whether the read had to wait for them: a measurement only starts at such
+// a read, when no backlog is left in the pipe that would inflate the rate.
+// It returns the measured rate at the end of each window.
+func (c *clockMonitor) observe(now time.Time, n int, blocked bool) (float64, bool) {
+	if c.opened.IsZero() {
+		c.opened = now
+	}
+	if c.start.IsZero() {
+		if blocked && now.Sub(c.opened) >= clockCheckWarmup {
+			c.start, c.samples = now, 0
+		}
+		return 0, false
+	}
+	c.samples += int64(n)
+	elapsed := now.Sub(c.start)
+	if elapsed < clockCheckWindow {
+		return 0, false
+	}
+	rate := float64(c.samples) / elapsed.Seconds()
+	c.start, c.samples = now, 0
+	return rate, true
+}
+
+// invalidate discards the current measurement, for example because the
+// application fell behind and a backlog built up.
+func (c *clockMonitor) invalidate() {
+	c.start = time.Time{}
+}
+
+// restart discards the measurement and waits for the warmup again.
+func (c *clockMonitor) restart() {
+	c.opened, c.start = time.Time{}, time.Time{}
+}
+
+// clockMismatched reports whether measured deviates from requested by more
+// than clockMismatchTolerance.
+func clockMismatched(measured float64, requested int) bool {
+	return math.Abs(measured/float64(requested)-1) > clockMismatchTolerance
+}
+
+// nominalSampleRate returns the standard rate within 1% of measured, or
+// measured rounded to an integer.
+func nominalSampleRate(measured float64) int {
+	for _, r := range standardSampleRates {
+		if math.Abs(measured/float64(r)-1) < 0.01 {
+			return r
+		}
+	}
+	return int(math.Round(measured))
+}
@@ -0,0 +1,81 @@
+package mediadevices
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClockMonitor(t *testing.T) {
+	var c clockMonitor
+	start := time.Unix(1000, 0)
+	chunk := 20 * time.Millisecond
+	var measured float64
+	done := false
+	// A device that claims 48 kHz but runs at 44.1 kHz: each 960-sample
+	// chunk takes 960/44100 s.
+	at := start
+	for i := 0; !done && i < 2000; i++ {
+		at = at.Add(time.Second * 960 / 44100)
+		measured, done = c.observe(at, 960, true)
+	}
+	if !done {
+		t.Fatal("no measurement")
+	}
+	if at.Sub(start) < clockCheckWarmup+clockCheckWindow {
+		t.Errorf("measured after %v, before warmup and a full window", at.Sub(start))
+	}
+	if measured < 44000 || measured > 44200 {
+		t.Errorf("measured %v Hz, want about 44100", measured)
+	}
+	if !clockMismatched(measured, 48000) || clockMismatched(48010, 48000) {
+		t.Error("mismatch tolerance")
+	}
+	if r := nominalSampleRate(measured); r != 44100 {
+		t.Errorf("nominal rate = %d, want 44100", r)
+	}
+	if r := nominalSampleRate(45500); r != 45500 {
+		t.Errorf("nominal rate = %d, want the measured 45500", r)
+	}
+
+	// Reads that did not block cannot start a measurement.
+	var backlog clockMonitor
+	for i := range 1000 {
+		if _, ok := backlog.observe(start.Add(time.Duration(i)*chunk), 960, false); ok {
+			t.Fatal("measurement started at a backlogged read")
+		}
+	}
+}
+
+func TestAudioReader_ClockMismatchEvent(t *testing.T) {
+	var events []ClockMismatchEvent
+	r := &AudioReader{
+		proc:       &captureSource{deviceID: "hw:1", cfg: Config{OnClockMismatch: func(ev ClockMismatchEvent) { events = append(events, ev) }}},
+		sampleRate: 48000,
+		inputRate:  48000,
+	}
+	at := time.Unix(1000, 0)
+	for range 3000 {
+		at = at.Add(time.Second * 960 / 44100)
+		r.checkClock(at, 960, true)
+	}
+	if len(events) != 1 {
+		t.Fatalf("events = %+v, want one report of the mismatch", events)
+	}
+	if ev := events[0]; ev.DeviceID != "hw:1" || ev.RequestedRate != 48000 || ev.InputRate != 0 {
+		t.Errorf("event = %+v", ev)
+	}
+	if m := r.MeasuredSampleRate(); m < 44000 || m > 44200 {
+		t.Errorf("MeasuredSampleRate = %v", m)
+	}
+}
+
+func TestAudioOutputArgs_InputRate(t *testing.T) {
+	args := strings.Join(audioOutputArgs(AudioCaptureParams{SampleRate: 48000, Channels: 1, InputRate: 44100}), " ")
+	if !strings.Contains(args, "-af asetrate=44100,aresample=48000 ") {
+		t.Errorf("corrected args: %s", args)
+	}
+	if args := strings.Join(audioOutputArgs(AudioCaptureParams{SampleRate: 48000, InputRate: 48000}), " "); strings.Contains(args, "asetrate") {
+		t.Errorf("uncorrected args: %s", args)
+	}
+}
@@ -0,0 +1,196 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+)
+
+// defaultMonitorSource is the PulseAudio/PipeWire source that monitors the
+// default output device.
+const defaultMonitorSource = "@DEFAULT_MONITOR@"
+
+// EchoReferenceConfig configures an EchoReferenceReader created by
+// NewEchoReferenceReader.
+type EchoReferenceConfig struct {
+	// Device and DeviceID select the microphone as in AudioConfig. If both
+	// are empty, the default audio input is used.
+	Device   MediaDeviceInfo
+	DeviceID string
+	// ReferenceDeviceID selects the render reference, the audio being
+	// played. On Linux it is a PulseAudio/PipeWire source name and defaults
+	// to the monitor of the default output. On Windows and macOS it is the
+	// DeviceID of a loopback audio input ("Stereo Mix", a virtual cable,
+	// BlackHole) and defaults to the first input whose label looks like one.
+	ReferenceDeviceID string
+	// SampleRate is the sampling rate in Hz of both streams. Defaults to 48000.
+	SampleRate int
+	// Channels is the number of channels of each stream, 1 or 2. Defaults
+	// to 1, which is what echo cancellers usually process.
+	Channels int
+	// ArgsHook, if set, can inspect and modify the FFmpeg arguments of
+	// this reader. It runs after Config.ArgsHook.
+	ArgsHook func(args []string) []string
+}
+
+// EchoReferenceReader captures a microphone together with the far-end
+// reference that an acoustic echo canceller (AEC) needs: what the speakers
+// are playing. Both streams come from one FFmpeg process and are merged
+// sample by sample, so every Read returns near-end and reference chunks
+// that cover the same time span.
+type EchoReferenceReader struct {
+	reader   *AudioReader
+	channels int
+}
+
+// NewEchoReferenceReader opens a microphone and the render reference. The
+// caller must Close the reader.
+func NewEchoReferenceReader(cfg EchoReferenceConfig) (*EchoReferenceReader, error) {
+	return defaultMediaDevices.NewEchoReferenceReaderContext(context.Background(), cfg)
+}
+
+// NewEchoReferenceReader is like the package-level NewEchoReferenceReader,
+// but selects the devices among those of m and captures with the
+// configuration of m.
+func (m *MediaDevices) NewEchoReferenceReader(cfg EchoReferenceConfig) (*EchoReferenceReader, error) {
+	return m.NewEchoReferenceReaderContext(context.Background(), cfg)
+}
+
+// NewEchoReferenceReaderContext is like NewEchoReferenceReader but uses ctx
+// for device discovery.
+func NewEchoReferenceReaderContext(ctx context.Context, cfg EchoReferenceConfig) (*EchoReferenceReader, error) {
+	return defaultMediaDevices.NewEchoReferenceReaderContext(ctx, cfg)
+}
+
+// NewEchoReferenceReaderContext is like the package-level
+// NewEchoReferenceReaderContext, but selects the devices among those of m
+// and captures with the configuration of m.
+func (m *MediaDevices) NewEchoReferenceReaderContext(ctx context.Context, cfg EchoReferenceConfig) (*EchoReferenceReader, error) {
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 48000
+	}
+	if cfg.Channels <= 0 {
+		cfg.Channels = 1
+	}
+	if cfg.Channels > 2 {
+		return nil, fmt.Errorf("ffmpeg: echo reference: %d channels, want 1 or 2", cfg.Channels)
+	}
+
+	mic, err := m.resolveCaptureDevice(ctx, MediaDeviceKindAudioInput, cfg.Device, cfg.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	ref, err := m.resolveReferenceDevice(ctx, cfg.ReferenceDeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: echo reference: %w", err)
+	}
+
+	gcfg := m.Config()
+	params := AudioCaptureParams{
+		DeviceID:               mic,
+		SampleRate:             cfg.SampleRate,
+		Channels:               cfg.Channels,
+		Profile:                gcfg.LatencyProfile,
+		UseWallclockTimestamps: gcfg.UseWallclockTimestamps,
+	}
+	refParams := params
+	refParams.DeviceID = ref
+	// The microphone is the first input.
+	args := m.overrideInput(ctx, MediaDeviceKindAudioInput, mic, cfg.DeviceID, buildEchoCaptureArgs(params, refParams))
+
+	r, err := m.newAudioReaderFromArgs(mic, args, cfg.SampleRate, 2*cfg.Channels, 0, cfg.ArgsHook)
+	if err != nil {
+		return nil, err
+	}
+	return &EchoReferenceReader{reader: r, channels: cfg.Channels}, nil
+}
+
+// resolveReferenceDevice returns the FFmpeg input name of the render
+// reference selected by id among the devices of m, or of the default one if
+// id is empty.
+func (m *MediaDevices) resolveReferenceDevice(ctx context.Context, id string) (string, error) {
+	if runtime.GOOS == "linux" {
+		if id == "" {
+			return defaultMonitorSource, nil
+		}
+		return id, nil
+	}
+	if id != "" {
+		return m.resolveCaptureDevice(ctx, MediaDeviceKindAudioInput, MediaDeviceInfo{}, id)
+	}
+	devices, err := m.devicesByKind(ctx, MediaDeviceKindAudioInput)
+	if err != nil {
+		return "", err
+	}
+	if d, ok := findLoopbackDevice(devices); ok {
+		return ffmpegDeviceName(d), nil
+	}
+	return "", fmt.Errorf("no loopback audio input found; enable Stereo Mix or install a virtual audio device and set ReferenceDeviceID")
+}
+
+// findLoopbackDevice returns the first loopback audio input.
+func findLoopbackDevice(devices []MediaDeviceInfo) (MediaDeviceInfo, bool) {
+	for _, d := range devices {
+		if d.Loopback || isLoopbackDevice(d) {
+			return d, true
+		}
+	}
+	return MediaDeviceInfo{}, false
+}
+
+// Read returns the next microphone chunk and the reference chunk captured
+// over the same samples. Returns io.EOF when the capture ends.
+func (r *EchoReferenceReader) Read() (near, ref *AudioChunk, err error) {
+	chunk, err := r.reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+	near, ref = splitEchoChunk(chunk, r.channels)
+	return near, ref, nil
+}
+
+// splitEchoChunk splits a merged chunk whose frames hold the channels of
+// the microphone followed by those of the reference.
+func splitEchoChunk(c *AudioChunk, channels int) (near, ref *AudioChunk) {
+	n := c.SamplesPerChannel
+	near = &AudioChunk{Data: make([]int16, 0, n*channels), Channels: channels, SampleRate: c.SampleRate, SamplesPerChannel: n, Position: c.Position, Timestamp: c.Timestamp}
+	ref = &AudioChunk{Data: make([]int16, 0, n*channels), Channels: channels, SampleRate: c.SampleRate, SamplesPerChannel: n, Position: c.Position, Timestamp: c.Timestamp}
+	for i := 0; i < n; i++ {
+		frame := c.Data[i*c.Channels : (i+1)*c.Channels]
+		near.Data = append(near.Data, frame[:channels]...)
+		ref.Data = append(ref.Data, frame[channels:]...)
+	}
+	return near, ref
+}
+
+// Close stops the capture.
+func (r *EchoReferenceReader) Close() error {
+	return r.reader.Close()
+}
+
+// SampleRate returns the sample rate in Hz of both streams.
+func (r *EchoReferenceReader) SampleRate() int {
+	return r.reader.SampleRate()
+}
+
+// Channels returns the number of channels of each stream.
+func (r *EchoReferenceReader) Channels() int {
+	return r.channels
+}
+
+// Restarts returns how many times the stall watchdog restarted the capture.
+func (r *EchoReferenceReader) Restarts() int {
+	return r.reader.Restarts()
+}
+
+// StderrLines returns up to the last n lines of FFmpeg's stderr; see
+// AudioReader.StderrLines.
+func (r *EchoReferenceReader) StderrLines(n int) []string {
+	return r.reader.StderrLines(n)
+}
+
+// OnStderrLine calls fn with every line FFmpeg writes to stderr from now
+// on; see AudioReader.OnStderrLine.
+func (r *EchoReferenceReader) OnStderrLine(fn func(line string)) (cancel func()) {
+	return r.reader.OnStderrLine(fn)
+}
@@ -0,0 +1,36 @@
+package mediadevices
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitEchoChunk(t *testing.T) {
+	merged := &AudioChunk{Data: []int16{1, 10, 2, 20, 3, 30}, Channels: 2, SampleRate: 48000, SamplesPerChannel: 3}
+	near, ref := splitEchoChunk(merged, 1)
+	if !reflect.DeepEqual(near.Data, []int16{1, 2, 3}) || !reflect.DeepEqual(ref.Data, []int16{10, 20, 30}) {
+		t.Errorf("mono split = %v, %v", near.Data, ref.Data)
+	}
+	if near.SamplesPerChannel != 3 || ref.Channels != 1 || ref.SampleRate != 48000 {
+		t.Errorf("reference chunk = %+v", ref)
+	}
+
+	merged = &AudioChunk{Data: []int16{1, 2, 10, 20, 3, 4, 30, 40}, Channels: 4, SampleRate: 48000, SamplesPerChannel: 2}
+	near, ref = splitEchoChunk(merged, 2)
+	if !reflect.DeepEqual(near.Data, []int16{1, 2, 3, 4}) || !reflect.DeepEqual(ref.Data, []int16{10, 20, 30, 40}) {
+		t.Errorf("stereo split = %v, %v", near.Data, ref.Data)
+	}
+}
+
+func TestFindLoopbackDevice(t *testing.T) {
+	devices := []MediaDeviceInfo{
+		{DeviceID: "mic", Label: "Microphone (Realtek Audio)"},
+		{DeviceID: "mix", Label: "Stereo Mix (Realtek Audio)"},
+	}
+	if d, ok := findLoopbackDevice(devices); !ok || d.DeviceID != "mix" {
+		t.Errorf("found %+v, %v; want Stereo Mix", d, ok)
+	}
+	if _, ok := findLoopbackDevice(devices[:1]); ok {
+		t.Error("microphone taken for a loopback device")
+	}
+}
@@ -0,0 +1,371 @@
+package mediadevices
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// defaultOpusBitRate 是 Ogg/Opus 文件的默认码率（kbps），适合语音。
+const defaultOpusBitRate = 32
+
+// defaultMP3BitRate 是 MP3 文件的默认码率（kbps）。
+const defaultMP3BitRate = 128
+
+// mp3SampleRates 是 MP3（MPEG-1/2/2.5 Layer III）支持的采样率。
+var mp3SampleRates = []int{8000, 11025, 12000, 16000, 22050, 24000, 32000, 44100, 48000}
+
+// wavHeaderSize 是 PCM WAV 文件头（RIFF + fmt + data 块头）的字节数。
+const wavHeaderSize = 44
+
+// AudioFileWriter 将 PCM 音频块写入音频文件，用于只需要录音的场景（语音备忘、音频日志），
+// 无需完整的 MediaRecorder。写入的块必须与创建时的采样率和声道数一致。
+type AudioFileWriter interface {
+	// WriteChunk 写入一个音频块。
+	WriteChunk(chunk *AudioChunk) error
+	// Duration 返回已写入音频的时长。
+	Duration() time.Duration
+	// Close 完成文件（写入最终时长）并关闭。
+	Close() error
+}
+
+// CreateAudioFile 按扩展名创建音频文件写入器：.wav 为 PCM WAV，
+// .ogg 和 .opus 为 Ogg/Opus（默认码率），.mp3 为 MP3（默认码率），.flac 为 FLAC。
+// 需要元数据标签时使用 NewMP3Writer 或 NewFLACWriter。
+func CreateAudioFile(path string, sampleRate, channels int) (AudioFileWriter, error) {
+	return defaultMediaDevices.CreateAudioFile(path, sampleRate, channels)
+}
+
+// CreateAudioFile 与包级函数 CreateAudioFile 相同，但使用 m 的配置运行 FFmpeg 编码器。
+func (m *MediaDevices) CreateAudioFile(path string, sampleRate, channels int) (AudioFileWriter, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".wav":
+		return NewWAVWriter(path, sampleRate, channels)
+	case ".ogg", ".opus":
+		return m.NewOggOpusWriter(path, sampleRate, channels, 0)
+	case ".mp3":
+		return m.NewMP3Writer(path, sampleRate, channels, 0, nil)
+	case ".flac":
+		return m.NewFLACWriter(path, sampleRate, channels, nil)
+	default:
+		return nil, fmt.Errorf("audio file: unsupported format %q (want .wav, .ogg, .opus, .mp3 or .flac)", ext)
+	}
+}
+
+// RecordAudio 反复调用 read（如 AudioReader.Read 或 MediaStreamTrack.ReadAudio）
+// 并将音频块写入 w，直到 read 返回 io.EOF 或 ctx 结束。
+// 正常结束时返回 nil，ctx 结束时返回 ctx.Err()。RecordAudio 不关闭 w。
+func RecordAudio(ctx context.Context, read func() (*AudioChunk, error), w AudioFileWriter) error {
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		chunk, err := read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := w.WriteChunk(chunk); err != nil {
+			return err
+		}
+	}
+}
+
+// audioFormat 是音频文件写入器的输入格式。
+type audioFormat struct {
+	sampleRate int
+	channels   int
+	samples    int64 // 已写入的每声道采样数
+}
+
+func newAudioFormat(sampleRate, channels int) (audioFormat, error) {
+	if sampleRate <= 0 || channels <= 0 {
+		return audioFormat{}, fmt.Errorf("audio file: invalid format %d Hz, %d channels", sampleRate, channels)
+	}
+	return audioFormat{sampleRate: sampleRate, channels: channels}, nil
+}
+
+// check 确认 chunk 与文件格式一致。
+func (f *audioFormat) check(chunk *AudioChunk) error {
+	if chunk.SampleRate != f.sampleRate || chunk.Channels != f.channels {
+		return fmt.Errorf("audio file: chunk is %d Hz, %d channels; file is %d Hz, %d channels",
+			chunk.SampleRate, chunk.Channels, f.sampleRate, f.channels)
+	}
+	return nil
+}
+
+func (f *audioFormat) Duration() time.Duration {
+	return time.Duration(f.samples) * time.Second / time.Duration(f.sampleRate)
+}
+
+// appendS16LE 将采样以 S16LE 追加到 buf。
+func appendS16LE(buf []byte, samples []int16) []byte {
+	for _, v := range samples {
+		buf = binary.LittleEndian.AppendUint16(buf, uint16(v))
+	}
+	return buf
+}
+
+// WAVWriter 将音频写入 16 位 PCM WAV 文件。
+// 文件头中的长度在 Close 时写入；未正常关闭的文件长度字段为 0，
+// 多数播放器仍可播放。WAV 格式限制数据不超过 4 GiB。
+type WAVWriter struct {
+	audioFormat
+	f   *os.File
+	w   *bufio.Writer
+	buf []byte
+}
+
+// NewWAVWriter 创建 path 处的 WAV 文件。
+func NewWAVWriter(path string, sampleRate, channels int) (*WAVWriter, error) {
+	format, err := newAudioFormat(sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("audio file: %w", err)
+	}
+	w := &WAVWriter{audioFormat: format, f: f, w: bufio.NewWriter(f)}
+	if _, err := w.w.Write(wavHeader(sampleRate, channels, 0)); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audio file: %w", err)
+	}
+	return w, nil
+}
+
+// wavHeader 返回 dataSize 字节 PCM 数据的 WAV 文件头。
+func wavHeader(sampleRate, channels int, dataSize uint32) []byte {
+	blockAlign := channels * 2
+	h := make([]byte, 0, wavHeaderSize)
+	h = append(h, "RIFF"...)
+	h = binary.LittleEndian.AppendUint32(h, 36+dataSize)
+	h = append(h, "WAVEfmt "...)
+	h = binary.LittleEndian.AppendUint32(h, 16)
+	h = binary.LittleEndian.AppendUint16(h, 1) // PCM
+	h = binary.LittleEndian.AppendUint16(h, uint16(channels))
+	h = binary.LittleEndian.AppendUint32(h, uint32(sampleRate))
+	h = binary.LittleEndian.AppendUint32(h, uint32(sampleRate*blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, uint16(blockAlign))
+	h = binary.LittleEndian.AppendUint16(h, 16)
+	h = append(h, "data"...)
+	h = binary.LittleEndian.AppendUint32(h, dataSize)
+	return h
+}
+
+// WriteChunk 写入一个音频块。
+func (w *WAVWriter) WriteChunk(chunk *AudioChunk) error {
+	if err := w.check(chunk); err != nil {
+		return err
+	}
+	if w.dataSize()+int64(2*len(chunk.Data)) > math.MaxUint32-36 {
+		return errors.New("audio file: WAV data exceeds 4 GiB")
+	}
+	w.buf = appendS16LE(w.buf[:0], chunk.Data)
+	if _, err := w.w.Write(w.buf); err != nil {
+		return fmt.Errorf("audio file: %w", err)
+	}
+	w.samples += int64(chunk.SamplesPerChannel)
+	return nil
+}
+
+func (w *WAVWriter) dataSize() int64 {
+	return w.samples * int64(w.channels) * 2
+}
+
+// Close 写入最终的文件头并关闭文件。
+func (w *WAVWriter) Close() error {
+	err := w.w.Flush()
+	if err == nil {
+		_, err = w.f.WriteAt(wavHeader(w.sampleRate, w.channels, uint32(w.dataSize())), 0)
+	}
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("audio file: %w", err)
+	}
+	return nil
+}
+
+// encoderWriter 将音频块交给从 stdin 读取 S16LE 的 FFmpeg 编码进程，
+// 是通过 FFmpeg 编码的音频文件写入器的共同部分。
+type encoderWriter struct {
+	audioFormat
+	proc *ffmpegProcess
+	buf  []byte
+}
+
+// newEncoderWriter 检查格式并以 cfg 和 args 启动编码进程。
+func newEncoderWriter(cfg Config, sampleRate, channels int, args []string) (encoderWriter, error) {
+	format, err := newAudioFormat(sampleRate, channels)
+	if err != nil {
+		return encoderWriter{}, err
+	}
+	proc, err := startEncodeProcess(cfg, args)
+	if err != nil {
+		return encoderWriter{}, fmt.Errorf("audio file: %w", err)
+	}
+	return encoderWriter{audioFormat: format, proc: proc}, nil
+}
+
+// WriteChunk 将一个音频块交给编码器。
+func (w *encoderWriter) WriteChunk(chunk *AudioChunk) error {
+	if err := w.check(chunk); err != nil {
+		return err
+	}
+	w.buf = appendS16LE(w.buf[:0], chunk.Data)
+	if _, err := w.proc.Write(w.buf); err != nil {
+		return newCaptureError(fmt.Errorf("audio file: write: %w", err), w.proc.LastStderr())
+	}
+	w.samples += int64(chunk.SamplesPerChannel)
+	return nil
+}
+
+// Close 等待 FFmpeg 编码剩余数据并完成文件。
+func (w *encoderWriter) Close() error {
+	if err := w.proc.Finish(recorderFinishTimeout); err != nil {
+		return newCaptureError(fmt.Errorf("audio file: encoder: %w", err), w.proc.LastStderr())
+	}
+	return nil
+}
+
+// encoderInputArgs 返回从 stdin 读取 S16LE 的 FFmpeg 输入参数。
+func encoderInputArgs(sampleRate, channels int) []string {
+	return []string{
+		"-y",
+		"-f", "s16le",
+		"-ar", fmt.Sprintf("%d", sampleRate),
+		"-ac", fmt.Sprintf("%d", channels),
+		"-i", "pipe:0",
+	}
+}
+
+// metadataArgs 返回按键排序的 -metadata 参数。
+func metadataArgs(tags map[string]string) []string {
+	var args []string
+	for _, k := range slices.Sorted(maps.Keys(tags)) {
+		args = append(args, "-metadata", k+"="+tags[k])
+	}
+	return args
+}
+
+// OggOpusWriter 通过 FFmpeg（libopus）将音频编码为 Ogg/Opus 文件。
+// Close 时 FFmpeg 写入最后一页，文件时长由最终的 granule position 确定。
+type OggOpusWriter struct {
+	encoderWriter
+}
+
+// NewOggOpusWriter 创建 path 处的 Ogg/Opus 文件。bitRate 为码率（kbps），
+// 0 表示默认 32 kbps。需要 FFmpeg 启用 libopus。
+func NewOggOpusWriter(path string, sampleRate, channels, bitRate int) (*OggOpusWriter, error) {
+	return defaultMediaDevices.NewOggOpusWriter(path, sampleRate, channels, bitRate)
+}
+
+// NewOggOpusWriter 与包级函数 NewOggOpusWriter 相同，但使用 m 的配置运行 FFmpeg。
+func (m *MediaDevices) NewOggOpusWriter(path string, sampleRate, channels, bitRate int) (*OggOpusWriter, error) {
+	w, err := newEncoderWriter(m.Config(), sampleRate, channels, buildOggOpusArgs(path, sampleRate, channels, bitRate))
+	if err != nil {
+		return nil, err
+	}
+	return &OggOpusWriter{w}, nil
+}
+
+// buildOggOpusArgs 构建从 stdin 读取 S16LE 并编码为 Ogg/Opus 的 FFmpeg 参数。
+// Opus 内部采样率固定为 48 kHz，其他采样率由 FFmpeg 重采样。
+func buildOggOpusArgs(path string, sampleRate, channels, bitRate int) []string {
+	if bitRate <= 0 {
+		bitRate = defaultOpusBitRate
+	}
+	return append(encoderInputArgs(sampleRate, channels),
+		"-c:a", "libopus",
+		"-b:a", fmt.Sprintf("%dk", bitRate),
+		"-ar", "48000",
+		"-f", "ogg", path,
+	)
+}
+
+// MP3Writer 通过 FFmpeg（libmp3lame）将音频编码为恒定码率的 MP3 文件。
+// Close 时 FFmpeg 写入 Xing/LAME 头，播放器据此得到准确的时长。
+type MP3Writer struct {
+	encoderWriter
+}
+
+// NewMP3Writer 创建 path 处的 MP3 文件。bitRate 为码率（kbps），0 表示默认 128 kbps。
+// tags 是写入 ID3v2.3 标签的元数据，键为 FFmpeg 的元数据名，如 "title"、"artist"、
+// "album"、"date"、"comment"，可以为 nil。需要 FFmpeg 启用 libmp3lame。
+//
+// MP3 最多两个声道，采样率限于 8～48 kHz 的标准值；其他格式由 FFmpeg 混缩为立体声、
+// 重采样为 48 kHz。
+func NewMP3Writer(path string, sampleRate, channels, bitRate int, tags map[string]string) (*MP3Writer, error) {
+	return defaultMediaDevices.NewMP3Writer(path, sampleRate, channels, bitRate, tags)
+}
+
+// NewMP3Writer 与包级函数 NewMP3Writer 相同，但使用 m 的配置运行 FFmpeg。
+func (m *MediaDevices) NewMP3Writer(path string, sampleRate, channels, bitRate int, tags map[string]string) (*MP3Writer, error) {
+	w, err := newEncoderWriter(m.Config(), sampleRate, channels, buildMP3Args(path, sampleRate, channels, bitRate, tags))
+	if err != nil {
+		return nil, err
+	}
+	return &MP3Writer{w}, nil
+}
+
+// buildMP3Args 构建从 stdin 读取 S16LE 并编码为 MP3 的 FFmpeg 参数。
+func buildMP3Args(path string, sampleRate, channels, bitRate int, tags map[string]string) []string {
+	if bitRate <= 0 {
+		bitRate = defaultMP3BitRate
+	}
+	args := append(encoderInputArgs(sampleRate, channels),
+		"-c:a", "libmp3lame",
+		"-b:a", fmt.Sprintf("%dk", bitRate),
+	)
+	if channels > 2 {
+		args = append(args, "-ac", "2")
+	}
+	if !slices.Contains(mp3SampleRates, sampleRate) {
+		args = append(args, "-ar", "48000")
+	}
+	args = append(args, metadataArgs(tags)...)
+	return append(args, "-id3v2_version", "3", "-f", "mp3", path)
+}
+
+// FLACWriter 通过 FFmpeg 将音频无损编码为 FLAC 文件。
+// Close 时 FFmpeg 在 STREAMINFO 中写入总采样数和 MD5 校验值。
+type FLACWriter struct {
+	encoderWriter
+}
+
+// NewFLACWriter 创建 path 处的 FLAC 文件。tags 是写入 Vorbis 注释的元数据，
+// 键为 FFmpeg 的元数据名，如 "title"、"artist"、"date"、"comment"，可以为 nil。
+// FLAC 编码器是 FFmpeg 内置的，不需要外部库。
+func NewFLACWriter(path string, sampleRate, channels int, tags map[string]string) (*FLACWriter, error) {
+	return defaultMediaDevices.NewFLACWriter(path, sampleRate, channels, tags)
+}
+
+// NewFLACWriter 与包级函数 NewFLACWriter 相同，但使用 m 的配置运行 FFmpeg。
+func (m *MediaDevices) NewFLACWriter(path string, sampleRate, channels int, tags map[string]string) (*FLACWriter, error) {
+	w, err := newEncoderWriter(m.Config(), sampleRate, channels, buildFLACArgs(path, sampleRate, channels, tags))
+	if err != nil {
+		return nil, err
+	}
+	return &FLACWriter{w}, nil
+}
+
+// buildFLACArgs 构建从 stdin 读取 S16LE 并编码为 FLAC 的 FFmpeg 参数。
+func buildFLACArgs(path string, sampleRate, channels int, tags map[string]string) []string {
+	args := append(encoderInputArgs(sampleRate, channels), "-c:a", "flac")
+	args = append(args, metadataArgs(tags)...)
+	return append(args, "-f", "flac", path)
+}
@@ -0,0 +1,107 @@
+package mediadevices
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWAVWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "memo.wav")
+	w, err := CreateAudioFile(path, 8000, 2)
+	if err != nil {
+		t.Fatalf("CreateAudioFile: %v", err)
+	}
+
+	chunks := 0
+	read := func() (*AudioChunk, error) {
+		if chunks == 4 {
+			return nil, io.EOF
+		}
+		chunks++
+		return &AudioChunk{Data: make([]int16, 2*400), Channels: 2, SampleRate: 8000, SamplesPerChannel: 400}, nil
+	}
+	if err := RecordAudio(context.Background(), read, w); err != nil {
+		t.Fatalf("RecordAudio: %v", err)
+	}
+	if err := w.WriteChunk(&AudioChunk{Data: make([]int16, 10), Channels: 1, SampleRate: 8000, SamplesPerChannel: 10}); err == nil {
+		t.Error("mono chunk accepted by stereo file")
+	}
+	if d := w.Duration(); d != 200*time.Millisecond {
+		t.Errorf("Duration = %v, want 200ms", d)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const dataSize = 4 * 400 * 2 * 2
+	if len(data) != wavHeaderSize+dataSize {
+		t.Fatalf("file size = %d, want %d", len(data), wavHeaderSize+dataSize)
+	}
+	if string(data[0:4]) != "RIFF" || string(data[8:16]) != "WAVEfmt " || string(data[36:40]) != "data" {
+		t.Errorf("bad header: %q", data[:wavHeaderSize])
+	}
+	le := binary.LittleEndian
+	if got := le.Uint32(data[4:]); got != 36+dataSize {
+		t.Errorf("RIFF size = %d", got)
+	}
+	if got := le.Uint32(data[40:]); got != dataSize {
+		t.Errorf("data size = %d", got)
+	}
+	if ch, rate, bits := le.Uint16(data[22:]), le.Uint32(data[24:]), le.Uint16(data[34:]); ch != 2 || rate != 8000 || bits != 16 {
+		t.Errorf("format = %d channels, %d Hz, %d bits", ch, rate, bits)
+	}
+}
+
+func TestCreateAudioFile_Formats(t *testing.T) {
+	if _, err := CreateAudioFile("memo.aac", 48000, 1); err == nil {
+		t.Error("unsupported format accepted")
+	}
+	if _, err := NewWAVWriter(filepath.Join(t.TempDir(), "x.wav"), 0, 1); err == nil {
+		t.Error("zero sample rate accepted")
+	}
+
+	args := strings.Join(buildOggOpusArgs("memo.ogg", 16000, 1, 0), " ")
+	for _, want := range []string{"-f s16le -ar 16000 -ac 1 -i pipe:0", "-c:a libopus -b:a 32k -ar 48000 -f ogg memo.ogg"} {
+		if !strings.Contains(args, want) {
+			t.Errorf("args missing %q: %s", want, args)
+		}
+	}
+
+	tags := map[string]string{"title": "Standup", "date": "2026-10-18", "comment": "a=b"}
+	args = strings.Join(buildMP3Args("memo.mp3", 96000, 4, 0, tags), " ")
+	want := "-y -f s16le -ar 96000 -ac 4 -i pipe:0 -c:a libmp3lame -b:a 128k -ac 2 -ar 48000 " +
+		"-metadata comment=a=b -metadata date=2026-10-18 -metadata title=Standup -id3v2_version 3 -f mp3 memo.mp3"
+	if args != want {
+		t.Errorf("MP3 args = %s\nwant %s", args, want)
+	}
+	if args := strings.Join(buildMP3Args("memo.mp3", 44100, 2, 64, nil), " "); strings.Contains(args, "-ar 48000") || !strings.Contains(args, "-b:a 64k") || strings.Contains(args, "-metadata") {
+		t.Errorf("MP3 args for a supported format = %s", args)
+	}
+
+	args = strings.Join(buildFLACArgs("log.flac", 16000, 1, map[string]string{"artist": "Front desk"}), " ")
+	if want := "-y -f s16le -ar 16000 -ac 1 -i pipe:0 -c:a flac -metadata artist=Front desk -f flac log.flac"; args != want {
+		t.Errorf("FLAC args = %s\nwant %s", args, want)
+	}
+}
+
+func TestRecordAudio_Canceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	read := func() (*AudioChunk, error) {
+		t.Fatal("read after cancellation")
+		return nil, nil
+	}
+	if err := RecordAudio(ctx, read, nil); err != context.Canceled {
+		t.Errorf("err = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,118 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+)
+
+// AudioWriterConfig configures an AudioWriter created by NewAudioWriter.
+type AudioWriterConfig struct {
+	// Device is the output device to play to, as returned by
+	// AudioOutputDevices. If it is the zero value, DeviceID is used.
+	Device MediaDeviceInfo
+	// DeviceID selects the device by its MediaDeviceInfo.DeviceID. If both
+	// Device and DeviceID are empty, the default audio output is used.
+	DeviceID string
+	// SampleRate is the sampling rate in Hz of the chunks. Defaults to 48000.
+	SampleRate int
+	// Channels is the number of channels of the chunks. Defaults to 2.
+	Channels int
+	// LowLatency keeps buffering in FFmpeg and the sound server to a
+	// minimum, for monitoring an input. It risks dropouts on a busy system.
+	LowLatency bool
+	// ArgsHook, if set, can inspect and modify the FFmpeg arguments of
+	// this writer. It runs after Config.ArgsHook.
+	ArgsHook func(args []string) []string
+}
+
+// AudioWriter plays audio chunks on an output device through an FFmpeg
+// subprocess, e.g. to monitor a microphone locally. The device consumes
+// samples in real time, so Write blocks once FFmpeg's buffers are full.
+//
+// FFmpeg has no audio output device on Windows, so NewAudioWriter fails
+// there; output devices are still listed by AudioOutputDevices.
+type AudioWriter struct {
+	proc       *ffmpegProcess
+	sampleRate int
+	channels   int
+	buf        []byte
+}
+
+// NewAudioWriter opens an audio output device. The caller must Close the
+// writer.
+func NewAudioWriter(cfg AudioWriterConfig) (*AudioWriter, error) {
+	return defaultMediaDevices.NewAudioWriterContext(context.Background(), cfg)
+}
+
+// NewAudioWriter is like the package-level NewAudioWriter, but selects the
+// device among those of m and plays with the configuration of m.
+func (m *MediaDevices) NewAudioWriter(cfg AudioWriterConfig) (*AudioWriter, error) {
+	return m.NewAudioWriterContext(context.Background(), cfg)
+}
+
+// NewAudioWriterContext is like NewAudioWriter; ctx bounds the device
+// lookup.
+func NewAudioWriterContext(ctx context.Context, cfg AudioWriterConfig) (*AudioWriter, error) {
+	return defaultMediaDevices.NewAudioWriterContext(ctx, cfg)
+}
+
+// NewAudioWriterContext is like the package-level NewAudioWriterContext,
+// but selects the device among those of m and plays with the configuration
+// of m.
+func (m *MediaDevices) NewAudioWriterContext(ctx context.Context, cfg AudioWriterConfig) (*AudioWriter, error) {
+	name, err := m.resolveCaptureDevice(ctx, MediaDeviceKindAudioOutput, cfg.Device, cfg.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	if cfg.SampleRate <= 0 {
+		cfg.SampleRate = 48000
+	}
+	if cfg.Channels <= 0 {
+		cfg.Channels = 2
+	}
+	args, err := buildAudioPlaybackArgs(AudioPlaybackParams{
+		DeviceID:   name,
+		SampleRate: cfg.SampleRate,
+		Channels:   cfg.Channels,
+		LowLatency: cfg.LowLatency,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+
+	gcfg := m.Config()
+	gcfg.ArgsHook = chainArgsHooks(gcfg.ArgsHook, cfg.ArgsHook)
+	proc, err := startEncodeProcess(gcfg, args)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: start audio playback: %w", err)
+	}
+	return &AudioWriter{proc: proc, sampleRate: cfg.SampleRate, channels: cfg.Channels}, nil
+}
+
+// Write plays one chunk. Its format must match the writer's; resample or
+// remix chunks of other formats first.
+func (w *AudioWriter) Write(chunk *AudioChunk) error {
+	if chunk.SampleRate != w.sampleRate || chunk.Channels != w.channels {
+		return fmt.Errorf("ffmpeg: audio playback: chunk is %d Hz, %d channels; writer is %d Hz, %d channels",
+			chunk.SampleRate, chunk.Channels, w.sampleRate, w.channels)
+	}
+	w.buf = appendS16LE(w.buf[:0], chunk.Data)
+	if _, err := w.proc.Write(w.buf); err != nil {
+		return newCaptureError(fmt.Errorf("ffmpeg: audio playback: %w", err), w.proc.LastStderr())
+	}
+	return nil
+}
+
+// SampleRate returns the sampling rate the writer expects.
+func (w *AudioWriter) SampleRate() int { return w.sampleRate }
+
+// Channels returns the channel count the writer expects.
+func (w *AudioWriter) Channels() int { return w.channels }
+
+// Close plays the samples already written and stops the FFmpeg process.
+func (w *AudioWriter) Close() error {
+	if err := w.proc.Finish(recorderFinishTimeout); err != nil {
+		return newCaptureError(fmt.Errorf("ffmpeg: audio playback: %w", err), w.proc.LastStderr())
+	}
+	return nil
+}
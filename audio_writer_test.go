@@ -0,0 +1,53 @@
+//go:build !windows && !android
+
+package mediadevices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAudioWriter(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{FFmpegPath: "/bin/sh"})
+
+	// sh stands in for ffmpeg and stores what would be played.
+	out := filepath.Join(t.TempDir(), "played.raw")
+	w, err := NewAudioWriter(AudioWriterConfig{
+		Device:     MediaDeviceInfo{DeviceID: "speakers", DeviceName: "hw:0", Kind: MediaDeviceKindAudioOutput},
+		SampleRate: 48000,
+		Channels:   2,
+		ArgsHook:   func([]string) []string { return []string{"-c", "cat > " + out} },
+	})
+	if err != nil {
+		t.Fatalf("NewAudioWriter: %v", err)
+	}
+	chunk := &AudioChunk{Data: []int16{1, -1, 256, -256}, Channels: 2, SampleRate: 48000, SamplesPerChannel: 2}
+	if err := w.Write(chunk); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Write(&AudioChunk{Data: []int16{1}, Channels: 1, SampleRate: 48000, SamplesPerChannel: 1}); err == nil {
+		t.Error("Write accepted a mono chunk for a stereo writer")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte{0x01, 0x00, 0xff, 0xff, 0x00, 0x01, 0x00, 0xff}
+	if string(data) != string(want) {
+		t.Errorf("played % x, want % x", data, want)
+	}
+}
+
+func TestAudioWriter_RejectsInputDevice(t *testing.T) {
+	_, err := NewAudioWriter(AudioWriterConfig{Device: MediaDeviceInfo{DeviceID: "mic", DeviceName: "hw:0", Kind: MediaDeviceKindAudioInput}})
+	if err == nil {
+		t.Error("NewAudioWriter opened an audio input")
+	}
+}
@@ -0,0 +1,140 @@
+package mediadevices
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"time"
+)
+
+// Sustained throughput of the stdout pipe from FFmpeg to this process for
+// large writes, measured with rawvideo on typical desktop hardware. Windows
+// anonymous pipes and the small macOS pipe buffers are much slower than
+// Linux pipes.
+const (
+	pipeThroughputLinux   = 1024 << 20
+	pipeThroughputDarwin  = 600 << 20
+	pipeThroughputWindows = 400 << 20
+)
+
+// Pipe buffer sizes: the default Linux pipe buffer, and the largest one an
+// unprivileged process can request (/proc/sys/fs/pipe-max-size).
+const (
+	minPipeBuffer = 64 << 10
+	maxPipeBuffer = 1 << 20
+)
+
+// ringBufferDuration is the amount of media a ring buffer between the reader
+// and a consumer should hold to ride out scheduling hiccups and GC pauses.
+const ringBufferDuration = 250 * time.Millisecond
+
+// pipeLoadWarning is the share of the pipe throughput above which a capture
+// is likely to drop frames when the system is under load.
+const pipeLoadWarning = 0.5
+
+// RawBandwidth is the estimated data rate of a raw capture between FFmpeg
+// and the reader, before any encoding.
+type RawBandwidth struct {
+	// BytesPerSecond is the raw data rate through the pipe.
+	BytesPerSecond float64
+	// FrameBytes is the size of one video frame or audio chunk as read by
+	// VideoReader.Read or AudioReader.Read.
+	FrameBytes int
+	// PipeBufferBytes is the recommended OS pipe buffer: one frame, rounded
+	// up to a power of two between 64 KiB and 1 MiB.
+	PipeBufferBytes int
+	// RingBufferFrames is the recommended number of frames (or chunks) a
+	// ring buffer between the reader and its consumer should hold.
+	RingBufferFrames int
+	// PipeThroughput is the sustained pipe throughput assumed for the
+	// current platform, in bytes per second.
+	PipeThroughput float64
+	// Warnings describe why the capture may not keep up, such as a data
+	// rate beyond what a pipe sustains on this platform.
+	Warnings []string
+}
+
+// EstimateVideoBandwidth estimates the raw data rate of capturing cfg with
+// NewVideoReader: YUV420p frames of the configured size and frame rate, with
+// the same defaults (640x480 at 30 fps). The device is not opened.
+func EstimateVideoBandwidth(cfg VideoConfig) RawBandwidth {
+	width, height, frameRate := cfg.Width, cfg.Height, cfg.FrameRate
+	if width == 0 && height == 0 {
+		width, height = 640, 480
+	}
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+	frameBytes := width * height * 3 / 2 // YUV420p
+	what := fmt.Sprintf("%dx%d at %g fps", width, height, frameRate)
+	return newRawBandwidth(what, frameBytes, frameRate, runtime.GOOS)
+}
+
+// EstimateAudioBandwidth estimates the raw data rate of capturing cfg with
+// NewAudioReader: S16LE samples of the configured rate and channel count,
+// read in chunks of the configured latency, with the same defaults (48 kHz
+// stereo, the latency profile's chunk). The device is not opened.
+func EstimateAudioBandwidth(cfg AudioConfig) RawBandwidth {
+	return defaultMediaDevices.EstimateAudioBandwidth(cfg)
+}
+
+// EstimateAudioBandwidth is like the package-level EstimateAudioBandwidth,
+// but the default chunk is that of the latency profile of m.
+func (m *MediaDevices) EstimateAudioBandwidth(cfg AudioConfig) RawBandwidth {
+	sampleRate, channels := cfg.SampleRate, cfg.Channels
+	if sampleRate <= 0 {
+		sampleRate = 48000
+	}
+	if channels <= 0 {
+		channels = 2
+	}
+	chunk, err := audioChunkDuration(m.Config().LatencyProfile, cfg.Latency, sampleRate)
+	if err != nil {
+		chunk = 20 * time.Millisecond
+	}
+	chunkBytes := int(float64(sampleRate)*chunk.Seconds()) * channels * 2 // 2 bytes per S16LE sample
+	what := fmt.Sprintf("%d Hz with %d channels", sampleRate, channels)
+	return newRawBandwidth(what, chunkBytes, 1/chunk.Seconds(), runtime.GOOS)
+}
+
+// newRawBandwidth returns the estimate for the capture described by what,
+// whose frames of frameBytes arrive at rate per second on the platform goos.
+func newRawBandwidth(what string, frameBytes int, rate float64, goos string) RawBandwidth {
+	b := RawBandwidth{
+		BytesPerSecond:   float64(frameBytes) * rate,
+		FrameBytes:       frameBytes,
+		PipeBufferBytes:  minPipeBuffer,
+		RingBufferFrames: max(2, int(math.Ceil(rate*ringBufferDuration.Seconds()))),
+		PipeThroughput:   pipeThroughput(goos),
+	}
+	for b.PipeBufferBytes < frameBytes && b.PipeBufferBytes < maxPipeBuffer {
+		b.PipeBufferBytes *= 2
+	}
+	if b.BytesPerSecond > b.PipeThroughput {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("%s (%s) exceeds the %s a pipe sustains on %s; lower the size or frame rate, or capture encoded video (NewH264Reader)",
+			what, formatByteRate(b.BytesPerSecond), formatByteRate(b.PipeThroughput), goos))
+	} else if b.BytesPerSecond > b.PipeThroughput*pipeLoadWarning {
+		b.Warnings = append(b.Warnings, fmt.Sprintf("%s (%s) is over half of the %s a pipe sustains on %s; frames may be dropped under load",
+			what, formatByteRate(b.BytesPerSecond), formatByteRate(b.PipeThroughput), goos))
+	}
+	return b
+}
+
+// pipeThroughput returns the assumed pipe throughput of goos.
+func pipeThroughput(goos string) float64 {
+	switch goos {
+	case "windows":
+		return pipeThroughputWindows
+	case "darwin":
+		return pipeThroughputDarwin
+	}
+	return pipeThroughputLinux
+}
+
+// formatByteRate formats a data rate in MB/s, or KB/s below 1 MB/s.
+func formatByteRate(bytesPerSecond float64) string {
+	if bytesPerSecond < 1<<20 {
+		return fmt.Sprintf("%.0f KB/s", bytesPerSecond/(1<<10))
+	}
+	return fmt.Sprintf("%.0f MB/s", bytesPerSecond/(1<<20))
+}
@@ -0,0 +1,56 @@
+package mediadevices
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEstimateVideoBandwidth(t *testing.T) {
+	b := EstimateVideoBandwidth(VideoConfig{})
+	if b.FrameBytes != 640*480*3/2 || b.BytesPerSecond != 640*480*3/2*30 {
+		t.Errorf("default 640x480@30: %+v", b)
+	}
+	if b.PipeBufferBytes != 512<<10 || b.RingBufferFrames != 8 || len(b.Warnings) != 0 {
+		t.Errorf("default 640x480@30: %+v", b)
+	}
+}
+
+func TestNewRawBandwidth_Warnings(t *testing.T) {
+	frame := 3840 * 2160 * 3 / 2 // 4K YUV420p
+	for _, tc := range []struct {
+		goos string
+		want string
+	}{
+		{"linux", "over half"},
+		{"windows", "exceeds"},
+	} {
+		b := newRawBandwidth("3840x2160 at 60 fps", frame, 60, tc.goos)
+		if b.PipeBufferBytes != maxPipeBuffer {
+			t.Errorf("%s: pipe buffer %d, want the 1 MiB maximum", tc.goos, b.PipeBufferBytes)
+		}
+		if len(b.Warnings) != 1 || !strings.Contains(b.Warnings[0], tc.want) || !strings.Contains(b.Warnings[0], "3840x2160") {
+			t.Errorf("%s: warnings = %q, want one containing %q", tc.goos, b.Warnings, tc.want)
+		}
+	}
+}
+
+func TestEstimateAudioBandwidth(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.LatencyProfile = ProfileRealtime
+	SetConfig(cfg)
+
+	b := EstimateAudioBandwidth(AudioConfig{})
+	if b.FrameBytes != 480*2*2 || b.BytesPerSecond != 48000*2*2 || b.RingBufferFrames != 25 {
+		t.Errorf("48 kHz stereo in 10 ms chunks: %+v", b)
+	}
+	if b.PipeBufferBytes != minPipeBuffer || len(b.Warnings) != 0 {
+		t.Errorf("48 kHz stereo in 10 ms chunks: %+v", b)
+	}
+	b = EstimateAudioBandwidth(AudioConfig{Channels: 1, Latency: 50 * time.Millisecond})
+	if b.FrameBytes != 2400*2 || b.RingBufferFrames != 5 {
+		t.Errorf("48 kHz mono in 50 ms chunks: %+v", b)
+	}
+}
@@ -0,0 +1,222 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// VideoMode is one capture mode supported by a camera.
+type VideoMode struct {
+	Width  int
+	Height int
+	// MinFrameRate and MaxFrameRate bound the frame rates of the mode.
+	// Both are zero when the backend does not report them (V4L2).
+	MinFrameRate float64
+	MaxFrameRate float64
+	// PixelFormat is the FFmpeg pixel format of a raw mode, such as
+	// "yuyv422" or "nv12". It is empty for compressed modes and on macOS.
+	PixelFormat string
+	// Codec is the FFmpeg codec of a compressed mode, such as "mjpeg" or
+	// "h264". It is empty for raw modes.
+	Codec string
+}
+
+// AudioMode is one capture format supported by a microphone.
+type AudioMode struct {
+	SampleRate    int
+	Channels      int
+	BitsPerSample int
+}
+
+// DeviceCapabilities lists the capture modes of a device, as reported by
+// GetDeviceCapabilities.
+type DeviceCapabilities struct {
+	DeviceID string
+	Kind     MediaDeviceKind
+	// VideoModes is set for video inputs, AudioModes for audio inputs on
+	// Windows. FFmpeg cannot list the formats of ALSA and AVFoundation
+	// microphones, so AudioModes is empty there.
+	VideoModes []VideoMode
+	AudioModes []AudioMode
+}
+
+// SupportsVideo reports whether a video mode has the given size and, when
+// fps is positive and the mode reports frame rates, includes fps in its
+// frame rate range.
+func (c DeviceCapabilities) SupportsVideo(width, height int, fps float64) bool {
+	for _, m := range c.VideoModes {
+		if m.Width != width || m.Height != height {
+			continue
+		}
+		if fps <= 0 || m.MaxFrameRate == 0 || (fps >= m.MinFrameRate && fps <= m.MaxFrameRate) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetDeviceCapabilities returns the capture modes supported by the
+// enumerated device with the given DeviceID, so that a capture can pick a
+// width, height and frame rate that the device actually delivers. It runs
+// FFmpeg once to query the device (-list_options with DirectShow,
+// -list_formats with V4L2, the supported-mode list of AVFoundation).
+//
+// It is equivalent to GetDeviceCapabilitiesContext with a background
+// context.
+func GetDeviceCapabilities(deviceID string) (DeviceCapabilities, error) {
+	return defaultMediaDevices.GetDeviceCapabilitiesContext(context.Background(), deviceID)
+}
+
+// GetDeviceCapabilities is like the package-level GetDeviceCapabilities,
+// but looks the device up among those of m.
+func (m *MediaDevices) GetDeviceCapabilities(deviceID string) (DeviceCapabilities, error) {
+	return m.GetDeviceCapabilitiesContext(context.Background(), deviceID)
+}
+
+// GetDeviceCapabilitiesContext is like GetDeviceCapabilities, but the
+// query is bounded by ctx, or by Config.DiscoveryTimeout (10 seconds by
+// default) if ctx has no deadline.
+func GetDeviceCapabilitiesContext(ctx context.Context, deviceID string) (DeviceCapabilities, error) {
+	return defaultMediaDevices.GetDeviceCapabilitiesContext(ctx, deviceID)
+}
+
+// GetDeviceCapabilitiesContext is like the package-level
+// GetDeviceCapabilitiesContext, but looks the device up among those of m
+// and queries it with the FFmpeg of m.
+func (m *MediaDevices) GetDeviceCapabilitiesContext(ctx context.Context, deviceID string) (DeviceCapabilities, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.enumerateTimeout())
+		defer cancel()
+	}
+
+	devices, err := m.enumerateDevicesRaw(ctx)
+	d, found := m.findDevice(devices, deviceID)
+	if !found {
+		if err != nil {
+			return DeviceCapabilities{}, err
+		}
+		return DeviceCapabilities{}, fmt.Errorf("%w: %s", ErrNotFound, deviceID)
+	}
+
+	caps := DeviceCapabilities{DeviceID: d.DeviceID, Kind: d.Kind}
+	probe := probeDeviceModes
+	switch name := ffmpegDeviceName(d); {
+	case isDeckLinkDevice(name):
+		probe = probeDeckLinkModes
+	case isVirtualDevice(name):
+		// A file or stream has the one mode it was encoded with.
+		return caps, nil
+	case isSyntheticDevice(name):
+		// Test sources generate any size and rate.
+		return caps, nil
+	}
+	output, err := probe(ctx, m.Config().FFmpegPath, d.Kind, ffmpegDeviceName(d))
+	if err != nil {
+		return caps, fmt.Errorf("ffmpeg: device capabilities of %s: %w", deviceID, err)
+	}
+	caps.VideoModes, caps.AudioModes = parseDeviceModes(output)
+	if d.Kind == MediaDeviceKindVideoInput && len(caps.VideoModes) == 0 && output != "" {
+		return caps, fmt.Errorf("ffmpeg: no capture modes reported for %s: %s", deviceID, lastLine(output))
+	}
+	return caps, nil
+}
+
+var (
+	// dshowVideoModeRe matches DirectShow -list_options lines like
+	// "  vcodec=mjpeg  min s=1280x720 fps=5 max s=1280x720 fps=30" and
+	// "  pixel_format=yuyv422  min s=640x480 fps=5 max s=640x480 fps=30".
+	dshowVideoModeRe = regexp.MustCompile(`(?:vcodec=(\S+)|pixel_format=(\S+))\s+min s=\d+x\d+ fps=([\d.]+) max s=(\d+)x(\d+) fps=([\d.]+)`)
+	// dshowAudioModeRe matches "  ch= 2, bits=16, rate= 44100".
+	dshowAudioModeRe = regexp.MustCompile(`ch=\s*(\d+), bits=\s*(\d+), rate=\s*(\d+)`)
+	// v4l2FormatRe matches V4L2 -list_formats lines like
+	// "Raw       :     yuyv422 :           YUYV 4:2:2 : 640x480 320x240".
+	v4l2FormatRe = regexp.MustCompile(`(Raw|Compressed|Emulated)\s*:\s*(\S+)\s*:.*:\s*((?:\d+x\d+\s*)+)$`)
+	// avfModeRe matches AVFoundation "  1280x720@[15.000000 30.000000]fps".
+	avfModeRe = regexp.MustCompile(`(\d+)x(\d+)@\[([\d.]+)\s+([\d.]+)\]fps`)
+	// deckLinkFormatRe matches DeckLink -list_formats lines like
+	// "	Hp60		1920x1080 at 60000/1000 fps".
+	deckLinkFormatRe = regexp.MustCompile(`\s(\d+)x(\d+) at (\d+)/(\d+) fps`)
+	modeSizeRe       = regexp.MustCompile(`(\d+)x(\d+)`)
+)
+
+// parseDeviceModes extracts the modes from FFmpeg output in any of the
+// DirectShow, V4L2, AVFoundation or DeckLink formats. Duplicate modes, such as
+// DirectShow's per-colorspace repetitions, are dropped.
+func parseDeviceModes(output string) ([]VideoMode, []AudioMode) {
+	var video []VideoMode
+	var audio []AudioMode
+	seenVideo := make(map[VideoMode]bool)
+	seenAudio := make(map[AudioMode]bool)
+	addVideo := func(m VideoMode) {
+		if !seenVideo[m] {
+			seenVideo[m] = true
+			video = append(video, m)
+		}
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := dshowVideoModeRe.FindStringSubmatch(line); m != nil {
+			mode := VideoMode{Codec: m[1], PixelFormat: m[2]}
+			mode.MinFrameRate, _ = strconv.ParseFloat(m[3], 64)
+			mode.Width, _ = strconv.Atoi(m[4])
+			mode.Height, _ = strconv.Atoi(m[5])
+			mode.MaxFrameRate, _ = strconv.ParseFloat(m[6], 64)
+			addVideo(mode)
+		} else if m := dshowAudioModeRe.FindStringSubmatch(line); m != nil {
+			var mode AudioMode
+			mode.Channels, _ = strconv.Atoi(m[1])
+			mode.BitsPerSample, _ = strconv.Atoi(m[2])
+			mode.SampleRate, _ = strconv.Atoi(m[3])
+			if !seenAudio[mode] {
+				seenAudio[mode] = true
+				audio = append(audio, mode)
+			}
+		} else if m := v4l2FormatRe.FindStringSubmatch(line); m != nil {
+			if m[2] == "Unsupported" {
+				continue
+			}
+			for _, s := range modeSizeRe.FindAllStringSubmatch(m[3], -1) {
+				var mode VideoMode
+				if m[1] == "Compressed" {
+					mode.Codec = m[2]
+				} else {
+					mode.PixelFormat = m[2]
+				}
+				mode.Width, _ = strconv.Atoi(s[1])
+				mode.Height, _ = strconv.Atoi(s[2])
+				addVideo(mode)
+			}
+		} else if m := avfModeRe.FindStringSubmatch(line); m != nil {
+			var mode VideoMode
+			mode.Width, _ = strconv.Atoi(m[1])
+			mode.Height, _ = strconv.Atoi(m[2])
+			mode.MinFrameRate, _ = strconv.ParseFloat(m[3], 64)
+			mode.MaxFrameRate, _ = strconv.ParseFloat(m[4], 64)
+			addVideo(mode)
+		} else if m := deckLinkFormatRe.FindStringSubmatch(line); m != nil {
+			var mode VideoMode
+			mode.Width, _ = strconv.Atoi(m[1])
+			mode.Height, _ = strconv.Atoi(m[2])
+			num, _ := strconv.ParseFloat(m[3], 64)
+			den, _ := strconv.ParseFloat(m[4], 64)
+			if den > 0 {
+				mode.MinFrameRate = num / den
+				mode.MaxFrameRate = mode.MinFrameRate
+			}
+			addVideo(mode)
+		}
+	}
+	return video, audio
+}
+
+// lastLine returns the last non-empty line of FFmpeg output, which usually
+// holds the error.
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
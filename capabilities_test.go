@@ -0,0 +1,102 @@
+package mediadevices
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+func TestParseDeviceModes_Dshow(t *testing.T) {
+	output := `[dshow @ 000001] DirectShow video device options (from video devices)
+[dshow @ 000001]  Pin "Capture" (alternative pin name "0")
+[dshow @ 000001]   vcodec=mjpeg  min s=1280x720 fps=5 max s=1280x720 fps=30
+[dshow @ 000001]   vcodec=mjpeg  min s=1280x720 fps=5 max s=1280x720 fps=30 (tv, bt470bg/bt709/unknown, topleft)
+[dshow @ 000001]   pixel_format=yuyv422  min s=640x480 fps=5 max s=640x480 fps=29.97
+[dshow @ 000001] DirectShow audio only device options (from audio devices)
+[dshow @ 000001]   ch= 2, bits=16, rate= 44100
+[dshow @ 000001]   ch= 1, bits=16, rate= 48000
+video=Integrated Camera: Immediate exit requested
+`
+	video, audio := parseDeviceModes(output)
+	wantVideo := []VideoMode{
+		{Width: 1280, Height: 720, MinFrameRate: 5, MaxFrameRate: 30, Codec: "mjpeg"},
+		{Width: 640, Height: 480, MinFrameRate: 5, MaxFrameRate: 29.97, PixelFormat: "yuyv422"},
+	}
+	if !reflect.DeepEqual(video, wantVideo) {
+		t.Errorf("video = %+v, want %+v", video, wantVideo)
+	}
+	wantAudio := []AudioMode{{SampleRate: 44100, Channels: 2, BitsPerSample: 16}, {SampleRate: 48000, Channels: 1, BitsPerSample: 16}}
+	if !reflect.DeepEqual(audio, wantAudio) {
+		t.Errorf("audio = %+v, want %+v", audio, wantAudio)
+	}
+}
+
+func TestParseDeviceModes_V4L2(t *testing.T) {
+	output := `[video4linux2,v4l2 @ 0x55d0] Raw       :     yuyv422 :           YUYV 4:2:2 : 640x480 320x240
+[video4linux2,v4l2 @ 0x55d0] Compressed:       mjpeg :          Motion-JPEG : 1280x720
+[video4linux2,v4l2 @ 0x55d0] Compressed: Unsupported :               H.265 : 1920x1080
+/dev/video0: Immediate exit requested
+`
+	video, audio := parseDeviceModes(output)
+	want := []VideoMode{
+		{Width: 640, Height: 480, PixelFormat: "yuyv422"},
+		{Width: 320, Height: 240, PixelFormat: "yuyv422"},
+		{Width: 1280, Height: 720, Codec: "mjpeg"},
+	}
+	if !reflect.DeepEqual(video, want) || audio != nil {
+		t.Errorf("modes = %+v, %+v; want %+v", video, audio, want)
+	}
+}
+
+func TestParseDeviceModes_AVFoundation(t *testing.T) {
+	output := `[avfoundation @ 0x7f9] Selected video size (1x1) is not supported by the device.
+[avfoundation @ 0x7f9] Supported modes:
+[avfoundation @ 0x7f9]   640x480@[15.000000 30.000000]fps
+[avfoundation @ 0x7f9]   1280x720@[15.000000 30.000000]fps
+0:none: Input/output error
+`
+	video, _ := parseDeviceModes(output)
+	want := []VideoMode{
+		{Width: 640, Height: 480, MinFrameRate: 15, MaxFrameRate: 30},
+		{Width: 1280, Height: 720, MinFrameRate: 15, MaxFrameRate: 30},
+	}
+	if !reflect.DeepEqual(video, want) {
+		t.Errorf("video = %+v, want %+v", video, want)
+	}
+}
+
+func TestDeviceCapabilities_SupportsVideo(t *testing.T) {
+	c := DeviceCapabilities{VideoModes: []VideoMode{
+		{Width: 1280, Height: 720, MinFrameRate: 5, MaxFrameRate: 30},
+		{Width: 640, Height: 480}, // V4L2: no frame rates
+	}}
+	for _, tc := range []struct {
+		w, h int
+		fps  float64
+		want bool
+	}{
+		{1280, 720, 30, true},
+		{1280, 720, 60, false},
+		{1280, 720, 0, true},
+		{640, 480, 60, true},
+		{1920, 1080, 30, false},
+	} {
+		if got := c.SupportsVideo(tc.w, tc.h, tc.fps); got != tc.want {
+			t.Errorf("SupportsVideo(%d, %d, %v) = %v, want %v", tc.w, tc.h, tc.fps, got, tc.want)
+		}
+	}
+}
+
+func TestGetDeviceCapabilities_UnknownDevice(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{{DeviceID: "cam-1", Kind: MediaDeviceKindVideoInput}}, nil
+	}
+	SetConfig(cfg)
+
+	if _, err := GetDeviceCapabilities("nope"); err == nil {
+		t.Error("unknown device accepted")
+	}
+}
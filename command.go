@@ -1,6 +1,9 @@
 package mediadevices
 
-import "fmt"
+import (
+	"fmt"
+	"image"
+)
 
 // VideoCaptureParams holds parameters for building video capture FFmpeg arguments.
 type VideoCaptureParams struct {
@@ -9,6 +12,11 @@ type VideoCaptureParams struct {
 	Height      int
 	FrameRate   float64
 	PixelFormat string // output pixel format, defaults to "yuv420p"
+	Profile     LatencyProfile
+	// UseWallclockTimestamps stamps input packets with the system clock
+	// (-use_wallclock_as_timestamps) and passes every captured frame through
+	// unchanged instead of resampling to a constant frame rate.
+	UseWallclockTimestamps bool
 }
 
 // AudioCaptureParams holds parameters for building audio capture FFmpeg arguments.
@@ -16,6 +24,128 @@ type AudioCaptureParams struct {
 	DeviceID   string
 	SampleRate int
 	Channels   int
+	Profile    LatencyProfile
+	// UseWallclockTimestamps stamps input packets with the system clock
+	// (-use_wallclock_as_timestamps).
+	UseWallclockTimestamps bool
+	// InputRate, if set, is the rate the device really delivers samples
+	// at while claiming SampleRate; the samples are resampled from it.
+	InputRate int
+}
+
+// AudioPlaybackParams holds parameters for building audio playback FFmpeg arguments.
+type AudioPlaybackParams struct {
+	DeviceID   string
+	SampleRate int
+	Channels   int
+	// LowLatency keeps FFmpeg and the output device from buffering more
+	// than necessary, for monitoring.
+	LowLatency bool
+}
+
+// audioPlaybackInputArgs builds the input of a playback process: raw PCM
+// S16LE on stdin.
+func audioPlaybackInputArgs(p AudioPlaybackParams) []string {
+	var args []string
+	if p.LowLatency {
+		args = append(args, "-fflags", "nobuffer")
+	}
+	return append(args,
+		"-f", "s16le",
+		"-ar", fmt.Sprintf("%d", p.SampleRate),
+		"-ac", fmt.Sprintf("%d", p.Channels),
+		"-i", "pipe:0",
+	)
+}
+
+// DisplayCaptureBackend selects the FFmpeg screen grabber on platforms that
+// offer more than one.
+type DisplayCaptureBackend string
+
+const (
+	// DisplayCaptureBackendGDIGrab captures through GDI (Windows, the default).
+	// It works everywhere, including window capture, but copies every frame
+	// through system memory and cannot sustain high frame rates at 4K.
+	DisplayCaptureBackendGDIGrab DisplayCaptureBackend = "gdigrab"
+	// DisplayCaptureBackendDDAGrab captures through the Desktop Duplication
+	// API (Windows 8+) into GPU frames, which are downloaded and converted
+	// with hwdownload. It captures one monitor at a time.
+	DisplayCaptureBackendDDAGrab DisplayCaptureBackend = "ddagrab"
+)
+
+// DisplayCaptureParams holds parameters for building screen capture FFmpeg arguments.
+type DisplayCaptureParams struct {
+	// Display selects the screen: the X11 display on Linux and the BSDs
+	// (defaults to $DISPLAY), the AVFoundation screen index on macOS and
+	// the framebuffer device on Android (defaults to /dev/graphics/fb0).
+	// Windows always captures the virtual desktop.
+	Display string
+	// Region is the rectangle to capture, in desktop pixel coordinates.
+	// The empty rectangle captures the whole display.
+	Region image.Rectangle
+	// Window is the native handle (HWND on Windows, X11 window ID on Linux)
+	// of a window to capture instead of the display. Region is ignored.
+	Window uintptr
+	// Backend selects the grabber; empty means the platform default.
+	Backend DisplayCaptureBackend
+	// Output is the DXGI output (monitor) index captured by ddagrab.
+	// With ddagrab, Region is relative to this output.
+	Output int
+	// Width and Height are the output frame size.
+	Width     int
+	Height    int
+	FrameRate float64
+	// DrawCursor draws the mouse pointer into the captured frames.
+	DrawCursor bool
+	Profile    LatencyProfile
+	// UseWallclockTimestamps is as in VideoCaptureParams.
+	UseWallclockTimestamps bool
+}
+
+// windowFitFilter scales a captured window of any size into a w x h frame,
+// keeping its aspect ratio and padding with black, so that the output size
+// stays the same when the window is resized.
+func windowFitFilter(w, h int) string {
+	return fmt.Sprintf("scale=%d:%d:force_original_aspect_ratio=decrease,pad=%d:%d:(ow-iw)/2:(oh-ih)/2", w, h, w, h)
+}
+
+// wallclockInputArgs returns the FFmpeg input options (placed before -i)
+// that stamp packets with the system clock instead of the device clock, so
+// that captures from separate devices share one time base.
+func wallclockInputArgs(enabled bool) []string {
+	if !enabled {
+		return nil
+	}
+	return []string{"-use_wallclock_as_timestamps", "1"}
+}
+
+// portableVideoInputArgs returns the FFmpeg input arguments of the video
+// devices that are opened the same way on every platform: DeckLink inputs,
+// virtual devices and synthetic test sources. ok is false for the
+// platform's own devices.
+func portableVideoInputArgs(p VideoCaptureParams) (args []string, ok bool) {
+	switch {
+	case isDeckLinkDevice(p.DeviceID):
+		return buildDeckLinkVideoInputArgs(p), true
+	case isVirtualDevice(p.DeviceID):
+		return buildVirtualInputArgs(p.DeviceID, p.Profile, p.UseWallclockTimestamps), true
+	case isSyntheticDevice(p.DeviceID):
+		return buildSyntheticVideoInputArgs(p), true
+	}
+	return nil, false
+}
+
+// portableAudioInputArgs is portableVideoInputArgs for audio devices.
+func portableAudioInputArgs(p AudioCaptureParams) (args []string, ok bool) {
+	switch {
+	case isDeckLinkDevice(p.DeviceID):
+		return buildDeckLinkAudioInputArgs(p), true
+	case isVirtualDevice(p.DeviceID):
+		return buildVirtualInputArgs(p.DeviceID, p.Profile, p.UseWallclockTimestamps), true
+	case isSyntheticDevice(p.DeviceID):
+		return buildSyntheticAudioInputArgs(p), true
+	}
+	return nil, false
 }
 
 // videoOutputArgs returns the common output arguments for raw video capture.
@@ -24,10 +154,16 @@ func videoOutputArgs(p VideoCaptureParams) []string {
 	if pixFmt == "" {
 		pixFmt = "yuv420p"
 	}
-	args := []string{
+	var args []string
+	if p.UseWallclockTimestamps {
+		// rawvideo defaults to constant frame rate, which would duplicate or
+		// drop frames and break the frame-to-capture-time correspondence
+		args = append(args, "-fps_mode", "passthrough")
+	}
+	args = append(args,
 		"-f", "rawvideo",
 		"-pix_fmt", pixFmt,
-	}
+	)
 	if p.Width > 0 && p.Height > 0 {
 		args = append(args, "-video_size", fmt.Sprintf("%dx%d", p.Width, p.Height))
 	}
@@ -35,12 +171,45 @@ func videoOutputArgs(p VideoCaptureParams) []string {
 	return args
 }
 
+// buildEchoCaptureArgs builds FFmpeg arguments that capture a microphone and
+// the render reference in one process and output them as one interleaved
+// stream: the channels of mic followed by those of ref. Both inputs are
+// resampled to mic's rate and layout; aresample's async mode absorbs the
+// drift between the two device clocks.
+func buildEchoCaptureArgs(mic, ref AudioCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(mic)...)
+	args = append(args, buildLoopbackInputArgs(ref)...)
+
+	layout := "mono"
+	if mic.Channels == 2 {
+		layout = "stereo"
+	}
+	format := fmt.Sprintf("aresample=%d:async=1,aformat=sample_fmts=s16:channel_layouts=%s", mic.SampleRate, layout)
+	args = append(args,
+		"-filter_complex", fmt.Sprintf("[0:a]%s[near];[1:a]%s[far];[near][far]amerge=inputs=2[out]", format, format),
+		"-map", "[out]",
+	)
+
+	// The merged stream keeps all channels of both inputs, so no -ac.
+	out := mic
+	out.Channels = 0
+	args = append(args, audioOutputArgs(out)...)
+
+	return args
+}
+
 // audioOutputArgs returns the common output arguments for raw audio capture.
 func audioOutputArgs(p AudioCaptureParams) []string {
-	args := []string{
+	var args []string
+	if p.InputRate > 0 && p.SampleRate > 0 && p.InputRate != p.SampleRate {
+		// Relabel the samples with their true rate, then convert.
+		args = append(args, "-af", fmt.Sprintf("asetrate=%d,aresample=%d", p.InputRate, p.SampleRate))
+	}
+	args = append(args,
 		"-f", "s16le",
 		"-acodec", "pcm_s16le",
-	}
+	)
 	if p.SampleRate > 0 {
 		args = append(args, "-ar", fmt.Sprintf("%d", p.SampleRate))
 	}
@@ -0,0 +1,144 @@
+//go:build android
+
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+)
+
+// buildVideoCaptureArgs builds FFmpeg arguments for capturing video via the
+// NDK Camera2 API (android_camera) on Android.
+func buildVideoCaptureArgs(p VideoCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildVideoInputArgs(p)...)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(p)...)
+
+	return args
+}
+
+// buildVideoInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for a camera via android_camera on Android, or for a virtual
+// device.
+func buildVideoInputArgs(p VideoCaptureParams) []string {
+	if args, ok := portableVideoInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
+
+	// Input format
+	args = append(args, "-f", "android_camera")
+
+	// Input options
+	if p.Width > 0 && p.Height > 0 {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", p.Width, p.Height))
+	}
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+	// Camera index: 0, 1
+	args = append(args, "-camera_index", p.DeviceID)
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// android_camera ignores the input name; the camera is selected above.
+	args = append(args, "-i", "android_camera")
+
+	return args
+}
+
+// buildAudioCaptureArgs builds FFmpeg arguments for capturing audio via
+// OpenAL on Android.
+func buildAudioCaptureArgs(p AudioCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(p)...)
+
+	// Output: raw PCM S16LE to stdout
+	args = append(args, audioOutputArgs(p)...)
+
+	return args
+}
+
+// buildAudioInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for a microphone via OpenAL on Android, or for a virtual device.
+// FFmpeg has no OpenSL ES or AAudio input of its own; OpenAL Soft records
+// through AAudio, or OpenSL ES on releases before Android 8.1.
+func buildAudioInputArgs(p AudioCaptureParams) []string {
+	if args, ok := portableAudioInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
+
+	// Input format
+	args = append(args, "-f", "openal")
+
+	// Input options
+	if p.SampleRate > 0 {
+		args = append(args, "-sample_rate", fmt.Sprintf("%d", p.SampleRate))
+	}
+	if p.Channels > 0 {
+		args = append(args, "-channels", fmt.Sprintf("%d", p.Channels))
+	}
+	args = append(args, "-sample_size", "16")
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input device: an OpenAL capture device name as listed by discovery
+	args = append(args, "-i", p.DeviceID)
+
+	return args
+}
+
+// buildDisplayCaptureArgs builds FFmpeg arguments for capturing the screen
+// from the framebuffer device (fbdev) on Android. FFmpeg cannot use the
+// MediaProjection API, so this only works where the process may read
+// /dev/graphics/fb0, as on rooted devices and some emulators. Regions and
+// windows are not supported.
+func buildDisplayCaptureArgs(p DisplayCaptureParams) []string {
+	args := []string{"-y"}
+
+	// Input format
+	args = append(args, "-f", "fbdev")
+
+	// Input options
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input framebuffer: /dev/graphics/fb0
+	device := p.Display
+	if device == "" {
+		device = "/dev/graphics/fb0"
+	}
+	args = append(args, "-i", device)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
+
+	return args
+}
+
+// buildLoopbackInputArgs builds the FFmpeg input arguments for the render
+// reference of an echo canceller. Android lists no loopback inputs, so this
+// is only reached with a ReferenceDeviceID, captured like a microphone.
+func buildLoopbackInputArgs(p AudioCaptureParams) []string {
+	return buildAudioInputArgs(p)
+}
+
+// buildAudioPlaybackArgs reports that FFmpeg cannot play audio on Android:
+// it has no OpenSL ES or AAudio output device.
+func buildAudioPlaybackArgs(p AudioPlaybackParams) ([]string, error) {
+	return nil, errors.New("audio playback is not supported on android: FFmpeg has no Android audio output device")
+}
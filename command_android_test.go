@@ -0,0 +1,38 @@
+//go:build android
+
+package mediadevices
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildVideoCaptureArgs_Android(t *testing.T) {
+	args := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: "1", Width: 1280, Height: 720, FrameRate: 30}), " ")
+	if !strings.Contains(args, "-f android_camera -video_size 1280x720 -framerate 30 -camera_index 1 -i android_camera ") {
+		t.Errorf("video args: %s", args)
+	}
+	if !strings.HasSuffix(args, " pipe:1") {
+		t.Errorf("video args do not end with pipe:1: %s", args)
+	}
+}
+
+func TestBuildAudioCaptureArgs_Android(t *testing.T) {
+	args := strings.Join(buildAudioCaptureArgs(AudioCaptureParams{DeviceID: "Android Default", SampleRate: 48000, Channels: 1}), " ")
+	if !strings.Contains(args, "-f openal -sample_rate 48000 -channels 1 -sample_size 16 -i Android Default ") {
+		t.Errorf("audio args: %s", args)
+	}
+}
+
+func TestBuildDisplayCaptureArgs_Android(t *testing.T) {
+	args := strings.Join(buildDisplayCaptureArgs(DisplayCaptureParams{Width: 1080, Height: 1920}), " ")
+	if !strings.Contains(args, "-f fbdev -i /dev/graphics/fb0 ") {
+		t.Errorf("display args: %s", args)
+	}
+}
+
+func TestBuildAudioPlaybackArgs_Android(t *testing.T) {
+	if _, err := buildAudioPlaybackArgs(AudioPlaybackParams{DeviceID: "Android Default"}); err == nil {
+		t.Error("buildAudioPlaybackArgs succeeded on Android")
+	}
+}
@@ -0,0 +1,168 @@
+//go:build freebsd || openbsd
+
+package mediadevices
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// bktrDevicePrefix marks the Brooktree frame grabbers of FreeBSD, which
+// FFmpeg opens with its bktr input instead of v4l2.
+const bktrDevicePrefix = "/dev/bktr"
+
+// buildVideoCaptureArgs builds FFmpeg arguments for capturing video via the
+// V4L2 compatible video devices on FreeBSD (webcamd) and OpenBSD (video(4)).
+func buildVideoCaptureArgs(p VideoCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildVideoInputArgs(p)...)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(p)...)
+
+	return args
+}
+
+// buildVideoInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for a video device via V4L2 on the BSDs, a bktr frame grabber, or
+// a virtual device.
+func buildVideoInputArgs(p VideoCaptureParams) []string {
+	if args, ok := portableVideoInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
+
+	// Input format
+	if strings.HasPrefix(p.DeviceID, bktrDevicePrefix) {
+		args = append(args, "-f", "bktr")
+	} else {
+		args = append(args, "-f", "v4l2")
+	}
+
+	// Input options
+	if p.Width > 0 && p.Height > 0 {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", p.Width, p.Height))
+	}
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input device: /dev/video0 or /dev/bktr0
+	args = append(args, "-i", p.DeviceID)
+
+	return args
+}
+
+// buildAudioCaptureArgs builds FFmpeg arguments for capturing audio via OSS
+// on FreeBSD or sndio on OpenBSD.
+func buildAudioCaptureArgs(p AudioCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(p)...)
+
+	// Output: raw PCM S16LE to stdout
+	args = append(args, audioOutputArgs(p)...)
+
+	return args
+}
+
+// buildAudioInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for an audio device via the platform's sound system (audioSystem),
+// or for a virtual device.
+func buildAudioInputArgs(p AudioCaptureParams) []string {
+	if args, ok := portableAudioInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
+
+	// Input format
+	args = append(args, "-f", audioSystem)
+
+	// Input options
+	if p.SampleRate > 0 {
+		args = append(args, "-sample_rate", fmt.Sprintf("%d", p.SampleRate))
+	}
+	if p.Channels > 0 {
+		args = append(args, "-channels", fmt.Sprintf("%d", p.Channels))
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input device: /dev/dsp0 (OSS) or snd/0 (sndio)
+	args = append(args, "-i", p.DeviceID)
+
+	return args
+}
+
+// buildDisplayCaptureArgs builds FFmpeg arguments for capturing the screen
+// via x11grab, as on Linux.
+func buildDisplayCaptureArgs(p DisplayCaptureParams) []string {
+	args := []string{"-y"}
+
+	// Input format
+	args = append(args, "-f", "x11grab")
+
+	// Input options
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+	if !p.DrawCursor {
+		args = append(args, "-draw_mouse", "0")
+	}
+	if p.Window != 0 {
+		args = append(args, "-window_id", fmt.Sprintf("%#x", p.Window))
+	} else if !p.Region.Empty() {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", p.Region.Dx(), p.Region.Dy()))
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input display: ":0.0", or ":0.0+X,Y" for the top-left corner of the region
+	display := p.Display
+	if display == "" {
+		display = os.Getenv("DISPLAY")
+	}
+	if display == "" {
+		display = ":0.0"
+	}
+	if p.Window == 0 && !p.Region.Empty() {
+		display += fmt.Sprintf("+%d,%d", p.Region.Min.X, p.Region.Min.Y)
+	}
+	args = append(args, "-i", display)
+
+	// Keep the output size fixed when the window is resized
+	if p.Window != 0 {
+		args = append(args, "-vf", windowFitFilter(p.Width, p.Height))
+	}
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
+
+	return args
+}
+
+// buildLoopbackInputArgs builds the FFmpeg input arguments for the render
+// reference of an echo canceller. The reference is an audio input given by
+// ReferenceDeviceID, such as a virtual_oss loopback device on FreeBSD or
+// sndiod's monitor sub-device (snd/0.mon) on OpenBSD, captured like a
+// microphone.
+func buildLoopbackInputArgs(p AudioCaptureParams) []string {
+	return buildAudioInputArgs(p)
+}
+
+// buildAudioPlaybackArgs builds FFmpeg arguments for playing PCM from stdin
+// on an OSS device on FreeBSD or a sndio device on OpenBSD.
+func buildAudioPlaybackArgs(p AudioPlaybackParams) ([]string, error) {
+	args := audioPlaybackInputArgs(p)
+	return append(args, "-f", audioSystem, p.DeviceID), nil
+}
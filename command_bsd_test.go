@@ -0,0 +1,33 @@
+//go:build freebsd || openbsd
+
+package mediadevices
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBuildVideoCaptureArgs_BSD(t *testing.T) {
+	video := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: "/dev/video0", Width: 640, Height: 480, FrameRate: 30}), " ")
+	if !strings.Contains(video, "-f v4l2 -video_size 640x480 -framerate 30 -i /dev/video0 ") {
+		t.Errorf("video args: %s", video)
+	}
+	bktr := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: "/dev/bktr0"}), " ")
+	if !strings.Contains(bktr, "-f bktr -i /dev/bktr0 ") {
+		t.Errorf("bktr args: %s", bktr)
+	}
+}
+
+func TestBuildAudioArgs_BSD(t *testing.T) {
+	audio := strings.Join(buildAudioCaptureArgs(AudioCaptureParams{DeviceID: "snd/0", SampleRate: 48000, Channels: 2}), " ")
+	if !strings.Contains(audio, "-f "+audioSystem+" -sample_rate 48000 -channels 2 -i snd/0 ") {
+		t.Errorf("audio args: %s", audio)
+	}
+	args, err := buildAudioPlaybackArgs(AudioPlaybackParams{DeviceID: "/dev/dsp0", SampleRate: 48000, Channels: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if playback := strings.Join(args, " "); !strings.HasSuffix(playback, "-f "+audioSystem+" /dev/dsp0") {
+		t.Errorf("playback args: %s", playback)
+	}
+}
@@ -7,6 +7,23 @@ import "fmt"
 // buildVideoCaptureArgs builds FFmpeg arguments for capturing video via AVFoundation on macOS.
 func buildVideoCaptureArgs(p VideoCaptureParams) []string {
 	args := []string{"-y"}
+	args = append(args, buildVideoInputArgs(p)...)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(p)...)
+
+	return args
+}
+
+// buildVideoInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for a video device via AVFoundation on macOS, or for a DeckLink
+// input or virtual device.
+func buildVideoInputArgs(p VideoCaptureParams) []string {
+	if args, ok := portableVideoInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
 
 	// Input format
 	args = append(args, "-f", "avfoundation")
@@ -19,18 +36,36 @@ func buildVideoCaptureArgs(p VideoCaptureParams) []string {
 		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
 	}
 
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
 	// Input device: "INDEX:none" (video only, no audio)
 	args = append(args, "-i", fmt.Sprintf("%s:none", p.DeviceID))
 
-	// Output: raw YUV420p to stdout
-	args = append(args, videoOutputArgs(p)...)
-
 	return args
 }
 
 // buildAudioCaptureArgs builds FFmpeg arguments for capturing audio via AVFoundation on macOS.
 func buildAudioCaptureArgs(p AudioCaptureParams) []string {
 	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(p)...)
+
+	// Output: raw PCM S16LE to stdout
+	args = append(args, audioOutputArgs(p)...)
+
+	return args
+}
+
+// buildAudioInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for an audio device via AVFoundation on macOS, or for the
+// embedded audio of a DeckLink input or virtual device.
+func buildAudioInputArgs(p AudioCaptureParams) []string {
+	if args, ok := portableAudioInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
 
 	// Input format
 	args = append(args, "-f", "avfoundation")
@@ -43,11 +78,67 @@ func buildAudioCaptureArgs(p AudioCaptureParams) []string {
 		args = append(args, "-ac", fmt.Sprintf("%d", p.Channels))
 	}
 
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
 	// Input device: "none:INDEX" (no video, audio only)
 	args = append(args, "-i", fmt.Sprintf("none:%s", p.DeviceID))
 
-	// Output: raw PCM S16LE to stdout
-	args = append(args, audioOutputArgs(p)...)
+	return args
+}
+
+// buildDisplayCaptureArgs builds FFmpeg arguments for capturing the screen via AVFoundation on macOS.
+// AVFoundation cannot capture individual windows, so p.Window is not supported.
+func buildDisplayCaptureArgs(p DisplayCaptureParams) []string {
+	args := []string{"-y"}
+
+	// Input format
+	args = append(args, "-f", "avfoundation")
+
+	// Input options
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+	if p.DrawCursor {
+		args = append(args, "-capture_cursor", "1")
+	} else {
+		args = append(args, "-capture_cursor", "0")
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input device: "Capture screen N:none" (video only, no audio)
+	screen := p.Display
+	if screen == "" {
+		screen = "0"
+	}
+	args = append(args, "-i", fmt.Sprintf("Capture screen %s:none", screen))
+
+	// AVFoundation always captures the full screen, so crop to the region
+	if !p.Region.Empty() {
+		args = append(args, "-vf", fmt.Sprintf("crop=%d:%d:%d:%d",
+			p.Region.Dx(), p.Region.Dy(), p.Region.Min.X, p.Region.Min.Y))
+	}
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
 
 	return args
 }
+
+// buildLoopbackInputArgs builds the FFmpeg input arguments for the render
+// reference of an echo canceller. The reference is a loopback audio input
+// device here, captured like a microphone.
+func buildLoopbackInputArgs(p AudioCaptureParams) []string {
+	return buildAudioInputArgs(p)
+}
+
+// buildAudioPlaybackArgs builds FFmpeg arguments for playing PCM from stdin
+// on a Core Audio device via AudioToolbox on macOS.
+func buildAudioPlaybackArgs(p AudioPlaybackParams) ([]string, error) {
+	args := audioPlaybackInputArgs(p)
+	return append(args, "-f", "audiotoolbox", "-audio_device_index", p.DeviceID, "-"), nil
+}
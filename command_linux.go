@@ -1,12 +1,33 @@
-//go:build linux
+//go:build linux && !android
 
 package mediadevices
 
-import "fmt"
+import (
+	"fmt"
+	"os"
+	"strings"
+)
 
 // buildVideoCaptureArgs builds FFmpeg arguments for capturing video via V4L2 on Linux.
 func buildVideoCaptureArgs(p VideoCaptureParams) []string {
 	args := []string{"-y"}
+	args = append(args, buildVideoInputArgs(p)...)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(p)...)
+
+	return args
+}
+
+// buildVideoInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for a video device via V4L2 on Linux, or for a DeckLink
+// input or virtual device.
+func buildVideoInputArgs(p VideoCaptureParams) []string {
+	if args, ok := portableVideoInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
 
 	// Input format
 	args = append(args, "-f", "v4l2")
@@ -19,18 +40,41 @@ func buildVideoCaptureArgs(p VideoCaptureParams) []string {
 		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
 	}
 
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
 	// Input device: /dev/video0
 	args = append(args, "-i", p.DeviceID)
 
-	// Output: raw YUV420p to stdout
-	args = append(args, videoOutputArgs(p)...)
-
 	return args
 }
 
 // buildAudioCaptureArgs builds FFmpeg arguments for capturing audio via ALSA on Linux.
 func buildAudioCaptureArgs(p AudioCaptureParams) []string {
 	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(p)...)
+
+	// Output: raw PCM S16LE to stdout
+	args = append(args, audioOutputArgs(p)...)
+
+	return args
+}
+
+// buildAudioInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for an audio device via ALSA on Linux, or via PulseAudio for the
+// sources of the sound server ("pulse:<source>"), or for the embedded audio
+// of a DeckLink input or virtual device.
+func buildAudioInputArgs(p AudioCaptureParams) []string {
+	if args, ok := portableAudioInputArgs(p); ok {
+		return args
+	}
+	if source, ok := strings.CutPrefix(p.DeviceID, pulseDevicePrefix); ok {
+		p.DeviceID = source
+		return buildPulseInputArgs(p)
+	}
+
+	var args []string
 
 	// Input format
 	args = append(args, "-f", "alsa")
@@ -43,11 +87,109 @@ func buildAudioCaptureArgs(p AudioCaptureParams) []string {
 		args = append(args, "-channels", fmt.Sprintf("%d", p.Channels))
 	}
 
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
 	// Input device: hw:0,0
 	args = append(args, "-i", p.DeviceID)
 
-	// Output: raw PCM S16LE to stdout
-	args = append(args, audioOutputArgs(p)...)
+	return args
+}
+
+// buildDisplayCaptureArgs builds FFmpeg arguments for capturing the screen via x11grab on Linux.
+func buildDisplayCaptureArgs(p DisplayCaptureParams) []string {
+	args := []string{"-y"}
+
+	// Input format
+	args = append(args, "-f", "x11grab")
+
+	// Input options
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+	if !p.DrawCursor {
+		args = append(args, "-draw_mouse", "0")
+	}
+	if p.Window != 0 {
+		args = append(args, "-window_id", fmt.Sprintf("%#x", p.Window))
+	} else if !p.Region.Empty() {
+		args = append(args, "-video_size", fmt.Sprintf("%dx%d", p.Region.Dx(), p.Region.Dy()))
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input display: ":0.0", or ":0.0+X,Y" for the top-left corner of the region
+	display := p.Display
+	if display == "" {
+		display = os.Getenv("DISPLAY")
+	}
+	if display == "" {
+		display = ":0.0"
+	}
+	if p.Window == 0 && !p.Region.Empty() {
+		display += fmt.Sprintf("+%d,%d", p.Region.Min.X, p.Region.Min.Y)
+	}
+	args = append(args, "-i", display)
+
+	// Keep the output size fixed when the window is resized
+	if p.Window != 0 {
+		args = append(args, "-vf", windowFitFilter(p.Width, p.Height))
+	}
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
 
 	return args
 }
+
+// buildLoopbackInputArgs builds the FFmpeg input arguments for the render
+// reference of an echo canceller: a PulseAudio (or PipeWire) monitor source
+// on Linux, since ALSA cannot capture what is being played.
+func buildLoopbackInputArgs(p AudioCaptureParams) []string {
+	p.DeviceID = strings.TrimPrefix(p.DeviceID, pulseDevicePrefix)
+	return buildPulseInputArgs(p)
+}
+
+// buildPulseInputArgs builds the FFmpeg input arguments for a PulseAudio
+// source; PipeWire serves them through pipewire-pulse.
+func buildPulseInputArgs(p AudioCaptureParams) []string {
+	args := []string{"-f", "pulse"}
+	if p.SampleRate > 0 {
+		args = append(args, "-sample_rate", fmt.Sprintf("%d", p.SampleRate))
+	}
+	if p.Channels > 0 {
+		args = append(args, "-channels", fmt.Sprintf("%d", p.Channels))
+	}
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input source: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor
+	args = append(args, "-i", p.DeviceID)
+
+	return args
+}
+
+// buildAudioPlaybackArgs builds FFmpeg arguments for playing PCM from stdin
+// on an ALSA card or, for the sinks of the sound server ("pulse:<sink>"),
+// through PulseAudio.
+func buildAudioPlaybackArgs(p AudioPlaybackParams) ([]string, error) {
+	args := audioPlaybackInputArgs(p)
+	if sink, ok := strings.CutPrefix(p.DeviceID, pulseDevicePrefix); ok {
+		args = append(args, "-f", "pulse", "-device", sink)
+		if p.LowLatency {
+			// In milliseconds; the server otherwise buffers about two seconds.
+			args = append(args, "-buffer_duration", "40")
+		}
+		// The output "file name" is the stream name shown in mixers.
+		return append(args, "mediadevices"), nil
+	}
+	// plughw converts to the formats the card supports; hw plays only those.
+	device := p.DeviceID
+	if card, ok := strings.CutPrefix(device, "hw:"); ok {
+		device = "plughw:" + card
+	}
+	return append(args, "-f", "alsa", device), nil
+}
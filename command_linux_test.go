@@ -0,0 +1,170 @@
+//go:build linux && !android
+
+package mediadevices
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestBuildDisplayCaptureArgs_Linux(t *testing.T) {
+	args := buildDisplayCaptureArgs(DisplayCaptureParams{
+		Display:   ":1.0",
+		Region:    image.Rect(100, 200, 740, 680),
+		Width:     640,
+		Height:    480,
+		FrameRate: 15,
+	})
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{"-f x11grab", "-draw_mouse 0", "-video_size 640x480 ", "-i :1.0+100,200 ", "-f rawvideo"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q in args: %s", want, joined)
+		}
+	}
+
+	args = buildDisplayCaptureArgs(DisplayCaptureParams{Display: ":0", DrawCursor: true, Width: 1920, Height: 1080})
+	joined = strings.Join(args, " ")
+	if strings.Contains(joined, "-draw_mouse") || !strings.Contains(joined, "-i :0 ") {
+		t.Errorf("whole display args: %s", joined)
+	}
+}
+
+func TestBuildDisplayCaptureArgs_LinuxWindow(t *testing.T) {
+	args := buildDisplayCaptureArgs(DisplayCaptureParams{
+		Display: ":0",
+		Region:  image.Rect(0, 0, 100, 100),
+		Window:  0x4400003,
+		Width:   1280,
+		Height:  720,
+	})
+	joined := strings.Join(args, " ")
+
+	for _, want := range []string{"-window_id 0x4400003", "-i :0 ", "-vf scale=1280:720:force_original_aspect_ratio=decrease,pad=1280:720:"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q in args: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "+0,0") {
+		t.Errorf("region applied to window capture: %s", joined)
+	}
+}
+
+func TestParseWmctrl(t *testing.T) {
+	out := "0x04400003  0 1920 24   1280 720  myhost Untitled - Text  Editor\n" +
+		"0x01e00006 -1 0    0    1920 24   myhost \n" +
+		"0x02200001  1 -50  100  640  480  myhost Terminal\n"
+	got := parseWmctrl(out)
+	if len(got) != 2 {
+		t.Fatalf("got %d windows, want 2: %+v", len(got), got)
+	}
+	if got[0].ID != "window:0x4400003" || got[0].Handle != 0x4400003 || got[0].Title != "Untitled - Text  Editor" ||
+		got[0].Bounds != image.Rect(1920, 24, 3200, 744) || got[0].Surface != DisplaySurfaceWindow {
+		t.Errorf("window 0 = %+v", got[0])
+	}
+	if got[1].Title != "Terminal" || got[1].Bounds != image.Rect(-50, 100, 590, 580) {
+		t.Errorf("window 1 = %+v", got[1])
+	}
+}
+
+func TestParseXwininfo(t *testing.T) {
+	out := `
+xwininfo: Window id: 0x4400003 "Terminal"
+
+  Absolute upper-left X:  1930
+  Absolute upper-left Y:  60
+  Relative upper-left X:  10
+  Relative upper-left Y:  36
+  Width: 800
+  Height: 600
+  Depth: 24
+`
+	got, err := parseXwininfo(out)
+	if err != nil || got != image.Rect(1930, 60, 2730, 660) {
+		t.Errorf("parseXwininfo = %v, %v", got, err)
+	}
+	if _, err := parseXwininfo("xwininfo: error"); err == nil {
+		t.Error("missing geometry accepted")
+	}
+}
+
+func TestParseXrandrMonitors(t *testing.T) {
+	out := "Monitors: 2\n" +
+		" 0: +*eDP-1 1920/344x1080/194+0+0  eDP-1\n" +
+		" 1: +HDMI-1 2560/597x1440/336+1920+0  HDMI-1\n"
+	got := parseXrandrMonitors(out)
+	want := []monitor{
+		{name: "eDP-1", bounds: image.Rect(0, 0, 1920, 1080), primary: true},
+		{name: "HDMI-1", bounds: image.Rect(1920, 0, 4480, 1440)},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("monitor %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestBuildCaptureArgs_LinuxWallclock(t *testing.T) {
+	video := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: "/dev/video0", Width: 640, Height: 480, UseWallclockTimestamps: true}), " ")
+	if !strings.Contains(video, "-use_wallclock_as_timestamps 1 -i /dev/video0 -fps_mode passthrough -f rawvideo") {
+		t.Errorf("video args: %s", video)
+	}
+	audio := strings.Join(buildAudioCaptureArgs(AudioCaptureParams{DeviceID: "hw:0,0", UseWallclockTimestamps: true}), " ")
+	if !strings.Contains(audio, "-use_wallclock_as_timestamps 1 -i hw:0,0 ") {
+		t.Errorf("audio args: %s", audio)
+	}
+	display := strings.Join(buildDisplayCaptureArgs(DisplayCaptureParams{Display: ":0", Width: 640, Height: 480, UseWallclockTimestamps: true}), " ")
+	if !strings.Contains(display, "-use_wallclock_as_timestamps 1 -i :0 ") || !strings.Contains(display, "-fps_mode passthrough") {
+		t.Errorf("display args: %s", display)
+	}
+	if plain := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: "/dev/video0"}), " "); strings.Contains(plain, "wallclock") || strings.Contains(plain, "fps_mode") {
+		t.Errorf("wallclock options without UseWallclockTimestamps: %s", plain)
+	}
+}
+
+func TestBuildEchoCaptureArgs_Linux(t *testing.T) {
+	mic := AudioCaptureParams{DeviceID: "hw:1,0", SampleRate: 48000, Channels: 1}
+	ref := mic
+	ref.DeviceID = defaultMonitorSource
+	joined := strings.Join(buildEchoCaptureArgs(mic, ref), " ")
+
+	for _, want := range []string{"-f alsa", "-i hw:1,0 ", "-f pulse", "-i @DEFAULT_MONITOR@ ", "amerge=inputs=2", "channel_layouts=mono", "-map [out]", "-f s16le"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing %q in args: %s", want, joined)
+		}
+	}
+	if strings.Contains(joined, "-ac ") {
+		t.Errorf("merged output must keep all channels: %s", joined)
+	}
+}
+
+func TestBuildAudioCaptureArgs_LinuxPulse(t *testing.T) {
+	joined := strings.Join(buildAudioCaptureArgs(AudioCaptureParams{DeviceID: "pulse:alsa_input.usb-mic", SampleRate: 48000, Channels: 1}), " ")
+	if !strings.Contains(joined, "-f pulse") || !strings.Contains(joined, "-i alsa_input.usb-mic ") || strings.Contains(joined, "alsa -") {
+		t.Errorf("pulse args: %s", joined)
+	}
+	joined = strings.Join(buildAudioCaptureArgs(AudioCaptureParams{DeviceID: "hw:1", SampleRate: 48000}), " ")
+	if !strings.Contains(joined, "-f alsa") || !strings.Contains(joined, "-i hw:1 ") {
+		t.Errorf("alsa args: %s", joined)
+	}
+}
+
+func TestBuildAudioPlaybackArgs_Linux(t *testing.T) {
+	args, err := buildAudioPlaybackArgs(AudioPlaybackParams{DeviceID: "pulse:alsa_output.usb-headset", SampleRate: 48000, Channels: 2})
+	joined := strings.Join(args, " ")
+	if err != nil || joined != "-f s16le -ar 48000 -ac 2 -i pipe:0 -f pulse -device alsa_output.usb-headset mediadevices" {
+		t.Errorf("pulse args: %s, %v", joined, err)
+	}
+	args, _ = buildAudioPlaybackArgs(AudioPlaybackParams{DeviceID: "pulse:alsa_output.usb-headset", SampleRate: 48000, Channels: 2, LowLatency: true})
+	if joined = strings.Join(args, " "); !strings.HasPrefix(joined, "-fflags nobuffer -f s16le") || !strings.HasSuffix(joined, "-buffer_duration 40 mediadevices") {
+		t.Errorf("low-latency pulse args: %s", joined)
+	}
+	args, _ = buildAudioPlaybackArgs(AudioPlaybackParams{DeviceID: "hw:1", SampleRate: 44100, Channels: 1})
+	if joined = strings.Join(args, " "); !strings.HasSuffix(joined, "-f alsa plughw:1") || !strings.Contains(joined, "-ar 44100 -ac 1") {
+		t.Errorf("alsa args: %s", joined)
+	}
+}
@@ -2,11 +2,31 @@
 
 package mediadevices
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+)
 
 // buildVideoCaptureArgs builds FFmpeg arguments for capturing video via DirectShow on Windows.
 func buildVideoCaptureArgs(p VideoCaptureParams) []string {
 	args := []string{"-y"}
+	args = append(args, buildVideoInputArgs(p)...)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(p)...)
+
+	return args
+}
+
+// buildVideoInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for a video device via DirectShow on Windows, or for a DeckLink
+// input or virtual device.
+func buildVideoInputArgs(p VideoCaptureParams) []string {
+	if args, ok := portableVideoInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
 
 	// Input format
 	args = append(args, "-f", "dshow")
@@ -22,18 +42,36 @@ func buildVideoCaptureArgs(p VideoCaptureParams) []string {
 		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
 	}
 
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
 	// Input device: video="Device Name"
 	args = append(args, "-i", fmt.Sprintf("video=%s", p.DeviceID))
 
-	// Output: raw YUV420p to stdout
-	args = append(args, videoOutputArgs(p)...)
-
 	return args
 }
 
 // buildAudioCaptureArgs builds FFmpeg arguments for capturing audio via DirectShow on Windows.
 func buildAudioCaptureArgs(p AudioCaptureParams) []string {
 	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(p)...)
+
+	// Output: raw PCM S16LE to stdout
+	args = append(args, audioOutputArgs(p)...)
+
+	return args
+}
+
+// buildAudioInputArgs builds the FFmpeg input arguments (format, input options
+// and -i) for an audio device via DirectShow on Windows, or for the embedded
+// audio of a DeckLink input or virtual device.
+func buildAudioInputArgs(p AudioCaptureParams) []string {
+	if args, ok := portableAudioInputArgs(p); ok {
+		return args
+	}
+
+	var args []string
 
 	// Input format
 	args = append(args, "-f", "dshow")
@@ -46,11 +84,106 @@ func buildAudioCaptureArgs(p AudioCaptureParams) []string {
 		args = append(args, "-channels", fmt.Sprintf("%d", p.Channels))
 	}
 
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
 	// Input device: audio="Device Name"
 	args = append(args, "-i", fmt.Sprintf("audio=%s", p.DeviceID))
 
-	// Output: raw PCM S16LE to stdout
-	args = append(args, audioOutputArgs(p)...)
+	return args
+}
+
+// buildDisplayCaptureArgs builds FFmpeg arguments for capturing the screen via GDI
+// or, if selected, Desktop Duplication on Windows.
+func buildDisplayCaptureArgs(p DisplayCaptureParams) []string {
+	if p.Backend == DisplayCaptureBackendDDAGrab {
+		return buildDDAGrabArgs(p)
+	}
+
+	args := []string{"-y"}
+
+	// Input format
+	args = append(args, "-f", "gdigrab")
+
+	// Input options
+	if p.FrameRate > 0 {
+		args = append(args, "-framerate", fmt.Sprintf("%g", p.FrameRate))
+	}
+	if !p.DrawCursor {
+		args = append(args, "-draw_mouse", "0")
+	}
+	if p.Window == 0 && !p.Region.Empty() {
+		// Offsets may be negative for monitors left of or above the primary one
+		args = append(args,
+			"-offset_x", fmt.Sprintf("%d", p.Region.Min.X),
+			"-offset_y", fmt.Sprintf("%d", p.Region.Min.Y),
+			"-video_size", fmt.Sprintf("%dx%d", p.Region.Dx(), p.Region.Dy()))
+	}
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Input: a window by handle, or the whole virtual desktop
+	if p.Window != 0 {
+		args = append(args, "-i", fmt.Sprintf("hwnd=%#x", p.Window))
+		// Keep the output size fixed when the window is resized
+		args = append(args, "-vf", windowFitFilter(p.Width, p.Height))
+	} else {
+		args = append(args, "-i", "desktop")
+	}
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
+
+	return args
+}
+
+// buildDDAGrabArgs builds FFmpeg arguments for capturing one monitor via the
+// ddagrab filter. Frames are D3D11 textures, downloaded to system memory with
+// hwdownload and converted to YUV420p on output.
+func buildDDAGrabArgs(p DisplayCaptureParams) []string {
+	args := []string{"-y"}
+
+	// Input format: ddagrab is a source filter
+	args = append(args, "-f", "lavfi")
+
+	// Capture buffering from the latency profile
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+
+	// Filter options
+	opts := fmt.Sprintf("output_idx=%d", p.Output)
+	if p.FrameRate > 0 {
+		opts += fmt.Sprintf(":framerate=%g", p.FrameRate)
+	}
+	if !p.DrawCursor {
+		opts += ":draw_mouse=0"
+	}
+	if !p.Region.Empty() {
+		opts += fmt.Sprintf(":video_size=%dx%d:offset_x=%d:offset_y=%d",
+			p.Region.Dx(), p.Region.Dy(), p.Region.Min.X, p.Region.Min.Y)
+	}
+
+	// Input: GPU capture, then download the BGRA frames
+	args = append(args, "-i", fmt.Sprintf("ddagrab=%s,hwdownload,format=bgra", opts))
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
 
 	return args
 }
+
+// buildLoopbackInputArgs builds the FFmpeg input arguments for the render
+// reference of an echo canceller. The reference is a loopback audio input
+// device here, captured like a microphone.
+func buildLoopbackInputArgs(p AudioCaptureParams) []string {
+	return buildAudioInputArgs(p)
+}
+
+// buildAudioPlaybackArgs reports that FFmpeg cannot play audio on Windows:
+// it has no DirectShow or WASAPI output device.
+func buildAudioPlaybackArgs(p AudioPlaybackParams) ([]string, error) {
+	return nil, errors.New("audio playback is not supported on windows: FFmpeg has no Windows audio output device")
+}
@@ -3,6 +3,7 @@
 package mediadevices
 
 import (
+	"image"
 	"strings"
 	"testing"
 )
@@ -58,6 +59,75 @@ func TestBuildAudioCaptureArgs_Windows(t *testing.T) {
 	}
 }
 
+func TestBuildDisplayCaptureArgs_Windows(t *testing.T) {
+	args := buildDisplayCaptureArgs(DisplayCaptureParams{
+		Region:     image.Rect(-1280, 0, 0, 720),
+		Width:      1280,
+		Height:     720,
+		FrameRate:  30,
+		DrawCursor: true,
+	})
+	joined := strings.Join(args, " ")
+
+	if !contains(args, "-f", "gdigrab") || !contains(args, "-i", "desktop") {
+		t.Errorf("missing gdigrab desktop input in args: %s", joined)
+	}
+	if !contains(args, "-offset_x", "-1280") || !contains(args, "-offset_y", "0") || !contains(args, "-video_size", "1280x720") {
+		t.Errorf("missing region in args: %s", joined)
+	}
+	if containsValue(args, "-draw_mouse") {
+		t.Errorf("unexpected -draw_mouse in args: %s", joined)
+	}
+}
+
+func TestBuildDisplayCaptureArgs_WindowsWindow(t *testing.T) {
+	args := buildDisplayCaptureArgs(DisplayCaptureParams{Window: 0x30a2c, Width: 800, Height: 600})
+	joined := strings.Join(args, " ")
+
+	if !contains(args, "-i", "hwnd=0x30a2c") || containsValue(args, "-offset_x") {
+		t.Errorf("missing hwnd input in args: %s", joined)
+	}
+	if !containsPrefix(args, "scale=800:600:") {
+		t.Errorf("missing fit filter in args: %s", joined)
+	}
+}
+
+func TestBuildDisplayCaptureArgs_DDAGrab(t *testing.T) {
+	mon := DisplaySource{ID: "monitor:2", Surface: DisplaySurfaceMonitor, Bounds: image.Rect(3840, 0, 7680, 2160)}
+	p, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{
+		Region:    image.Rect(100, 200, 1380, 920),
+		FrameRate: Float64Ptr(60),
+		Backend:   DisplayCaptureBackendDDAGrab,
+	}, mon)
+	if err != nil {
+		t.Fatalf("resolveDisplayParams: %v", err)
+	}
+	args := buildDisplayCaptureArgs(p)
+	joined := strings.Join(args, " ")
+
+	want := "ddagrab=output_idx=1:framerate=60:video_size=1280x720:offset_x=100:offset_y=200,hwdownload,format=bgra"
+	if !contains(args, "-f", "lavfi") || !contains(args, "-i", want) {
+		t.Errorf("want lavfi input %q in args: %s", want, joined)
+	}
+	if !contains(args, "-pix_fmt", "yuv420p") {
+		t.Errorf("missing yuv420p conversion in args: %s", joined)
+	}
+
+	p.DrawCursor = false
+	if args := buildDisplayCaptureArgs(p); !containsPrefix(args, "ddagrab=output_idx=1:framerate=60:draw_mouse=0:") {
+		t.Errorf("missing draw_mouse=0 in args: %s", strings.Join(args, " "))
+	}
+	p.Backend = ""
+	if args := buildDisplayCaptureArgs(p); !contains(args, "-draw_mouse", "0") {
+		t.Errorf("missing -draw_mouse 0 in gdigrab args: %s", strings.Join(args, " "))
+	}
+
+	desktop := DisplaySource{ID: desktopSourceID, Surface: DisplaySurfaceMonitor}
+	if _, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Backend: DisplayCaptureBackendDDAGrab}, desktop); err == nil {
+		t.Error("ddagrab accepted for the whole desktop")
+	}
+}
+
 // contains checks if args has a consecutive pair [flag, value].
 func contains(args []string, flag, value string) bool {
 	for i := 0; i < len(args)-1; i++ {
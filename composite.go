@@ -0,0 +1,204 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"math"
+	"strings"
+)
+
+// CompositeLayout selects how the sources of a composite track are arranged.
+type CompositeLayout string
+
+const (
+	// CompositeLayoutGrid tiles all sources in equally sized cells, row by row.
+	CompositeLayoutGrid CompositeLayout = "grid"
+	// CompositeLayoutPIP shows the first source full-frame and overlays the
+	// remaining sources at their configured Rect (picture-in-picture).
+	CompositeLayoutPIP CompositeLayout = "pip"
+)
+
+// CompositeSource is one camera feeding a composite track.
+type CompositeSource struct {
+	// Device is the video input to capture, as returned by VideoInputDevices.
+	Device MediaDeviceInfo
+	// Width, Height and FrameRate are the capture mode requested from the
+	// device. Zero values leave the choice to the device driver.
+	Width     int
+	Height    int
+	FrameRate float64
+	// Rect is the placement of this source in the output frame for
+	// CompositeLayoutPIP. It is ignored for the first (background) source
+	// and for CompositeLayoutGrid.
+	Rect image.Rectangle
+}
+
+// CompositeConfig configures a multi-camera composite track.
+type CompositeConfig struct {
+	// Layout selects grid or picture-in-picture arrangement. Defaults to grid.
+	Layout CompositeLayout
+	// Sources lists the cameras to combine, at least one.
+	Sources []CompositeSource
+	// Width and Height are the output frame size in pixels.
+	Width  int
+	Height int
+	// FrameRate is the output frame rate. Defaults to 30.
+	FrameRate float64
+	// Columns is the number of grid columns. Defaults to ceil(sqrt(len(Sources))).
+	Columns int
+}
+
+// NewCompositeTrack opens all configured cameras in a single FFmpeg process
+// and combines them with the xstack (grid) or overlay (PIP) filters into one
+// video track. The track behaves like any other video track: frames are
+// delivered as YUV420p *image.YCbCr of the configured output size.
+func NewCompositeTrack(cfg CompositeConfig) (*MediaStreamTrack, error) {
+	return defaultMediaDevices.NewCompositeTrack(cfg)
+}
+
+// NewCompositeTrack is like the package-level NewCompositeTrack, but
+// captures with the configuration of m and registers the track in m.
+func (m *MediaDevices) NewCompositeTrack(cfg CompositeConfig) (*MediaStreamTrack, error) {
+	args, err := m.buildCompositeArgs(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, len(cfg.Sources))
+	for i, src := range cfg.Sources {
+		labels[i] = src.Device.Label
+	}
+	label := fmt.Sprintf("Composite (%s)", strings.Join(labels, ", "))
+
+	reader, err := m.newVideoReaderFromArgs(label, args, cfg.Width, cfg.Height, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create composite reader: %w", err)
+	}
+
+	return m.resources.addTrack(&MediaStreamTrack{
+		id:          generateTrackID(),
+		kind:        MediaDeviceKindVideoInput,
+		label:       label,
+		readyState:  MediaStreamTrackStateLive,
+		videoReader: reader,
+	}), nil
+}
+
+// buildCompositeArgs builds the FFmpeg command line for a composite capture:
+// one platform input per source, a filter graph ending in [out], and raw
+// YUV420p output to stdout, with the configuration of m.
+func (m *MediaDevices) buildCompositeArgs(cfg CompositeConfig) ([]string, error) {
+	if len(cfg.Sources) == 0 {
+		return nil, fmt.Errorf("composite: at least one source is required")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		return nil, fmt.Errorf("composite: output width and height must be positive (got %dx%d)", cfg.Width, cfg.Height)
+	}
+	frameRate := cfg.FrameRate
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+
+	gcfg := m.Config()
+	args := []string{"-y"}
+	maskFilters := make([]string, len(cfg.Sources))
+	for i, src := range cfg.Sources {
+		masks, err := m.privacyMasksFor(context.Background(), ffmpegDeviceName(src.Device), src.Device.DeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("composite: source %d: %w", i, err)
+		}
+		maskFilters[i] = privacyMaskFilter(masks)
+		input := buildVideoInputArgs(VideoCaptureParams{
+			DeviceID:  ffmpegDeviceName(src.Device),
+			Width:     src.Width,
+			Height:    src.Height,
+			FrameRate: src.FrameRate,
+			Profile:   gcfg.LatencyProfile,
+
+			UseWallclockTimestamps: gcfg.UseWallclockTimestamps,
+		})
+		input = m.overrideInput(context.Background(), MediaDeviceKindVideoInput, ffmpegDeviceName(src.Device), src.Device.DeviceID, input)
+		args = append(args, input...)
+	}
+
+	var graph string
+	switch cfg.Layout {
+	case CompositeLayoutGrid, "":
+		graph = compositeGridFilter(len(cfg.Sources), cfg.Columns, cfg.Width, cfg.Height)
+	case CompositeLayoutPIP:
+		g, err := compositePIPFilter(cfg.Sources, cfg.Width, cfg.Height)
+		if err != nil {
+			return nil, err
+		}
+		graph = g
+	default:
+		return nil, fmt.Errorf("composite: unknown layout %q", cfg.Layout)
+	}
+	graph += fmt.Sprintf(";[stack]fps=%g,format=yuv420p[out]", frameRate)
+	// Masked sources enter the layout through their masking chain.
+	for i, f := range maskFilters {
+		if f != "" {
+			in, out := fmt.Sprintf("[%d:v]", i), fmt.Sprintf("[masked%d]", i)
+			graph = in + f + out + ";" + strings.ReplaceAll(graph, in, out)
+		}
+	}
+
+	args = append(args, "-filter_complex", graph, "-map", "[out]")
+	args = append(args, videoOutputArgs(VideoCaptureParams{Width: cfg.Width, Height: cfg.Height, UseWallclockTimestamps: gcfg.UseWallclockTimestamps})...)
+	return args, nil
+}
+
+// compositeGridFilter scales n inputs to equal cells and tiles them with xstack.
+// Cells keep the output aspect ratio; unused cells are filled black.
+func compositeGridFilter(n, columns, width, height int) string {
+	if columns <= 0 {
+		columns = int(math.Ceil(math.Sqrt(float64(n))))
+	}
+	if columns > n {
+		columns = n
+	}
+	rows := (n + columns - 1) / columns
+	// Keep cell sizes even so the YUV420p output has whole chroma samples.
+	cellW := width / columns &^ 1
+	cellH := height / rows &^ 1
+
+	var parts []string
+	var labels, layout strings.Builder
+	for i := 0; i < n; i++ {
+		parts = append(parts, fmt.Sprintf("[%d:v]scale=%d:%d,setsar=1[v%d]", i, cellW, cellH, i))
+		fmt.Fprintf(&labels, "[v%d]", i)
+		if i > 0 {
+			layout.WriteByte('|')
+		}
+		fmt.Fprintf(&layout, "%d_%d", (i%columns)*cellW, (i/columns)*cellH)
+	}
+
+	if n == 1 {
+		parts = append(parts, fmt.Sprintf("[v0]pad=%d:%d[stack]", width, height))
+	} else {
+		parts = append(parts, fmt.Sprintf("%sxstack=inputs=%d:layout=%s:fill=black,pad=%d:%d[stack]", labels.String(), n, layout.String(), width, height))
+	}
+	return strings.Join(parts, ";")
+}
+
+// compositePIPFilter scales the first input to the full output and overlays
+// every further input, scaled to its Rect, in order.
+func compositePIPFilter(sources []CompositeSource, width, height int) (string, error) {
+	parts := []string{fmt.Sprintf("[0:v]scale=%d:%d,setsar=1[base0]", width, height)}
+	prev := "base0"
+	for i := 1; i < len(sources); i++ {
+		r := sources[i].Rect
+		if r.Empty() {
+			return "", fmt.Errorf("composite: source %d needs a non-empty Rect for picture-in-picture", i)
+		}
+		next := fmt.Sprintf("base%d", i)
+		parts = append(parts,
+			fmt.Sprintf("[%d:v]scale=%d:%d,setsar=1[pip%d]", i, r.Dx(), r.Dy(), i),
+			fmt.Sprintf("[%s][pip%d]overlay=%d:%d[%s]", prev, i, r.Min.X, r.Min.Y, next),
+		)
+		prev = next
+	}
+	parts = append(parts, fmt.Sprintf("[%s]null[stack]", prev))
+	return strings.Join(parts, ";"), nil
+}
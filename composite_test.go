@@ -0,0 +1,88 @@
+package mediadevices
+
+import (
+	"image"
+	"strings"
+	"testing"
+)
+
+func TestCompositeGridFilter(t *testing.T) {
+	got := compositeGridFilter(3, 0, 1280, 720)
+	want := "[0:v]scale=640:360,setsar=1[v0];" +
+		"[1:v]scale=640:360,setsar=1[v1];" +
+		"[2:v]scale=640:360,setsar=1[v2];" +
+		"[v0][v1][v2]xstack=inputs=3:layout=0_0|640_0|0_360:fill=black,pad=1280:720[stack]"
+	if got != want {
+		t.Errorf("compositeGridFilter() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCompositeGridFilter_Single(t *testing.T) {
+	got := compositeGridFilter(1, 0, 640, 480)
+	if strings.Contains(got, "xstack") {
+		t.Errorf("single source must not use xstack: %s", got)
+	}
+	if !strings.HasSuffix(got, "[v0]pad=640:480[stack]") {
+		t.Errorf("compositeGridFilter() = %s", got)
+	}
+}
+
+func TestBuildCompositeArgs_PIP(t *testing.T) {
+	args, err := defaultMediaDevices.buildCompositeArgs(CompositeConfig{
+		Layout: CompositeLayoutPIP,
+		Width:  1280,
+		Height: 720,
+		Sources: []CompositeSource{
+			{Device: MediaDeviceInfo{DeviceID: "cam-a", DeviceName: "Front"}},
+			{Device: MediaDeviceInfo{DeviceID: "cam-b", DeviceName: "Rear"}, Rect: image.Rect(960, 540, 1280, 720)},
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildCompositeArgs: %v", err)
+	}
+
+	inputs := 0
+	var graph string
+	for i, a := range args {
+		if a == "-i" {
+			inputs++
+		}
+		if a == "-filter_complex" && i+1 < len(args) {
+			graph = args[i+1]
+		}
+	}
+	if inputs != 2 {
+		t.Errorf("got %d inputs, want 2: %v", inputs, args)
+	}
+	for _, want := range []string{
+		"[0:v]scale=1280:720,setsar=1[base0]",
+		"[1:v]scale=320:180,setsar=1[pip1]",
+		"[base0][pip1]overlay=960:540[base1]",
+		"[stack]fps=30,format=yuv420p[out]",
+	} {
+		if !strings.Contains(graph, want) {
+			t.Errorf("filter graph missing %q:\n%s", want, graph)
+		}
+	}
+	if args[len(args)-1] != "pipe:1" {
+		t.Errorf("last arg = %q, want pipe:1", args[len(args)-1])
+	}
+}
+
+func TestBuildCompositeArgs_Invalid(t *testing.T) {
+	src := CompositeSource{Device: MediaDeviceInfo{DeviceID: "cam"}}
+	tests := []struct {
+		name string
+		cfg  CompositeConfig
+	}{
+		{"no sources", CompositeConfig{Width: 640, Height: 480}},
+		{"no size", CompositeConfig{Sources: []CompositeSource{src}}},
+		{"pip without rect", CompositeConfig{Layout: CompositeLayoutPIP, Width: 640, Height: 480, Sources: []CompositeSource{src, src}}},
+		{"unknown layout", CompositeConfig{Layout: "mosaic", Width: 640, Height: 480, Sources: []CompositeSource{src}}},
+	}
+	for _, tt := range tests {
+		if _, err := defaultMediaDevices.buildCompositeArgs(tt.cfg); err == nil {
+			t.Errorf("%s: expected error", tt.name)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// BitrateAdapterConfig configures congestion-driven bitrate adaptation.
+type BitrateAdapterConfig struct {
+	// MinBitRate and MaxBitRate bound the target bitrate in kbps.
+	// MinBitRate defaults to 150; MaxBitRate defaults to the encoder's
+	// bitrate when the adapter is created, or 4000 if it has none.
+	MinBitRate int
+	MaxBitRate int
+	// MinInterval is the minimum time between encoder bitrate changes.
+	// Every change restarts the encoder and costs an IDR frame, so changes
+	// are batched. Defaults to 3s.
+	MinInterval time.Duration
+	// OnChange, if set, is called after every applied bitrate change.
+	OnChange func(BitrateChange)
+}
+
+// BitrateChange describes a bitrate change made by a BitrateAdapter.
+type BitrateChange struct {
+	From, To int // kbps
+	// Loss is the last reported fraction of lost packets (0-1).
+	Loss float64
+	// Estimate is the last bandwidth estimate in kbps, 0 if none.
+	Estimate int
+	// Err is non-nil if the encoder could not be reconfigured.
+	Err error
+}
+
+// BitrateAdapter adapts the bitrate of an RTP stream to network conditions.
+// It combines packet loss from RTCP receiver reports (loss-based control:
+// back off under heavy loss, probe upwards while loss is low) with an
+// optional bandwidth estimate, from REMB feedback or from the application,
+// which caps the target.
+//
+// Feed it RTCP packets received for the stream with HandleRTCP, and/or
+// estimates with SetBandwidthEstimate. Changes are applied to the encoder
+// from the calling goroutine.
+type BitrateAdapter struct {
+	cfg   BitrateAdapterConfig
+	ssrc  func() uint32
+	apply func(kbps int) error
+
+	mu         sync.Mutex
+	target     float64 // kbps
+	current    int     // kbps applied to the encoder
+	loss       float64
+	estimate   int // kbps
+	lastChange time.Time
+}
+
+// Loss-based control thresholds, as in Google Congestion Control.
+const (
+	lossBackoff  = 0.10 // above: decrease by half the loss fraction
+	lossIncrease = 0.02 // below: increase by 5%
+	// minBitrateStep is the relative change below which the encoder is not
+	// restarted.
+	minBitrateStep = 0.10
+)
+
+// NewBitrateAdapter creates a bitrate adapter for r.
+func NewBitrateAdapter(r *RTPReader, cfg BitrateAdapterConfig) *BitrateAdapter {
+	return newBitrateAdapter(r.SSRC, r.SetBitRate, r.BitRate(), cfg)
+}
+
+func newBitrateAdapter(ssrc func() uint32, apply func(int) error, current int, cfg BitrateAdapterConfig) *BitrateAdapter {
+	if cfg.MinBitRate <= 0 {
+		cfg.MinBitRate = 150
+	}
+	if cfg.MaxBitRate <= 0 {
+		cfg.MaxBitRate = current
+		if cfg.MaxBitRate <= 0 {
+			cfg.MaxBitRate = 4000
+		}
+	}
+	if cfg.MaxBitRate < cfg.MinBitRate {
+		cfg.MaxBitRate = cfg.MinBitRate
+	}
+	if cfg.MinInterval <= 0 {
+		cfg.MinInterval = 3 * time.Second
+	}
+	if current <= 0 {
+		current = cfg.MaxBitRate
+	}
+	return &BitrateAdapter{
+		cfg:     cfg,
+		ssrc:    ssrc,
+		apply:   apply,
+		target:  float64(current),
+		current: current,
+	}
+}
+
+// Target returns the current target bitrate in kbps.
+func (a *BitrateAdapter) Target() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int(a.target)
+}
+
+// SetBandwidthEstimate sets the available bandwidth estimate in bits per
+// second, e.g. from the application's own congestion controller. The target
+// bitrate is kept below it. Zero removes the estimate.
+func (a *BitrateAdapter) SetBandwidthEstimate(bps int) {
+	a.update(time.Now(), func() {
+		a.estimate = bps / 1000
+	})
+}
+
+// HandleRTCP processes a received (compound) RTCP packet. Report blocks of
+// sender and receiver reports about this stream's SSRC drive loss-based
+// control; REMB messages set the bandwidth estimate. Other packets are
+// ignored.
+func (a *BitrateAdapter) HandleRTCP(data []byte) error {
+	ssrc := a.ssrc()
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return fmt.Errorf("rtcp: truncated header")
+		}
+		if data[0]>>6 != 2 {
+			return fmt.Errorf("rtcp: unsupported version %d", data[0]>>6)
+		}
+		count := int(data[0] & 0x1F)
+		pt := data[1]
+		size := (int(binary.BigEndian.Uint16(data[2:4])) + 1) * 4
+		if size > len(data) {
+			return fmt.Errorf("rtcp: packet length %d exceeds buffer (%d)", size, len(data))
+		}
+		body := data[4:size]
+		data = data[size:]
+
+		switch pt {
+		case 200, 201: // SR, RR
+			offset := 4 // sender SSRC
+			if pt == 200 {
+				offset += 20 // sender info
+			}
+			for i := 0; i < count && offset+24 <= len(body); i++ {
+				block := body[offset : offset+24]
+				offset += 24
+				if binary.BigEndian.Uint32(block[0:4]) == ssrc {
+					a.handleLoss(float64(block[4]) / 256)
+				}
+			}
+		case 206: // payload-specific feedback
+			if count == 15 {
+				if bps, ok := parseREMB(body); ok {
+					a.SetBandwidthEstimate(bps)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// parseREMB returns the bitrate of a REMB message (the body of a PSFB
+// packet with FMT 15, after the common header).
+func parseREMB(body []byte) (int, bool) {
+	if len(body) < 16 || string(body[8:12]) != "REMB" {
+		return 0, false
+	}
+	exp := body[13] >> 2
+	mantissa := uint64(body[13]&0x03)<<16 | uint64(body[14])<<8 | uint64(body[15])
+	bps := mantissa << exp
+	if bps > 1<<40 {
+		return 0, false
+	}
+	return int(bps), true
+}
+
+// handleLoss applies loss-based control for one report.
+func (a *BitrateAdapter) handleLoss(loss float64) {
+	a.update(time.Now(), func() {
+		a.loss = loss
+		switch {
+		case loss > lossBackoff:
+			a.target *= 1 - 0.5*loss
+		case loss < lossIncrease:
+			a.target *= 1.05
+		}
+	})
+}
+
+// update runs fn under the lock, clamps the target and applies it to the
+// encoder if it changed enough and the rate limit allows.
+func (a *BitrateAdapter) update(now time.Time, fn func()) {
+	a.mu.Lock()
+	fn()
+	if a.target > float64(a.cfg.MaxBitRate) {
+		a.target = float64(a.cfg.MaxBitRate)
+	}
+	if a.estimate > 0 && a.target > 0.95*float64(a.estimate) {
+		a.target = 0.95 * float64(a.estimate)
+	}
+	if a.target < float64(a.cfg.MinBitRate) {
+		a.target = float64(a.cfg.MinBitRate)
+	}
+
+	to := int(a.target)
+	from := a.current
+	step := float64(to-from) / float64(from)
+	if (step > -minBitrateStep && step < minBitrateStep) || now.Sub(a.lastChange) < a.cfg.MinInterval {
+		a.mu.Unlock()
+		return
+	}
+	a.lastChange = now
+	ev := BitrateChange{From: from, To: to, Loss: a.loss, Estimate: a.estimate}
+	a.mu.Unlock()
+
+	ev.Err = a.apply(to)
+
+	a.mu.Lock()
+	if ev.Err == nil {
+		a.current = to
+	}
+	a.mu.Unlock()
+
+	if a.cfg.OnChange != nil {
+		a.cfg.OnChange(ev)
+	}
+}
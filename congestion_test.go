@@ -0,0 +1,86 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// testRR builds an RTCP receiver report with one report block.
+func testRR(ssrc uint32, fractionLost byte) []byte {
+	pkt := make([]byte, 32)
+	pkt[0] = 0x81 // V=2, RC=1
+	pkt[1] = 201
+	binary.BigEndian.PutUint16(pkt[2:4], 7)
+	binary.BigEndian.PutUint32(pkt[4:8], 0xCAFE) // reporter
+	binary.BigEndian.PutUint32(pkt[8:12], ssrc)
+	pkt[12] = fractionLost
+	return pkt
+}
+
+// testREMB builds an RTCP REMB message for bps = mantissa << exp.
+func testREMB(mantissa uint32, exp byte) []byte {
+	pkt := make([]byte, 24)
+	pkt[0] = 0x8F // V=2, FMT=15
+	pkt[1] = 206
+	binary.BigEndian.PutUint16(pkt[2:4], 5)
+	copy(pkt[12:16], "REMB")
+	pkt[16] = 1
+	pkt[17] = exp<<2 | byte(mantissa>>16&0x03)
+	pkt[18] = byte(mantissa >> 8)
+	pkt[19] = byte(mantissa)
+	return pkt
+}
+
+func TestBitrateAdapter(t *testing.T) {
+	var applied []int
+	a := newBitrateAdapter(
+		func() uint32 { return 0x1234 },
+		func(kbps int) error { applied = append(applied, kbps); return nil },
+		2000,
+		BitrateAdapterConfig{MinInterval: 1},
+	)
+
+	// Reports about other streams are ignored.
+	a.HandleRTCP(testRR(0x9999, 128))
+	if a.Target() != 2000 {
+		t.Fatalf("target after foreign report = %d, want 2000", a.Target())
+	}
+
+	// 25% loss: back off by 12.5%.
+	if err := a.HandleRTCP(testRR(0x1234, 64)); err != nil {
+		t.Fatalf("HandleRTCP: %v", err)
+	}
+	if a.Target() != 1750 || len(applied) != 1 || applied[0] != 1750 {
+		t.Fatalf("after 25%% loss target=%d applied=%v, want 1750", a.Target(), applied)
+	}
+
+	// A 1 Mbps REMB estimate caps the target at 95%.
+	a.HandleRTCP(testREMB(250000, 2))
+	if a.Target() != 950 {
+		t.Fatalf("target after REMB = %d, want 950", a.Target())
+	}
+
+	// Low loss probes upwards but stays below the estimate.
+	a.HandleRTCP(testRR(0x1234, 0))
+	if a.Target() != 950 {
+		t.Errorf("target after clean report = %d, want capped 950", a.Target())
+	}
+	if got := applied[len(applied)-1]; got != 950 {
+		t.Errorf("last applied bitrate = %d, want 950", got)
+	}
+}
+
+func TestBitrateAdapter_SmallChangesNotApplied(t *testing.T) {
+	calls := 0
+	a := newBitrateAdapter(func() uint32 { return 1 }, func(int) error { calls++; return nil }, 1000,
+		BitrateAdapterConfig{MaxBitRate: 5000, MinInterval: 1})
+
+	a.HandleRTCP(testRR(1, 0)) // +5%
+	if calls != 0 {
+		t.Errorf("encoder reconfigured for a 5%% change")
+	}
+	a.HandleRTCP(testRR(1, 0)) // +10.25% in total
+	if calls != 1 {
+		t.Errorf("encoder reconfigured %d times, want 1", calls)
+	}
+}
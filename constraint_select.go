@@ -0,0 +1,264 @@
+package mediadevices
+
+import (
+	"fmt"
+	"math"
+)
+
+// 未约束的设置使用的默认值。
+const (
+	defaultVideoWidth     = 640
+	defaultVideoHeight    = 480
+	defaultVideoFrameRate = 30.0
+	defaultAudioRate      = 48000
+	defaultAudioChannels  = 2
+)
+
+// constraintEpsilon 是 Exact、Min、Max 比较时允许的相对误差。
+const constraintEpsilon = 1e-3
+
+// OverconstrainedError 表示设备没有能满足必需约束（Exact、Min、Max）的设置。
+// 对应 MDN 的 OverconstrainedError。
+type OverconstrainedError struct {
+	// Constraint 是无法满足的约束，使用 W3C 的名称，如 "width"、"frameRate"、"channelCount"。
+	Constraint string
+	// Message 说明原因。
+	Message string
+}
+
+func (e *OverconstrainedError) Error() string {
+	return fmt.Sprintf("overconstrained: %s: %s", e.Constraint, e.Message)
+}
+
+func (c ConstrainInt) double() ConstrainDouble {
+	conv := func(p *int) *float64 {
+		if p == nil {
+			return nil
+		}
+		v := float64(*p)
+		return &v
+	}
+	return ConstrainDouble{Min: conv(c.Min), Max: conv(c.Max), Ideal: conv(c.Ideal), Exact: conv(c.Exact)}
+}
+
+func (c ConstrainDouble) empty() bool {
+	return c.Min == nil && c.Max == nil && c.Ideal == nil && c.Exact == nil
+}
+
+// admits 报告 v 是否满足 c 的必需约束。
+func (c ConstrainDouble) admits(v float64) bool {
+	tol := func(x float64) float64 { return constraintEpsilon * math.Max(1, math.Abs(x)) }
+	switch {
+	case c.Exact != nil && math.Abs(v-*c.Exact) > tol(*c.Exact):
+		return false
+	case c.Min != nil && v < *c.Min-tol(*c.Min):
+		return false
+	case c.Max != nil && v > *c.Max+tol(*c.Max):
+		return false
+	}
+	return true
+}
+
+// distance 返回 v 与 Ideal 的适应度距离：|v - ideal| / max(|v|, |ideal|)，没有 Ideal 时为 0。
+func (c ConstrainDouble) distance(v float64) float64 {
+	if c.Ideal == nil {
+		return 0
+	}
+	return relativeDistance(v, *c.Ideal)
+}
+
+func relativeDistance(v, target float64) float64 {
+	if v == target {
+		return 0
+	}
+	return math.Abs(v-target) / math.Max(math.Abs(v), math.Abs(target))
+}
+
+// target 返回 c 希望的值：Exact，否则 Ideal，否则 def。
+func (c ConstrainDouble) target(def float64) float64 {
+	switch {
+	case c.Exact != nil:
+		return *c.Exact
+	case c.Ideal != nil:
+		return *c.Ideal
+	}
+	return def
+}
+
+// pick 在设备支持的区间 [lo, hi] 与 c 的 Min、Max 的交集内选择最接近 target(def) 的值。
+func (c ConstrainDouble) pick(def, lo, hi float64) float64 {
+	if c.Min != nil {
+		lo = math.Max(lo, *c.Min)
+	}
+	if c.Max != nil {
+		hi = math.Min(hi, *c.Max)
+	}
+	return math.Max(lo, math.Min(hi, c.target(def)))
+}
+
+// videoSettings 是为视频轨道选出的捕获设置。
+type videoSettings struct {
+	width, height int
+	frameRate     float64
+}
+
+// namedConstraint 是一个约束及其在设置中对应的值。
+type namedConstraint[S any] struct {
+	name  string
+	c     ConstrainDouble
+	value func(S) float64
+}
+
+func videoConstraintList(c *VideoTrackConstraints) []namedConstraint[videoSettings] {
+	return []namedConstraint[videoSettings]{
+		{"width", c.Width.double(), func(s videoSettings) float64 { return float64(s.width) }},
+		{"height", c.Height.double(), func(s videoSettings) float64 { return float64(s.height) }},
+		{"aspectRatio", c.AspectRatio, func(s videoSettings) float64 { return float64(s.width) / float64(s.height) }},
+		{"frameRate", c.FrameRate, func(s videoSettings) float64 { return s.frameRate }},
+	}
+}
+
+// fitness 返回设置 s 对约束 cs 的适应度距离之和；s 不满足必需约束时返回 +Inf 和该约束的名称。
+func fitness[S any](cs []namedConstraint[S], s S) (float64, string) {
+	var d float64
+	for _, nc := range cs {
+		v := nc.value(s)
+		if !nc.c.admits(v) {
+			return math.Inf(1), nc.name
+		}
+		d += nc.c.distance(v)
+	}
+	return d, ""
+}
+
+// selectSettings 在候选设置中选择适应度距离最小的一个，相同时选择 def 距离最小的
+// （未约束的设置接近默认值）。没有满足必需约束的候选时返回 *OverconstrainedError，
+// 报告第一个没有任何候选能满足的约束。
+func selectSettings[S any](cs []namedConstraint[S], candidates []S, def func(S) float64) (S, error) {
+	best, bestFit, bestDef := -1, math.Inf(1), math.Inf(1)
+	for i, s := range candidates {
+		fit, _ := fitness(cs, s)
+		if math.IsInf(fit, 1) {
+			continue
+		}
+		if d := def(s); fit < bestFit || (fit == bestFit && d < bestDef) {
+			best, bestFit, bestDef = i, fit, d
+		}
+	}
+	if best >= 0 {
+		return candidates[best], nil
+	}
+
+	var zero S
+	for _, nc := range cs {
+		satisfiable := false
+		for _, s := range candidates {
+			if nc.c.admits(nc.value(s)) {
+				satisfiable = true
+				break
+			}
+		}
+		if !satisfiable {
+			return zero, &OverconstrainedError{Constraint: nc.name, Message: "no setting of the device satisfies it"}
+		}
+	}
+	name := "unknown"
+	if len(candidates) > 0 {
+		_, name = fitness(cs, candidates[0])
+	}
+	return zero, &OverconstrainedError{Constraint: name, Message: "no setting of the device satisfies it together with the other constraints"}
+}
+
+// selectVideoSettings 按约束 c 在设备的捕获模式 modes 中选择设置。每个模式的帧率在其帧率范围内
+// 按约束选取；modes 为空（设备不报告模式）时直接按约束取值。
+func selectVideoSettings(c *VideoTrackConstraints, modes []VideoMode) (videoSettings, error) {
+	cs := videoConstraintList(c)
+	def := func(s videoSettings) float64 {
+		return relativeDistance(float64(s.width), defaultVideoWidth) +
+			relativeDistance(float64(s.height), defaultVideoHeight) +
+			relativeDistance(s.frameRate, defaultVideoFrameRate)
+	}
+
+	var candidates []videoSettings
+	for _, m := range modes {
+		if m.Width <= 0 || m.Height <= 0 {
+			continue
+		}
+		lo, hi := 0.0, math.Inf(1)
+		if m.MaxFrameRate > 0 {
+			lo, hi = m.MinFrameRate, m.MaxFrameRate
+		}
+		candidates = append(candidates, videoSettings{
+			width:     m.Width,
+			height:    m.Height,
+			frameRate: c.FrameRate.pick(defaultVideoFrameRate, lo, hi),
+		})
+	}
+	if len(candidates) == 0 {
+		candidates = []videoSettings{directVideoSettings(c)}
+	}
+	return selectSettings(cs, candidates, def)
+}
+
+// directVideoSettings 在不知道设备模式时直接按约束取值。只约束了宽度或高度之一且给出了
+// 宽高比时，另一边由宽高比推出。
+func directVideoSettings(c *VideoTrackConstraints) videoSettings {
+	inf := math.Inf(1)
+	w := c.Width.double().pick(defaultVideoWidth, 1, inf)
+	h := c.Height.double().pick(defaultVideoHeight, 1, inf)
+	if !c.AspectRatio.empty() {
+		ratio := c.AspectRatio.pick(float64(defaultVideoWidth)/defaultVideoHeight, 0, inf)
+		switch {
+		case ratio <= 0:
+		case !c.Width.double().empty() && c.Height.double().empty():
+			h = math.Round(w / ratio)
+		case c.Width.double().empty():
+			w = math.Round(h * ratio)
+		}
+	}
+	return videoSettings{
+		width:     int(math.Round(w)),
+		height:    int(math.Round(h)),
+		frameRate: c.FrameRate.pick(defaultVideoFrameRate, 0, inf),
+	}
+}
+
+// audioSettings 是为音频轨道选出的捕获设置。
+type audioSettings struct {
+	sampleRate, channels int
+}
+
+// selectAudioSettings 按约束 c 选择音频设置。音频总以 16 位交付，SampleSize 据此检查。
+// 任何采样率和声道数都可由 FFmpeg 转换得到，因此按约束直接取值；设备的原生格式 modes 中
+// 有与之同样符合约束（适应度距离为 0）的格式时优先使用原生格式。
+func selectAudioSettings(c *AudioTrackConstraints, modes []AudioMode) (audioSettings, error) {
+	if size := c.SampleSize.double(); !size.admits(16) {
+		return audioSettings{}, &OverconstrainedError{Constraint: "sampleSize", Message: "audio is delivered as 16-bit samples only"}
+	}
+	cs := []namedConstraint[audioSettings]{
+		{"sampleRate", c.SampleRate.double(), func(s audioSettings) float64 { return float64(s.sampleRate) }},
+		{"channelCount", c.Channels.double(), func(s audioSettings) float64 { return float64(s.channels) }},
+	}
+	def := func(s audioSettings) float64 {
+		return relativeDistance(float64(s.sampleRate), defaultAudioRate) +
+			relativeDistance(float64(s.channels), defaultAudioChannels)
+	}
+
+	var native []audioSettings
+	for _, m := range modes {
+		if m.SampleRate > 0 && m.Channels > 0 {
+			native = append(native, audioSettings{m.SampleRate, m.Channels})
+		}
+	}
+	if s, err := selectSettings(cs, native, def); err == nil {
+		if fit, _ := fitness(cs, s); fit == 0 {
+			return s, nil
+		}
+	}
+	inf := math.Inf(1)
+	direct := audioSettings{
+		sampleRate: int(math.Round(c.SampleRate.double().pick(defaultAudioRate, 1, inf))),
+		channels:   int(math.Round(c.Channels.double().pick(defaultAudioChannels, 1, inf))),
+	}
+	return selectSettings(cs, []audioSettings{direct}, def)
+}
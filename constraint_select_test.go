@@ -0,0 +1,79 @@
+package mediadevices
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSelectVideoSettings(t *testing.T) {
+	modes := []VideoMode{
+		{Width: 640, Height: 480, MinFrameRate: 5, MaxFrameRate: 30, PixelFormat: "yuyv422"},
+		{Width: 1280, Height: 720, MinFrameRate: 5, MaxFrameRate: 30, PixelFormat: "yuyv422"},
+		{Width: 1280, Height: 720, MinFrameRate: 5, MaxFrameRate: 60, Codec: "mjpeg"},
+		{Width: 1920, Height: 1080, MinFrameRate: 5, MaxFrameRate: 30, Codec: "mjpeg"},
+	}
+	for _, tc := range []struct {
+		name  string
+		c     VideoTrackConstraints
+		modes []VideoMode
+		want  videoSettings
+		fail  string // constraint of the expected OverconstrainedError
+	}{
+		{name: "defaults", modes: modes, want: videoSettings{640, 480, 30}},
+		{name: "ideal size", c: VideoTrackConstraints{Width: IdealInt(1280), Height: IdealInt(720)}, modes: modes, want: videoSettings{1280, 720, 30}},
+		{name: "closest width", c: VideoTrackConstraints{Width: IdealInt(1000)}, modes: modes, want: videoSettings{1280, 720, 30}},
+		{name: "ideal rate clamped to mode", c: VideoTrackConstraints{FrameRate: IdealFloat64(100)}, modes: modes, want: videoSettings{1280, 720, 60}},
+		{name: "exact rate", c: VideoTrackConstraints{FrameRate: ExactFloat64(60)}, modes: modes, want: videoSettings{1280, 720, 60}},
+		{name: "min width", c: VideoTrackConstraints{Width: ConstrainInt{Min: IntPtr(1500)}}, modes: modes, want: videoSettings{1920, 1080, 30}},
+		{name: "aspect ratio", c: VideoTrackConstraints{AspectRatio: ExactFloat64(16.0 / 9), FrameRate: IdealFloat64(15)}, modes: modes, want: videoSettings{1280, 720, 15}},
+		{name: "exact width missing", c: VideoTrackConstraints{Width: ExactInt(800)}, modes: modes, fail: "width"},
+		{name: "combination", c: VideoTrackConstraints{Width: ConstrainInt{Min: IntPtr(1900)}, FrameRate: ExactFloat64(60)}, modes: modes, fail: "width"},
+		{name: "no modes", c: VideoTrackConstraints{Width: IdealInt(1280), AspectRatio: IdealFloat64(16.0 / 9)}, want: videoSettings{1280, 720, 30}},
+		{name: "no modes exact rate", c: VideoTrackConstraints{FrameRate: ExactFloat64(15)}, want: videoSettings{640, 480, 15}},
+		{name: "empty range", c: VideoTrackConstraints{Width: IntRange(100, 50)}, fail: "width"},
+	} {
+		got, err := selectVideoSettings(&tc.c, tc.modes)
+		if tc.fail != "" {
+			var oc *OverconstrainedError
+			if !errors.As(err, &oc) || oc.Constraint != tc.fail {
+				t.Errorf("%s: got %+v, %v; want overconstrained %s", tc.name, got, err, tc.fail)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %+v, %v; want %+v", tc.name, got, err, tc.want)
+		}
+	}
+}
+
+func TestSelectAudioSettings(t *testing.T) {
+	modes := []AudioMode{{SampleRate: 44100, Channels: 2, BitsPerSample: 16}, {SampleRate: 48000, Channels: 1, BitsPerSample: 16}}
+	for _, tc := range []struct {
+		name  string
+		c     AudioTrackConstraints
+		modes []AudioMode
+		want  audioSettings
+		fail  string
+	}{
+		{name: "defaults", want: audioSettings{48000, 2}},
+		{name: "native format", modes: modes, want: audioSettings{44100, 2}},
+		{name: "native mono", c: AudioTrackConstraints{Channels: ExactInt(1)}, modes: modes, want: audioSettings{48000, 1}},
+		{name: "converted", c: AudioTrackConstraints{SampleRate: IdealInt(16000)}, modes: modes, want: audioSettings{16000, 2}},
+		{name: "converted channels", c: AudioTrackConstraints{Channels: ExactInt(6)}, modes: modes, want: audioSettings{48000, 6}},
+		{name: "range", c: AudioTrackConstraints{SampleRate: IntRange(8000, 16000)}, want: audioSettings{16000, 2}},
+		{name: "sample size", c: AudioTrackConstraints{SampleSize: ExactInt(24)}, fail: "sampleSize"},
+		{name: "ideal sample size", c: AudioTrackConstraints{SampleSize: IdealInt(24)}, want: audioSettings{48000, 2}},
+	} {
+		got, err := selectAudioSettings(&tc.c, tc.modes)
+		if tc.fail != "" {
+			var oc *OverconstrainedError
+			if !errors.As(err, &oc) || oc.Constraint != tc.fail {
+				t.Errorf("%s: got %+v, %v; want overconstrained %s", tc.name, got, err, tc.fail)
+			}
+			continue
+		}
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %+v, %v; want %+v", tc.name, got, err, tc.want)
+		}
+	}
+}
@@ -1,26 +1,32 @@
 package mediadevices
 
+import "time"
+
 // MediaTrackSupportedConstraints 表示浏览器支持的轨道约束。
 // 对应 MDN 的 MediaTrackSupportedConstraints 接口。
 type MediaTrackSupportedConstraints struct {
 	// Width 是否支持宽度约束。
-	Width bool
+	Width bool `json:"width"`
 	// Height 是否支持高度约束。
-	Height bool
+	Height bool `json:"height"`
 	// FrameRate 是否支持帧率约束。
-	FrameRate bool
+	FrameRate bool `json:"frameRate"`
 	// AspectRatio 是否支持宽高比约束。
-	AspectRatio bool
+	AspectRatio bool `json:"aspectRatio"`
 	// SampleRate 是否支持采样率约束（音频）。
-	SampleRate bool
+	SampleRate bool `json:"sampleRate"`
 	// SampleSize 是否支持采样大小约束（音频）。
-	SampleSize bool
+	SampleSize bool `json:"sampleSize"`
+	// Latency 是否支持延迟约束（音频）。
+	Latency bool `json:"latency"`
 	// EchoCancellation 是否支持回声消除约束（音频）。
-	EchoCancellation bool
+	EchoCancellation bool `json:"echoCancellation"`
 	// AutoGainControl 是否支持自动增益控制约束（音频）。
-	AutoGainControl bool
+	AutoGainControl bool `json:"autoGainControl"`
 	// NoiseSuppression 是否支持噪声抑制约束（音频）。
-	NoiseSuppression bool
+	NoiseSuppression bool `json:"noiseSuppression"`
+	// FacingMode 是否支持摄像头朝向约束（视频）。
+	FacingMode bool `json:"facingMode"`
 }
 
 // GetSupportedConstraints 返回当前系统支持的轨道约束。
@@ -33,23 +39,83 @@ func GetSupportedConstraints() MediaTrackSupportedConstraints {
 		AspectRatio:      true,
 		SampleRate:       true,
 		SampleSize:       true,
+		Latency:          true,
 		EchoCancellation: true,
 		AutoGainControl:  true,
 		NoiseSuppression: true,
+		FacingMode:       true,
 	}
 }
 
+// ConstrainInt 是整数设置的约束，对应 W3C 的 ConstrainULong。零值表示不约束。
+//
+// Exact、Min 和 Max 是必需约束：没有能满足它们的设置时 GetUserMedia 返回
+// *OverconstrainedError。Ideal 只是期望值：在满足必需约束的设置中选择最接近它的，
+// 即使相差很远也不会失败。浏览器中直接写 width: 1280 相当于 IdealInt(1280)。
+type ConstrainInt struct {
+	Min   *int
+	Max   *int
+	Ideal *int
+	Exact *int
+}
+
+// ConstrainDouble 是浮点数设置的约束，对应 W3C 的 ConstrainDouble，语义与 ConstrainInt 相同。
+// Exact 允许千分之一的相对误差，因此 29.97 与 30000/1001 视为相等。
+type ConstrainDouble struct {
+	Min   *float64
+	Max   *float64
+	Ideal *float64
+	Exact *float64
+}
+
+// IdealInt 返回期望值为 v 的约束。
+func IdealInt(v int) ConstrainInt {
+	return ConstrainInt{Ideal: &v}
+}
+
+// ExactInt 返回必须等于 v 的约束。
+func ExactInt(v int) ConstrainInt {
+	return ConstrainInt{Exact: &v}
+}
+
+// IntRange 返回必须在 [min, max] 内的约束。
+func IntRange(min, max int) ConstrainInt {
+	return ConstrainInt{Min: &min, Max: &max}
+}
+
+// IdealFloat64 返回期望值为 v 的约束。
+func IdealFloat64(v float64) ConstrainDouble {
+	return ConstrainDouble{Ideal: &v}
+}
+
+// ExactFloat64 返回必须等于 v 的约束。
+func ExactFloat64(v float64) ConstrainDouble {
+	return ConstrainDouble{Exact: &v}
+}
+
+// Float64Range 返回必须在 [min, max] 内的约束。
+func Float64Range(min, max float64) ConstrainDouble {
+	return ConstrainDouble{Min: &min, Max: &max}
+}
+
 // VideoTrackConstraints 表示视频轨道的约束条件。
 // 用于 GetUserMedia 调用时指定视频捕获参数。
+//
+// 设置了尺寸、帧率或宽高比约束时，GetUserMedia 先用 GetDeviceCapabilities 查询设备的捕获模式，
+// 按 W3C 的适应度距离（fitness distance）算法选择最合适的真实模式；
+// 设备不报告模式时直接按约束取值。未约束的设置默认 640x480、30fps。
 type VideoTrackConstraints struct {
-	// Width 指定期望的视频宽度（像素）。
-	Width *int
-	// Height 指定期望的视频高度（像素）。
-	Height *int
-	// FrameRate 指定期望的帧率。
-	FrameRate *float64
-	// AspectRatio 指定期望的宽高比（宽度/高度）。
-	AspectRatio *float64
+	// Width 约束视频宽度（像素）。
+	Width ConstrainInt
+	// Height 约束视频高度（像素）。
+	Height ConstrainInt
+	// FrameRate 约束帧率。
+	FrameRate ConstrainDouble
+	// AspectRatio 约束宽高比（宽度/高度）。
+	AspectRatio ConstrainDouble
+	// FacingMode 约束摄像头的朝向（MediaDeviceInfo.FacingMode），用于选择前置或后置摄像头。
+	// 设置了 DeviceID 时只检查 Exact。
+	FacingMode ConstrainFacingMode
 	// DeviceID 指定使用的设备 ID。
 	// 如果为 nil，则使用默认视频设备。
 	DeviceID *string
@@ -57,11 +123,19 @@ type VideoTrackConstraints struct {
 
 // AudioTrackConstraints 表示音频轨道的约束条件。
 // 用于 GetUserMedia 调用时指定音频捕获参数。
+//
+// FFmpeg 可以把音频转换为任意采样率和声道数，因此 SampleRate 和 Channels 总能满足；
+// 设备报告了格式（Windows）且某个原生格式同样符合约束时优先使用它。未约束时默认 48kHz 立体声。
 type AudioTrackConstraints struct {
-	// SampleRate 指定期望的采样率（Hz）。
-	SampleRate *int
-	// Channels 指定期望的声道数（1=单声道，2=立体声）。
-	Channels *int
+	// SampleRate 约束采样率（Hz）。
+	SampleRate ConstrainInt
+	// Channels 约束声道数（1=单声道，2=立体声）。
+	Channels ConstrainInt
+	// SampleSize 约束采样大小（位）。音频总是以 16 位（S16LE）交付，不允许 16 的约束返回错误。
+	SampleSize ConstrainInt
+	// Latency 指定每段音频（ReadAudio 返回的 AudioChunk）的时长，即分段带来的延迟。
+	// 为 nil 时使用 Config.LatencyProfile 的音频分段时长，没有延迟配置时为 20ms。
+	Latency *time.Duration
 	// EchoCancellation 是否启用回声消除。
 	EchoCancellation *bool
 	// AutoGainControl 是否启用自动增益控制。
@@ -95,10 +169,16 @@ type MediaTrackSettings struct {
 	FrameRate float64
 	// AspectRatio 视频的实际宽高比。
 	AspectRatio float64
+	// FacingMode 摄像头的朝向，未知时为空。
+	FacingMode FacingMode
 	// SampleRate 音频的实际采样率。
 	SampleRate int
+	// ChannelCount 音频的实际声道数。
+	ChannelCount int
 	// SampleSize 音频的实际采样大小（位）。
 	SampleSize int
+	// Latency 音频每段的实际时长。
+	Latency time.Duration
 	// EchoCancellation 是否启用了回声消除。
 	EchoCancellation bool
 	// AutoGainControl 是否启用了自动增益控制。
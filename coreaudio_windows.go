@@ -0,0 +1,197 @@
+//go:build windows
+
+package mediadevices
+
+import (
+	"fmt"
+	"runtime"
+	"syscall"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	ole32            = windows.NewLazySystemDLL("ole32.dll")
+	coInitializeEx   = ole32.NewProc("CoInitializeEx")
+	coCreateInstance = ole32.NewProc("CoCreateInstance")
+	propVariantClear = ole32.NewProc("PropVariantClear")
+)
+
+var (
+	clsidMMDeviceEnumerator = windows.GUID{Data1: 0xBCDE0395, Data2: 0xE52F, Data3: 0x467C, Data4: [8]byte{0x8E, 0x3D, 0xC4, 0x57, 0x92, 0x91, 0x69, 0x2E}}
+	iidIMMDeviceEnumerator  = windows.GUID{Data1: 0xA95664D2, Data2: 0x9614, Data3: 0x4F35, Data4: [8]byte{0xA7, 0x46, 0xDE, 0x8D, 0xB6, 0x36, 0x17, 0xE6}}
+	pkeyDeviceFriendlyName  = propertyKey{
+		fmtid: windows.GUID{Data1: 0xA45C254E, Data2: 0xDF1C, Data3: 0x4EFD, Data4: [8]byte{0x80, 0x20, 0x67, 0xD1, 0x46, 0xA8, 0x50, 0xE0}},
+		pid:   14,
+	}
+	pkeyDeviceContainerID = propertyKey{
+		fmtid: windows.GUID{Data1: 0x8C7ED206, Data2: 0x3F8A, Data3: 0x4827, Data4: [8]byte{0xB3, 0xAB, 0xAE, 0x9E, 0x1F, 0xAE, 0xFC, 0x6C}},
+		pid:   2,
+	}
+)
+
+const (
+	clsctxAll          = 0x17
+	eRender            = 0
+	eCapture           = 1
+	eConsole           = 0
+	deviceStateActive  = 0x1
+	stgmRead           = 0
+	vtLPWSTR           = 31
+	vtCLSID            = 72
+	rpcEChangedMode    = 0x80010106
+	vtblRelease        = 2
+	vtblEnumEndpoints  = 3 // IMMDeviceEnumerator::EnumAudioEndpoints
+	vtblDefaultDevice  = 4 // IMMDeviceEnumerator::GetDefaultAudioEndpoint
+	vtblCollCount      = 3 // IMMDeviceCollection::GetCount
+	vtblCollItem       = 4 // IMMDeviceCollection::Item
+	vtblOpenPropStore  = 4 // IMMDevice::OpenPropertyStore
+	vtblPropStoreValue = 5 // IPropertyStore::GetValue
+)
+
+type propertyKey struct {
+	fmtid windows.GUID
+	pid   uint32
+}
+
+// propVariant mirrors PROPVARIANT for the pointer-valued cases used here
+// (VT_LPWSTR and VT_CLSID).
+type propVariant struct {
+	vt       uint16
+	reserved [3]uint16
+	val      unsafe.Pointer
+	_        uintptr
+}
+
+// comObject is the memory layout of a COM interface pointer.
+type comObject struct {
+	vtbl *[8]uintptr
+}
+
+// comCall invokes method i of the COM object's vtable and returns the HRESULT.
+func comCall(obj *comObject, i int, args ...uintptr) uintptr {
+	hr, _, _ := syscall.SyscallN(obj.vtbl[i], append([]uintptr{uintptr(unsafe.Pointer(obj))}, args...)...)
+	return hr
+}
+
+// withDeviceEnumerator runs fn with a Core Audio IMMDeviceEnumerator on a
+// COM-initialized, locked OS thread.
+func withDeviceEnumerator(fn func(enumerator *comObject) error) error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	// S_OK and S_FALSE must be balanced by CoUninitialize; RPC_E_CHANGED_MODE
+	// means COM is already initialized on this thread, which is fine too.
+	if hr, _, _ := coInitializeEx.Call(0, windows.COINIT_MULTITHREADED); hr == 0 || hr == 1 {
+		defer windows.CoUninitialize()
+	} else if uint32(hr) != rpcEChangedMode {
+		return fmt.Errorf("CoInitializeEx: HRESULT 0x%08x", uint32(hr))
+	}
+
+	var enumerator *comObject
+	if hr, _, _ := coCreateInstance.Call(
+		uintptr(unsafe.Pointer(&clsidMMDeviceEnumerator)), 0, clsctxAll,
+		uintptr(unsafe.Pointer(&iidIMMDeviceEnumerator)), uintptr(unsafe.Pointer(&enumerator))); hr != 0 {
+		return fmt.Errorf("create MMDeviceEnumerator: HRESULT 0x%08x", uint32(hr))
+	}
+	defer comCall(enumerator, vtblRelease)
+	return fn(enumerator)
+}
+
+// endpointProperty reads one property of an IMMDevice. The value is the
+// friendly name for VT_LPWSTR and the braced GUID for VT_CLSID.
+func endpointProperty(device *comObject, key *propertyKey) (string, error) {
+	var store *comObject
+	if hr := comCall(device, vtblOpenPropStore, stgmRead, uintptr(unsafe.Pointer(&store))); hr != 0 {
+		return "", fmt.Errorf("OpenPropertyStore: HRESULT 0x%08x", uint32(hr))
+	}
+	defer comCall(store, vtblRelease)
+
+	var pv propVariant
+	if hr := comCall(store, vtblPropStoreValue, uintptr(unsafe.Pointer(key)), uintptr(unsafe.Pointer(&pv))); hr != 0 {
+		return "", fmt.Errorf("GetValue: HRESULT 0x%08x", uint32(hr))
+	}
+	defer propVariantClear.Call(uintptr(unsafe.Pointer(&pv)))
+	switch {
+	case pv.val == nil:
+	case pv.vt == vtLPWSTR:
+		return windows.UTF16PtrToString((*uint16)(pv.val)), nil
+	case pv.vt == vtCLSID:
+		return (*windows.GUID)(pv.val).String(), nil
+	}
+	return "", fmt.Errorf("GetValue: unexpected variant type %d", pv.vt)
+}
+
+// defaultCaptureEndpointName returns the friendly name of the default audio
+// capture endpoint for the console role, e.g. "Microphone (Realtek(R) Audio)".
+func defaultCaptureEndpointName() (string, error) {
+	return defaultEndpointName(eCapture)
+}
+
+// defaultEndpointName returns the friendly name of the default endpoint of
+// the data flow (eRender or eCapture) for the console role.
+func defaultEndpointName(flow uintptr) (string, error) {
+	var name string
+	err := withDeviceEnumerator(func(enumerator *comObject) error {
+		var device *comObject
+		if hr := comCall(enumerator, vtblDefaultDevice, flow, eConsole, uintptr(unsafe.Pointer(&device))); hr != 0 {
+			return fmt.Errorf("GetDefaultAudioEndpoint: HRESULT 0x%08x", uint32(hr))
+		}
+		defer comCall(device, vtblRelease)
+		var err error
+		name, err = endpointProperty(device, &pkeyDeviceFriendlyName)
+		return err
+	})
+	return name, err
+}
+
+// captureEndpointContainers maps the friendly name of each active audio
+// capture endpoint to the container ID of the physical device it belongs to.
+func captureEndpointContainers() (map[string]string, error) {
+	containers := make(map[string]string)
+	endpoints, err := activeEndpoints(eCapture)
+	for _, e := range endpoints {
+		containers[e.name] = e.container
+	}
+	return containers, err
+}
+
+// audioEndpoint is an active Core Audio endpoint.
+type audioEndpoint struct {
+	name      string // friendly name, e.g. "Speakers (Realtek(R) Audio)"
+	container string // container ID of the physical device
+}
+
+// activeEndpoints lists the active endpoints of the data flow (eRender or
+// eCapture) in Core Audio's order. Endpoints whose properties cannot be read
+// are skipped.
+func activeEndpoints(flow uintptr) ([]audioEndpoint, error) {
+	var endpoints []audioEndpoint
+	err := withDeviceEnumerator(func(enumerator *comObject) error {
+		var coll *comObject
+		if hr := comCall(enumerator, vtblEnumEndpoints, flow, deviceStateActive, uintptr(unsafe.Pointer(&coll))); hr != 0 {
+			return fmt.Errorf("EnumAudioEndpoints: HRESULT 0x%08x", uint32(hr))
+		}
+		defer comCall(coll, vtblRelease)
+
+		var n uint32
+		if hr := comCall(coll, vtblCollCount, uintptr(unsafe.Pointer(&n))); hr != 0 {
+			return fmt.Errorf("GetCount: HRESULT 0x%08x", uint32(hr))
+		}
+		for i := uint32(0); i < n; i++ {
+			var device *comObject
+			if comCall(coll, vtblCollItem, uintptr(i), uintptr(unsafe.Pointer(&device))) != 0 {
+				continue
+			}
+			name, err1 := endpointProperty(device, &pkeyDeviceFriendlyName)
+			container, err2 := endpointProperty(device, &pkeyDeviceContainerID)
+			comCall(device, vtblRelease)
+			if err1 == nil && err2 == nil {
+				endpoints = append(endpoints, audioEndpoint{name: name, container: container})
+			}
+		}
+		return nil
+	})
+	return endpoints, err
+}
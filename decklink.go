@@ -0,0 +1,177 @@
+package mediadevices
+
+import (
+	"context"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// deckLinkDevicePrefix marks the device names of Blackmagic DeckLink
+// inputs ("decklink:DeckLink Mini Recorder"), which are opened with FFmpeg's
+// decklink input on every platform rather than the platform's camera API.
+const deckLinkDevicePrefix = "decklink:"
+
+// deckLinkDeviceRe matches device lines of "-f decklink -list_devices 1"
+// like: [decklink @ 0x...] 	'DeckLink Mini Recorder'
+var deckLinkDeviceRe = regexp.MustCompile(`\[decklink\s+@\s+\S+\]\s+'(.+)'\s*$`)
+
+// deckLinkMode is a video mode of a DeckLink input and the format code
+// FFmpeg's -format_code selects it with.
+type deckLinkMode struct {
+	name      string // "1080p60"
+	code      string
+	width     int
+	height    int
+	frameRate float64 // frames, not fields, per second
+}
+
+// deckLinkModes are the common SD, HD and UHD modes. Progressive modes come
+// first so that they are preferred over interlaced ones of the same frame
+// rate.
+var deckLinkModes = []deckLinkMode{
+	{"1080p23.98", "23ps", 1920, 1080, 24000.0 / 1001},
+	{"1080p24", "24ps", 1920, 1080, 24},
+	{"1080p25", "Hp25", 1920, 1080, 25},
+	{"1080p29.97", "Hp29", 1920, 1080, 30000.0 / 1001},
+	{"1080p30", "Hp30", 1920, 1080, 30},
+	{"1080p50", "Hp50", 1920, 1080, 50},
+	{"1080p59.94", "Hp59", 1920, 1080, 60000.0 / 1001},
+	{"1080p60", "Hp60", 1920, 1080, 60},
+	{"720p50", "hp50", 1280, 720, 50},
+	{"720p59.94", "hp59", 1280, 720, 60000.0 / 1001},
+	{"720p60", "hp60", 1280, 720, 60},
+	{"2160p23.98", "4k23", 3840, 2160, 24000.0 / 1001},
+	{"2160p24", "4k24", 3840, 2160, 24},
+	{"2160p25", "4k25", 3840, 2160, 25},
+	{"2160p29.97", "4k29", 3840, 2160, 30000.0 / 1001},
+	{"2160p30", "4k30", 3840, 2160, 30},
+	{"2160p50", "4k50", 3840, 2160, 50},
+	{"2160p59.94", "4k59", 3840, 2160, 60000.0 / 1001},
+	{"2160p60", "4k60", 3840, 2160, 60},
+	{"1080i50", "Hi50", 1920, 1080, 25},
+	{"1080i59.94", "Hi59", 1920, 1080, 30000.0 / 1001},
+	{"1080i60", "Hi60", 1920, 1080, 30},
+	{"NTSC", "ntsc", 720, 486, 30000.0 / 1001},
+	{"PAL", "pal ", 720, 576, 25},
+}
+
+// deckLinkFormatCode returns the format code of the DeckLink mode with the
+// given size and frame rate. A frame rate matches within 0.01, so 59.94
+// selects the 60000/1001 modes. Without a match it returns "": FFmpeg then
+// detects the mode of the incoming signal, if the card can, and the output
+// is scaled to the requested size.
+func deckLinkFormatCode(width, height int, frameRate float64) string {
+	for _, m := range deckLinkModes {
+		if m.width == width && m.height == height && math.Abs(m.frameRate-frameRate) < 0.01 {
+			return m.code
+		}
+	}
+	return ""
+}
+
+// deckLinkModeByName returns the format code of a mode name such as
+// "1080p60" or "1080i50", or of a format code such as "Hp60".
+func deckLinkModeByName(name string) (string, bool) {
+	for _, m := range deckLinkModes {
+		if strings.EqualFold(m.name, name) || strings.TrimSpace(m.code) == name {
+			return m.code, true
+		}
+	}
+	return "", false
+}
+
+// deckLinkInput splits "decklink:Name" or "decklink:Name@1080i50" into the
+// card name and the format code of the mode after "@", if it names one.
+func deckLinkInput(device string) (name, code string) {
+	name = strings.TrimPrefix(device, deckLinkDevicePrefix)
+	if i := strings.LastIndex(name, "@"); i >= 0 {
+		if code, ok := deckLinkModeByName(name[i+1:]); ok {
+			return name[:i], code
+		}
+	}
+	return name, ""
+}
+
+// isDeckLinkDevice reports whether an FFmpeg device name is a DeckLink input.
+func isDeckLinkDevice(name string) bool {
+	return strings.HasPrefix(name, deckLinkDevicePrefix)
+}
+
+// buildDeckLinkVideoInputArgs builds the FFmpeg input arguments for the
+// video of a DeckLink input. A mode given after "@" in the device name takes
+// precedence over the one selected by size and frame rate.
+func buildDeckLinkVideoInputArgs(p VideoCaptureParams) []string {
+	name, code := deckLinkInput(p.DeviceID)
+	if code == "" {
+		code = deckLinkFormatCode(p.Width, p.Height, p.FrameRate)
+	}
+	args := []string{"-f", "decklink"}
+	if code != "" {
+		args = append(args, "-format_code", code)
+	}
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+	return append(args, "-i", name)
+}
+
+// buildDeckLinkAudioInputArgs builds the FFmpeg input arguments for the
+// embedded audio of a DeckLink input. DeckLink audio is always 48 kHz and
+// has 2, 8 or 16 channels; the output resamples and downmixes it.
+func buildDeckLinkAudioInputArgs(p AudioCaptureParams) []string {
+	name, code := deckLinkInput(p.DeviceID)
+	args := []string{"-f", "decklink"}
+	if code != "" {
+		args = append(args, "-format_code", code)
+	}
+	switch {
+	case p.Channels > 8:
+		args = append(args, "-channels", "16")
+	case p.Channels > 2:
+		args = append(args, "-channels", "8")
+	}
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+	return append(args, "-i", name)
+}
+
+// discoverDeckLinkDevices lists the DeckLink inputs. Each one is both a
+// video and an audio input of the same group. FFmpeg builds without DeckLink
+// support fail with "Unknown input format", which lists no devices.
+func discoverDeckLinkDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	output, err := runDeviceList(ctx, ffmpegPath, "-hide_banner", "-f", "decklink", "-list_devices", "1", "-i", "dummy")
+	return parseDeckLinkOutput(output), err
+}
+
+// parseDeckLinkOutput parses the output of "-f decklink -list_devices 1".
+func parseDeckLinkOutput(output string) []MediaDeviceInfo {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	for _, line := range strings.Split(output, "\n") {
+		m := deckLinkDeviceRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		name := deckLinkDevicePrefix + m[1]
+		for _, kind := range []MediaDeviceKind{MediaDeviceKindVideoInput, MediaDeviceKindAudioInput} {
+			devices = append(devices, MediaDeviceInfo{
+				DeviceID:   ids.id(name, kind),
+				DeviceName: name,
+				GroupID:    name,
+				Kind:       kind,
+				Label:      m[1],
+			})
+		}
+	}
+	return devices
+}
+
+// probeDeckLinkModes lists the modes of a DeckLink input. The embedded
+// audio has no formats to list.
+func probeDeckLinkModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	if kind != MediaDeviceKindVideoInput {
+		return "", nil
+	}
+	name, _ = deckLinkInput(name)
+	return runDeviceList(ctx, ffmpegPath, "-hide_banner", "-f", "decklink", "-list_formats", "1", "-i", name)
+}
@@ -0,0 +1,98 @@
+package mediadevices
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseDeckLinkOutput(t *testing.T) {
+	output := "[decklink @ 0x55d0] Blackmagic DeckLink input devices:\n" +
+		"[decklink @ 0x55d0] \t'DeckLink Mini Recorder'\r\n" +
+		"[decklink @ 0x55d0] \t'DeckLink Duo (1)'\n" +
+		"dummy: Immediate exit requested\n"
+	devices := parseDeckLinkOutput(output)
+	if len(devices) != 4 {
+		t.Fatalf("got %d devices, want a video and an audio input per card: %+v", len(devices), devices)
+	}
+	video, audio := devices[2], devices[3]
+	if video.Kind != MediaDeviceKindVideoInput || audio.Kind != MediaDeviceKindAudioInput {
+		t.Errorf("kinds = %s, %s", video.Kind, audio.Kind)
+	}
+	if video.DeviceName != "decklink:DeckLink Duo (1)" || video.Label != "DeckLink Duo (1)" {
+		t.Errorf("device = %+v", video)
+	}
+	if video.GroupID != audio.GroupID || video.GroupID == devices[0].GroupID {
+		t.Errorf("groups = %q, %q, %q", devices[0].GroupID, video.GroupID, audio.GroupID)
+	}
+	if video.DeviceID == audio.DeviceID || video.DeviceID == devices[0].DeviceID {
+		t.Error("DeviceIDs are not unique")
+	}
+
+	if got := parseDeckLinkOutput("Unknown input format: 'decklink'\n"); got != nil {
+		t.Errorf("without DeckLink support: %+v", got)
+	}
+}
+
+func TestDeckLinkFormatCode(t *testing.T) {
+	for _, tc := range []struct {
+		w, h int
+		fps  float64
+		want string
+	}{
+		{1920, 1080, 60, "Hp60"},
+		{1920, 1080, 59.94, "Hp59"},
+		{1920, 1080, 25, "Hp25"}, // progressive before 1080i50
+		{3840, 2160, 30, "4k30"},
+		{720, 576, 25, "pal "},
+		{640, 480, 30, ""},
+	} {
+		if got := deckLinkFormatCode(tc.w, tc.h, tc.fps); got != tc.want {
+			t.Errorf("deckLinkFormatCode(%d, %d, %g) = %q, want %q", tc.w, tc.h, tc.fps, got, tc.want)
+		}
+	}
+}
+
+func TestBuildDeckLinkInputArgs(t *testing.T) {
+	args := buildVideoInputArgs(VideoCaptureParams{DeviceID: "decklink:DeckLink Mini Recorder", Width: 1920, Height: 1080, FrameRate: 60})
+	want := []string{"-f", "decklink", "-format_code", "Hp60", "-i", "DeckLink Mini Recorder"}
+	if !slices.Equal(args, want) {
+		t.Errorf("video: got %v, want %v", args, want)
+	}
+
+	// A mode in the device name overrides the size and frame rate.
+	args = buildVideoInputArgs(VideoCaptureParams{DeviceID: "decklink:DeckLink Mini Recorder@1080i50", Width: 1280, Height: 720, FrameRate: 30})
+	want = []string{"-f", "decklink", "-format_code", "Hi50", "-i", "DeckLink Mini Recorder"}
+	if !slices.Equal(args, want) {
+		t.Errorf("video with mode: got %v, want %v", args, want)
+	}
+
+	args = buildAudioCaptureArgs(AudioCaptureParams{DeviceID: "decklink:DeckLink Mini Recorder", SampleRate: 44100, Channels: 6})
+	joined := strings.Join(args, " ")
+	if !strings.Contains(joined, "-f decklink -channels 8 -i DeckLink Mini Recorder") || !strings.Contains(joined, "-ar 44100 -ac 6") {
+		t.Errorf("audio: %v", args)
+	}
+	if args := buildDeckLinkAudioInputArgs(AudioCaptureParams{DeviceID: "decklink:X", Channels: 1}); slices.Contains(args, "-channels") {
+		t.Errorf("mono audio: %v, want the default two channels", args)
+	}
+}
+
+func TestParseDeviceModes_DeckLink(t *testing.T) {
+	output := `[decklink @ 0x55d0] Supported formats for 'DeckLink Mini Recorder':
+[decklink @ 0x55d0] 	format_code	description
+[decklink @ 0x55d0] 	ntsc		720x486 at 30000/1001 fps (interlaced, lower field first)
+[decklink @ 0x55d0] 	Hp25		1920x1080 at 25000/1000 fps
+[decklink @ 0x55d0] 	Hi50		1920x1080 at 25000/1000 fps (interlaced, upper field first)
+[decklink @ 0x55d0] 	Hp60		1920x1080 at 60000/1000 fps
+`
+	video, _ := parseDeviceModes(output)
+	want := []VideoMode{
+		{Width: 720, Height: 486, MinFrameRate: 30000.0 / 1001, MaxFrameRate: 30000.0 / 1001},
+		{Width: 1920, Height: 1080, MinFrameRate: 25, MaxFrameRate: 25},
+		{Width: 1920, Height: 1080, MinFrameRate: 60, MaxFrameRate: 60},
+	}
+	if !reflect.DeepEqual(video, want) {
+		t.Errorf("video = %+v, want %+v", video, want)
+	}
+}
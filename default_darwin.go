@@ -0,0 +1,63 @@
+//go:build darwin
+
+package mediadevices
+
+import (
+	"context"
+	"encoding/json"
+	"os/exec"
+)
+
+// markDefaultDevices marks the Core Audio default input and output devices,
+// as reported by system_profiler. AVFoundation has no command-line query for
+// the default camera, so the first video device (index 0) stays the default.
+func markDefaultDevices(ctx context.Context, devices []MediaDeviceInfo) {
+	out, err := exec.CommandContext(ctx, "system_profiler", "-json", "SPAudioDataType").Output()
+	if err != nil {
+		return
+	}
+	info := parseCoreAudioDevices(out)
+	markDefaultDevice(devices, MediaDeviceKindAudioInput, info.defaultInput)
+	markDefaultDevice(devices, MediaDeviceKindAudioOutput, info.defaultOutput)
+}
+
+// coreAudioDevices is what system_profiler reports about audio devices.
+type coreAudioDevices struct {
+	defaultInput  string
+	defaultOutput string
+	// outputs holds the names of the devices with output channels.
+	outputs map[string]bool
+}
+
+// parseCoreAudioDevices parses `system_profiler -json SPAudioDataType`
+// output. Unparsable output yields no devices.
+func parseCoreAudioDevices(out []byte) coreAudioDevices {
+	var doc struct {
+		Audio []struct {
+			Items []struct {
+				Name          string `json:"_name"`
+				DefaultInput  string `json:"coreaudio_default_audio_input_device"`
+				DefaultOutput string `json:"coreaudio_default_audio_output_device"`
+				Output        int    `json:"coreaudio_device_output"`
+			} `json:"_items"`
+		} `json:"SPAudioDataType"`
+	}
+	info := coreAudioDevices{outputs: make(map[string]bool)}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return info
+	}
+	for _, a := range doc.Audio {
+		for _, item := range a.Items {
+			if item.DefaultInput == "spaudio_yes" {
+				info.defaultInput = item.Name
+			}
+			if item.DefaultOutput == "spaudio_yes" {
+				info.defaultOutput = item.Name
+			}
+			if item.Output > 0 {
+				info.outputs[item.Name] = true
+			}
+		}
+	}
+	return info
+}
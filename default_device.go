@@ -0,0 +1,115 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// DefaultVideoInput 返回系统默认的视频输入设备。
+//
+// Windows 没有系统级默认摄像头，使用 DirectShow 枚举顺序中的第一个（与浏览器一致）；
+// macOS 和 Linux 同样以第一个摄像头为默认。
+// 没有标记为默认的设备时返回第一个视频输入设备。
+func DefaultVideoInput() (MediaDeviceInfo, error) {
+	d, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindVideoInput)
+	if err != nil {
+		return MediaDeviceInfo{}, err
+	}
+	return defaultMediaDevices.redactDevices([]MediaDeviceInfo{d})[0], nil
+}
+
+// DefaultAudioInput 返回系统默认的音频输入设备。
+//
+// Windows 通过 Core Audio（MMDeviceEnumerator）查询默认录音设备，
+// macOS 通过 system_profiler 查询 Core Audio 默认输入设备，Linux 使用 ALSA 的第一张声卡。
+// 没有标记为默认的设备时返回第一个音频输入设备。
+func DefaultAudioInput() (MediaDeviceInfo, error) {
+	d, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindAudioInput)
+	if err != nil {
+		return MediaDeviceInfo{}, err
+	}
+	return defaultMediaDevices.redactDevices([]MediaDeviceInfo{d})[0], nil
+}
+
+// DefaultAudioOutput 返回系统默认的音频输出设备。
+//
+// Windows 通过 Core Audio 查询默认播放设备，macOS 通过 system_profiler 查询，
+// Linux 使用 PulseAudio/PipeWire 的默认 sink，没有声音服务器时使用 ALSA 的第一张声卡。
+// 没有标记为默认的设备时返回第一个音频输出设备。
+func DefaultAudioOutput() (MediaDeviceInfo, error) {
+	d, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindAudioOutput)
+	if err != nil {
+		return MediaDeviceInfo{}, err
+	}
+	return defaultMediaDevices.redactDevices([]MediaDeviceInfo{d})[0], nil
+}
+
+// defaultDevice 返回指定类型的默认设备（未经隐私处理）。
+func (m *MediaDevices) defaultDevice(ctx context.Context, kind MediaDeviceKind) (MediaDeviceInfo, error) {
+	devices, err := m.devicesByKind(ctx, kind)
+	if err != nil {
+		return MediaDeviceInfo{}, err
+	}
+	// 屏幕捕获来源（Config.EnumerateDisplays）不作为默认摄像头，回环设备不作为默认麦克风
+	devices = slices.DeleteFunc(slices.Clone(devices), func(d MediaDeviceInfo) bool {
+		return isDisplayDevice(d) || d.Loopback
+	})
+	d, ok := pickDefaultDevice(devices)
+	if !ok {
+		return MediaDeviceInfo{}, fmt.Errorf("%w: no %s devices available", ErrNotFound, kind)
+	}
+	return d, nil
+}
+
+// pickDefaultDevice 返回标记为默认的设备，没有则返回第一个。
+func pickDefaultDevice(devices []MediaDeviceInfo) (MediaDeviceInfo, bool) {
+	for _, d := range devices {
+		if d.IsDefault {
+			return d, true
+		}
+	}
+	if len(devices) == 0 {
+		return MediaDeviceInfo{}, false
+	}
+	return devices[0], true
+}
+
+// markDefaultDevice 将 kind 类型中与系统默认设备名 name 匹配的设备标记为默认，
+// 同类型的其他设备取消默认标记。没有匹配的设备时不做修改并返回 false。
+func markDefaultDevice(devices []MediaDeviceInfo, kind MediaDeviceKind, name string) bool {
+	match := matchDeviceName(devices, kind, name)
+	if match < 0 {
+		return false
+	}
+	for i := range devices {
+		if devices[i].Kind == kind {
+			devices[i].IsDefault = i == match
+		}
+	}
+	return true
+}
+
+// matchDeviceName 返回 kind 类型中与系统设备名 name 对应的设备下标，没有时返回 -1。
+//
+// 完全匹配优先；否则接受 name 以设备名开头的最长匹配，
+// 因为 DirectShow 的音频设备名截断为 31 个字符（WaveIn 名称长度限制）。
+func matchDeviceName(devices []MediaDeviceInfo, kind MediaDeviceKind, name string) int {
+	if name == "" {
+		return -1
+	}
+	match := -1
+	for i, d := range devices {
+		if d.Kind != kind {
+			continue
+		}
+		if d.Label == name {
+			return i
+		}
+		if d.Label != "" && strings.HasPrefix(name, d.Label) && (match < 0 || len(d.Label) > len(devices[match].Label)) {
+			match = i
+		}
+	}
+	return match
+}
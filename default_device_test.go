@@ -0,0 +1,67 @@
+package mediadevices
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMarkDefaultDevice(t *testing.T) {
+	devices := []MediaDeviceInfo{
+		{Kind: MediaDeviceKindVideoInput, Label: "Microphone (USB Audio)", IsDefault: true},
+		{Kind: MediaDeviceKindAudioInput, Label: "Microphone (USB Audio)", IsDefault: true},
+		{Kind: MediaDeviceKindAudioInput, Label: "Microphone (Realtek High Defini"},
+		{Kind: MediaDeviceKindAudioInput, Label: "Microphone"},
+	}
+
+	// DirectShow truncates audio names to 31 characters.
+	if !markDefaultDevice(devices, MediaDeviceKindAudioInput, "Microphone (Realtek High Definition Audio)") {
+		t.Fatal("truncated name not matched")
+	}
+	for i, want := range []bool{true, false, true, false} {
+		if devices[i].IsDefault != want {
+			t.Errorf("device %d IsDefault = %v, want %v", i, devices[i].IsDefault, want)
+		}
+	}
+
+	if markDefaultDevice(devices, MediaDeviceKindAudioInput, "Headset") {
+		t.Error("unknown name matched")
+	}
+	if !devices[2].IsDefault {
+		t.Error("failed match changed the default")
+	}
+}
+
+func TestPickDefaultDevice(t *testing.T) {
+	if _, ok := pickDefaultDevice(nil); ok {
+		t.Error("default picked from no devices")
+	}
+	d, _ := pickDefaultDevice([]MediaDeviceInfo{{DeviceID: "a"}, {DeviceID: "b", IsDefault: true}})
+	if d.DeviceID != "b" {
+		t.Errorf("picked %q, want the default device", d.DeviceID)
+	}
+	d, _ = pickDefaultDevice([]MediaDeviceInfo{{DeviceID: "a"}, {DeviceID: "b"}})
+	if d.DeviceID != "a" {
+		t.Errorf("picked %q, want the first device", d.DeviceID)
+	}
+}
+
+func TestDefaultDevice_SkipsLoopback(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{
+			{DeviceID: "monitor", Kind: MediaDeviceKindAudioInput, IsDefault: true, Loopback: true},
+			{DeviceID: "mic", Kind: MediaDeviceKindAudioInput},
+		}, nil
+	}
+	SetConfig(cfg)
+
+	if d, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindAudioInput); err != nil || d.DeviceID != "mic" {
+		t.Errorf("default = %q, %v; want the microphone", d.DeviceID, err)
+	}
+	loopback, err := LoopbackAudioDevices()
+	if err != nil || len(loopback) != 1 || loopback[0].DeviceID != "monitor" {
+		t.Errorf("LoopbackAudioDevices = %+v, %v", loopback, err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build windows
+
+package mediadevices
+
+// markDefaultDevices marks the default microphone reported by Core Audio.
+// Windows has no default camera, so the first DirectShow video device (the
+// system enumeration order, as browsers use) is marked instead.
+func markDefaultDevices(devices []MediaDeviceInfo) {
+	for i := range devices {
+		if devices[i].Kind == MediaDeviceKindVideoInput {
+			devices[i].IsDefault = true
+			break
+		}
+	}
+	if name, err := defaultCaptureEndpointName(); err == nil {
+		markDefaultDevice(devices, MediaDeviceKindAudioInput, name)
+	}
+}
@@ -1,11 +1,79 @@
 package mediadevices
 
+import (
+	"context"
+	"fmt"
+)
+
 // DeviceKind indicates whether a device captures video or audio.
+//
+// Deprecated: Devices are described by MediaDeviceInfo, whose Kind is a
+// MediaDeviceKind. Use MediaDeviceKindVideoInput and MediaDeviceKindAudioInput.
 type DeviceKind int
 
 const (
 	// VideoDevice represents a video capture device (camera, screen capture, etc.)
+	//
+	// Deprecated: Use MediaDeviceKindVideoInput.
 	VideoDevice DeviceKind = iota
 	// AudioDevice represents an audio capture device (microphone, line-in, etc.)
+	//
+	// Deprecated: Use MediaDeviceKindAudioInput.
 	AudioDevice
 )
+
+// MediaKind returns the MediaDeviceKind corresponding to k.
+func (k DeviceKind) MediaKind() MediaDeviceKind {
+	if k == AudioDevice {
+		return MediaDeviceKindAudioInput
+	}
+	return MediaDeviceKindVideoInput
+}
+
+// ffmpegDeviceName returns the name FFmpeg opens d by: the DirectShow
+// alternative name if there is one, since friendly names need not be
+// unique, else DeviceName, else DeviceID.
+func ffmpegDeviceName(d MediaDeviceInfo) string {
+	switch {
+	case d.AlternativeName != "":
+		return d.AlternativeName
+	case d.DeviceName != "":
+		return d.DeviceName
+	}
+	return d.DeviceID
+}
+
+// resolveCaptureDevice returns the FFmpeg device name to open for a reader
+// config: that of device if it is set, else that of the enumerated device
+// of the given kind whose DeviceID is deviceID, else that of the default
+// device of that kind.
+func (m *MediaDevices) resolveCaptureDevice(ctx context.Context, kind MediaDeviceKind, device MediaDeviceInfo, deviceID string) (string, error) {
+	if device.DeviceName != "" || device.AlternativeName != "" || device.DeviceID != "" {
+		if device.Kind != "" && device.Kind != kind {
+			return "", fmt.Errorf("device %s is a %s device, want %s", device.DeviceID, device.Kind, kind)
+		}
+		if device.DeviceName != "" || device.AlternativeName != "" {
+			return ffmpegDeviceName(device), nil
+		}
+		// A redacted or hand-built value: look the name up by ID.
+		deviceID = device.DeviceID
+	}
+
+	var d MediaDeviceInfo
+	if deviceID == "" {
+		var err error
+		if d, err = m.defaultDevice(ctx, kind); err != nil {
+			return "", err
+		}
+	} else {
+		devices, err := m.devicesByKind(ctx, kind)
+		if err != nil {
+			return "", err
+		}
+		var found bool
+		if d, found = m.findDevice(devices, deviceID); !found {
+			return "", fmt.Errorf("%s %w: %s", kind, ErrNotFound, deviceID)
+		}
+	}
+	return ffmpegDeviceName(d), nil
+}
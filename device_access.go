@@ -0,0 +1,50 @@
+package mediadevices
+
+import (
+	"fmt"
+	"strings"
+)
+
+// checkDeviceAccess probes, before FFmpeg is started, whether the capture
+// device name of the given kind can be opened. A device held by another
+// process or refused by the OS yields a *CaptureError of CauseDeviceBusy or
+// CausePermissionDenied that wraps ErrDeviceBusy or ErrPermissionDenied,
+// instead of a failed start with only FFmpeg's stderr to go on.
+//
+// Devices that are not opened through the platform's capture API (DeckLink,
+// virtual, synthetic and display devices) are not probed. When the probe
+// cannot tell, it returns nil and a failure is classified from FFmpeg's
+// stderr as before.
+func checkDeviceAccess(kind MediaDeviceKind, name string) error {
+	if name == "" || isDeckLinkDevice(name) || isVirtualDevice(name) || isSyntheticDevice(name) ||
+		strings.HasPrefix(name, displayDevicePrefix) {
+		return nil
+	}
+	return probeDeviceAccess(kind, name)
+}
+
+// describeDeviceHolder replaces the hint of ce, if it reports the device
+// name of the given kind busy, with one naming the process that holds it,
+// where the platform can tell. It returns ce.
+func describeDeviceHolder(ce *CaptureError, kind MediaDeviceKind, name string) *CaptureError {
+	if ce.Cause == CauseDeviceBusy {
+		if hint := deviceHolderHint(kind, name); hint != "" {
+			ce.Hint = hint
+		}
+	}
+	return ce
+}
+
+// deviceAccessError returns the error of a failed access probe of the
+// device name.
+func deviceAccessError(cause ErrorCause, name, hint string) *CaptureError {
+	sentinel := ErrPermissionDenied
+	if cause == CauseDeviceBusy {
+		sentinel = ErrDeviceBusy
+	}
+	return &CaptureError{
+		Cause: cause,
+		Hint:  hint,
+		Err:   fmt.Errorf("ffmpeg: open %s: %w", name, sentinel),
+	}
+}
@@ -0,0 +1,166 @@
+package mediadevices
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+// deviceChangeInterval 是设备变化监听重新发现设备的间隔。
+var deviceChangeInterval = 2 * time.Second
+
+// DeviceChangeEvent 描述一次设备列表变化，对应 MDN 的 devicechange 事件。
+// 浏览器的事件不携带内容，这里附带变化前后的差异以便直接使用。
+type DeviceChangeEvent struct {
+	// Devices 是变化后的完整设备列表，与 EnumerateDevices 的返回值相同。
+	Devices []MediaDeviceInfo
+	// Added 是新连接的设备。
+	Added []MediaDeviceInfo
+	// Removed 是已移除的设备。
+	Removed []MediaDeviceInfo
+}
+
+// deviceWatcher 在至少有一个订阅者时周期性地重新发现设备。
+type deviceWatcher struct {
+	mu     sync.Mutex
+	subs   map[int]func(DeviceChangeEvent)
+	nextID int
+	stop   chan struct{}
+}
+
+// OnDeviceChange 订阅设备连接与移除，对应 MDN 的
+// navigator.mediaDevices.ondevicechange。
+//
+// 存在订阅者期间，每隔 2 秒重新发现一次设备并更新 EnumerateDevices 的缓存，
+// 因此启动后插入的摄像头也能被枚举和 GetUserMedia 使用。设备集合（按类型和
+// DeviceID 比较）变化时依次调用各订阅者的 fn；fn 在监听 goroutine 中执行，
+// 不应长时间阻塞。某个发现后端失败或超时时，该次只报告新增设备，以免误报移除。
+//
+// 返回的 cancel 取消订阅，可重复调用；最后一个订阅者取消后停止监听。
+func OnDeviceChange(fn func(DeviceChangeEvent)) (cancel func()) {
+	return defaultMediaDevices.OnDeviceChange(fn)
+}
+
+// OnDeviceChange 与包级函数 OnDeviceChange 相同，但监听 m 的设备并更新 m 的缓存。
+func (m *MediaDevices) OnDeviceChange(fn func(DeviceChangeEvent)) (cancel func()) {
+	w := &m.watch
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.subs == nil {
+		w.subs = make(map[int]func(DeviceChangeEvent))
+	}
+	id := w.nextID
+	w.nextID++
+	w.subs[id] = fn
+	if w.stop == nil {
+		w.stop = make(chan struct{})
+		go w.run(m, w.stop)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			w.mu.Lock()
+			defer w.mu.Unlock()
+			delete(w.subs, id)
+			if len(w.subs) == 0 && w.stop != nil {
+				close(w.stop)
+				w.stop = nil
+			}
+		})
+	}
+}
+
+// run 是监听 m 的设备的 goroutine，直到 stop 关闭。
+func (w *deviceWatcher) run(m *MediaDevices, stop chan struct{}) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		<-stop
+		cancel()
+	}()
+
+	// 部分后端失败时的结果也可作为基准，之后只可能多报新增设备。
+	last, _ := m.enumerateDevicesRaw(ctx)
+
+	ticker := time.NewTicker(deviceChangeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		devices, err := m.refreshDevices(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		added, removed := diffDevices(last, devices)
+		if err != nil {
+			// 失败的后端缺少的设备不视为移除，仍保留在基准中。
+			removed = nil
+			devices = slices.Concat(devices, missingDevices(last, devices))
+		}
+		last = devices
+		if len(added) == 0 && len(removed) == 0 {
+			continue
+		}
+		ev := DeviceChangeEvent{
+			Devices: m.redactDevices(devices),
+			Added:   m.redactDevices(added),
+			Removed: m.redactDevices(removed),
+		}
+		for _, fn := range w.subscribers(stop) {
+			fn(ev)
+		}
+	}
+}
+
+// subscribers 返回当前订阅者；监听已被 stop 停止时返回 nil。
+func (w *deviceWatcher) subscribers(stop chan struct{}) []func(DeviceChangeEvent) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != stop {
+		return nil
+	}
+	fns := make([]func(DeviceChangeEvent), 0, len(w.subs))
+	for id := 0; id < w.nextID; id++ {
+		if fn, ok := w.subs[id]; ok {
+			fns = append(fns, fn)
+		}
+	}
+	return fns
+}
+
+// missingDevices 返回 before 中不在 after 里的设备。
+func missingDevices(before, after []MediaDeviceInfo) []MediaDeviceInfo {
+	_, removed := diffDevices(before, after)
+	return removed
+}
+
+// diffDevices 按类型和 DeviceID 比较两个设备列表。
+func diffDevices(before, after []MediaDeviceInfo) (added, removed []MediaDeviceInfo) {
+	type key struct {
+		kind MediaDeviceKind
+		id   string
+	}
+	seen := make(map[key]bool, len(before))
+	for _, d := range before {
+		seen[key{d.Kind, d.DeviceID}] = true
+	}
+	for _, d := range after {
+		k := key{d.Kind, d.DeviceID}
+		if seen[k] {
+			delete(seen, k)
+			continue
+		}
+		added = append(added, d)
+	}
+	for _, d := range before {
+		if seen[key{d.Kind, d.DeviceID}] {
+			removed = append(removed, d)
+		}
+	}
+	return added, removed
+}
@@ -0,0 +1,100 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOnDeviceChange(t *testing.T) {
+	savedInterval := deviceChangeInterval
+	deviceChangeInterval = 10 * time.Millisecond
+	defer func() { deviceChangeInterval = savedInterval }()
+
+	cam := MediaDeviceInfo{DeviceID: "cam-1", Kind: MediaDeviceKindVideoInput, Label: "Cam"}
+	mic := MediaDeviceInfo{DeviceID: "mic-1", Kind: MediaDeviceKindAudioInput, Label: "Mic"}
+
+	var mu sync.Mutex
+	current := []MediaDeviceInfo{mic}
+	var failing error
+	set := func(devices []MediaDeviceInfo, err error) {
+		mu.Lock()
+		current, failing = devices, err
+		mu.Unlock()
+	}
+
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]MediaDeviceInfo(nil), current...), failing
+	}
+	SetConfig(cfg)
+
+	events := make(chan DeviceChangeEvent, 10)
+	cancel := OnDeviceChange(func(ev DeviceChangeEvent) { events <- ev })
+	defer cancel()
+
+	next := func() DeviceChangeEvent {
+		t.Helper()
+		select {
+		case ev := <-events:
+			return ev
+		case <-time.After(2 * time.Second):
+			t.Fatal("no device change event")
+			return DeviceChangeEvent{}
+		}
+	}
+
+	// Let the watcher take its baseline first.
+	time.Sleep(30 * time.Millisecond)
+	set([]MediaDeviceInfo{mic, cam}, nil)
+	ev := next()
+	if len(ev.Added) != 1 || ev.Added[0].DeviceID != "cam-1" || len(ev.Removed) != 0 || len(ev.Devices) != 2 {
+		t.Fatalf("plug event = %+v", ev)
+	}
+
+	// A failing backend must not report its devices as removed.
+	set([]MediaDeviceInfo{cam}, errors.New("alsa: timeout"))
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case ev := <-events:
+		t.Fatalf("unexpected event on partial discovery: %+v", ev)
+	default:
+	}
+
+	set([]MediaDeviceInfo{mic}, nil)
+	ev = next()
+	if len(ev.Removed) != 1 || ev.Removed[0].DeviceID != "cam-1" || len(ev.Added) != 0 {
+		t.Fatalf("unplug event = %+v", ev)
+	}
+
+	cancel()
+	cancel()
+	set([]MediaDeviceInfo{mic, cam}, nil)
+	time.Sleep(50 * time.Millisecond)
+	select {
+	case ev := <-events:
+		t.Fatalf("event after cancel: %+v", ev)
+	default:
+	}
+}
+
+func TestDiffDevices(t *testing.T) {
+	a := MediaDeviceInfo{DeviceID: "a", Kind: MediaDeviceKindVideoInput}
+	b := MediaDeviceInfo{DeviceID: "b", Kind: MediaDeviceKindVideoInput}
+	// Same ID, different kind: a separate device.
+	bMic := MediaDeviceInfo{DeviceID: "b", Kind: MediaDeviceKindAudioInput}
+
+	added, removed := diffDevices([]MediaDeviceInfo{a, b}, []MediaDeviceInfo{b, bMic})
+	if len(added) != 1 || added[0] != bMic {
+		t.Errorf("added = %+v", added)
+	}
+	if len(removed) != 1 || removed[0] != a {
+		t.Errorf("removed = %+v", removed)
+	}
+}
@@ -0,0 +1,152 @@
+package mediadevices
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// DeviceFilter 是设备的白名单、黑名单和别名，由 Config.DeviceFilter 设置，
+// 可用 LoadDeviceFilter 从 JSON 文件载入、用 Save 保存。
+//
+// 模式使用 path.Match 的通配符语法（如 "*Virtual*"），不区分大小写，
+// 与设备的 Label、DeviceID 或 DeviceName 任一匹配即可；模式也可以是 Aliases 中的别名，
+// 匹配其指向的设备。
+type DeviceFilter struct {
+	// Include 非空时只保留匹配其中任一模式的设备。
+	Include []string `json:"include,omitempty"`
+	// Exclude 中任一模式匹配的设备被去除，优先于 Include。
+	// 如 "OBS Virtual Camera" 可在全局排除虚拟摄像头。
+	Exclude []string `json:"exclude,omitempty"`
+	// Aliases 将易记的名称映射到 DeviceID，如 "front-door" → "<UUID>"。
+	// DeviceID 在重启和重新插拔后保持不变，因此别名可以长期保存。
+	// 约束、读取器配置、SwitchDevice 和 GetDeviceCapabilities 中
+	// 接受 DeviceID 的地方都可以使用别名。
+	Aliases map[string]string `json:"aliases,omitempty"`
+}
+
+// LoadDeviceFilter 从 JSON 文件 name 载入设备过滤配置，文件内容形如
+// {"include": [...], "exclude": [...], "aliases": {"front-door": "<DeviceID>"}}。
+// 模式无效时返回错误。
+func LoadDeviceFilter(name string) (DeviceFilter, error) {
+	var f DeviceFilter
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return f, fmt.Errorf("device filter: %w", err)
+	}
+	if err := json.Unmarshal(data, &f); err != nil {
+		return f, fmt.Errorf("device filter %s: %w", name, err)
+	}
+	if err := f.Validate(); err != nil {
+		return f, fmt.Errorf("device filter %s: %w", name, err)
+	}
+	return f, nil
+}
+
+// Save 将设备过滤配置写入 JSON 文件 name（必要时创建所在目录），格式见 LoadDeviceFilter。
+// 文件先写入临时文件再重命名，中途崩溃不会留下损坏的文件。
+func (f DeviceFilter) Save(name string) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(name, append(data, '\n')); err != nil {
+		return fmt.Errorf("device filter: %w", err)
+	}
+	return nil
+}
+
+// Validate 检查模式的语法和别名。无效的模式在过滤时不匹配任何设备。
+func (f DeviceFilter) Validate() error {
+	for _, p := range append(append([]string(nil), f.Include...), f.Exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	for alias, id := range f.Aliases {
+		if alias == "" || id == "" {
+			return fmt.Errorf("invalid alias %q for device %q", alias, id)
+		}
+	}
+	return nil
+}
+
+// apply 返回经过 Include 和 Exclude 过滤的设备。没有模式时原样返回 devices，
+// 否则不修改 devices（可能是缓存）的底层数组。
+func (f DeviceFilter) apply(devices []MediaDeviceInfo) []MediaDeviceInfo {
+	if len(f.Include) == 0 && len(f.Exclude) == 0 {
+		return devices
+	}
+	var result []MediaDeviceInfo
+	for _, d := range devices {
+		if len(f.Include) > 0 && !f.matchAny(f.Include, d) || f.matchAny(f.Exclude, d) {
+			continue
+		}
+		result = append(result, d)
+	}
+	return result
+}
+
+// matchAny 判断设备 d 是否与 patterns 中任一模式匹配。
+func (f DeviceFilter) matchAny(patterns []string, d MediaDeviceInfo) bool {
+	for _, p := range patterns {
+		if id, ok := f.Aliases[p]; ok {
+			if d.DeviceID == id {
+				return true
+			}
+			continue
+		}
+		p = strings.ToLower(p)
+		for _, s := range []string{d.Label, d.DeviceID, d.DeviceName} {
+			if ok, _ := path.Match(p, strings.ToLower(s)); ok && s != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// resolve 返回别名 id 指向的 DeviceID；id 不是别名时原样返回。
+func (f DeviceFilter) resolve(id string) string {
+	if target, ok := f.Aliases[id]; ok {
+		return target
+	}
+	return id
+}
+
+// findDevice 在 devices 中查找 DeviceID 为 id 的设备，id 可以是 m 的 Config.DeviceFilter 中的别名。
+func (m *MediaDevices) findDevice(devices []MediaDeviceInfo, id string) (MediaDeviceInfo, bool) {
+	id = m.Config().DeviceFilter.resolve(id)
+	for _, d := range devices {
+		if d.DeviceID == id {
+			return d, true
+		}
+	}
+	return MediaDeviceInfo{}, false
+}
+
+// writeFileAtomic 将 data 写入文件 name（必要时创建所在目录）：先写入同目录的临时文件再重命名。
+func writeFileAtomic(name string, data []byte) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	_, werr := tmp.Write(data)
+	if cerr := tmp.Close(); werr == nil {
+		werr = cerr
+	}
+	if werr == nil {
+		werr = os.Rename(tmp.Name(), name)
+	}
+	if werr != nil {
+		os.Remove(tmp.Name())
+	}
+	return werr
+}
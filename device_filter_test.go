@@ -0,0 +1,112 @@
+package mediadevices
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestDeviceFilter(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{
+			{DeviceID: "cam-1", DeviceName: "/dev/video0", Label: "USB Camera", Kind: MediaDeviceKindVideoInput},
+			{DeviceID: "cam-2", DeviceName: "/dev/video2", Label: "OBS Virtual Camera", Kind: MediaDeviceKindVideoInput},
+			{DeviceID: "cam-3", DeviceName: "/dev/video4", Label: "Door Camera", Kind: MediaDeviceKindVideoInput},
+			{DeviceID: "mic-1", DeviceName: "hw:0,0", Label: "USB Microphone", Kind: MediaDeviceKindAudioInput},
+		}, nil
+	}
+
+	ids := func(devices []MediaDeviceInfo) []string {
+		var ids []string
+		for _, d := range devices {
+			ids = append(ids, d.DeviceID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+	for _, tc := range []struct {
+		name   string
+		filter DeviceFilter
+		want   []string
+	}{
+		{"no filter", DeviceFilter{}, []string{"cam-1", "cam-2", "cam-3", "mic-1"}},
+		{"exclude label", DeviceFilter{Exclude: []string{"obs virtual camera"}}, []string{"cam-1", "cam-3", "mic-1"}},
+		{"include wildcard", DeviceFilter{Include: []string{"USB *"}}, []string{"cam-1", "mic-1"}},
+		{"exclude wins", DeviceFilter{Include: []string{"*Camera"}, Exclude: []string{"*Virtual*"}}, []string{"cam-1", "cam-3"}},
+		{"include name", DeviceFilter{Include: []string{"/dev/video[02]"}}, []string{"cam-1", "cam-2"}},
+		{"include alias", DeviceFilter{Include: []string{"front-door"}, Aliases: map[string]string{"front-door": "cam-3"}}, []string{"cam-3"}},
+	} {
+		cfg.DeviceFilter = tc.filter
+		SetConfig(cfg)
+		devices, err := EnumerateDevices()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		if got := ids(devices); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: devices = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	// Aliases select a device wherever a DeviceID is accepted.
+	cfg.DeviceFilter = DeviceFilter{
+		Exclude: []string{"*Virtual*"},
+		Aliases: map[string]string{"front-door": "cam-3", "obs": "cam-2"},
+	}
+	SetConfig(cfg)
+	ctx := context.Background()
+	if got, err := defaultMediaDevices.resolveCaptureDevice(ctx, MediaDeviceKindVideoInput, MediaDeviceInfo{}, "front-door"); err != nil || got != "/dev/video4" {
+		t.Errorf("alias: got %q, %v; want /dev/video4", got, err)
+	}
+	if got, err := defaultMediaDevices.resolveCaptureDevice(ctx, MediaDeviceKindVideoInput, MediaDeviceInfo{}, "obs"); err == nil {
+		t.Errorf("excluded device selected through its alias: %q", got)
+	}
+}
+
+func TestDeviceFilterValidate(t *testing.T) {
+	for _, f := range []DeviceFilter{
+		{Include: []string{"["}},
+		{Exclude: []string{"cam\\"}},
+		{Aliases: map[string]string{"": "cam-1"}},
+		{Aliases: map[string]string{"front-door": ""}},
+	} {
+		if err := f.Validate(); err == nil {
+			t.Errorf("%+v accepted", f)
+		}
+	}
+	if err := (DeviceFilter{Include: []string{"*USB*"}, Aliases: map[string]string{"a": "b"}}).Validate(); err != nil {
+		t.Errorf("valid filter rejected: %v", err)
+	}
+}
+
+func TestDeviceFilterSaveLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "filter.json")
+	want := DeviceFilter{
+		Exclude: []string{"OBS Virtual Camera"},
+		Aliases: map[string]string{"front-door": "cam-3"},
+	}
+	if err := want.Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	got, err := LoadDeviceFilter(path)
+	if err != nil {
+		t.Fatalf("LoadDeviceFilter: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("loaded %+v, want %+v", got, want)
+	}
+
+	if err := (DeviceFilter{Include: []string{"["}}).Save(path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDeviceFilter(path); err == nil {
+		t.Error("invalid pattern loaded")
+	}
+	if _, err := LoadDeviceFilter(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file loaded")
+	}
+}
@@ -0,0 +1,59 @@
+package mediadevices
+
+import (
+	"crypto/sha256"
+	"fmt"
+
+	"github.com/denisbrodbeck/machineid"
+	"github.com/google/uuid"
+)
+
+// getMachineID returns the unique machine ID for this device.
+func getMachineID() string {
+	id, err := machineid.ID()
+	if err != nil {
+		// Fallback to a constant if machine ID cannot be obtained
+		return "unknown"
+	}
+	return id
+}
+
+// machineID is cached at package init
+var cachedMachineID = getMachineID()
+
+// generateDeviceUUID generates a deterministic UUID from machine ID, device name and kind.
+// This ensures the same device on the same machine always gets the same UUID,
+// while devices on different machines get different UUIDs even with identical names.
+func generateDeviceUUID(name string, kind MediaDeviceKind) uuid.UUID {
+	// Include machine ID, device name, and kind in the hash
+	input := fmt.Sprintf("%s:%s:%s", cachedMachineID, name, kind)
+	hash := sha256.Sum256([]byte(input))
+	// Use first 16 bytes of SHA256 hash to create UUID v5 style
+	return uuid.UUID{
+		hash[0], hash[1], hash[2], hash[3],
+		hash[4], hash[5], hash[6], hash[7],
+		hash[8], hash[9], hash[10], hash[11],
+		hash[12], hash[13], hash[14], hash[15],
+	}
+}
+
+// deviceIDs generates the DeviceIDs of one discovery run from keys that
+// identify a device across reboots (a device name or a udev path, never an
+// enumeration index). Devices with the same key, such as two identical
+// webcams, are numbered in discovery order.
+type deviceIDs struct {
+	seen map[string]int
+}
+
+// id returns the DeviceID of the next device of kind with the given key.
+func (g *deviceIDs) id(key string, kind MediaDeviceKind) string {
+	if g.seen == nil {
+		g.seen = make(map[string]int)
+	}
+	deviceKey := fmt.Sprintf("%s:%s", key, kind)
+	g.seen[deviceKey]++
+	if n := g.seen[deviceKey]; n > 1 {
+		deviceKey = fmt.Sprintf("%s:%d", deviceKey, n)
+	}
+	return generateDeviceUUID(deviceKey, kind).String()
+}
@@ -0,0 +1,17 @@
+package mediadevices
+
+import "testing"
+
+func TestDeviceIDs(t *testing.T) {
+	var a, b deviceIDs
+	first := a.id("v4l2:by-id/usb-046d_0825-video-index0", MediaDeviceKindVideoInput)
+	if again := b.id("v4l2:by-id/usb-046d_0825-video-index0", MediaDeviceKindVideoInput); again != first {
+		t.Errorf("ID changed between runs: %s, %s", first, again)
+	}
+	if second := a.id("v4l2:by-id/usb-046d_0825-video-index0", MediaDeviceKindVideoInput); second == first {
+		t.Error("identical devices got the same ID")
+	}
+	if audio := b.id("v4l2:by-id/usb-046d_0825-video-index0", MediaDeviceKindAudioInput); audio == first {
+		t.Error("kinds share an ID")
+	}
+}
@@ -0,0 +1,56 @@
+package mediadevices
+
+import (
+	"cmp"
+	"slices"
+)
+
+// deviceKindOrder 是 EnumerateDevices 结果中各设备类型的先后顺序。
+var deviceKindOrder = map[MediaDeviceKind]int{
+	MediaDeviceKindVideoInput:  0,
+	MediaDeviceKindAudioInput:  1,
+	MediaDeviceKindAudioOutput: 2,
+}
+
+// compareDevices 按类型（视频输入、音频输入、音频输出）、标签、设备 ID 的顺序比较两个设备。
+func compareDevices(a, b MediaDeviceInfo) int {
+	return cmp.Or(
+		cmp.Compare(kindRank(a.Kind), kindRank(b.Kind)),
+		cmp.Compare(a.Label, b.Label),
+		cmp.Compare(a.DeviceID, b.DeviceID),
+	)
+}
+
+// kindRank 返回设备类型的排序位置，未知类型排在最后。
+func kindRank(kind MediaDeviceKind) int {
+	if r, ok := deviceKindOrder[kind]; ok {
+		return r
+	}
+	return len(deviceKindOrder)
+}
+
+// normalizeDevices 对发现的设备去重并稳定排序，使 devices[0] 在多次运行间可预测。
+// 类型和设备 ID 相同的条目（例如 FFmpeg 在多次发现中重复列出的同一设备）只保留第一个，
+// 任一重复条目标记为默认时保留默认标记。没有报告朝向的摄像头按标签推测朝向。
+func normalizeDevices(devices []MediaDeviceInfo) []MediaDeviceInfo {
+	type key struct {
+		kind MediaDeviceKind
+		id   string
+	}
+	seen := make(map[key]int, len(devices))
+	result := make([]MediaDeviceInfo, 0, len(devices))
+	for _, d := range devices {
+		k := key{d.Kind, d.DeviceID}
+		if i, ok := seen[k]; ok {
+			result[i].IsDefault = result[i].IsDefault || d.IsDefault
+			continue
+		}
+		seen[k] = len(result)
+		if d.Kind == MediaDeviceKindVideoInput && d.FacingMode == "" {
+			d.FacingMode = facingModeFromLabel(d.Label)
+		}
+		result = append(result, d)
+	}
+	slices.SortStableFunc(result, compareDevices)
+	return result
+}
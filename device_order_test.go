@@ -0,0 +1,38 @@
+package mediadevices
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeDevices(t *testing.T) {
+	in := []MediaDeviceInfo{
+		{DeviceID: "hw:1", Kind: MediaDeviceKindAudioInput, Label: "USB Audio"},
+		{DeviceID: "/dev/video2", Kind: MediaDeviceKindVideoInput, Label: "video2"},
+		{DeviceID: "hw:0", Kind: MediaDeviceKindAudioInput, Label: "HDA Intel PCH"},
+		{DeviceID: "b", Kind: MediaDeviceKindVideoInput, Label: "Camera"},
+		{DeviceID: "a", Kind: MediaDeviceKindVideoInput, Label: "Camera"},
+		{DeviceID: "hw:0", Kind: MediaDeviceKindAudioInput, Label: "HDA Intel PCH", IsDefault: true},
+		{DeviceID: "out", Kind: MediaDeviceKindAudioOutput, Label: "Speakers"},
+	}
+	got := normalizeDevices(in)
+
+	var ids []string
+	for _, d := range got {
+		ids = append(ids, d.DeviceID)
+	}
+	want := []string{"a", "b", "/dev/video2", "hw:0", "hw:1", "out"}
+	if !slices.Equal(ids, want) {
+		t.Errorf("order = %v, want %v", ids, want)
+	}
+	if !got[3].IsDefault {
+		t.Error("default flag of duplicate entry lost")
+	}
+
+	// Input order must not matter.
+	rev := slices.Clone(in)
+	slices.Reverse(rev)
+	if again := normalizeDevices(rev); !slices.Equal(again, got) {
+		t.Errorf("reversed input gave %v, want %v", again, got)
+	}
+}
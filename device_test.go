@@ -0,0 +1,47 @@
+package mediadevices
+
+import (
+	"context"
+	"testing"
+)
+
+func TestResolveCaptureDevice(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{
+			{DeviceID: "cam-1", DeviceName: "/dev/video0", Kind: MediaDeviceKindVideoInput},
+			{DeviceID: "cam-2", DeviceName: "/dev/video2", Kind: MediaDeviceKindVideoInput, IsDefault: true},
+			{DeviceID: "mic-1", DeviceName: "hw:0,0", Kind: MediaDeviceKindAudioInput},
+		}, nil
+	}
+	SetConfig(cfg)
+
+	ctx := context.Background()
+	for _, tc := range []struct {
+		name     string
+		kind     MediaDeviceKind
+		device   MediaDeviceInfo
+		deviceID string
+		want     string
+	}{
+		{"device value", MediaDeviceKindVideoInput, MediaDeviceInfo{DeviceID: "x", DeviceName: "/dev/video5"}, "", "/dev/video5"},
+		{"alternative name", MediaDeviceKindVideoInput, MediaDeviceInfo{DeviceID: "x", DeviceName: "USB Camera", AlternativeName: "@device_pnp_x"}, "", "@device_pnp_x"},
+		{"redacted device value", MediaDeviceKindVideoInput, MediaDeviceInfo{DeviceID: "cam-1", Kind: MediaDeviceKindVideoInput}, "", "/dev/video0"},
+		{"device ID", MediaDeviceKindAudioInput, MediaDeviceInfo{}, "mic-1", "hw:0,0"},
+		{"default", MediaDeviceKindVideoInput, MediaDeviceInfo{}, "", "/dev/video2"},
+	} {
+		got, err := defaultMediaDevices.resolveCaptureDevice(ctx, tc.kind, tc.device, tc.deviceID)
+		if err != nil || got != tc.want {
+			t.Errorf("%s: got %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	if _, err := defaultMediaDevices.resolveCaptureDevice(ctx, MediaDeviceKindVideoInput, MediaDeviceInfo{}, "nope"); err == nil {
+		t.Error("unknown device ID accepted")
+	}
+	if _, err := defaultMediaDevices.resolveCaptureDevice(ctx, MediaDeviceKindVideoInput, MediaDeviceInfo{DeviceID: "mic-1", DeviceName: "hw:0,0", Kind: MediaDeviceKindAudioInput}, ""); err == nil {
+		t.Error("audio device accepted for a video reader")
+	}
+}
@@ -0,0 +1,181 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors matched by errors.Is against a *CaptureError of the corresponding
+// cause, whether the cause was detected before FFmpeg started or classified
+// from its stderr.
+var (
+	// ErrDeviceBusy means the device is in use by another process.
+	ErrDeviceBusy = errors.New("device busy")
+	// ErrPermissionDenied means the OS refused access to the device.
+	ErrPermissionDenied = errors.New("permission denied")
+)
+
+// Errors named after the DOMException a browser's getUserMedia rejects
+// with, so that callers can handle failures the way web code does. Together
+// with *OverconstrainedError they cover the failures of GetUserMedia:
+//
+//   - ErrNotFound (NotFoundError): no device has the requested ID, or there
+//     is no device of the requested kind. Returned wrapped by device lookups.
+//   - ErrNotReadable (NotReadableError): the device exists but cannot be
+//     read, because another process holds it or it failed. Matched by a
+//     *CaptureError of CauseDeviceBusy or CauseIOError.
+//   - ErrNotAllowed (NotAllowedError): the OS denied access to the device.
+//     Matched by a *CaptureError of CausePermissionDenied.
+//
+// A *CaptureError of CauseDeviceNotFound, where FFmpeg could not open the
+// named device, also matches ErrNotFound.
+var (
+	ErrNotFound    = errors.New("device not found")
+	ErrNotReadable = errors.New("device not readable")
+	ErrNotAllowed  = errors.New("device access not allowed")
+)
+
+// ErrorCause is a machine-readable classification of an FFmpeg capture failure,
+// derived from the subprocess stderr output.
+type ErrorCause string
+
+const (
+	// CauseUnknown means stderr did not match any known failure signature.
+	CauseUnknown ErrorCause = "unknown"
+	// CauseUnsupportedResolution means the device rejected the requested video size.
+	CauseUnsupportedResolution ErrorCause = "unsupported_resolution"
+	// CauseUnsupportedFrameRate means the device rejected the requested frame rate.
+	CauseUnsupportedFrameRate ErrorCause = "unsupported_frame_rate"
+	// CauseUnsupportedFormat means the device rejected the requested sample rate,
+	// channel count or pixel format.
+	CauseUnsupportedFormat ErrorCause = "unsupported_format"
+	// CauseDeviceNotFound means FFmpeg could not find or open the named device.
+	CauseDeviceNotFound ErrorCause = "device_not_found"
+	// CauseDeviceBusy means the device is in use by another process.
+	CauseDeviceBusy ErrorCause = "device_busy"
+	// CausePermissionDenied means the OS refused access to the device.
+	CausePermissionDenied ErrorCause = "permission_denied"
+	// CauseIOError means the device failed while streaming (unplugged, driver error).
+	CauseIOError ErrorCause = "io_error"
+)
+
+// stderrSignature maps a substring of FFmpeg stderr to a failure cause.
+type stderrSignature struct {
+	pattern string // matched case-insensitively
+	cause   ErrorCause
+	hint    string
+}
+
+// stderrSignatures is checked in order; the first match wins. More specific
+// patterns must therefore precede generic ones such as "Input/output error".
+var stderrSignatures = []stderrSignature{
+	// Resolution negotiation.
+	{"selected video size", CauseUnsupportedResolution, "the device does not support the requested resolution; pick one of its native modes"},
+	{"driver changed the video from", CauseUnsupportedResolution, "the V4L2 driver substituted a different resolution; request one the camera supports"},
+	{"could not set video options", CauseUnsupportedResolution, "DirectShow rejected the video size/frame rate combination; list the device options and choose a supported mode"},
+
+	// Frame rate negotiation.
+	{"selected framerate", CauseUnsupportedFrameRate, "the device does not support the requested frame rate"},
+	{"time per frame", CauseUnsupportedFrameRate, "the V4L2 driver substituted a different frame rate; request one the camera supports"},
+
+	// Audio/pixel format negotiation.
+	{"cannot set sample rate", CauseUnsupportedFormat, "the device does not support the requested sample rate"},
+	{"cannot set channel count", CauseUnsupportedFormat, "the device does not support the requested channel count"},
+	{"incompatible pixel format", CauseUnsupportedFormat, "the device does not support the requested pixel format"},
+
+	// Access problems.
+	{"permission denied", CausePermissionDenied, "the OS denied access to the device; check privacy settings or group membership (e.g. 'video'/'audio')"},
+	{"not authorized", CausePermissionDenied, "camera/microphone access has not been granted to this application"},
+	{"access is denied", CausePermissionDenied, "Windows denied access to the device; check Settings > Privacy & security > Camera/Microphone"},
+	{"device or resource busy", CauseDeviceBusy, "another application is using the device; close it and retry"},
+	{"could not run graph", CauseDeviceBusy, "DirectShow could not start the device; it is usually in use by another application"},
+	{"acamera_error_permission_denied", CausePermissionDenied, "the app has not been granted the Android CAMERA permission; request it before capturing"},
+	{"acamera_error_camera_in_use", CauseDeviceBusy, "another app is using the camera; close it and retry"},
+	{"acamera_error_max_camera_in_use", CauseDeviceBusy, "the device cannot open more cameras at once; close another camera and retry"},
+
+	// Missing devices.
+	{"could not find video device", CauseDeviceNotFound, "no device with that name exists; re-run device enumeration"},
+	{"could not find audio only device", CauseDeviceNotFound, "no device with that name exists; re-run device enumeration"},
+	{"no camera with index", CauseDeviceNotFound, "the Android device has no camera with that index; list its cameras with Config.DiscoverDevices"},
+	{"no such file or directory", CauseDeviceNotFound, "the device node does not exist; it may have been unplugged"},
+	{"no such device", CauseDeviceNotFound, "the device disappeared; it may have been unplugged"},
+
+	// Streaming failures.
+	{"input/output error", CauseIOError, "the device stopped responding; check the cable, USB hub and driver"},
+	{"i/o error", CauseIOError, "the device stopped responding; check the cable, USB hub and driver"},
+}
+
+// classifyStderr inspects FFmpeg stderr output for known failure signatures
+// and returns the matching cause and a human-readable hint.
+// It returns CauseUnknown and an empty hint if nothing matches.
+func classifyStderr(stderr string) (ErrorCause, string) {
+	lower := strings.ToLower(stderr)
+	for _, sig := range stderrSignatures {
+		if strings.Contains(lower, sig.pattern) {
+			return sig.cause, sig.hint
+		}
+	}
+	return CauseUnknown, ""
+}
+
+// CaptureError is returned when reading from an FFmpeg capture fails.
+// It carries a classification of the failure derived from stderr.
+// Use errors.As to inspect it.
+type CaptureError struct {
+	// Cause is the machine-readable failure classification.
+	Cause ErrorCause
+	// Hint is a short human-readable suggestion, empty for CauseUnknown.
+	Hint string
+	// Stderr is the tail of FFmpeg's stderr at the time of the failure.
+	Stderr string
+	// Err is the underlying read error.
+	Err error
+}
+
+// newCaptureError wraps err with a classification of the given stderr tail.
+func newCaptureError(err error, stderr string) *CaptureError {
+	cause, hint := classifyStderr(stderr)
+	return &CaptureError{
+		Cause:  cause,
+		Hint:   hint,
+		Stderr: stderr,
+		Err:    err,
+	}
+}
+
+// Error implements the error interface. Errors detected before FFmpeg
+// started have no stderr to show.
+func (e *CaptureError) Error() string {
+	msg := fmt.Sprint(e.Err)
+	if e.Cause != CauseUnknown {
+		msg += fmt.Sprintf(" (%s: %s)", e.Cause, e.Hint)
+	}
+	if e.Stderr != "" {
+		msg += "\nstderr: " + e.Stderr
+	}
+	return msg
+}
+
+// Unwrap returns the underlying read error.
+func (e *CaptureError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is a sentinel error of the failure cause:
+// ErrDeviceBusy and ErrNotReadable for CauseDeviceBusy, ErrNotReadable for
+// CauseIOError, ErrPermissionDenied and ErrNotAllowed for
+// CausePermissionDenied, and ErrNotFound for CauseDeviceNotFound.
+func (e *CaptureError) Is(target error) bool {
+	switch target {
+	case ErrDeviceBusy:
+		return e.Cause == CauseDeviceBusy
+	case ErrPermissionDenied, ErrNotAllowed:
+		return e.Cause == CausePermissionDenied
+	case ErrNotReadable:
+		return e.Cause == CauseDeviceBusy || e.Cause == CauseIOError
+	case ErrNotFound:
+		return e.Cause == CauseDeviceNotFound
+	}
+	return false
+}
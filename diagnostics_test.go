@@ -0,0 +1,101 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestClassifyStderr(t *testing.T) {
+	tests := []struct {
+		name   string
+		stderr string
+		want   ErrorCause
+	}{
+		{"avfoundation size", "[avfoundation @ 0x7f] Selected video size (1920x1080) is not supported by the device.", CauseUnsupportedResolution},
+		{"v4l2 size", "[video4linux2,v4l2 @ 0x55] The V4L2 driver changed the video from 1920x1080 to 640x480", CauseUnsupportedResolution},
+		{"dshow options", "[dshow @ 000001] Could not set video options\nvideo=Cam: I/O error", CauseUnsupportedResolution},
+		{"avfoundation fps", "[avfoundation @ 0x7f] Selected framerate (60.000000) is not supported by the device.", CauseUnsupportedFrameRate},
+		{"v4l2 fps", "[video4linux2,v4l2 @ 0x55] The driver changed the time per frame from 1/60 to 1/30", CauseUnsupportedFrameRate},
+		{"alsa rate", "[alsa @ 0x55] cannot set sample rate 0x1f40 (Invalid argument)", CauseUnsupportedFormat},
+		{"v4l2 permission", "[video4linux2,v4l2 @ 0x55] Cannot open video device /dev/video0: Permission denied", CausePermissionDenied},
+		{"v4l2 busy", "[video4linux2,v4l2 @ 0x55] ioctl(VIDIOC_STREAMON): Device or resource busy", CauseDeviceBusy},
+		{"dshow busy", "[dshow @ 000001] Could not run graph (sometimes caused by a device already in use by other application)", CauseDeviceBusy},
+		{"dshow denied", "[dshow @ 000001] Could not RenderStream to connect pins: Access is denied.", CausePermissionDenied},
+		{"dshow missing", "[dshow @ 000001] Could not find video device with name [Nope] among source devices of type video.", CauseDeviceNotFound},
+		{"v4l2 missing", "/dev/video7: No such file or directory", CauseDeviceNotFound},
+		{"android denied", "[android_camera @ 0x7b] Failed to open camera with id 0, error: ACAMERA_ERROR_PERMISSION_DENIED.", CausePermissionDenied},
+		{"android busy", "[android_camera @ 0x7b] Failed to open camera with id 1, error: ACAMERA_ERROR_CAMERA_IN_USE.", CauseDeviceBusy},
+		{"android missing", "[android_camera @ 0x7b] No camera with index 2 available.", CauseDeviceNotFound},
+		{"io", "[video4linux2,v4l2 @ 0x55] ioctl(VIDIOC_DQBUF): Input/output error", CauseIOError},
+		{"benign", "Stream #0:0: Video: rawvideo (YUY2 / 0x32595559), yuyv422, 640x480, 30 fps", CauseUnknown},
+		{"empty", "", CauseUnknown},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, hint := classifyStderr(tt.stderr)
+			if got != tt.want {
+				t.Errorf("classifyStderr() = %q, want %q", got, tt.want)
+			}
+			if (hint == "") != (tt.want == CauseUnknown) {
+				t.Errorf("hint = %q for cause %q", hint, got)
+			}
+		})
+	}
+}
+
+func TestCaptureError_Unwrap(t *testing.T) {
+	err := error(newCaptureError(io.ErrClosedPipe, "Permission denied"))
+
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Error("errors.Is should find the wrapped read error")
+	}
+	var ce *CaptureError
+	if !errors.As(err, &ce) {
+		t.Fatal("errors.As should find *CaptureError")
+	}
+	if ce.Cause != CausePermissionDenied {
+		t.Errorf("Cause = %q, want %q", ce.Cause, CausePermissionDenied)
+	}
+	if !strings.Contains(err.Error(), "stderr: Permission denied") {
+		t.Errorf("Error() = %q, want stderr tail included", err.Error())
+	}
+}
+
+func TestCaptureError_Is(t *testing.T) {
+	busy := fmt.Errorf("ffmpeg: start video capture: %w", newCaptureError(io.EOF, "ioctl(VIDIOC_STREAMON): Device or resource busy"))
+	if !errors.Is(busy, ErrDeviceBusy) {
+		t.Error("errors.Is(busy, ErrDeviceBusy) = false")
+	}
+	if errors.Is(busy, ErrPermissionDenied) {
+		t.Error("errors.Is(busy, ErrPermissionDenied) = true")
+	}
+
+	denied := deviceAccessError(CausePermissionDenied, "/dev/video0", "no access")
+	if !errors.Is(denied, ErrPermissionDenied) || errors.Is(denied, ErrDeviceBusy) {
+		t.Errorf("errors.Is(%v) matches the wrong sentinel", denied)
+	}
+	if got, want := denied.Error(), "ffmpeg: open /dev/video0: permission denied (permission_denied: no access)"; got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+
+	// The DOMException-style sentinels.
+	for _, tc := range []struct {
+		err  error
+		want error
+	}{
+		{busy, ErrNotReadable},
+		{newCaptureError(io.EOF, "/dev/video0: Input/output error"), ErrNotReadable},
+		{denied, ErrNotAllowed},
+		{newCaptureError(io.EOF, "/dev/video7: No such file or directory"), ErrNotFound},
+	} {
+		for _, sentinel := range []error{ErrNotFound, ErrNotReadable, ErrNotAllowed} {
+			if got := errors.Is(tc.err, sentinel); got != (sentinel == tc.want) {
+				t.Errorf("errors.Is(%q, %v) = %v", tc.err, sentinel, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,187 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// discoveryBackendTimeout bounds each discovery backend independently of
+	// the others, within the overall enumeration deadline, unless
+	// Config.DiscoveryTimeout is set.
+	discoveryBackendTimeout = 5 * time.Second
+
+	// discoveryAbandonGrace is how long a backend that has exceeded its
+	// deadline is given to return its partial result before it is abandoned.
+	discoveryAbandonGrace = 2 * time.Second
+)
+
+// DiscoveryError reports the failure of one device discovery backend
+// ("v4l2", "alsa", "pulse", "dshow" or "avfoundation"). The devices found by the other
+// backends are still returned alongside it.
+type DiscoveryError struct {
+	Backend string
+	Err     error
+}
+
+func (e *DiscoveryError) Error() string {
+	return e.Backend + ": " + e.Err.Error()
+}
+
+func (e *DiscoveryError) Unwrap() error {
+	return e.Err
+}
+
+// loopbackLabels are substrings of the labels of common loopback audio
+// inputs, matched case-insensitively: Windows "Stereo Mix" style drivers,
+// virtual cables and the virtual-audio-capturer DirectShow filter of
+// screen-capture-recorder, which records through WASAPI loopback, and the
+// virtual devices of macOS.
+var loopbackLabels = []string{"stereo mix", "what u hear", "wave out mix", "cable output", "virtual-audio-capturer", "blackhole", "soundflower", "loopback audio"}
+
+// isLoopbackDevice reports whether the label of d names a loopback audio
+// input.
+func isLoopbackDevice(d MediaDeviceInfo) bool {
+	label := strings.ToLower(d.Label)
+	for _, l := range loopbackLabels {
+		if strings.Contains(label, l) {
+			return true
+		}
+	}
+	return false
+}
+
+// markLoopbackDevices sets Loopback on the audio inputs whose label names
+// a loopback device. Sources that are loopbacks by construction, like
+// PulseAudio monitors, are marked by their backend.
+func markLoopbackDevices(devices []MediaDeviceInfo) {
+	for i, d := range devices {
+		if d.Kind == MediaDeviceKindAudioInput && isLoopbackDevice(d) {
+			devices[i].Loopback = true
+		}
+	}
+}
+
+// discoveryBackend lists the devices of one capture subsystem.
+type discoveryBackend struct {
+	name     string
+	discover func(ctx context.Context) ([]MediaDeviceInfo, error)
+}
+
+// discoverConcurrently runs the backends concurrently, each with its own
+// timeout, and returns the devices of all of them, including partial results
+// of failed backends, together with one DiscoveryError per failed backend.
+func discoverConcurrently(ctx context.Context, backends []discoveryBackend) ([]MediaDeviceInfo, error) {
+	type result struct {
+		devices []MediaDeviceInfo
+		err     error
+	}
+	results := make([]result, len(backends))
+	var wg sync.WaitGroup
+	for i, b := range backends {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i].devices, results[i].err = runDiscoveryBackend(ctx, b)
+		}()
+	}
+	wg.Wait()
+
+	var devices []MediaDeviceInfo
+	var errs []error
+	for i, r := range results {
+		devices = append(devices, r.devices...)
+		if r.err != nil {
+			errs = append(errs, &DiscoveryError{Backend: backends[i].name, Err: r.err})
+		}
+	}
+	return devices, errors.Join(errs...)
+}
+
+// backendTimeoutKey is the context key of the Config.DiscoveryTimeout of
+// the MediaDevices whose discovery is running; see withBackendTimeout.
+type backendTimeoutKey struct{}
+
+// withBackendTimeout returns ctx carrying the timeout of each discovery
+// backend, so that the platform discovery, which only gets the FFmpeg path,
+// honors Config.DiscoveryTimeout of the MediaDevices it runs for. Zero
+// keeps discoveryBackendTimeout.
+func withBackendTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	if timeout <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, backendTimeoutKey{}, timeout)
+}
+
+// runDiscoveryBackend runs one backend under its own timeout. A backend that
+// ignores its context (for example one blocked opening a device node of a
+// broken driver) is abandoned and left to finish in the background.
+func runDiscoveryBackend(ctx context.Context, b discoveryBackend) ([]MediaDeviceInfo, error) {
+	timeout := discoveryBackendTimeout
+	if t, ok := ctx.Value(backendTimeoutKey{}).(time.Duration); ok && t > 0 {
+		timeout = t
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	type result struct {
+		devices []MediaDeviceInfo
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		devices, err := b.discover(ctx)
+		done <- result{devices, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.devices, r.err
+	case <-ctx.Done():
+	}
+	select {
+	case r := <-done:
+		if r.err == nil {
+			r.err = ctx.Err()
+		}
+		return r.devices, r.err
+	case <-time.After(discoveryAbandonGrace):
+		return nil, ctx.Err()
+	}
+}
+
+// runDeviceList runs an FFmpeg device listing command and returns its
+// combined output. FFmpeg prints the list to stderr and exits with an error
+// code, so only a context error is reported; the output read before the
+// process was killed is returned with it so that callers can parse the
+// devices listed so far.
+func runDeviceList(ctx context.Context, ffmpegPath string, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	// Don't wait for the pipes forever if a killed ffmpeg left a child
+	// process holding them open.
+	cmd.WaitDelay = time.Second
+	output, _ := cmd.CombinedOutput()
+	return string(output), ctx.Err()
+}
+
+// runDiscoveryTool runs a helper tool of device or display discovery, such
+// as xrandr or wmctrl, and returns its standard output. The tool is killed
+// after timeout, the discovery timeout of the configuration that asked for
+// it, so that a tool stuck on an unresponsive display server or driver
+// cannot block enumeration.
+func runDiscoveryTool(timeout time.Duration, name string, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.WaitDelay = time.Second
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		err = fmt.Errorf("%s: %w", name, ctx.Err())
+	}
+	return out, err
+}
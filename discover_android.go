@@ -0,0 +1,101 @@
+//go:build android
+
+package mediadevices
+
+import (
+	"bufio"
+	"context"
+	"regexp"
+	"strings"
+)
+
+// openALDeviceRe matches the device lines FFmpeg prints under the OpenAL
+// listing header, like: "[openal @ 0x7b3c0d2a40]   Android Default"
+var openALDeviceRe = regexp.MustCompile(`^\[openal\s+@\s+\S+\]\s{2,}(\S.*)$`)
+
+// openALListHeader precedes the capture devices in the OpenAL listing.
+const openALListHeader = "List of OpenAL capture devices on this system:"
+
+// androidCameras are the cameras listed on Android. FFmpeg's android_camera
+// cannot enumerate cameras, and the NDK camera list needs the app's CAMERA
+// permission and a JNI context to label, so the two cameras every phone has
+// are listed by index: Camera2 reports the back camera first, and the lens
+// facing follows from the index. Apps that know the device's cameras should
+// list them with Config.DiscoverDevices.
+var androidCameras = []MediaDeviceInfo{
+	{DeviceName: "0", Label: "Back Camera", FacingMode: FacingModeEnvironment},
+	{DeviceName: "1", Label: "Front Camera", FacingMode: FacingModeUser},
+}
+
+// discoverDevices lists the Camera2 cameras and the OpenAL capture devices
+// concurrently. Android has no DeckLink driver and FFmpeg no Android audio
+// output, so neither is listed.
+func discoverDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	return discoverConcurrently(ctx, []discoveryBackend{{
+		name:     "android_camera",
+		discover: func(context.Context) ([]MediaDeviceInfo, error) { return discoverAndroidCameras(), nil },
+	}, {
+		name: "openal",
+		discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			output, err := runDeviceList(ctx, ffmpegPath, "-hide_banner", "-list_devices", "true", "-f", "openal", "-i", "dummy")
+			return parseOpenALOutput(output), err
+		},
+	}})
+}
+
+func discoverAndroidCameras() []MediaDeviceInfo {
+	var ids deviceIDs
+	devices := make([]MediaDeviceInfo, 0, len(androidCameras))
+	for _, c := range androidCameras {
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id("android_camera:"+c.DeviceName, MediaDeviceKindVideoInput),
+			DeviceName: c.DeviceName, // camera index for FFmpeg
+			GroupID:    "builtin",    // shared with the built-in microphone
+			Kind:       MediaDeviceKindVideoInput,
+			Label:      c.Label,
+			IsDefault:  c.DeviceName == "0",
+			FacingMode: c.FacingMode,
+		})
+	}
+	return devices
+}
+
+// parseOpenALOutput parses the OpenAL capture device listing. OpenAL Soft
+// lists its default device first.
+func parseOpenALOutput(output string) []MediaDeviceInfo {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	listing := false
+	sc := bufio.NewScanner(strings.NewReader(output))
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if strings.HasSuffix(line, openALListHeader) {
+			listing = true
+			continue
+		}
+		if !listing {
+			continue
+		}
+		m := openALDeviceRe.FindStringSubmatch(line)
+		if m == nil {
+			// The listing ends at the first line that is not a device.
+			break
+		}
+		name := strings.TrimSpace(m[1])
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id("openal:"+name, MediaDeviceKindAudioInput),
+			DeviceName: name,
+			GroupID:    "builtin", // shared with the cameras
+			Kind:       MediaDeviceKindAudioInput,
+			Label:      name,
+			IsDefault:  len(devices) == 0,
+		})
+	}
+	return devices
+}
+
+// probeDeviceModes reports no modes on Android: android_camera cannot list
+// the sizes of a camera, and FFmpeg cannot list microphone formats.
+func probeDeviceModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	return "", nil
+}
@@ -0,0 +1,39 @@
+//go:build android
+
+package mediadevices
+
+import "testing"
+
+func TestParseOpenALOutput(t *testing.T) {
+	output := `[openal @ 0x7b3c0d2a40] List of OpenAL capture devices on this system:
+[openal @ 0x7b3c0d2a40]   Android Default
+[openal @ 0x7b3c0d2a40]   USB Audio Device
+[in#0 @ 0x7b3c0d1e00] Error opening input: Immediate exit requested
+`
+	devices := parseOpenALOutput(output)
+	if len(devices) != 2 {
+		t.Fatalf("devices = %+v, want 2", devices)
+	}
+	if d := devices[0]; d.DeviceName != "Android Default" || d.Label != "Android Default" || !d.IsDefault || d.Kind != MediaDeviceKindAudioInput {
+		t.Errorf("devices[0] = %+v", d)
+	}
+	if d := devices[1]; d.DeviceName != "USB Audio Device" || d.IsDefault {
+		t.Errorf("devices[1] = %+v", d)
+	}
+	if devices[0].DeviceID == devices[1].DeviceID {
+		t.Error("devices share an ID")
+	}
+}
+
+func TestDiscoverAndroidCameras(t *testing.T) {
+	devices := discoverAndroidCameras()
+	if len(devices) != 2 {
+		t.Fatalf("devices = %+v, want the back and front cameras", devices)
+	}
+	if d := devices[0]; d.DeviceName != "0" || !d.IsDefault || d.Kind != MediaDeviceKindVideoInput || d.FacingMode != FacingModeEnvironment {
+		t.Errorf("devices[0] = %+v", d)
+	}
+	if d := devices[1]; d.DeviceName != "1" || d.IsDefault || d.GroupID != "builtin" || d.FacingMode != FacingModeUser {
+		t.Errorf("devices[1] = %+v", d)
+	}
+}
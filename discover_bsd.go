@@ -0,0 +1,73 @@
+//go:build freebsd || openbsd
+
+package mediadevices
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// discoverDevices lists the video devices and the devices of the sound
+// system concurrently, so that a hung video driver does not hide the
+// microphones and vice versa.
+func discoverDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	return discoverConcurrently(ctx, []discoveryBackend{
+		{name: "v4l2", discover: discoverVideoNodes},
+		{name: audioSystem, discover: func(context.Context) ([]MediaDeviceInfo, error) { return discoverAudioDevices() }},
+	})
+}
+
+// discoverVideoNodes lists the V4L2 compatible video devices, /dev/video*
+// (from webcamd on FreeBSD, video(4) on OpenBSD), and on FreeBSD the
+// Brooktree frame grabbers, /dev/bktr*.
+func discoverVideoNodes(ctx context.Context) ([]MediaDeviceInfo, error) {
+	var matches []string
+	for _, pattern := range []string{"/dev/video[0-9]*", bktrDevicePrefix + "[0-9]*"} {
+		m, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, m...)
+	}
+
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	for _, path := range matches {
+		if err := ctx.Err(); err != nil {
+			return devices, err
+		}
+		// Only include devices we can open.
+		f, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		f.Close()
+
+		name := filepath.Base(path)
+		label := name
+		if strings.HasPrefix(path, bktrDevicePrefix) {
+			label = "Brooktree frame grabber (" + name + ")"
+		}
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id("v4l2:"+path, MediaDeviceKindVideoInput),
+			DeviceName: path,
+			GroupID:    path,
+			Kind:       MediaDeviceKindVideoInput,
+			Label:      label,
+			IsDefault:  path == "/dev/video0",
+		})
+	}
+	return devices, nil
+}
+
+// probeDeviceModes lists the formats and frame sizes of a V4L2 camera.
+// FFmpeg cannot list the formats of bktr, OSS or sndio devices, so those
+// report no modes.
+func probeDeviceModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	if kind != MediaDeviceKindVideoInput || strings.HasPrefix(name, bktrDevicePrefix) {
+		return "", nil
+	}
+	return runDeviceList(ctx, ffmpegPath, "-hide_banner", "-f", "v4l2", "-list_formats", "all", "-i", name)
+}
@@ -3,8 +3,10 @@
 package mediadevices
 
 import (
+	"context"
 	"os/exec"
 	"regexp"
+	"slices"
 	"strings"
 )
 
@@ -14,14 +16,94 @@ var avfDeviceRe = regexp.MustCompile(`\[AVFoundation[^\]]*\]\s+\[(\d+)\]\s+(.+)`
 // avfSectionRe matches section headers like: [AVFoundation ...] AVFoundation video devices:
 var avfSectionRe = regexp.MustCompile(`\[AVFoundation[^\]]*\]\s+AVFoundation\s+(video|audio)\s+devices:`)
 
-func discoverDevices(ffmpegPath string) ([]MediaDeviceInfo, error) {
-	cmd := exec.Command(ffmpegPath, "-f", "avfoundation", "-list_devices", "true", "-i", "")
-	// FFmpeg writes device list to stderr and exits with error code; that's expected.
-	output, _ := cmd.CombinedOutput()
-	return parseAVFoundationOutput(string(output)), nil
+// audioToolboxDeviceRe matches lines like:
+// [AudioToolbox @ 0x...] [ 1]          MacBook Pro Speakers, BuiltInSpeakerDevice
+var audioToolboxDeviceRe = regexp.MustCompile(`\[AudioToolbox[^\]]*\]\s+\[\s*(\d+)\]\s+(.+)`)
+
+// discoverDevices lists AVFoundation capture devices, AudioToolbox
+// output devices and DeckLink inputs. FFmpeg lists AVFoundation video and audio devices in one
+// run, so they share the single "avfoundation" backend.
+func discoverDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	return discoverConcurrently(ctx, []discoveryBackend{{
+		name: "avfoundation",
+		discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			output, err := runDeviceList(ctx, ffmpegPath, "-f", "avfoundation", "-list_devices", "true", "-i", "")
+			devices := parseAVFoundationOutput(output)
+			if err != nil {
+				return devices, err
+			}
+			markDefaultDevices(ctx, devices)
+			groupDevices(ctx, devices)
+			return devices, nil
+		},
+	}, {
+		name: "audiotoolbox",
+		discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			return discoverAudioToolboxDevices(ctx, ffmpegPath)
+		},
+	}, {
+		name: "decklink",
+		discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			return discoverDeckLinkDevices(ctx, ffmpegPath)
+		},
+	}})
+}
+
+// discoverAudioToolboxDevices lists the Core Audio devices FFmpeg's
+// audiotoolbox output can play to. The list contains every Core Audio
+// device, so those without output channels according to system_profiler
+// are skipped. Listing happens when the output opens, so one frame of
+// silence is played to the default device.
+func discoverAudioToolboxDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	output, err := runDeviceList(ctx, ffmpegPath, "-hide_banner", "-f", "lavfi", "-i", "anullsrc", "-frames:a", "1",
+		"-f", "audiotoolbox", "-list_devices", "true", "-")
+	devices := parseAudioToolboxOutput(output)
+	if len(devices) == 0 {
+		return nil, err
+	}
+	if out, perr := exec.CommandContext(ctx, "system_profiler", "-json", "SPAudioDataType").Output(); perr == nil {
+		info := parseCoreAudioDevices(out)
+		if len(info.outputs) > 0 {
+			devices = slices.DeleteFunc(devices, func(d MediaDeviceInfo) bool { return !info.outputs[d.Label] })
+		}
+		markDefaultDevice(devices, MediaDeviceKindAudioOutput, info.defaultOutput)
+	}
+	return devices, nil
+}
+
+// parseAudioToolboxOutput parses the device list of FFmpeg's audiotoolbox
+// output: the index, then the name right-aligned and the device UID.
+func parseAudioToolboxOutput(output string) []MediaDeviceInfo {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	for _, line := range strings.Split(output, "\n") {
+		m := audioToolboxDeviceRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		name, uid := strings.TrimSpace(m[2]), ""
+		if i := strings.LastIndex(name, ", "); i >= 0 {
+			name, uid = strings.TrimSpace(name[:i]), strings.TrimSpace(name[i+2:])
+		}
+		// The UID survives reboots and reordering; the index does not.
+		key := uid
+		if key == "" {
+			key = name
+		}
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id("audiotoolbox:"+key, MediaDeviceKindAudioOutput),
+			DeviceName: m[1], // -audio_device_index for FFmpeg
+			GroupID:    outputDeviceGroupID(uid, name),
+			Kind:       MediaDeviceKindAudioOutput,
+			Label:      name,
+			IsDefault:  len(devices) == 0,
+		})
+	}
+	return devices
 }
 
 func parseAVFoundationOutput(output string) []MediaDeviceInfo {
+	var ids deviceIDs
 	var devices []MediaDeviceInfo
 	lines := strings.Split(output, "\n")
 	currentKind := MediaDeviceKindVideoInput
@@ -39,15 +121,49 @@ func parseAVFoundationOutput(output string) []MediaDeviceInfo {
 		if dm := avfDeviceRe.FindStringSubmatch(line); dm != nil {
 			idx := dm[1]
 			name := strings.TrimSpace(dm[2])
-			devices = append(devices, MediaDeviceInfo{
-				DeviceID:  idx,
-				GroupID:   idx, // avfoundation doesn't provide groupId, use deviceId
-				Kind:      currentKind,
-				Label:     name,
-				IsDefault: idx == "0",
-			})
+			// Indices follow enumeration order, which changes when devices
+			// come and go, so the ID is derived from the device name.
+			d := MediaDeviceInfo{
+				DeviceID:   ids.id("avfoundation:"+name, currentKind),
+				DeviceName: idx,  // index for FFmpeg
+				GroupID:    name, // replaced by the USB location or "builtin" in groupDevices
+				Kind:       currentKind,
+				Label:      name,
+				IsDefault:  idx == "0",
+			}
+			if currentKind == MediaDeviceKindVideoInput {
+				d.FacingMode = avfoundationFacingMode(name)
+			}
+			devices = append(devices, d)
 		}
 	}
 
 	return devices
 }
+
+// avfoundationFacingMode returns the facing of an AVFoundation camera from
+// the position AVFoundation reports for it, which FFmpeg does not print.
+// The built-in FaceTime cameras are at the front, as is Continuity Camera,
+// which mounts the iPhone with its rear cameras towards the user. Desk
+// View films the desk from above and faces away from the user. Other
+// cameras have an unspecified position and are left to the label.
+func avfoundationFacingMode(name string) FacingMode {
+	switch {
+	case strings.Contains(name, "Desk View"):
+		return FacingModeEnvironment
+	case strings.Contains(name, "FaceTime"), strings.Contains(name, "iPhone"), strings.Contains(name, "iPad"):
+		return FacingModeUser
+	}
+	return ""
+}
+
+// probeDeviceModes lists the modes of an AVFoundation camera. AVFoundation
+// has no listing option, but prints the supported modes when asked for an
+// impossible size. FFmpeg cannot list microphone formats, so audio devices
+// report no modes.
+func probeDeviceModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	if kind != MediaDeviceKindVideoInput {
+		return "", nil
+	}
+	return runDeviceList(ctx, ffmpegPath, "-hide_banner", "-f", "avfoundation", "-video_size", "1x1", "-i", name+":none")
+}
@@ -0,0 +1,64 @@
+package mediadevices
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// audioSystem is the FFmpeg format of the FreeBSD sound system.
+const audioSystem = "oss"
+
+// audioNodePattern matches the OSS device nodes, checked by queryPermission.
+const audioNodePattern = "/dev/dsp[0-9]*"
+
+// sndstatRe matches the devices in /dev/sndstat, like:
+// "pcm0: <Realtek ALC892 (Rear Analog)> (play/rec) default"
+var sndstatRe = regexp.MustCompile(`^pcm(\d+):\s+<([^>]*)>.*\((play/rec|play|rec)\)(.*)$`)
+
+// discoverAudioDevices lists the OSS devices of /dev/sndstat: those that
+// record as inputs and those that play as outputs.
+func discoverAudioDevices() ([]MediaDeviceInfo, error) {
+	data, err := os.ReadFile("/dev/sndstat")
+	if os.IsNotExist(err) {
+		return nil, nil // no sound driver loaded
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parseSndstat(string(data)), nil
+}
+
+// parseSndstat parses /dev/sndstat. The unit marked "default" is
+// hw.snd.default_unit, which /dev/dsp opens.
+func parseSndstat(out string) []MediaDeviceInfo {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		m := sndstatRe.FindStringSubmatch(strings.TrimSpace(sc.Text()))
+		if m == nil {
+			continue
+		}
+		unit, label, modes := m[1], strings.TrimSpace(m[2]), m[3]
+		isDefault := strings.Contains(m[4], "default")
+		// Unit numbers follow probe order; the description is stable.
+		for _, kind := range []MediaDeviceKind{MediaDeviceKindAudioInput, MediaDeviceKindAudioOutput} {
+			if kind == MediaDeviceKindAudioInput && !strings.Contains(modes, "rec") ||
+				kind == MediaDeviceKindAudioOutput && !strings.Contains(modes, "play") {
+				continue
+			}
+			devices = append(devices, MediaDeviceInfo{
+				DeviceID:   ids.id("oss:"+label, kind),
+				DeviceName: fmt.Sprintf("/dev/dsp%s", unit),
+				GroupID:    "pcm" + unit,
+				Kind:       kind,
+				Label:      label,
+				IsDefault:  isDefault,
+			})
+		}
+	}
+	return devices
+}
@@ -0,0 +1,29 @@
+package mediadevices
+
+import "testing"
+
+func TestParseSndstat(t *testing.T) {
+	out := `Installed devices:
+pcm0: <Realtek ALC892 (Rear Analog)> (play/rec) default
+pcm1: <Realtek ALC892 (Front Analog)> (play/rec)
+pcm2: <NVIDIA (0x0083) (HDMI/DP 8ch)> (play)
+pcm3: <USB audio> (rec)
+No devices installed from userspace.
+`
+	devices := parseSndstat(out)
+	if len(devices) != 6 {
+		t.Fatalf("devices = %+v, want 3 inputs and 3 outputs", devices)
+	}
+	if d := devices[0]; d.Kind != MediaDeviceKindAudioInput || d.DeviceName != "/dev/dsp0" || d.Label != "Realtek ALC892 (Rear Analog)" || !d.IsDefault {
+		t.Errorf("devices[0] = %+v", d)
+	}
+	if d := devices[1]; d.Kind != MediaDeviceKindAudioOutput || d.DeviceName != "/dev/dsp0" || !d.IsDefault {
+		t.Errorf("devices[1] = %+v", d)
+	}
+	if d := devices[4]; d.Kind != MediaDeviceKindAudioOutput || d.Label != "NVIDIA (0x0083) (HDMI/DP 8ch)" || d.IsDefault {
+		t.Errorf("devices[4] = %+v", d)
+	}
+	if d := devices[5]; d.Kind != MediaDeviceKindAudioInput || d.DeviceName != "/dev/dsp3" || d.GroupID != "pcm3" {
+		t.Errorf("devices[5] = %+v", d)
+	}
+}
@@ -1,9 +1,10 @@
-//go:build linux
+//go:build linux && !android
 
 package mediadevices
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,46 +13,81 @@ import (
 )
 
 // cardRe matches lines from /proc/asound/cards like: " 0 [PCH            ]: HDA-Intel - HDA Intel PCH"
-var cardRe = regexp.MustCompile(`^\s*(\d+)\s+\[`)
+var cardRe = regexp.MustCompile(`^\s*(\d+)\s+\[(\S+)\s*\]`)
 
-func discoverDevices(ffmpegPath string) ([]MediaDeviceInfo, error) {
-	var devices []MediaDeviceInfo
-
-	videoDevs, err := discoverV4L2Devices()
-	if err == nil {
-		devices = append(devices, videoDevs...)
-	}
+// v4l2LinkDirs hold the persistent udev names of V4L2 devices, most stable
+// first: by-id names contain the vendor, model and serial number, by-path
+// names the port the device is plugged into.
+var v4l2LinkDirs = []string{"/dev/v4l/by-id", "/dev/v4l/by-path"}
 
-	audioDevs, err := discoverALSADevices()
-	if err == nil {
-		devices = append(devices, audioDevs...)
-	}
-
-	return devices, nil
+// discoverDevices lists V4L2 cameras, ALSA cards, PulseAudio or PipeWire
+// sources and sinks and DeckLink inputs concurrently, so that a hung video driver does not hide
+// the microphones and vice versa.
+func discoverDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	devices, err := discoverConcurrently(ctx, []discoveryBackend{
+		{name: "v4l2", discover: discoverV4L2Devices},
+		{name: "alsa", discover: func(context.Context) ([]MediaDeviceInfo, error) { return discoverALSADevices() }},
+		{name: "pulse", discover: discoverPulseDevices},
+		{name: "decklink", discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			return discoverDeckLinkDevices(ctx, ffmpegPath)
+		}},
+	})
+	preferSoundServerDefault(devices)
+	return devices, err
 }
 
-func discoverV4L2Devices() ([]MediaDeviceInfo, error) {
+func discoverV4L2Devices(ctx context.Context) ([]MediaDeviceInfo, error) {
 	matches, err := filepath.Glob("/dev/video*")
 	if err != nil {
 		return nil, err
 	}
 
+	links := udevLinks(v4l2LinkDirs)
+	var ids deviceIDs
 	var devices []MediaDeviceInfo
 	for _, path := range matches {
+		if err := ctx.Err(); err != nil {
+			return devices, err
+		}
 		// Only include devices we can open.
 		f, err := os.Open(path)
 		if err != nil {
 			continue
 		}
+		c, err := queryV4L2Capability(f)
 		f.Close()
 
 		name := filepath.Base(path)
+		label := name
+		group := sysfsGroupID("video4linux", name)
+		if err == nil {
+			// Skip metadata, codec and output nodes.
+			if !c.canCapture() {
+				continue
+			}
+			if card := c.card(); card != "" {
+				label = card
+			}
+			if group == "" {
+				group = c.busInfo()
+			}
+		}
+		if group == "" {
+			group = path
+		}
+		// /dev/videoN is assigned in probe order, so the ID is derived
+		// from the udev name when there is one.
+		key := path
+		if link, ok := links[path]; ok {
+			key = link
+		}
 		devices = append(devices, MediaDeviceInfo{
-			DeviceID:  path,
-			GroupID:   path, // v4l2 doesn't provide groupId
-			Kind:      MediaDeviceKindVideoInput,
-			Label:     name,
-			IsDefault: path == "/dev/video0",
+			DeviceID:   ids.id("v4l2:"+key, MediaDeviceKindVideoInput),
+			DeviceName: path,
+			GroupID:    group, // physical device, shared with its microphone
+			Kind:       MediaDeviceKindVideoInput,
+			Label:      label,
+			IsDefault:  path == "/dev/video0",
 		})
 	}
 	return devices, nil
@@ -59,11 +95,15 @@ func discoverV4L2Devices() ([]MediaDeviceInfo, error) {
 
 func discoverALSADevices() ([]MediaDeviceInfo, error) {
 	f, err := os.Open("/proc/asound/cards")
+	if os.IsNotExist(err) {
+		return nil, nil // no sound cards, or no ALSA
+	}
 	if err != nil {
 		return nil, err
 	}
 	defer f.Close()
 
+	var ids deviceIDs
 	var devices []MediaDeviceInfo
 	scanner := bufio.NewScanner(f)
 	for scanner.Scan() {
@@ -72,20 +112,70 @@ func discoverALSADevices() ([]MediaDeviceInfo, error) {
 		if m == nil {
 			continue
 		}
-		cardNum := m[1]
+		cardNum, cardID := m[1], m[2]
 		// Extract the descriptive name from the line (after the ": " part).
 		name := strings.TrimSpace(line)
 		if idx := strings.Index(name, " - "); idx >= 0 {
 			name = strings.TrimSpace(name[idx+3:])
 		}
 
+		group := sysfsGroupID("sound", "card"+cardNum)
+		if group == "" {
+			group = fmt.Sprintf("hw:%s", cardNum)
+		}
+		// Card numbers follow probe order; the card ID ("PCH", "C270")
+		// is stable.
 		devices = append(devices, MediaDeviceInfo{
-			DeviceID:  fmt.Sprintf("hw:%s", cardNum),
-			GroupID:   fmt.Sprintf("hw:%s", cardNum), // ALSA doesn't provide separate groupId
-			Kind:      MediaDeviceKindAudioInput,
-			Label:     name,
-			IsDefault: cardNum == "0",
+			DeviceID:   ids.id("alsa:"+cardID, MediaDeviceKindAudioInput),
+			DeviceName: fmt.Sprintf("hw:%s", cardNum),
+			GroupID:    group, // physical device, shared with its camera
+			Kind:       MediaDeviceKindAudioInput,
+			Label:      name,
+			IsDefault:  cardNum == "0",
 		})
+		// Cards with a playback PCM (pcmNp) are also outputs.
+		if playback, _ := filepath.Glob(fmt.Sprintf("/proc/asound/card%s/pcm*p", cardNum)); len(playback) > 0 {
+			devices = append(devices, MediaDeviceInfo{
+				DeviceID:   ids.id("alsa:"+cardID, MediaDeviceKindAudioOutput),
+				DeviceName: fmt.Sprintf("hw:%s", cardNum),
+				GroupID:    group,
+				Kind:       MediaDeviceKindAudioOutput,
+				Label:      name,
+				IsDefault:  cardNum == "0",
+			})
+		}
 	}
 	return devices, scanner.Err()
 }
+
+// udevLinks maps device nodes to the first persistent symlink naming them
+// in dirs, relative to its directory's parent ("by-id/usb-...").
+func udevLinks(dirs []string) map[string]string {
+	links := make(map[string]string)
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			target, err := filepath.EvalSymlinks(filepath.Join(dir, e.Name()))
+			if err != nil {
+				continue
+			}
+			if _, ok := links[target]; !ok {
+				links[target] = filepath.Join(filepath.Base(dir), e.Name())
+			}
+		}
+	}
+	return links
+}
+
+// probeDeviceModes lists the formats and frame sizes of a V4L2 camera.
+// FFmpeg cannot list the formats of an ALSA card, so audio devices report
+// no modes.
+func probeDeviceModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	if kind != MediaDeviceKindVideoInput {
+		return "", nil
+	}
+	return runDeviceList(ctx, ffmpegPath, "-hide_banner", "-f", "v4l2", "-list_formats", "all", "-i", name)
+}
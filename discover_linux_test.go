@@ -0,0 +1,166 @@
+//go:build linux && !android
+
+package mediadevices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestParsePactlSources(t *testing.T) {
+	info := "Server Name: PulseAudio (on PipeWire 1.0.5)\nDefault Sink: alsa_output.pci-0000_00_1f.3.analog-stereo\nDefault Source: alsa_input.usb-046d_0825-00.mono-fallback\n"
+	sources := `Source #48
+	State: SUSPENDED
+	Name: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor
+	Description: Monitor of Built-in Audio Analog Stereo
+	Monitor of Sink: alsa_output.pci-0000_00_1f.3.analog-stereo
+Source #49
+	State: RUNNING
+	Name: alsa_input.usb-046d_0825-00.mono-fallback
+	Description: Webcam C270 Mono
+	Monitor of Sink: n/a
+	Properties:
+		alsa.card = "2"
+		device.description = "Webcam C270"
+Source #50
+	Name: alsa_input.pci-0000_00_1f.3.analog-stereo
+	Description: Built-in Audio Analog Stereo
+	Monitor of Sink: n/a
+`
+	devices := parsePactlSources(sources, parsePactlDefault(info, "Default Source:"))
+	if len(devices) != 3 {
+		t.Fatalf("devices = %+v, want 2 and the monitor", devices)
+	}
+	if d := devices[0]; !d.Loopback || d.IsDefault || d.Label != "Monitor of Built-in Audio Analog Stereo" || d.GroupID != "pulse:alsa_output.pci-0000_00_1f.3.analog-stereo" {
+		t.Errorf("monitor = %+v", d)
+	}
+	d := devices[1]
+	if d.DeviceName != "pulse:alsa_input.usb-046d_0825-00.mono-fallback" || d.Label != "Webcam C270 Mono" || !d.IsDefault || d.Kind != MediaDeviceKindAudioInput || d.Loopback {
+		t.Errorf("devices[1] = %+v", d)
+	}
+	if devices[2].IsDefault || devices[2].Label != "Built-in Audio Analog Stereo" {
+		t.Errorf("devices[2] = %+v", devices[2])
+	}
+
+	sinks := `Sink #47
+	State: SUSPENDED
+	Name: alsa_output.pci-0000_00_1f.3.analog-stereo
+	Description: Built-in Audio Analog Stereo
+	Monitor Source: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor
+Sink #51
+	Name: bluez_output.00_1B_66_A1_B2_C3.1
+	Description: Headphones
+`
+	outputs := parsePactlSinks(sinks, parsePactlDefault(info, "Default Sink:"))
+	if len(outputs) != 2 {
+		t.Fatalf("outputs = %+v, want 2", outputs)
+	}
+	if d := outputs[0]; d.DeviceName != "pulse:alsa_output.pci-0000_00_1f.3.analog-stereo" || d.Kind != MediaDeviceKindAudioOutput || !d.IsDefault {
+		t.Errorf("outputs[0] = %+v", d)
+	}
+	if d := outputs[1]; d.Label != "Headphones" || d.IsDefault {
+		t.Errorf("outputs[1] = %+v", d)
+	}
+}
+
+func TestParsePWDump(t *testing.T) {
+	dump := `[
+  {"id": 30, "type": "PipeWire:Interface:Metadata", "props": {"metadata.name": "default"},
+   "metadata": [{"subject": 0, "key": "default.audio.source", "type": "Spa:String:JSON", "value": {"name": "alsa_input.pci-0000_00_1f.3.analog-stereo"}},
+                {"subject": 0, "key": "default.audio.sink", "type": "Spa:String:JSON", "value": {"name": "alsa_output.pci-0000_00_1f.3.analog-stereo"}}]},
+  {"id": 41, "type": "PipeWire:Interface:Node", "info": {"props": {"media.class": "Audio/Sink", "node.name": "alsa_output.pci-0000_00_1f.3.analog-stereo"}}},
+  {"id": 42, "type": "PipeWire:Interface:Node", "info": {"props": {"media.class": "Audio/Source", "node.name": "alsa_input.pci-0000_00_1f.3.analog-stereo", "node.description": "Built-in Audio Analog Stereo", "api.alsa.pcm.card": 0}}}
+]`
+	devices, err := parsePWDump([]byte(dump))
+	if err != nil {
+		t.Fatalf("parsePWDump: %v", err)
+	}
+	if len(devices) != 3 {
+		t.Fatalf("devices = %+v, want the sink, its monitor and the source", devices)
+	}
+	if d := devices[0]; d.DeviceName != "pulse:alsa_output.pci-0000_00_1f.3.analog-stereo" || d.Kind != MediaDeviceKindAudioOutput || !d.IsDefault {
+		t.Errorf("sink = %+v", d)
+	}
+	// The monitor has the name and ID pactl would list it with.
+	monitor := parsePactlSources("Source #1\n\tName: alsa_output.pci-0000_00_1f.3.analog-stereo.monitor\n\tMonitor of Sink: alsa_output.pci-0000_00_1f.3.analog-stereo\n", "")
+	if d := devices[1]; d.Kind != MediaDeviceKindAudioInput || !d.Loopback || d.IsDefault || d.DeviceID != monitor[0].DeviceID || d.GroupID != devices[0].GroupID {
+		t.Errorf("monitor = %+v", d)
+	}
+	if d := devices[2]; d.DeviceName != "pulse:alsa_input.pci-0000_00_1f.3.analog-stereo" || d.Label != "Built-in Audio Analog Stereo" || !d.IsDefault {
+		t.Errorf("source = %+v", d)
+	}
+}
+
+func TestPreferSoundServerDefault(t *testing.T) {
+	devices := []MediaDeviceInfo{
+		{DeviceName: "/dev/video0", Kind: MediaDeviceKindVideoInput, IsDefault: true},
+		{DeviceName: "hw:0", Kind: MediaDeviceKindAudioInput, IsDefault: true},
+		{DeviceName: "pulse:mic", Kind: MediaDeviceKindAudioInput, IsDefault: true},
+		{DeviceName: "hw:0", Kind: MediaDeviceKindAudioOutput, IsDefault: true},
+	}
+	preferSoundServerDefault(devices)
+	// Without a default sink the ALSA output stays the default.
+	if !devices[0].IsDefault || devices[1].IsDefault || !devices[2].IsDefault || !devices[3].IsDefault {
+		t.Errorf("defaults = %+v", devices)
+	}
+}
+
+func TestUdevLinks(t *testing.T) {
+	dir := t.TempDir()
+	node := filepath.Join(dir, "video0")
+	if err := os.WriteFile(node, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	byID, byPath := filepath.Join(dir, "by-id"), filepath.Join(dir, "by-path")
+	for _, d := range []string{byID, byPath} {
+		if err := os.Mkdir(d, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(node, filepath.Join(byID, "usb-046d_0825_ABC-video-index0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(node, filepath.Join(byPath, "pci-0000:00:14.0-usb-0:1:1.0-video-index0")); err != nil {
+		t.Fatal(err)
+	}
+
+	links := udevLinks([]string{byID, byPath, filepath.Join(dir, "missing")})
+	target, _ := filepath.EvalSymlinks(node)
+	if got := links[target]; got != "by-id/usb-046d_0825_ABC-video-index0" {
+		t.Errorf("link = %q, want the by-id name", got)
+	}
+}
+
+func TestV4L2Capability(t *testing.T) {
+	if size := unsafe.Sizeof(v4l2Capability{}); size != 104 {
+		t.Fatalf("sizeof(v4l2Capability) = %d, want 104 as in videodev2.h", size)
+	}
+
+	var c v4l2Capability
+	copy(c.Card[:], "HD Pro Webcam C920")
+	copy(c.BusInfo[:], "usb-0000:00:14.0-1")
+	if c.card() != "HD Pro Webcam C920" || c.busInfo() != "usb-0000:00:14.0-1" {
+		t.Errorf("card = %q, bus info = %q", c.card(), c.busInfo())
+	}
+
+	for _, tc := range []struct {
+		name       string
+		caps, node uint32
+		want       bool
+	}{
+		// uvcvideo: the device captures, but its second node only has
+		// V4L2_CAP_META_CAPTURE (0x00800000).
+		{"capture node", 0x84a00001, 0x04200001, true},
+		{"metadata node", 0x84a00001, 0x04a00000, false},
+		{"multi-planar", 0x84201000, 0x04201000, true},
+		{"no device caps", 0x05000001, 0, true},
+		{"output only", 0x00000002, 0, false},
+	} {
+		c.Capabilities, c.DeviceCaps = tc.caps, tc.node
+		if got := c.canCapture(); got != tc.want {
+			t.Errorf("%s: canCapture = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
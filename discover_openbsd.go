@@ -0,0 +1,30 @@
+package mediadevices
+
+// audioSystem is the FFmpeg format of the OpenBSD sound system.
+const audioSystem = "sndio"
+
+// audioNodePattern is empty on OpenBSD: programs record and play through
+// the sndiod server, not the /dev/audio* nodes, which sndiod holds.
+const audioNodePattern = ""
+
+// sndioDevice is the default sub-device of the first sndiod server.
+const sndioDevice = "snd/0"
+
+// discoverAudioDevices lists sndiod's default device as an input and an
+// output. sndio cannot enumerate devices: the hardware behind snd/0 is
+// chosen when sndiod is started (sndiod -f rsnd/1).
+func discoverAudioDevices() ([]MediaDeviceInfo, error) {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	for _, kind := range []MediaDeviceKind{MediaDeviceKindAudioInput, MediaDeviceKindAudioOutput} {
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id("sndio:"+sndioDevice, kind),
+			DeviceName: sndioDevice,
+			GroupID:    sndioDevice,
+			Kind:       kind,
+			Label:      "sndio (" + sndioDevice + ")",
+			IsDefault:  true,
+		})
+	}
+	return devices, nil
+}
@@ -0,0 +1,254 @@
+//go:build linux
+
+package mediadevices
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// pulseDevicePrefix marks the device names of PulseAudio and PipeWire
+// sources and sinks, which are captured and played with FFmpeg's pulse
+// devices instead of ALSA. PipeWire is reached through pipewire-pulse, so
+// it shares them.
+const pulseDevicePrefix = "pulse:"
+
+// discoverPulseDevices lists the capture sources and the playback sinks of
+// the sound server with pactl, or with pw-dump where only PipeWire's own
+// tools are installed. The monitor of each sink is listed as a loopback
+// audio input. Systems without a sound server report no devices and no
+// error.
+func discoverPulseDevices(ctx context.Context) ([]MediaDeviceInfo, error) {
+	out, err := runSoundServerTool(ctx, "pactl", "list", "sources")
+	if err == nil {
+		info, err := runSoundServerTool(ctx, "pactl", "info")
+		if err != nil {
+			return nil, err
+		}
+		sinks, err := runSoundServerTool(ctx, "pactl", "list", "sinks")
+		if err != nil {
+			return nil, err
+		}
+		devices := parsePactlSources(string(out), parsePactlDefault(string(info), "Default Source:"))
+		return append(devices, parsePactlSinks(string(sinks), parsePactlDefault(string(info), "Default Sink:"))...), nil
+	}
+	if !errors.Is(err, exec.ErrNotFound) {
+		return nil, err
+	}
+	out, err = runSoundServerTool(ctx, "pw-dump")
+	if errors.Is(err, exec.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parsePWDump(out)
+}
+
+// runSoundServerTool runs a pactl or pw-dump command with untranslated
+// output. A context error takes precedence over the tool's exit status.
+func runSoundServerTool(ctx context.Context, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return out, nil
+}
+
+// parsePactlDefault returns the field of `pactl info` with the given key,
+// "Default Source:" or "Default Sink:".
+func parsePactlDefault(output, key string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if name, ok := strings.CutPrefix(strings.TrimSpace(line), key); ok {
+			return strings.TrimSpace(name)
+		}
+	}
+	return ""
+}
+
+// parsePactlSources parses `pactl list sources`. Each source starts with
+// "Source #N" and has tab-indented "Key: value" fields and properties of the
+// form `key = "value"`.
+func parsePactlSources(output, defaultSource string) []MediaDeviceInfo {
+	return parsePactlList(output, "Source #", MediaDeviceKindAudioInput, defaultSource)
+}
+
+// parsePactlSinks parses `pactl list sinks`, which has the layout of
+// `pactl list sources` with "Sink #N" headers.
+func parsePactlSinks(output, defaultSink string) []MediaDeviceInfo {
+	return parsePactlList(output, "Sink #", MediaDeviceKindAudioOutput, defaultSink)
+}
+
+// parsePactlList parses the entries starting with header as devices of kind.
+func parsePactlList(output, header string, kind MediaDeviceKind, defaultName string) []MediaDeviceInfo {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	var name, label, card, monitorOf string
+	flush := func() {
+		if name != "" {
+			d := pulseDevice(&ids, kind, name, label, card, name == defaultName)
+			if monitorOf != "" {
+				// A monitor belongs to the device of its sink.
+				d.Loopback = true
+				if card == "" {
+					d.GroupID = pulseDevicePrefix + monitorOf
+				}
+			}
+			devices = append(devices, d)
+		}
+		name, label, card, monitorOf = "", "", "", ""
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, header):
+			flush()
+		case strings.HasPrefix(line, "Name:"):
+			name = strings.TrimSpace(strings.TrimPrefix(line, "Name:"))
+		case strings.HasPrefix(line, "Description:"):
+			label = strings.TrimSpace(strings.TrimPrefix(line, "Description:"))
+		case strings.HasPrefix(line, "Monitor of Sink:"):
+			if sink := strings.TrimSpace(strings.TrimPrefix(line, "Monitor of Sink:")); sink != "n/a" {
+				monitorOf = sink
+			}
+		case strings.HasPrefix(line, "alsa.card = "):
+			card = strings.Trim(strings.TrimPrefix(line, "alsa.card = "), `"`)
+		}
+	}
+	flush()
+	return devices
+}
+
+// parsePWDump parses the JSON of `pw-dump`: the Audio/Source and Audio/Sink
+// nodes and the default source and sink from the "default" metadata. Sinks
+// have no monitor nodes; pipewire-pulse provides their monitors under the
+// sink name with a ".monitor" suffix, as pactl lists them.
+func parsePWDump(data []byte) ([]MediaDeviceInfo, error) {
+	var objects []struct {
+		Type  string `json:"type"`
+		Props struct {
+			Name string `json:"metadata.name"`
+		} `json:"props"`
+		Info struct {
+			Props map[string]any `json:"props"`
+		} `json:"info"`
+		Metadata []struct {
+			Key   string `json:"key"`
+			Value struct {
+				Name string `json:"name"`
+			} `json:"value"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return nil, fmt.Errorf("pw-dump: %w", err)
+	}
+
+	defaults := make(map[string]string) // media class to node name
+	for _, o := range objects {
+		if o.Type == "PipeWire:Interface:Metadata" && o.Props.Name == "default" {
+			for _, m := range o.Metadata {
+				switch m.Key {
+				case "default.audio.source":
+					defaults["Audio/Source"] = m.Value.Name
+				case "default.audio.sink":
+					defaults["Audio/Sink"] = m.Value.Name
+				}
+			}
+		}
+	}
+
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	for _, o := range objects {
+		props := o.Info.Props
+		class, _ := props["media.class"].(string)
+		kind := MediaDeviceKindAudioInput
+		switch {
+		case o.Type != "PipeWire:Interface:Node":
+			continue
+		case class == "Audio/Sink":
+			kind = MediaDeviceKindAudioOutput
+		case class != "Audio/Source":
+			continue
+		}
+		name, _ := props["node.name"].(string)
+		if name == "" {
+			continue
+		}
+		label, _ := props["node.description"].(string)
+		card := ""
+		for _, key := range []string{"alsa.card", "api.alsa.pcm.card"} {
+			if v, ok := props[key]; ok && card == "" {
+				card = fmt.Sprint(v)
+			}
+		}
+		d := pulseDevice(&ids, kind, name, label, card, name == defaults[class])
+		devices = append(devices, d)
+		if kind == MediaDeviceKindAudioOutput {
+			monitor := pulseDevice(&ids, MediaDeviceKindAudioInput, name+".monitor", "Monitor of "+d.Label, card, false)
+			monitor.GroupID = d.GroupID
+			monitor.Loopback = true
+			devices = append(devices, monitor)
+		}
+	}
+	return devices, nil
+}
+
+// pulseDevice returns the device of the sound server source or sink name.
+// The names are stable, so the DeviceID is derived from them. Sources and
+// sinks of an ALSA card are grouped with the card's other functions.
+func pulseDevice(ids *deviceIDs, kind MediaDeviceKind, name, label, card string, isDefault bool) MediaDeviceInfo {
+	if label == "" {
+		label = name
+	}
+	group := ""
+	if card != "" {
+		group = sysfsGroupID("sound", "card"+card)
+	}
+	if group == "" {
+		group = pulseDevicePrefix + name
+	}
+	return MediaDeviceInfo{
+		DeviceID:   ids.id(pulseDevicePrefix+name, kind),
+		DeviceName: pulseDevicePrefix + name,
+		GroupID:    group,
+		Kind:       kind,
+		Label:      label,
+		IsDefault:  isDefault,
+	}
+}
+
+// preferSoundServerDefault makes the default source and sink of the sound
+// server the only default microphone and speaker. Desktop systems route
+// audio through them, and ALSA card 0 is often not the device the user chose.
+func preferSoundServerDefault(devices []MediaDeviceInfo) {
+	for _, kind := range []MediaDeviceKind{MediaDeviceKindAudioInput, MediaDeviceKindAudioOutput} {
+		found := false
+		for _, d := range devices {
+			if d.Kind == kind && d.IsDefault && strings.HasPrefix(d.DeviceName, pulseDevicePrefix) {
+				found = true
+			}
+		}
+		if !found {
+			continue
+		}
+		for i, d := range devices {
+			if d.Kind == kind && !strings.HasPrefix(d.DeviceName, pulseDevicePrefix) {
+				devices[i].IsDefault = false
+			}
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+)
+
+func TestDiscoverConcurrently(t *testing.T) {
+	defer func(timeout, grace time.Duration) {
+		discoveryBackendTimeout, discoveryAbandonGrace = timeout, grace
+	}(discoveryBackendTimeout, discoveryAbandonGrace)
+	discoveryBackendTimeout, discoveryAbandonGrace = 50*time.Millisecond, 50*time.Millisecond
+
+	broken := errors.New("no such device")
+	hang := make(chan struct{})
+	defer close(hang)
+	backends := []discoveryBackend{
+		{name: "video", discover: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: "v", Kind: MediaDeviceKindVideoInput}}, nil
+		}},
+		{name: "audio", discover: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: "a", Kind: MediaDeviceKindAudioInput}}, broken
+		}},
+		{name: "stuck", discover: func(context.Context) ([]MediaDeviceInfo, error) {
+			<-hang // ignores its context
+			return nil, nil
+		}},
+		{name: "slow", discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			<-ctx.Done()
+			return []MediaDeviceInfo{{DeviceID: "s", Kind: MediaDeviceKindAudioInput}}, nil
+		}},
+	}
+
+	start := time.Now()
+	devices, err := discoverConcurrently(context.Background(), backends)
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("discovery took %v", d)
+	}
+	if len(devices) != 3 || devices[0].DeviceID != "v" || devices[1].DeviceID != "a" || devices[2].DeviceID != "s" {
+		t.Errorf("devices = %+v, want v, a and s", devices)
+	}
+
+	if !errors.Is(err, broken) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("err = %v", err)
+	}
+	var de *DiscoveryError
+	if !errors.As(err, &de) || de.Backend != "audio" {
+		t.Errorf("first DiscoveryError = %+v, want audio", de)
+	}
+	if got := err.Error(); got != "audio: no such device\nstuck: context deadline exceeded\nslow: context deadline exceeded" {
+		t.Errorf("err = %q", got)
+	}
+}
+
+func TestDiscoveryTimeout(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	defer func(grace time.Duration) { discoveryAbandonGrace = grace }(discoveryAbandonGrace)
+	discoveryAbandonGrace = 50 * time.Millisecond
+
+	if got := defaultMediaDevices.enumerateTimeout(); got != defaultEnumerateTimeout {
+		t.Errorf("default timeout = %v", got)
+	}
+	cfg := orig
+	cfg.DiscoveryTimeout = 100 * time.Millisecond
+	SetConfig(cfg)
+	if got := defaultMediaDevices.enumerateTimeout(); got != cfg.DiscoveryTimeout {
+		t.Errorf("timeout = %v, want %v", got, cfg.DiscoveryTimeout)
+	}
+
+	// A backend is bounded by the configured timeout instead of the
+	// default of 5 seconds.
+	start := time.Now()
+	_, err := discoverConcurrently(withBackendTimeout(context.Background(), cfg.DiscoveryTimeout), []discoveryBackend{
+		{name: "hung", discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}},
+	})
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("backend ran %v", d)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("backend err = %v, want context.DeadlineExceeded", err)
+	}
+
+	// So is a helper tool.
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not found")
+	}
+	start = time.Now()
+	if _, err := runDiscoveryTool(cfg.discoveryTimeout(), "sleep", "10"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("tool err = %v, want context.DeadlineExceeded", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Errorf("tool ran %v", d)
+	}
+}
+
+func TestMarkLoopbackDevices(t *testing.T) {
+	devices := []MediaDeviceInfo{
+		{Kind: MediaDeviceKindAudioInput, Label: "Stereo Mix (Realtek(R) Audio)"},
+		{Kind: MediaDeviceKindAudioInput, Label: "virtual-audio-capturer"},
+		{Kind: MediaDeviceKindAudioInput, Label: "BlackHole 2ch", DeviceName: "1"},
+		{Kind: MediaDeviceKindAudioInput, Label: "Microphone (USB Audio)"},
+		{Kind: MediaDeviceKindAudioOutput, Label: "BlackHole 2ch"},
+	}
+	markLoopbackDevices(devices)
+	for i, want := range []bool{true, true, true, false, false} {
+		if devices[i].Loopback != want {
+			t.Errorf("%q Loopback = %v, want %v", devices[i].Label, devices[i].Loopback, want)
+		}
+	}
+}
@@ -3,14 +3,9 @@
 package mediadevices
 
 import (
-	"crypto/sha256"
-	"fmt"
-	"os/exec"
+	"context"
 	"regexp"
 	"strings"
-
-	"github.com/denisbrodbeck/machineid"
-	"github.com/google/uuid"
 )
 
 // dshowDeviceRe matches lines like: [dshow @ 0x...] "Device Name" (video)
@@ -20,56 +15,73 @@ var dshowDeviceRe = regexp.MustCompile(`\[dshow\s+@\s+\S+\]\s+"([^"]+)"\s+\((vid
 // that appear after a section header indicating video or audio.
 var dshowAltRe = regexp.MustCompile(`\[dshow\s+@\s+\S+\]\s+"([^"]+)"`)
 
+// dshowAltNameRe matches lines like: [dshow @ 0x...]   Alternative name "@device_pnp_\\?\usb#..."
+var dshowAltNameRe = regexp.MustCompile(`\[dshow\s+@\s+\S+\]\s+Alternative name\s+"([^"]+)"`)
+
 // dshowSectionRe matches section headers like: [dshow @ 0x...] DirectShow video devices
 var dshowSectionRe = regexp.MustCompile(`\[dshow\s+@\s+\S+\]\s+DirectShow\s+(video|audio)\s+devices`)
 
-func discoverDevices(ffmpegPath string) ([]MediaDeviceInfo, error) {
-	cmd := exec.Command(ffmpegPath, "-list_devices", "true", "-f", "dshow", "-i", "dummy")
-	// FFmpeg writes device list to stderr and exits with error code; that's expected.
-	output, _ := cmd.CombinedOutput()
-	return parseDshowOutput(string(output)), nil
+// discoverDevices lists DirectShow capture devices, the Core Audio
+// (WASAPI) render endpoints and DeckLink inputs. FFmpeg lists DirectShow video and audio devices
+// in one run, so they share the single "dshow" backend.
+func discoverDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	return discoverConcurrently(ctx, []discoveryBackend{{
+		name: "dshow",
+		discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			output, err := runDeviceList(ctx, ffmpegPath, "-list_devices", "true", "-f", "dshow", "-i", "dummy")
+			devices := parseDshowOutput(output)
+			markDefaultDevices(devices)
+			groupDevices(devices)
+			return devices, err
+		},
+	}, {
+		name:     "wasapi",
+		discover: func(context.Context) ([]MediaDeviceInfo, error) { return discoverRenderEndpoints() },
+	}, {
+		name: "decklink",
+		discover: func(ctx context.Context) ([]MediaDeviceInfo, error) {
+			return discoverDeckLinkDevices(ctx, ffmpegPath)
+		},
+	}})
 }
 
-// getMachineID returns the unique machine ID for this device.
-func getMachineID() string {
-	id, err := machineid.ID()
+// discoverRenderEndpoints lists the active audio render endpoints
+// (speakers, headphones, HDMI outputs) as audio outputs.
+func discoverRenderEndpoints() ([]MediaDeviceInfo, error) {
+	endpoints, err := activeEndpoints(eRender)
 	if err != nil {
-		// Fallback to a constant if machine ID cannot be obtained
-		return "unknown"
+		return nil, err
 	}
-	return id
-}
-
-// machineID is cached at package init
-var cachedMachineID = getMachineID()
-
-// generateDeviceUUID generates a deterministic UUID from machine ID, device name and kind.
-// This ensures the same device on the same machine always gets the same UUID,
-// while devices on different machines get different UUIDs even with identical names.
-func generateDeviceUUID(name string, kind MediaDeviceKind) uuid.UUID {
-	// Include machine ID, device name, and kind in the hash
-	input := fmt.Sprintf("%s:%s:%s", cachedMachineID, name, kind)
-	hash := sha256.Sum256([]byte(input))
-	// Use first 16 bytes of SHA256 hash to create UUID v5 style
-	return uuid.UUID{
-		hash[0], hash[1], hash[2], hash[3],
-		hash[4], hash[5], hash[6], hash[7],
-		hash[8], hash[9], hash[10], hash[11],
-		hash[12], hash[13], hash[14], hash[15],
+	var ids deviceIDs
+	devices := make([]MediaDeviceInfo, 0, len(endpoints))
+	for _, e := range endpoints {
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id(e.name, MediaDeviceKindAudioOutput),
+			DeviceName: e.name,
+			GroupID:    e.container,
+			Kind:       MediaDeviceKindAudioOutput,
+			Label:      e.name,
+		})
+	}
+	if name, err := defaultEndpointName(eRender); err == nil {
+		markDefaultDevice(devices, MediaDeviceKindAudioOutput, name)
 	}
+	return devices, nil
 }
 
 func parseDshowOutput(output string) []MediaDeviceInfo {
 	var devices []MediaDeviceInfo
 	lines := strings.Split(output, "\n")
 
-	// Track seen name+kind combinations to handle potential duplicates
-	seenDeviceKeys := make(map[string]int)
+	// Numbers duplicate name+kind combinations to keep the IDs unique
+	var ids deviceIDs
 
-	// First try the explicit format: "Name" (video) / "Name" (audio)
+	// First try the explicit format: "Name" (video) / "Name" (audio),
+	// each optionally followed by an Alternative name line
 	for _, line := range lines {
 		m := dshowDeviceRe.FindStringSubmatch(line)
 		if m == nil {
+			setAlternativeName(devices, line)
 			continue
 		}
 		name := m[1]
@@ -77,22 +89,14 @@ func parseDshowOutput(output string) []MediaDeviceInfo {
 		if m[2] == "audio" {
 			kind = MediaDeviceKindAudioInput
 		}
-		// Generate unique key for this name+kind combination
-		deviceKey := fmt.Sprintf("%s:%s", name, kind)
-		seenDeviceKeys[deviceKey]++
-		// If duplicate, append index to ensure unique UUID
-		uniqueKey := deviceKey
-		if seenDeviceKeys[deviceKey] > 1 {
-			uniqueKey = fmt.Sprintf("%s:%d", deviceKey, seenDeviceKeys[deviceKey])
-		}
-		deviceID := generateDeviceUUID(uniqueKey, kind).String()
+		deviceID := ids.id(name, kind)
 		devices = append(devices, MediaDeviceInfo{
 			DeviceID:   deviceID,
 			DeviceName: name, // Original device name for FFmpeg
-			GroupID:    name, // dshow doesn't provide groupId, use name for grouping
+			GroupID:    name, // replaced by the container ID in groupDevices
 			Kind:       kind,
 			Label:      name,
-			IsDefault:  false, // dshow doesn't indicate default
+			IsDefault:  false, // dshow doesn't indicate default; see markDefaultDevices
 		})
 	}
 
@@ -111,20 +115,12 @@ func parseDshowOutput(output string) []MediaDeviceInfo {
 			}
 			continue
 		}
+		if setAlternativeName(devices, line) {
+			continue
+		}
 		if am := dshowAltRe.FindStringSubmatch(line); am != nil {
 			name := am[1]
-			// Skip alternative name lines (they contain "Alternative name")
-			if strings.Contains(line, "Alternative name") {
-				continue
-			}
-			// Generate unique key with kind and seen count
-			deviceKey := fmt.Sprintf("%s:%s", name, currentKind)
-			seenDeviceKeys[deviceKey]++
-			uniqueKey := deviceKey
-			if seenDeviceKeys[deviceKey] > 1 {
-				uniqueKey = fmt.Sprintf("%s:%d", deviceKey, seenDeviceKeys[deviceKey])
-			}
-			deviceID := generateDeviceUUID(uniqueKey, currentKind).String()
+			deviceID := ids.id(name, currentKind)
 			devices = append(devices, MediaDeviceInfo{
 				DeviceID:   deviceID,
 				DeviceName: name, // Original device name for FFmpeg
@@ -138,3 +134,26 @@ func parseDshowOutput(output string) []MediaDeviceInfo {
 
 	return devices
 }
+
+// setAlternativeName stores the alternative name on an Alternative name
+// line as that of the last device listed before it, and reports whether line
+// was one.
+func setAlternativeName(devices []MediaDeviceInfo, line string) bool {
+	m := dshowAltNameRe.FindStringSubmatch(line)
+	if m == nil {
+		return false
+	}
+	if len(devices) > 0 {
+		devices[len(devices)-1].AlternativeName = m[1]
+	}
+	return true
+}
+
+// probeDeviceModes lists the pin formats of a DirectShow device.
+func probeDeviceModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	input := "video=" + name
+	if kind == MediaDeviceKindAudioInput {
+		input = "audio=" + name
+	}
+	return runDeviceList(ctx, ffmpegPath, "-hide_banner", "-list_options", "true", "-f", "dshow", "-i", input)
+}
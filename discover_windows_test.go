@@ -2,7 +2,10 @@
 
 package mediadevices
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestParseDshowOutput_ExplicitFormat(t *testing.T) {
 	// Simulates ffmpeg -list_devices true -f dshow -i dummy stderr output.
@@ -65,6 +68,9 @@ func TestParseDshowOutput_AltFormat(t *testing.T) {
 	if devices[1].Label != "Built-in Mic" || devices[1].Kind != MediaDeviceKindAudioInput {
 		t.Errorf("devices[1] = %+v", devices[1])
 	}
+	if devices[0].AlternativeName != "@device_pnp_..." || devices[1].AlternativeName != "@device_cm_..." {
+		t.Errorf("alternative names = %q, %q", devices[0].AlternativeName, devices[1].AlternativeName)
+	}
 }
 
 func TestParseDshowOutput_Empty(t *testing.T) {
@@ -73,3 +79,53 @@ func TestParseDshowOutput_Empty(t *testing.T) {
 		t.Errorf("got %d devices from empty output, want 0", len(devices))
 	}
 }
+
+func TestParseDshowOutput_AlternativeNames(t *testing.T) {
+	output := `[dshow @ 000001] "Integrated Camera" (video)
+[dshow @ 000001]   Alternative name "@device_pnp_\\?\usb#vid_04f2&pid_b604&mi_00#6&2b8b1b1b&0&0000#{65e8773d-8f56-11d0-a3b9-00a0c9223196}\global"
+[dshow @ 000001] "OBS Virtual Camera" (video)
+[dshow @ 000001] "Microphone (Realtek Audio)" (audio)
+[dshow @ 000001]   Alternative name "@device_cm_{33D9A762-90C8-11D0-BD43-00A0C911CE86}\wave_{0F2B1A6E-0000-0000-0000-000000000000}"
+`
+	devices := parseDshowOutput(output)
+	if len(devices) != 3 || devices[0].AlternativeName == "" || devices[1].AlternativeName != "" || devices[2].AlternativeName == "" {
+		t.Fatalf("devices = %+v", devices)
+	}
+	if got := ffmpegDeviceName(devices[0]); got != devices[0].AlternativeName {
+		t.Errorf("ffmpegDeviceName = %q, want the alternative name", got)
+	}
+	if got := ffmpegDeviceName(devices[1]); got != "OBS Virtual Camera" {
+		t.Errorf("ffmpegDeviceName = %q, want the friendly name", got)
+	}
+
+	id, ok := dshowInstanceID(devices[0].AlternativeName)
+	if !ok || id != `usb\vid_04f2&pid_b604&mi_00\6&2b8b1b1b&0&0000` {
+		t.Errorf("dshowInstanceID = %q, %v", id, ok)
+	}
+	if _, ok := dshowInstanceID(devices[2].AlternativeName); ok {
+		t.Error("instance ID derived from a non-PnP moniker")
+	}
+}
+
+func TestParseDshowOutput_IdenticalCameras(t *testing.T) {
+	output := `[dshow @ 000001] "USB Camera" (video)
+[dshow @ 000001]   Alternative name "@device_pnp_\\?\usb#vid_046d&pid_0825&mi_00#7&1a2b&0&0000#{65e8773d-8f56-11d0-a3b9-00a0c9223196}\global"
+[dshow @ 000001] "USB Camera" (video)
+[dshow @ 000001]   Alternative name "@device_pnp_\\?\usb#vid_046d&pid_0825&mi_00#7&3c4d&0&0000#{65e8773d-8f56-11d0-a3b9-00a0c9223196}\global"
+`
+	devices := parseDshowOutput(output)
+	if len(devices) != 2 {
+		t.Fatalf("got %d devices, want 2", len(devices))
+	}
+	if devices[0].DeviceID == devices[1].DeviceID {
+		t.Error("identical cameras share a DeviceID")
+	}
+	a, b := ffmpegDeviceName(devices[0]), ffmpegDeviceName(devices[1])
+	if a == b || !strings.Contains(a, "7&1a2b") || !strings.Contains(b, "7&3c4d") {
+		t.Errorf("FFmpeg names = %q, %q, want distinct alternative names", a, b)
+	}
+	args := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: b, Width: 640, Height: 480, FrameRate: 30}), " ")
+	if !strings.Contains(args, "video="+b) {
+		t.Errorf("args = %s, want input video=%s", args, b)
+	}
+}
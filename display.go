@@ -0,0 +1,494 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"log"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 无法获取桌面尺寸且未指定输出尺寸时，整个桌面缩放到的默认尺寸。
+const (
+	defaultDisplayWidth  = 1920
+	defaultDisplayHeight = 1080
+)
+
+// windowPollInterval 是检查被捕获窗口尺寸变化的间隔。
+const windowPollInterval = 500 * time.Millisecond
+
+// DisplaySurface 表示可捕获画面的类型。
+// 对应 MDN 的 MediaTrackSettings.displaySurface。
+type DisplaySurface string
+
+const (
+	// DisplaySurfaceMonitor 表示整个桌面或单个显示器。
+	DisplaySurfaceMonitor DisplaySurface = "monitor"
+	// DisplaySurfaceWindow 表示单个应用程序窗口。
+	DisplaySurfaceWindow DisplaySurface = "window"
+)
+
+// desktopSourceID 是整个桌面的捕获源 ID。
+const desktopSourceID = "desktop"
+
+// DisplaySource 描述一个可供 GetDisplayMedia 捕获的画面来源，
+// 相当于浏览器屏幕共享选择器中的一项。
+type DisplaySource struct {
+	// ID 用于 DisplayMediaConstraints.SourceID。
+	ID string `json:"id"`
+	// Surface 来源类型。
+	Surface DisplaySurface `json:"surface"`
+	// Title 可读名称（显示器名称或窗口标题）。
+	Title string `json:"title"`
+	// Handle 窗口的原生句柄（Windows 上为 HWND，Linux 上为 X11 窗口 ID）。
+	Handle uintptr `json:"handle,omitempty"`
+	// Bounds 枚举时在桌面上的位置和尺寸（像素）。
+	// macOS 不提供显示器的位置，各显示器均从 (0, 0) 开始。
+	Bounds image.Rectangle `json:"bounds"`
+	// Primary 表示主显示器。
+	Primary bool `json:"primary"`
+
+	screen string // macOS 上 AVFoundation 的屏幕序号
+}
+
+// monitor 是平台枚举出的单个显示器。
+type monitor struct {
+	name    string
+	bounds  image.Rectangle
+	primary bool
+	screen  string // 仅 macOS：AVFoundation 的屏幕序号
+}
+
+// windowSource 构造窗口类型的 DisplaySource。
+func windowSource(handle uintptr, title string, bounds image.Rectangle) DisplaySource {
+	return DisplaySource{
+		ID:      fmt.Sprintf("window:%#x", handle),
+		Surface: DisplaySurfaceWindow,
+		Title:   title,
+		Handle:  handle,
+		Bounds:  bounds,
+	}
+}
+
+// GetDisplaySources 列出可捕获的画面来源：整个桌面、各个显示器（ID 为
+// "monitor:1"、"monitor:2"……）以及各个可见的应用程序窗口。
+//
+// 显示器枚举在 Windows 上使用 EnumDisplayMonitors，在 Linux 上需要 xrandr，
+// 在 macOS 上使用 system_profiler。窗口枚举在 Windows 上使用 EnumWindows，
+// 在 Linux 上需要 wmctrl；macOS 不支持窗口捕获。窗口枚举失败（如未安装 wmctrl）时
+// 仍返回桌面和显示器，错误仅在 Config.Verbose 时记录。
+func GetDisplaySources() ([]DisplaySource, error) {
+	return defaultMediaDevices.GetDisplaySources()
+}
+
+// GetDisplaySources 与包级函数 GetDisplaySources 相同，但枚举工具的超时和日志使用 m 的配置。
+func (m *MediaDevices) GetDisplaySources() ([]DisplaySource, error) {
+	return displaySources(m.Config(), enumerateMonitors, enumerateWindows)
+}
+
+// displaySources 是 GetDisplaySources 的实现，显示器和窗口分别由 listMonitors 和
+// listWindows 在 cfg 的发现超时内枚举，cfg 决定是否记录窗口枚举的错误。
+func displaySources(cfg Config, listMonitors func(time.Duration) ([]monitor, error), listWindows func(time.Duration) ([]DisplaySource, error)) ([]DisplaySource, error) {
+	monitors, err := listMonitors(cfg.discoveryTimeout())
+	if err != nil {
+		return nil, fmt.Errorf("getDisplaySources: %w", err)
+	}
+	sources := buildMonitorSources(monitors)
+	windows, err := listWindows(cfg.discoveryTimeout())
+	if err != nil {
+		if cfg.Verbose {
+			log.Printf("ffmpeg: getDisplaySources: windows: %v", err)
+		}
+		return sources, nil
+	}
+	return append(sources, windows...), nil
+}
+
+// monitorSources 返回整个桌面及各显示器的捕获来源，xrandr 等工具受 cfg 的发现超时限制。
+func monitorSources(cfg Config) ([]DisplaySource, error) {
+	monitors, err := enumerateMonitors(cfg.discoveryTimeout())
+	if err != nil {
+		return nil, err
+	}
+	return buildMonitorSources(monitors), nil
+}
+
+// buildMonitorSources 由显示器列表构造整个桌面及各显示器的捕获来源。
+func buildMonitorSources(monitors []monitor) []DisplaySource {
+	desktop := DisplaySource{ID: desktopSourceID, Surface: DisplaySurfaceMonitor, Title: "Entire Screen"}
+	sources := []DisplaySource{desktop}
+	for i, m := range monitors {
+		sources = append(sources, DisplaySource{
+			ID:      fmt.Sprintf("monitor:%d", i+1),
+			Surface: DisplaySurfaceMonitor,
+			Title:   m.name,
+			Bounds:  m.bounds,
+			Primary: m.primary,
+			screen:  m.screen,
+		})
+		// 桌面为所有显示器的并集；macOS 上 AVFoundation 的桌面只有主显示器
+		if m.screen == "" {
+			desktop.Bounds = desktop.Bounds.Union(m.bounds)
+		} else if m.primary {
+			desktop.Bounds = m.bounds
+		}
+	}
+	sources[0] = desktop
+	return sources
+}
+
+// DisplayMediaConstraints 指定屏幕捕获的参数。
+// 对应 MDN 的 getDisplayMedia() 的 options。
+type DisplayMediaConstraints struct {
+	// SourceID 选择要捕获的来源（见 GetDisplaySources），如 "monitor:2"。
+	// 空表示整个桌面。
+	SourceID string
+	// WindowTitle 按标题选择要捕获的窗口（完全匹配），优先于 SourceID。
+	WindowTitle string
+	// Region 仅捕获桌面上的这个矩形（桌面像素坐标，可为负以覆盖主显示器
+	// 左侧或上方的显示器）。零值表示捕获整个来源。
+	// 捕获单个显示器时坐标相对于该显示器；不能与窗口捕获同时使用。
+	Region image.Rectangle
+	// Width、Height 输出帧尺寸，nil 表示与 Region（或窗口、显示器、桌面）
+	// 相同；无法获取桌面尺寸时默认 1920x1080。
+	Width  *int
+	Height *int
+	// FrameRate 捕获帧率，默认 30。
+	FrameRate *float64
+	// DrawCursor 是否在画面中绘制鼠标指针，默认 true。
+	// 所有捕获后端（gdigrab、ddagrab、x11grab、AVFoundation）均支持。
+	DrawCursor *bool
+	// Backend 选择屏幕捕获后端，空表示平台默认。
+	// 仅 Windows 可选 DisplayCaptureBackendDDAGrab（GPU 捕获，适合 4K 60fps），
+	// 它只能捕获单个显示器（SourceID 为 "monitor:N"），不支持窗口捕获。
+	Backend DisplayCaptureBackend
+}
+
+// GetDisplayMedia 捕获屏幕内容，返回包含一条视频轨道的 MediaStream。
+// 对应 MDN 的 navigator.mediaDevices.getDisplayMedia()。
+//
+// 后端为 Windows 的 gdigrab、Linux 的 x11grab（需要 X11 会话）和
+// macOS 的 AVFoundation（需要屏幕录制权限）。
+//
+// 捕获窗口时画面跟随窗口移动；窗口尺寸变化时会以新尺寸重启捕获，
+// 画面按比例缩放并加黑边，输出尺寸保持不变。窗口关闭后轨道结束。
+//
+// 示例：
+//
+//	// 仅捕获桌面左上角 1280x720 的区域
+//	stream, err := mediadevices.GetDisplayMedia(mediadevices.DisplayMediaConstraints{
+//	    Region: image.Rect(0, 0, 1280, 720),
+//	})
+//
+//	// 捕获指定窗口
+//	stream, err := mediadevices.GetDisplayMedia(mediadevices.DisplayMediaConstraints{
+//	    WindowTitle: "Untitled - Notepad",
+//	})
+func GetDisplayMedia(constraints DisplayMediaConstraints) (*MediaStream, error) {
+	return defaultMediaDevices.GetDisplayMediaContext(context.Background(), constraints)
+}
+
+// GetDisplayMedia 与包级函数 GetDisplayMedia 相同，但使用 m 的配置捕获，轨道登记在 m 中。
+func (m *MediaDevices) GetDisplayMedia(constraints DisplayMediaConstraints) (*MediaStream, error) {
+	return m.GetDisplayMediaContext(context.Background(), constraints)
+}
+
+// GetDisplayMediaContext 与 GetDisplayMedia 相同，但准备过程受 ctx 控制。
+// ctx 在准备过程中被取消或超时时，已启动的 FFmpeg 进程被停止，
+// 返回包装了 ctx.Err() 的错误。成功返回后取消 ctx 不影响流。
+func GetDisplayMediaContext(ctx context.Context, constraints DisplayMediaConstraints) (*MediaStream, error) {
+	return defaultMediaDevices.GetDisplayMediaContext(ctx, constraints)
+}
+
+// GetDisplayMediaContext 与包级函数 GetDisplayMediaContext 相同，但使用 m 的配置捕获，
+// 轨道登记在 m 中。
+func (m *MediaDevices) GetDisplayMediaContext(ctx context.Context, constraints DisplayMediaConstraints) (*MediaStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+	cfg := m.Config()
+	src, err := selectDisplaySource(cfg, constraints)
+	if err != nil {
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+	params, err := resolveDisplayParams(cfg, constraints, src)
+	if err != nil {
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+
+	label := displayLabel(params.Region)
+	if src.ID != desktopSourceID && constraints.Region.Empty() {
+		label = src.Title
+	}
+	args, err := m.maskVideoArgs(ctx, "", displayDevicePrefix+src.ID, buildDisplayCaptureArgs(params))
+	if err != nil {
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+	reader, err := m.newVideoReaderFromArgs(label, args, params.Width, params.Height, nil)
+	if err != nil {
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+	if err := ctx.Err(); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("getDisplayMedia: %w", err)
+	}
+	reader.frameRate = params.FrameRate
+	if params.Window != 0 {
+		go followWindow(reader.proc, params.Window)
+	}
+
+	track := m.resources.addTrack(&MediaStreamTrack{
+		id:          generateTrackID(),
+		kind:        MediaDeviceKindVideoInput,
+		label:       label,
+		readyState:  MediaStreamTrackStateLive,
+		videoReader: reader,
+	})
+	return newMediaStreamWithTracks(track), nil
+}
+
+// selectDisplaySource 根据约束选择捕获来源，枚举工具受 cfg 的发现超时限制。
+func selectDisplaySource(cfg Config, c DisplayMediaConstraints) (DisplaySource, error) {
+	desktop := c.WindowTitle == "" && (c.SourceID == "" || c.SourceID == desktopSourceID)
+	var sources []DisplaySource
+	var err error
+	if c.WindowTitle != "" || strings.HasPrefix(c.SourceID, "window:") {
+		sources, err = enumerateWindows(cfg.discoveryTimeout())
+	} else {
+		sources, err = monitorSources(cfg)
+	}
+	if err != nil {
+		if desktop {
+			// 捕获整个桌面不依赖显示器枚举，只是无法得知桌面尺寸
+			return DisplaySource{ID: desktopSourceID, Surface: DisplaySurfaceMonitor}, nil
+		}
+		return DisplaySource{}, err
+	}
+	if desktop {
+		return sources[0], nil
+	}
+	for _, s := range sources {
+		if c.WindowTitle != "" && s.Surface == DisplaySurfaceWindow && s.Title == c.WindowTitle ||
+			c.WindowTitle == "" && s.ID == c.SourceID {
+			return s, nil
+		}
+	}
+	if c.WindowTitle != "" {
+		return DisplaySource{}, fmt.Errorf("%w: no window titled %q", ErrNotFound, c.WindowTitle)
+	}
+	return DisplaySource{}, fmt.Errorf("%w: no display source %s", ErrNotFound, c.SourceID)
+}
+
+// resolveDisplayParams 将约束转换为捕获来源 src 的捕获参数并填充默认值，
+// 延迟配置和时间戳取自 cfg。
+func resolveDisplayParams(cfg Config, c DisplayMediaConstraints, src DisplaySource) (DisplayCaptureParams, error) {
+	p := DisplayCaptureParams{
+		Region:     c.Region.Canon(),
+		FrameRate:  30,
+		DrawCursor: true,
+		Profile:    cfg.LatencyProfile,
+
+		UseWallclockTimestamps: cfg.UseWallclockTimestamps,
+	}
+	if _, err := p.Profile.settings(); err != nil {
+		return p, err
+	}
+	if c.FrameRate != nil {
+		p.FrameRate = *c.FrameRate
+	}
+	if c.DrawCursor != nil {
+		p.DrawCursor = *c.DrawCursor
+	}
+	if err := selectDisplayBackend(&p, c.Backend, src); err != nil {
+		return p, err
+	}
+
+	if src.Surface == DisplaySurfaceWindow {
+		if !p.Region.Empty() {
+			return p, fmt.Errorf("region cannot be combined with window capture")
+		}
+		// 以当前窗口尺寸为准，同时确认窗口仍然存在且平台支持窗口捕获
+		bounds, err := windowBounds(cfg.discoveryTimeout(), src.Handle)
+		if err != nil {
+			return p, err
+		}
+		p.Window = src.Handle
+		src.Bounds = bounds
+	} else if src.ID != desktopSourceID {
+		p.Display = src.screen
+		// macOS 按屏幕序号捕获，其余平台截取显示器在虚拟桌面中的区域
+		if src.screen == "" {
+			if p.Region.Empty() {
+				p.Region = src.Bounds
+			} else if p.Region = p.Region.Add(src.Bounds.Min).Intersect(src.Bounds); p.Region.Empty() {
+				return p, fmt.Errorf("region %v is outside monitor %s", c.Region, src.ID)
+			}
+		}
+	}
+
+	// YUV420p 要求偶数尺寸
+	switch {
+	case p.Window != 0:
+		p.Width, p.Height = src.Bounds.Dx()&^1, src.Bounds.Dy()&^1
+	case !p.Region.Empty():
+		p.Width, p.Height = p.Region.Dx()&^1, p.Region.Dy()&^1
+	case !src.Bounds.Empty():
+		p.Width, p.Height = src.Bounds.Dx()&^1, src.Bounds.Dy()&^1
+	default:
+		p.Width, p.Height = defaultDisplayWidth, defaultDisplayHeight
+	}
+	if p.Backend == DisplayCaptureBackendDDAGrab && !p.Region.Empty() {
+		// ddagrab 的区域相对于所捕获的显示器
+		p.Region = p.Region.Sub(src.Bounds.Min)
+	}
+	if c.Width != nil {
+		p.Width = *c.Width
+	}
+	if c.Height != nil {
+		p.Height = *c.Height
+	}
+	if p.Width <= 0 || p.Height <= 0 {
+		return p, fmt.Errorf("output width and height must be positive (got %dx%d)", p.Width, p.Height)
+	}
+	if p.FrameRate <= 0 {
+		return p, fmt.Errorf("frame rate must be positive (got %g)", p.FrameRate)
+	}
+	return p, nil
+}
+
+// selectDisplayBackend 校验并设置捕获后端；ddagrab 需要 Windows 和单个显示器。
+func selectDisplayBackend(p *DisplayCaptureParams, backend DisplayCaptureBackend, src DisplaySource) error {
+	switch backend {
+	case "":
+		return nil
+	case DisplayCaptureBackendGDIGrab, DisplayCaptureBackendDDAGrab:
+		if runtime.GOOS != "windows" {
+			return fmt.Errorf("display capture backend %s is only available on Windows", backend)
+		}
+	default:
+		return fmt.Errorf("unknown display capture backend %q", backend)
+	}
+	p.Backend = backend
+	if backend != DisplayCaptureBackendDDAGrab {
+		return nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimPrefix(src.ID, "monitor:"))
+	if src.Surface != DisplaySurfaceMonitor || !strings.HasPrefix(src.ID, "monitor:") || err != nil || n < 1 {
+		return fmt.Errorf("ddagrab captures a single monitor; select a \"monitor:N\" source")
+	}
+	// 假定 DXGI 输出与 EnumDisplayMonitors 的顺序一致
+	p.Output = n - 1
+	return nil
+}
+
+// displayLabel 返回屏幕捕获轨道的标签。
+func displayLabel(region image.Rectangle) string {
+	if region.Empty() {
+		return "Screen"
+	}
+	return fmt.Sprintf("Screen (%dx%d+%d,%d)", region.Dx(), region.Dy(), region.Min.X, region.Min.Y)
+}
+
+// followWindow 轮询被捕获窗口的尺寸，变化时以相同参数重启 FFmpeg，
+// 使其按新尺寸捕获（gdigrab 和 x11grab 只在启动时读取窗口尺寸）。
+// 窗口移动无需处理，两者都按窗口而非屏幕坐标捕获。
+// 捕获停止后返回。
+func followWindow(src *captureSource, handle uintptr) {
+	timeout := src.cfg.discoveryTimeout()
+	var size image.Point
+	if bounds, err := windowBounds(timeout, handle); err == nil {
+		size = bounds.Size()
+	}
+	ticker := time.NewTicker(windowPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-src.stop:
+			return
+		case <-ticker.C:
+		}
+		bounds, err := windowBounds(timeout, handle)
+		if err != nil || bounds.Empty() || bounds.Size() == size {
+			// 窗口关闭或最小化：保持现状，由 FFmpeg 结束或继续捕获
+			continue
+		}
+		size = bounds.Size()
+		if err := src.reopen(); err != nil && src.cfg.Verbose {
+			log.Printf("ffmpeg: restart capture of resized window %#x: %v", handle, err)
+		}
+	}
+}
+
+// displayDevicePrefix 是作为视频输入设备枚举的屏幕捕获来源的 DeviceID 前缀，
+// 其后为 DisplaySource.ID，如 "display:monitor:1"。
+const displayDevicePrefix = "display:"
+
+// isDisplayDevice 判断设备是否为屏幕捕获来源。
+func isDisplayDevice(d MediaDeviceInfo) bool {
+	return strings.HasPrefix(d.DeviceID, displayDevicePrefix)
+}
+
+// withDisplayDevices 在 cfg 启用 Config.EnumerateDisplays 时，在设备列表末尾追加屏幕捕获来源。
+// 不修改 devices（可能是缓存）的底层数组。屏幕来源不缓存，枚举失败时忽略。
+func withDisplayDevices(cfg Config, devices []MediaDeviceInfo) []MediaDeviceInfo {
+	if !cfg.EnumerateDisplays {
+		return devices
+	}
+	sources, err := displaySources(cfg, enumerateMonitors, enumerateWindows)
+	if err != nil {
+		if cfg.Verbose {
+			log.Printf("ffmpeg: display sources: %v", err)
+		}
+		// 没有显示器信息时仍可捕获整个桌面
+		sources = []DisplaySource{{ID: desktopSourceID, Surface: DisplaySurfaceMonitor, Title: "Entire Screen"}}
+	}
+	devices = slices.Clip(devices)
+	for _, s := range sources {
+		id := displayDevicePrefix + s.ID
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   id,
+			DeviceName: id,
+			GroupID:    displayDevicePrefix + string(s.Surface),
+			Kind:       MediaDeviceKindVideoInput,
+			Label:      s.Title,
+		})
+	}
+	return devices
+}
+
+// newDisplayVideoReader 以摄像头的方式打开屏幕捕获来源 sourceID（DisplaySource.ID），
+// 输出 width x height 的帧，供 NewVideoReader、GetUserMedia 等使用。
+func (m *MediaDevices) newDisplayVideoReader(sourceID string, width, height int, frameRate float64, hook func([]string) []string) (*VideoReader, error) {
+	c := DisplayMediaConstraints{SourceID: sourceID, Width: &width, Height: &height, FrameRate: &frameRate}
+	gcfg := m.Config()
+	src, err := selectDisplaySource(gcfg, c)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	params, err := resolveDisplayParams(gcfg, c, src)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	args, err := m.maskVideoArgs(context.Background(), "", displayDevicePrefix+sourceID, buildDisplayCaptureArgs(params))
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	r, err := m.newVideoReaderFromArgs(displayDevicePrefix+sourceID, args, params.Width, params.Height, hook)
+	if err != nil {
+		return nil, err
+	}
+	r.frameRate = params.FrameRate
+	if params.Window != 0 {
+		go followWindow(r.proc, params.Window)
+	}
+	return r, nil
+}
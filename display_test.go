@@ -0,0 +1,147 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"image"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestResolveDisplayParams(t *testing.T) {
+	p, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Region: image.Rect(100, 50, 741, 531)}, DisplaySource{ID: desktopSourceID, Surface: DisplaySurfaceMonitor})
+	if err != nil {
+		t.Fatalf("resolveDisplayParams: %v", err)
+	}
+	if p.Width != 640 || p.Height != 480 {
+		t.Errorf("output size = %dx%d, want region size rounded to even 640x480", p.Width, p.Height)
+	}
+	if p.FrameRate != 30 || !p.DrawCursor {
+		t.Errorf("defaults = %g fps, mouse %v", p.FrameRate, p.DrawCursor)
+	}
+
+	p, _ = resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Width: IntPtr(1280), Height: IntPtr(720), DrawCursor: BoolPtr(false)}, DisplaySource{ID: desktopSourceID, Surface: DisplaySurfaceMonitor})
+	if !p.Region.Empty() || p.Width != 1280 || p.Height != 720 || p.DrawCursor {
+		t.Errorf("whole desktop params = %+v", p)
+	}
+
+	window := DisplaySource{Surface: DisplaySurfaceWindow, Handle: 1}
+	if _, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Region: image.Rect(0, 0, 64, 64)}, window); err == nil {
+		t.Error("region accepted for window capture")
+	}
+
+	if _, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Region: image.Rect(0, 0, 1, 1)}, DisplaySource{ID: desktopSourceID, Surface: DisplaySurfaceMonitor}); err == nil {
+		t.Error("1x1 region accepted")
+	}
+}
+
+func TestResolveDisplayParams_Monitor(t *testing.T) {
+	mon := DisplaySource{ID: "monitor:2", Surface: DisplaySurfaceMonitor, Bounds: image.Rect(-2560, 0, 0, 1440)}
+
+	p, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{}, mon)
+	if err != nil {
+		t.Fatalf("resolveDisplayParams: %v", err)
+	}
+	if p.Region != mon.Bounds || p.Width != 2560 || p.Height != 1440 {
+		t.Errorf("whole monitor params = %+v", p)
+	}
+
+	// Region is relative to the monitor and clipped to it.
+	p, _ = resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Region: image.Rect(2000, 100, 3000, 500)}, mon)
+	if p.Region != image.Rect(-560, 100, 0, 500) || p.Width != 560 || p.Height != 400 {
+		t.Errorf("monitor region params = %+v", p)
+	}
+	if _, err := resolveDisplayParams(GetConfig(), DisplayMediaConstraints{Region: image.Rect(3000, 0, 3100, 100)}, mon); err == nil {
+		t.Error("region outside monitor accepted")
+	}
+
+	// macOS selects the screen instead of a desktop region.
+	mac := DisplaySource{ID: "monitor:2", Surface: DisplaySurfaceMonitor, Bounds: image.Rect(0, 0, 3840, 2160), screen: "1"}
+	p, _ = resolveDisplayParams(GetConfig(), DisplayMediaConstraints{}, mac)
+	if p.Display != "1" || !p.Region.Empty() || p.Width != 3840 || p.Height != 2160 {
+		t.Errorf("macOS monitor params = %+v", p)
+	}
+}
+
+func TestSelectDisplayBackend(t *testing.T) {
+	var p DisplayCaptureParams
+	mon := DisplaySource{ID: "monitor:1", Surface: DisplaySurfaceMonitor}
+	if err := selectDisplayBackend(&p, "dxgi", mon); err == nil {
+		t.Error("unknown backend accepted")
+	}
+	err := selectDisplayBackend(&p, DisplayCaptureBackendDDAGrab, mon)
+	if runtime.GOOS != "windows" && err == nil {
+		t.Errorf("ddagrab accepted on %s", runtime.GOOS)
+	}
+	if runtime.GOOS == "windows" && (err != nil || p.Output != 0) {
+		t.Errorf("ddagrab on monitor:1: output %d, %v", p.Output, err)
+	}
+}
+
+func TestDisplayLabel(t *testing.T) {
+	if got := displayLabel(image.Rectangle{}); got != "Screen" {
+		t.Errorf("label = %q", got)
+	}
+	if got := displayLabel(image.Rect(-1920, 0, 0, 1080)); got != "Screen (1920x1080+-1920,0)" {
+		t.Errorf("label = %q", got)
+	}
+}
+
+func TestEnumerateDisplays(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{{DeviceID: "cam-1", Kind: MediaDeviceKindVideoInput}}, nil
+	}
+	SetConfig(cfg)
+
+	devices, err := defaultMediaDevices.enumerateDevicesRaw(context.Background())
+	if err != nil || len(devices) != 1 {
+		t.Fatalf("devices without EnumerateDisplays = %+v, %v", devices, err)
+	}
+
+	cfg.EnumerateDisplays = true
+	SetConfig(cfg)
+	devices, err = defaultMediaDevices.enumerateDevicesRaw(context.Background())
+	if err != nil {
+		t.Fatalf("enumerateDevicesRaw: %v", err)
+	}
+	if len(devices) < 2 || devices[0].DeviceID != "cam-1" {
+		t.Fatalf("devices = %+v, want the camera followed by display sources", devices)
+	}
+	for _, d := range devices[1:] {
+		if !isDisplayDevice(d) || d.Kind != MediaDeviceKindVideoInput {
+			t.Errorf("display device = %+v", d)
+		}
+	}
+
+	d, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindVideoInput)
+	if err != nil || d.DeviceID != "cam-1" {
+		t.Errorf("default camera = %+v, %v; want cam-1", d, err)
+	}
+}
+
+func TestDisplaySources_WindowsBestEffort(t *testing.T) {
+	monitors := func(time.Duration) ([]monitor, error) {
+		return []monitor{{name: "HDMI-1", bounds: image.Rect(0, 0, 1920, 1080), primary: true}}, nil
+	}
+	noWindows := func(time.Duration) ([]DisplaySource, error) {
+		return nil, errors.New("list windows (is wmctrl installed?): executable file not found")
+	}
+	sources, err := displaySources(Config{}, monitors, noWindows)
+	if err != nil {
+		t.Fatalf("displaySources: %v", err)
+	}
+	if len(sources) != 2 || sources[0].ID != desktopSourceID || sources[1].ID != "monitor:1" {
+		t.Errorf("sources = %+v, want the desktop and monitor:1", sources)
+	}
+
+	windows := func(time.Duration) ([]DisplaySource, error) {
+		return []DisplaySource{windowSource(0x42, "Editor", image.Rect(0, 0, 800, 600))}, nil
+	}
+	if sources, err := displaySources(Config{}, monitors, windows); err != nil || len(sources) != 3 || sources[2].Title != "Editor" {
+		t.Errorf("sources = %+v, %v; want the desktop, monitor:1 and the window", sources, err)
+	}
+}
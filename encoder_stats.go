@@ -0,0 +1,225 @@
+package mediadevices
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultStatsWindow is the sliding window used by encoded-stream statistics
+// when none is configured.
+const defaultStatsWindow = 5 * time.Second
+
+// EncoderStats summarizes an encoded video stream over a sliding window,
+// for validating rate control (bitrate, GOP structure, frame sizes) in production.
+// Frame counts and sizes refer to access units (coded pictures), including
+// their SPS/PPS/SEI NAL units.
+type EncoderStats struct {
+	// Window is the span of time the windowed fields below cover.
+	Window time.Duration
+
+	// Bitrate is the realized bitrate in bits per second.
+	Bitrate float64
+	// FrameRate is the realized number of frames per second.
+	FrameRate float64
+	// KeyframeInterval is the mean number of frames between consecutive IDR
+	// frames, or 0 if fewer than two IDR frames fall within the window.
+	KeyframeInterval float64
+	// KeyframeIntervalDuration is the mean time between consecutive IDR frames.
+	KeyframeIntervalDuration time.Duration
+
+	// Frame size distribution in bytes.
+	FrameSizeMin  int
+	FrameSizeMax  int
+	FrameSizeMean int
+	FrameSizeP50  int
+	FrameSizeP95  int
+	// KeyframeSizeMean is the mean size of IDR frames in bytes.
+	KeyframeSizeMean int
+
+	// Lifetime totals since the reader was created.
+	TotalBytes     int64
+	TotalFrames    int64
+	TotalKeyframes int64
+
+	// Anomalies counts malformed data skipped while parsing the stream.
+	Anomalies ParseAnomalies
+}
+
+// frameRecord is one completed access unit in the statistics window.
+type frameRecord struct {
+	at   time.Time
+	size int
+	key  bool
+}
+
+// encoderStats accumulates per-frame records from a stream of NAL units.
+// A frame boundary is detected at every VCL NAL unit whose slice header
+// starts at macroblock 0; preceding non-VCL units count toward that frame.
+type encoderStats struct {
+	window time.Duration
+
+	mu      sync.Mutex
+	frames  []frameRecord
+	cur     frameRecord
+	started bool // cur has seen its first slice
+	prefix  int  // bytes of non-VCL units (SPS/PPS/SEI/AUD) awaiting the next frame
+
+	totalBytes     int64
+	totalFrames    int64
+	totalKeyframes int64
+}
+
+func newEncoderStats(window time.Duration) *encoderStats {
+	if window <= 0 {
+		window = defaultStatsWindow
+	}
+	return &encoderStats{window: window}
+}
+
+// observe accounts one NAL unit received at time now.
+func (s *encoderStats) observe(nal *NALUnit, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	size := len(nal.Data) + 4 // plus start code
+	s.totalBytes += int64(size)
+
+	switch {
+	case isFirstSliceOfPicture(nal):
+		if s.started {
+			s.finishFrame()
+		}
+		s.started = true
+		s.cur.at = now
+	case !isVCL(nal):
+		// Non-VCL units precede the slices of the picture they belong to.
+		s.prefix += size
+		return
+	}
+	s.cur.size += s.prefix + size
+	s.prefix = 0
+	if nal.Type == 5 { // IDR slice
+		s.cur.key = true
+	}
+	s.prune(now)
+}
+
+// finishFrame moves the current frame into the window. Caller holds s.mu.
+func (s *encoderStats) finishFrame() {
+	s.frames = append(s.frames, s.cur)
+	s.totalFrames++
+	if s.cur.key {
+		s.totalKeyframes++
+	}
+	s.cur = frameRecord{}
+}
+
+// prune drops frames older than the window. Caller holds s.mu.
+func (s *encoderStats) prune(now time.Time) {
+	cutoff := now.Add(-s.window)
+	i := 0
+	for i < len(s.frames) && s.frames[i].at.Before(cutoff) {
+		i++
+	}
+	if i > 0 {
+		s.frames = append(s.frames[:0], s.frames[i:]...)
+	}
+}
+
+// snapshot computes the statistics for the window ending at now.
+func (s *encoderStats) snapshot(now time.Time) EncoderStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now)
+
+	st := EncoderStats{
+		Window:         s.window,
+		TotalBytes:     s.totalBytes,
+		TotalFrames:    s.totalFrames,
+		TotalKeyframes: s.totalKeyframes,
+	}
+	n := len(s.frames)
+	if n == 0 {
+		return st
+	}
+
+	sizes := make([]int, n)
+	total, keyTotal, keyCount := 0, 0, 0
+	lastKey := -1
+	var keyGapFrames int
+	var keyGapTime time.Duration
+	keyGaps := 0
+	for i, f := range s.frames {
+		sizes[i] = f.size
+		total += f.size
+		if f.key {
+			keyTotal += f.size
+			keyCount++
+			if lastKey >= 0 {
+				keyGapFrames += i - lastKey
+				keyGapTime += f.at.Sub(s.frames[lastKey].at)
+				keyGaps++
+			}
+			lastKey = i
+		}
+	}
+
+	// Rates are computed over the observed span, which is shorter than the
+	// window right after startup.
+	span := s.window
+	if first := now.Sub(s.frames[0].at); first < span && first > 0 {
+		span = first
+	}
+	st.Bitrate = float64(total*8) / span.Seconds()
+	st.FrameRate = float64(n) / span.Seconds()
+
+	if keyGaps > 0 {
+		st.KeyframeInterval = float64(keyGapFrames) / float64(keyGaps)
+		st.KeyframeIntervalDuration = keyGapTime / time.Duration(keyGaps)
+	}
+	if keyCount > 0 {
+		st.KeyframeSizeMean = keyTotal / keyCount
+	}
+
+	sort.Ints(sizes)
+	st.FrameSizeMin = sizes[0]
+	st.FrameSizeMax = sizes[n-1]
+	st.FrameSizeMean = total / n
+	st.FrameSizeP50 = sizes[(n-1)*50/100]
+	st.FrameSizeP95 = sizes[(n-1)*95/100]
+	return st
+}
+
+// isFirstSliceOfPicture reports whether nal carries a slice header (non-IDR
+// slice, data partition A or IDR slice) whose first_mb_in_slice is 0, i.e.
+// the start of a new picture. first_mb_in_slice is ue(v) coded, so it is 0
+// exactly when the first bit of the slice header is 1.
+func isFirstSliceOfPicture(nal *NALUnit) bool {
+	if len(nal.Data) < 2 {
+		return false
+	}
+	switch nal.Type {
+	case 1, 2, 5:
+		return nal.Data[1]&0x80 != 0
+	default:
+		return false
+	}
+}
+
+// isVCL reports whether nal carries coded slice data (types 1-5).
+func isVCL(nal *NALUnit) bool {
+	return nal.Type >= 1 && nal.Type <= 5
+}
+
+// startsAccessUnit reports whether nal begins a new access unit: an access
+// unit delimiter, SEI, SPS or PPS (types 6-9 and 14-18 precede the first
+// slice of a picture), or the first slice of a new picture.
+func startsAccessUnit(nal *NALUnit) bool {
+	switch {
+	case nal.Type >= 6 && nal.Type <= 9, nal.Type >= 14 && nal.Type <= 18:
+		return true
+	default:
+		return isFirstSliceOfPicture(nal)
+	}
+}
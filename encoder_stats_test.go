@@ -0,0 +1,102 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+)
+
+// statsNAL builds a NAL unit of the given type and total payload size whose
+// slice header (if any) starts a new picture.
+func statsNAL(typ H264NaluType, size int) *NALUnit {
+	data := make([]byte, size)
+	data[0] = byte(typ)
+	data[1] = 0x80 // first_mb_in_slice = 0
+	return &NALUnit{Type: typ, Data: data}
+}
+
+func TestEncoderStats(t *testing.T) {
+	s := newEncoderStats(10 * time.Second)
+	base := time.Unix(1000, 0)
+
+	// 30 frames at 10 fps, IDR every 10 frames. Keyframes carry SPS+PPS.
+	for i := 0; i < 30; i++ {
+		at := base.Add(time.Duration(i) * 100 * time.Millisecond)
+		if i%10 == 0 {
+			s.observe(statsNAL(NALUTypeSPS, 16), at)
+			s.observe(statsNAL(NALUTypePPS, 8), at)
+			s.observe(statsNAL(5, 996), at)
+		} else {
+			s.observe(statsNAL(NALUTypeSlice, 196), at)
+		}
+	}
+	// Start of frame 31 completes frame 30.
+	s.observe(statsNAL(NALUTypeSlice, 196), base.Add(3*time.Second))
+
+	st := s.snapshot(base.Add(3 * time.Second))
+	if st.TotalFrames != 30 || st.TotalKeyframes != 3 {
+		t.Fatalf("totals = %d frames, %d keyframes; want 30, 3", st.TotalFrames, st.TotalKeyframes)
+	}
+	if st.FrameRate < 9.9 || st.FrameRate > 10.1 {
+		t.Errorf("FrameRate = %.2f, want 10", st.FrameRate)
+	}
+	if st.KeyframeInterval != 10 {
+		t.Errorf("KeyframeInterval = %v, want 10", st.KeyframeInterval)
+	}
+	if st.KeyframeIntervalDuration != time.Second {
+		t.Errorf("KeyframeIntervalDuration = %v, want 1s", st.KeyframeIntervalDuration)
+	}
+	// Key frames: 20+12+1000 bytes (with start codes); others 200.
+	if st.FrameSizeMin != 200 || st.FrameSizeMax != 1032 || st.KeyframeSizeMean != 1032 {
+		t.Errorf("sizes min=%d max=%d keymean=%d; want 200, 1032, 1032", st.FrameSizeMin, st.FrameSizeMax, st.KeyframeSizeMean)
+	}
+	if st.FrameSizeP50 != 200 || st.FrameSizeP95 != 1032 {
+		t.Errorf("p50=%d p95=%d; want 200, 1032", st.FrameSizeP50, st.FrameSizeP95)
+	}
+	wantBitrate := float64((27*200+3*1032)*8) / 3
+	if st.Bitrate < wantBitrate*0.99 || st.Bitrate > wantBitrate*1.01 {
+		t.Errorf("Bitrate = %.0f, want %.0f", st.Bitrate, wantBitrate)
+	}
+}
+
+func TestEncoderStats_WindowExpires(t *testing.T) {
+	s := newEncoderStats(time.Second)
+	base := time.Unix(1000, 0)
+	s.observe(statsNAL(5, 100), base)
+	s.observe(statsNAL(NALUTypeSlice, 100), base.Add(100*time.Millisecond))
+
+	st := s.snapshot(base.Add(5 * time.Second))
+	if st.FrameRate != 0 || st.FrameSizeMax != 0 {
+		t.Errorf("expired window still reports frames: %+v", st)
+	}
+	if st.TotalFrames != 1 {
+		t.Errorf("TotalFrames = %d, want 1", st.TotalFrames)
+	}
+}
+
+func TestH264VideoReader_NextNAL(t *testing.T) {
+	r := &H264VideoReader{}
+	r.pending = []byte{
+		0, 0, 0, 1, 0x67, 0xAA, // SPS, 4-byte start code
+		0, 0, 1, 0x68, 0xBB, // PPS, 3-byte start code
+		0, 0, 0, 1, 0x65, 0x88, 0x01, // IDR, incomplete until EOF
+	}
+
+	sps := r.nextNAL(false)
+	if sps == nil || sps.Type != NALUTypeSPS || len(sps.Data) != 2 {
+		t.Fatalf("first NAL = %v, want SPS of 2 bytes", sps)
+	}
+	pps := r.nextNAL(false)
+	if pps == nil || pps.Type != NALUTypePPS || len(pps.Data) != 2 {
+		t.Fatalf("second NAL = %v, want PPS of 2 bytes (trailing zero stripped)", pps)
+	}
+	if nal := r.nextNAL(false); nal != nil {
+		t.Fatalf("incomplete NAL returned before EOF: %v", nal)
+	}
+	idr := r.nextNAL(true)
+	if idr == nil || idr.Type != 5 || len(idr.Data) != 3 || !idr.Keyframe {
+		t.Fatalf("final NAL = %v, want 3-byte IDR keyframe", idr)
+	}
+	if nal := r.nextNAL(true); nal != nil {
+		t.Fatalf("unexpected NAL after flush: %v", nal)
+	}
+}
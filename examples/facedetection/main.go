@@ -79,9 +79,9 @@ func main() {
 	// Request video access using GetUserMedia
 	stream, err := mediadevices.GetUserMedia(mediadevices.MediaTrackConstraints{
 		Video: &mediadevices.VideoTrackConstraints{
-			Width:    mediadevices.IntPtr(640),
-			Height:   mediadevices.IntPtr(480),
-			FrameRate: mediadevices.Float64Ptr(30.0),
+			Width:    mediadevices.IdealInt(640),
+			Height:   mediadevices.IdealInt(480),
+			FrameRate: mediadevices.IdealFloat64(30.0),
 		},
 	})
 	if err != nil {
@@ -0,0 +1,119 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// FacingMode 是摄像头相对用户的朝向，对应 W3C 的 VideoFacingModeEnum。
+type FacingMode string
+
+const (
+	// FacingModeUser 表示朝向用户的摄像头，如手机的前置摄像头和笔记本的内置摄像头。
+	FacingModeUser FacingMode = "user"
+	// FacingModeEnvironment 表示背向用户的摄像头，如手机的后置摄像头。
+	FacingModeEnvironment FacingMode = "environment"
+)
+
+// ConstrainFacingMode 是摄像头朝向的约束，对应 W3C 的 ConstrainDOMString。零值表示不约束。
+//
+// Exact 是必需约束：没有朝向相符的摄像头时 GetUserMedia 返回 *OverconstrainedError，
+// 朝向未知的摄像头不符合。Ideal 只是期望值：优先选择朝向相符的摄像头，没有时使用默认摄像头。
+// 浏览器中直接写 facingMode: "user" 相当于 IdealFacingMode(FacingModeUser)。
+type ConstrainFacingMode struct {
+	Ideal *FacingMode
+	Exact *FacingMode
+}
+
+// IdealFacingMode 返回期望朝向为 f 的约束。
+func IdealFacingMode(f FacingMode) ConstrainFacingMode {
+	return ConstrainFacingMode{Ideal: &f}
+}
+
+// ExactFacingMode 返回朝向必须为 f 的约束。
+func ExactFacingMode(f FacingMode) ConstrainFacingMode {
+	return ConstrainFacingMode{Exact: &f}
+}
+
+// empty 报告 c 是否不约束朝向。
+func (c ConstrainFacingMode) empty() bool {
+	return c.Ideal == nil && c.Exact == nil
+}
+
+// admits 报告朝向为 f 的摄像头是否满足必需约束。
+func (c ConstrainFacingMode) admits(f FacingMode) bool {
+	return c.Exact == nil || *c.Exact == f
+}
+
+// prefers 报告朝向为 f 的摄像头是否符合期望值。
+func (c ConstrainFacingMode) prefers(f FacingMode) bool {
+	return c.Ideal != nil && *c.Ideal == f
+}
+
+// facingLabelWords 是摄像头标签中表示朝向的词（小写）。Surface、iPad 和
+// Linux 手机把摄像头标为 "Front"/"Rear"/"Back"，笔记本的内置摄像头（"Integrated
+// Camera"、"FaceTime HD Camera"）朝向用户。
+var facingLabelWords = map[string]FacingMode{
+	"front":       FacingModeUser,
+	"user":        FacingModeUser,
+	"selfie":      FacingModeUser,
+	"facetime":    FacingModeUser,
+	"integrated":  FacingModeUser,
+	"back":        FacingModeEnvironment,
+	"rear":        FacingModeEnvironment,
+	"environment": FacingModeEnvironment,
+	"world":       FacingModeEnvironment,
+}
+
+// facingModeFromLabel 按摄像头标签中第一个表示朝向的词推测朝向，没有时返回空。
+func facingModeFromLabel(label string) FacingMode {
+	words := strings.FieldsFunc(strings.ToLower(label), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, w := range words {
+		if f, ok := facingLabelWords[w]; ok {
+			return f
+		}
+	}
+	return ""
+}
+
+// videoInputDevice 按约束 c 的 DeviceID 和 FacingMode 选择摄像头。
+// 指定了 DeviceID 时使用该设备，FacingMode 只检查必需约束；
+// 否则在朝向满足必需约束的摄像头中优先选择符合期望值的，再优先选择默认摄像头。
+func (m *MediaDevices) videoInputDevice(ctx context.Context, c *VideoTrackConstraints) (MediaDeviceInfo, error) {
+	if c.FacingMode.empty() || c.DeviceID != nil {
+		d, err := m.inputDevice(ctx, MediaDeviceKindVideoInput, c.DeviceID)
+		if err != nil {
+			return MediaDeviceInfo{}, err
+		}
+		if !c.FacingMode.admits(d.FacingMode) {
+			return MediaDeviceInfo{}, &OverconstrainedError{Constraint: "facingMode", Message: fmt.Sprintf("device %s does not face %s", d.DeviceID, *c.FacingMode.Exact)}
+		}
+		return d, nil
+	}
+
+	devices, err := m.devicesByKind(ctx, MediaDeviceKindVideoInput)
+	if err != nil {
+		return MediaDeviceInfo{}, fmt.Errorf("failed to get video devices: %w", err)
+	}
+	devices = slices.DeleteFunc(slices.Clone(devices), func(d MediaDeviceInfo) bool {
+		return isDisplayDevice(d) || !c.FacingMode.admits(d.FacingMode)
+	})
+	if preferred := slices.DeleteFunc(slices.Clone(devices), func(d MediaDeviceInfo) bool {
+		return !c.FacingMode.prefers(d.FacingMode)
+	}); len(preferred) > 0 {
+		devices = preferred
+	}
+	d, ok := pickDefaultDevice(devices)
+	if !ok {
+		if c.FacingMode.Exact != nil {
+			return MediaDeviceInfo{}, &OverconstrainedError{Constraint: "facingMode", Message: fmt.Sprintf("no camera faces %s", *c.FacingMode.Exact)}
+		}
+		return MediaDeviceInfo{}, fmt.Errorf("failed to get default video device: %w: no %s devices available", ErrNotFound, MediaDeviceKindVideoInput)
+	}
+	return d, nil
+}
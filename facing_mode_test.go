@@ -0,0 +1,81 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestFacingModeFromLabel(t *testing.T) {
+	for label, want := range map[string]FacingMode{
+		"Front Camera":               FacingModeUser,
+		"Microsoft Camera Rear":      FacingModeEnvironment,
+		"Integrated Webcam":          FacingModeUser,
+		"FaceTime HD Camera":         FacingModeUser,
+		"back-camera (ov8858)":       FacingModeEnvironment,
+		"Logitech BRIO":              "",
+		"Feedback Capture (Virtual)": "",
+		"":                           "",
+	} {
+		if got := facingModeFromLabel(label); got != want {
+			t.Errorf("facingModeFromLabel(%q) = %q, want %q", label, got, want)
+		}
+	}
+}
+
+func TestVideoInputDevice_FacingMode(t *testing.T) {
+	m := NewMediaDevices(Config{
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{
+				{DeviceID: "usb", DeviceName: "/dev/video0", Label: "USB Camera", Kind: MediaDeviceKindVideoInput, IsDefault: true},
+				{DeviceID: "rear", DeviceName: "/dev/video2", Label: "Rear Camera", Kind: MediaDeviceKindVideoInput},
+				{DeviceID: "front", DeviceName: "/dev/video4", Label: "Camera 2", Kind: MediaDeviceKindVideoInput, FacingMode: FacingModeUser},
+			}, nil
+		},
+	})
+	ctx := context.Background()
+	usb := "usb"
+	pick := func(c VideoTrackConstraints) (string, error) {
+		d, err := m.videoInputDevice(ctx, &c)
+		return d.DeviceID, err
+	}
+
+	// The label decides when discovery does not report the facing.
+	devices, _ := m.EnumerateDevices()
+	if devices[1].DeviceID != "rear" || devices[1].FacingMode != FacingModeEnvironment || devices[2].FacingMode != "" {
+		t.Errorf("devices = %+v", devices)
+	}
+
+	for _, tc := range []struct {
+		name string
+		c    VideoTrackConstraints
+		want string
+	}{
+		{"unconstrained", VideoTrackConstraints{}, "usb"},
+		{"ideal user", VideoTrackConstraints{FacingMode: IdealFacingMode(FacingModeUser)}, "front"},
+		{"exact environment", VideoTrackConstraints{FacingMode: ExactFacingMode(FacingModeEnvironment)}, "rear"},
+		{"device wins over ideal", VideoTrackConstraints{DeviceID: &usb, FacingMode: IdealFacingMode(FacingModeUser)}, "usb"},
+	} {
+		if got, err := pick(tc.c); err != nil || got != tc.want {
+			t.Errorf("%s: picked %q, %v; want %q", tc.name, got, err, tc.want)
+		}
+	}
+
+	// Without a camera facing that way, an ideal falls back to the default
+	// and an exact constraint fails.
+	m = NewMediaDevices(Config{
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: "usb", Label: "USB Camera", Kind: MediaDeviceKindVideoInput}}, nil
+		},
+	})
+	if got, err := pick(VideoTrackConstraints{FacingMode: IdealFacingMode(FacingModeEnvironment)}); err != nil || got != "usb" {
+		t.Errorf("ideal without a match: %q, %v", got, err)
+	}
+	var oc *OverconstrainedError
+	if _, err := pick(VideoTrackConstraints{FacingMode: ExactFacingMode(FacingModeEnvironment)}); !errors.As(err, &oc) || oc.Constraint != "facingMode" {
+		t.Errorf("exact without a match: %v", err)
+	}
+	if _, err := pick(VideoTrackConstraints{DeviceID: &usb, FacingMode: ExactFacingMode(FacingModeUser)}); !errors.As(err, &oc) || oc.Constraint != "facingMode" {
+		t.Errorf("exact on a device facing elsewhere: %v", err)
+	}
+}
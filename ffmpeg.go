@@ -11,19 +11,26 @@
 //	cfg.FFmpegPath = "/usr/local/bin/ffmpeg"
 //	mediadevices.SetConfig(cfg)
 //
-//	devices, err := mediadevices.DiscoverDevices()
+//	devices, err := mediadevices.VideoInputDevices()
 //	// pick a video device, then:
 //	reader, err := mediadevices.NewVideoReader(mediadevices.VideoConfig{
-//	    Device:    devices[0],
+//	    Device:    devices[0], // or DeviceID: "...", or neither for the default camera
 //	    Width:     1280,
 //	    Height:    720,
 //	    FrameRate: 30,
 //	})
 //	defer reader.Close()
 //	img, err := reader.Read()
+//
+// Devices are described by MediaDeviceInfo throughout the package. Most
+// applications use the browser-style API instead of readers:
+// EnumerateDevices, GetUserMedia and MediaStreamTrack.
 package mediadevices
 
-import "sync"
+import (
+	"context"
+	"time"
+)
 
 // Config holds global configuration for FFmpeg operations.
 type Config struct {
@@ -32,28 +39,164 @@ type Config struct {
 
 	// Verbose enables debug logging of FFmpeg stderr output.
 	Verbose bool
-}
 
-var (
-	globalConfig = Config{
-		FFmpegPath: "ffmpeg",
-	}
-	configMu sync.RWMutex
-)
+	// RedactLabels enables browser-style privacy mode for enumeration: until
+	// GetUserMedia has succeeded once, devices are returned with empty Label
+	// and DeviceName and an opaque GroupID, exposing only the DeviceID.
+	// GetUserMedia itself still resolves devices by DeviceID.
+	RedactLabels bool
+
+	// LogDir, if set, makes every FFmpeg subprocess tee its complete stderr
+	// to its own log file in this directory (created if missing), named
+	// ffmpeg-<start time>-<pid>.log. The in-memory stderr tail used in error
+	// messages is kept regardless. The command line at the top of each file
+	// has keys, passwords and URL credentials replaced by REDACTED.
+	LogDir string
+
+	// LogMaxSize is the size in bytes at which a subprocess log is rotated.
+	// Defaults to 10 MiB.
+	LogMaxSize int64
+
+	// LogMaxBackups is the number of rotated log files (.1, .2, ...) kept per
+	// subprocess. Defaults to 3.
+	LogMaxBackups int
+
+	// StderrBufferSize is the number of bytes of stderr kept in memory per
+	// subprocess, as returned by StderrLines and reported in CaptureError
+	// and StallEvent. Defaults to 4096; raise it for encoders whose verbose
+	// diagnostics would otherwise be cut off.
+	StderrBufferSize int
+
+	// StallTimeout enables the capture watchdog when positive. If a capture
+	// process stays alive but a read waits this long for data, the process
+	// is killed and restarted with the same arguments. Time in which the
+	// application does not read is not counted. Zero disables the watchdog.
+	StallTimeout time.Duration
+
+	// OnStall, if set, is called from the watchdog goroutine every time a
+	// stalled capture is restarted (or a restart attempt fails).
+	OnStall func(StallEvent)
+
+	// OnClockMismatch, if set, is called when an audio capture delivers
+	// samples at a rate that differs from the requested one by more than
+	// 2%, measured against the wall clock. Such devices play back too fast
+	// or too slow ("chipmunk" audio) in recordings.
+	OnClockMismatch func(ClockMismatchEvent)
+
+	// CorrectClockMismatch restarts an audio capture with a clock-rate
+	// mismatch so that its samples are resampled from the measured rate to
+	// the requested one.
+	CorrectClockMismatch bool
+
+	// LatencyProfile selects capture buffering ("realtime", "balanced" or
+	// "archive") for all captures, and is the default profile of encoders.
+	// Empty keeps FFmpeg's defaults.
+	LatencyProfile LatencyProfile
+
+	// UseWallclockTimestamps makes captures stamp their input with the
+	// system clock (-use_wallclock_as_timestamps 1) instead of the device
+	// clock, and report the capture time of each frame and audio chunk
+	// (MediaStreamTrack.FrameTimestamp, AudioChunk.Timestamp). Use it when
+	// combining streams from separate devices.
+	UseWallclockTimestamps bool
+
+	// DiscoverDevices, if set, replaces the platform device discovery
+	// (DirectShow, AVFoundation, V4L2/ALSA). It is called on every
+	// enumeration instead of the cached discovery, and its result is sorted
+	// and de-duplicated like that of the built-in discovery. It is meant for
+	// tests; see the mediadevicestest package.
+	DiscoverDevices func(ctx context.Context) ([]MediaDeviceInfo, error)
+
+	// DeviceCacheTTL is how long the result of device discovery is reused
+	// by EnumerateDevices and GetUserMedia. Zero caches it until
+	// RefreshDevices is called.
+	DeviceCacheTTL time.Duration
 
-// SetConfig updates the global FFmpeg configuration.
+	// DiscoveryTimeout bounds device discovery and capability queries whose
+	// context has no deadline, each discovery backend (DirectShow,
+	// AVFoundation, V4L2, ALSA, ...) and every helper tool run during
+	// discovery, such as xrandr, wmctrl or system_profiler. Zero uses 10
+	// seconds for the whole discovery and 5 seconds per backend. Raise it
+	// for drivers that are slow to enumerate; a hung driver then blocks
+	// enumeration for at most this long.
+	DiscoveryTimeout time.Duration
+
+	// PrewarmTimeout is how long a device opened by Prewarm is kept open
+	// while no capture takes it over. Zero uses 30 seconds.
+	PrewarmTimeout time.Duration
+
+	// EnumerateDisplays adds the screens and windows returned by
+	// GetDisplaySources to EnumerateDevices as video inputs with IDs of the
+	// form "display:<source ID>", so GetUserMedia and NewVideoReader can
+	// capture them like cameras. They are never chosen as the default camera.
+	EnumerateDisplays bool
+
+	// SyntheticDevices adds FFmpeg's lavfi test sources to EnumerateDevices:
+	// the video inputs "lavfi:testsrc2" and "lavfi:smptebars" and the audio
+	// inputs "lavfi:sine" (440 Hz) and "lavfi:anullsrc" (silence). They
+	// capture like real devices, so CI and examples run without a camera or
+	// microphone, and are the defaults only when no real device exists.
+	SyntheticDevices bool
+
+	// ArgsHook, if set, receives the complete argument list of every FFmpeg
+	// process started for capture or encoding (not for device discovery)
+	// and returns the arguments to run instead. It is the last chance to
+	// work around an unusual device without forking the argument builders,
+	// and runs again when a process is restarted. Readers can add their own
+	// hook, which runs after this one.
+	ArgsHook func(args []string) []string
+
+	// PrivacyMasks maps a DeviceID (or the FFmpeg device name) to regions
+	// that FFmpeg blacks out in every frame of that camera or screen before
+	// the frames reach Go code, encoders or files: raw readers and tracks,
+	// H264 readers and composite tracks. A capture with invalid masks fails
+	// to start instead of running unmasked.
+	PrivacyMasks map[string][]PrivacyMask
+
+	// InputFormats maps a DeviceID (or the FFmpeg device name) to the FFmpeg
+	// input format that captures of that device use instead of the
+	// platform's default, such as vfwcap instead of dshow or pipewire
+	// instead of v4l2, for drivers that misbehave with the default. Device
+	// discovery and capability queries still use the default.
+	InputFormats map[string]InputFormat
+
+	// DevicePreferences, if set, remembers the user's preferred camera and
+	// microphone for GetUserMediaPreferred; see NewFilePreferenceStore.
+	DevicePreferences DevicePreferenceStore
+
+	// DeviceFilter hides devices from enumeration and device selection by
+	// include and exclude patterns, for example virtual cameras such as "OBS
+	// Virtual Camera", and maps aliases such as "front-door" to DeviceIDs
+	// wherever a DeviceID is accepted; see LoadDeviceFilter.
+	DeviceFilter DeviceFilter
+
+	// owner is the MediaDevices this configuration was read from, whose
+	// device cache and resource registry its captures use. It is set by
+	// MediaDevices.Config and MediaDevices.SetConfig; nil means the default
+	// instance.
+	owner *MediaDevices
+
+	// stderrFeed, if set, receives the stderr lines of the processes
+	// started with this configuration; see the StderrLines methods.
+	stderrFeed *stderrFeed
+}
+
+// SetConfig updates the global FFmpeg configuration, that of the default
+// MediaDevices.
 func SetConfig(cfg Config) {
-	configMu.Lock()
-	defer configMu.Unlock()
-	if cfg.FFmpegPath == "" {
-		cfg.FFmpegPath = "ffmpeg"
-	}
-	globalConfig = cfg
+	defaultMediaDevices.SetConfig(cfg)
 }
 
-// GetConfig returns a copy of the current global FFmpeg configuration.
+// GetConfig returns a copy of the current global FFmpeg configuration, that
+// of the default MediaDevices.
 func GetConfig() Config {
-	configMu.RLock()
-	defer configMu.RUnlock()
-	return globalConfig
+	return defaultMediaDevices.Config()
+}
+
+// mediaDevices returns the MediaDevices the configuration belongs to.
+func (cfg Config) mediaDevices() *MediaDevices {
+	if cfg.owner == nil {
+		return defaultMediaDevices
+	}
+	return cfg.owner
 }
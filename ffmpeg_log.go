@@ -0,0 +1,201 @@
+package mediadevices
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultLogMaxSize is the size at which a subprocess log file is rotated
+	// when Config.LogMaxSize is zero.
+	defaultLogMaxSize = 10 << 20 // 10 MiB
+	// defaultLogMaxBackups is the number of rotated files kept when
+	// Config.LogMaxBackups is zero.
+	defaultLogMaxBackups = 3
+)
+
+// rotatingFile is an io.WriteCloser that writes to path and rotates it to
+// path.1, path.2, ... once it grows beyond maxSize bytes, keeping at most
+// maxBackups rotated files.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// openRotatingFile creates (or truncates) path for writing.
+func openRotatingFile(path string, maxSize int64, maxBackups int) (*rotatingFile, error) {
+	if maxSize <= 0 {
+		maxSize = defaultLogMaxSize
+	}
+	if maxBackups <= 0 {
+		maxBackups = defaultLogMaxBackups
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return &rotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		f:          f,
+	}, nil
+}
+
+// Write appends p to the current file, rotating first if p would push the
+// file past maxSize. A single write is never split across files.
+func (w *rotatingFile) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.f == nil {
+		return 0, os.ErrClosed
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// rotate shifts path.N-1 -> path.N, ..., path -> path.1 and reopens path.
+// The oldest backup beyond maxBackups is discarded. Caller holds w.mu.
+func (w *rotatingFile) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+
+	os.Remove(fmt.Sprintf("%s.%d", w.path, w.maxBackups))
+	for i := w.maxBackups - 1; i >= 1; i-- {
+		os.Rename(fmt.Sprintf("%s.%d", w.path, i), fmt.Sprintf("%s.%d", w.path, i+1))
+	}
+	if err := os.Rename(w.path, w.path+".1"); err != nil {
+		return err
+	}
+
+	f, err := os.Create(w.path)
+	if err != nil {
+		return err
+	}
+	w.f = f
+	w.size = 0
+	return nil
+}
+
+// Close closes the current file.
+func (w *rotatingFile) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+// openProcessLog creates the stderr log for an FFmpeg subprocess in cfg.LogDir
+// and writes a header with the start time and the command line, with secrets
+// redacted. It returns nil if logging is disabled.
+func openProcessLog(cfg Config, pid int, args []string) (*rotatingFile, error) {
+	if cfg.LogDir == "" {
+		return nil, nil
+	}
+	if err := os.MkdirAll(cfg.LogDir, 0o755); err != nil {
+		return nil, fmt.Errorf("ffmpeg log dir: %w", err)
+	}
+
+	now := time.Now()
+	name := fmt.Sprintf("ffmpeg-%s-%d.log", now.Format("20060102-150405.000"), pid)
+	w, err := openRotatingFile(filepath.Join(cfg.LogDir, name), cfg.LogMaxSize, cfg.LogMaxBackups)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg log file: %w", err)
+	}
+
+	fmt.Fprintf(w, "# started %s pid=%d\n# %s %s\n", now.Format(time.RFC3339Nano), pid, cfg.FFmpegPath, strings.Join(redactArgs(args), " "))
+	return w, nil
+}
+
+// redacted replaces secrets in logged command lines.
+const redacted = "REDACTED"
+
+// secretOptions are FFmpeg options whose value is a key, a password or may
+// carry credentials.
+var secretOptions = map[string]bool{
+	"-encryption_key":  true,
+	"-decryption_key":  true,
+	"-passphrase":      true,
+	"-password":        true,
+	"-srtp_in_params":  true,
+	"-srtp_out_params": true,
+	"-headers":         true,
+}
+
+// secretQueryParams are URL query parameters whose value is a secret, as in
+// srt://host:port?passphrase=....
+var secretQueryParams = []string{"passphrase", "password", "token"}
+
+// redactArgs returns a copy of args with the values of secret options and
+// the credentials in URLs replaced by redacted.
+func redactArgs(args []string) []string {
+	out := make([]string, len(args))
+	for i, arg := range args {
+		if i > 0 && secretOptions[args[i-1]] {
+			out[i] = redacted
+			continue
+		}
+		out[i] = redactURL(arg)
+	}
+	return out
+}
+
+// redactURL replaces the userinfo and secret query parameters of a URL. An
+// argument that looks like a URL with credentials but does not parse is
+// redacted entirely.
+func redactURL(arg string) string {
+	if !strings.Contains(arg, "://") {
+		return arg
+	}
+	u, err := url.Parse(arg)
+	if err != nil {
+		if strings.Contains(arg, "@") {
+			return redacted
+		}
+		return arg
+	}
+	changed := false
+	if u.User != nil {
+		u.User = url.User(redacted)
+		changed = true
+	}
+	if u.RawQuery != "" {
+		q := u.Query()
+		for _, k := range secretQueryParams {
+			if q.Has(k) {
+				q.Set(k, redacted)
+				changed = true
+			}
+		}
+		if changed {
+			u.RawQuery = q.Encode()
+		}
+	}
+	if !changed {
+		return arg
+	}
+	return u.String()
+}
@@ -0,0 +1,98 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrCommandsUnsupported is returned when sending a filter command to an
+// FFmpeg process that was not started with a command channel.
+var ErrCommandsUnsupported = errors.New("ffmpeg: process does not accept commands")
+
+// FilterCommand is a runtime command for a filter of a running FFmpeg
+// process, as accepted by avfilter_graph_send_command: for example
+// {Target: "drawtext@title", Command: "reinit", Arg: "text='Live'"},
+// {Target: "crop@pan", Command: "x", Arg: "320"} or
+// {Target: "equalizer@eq", Command: "g", Arg: "6"}. Name filter instances
+// with "filter@name" in the filter chain to address them.
+type FilterCommand struct {
+	// Target is the filter instance name, or "all" for every filter that
+	// supports Command.
+	Target string
+	// Command is the filter-specific command, usually an option name.
+	Command string
+	// Arg is the command argument, such as the new option value.
+	Arg string
+}
+
+// line returns the command in the form read by FFmpeg's interactive "c"
+// key: "<target> <time> <command>[ <argument>]", with time -1 to apply it
+// immediately.
+func (c FilterCommand) line() (string, error) {
+	switch {
+	case c.Target == "" || c.Command == "":
+		return "", errors.New("ffmpeg: filter command needs a target and a command")
+	case strings.ContainsAny(c.Target, " \t\r\n") || len(c.Target) > 63:
+		return "", fmt.Errorf("ffmpeg: invalid filter command target %q", c.Target)
+	case strings.ContainsAny(c.Command, " \t\r\n") || len(c.Command) > 255:
+		return "", fmt.Errorf("ffmpeg: invalid filter command %q", c.Command)
+	case strings.ContainsAny(c.Arg, "\r\n") || len(c.Arg) > 255:
+		return "", fmt.Errorf("ffmpeg: invalid filter command argument %q", c.Arg)
+	}
+	line := c.Target + " -1 " + c.Command
+	if c.Arg != "" {
+		line += " " + c.Arg
+	}
+	return line + "\n", nil
+}
+
+// SendCommand sends a filter command to the process: to its zmq filter if
+// it has one, waiting for the reply, and otherwise through FFmpeg's
+// interactive stdin channel. The latter requires a stdin pipe that carries
+// no media data (see startInteractiveProcess); FFmpeg then applies the
+// command asynchronously and reports failures only on stderr.
+func (p *ffmpegProcess) SendCommand(c FilterCommand) error {
+	if p.zmq != nil {
+		_, err := p.zmq.Send(c)
+		return err
+	}
+	line, err := c.line()
+	if err != nil {
+		return err
+	}
+	if p.stdin == nil || !p.interactive {
+		return ErrCommandsUnsupported
+	}
+	p.stdinMu.Lock()
+	defer p.stdinMu.Unlock()
+	if _, err := p.stdin.Write([]byte("c" + line)); err != nil {
+		return fmt.Errorf("ffmpeg: send filter command: %w", err)
+	}
+	return nil
+}
+
+// SendCommand changes a filter of the running encoder without restarting
+// it, for example to update a drawtext overlay or move a crop window.
+// Filters are added with H264ReaderConfig.VideoFilter. With
+// H264ReaderConfig.ZMQControl the command is acknowledged by FFmpeg and a
+// rejected command returns a *FilterCommandError. Once SetResolution has
+// started a replacement encoder, the command goes to it, but it is not
+// replayed to encoders started later, which begin with the configured
+// filter options.
+func (r *H264VideoReader) SendCommand(c FilterCommand) error {
+	r.mu.Lock()
+	proc := r.proc
+	if r.next != nil {
+		// The encoder it replaces has been stopped.
+		proc = r.next
+	}
+	r.mu.Unlock()
+	return proc.SendCommand(c)
+}
+
+// SendCommand changes a filter of the running encoder; see
+// H264VideoReader.SendCommand.
+func (r *RTPReader) SendCommand(c FilterCommand) error {
+	return r.reader.SendCommand(c)
+}
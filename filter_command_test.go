@@ -0,0 +1,35 @@
+package mediadevices
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFilterCommand_Line(t *testing.T) {
+	line, err := FilterCommand{Target: "crop@pan", Command: "x", Arg: "320"}.line()
+	if err != nil || line != "crop@pan -1 x 320\n" {
+		t.Errorf("line = %q, %v", line, err)
+	}
+	if line, _ := (FilterCommand{Target: "all", Command: "reinit"}).line(); line != "all -1 reinit\n" {
+		t.Errorf("line without argument = %q", line)
+	}
+	for _, c := range []FilterCommand{
+		{Command: "x"},
+		{Target: "crop@pan"},
+		{Target: "crop pan", Command: "x"},
+		{Target: "crop@pan", Command: "x y"},
+		{Target: "crop@pan", Command: "x", Arg: "1\nq"},
+		{Target: "crop@pan", Command: "x", Arg: strings.Repeat("1", 256)},
+	} {
+		if _, err := c.line(); err == nil {
+			t.Errorf("%+v accepted", c)
+		}
+	}
+}
+
+func TestBuildH264Args_VideoFilter(t *testing.T) {
+	args := strings.Join(buildH264Args(H264ReaderConfig{DeviceName: "cam", Width: 640, Height: 480, VideoFilter: "drawtext@title=text=x"}), " ")
+	if !strings.Contains(args, "-vf scale=640:480,drawtext@title=text=x ") {
+		t.Errorf("args = %s", args)
+	}
+}
@@ -0,0 +1,294 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// FrameCacheConfig 配置 NewFrameCache。
+type FrameCacheConfig struct {
+	// Dir 是保存 JPEG 帧的目录，必填，不存在时创建。目录由缓存独占：
+	// 其中已有的帧文件在启动时被载入索引，并同样受容量限制。
+	Dir string
+	// Width 和 Height 是缩放后的尺寸。都为 0 时宽度为 320（源更窄时不放大），
+	// 只给出其一时另一个按源的宽高比计算。
+	Width, Height int
+	// Interval 是写入帧的最小间隔，默认 1 秒。其间读到的帧被丢弃。
+	Interval time.Duration
+	// Quality 是 JPEG 质量（1-100），默认 75。
+	Quality int
+	// MaxAge 是帧的保留时长，默认 10 分钟。
+	MaxAge time.Duration
+	// MaxBytes 是所有帧文件的总大小上限，默认 64 MiB。超出时删除最旧的帧。
+	MaxBytes int64
+}
+
+// CachedFrame 是帧缓存中的一帧。
+type CachedFrame struct {
+	// Time 是帧的捕获时间：启用 Config.UseWallclockTimestamps 时为 FrameTimestamp，
+	// 否则为读取到帧的时间。
+	Time time.Time
+	// Path 是 JPEG 文件的路径。帧可能随时因超出容量被删除，打开失败时应重新查询。
+	Path string
+	// Size 是文件大小（字节）。
+	Size int64
+}
+
+// FrameCache 将视频轨道的缩小帧持续写入容量有限的磁盘目录，并按时间戳建立索引，
+// 使操作人员即使没有开启完整录像也能回看最近的画面。由 NewFrameCache 创建。
+type FrameCache struct {
+	track *MediaStreamTrack
+	cfg   FrameCacheConfig
+	now   func() time.Time // 帧没有时间戳时的时钟
+
+	mu     sync.Mutex
+	frames []CachedFrame // 按时间排序
+	bytes  int64
+
+	stopc    chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	err      error // 写入循环的第一个错误，done 关闭后可读
+}
+
+// frameCacheExt 是帧文件的扩展名。文件名为捕获时间的 Unix 纳秒数，如 "1760799845123456789.jpg"，
+// 因此目录本身就是索引，重启后可以恢复。
+const frameCacheExt = ".jpg"
+
+// NewFrameCache 开始将视频轨道 track 的帧按 cfg 缩小后以 JPEG 写入 cfg.Dir。
+//
+// 缓存期间轨道由 FrameCache 读取，应用程序不应同时调用其 Read；
+// 若轨道还要用于录像或显示，传入它的 AnalysisStream 派生轨道。
+// 轨道结束时写入随之结束，已缓存的帧仍可查询；调用 Stop 停止写入，轨道本身不会被停止。
+func NewFrameCache(track *MediaStreamTrack, cfg FrameCacheConfig) (*FrameCache, error) {
+	return newFrameCache(track, cfg, time.Now)
+}
+
+// newFrameCache 与 NewFrameCache 相同，但以 now 作为时钟。
+func newFrameCache(track *MediaStreamTrack, cfg FrameCacheConfig, now func() time.Time) (*FrameCache, error) {
+	if track == nil || track.Kind() != MediaDeviceKindVideoInput {
+		return nil, errors.New("frame cache: track is not a video track")
+	}
+	if cfg.Dir == "" {
+		return nil, errors.New("frame cache: Dir is required")
+	}
+	if cfg.Width < 0 || cfg.Height < 0 {
+		return nil, fmt.Errorf("frame cache: invalid size %dx%d", cfg.Width, cfg.Height)
+	}
+	if cfg.Quality < 0 || cfg.Quality > 100 {
+		return nil, fmt.Errorf("frame cache: invalid JPEG quality %d", cfg.Quality)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = time.Second
+	}
+	if cfg.Quality == 0 {
+		cfg.Quality = 75
+	}
+	if cfg.MaxAge <= 0 {
+		cfg.MaxAge = 10 * time.Minute
+	}
+	if cfg.MaxBytes <= 0 {
+		cfg.MaxBytes = 64 << 20
+	}
+	if err := os.MkdirAll(cfg.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("frame cache: %w", err)
+	}
+
+	c := &FrameCache{
+		track: track,
+		cfg:   cfg,
+		now:   now,
+		stopc: make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if err := c.load(); err != nil {
+		return nil, fmt.Errorf("frame cache: %w", err)
+	}
+	c.prune(now())
+	go c.run()
+	return c, nil
+}
+
+// Frames 返回捕获时间在 [from, to] 内的帧，按时间排序。零值的 from 或 to 表示不限。
+func (c *FrameCache) Frames(from, to time.Time) []CachedFrame {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var frames []CachedFrame
+	for _, f := range c.frames {
+		if (from.IsZero() || !f.Time.Before(from)) && (to.IsZero() || !f.Time.After(to)) {
+			frames = append(frames, f)
+		}
+	}
+	return frames
+}
+
+// FrameAt 返回时刻 t 显示的帧，即捕获时间不晚于 t 的最后一帧，用于拖动时间轴回看。
+// t 早于所有帧时返回 false。
+func (c *FrameCache) FrameAt(t time.Time) (CachedFrame, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	i, found := slices.BinarySearchFunc(c.frames, t, func(f CachedFrame, t time.Time) int {
+		return f.Time.Compare(t)
+	})
+	if found {
+		return c.frames[i], true
+	}
+	if i == 0 {
+		return CachedFrame{}, false
+	}
+	return c.frames[i-1], true
+}
+
+// Done 返回在写入结束（调用 Stop、轨道结束或写入出错）时关闭的通道。
+func (c *FrameCache) Done() <-chan struct{} {
+	return c.done
+}
+
+// Stop 停止写入，返回写入过程中发生的第一个错误。轨道正常结束不视为错误。
+// 已缓存的帧保留在磁盘上，仍可通过 Frames 和 FrameAt 查询。
+func (c *FrameCache) Stop() error {
+	c.stopOnce.Do(func() { close(c.stopc) })
+	<-c.done
+	return c.err
+}
+
+// run 读取轨道并按 Interval 写入帧，直到轨道结束、写入出错或 Stop 被调用。
+func (c *FrameCache) run() {
+	defer close(c.done)
+	var last time.Time
+	for {
+		select {
+		case <-c.stopc:
+			return
+		default:
+		}
+		img, err := c.track.Read()
+		if err != nil {
+			if err != io.EOF {
+				c.err = fmt.Errorf("frame cache: read video track: %w", err)
+			}
+			return
+		}
+		t := c.track.FrameTimestamp()
+		if t.IsZero() {
+			t = c.now()
+		}
+		if !last.IsZero() && t.Sub(last) < c.cfg.Interval {
+			continue
+		}
+		last = t
+
+		src := toYCbCr420(img)
+		b := src.Rect
+		w, h := frameCacheSize(b.Dx(), b.Dy(), c.cfg.Width, c.cfg.Height)
+		if err := c.write(scaleYCbCr420(src, w, h), t); err != nil {
+			c.err = fmt.Errorf("frame cache: %w", err)
+			return
+		}
+		c.prune(t)
+	}
+}
+
+// write 将帧编码为 JPEG 写入缓存目录并加入索引。先写入临时文件再重命名，
+// 因此索引中的文件总是完整的。
+func (c *FrameCache) write(img *image.YCbCr, t time.Time) error {
+	tmp, err := os.CreateTemp(c.cfg.Dir, ".frame-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := jpeg.Encode(tmp, img, &jpeg.Options{Quality: c.cfg.Quality}); err != nil {
+		tmp.Close()
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	path := filepath.Join(c.cfg.Dir, strconv.FormatInt(t.UnixNano(), 10)+frameCacheExt)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	f := CachedFrame{Time: t, Path: path, Size: info.Size()}
+	i, _ := slices.BinarySearchFunc(c.frames, t, func(f CachedFrame, t time.Time) int {
+		return f.Time.Compare(t)
+	})
+	c.frames = slices.Insert(c.frames, i, f)
+	c.bytes += f.Size
+	return nil
+}
+
+// prune 删除捕获时间早于 now 减 MaxAge 的帧，以及总大小超出 MaxBytes 时最旧的帧。
+func (c *FrameCache) prune(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cutoff := now.Add(-c.cfg.MaxAge)
+	n := 0
+	for n < len(c.frames) && (c.frames[n].Time.Before(cutoff) || c.bytes > c.cfg.MaxBytes) {
+		os.Remove(c.frames[n].Path)
+		c.bytes -= c.frames[n].Size
+		n++
+	}
+	c.frames = slices.Delete(c.frames, 0, n)
+}
+
+// load 将缓存目录中已有的帧文件载入索引，忽略其他文件。
+func (c *FrameCache) load() error {
+	entries, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		ns, err := strconv.ParseInt(strings.TrimSuffix(e.Name(), frameCacheExt), 10, 64)
+		if err != nil || !strings.HasSuffix(e.Name(), frameCacheExt) || !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		c.frames = append(c.frames, CachedFrame{
+			Time: time.Unix(0, ns),
+			Path: filepath.Join(c.cfg.Dir, e.Name()),
+			Size: info.Size(),
+		})
+		c.bytes += info.Size()
+	}
+	slices.SortFunc(c.frames, func(a, b CachedFrame) int { return a.Time.Compare(b.Time) })
+	return nil
+}
+
+// frameCacheSize 返回源尺寸 srcW x srcH 的帧缩放后的尺寸，规则见 FrameCacheConfig.Width。
+// 计算出的尺寸取偶数，以适合 4:2:0 采样。
+func frameCacheSize(srcW, srcH, w, h int) (int, int) {
+	if srcW <= 0 || srcH <= 0 {
+		return w, h
+	}
+	switch {
+	case w > 0 && h > 0:
+		return w, h
+	case w > 0:
+		return w, max(2, (w*srcH/srcW)&^1)
+	case h > 0:
+		return max(2, (h*srcW/srcH)&^1), h
+	}
+	w = min(320, srcW)
+	return w, max(2, (w*srcH/srcW)&^1)
+}
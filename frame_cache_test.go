@@ -0,0 +1,138 @@
+package mediadevices
+
+import (
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFrameCache_WritesScaledFrames(t *testing.T) {
+	n := 0
+	track, err := NewVideoTrackFromFunc(func() image.Image {
+		if n++; n > 10 {
+			return nil
+		}
+		return image.NewYCbCr(image.Rect(0, 0, 640, 480), image.YCbCrSubsampleRatio420)
+	}, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The frames have no timestamps, so the cache stamps them with its
+	// clock, which advances 10ms per call.
+	clock := time.Now()
+	now := func() time.Time {
+		clock = clock.Add(10 * time.Millisecond)
+		return clock
+	}
+	dir := filepath.Join(t.TempDir(), "frames")
+	c, err := newFrameCache(track, FrameCacheConfig{Dir: dir, Interval: 25 * time.Millisecond}, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-c.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("frame cache did not end with the track")
+	}
+	if err := c.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	frames := c.Frames(time.Time{}, time.Time{})
+	if len(frames) != 4 {
+		t.Fatalf("cached %d of 10 frames 10ms apart with a 25ms interval, want 4", len(frames))
+	}
+	for i := 1; i < len(frames); i++ {
+		if d := frames[i].Time.Sub(frames[i-1].Time); d != 30*time.Millisecond {
+			t.Errorf("frames %d and %d are %v apart, want 30ms", i-1, i, d)
+		}
+	}
+
+	f, err := os.Open(frames[0].Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	cfg, err := jpeg.DecodeConfig(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Width != 320 || cfg.Height != 240 {
+		t.Errorf("cached frame is %dx%d, want 320x240", cfg.Width, cfg.Height)
+	}
+
+	last := frames[len(frames)-1]
+	if got, ok := c.FrameAt(last.Time.Add(time.Millisecond)); !ok || got != last {
+		t.Errorf("FrameAt(after last) = %v, %v, want the last frame", got, ok)
+	}
+	if got, ok := c.FrameAt(frames[1].Time); !ok || got != frames[1] {
+		t.Errorf("FrameAt(frame 1) = %v, %v, want frame 1", got, ok)
+	}
+	if _, ok := c.FrameAt(frames[0].Time.Add(-time.Millisecond)); ok {
+		t.Error("FrameAt(before first) found a frame")
+	}
+	if got := c.Frames(frames[1].Time, last.Time); len(got) != len(frames)-1 {
+		t.Errorf("Frames(frame 1, last) returned %d frames, want %d", len(got), len(frames)-1)
+	}
+}
+
+func TestFrameCache_LoadAndPrune(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	write := func(name string, size int) {
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	frameName := func(t time.Time) string { return strconv.FormatInt(t.UnixNano(), 10) + frameCacheExt }
+	write(frameName(now.Add(-time.Hour)), 1000)
+	write(frameName(now.Add(-3*time.Second)), 1000)
+	write(frameName(now.Add(-2*time.Second)), 1000)
+	write(frameName(now.Add(-time.Second)), 1000)
+	write("notes.txt", 1000)
+
+	c := &FrameCache{cfg: FrameCacheConfig{Dir: dir, MaxAge: time.Minute, MaxBytes: 2500}}
+	if err := c.load(); err != nil {
+		t.Fatal(err)
+	}
+	if len(c.frames) != 4 || c.bytes != 4000 {
+		t.Fatalf("loaded %d frames of %d bytes, want 4 of 4000", len(c.frames), c.bytes)
+	}
+	c.prune(now)
+
+	// The hour-old frame is past MaxAge, and the oldest recent one exceeds MaxBytes.
+	if len(c.frames) != 2 || c.bytes != 2000 {
+		t.Fatalf("kept %d frames of %d bytes, want 2 of 2000", len(c.frames), c.bytes)
+	}
+	if !c.frames[0].Time.Equal(now.Add(-2 * time.Second)) {
+		t.Errorf("oldest kept frame is at %v, want 2s ago", now.Sub(c.frames[0].Time))
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 3 {
+		t.Errorf("%d files left in the cache directory, want 2 frames and notes.txt", len(entries))
+	}
+}
+
+func TestFrameCacheSize(t *testing.T) {
+	tests := []struct {
+		srcW, srcH, w, h int
+		wantW, wantH     int
+	}{
+		{1920, 1080, 0, 0, 320, 180},
+		{1280, 720, 640, 0, 640, 360},
+		{1280, 720, 0, 90, 160, 90},
+		{1280, 720, 200, 200, 200, 200},
+		{160, 120, 0, 0, 160, 120},
+		{1440, 1080, 0, 0, 320, 240},
+	}
+	for _, tt := range tests {
+		w, h := frameCacheSize(tt.srcW, tt.srcH, tt.w, tt.h)
+		if w != tt.wantW || h != tt.wantH {
+			t.Errorf("frameCacheSize(%dx%d, %dx%d) = %dx%d, want %dx%d", tt.srcW, tt.srcH, tt.w, tt.h, w, h, tt.wantW, tt.wantH)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package mediadevices
 
 import (
+	"context"
 	"fmt"
+	"time"
 )
 
 // GetUserMedia 请求用户授权并访问摄像头和/或麦克风。
@@ -12,6 +14,11 @@ import (
 //   - Audio: 设置 AudioTrackConstraints 来请求音频
 //   - 同时设置两者可以同时获取音视频
 //
+// 尺寸、帧率等约束按 W3C 的适应度距离算法匹配设备的真实捕获模式（见 VideoTrackConstraints）。
+// 失败的原因与浏览器 getUserMedia 拒绝时的 DOMException 对应：无法满足必需约束时
+// 返回的错误包装了 *OverconstrainedError（errors.As）；找不到设备、设备被占用或出错、
+// 系统拒绝访问时分别匹配 ErrNotFound、ErrNotReadable、ErrNotAllowed（errors.Is）。
+//
 // 返回包含请求轨道的 MediaStream。
 // 调用方应在使用完毕后调用 stream.Close() 释放资源。
 //
@@ -20,9 +27,9 @@ import (
 //	// 仅获取视频
 //	stream, err := mediadevices.GetUserMedia(mediadevices.MediaTrackConstraints{
 //	    Video: &mediadevices.VideoTrackConstraints{
-//	        Width:    IntPtr(1280),
-//	        Height:   IntPtr(720),
-//	        FrameRate: Float64Ptr(30.0),
+//	        Width:     mediadevices.IdealInt(1280),
+//	        Height:    mediadevices.IdealInt(720),
+//	        FrameRate: mediadevices.ConstrainDouble{Min: mediadevices.Float64Ptr(25)},
 //	    },
 //	})
 //
@@ -32,11 +39,35 @@ import (
 //	    Audio: &mediadevices.AudioTrackConstraints{...},
 //	})
 func GetUserMedia(constraints MediaTrackConstraints) (*MediaStream, error) {
+	return defaultMediaDevices.GetUserMediaContext(context.Background(), constraints)
+}
+
+// GetUserMedia 与包级函数 GetUserMedia 相同，但在 m 的设备中选择设备并使用 m 的配置捕获。
+func (m *MediaDevices) GetUserMedia(constraints MediaTrackConstraints) (*MediaStream, error) {
+	return m.GetUserMediaContext(context.Background(), constraints)
+}
+
+// GetUserMediaContext 与 GetUserMedia 相同，但设备查找和 FFmpeg 启动受 ctx 控制。
+// ctx 在准备过程中被取消或超时时，已启动的 FFmpeg 进程全部停止，
+// 返回包装了 ctx.Err() 的错误，不会泄漏子进程。
+//
+// ctx 只作用于准备阶段：成功返回后取消 ctx 不影响流，流的生命周期由 stream.Close() 控制。
+func GetUserMediaContext(ctx context.Context, constraints MediaTrackConstraints) (*MediaStream, error) {
+	return defaultMediaDevices.GetUserMediaContext(ctx, constraints)
+}
+
+// GetUserMediaContext 与包级函数 GetUserMediaContext 相同，
+// 但在 m 的设备中选择设备并使用 m 的配置捕获。
+func (m *MediaDevices) GetUserMediaContext(ctx context.Context, constraints MediaTrackConstraints) (*MediaStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("getUserMedia: %w", err)
+	}
+
 	var tracks []*MediaStreamTrack
 
 	// 请求视频
 	if constraints.Video != nil {
-		track, err := getVideoTrack(constraints.Video)
+		track, err := m.getVideoTrack(ctx, constraints.Video)
 		if err != nil {
 			// 清理已创建的轨道
 			for _, t := range tracks {
@@ -48,8 +79,8 @@ func GetUserMedia(constraints MediaTrackConstraints) (*MediaStream, error) {
 	}
 
 	// 请求音频
-	if constraints.Audio != nil {
-		track, err := getAudioTrack(constraints.Audio)
+	if constraints.Audio != nil && ctx.Err() == nil {
+		track, err := m.getAudioTrack(ctx, constraints.Audio)
 		if err != nil {
 			// 清理已创建的轨道
 			for _, t := range tracks {
@@ -60,109 +91,110 @@ func GetUserMedia(constraints MediaTrackConstraints) (*MediaStream, error) {
 		tracks = append(tracks, track)
 	}
 
+	// 准备期间被取消：停止已启动的进程
+	if err := ctx.Err(); err != nil {
+		for _, t := range tracks {
+			t.Stop()
+		}
+		return nil, fmt.Errorf("getUserMedia: %w", err)
+	}
+
 	if len(tracks) == 0 {
 		return nil, fmt.Errorf("getUserMedia: no constraints specified (neither video nor audio)")
 	}
 
+	// 与浏览器一致：成功获取媒体后，EnumerateDevices 开始返回设备标签
+	m.labelConsent.Store(true)
+
 	return newMediaStreamWithTracks(tracks...), nil
 }
 
 // getVideoTrack 根据约束创建视频轨道。
-func getVideoTrack(constraints *VideoTrackConstraints) (*MediaStreamTrack, error) {
-	// 获取设备
-	var deviceInfo MediaDeviceInfo
-	if constraints.DeviceID != nil {
-		// 使用指定的设备
-		devices, err := VideoInputDevices()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get video devices: %w", err)
-		}
-		found := false
-		for _, d := range devices {
-			if d.DeviceID == *constraints.DeviceID {
-				deviceInfo = d
-				found = true
-				break
-			}
-		}
-		if !found {
-			return nil, fmt.Errorf("video device not found: %s", *constraints.DeviceID)
-		}
-	} else {
-		// 使用默认设备（第一个可用的视频输入设备）
-		devices, err := VideoInputDevices()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get video devices: %w", err)
-		}
-		if len(devices) == 0 {
-			return nil, fmt.Errorf("no video input devices available")
-		}
-		deviceInfo = devices[0]
+func (m *MediaDevices) getVideoTrack(ctx context.Context, constraints *VideoTrackConstraints) (*MediaStreamTrack, error) {
+	deviceInfo, err := m.videoInputDevice(ctx, constraints)
+	if err != nil {
+		return nil, err
+	}
+	s, err := m.videoSettingsFor(ctx, deviceInfo, constraints)
+	if err != nil {
+		return nil, err
 	}
 
-	// 解析约束
-	width := 640
-	height := 480
-	frameRate := 30.0
+	return m.newVideoTrack(deviceInfo, s.width, s.height, s.frameRate)
+}
 
-	if constraints.Width != nil {
-		width = *constraints.Width
+// getAudioTrack 根据约束创建音频轨道。
+func (m *MediaDevices) getAudioTrack(ctx context.Context, constraints *AudioTrackConstraints) (*MediaStreamTrack, error) {
+	deviceInfo, err := m.inputDevice(ctx, MediaDeviceKindAudioInput, constraints.DeviceID)
+	if err != nil {
+		return nil, err
 	}
-	if constraints.Height != nil {
-		height = *constraints.Height
+	s, err := m.audioSettingsFor(ctx, deviceInfo, constraints)
+	if err != nil {
+		return nil, err
 	}
-	if constraints.FrameRate != nil {
-		frameRate = *constraints.FrameRate
+	cfg := AudioConfig{SampleRate: s.sampleRate, Channels: s.channels}
+	if constraints.Latency != nil {
+		cfg.Latency = *constraints.Latency
 	}
 
-	return newVideoTrack(deviceInfo, width, height, frameRate)
+	return m.newAudioTrack(deviceInfo, cfg)
 }
 
-// getAudioTrack 根据约束创建音频轨道。
-func getAudioTrack(constraints *AudioTrackConstraints) (*MediaStreamTrack, error) {
-	// 获取设备
-	var deviceInfo MediaDeviceInfo
-	if constraints.DeviceID != nil {
-		// 使用指定的设备
-		devices, err := AudioInputDevices()
-		if err != nil {
-			return nil, fmt.Errorf("failed to get audio devices: %w", err)
-		}
-		found := false
-		for _, d := range devices {
-			if d.DeviceID == *constraints.DeviceID {
-				deviceInfo = d
-				found = true
-				break
-			}
-		}
-		if !found {
-			return nil, fmt.Errorf("audio device not found: %s", *constraints.DeviceID)
-		}
-	} else {
-		// 使用默认设备（第一个可用的音频输入设备）
-		devices, err := AudioInputDevices()
+// inputDevice 返回 kind 类型中 ID 为 deviceID 的设备，deviceID 为 nil 时返回系统默认设备。
+func (m *MediaDevices) inputDevice(ctx context.Context, kind MediaDeviceKind, deviceID *string) (MediaDeviceInfo, error) {
+	name := "video"
+	if kind == MediaDeviceKindAudioInput {
+		name = "audio"
+	}
+	if deviceID == nil {
+		// 使用系统默认设备
+		d, err := m.defaultDevice(ctx, kind)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get audio devices: %w", err)
-		}
-		if len(devices) == 0 {
-			return nil, fmt.Errorf("no audio input devices available")
+			return MediaDeviceInfo{}, fmt.Errorf("failed to get default %s device: %w", name, err)
 		}
-		deviceInfo = devices[0]
+		return d, nil
 	}
 
-	// 解析约束
-	sampleRate := 48000
-	channels := 2
-
-	if constraints.SampleRate != nil {
-		sampleRate = *constraints.SampleRate
+	// 使用指定的设备
+	devices, err := m.devicesByKind(ctx, kind)
+	if err != nil {
+		return MediaDeviceInfo{}, fmt.Errorf("failed to get %s devices: %w", name, err)
+	}
+	d, found := m.findDevice(devices, *deviceID)
+	if !found {
+		return MediaDeviceInfo{}, fmt.Errorf("%s %w: %s", name, ErrNotFound, *deviceID)
 	}
-	if constraints.Channels != nil {
-		channels = *constraints.Channels
+	return d, nil
+}
+
+// videoSettingsFor 按约束选择设备 d 的捕获模式；查询失败（设备不支持列出模式等）时直接按约束取值。
+// 设备已预热时使用预热时查询到的模式，与 Prewarm 选择的设置一致。
+func (m *MediaDevices) videoSettingsFor(ctx context.Context, d MediaDeviceInfo, constraints *VideoTrackConstraints) (videoSettings, error) {
+	var modes []VideoMode
+	if caps, ok := m.prewarmedCapabilities(d); ok {
+		modes = caps.VideoModes
+	} else if !constraints.Width.double().empty() || !constraints.Height.double().empty() ||
+		!constraints.FrameRate.empty() || !constraints.AspectRatio.empty() {
+		if caps, err := m.GetDeviceCapabilitiesContext(ctx, d.DeviceID); err == nil {
+			modes = caps.VideoModes
+		}
 	}
+	return selectVideoSettings(constraints, modes)
+}
 
-	return newAudioTrack(deviceInfo, sampleRate, channels)
+// audioSettingsFor 在设备 d 报告了原生格式时按约束选择，否则直接按约束取值。
+// 设备已预热时使用预热时查询到的格式。
+func (m *MediaDevices) audioSettingsFor(ctx context.Context, d MediaDeviceInfo, constraints *AudioTrackConstraints) (audioSettings, error) {
+	var modes []AudioMode
+	if caps, ok := m.prewarmedCapabilities(d); ok {
+		modes = caps.AudioModes
+	} else if !constraints.SampleRate.double().empty() || !constraints.Channels.double().empty() {
+		if caps, err := m.GetDeviceCapabilitiesContext(ctx, d.DeviceID); err == nil {
+			modes = caps.AudioModes
+		}
+	}
+	return selectAudioSettings(constraints, modes)
 }
 
 // IntPtr 返回指向整数的指针。
@@ -171,6 +203,12 @@ func IntPtr(i int) *int {
 	return &i
 }
 
+// DurationPtr 返回指向 time.Duration 的指针。
+// 用于设置约束中的可选时长字段，如 AudioTrackConstraints.Latency。
+func DurationPtr(d time.Duration) *time.Duration {
+	return &d
+}
+
 // Float64Ptr 返回指向 float64 的指针。
 // 用于设置约束中的可选浮点数字段。
 func Float64Ptr(f float64) *float64 {
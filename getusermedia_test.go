@@ -0,0 +1,152 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestGetUserMediaContext_Canceled(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	discovered := 0
+	SetConfig(Config{
+		FFmpegPath: "/bin/sh",
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			discovered++
+			// The request is cancelled while the camera is being set up.
+			cancel()
+			return []MediaDeviceInfo{
+				{DeviceID: "cam", Kind: MediaDeviceKindVideoInput},
+				{DeviceID: "mic", Kind: MediaDeviceKindAudioInput},
+			}, nil
+		},
+	})
+
+	_, err := GetUserMediaContext(ctx, MediaTrackConstraints{
+		Video: &VideoTrackConstraints{},
+		Audio: &AudioTrackConstraints{},
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if discovered != 1 {
+		t.Errorf("discovery ran %d times, want 1 (audio setup skipped after cancellation)", discovered)
+	}
+
+	if _, err := GetUserMediaContext(ctx, MediaTrackConstraints{Video: &VideoTrackConstraints{}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled context: err = %v", err)
+	}
+	if discovered != 1 {
+		t.Error("discovery ran for an already cancelled request")
+	}
+}
+
+func TestGetUserMediaPreferred(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+
+	devices := []MediaDeviceInfo{
+		{DeviceID: "cam-1", Label: "Default Cam", Kind: MediaDeviceKindVideoInput, IsDefault: true},
+		{DeviceID: "cam-2", Label: "USB Cam", Kind: MediaDeviceKindVideoInput},
+	}
+	store := NewFilePreferenceStore(t.TempDir() + "/devices.json")
+	SetConfig(Config{
+		FFmpegPath: "/bin/sh",
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return devices, nil
+		},
+		DevicePreferences: store,
+	})
+	videoLabel := func(c MediaTrackConstraints) string {
+		t.Helper()
+		stream, err := GetUserMediaPreferred(c)
+		if err != nil {
+			t.Fatalf("GetUserMediaPreferred: %v", err)
+		}
+		defer stream.Close()
+		return stream.GetVideoTracks()[0].Label()
+	}
+
+	if got := videoLabel(MediaTrackConstraints{Video: &VideoTrackConstraints{}}); got != "Default Cam" {
+		t.Errorf("without preference: %q", got)
+	}
+	// An explicit choice is remembered...
+	id := "cam-2"
+	if got := videoLabel(MediaTrackConstraints{Video: &VideoTrackConstraints{DeviceID: &id}}); got != "USB Cam" {
+		t.Errorf("explicit device: %q", got)
+	}
+	// ...and used when no device is given.
+	if got := videoLabel(MediaTrackConstraints{Video: &VideoTrackConstraints{}}); got != "USB Cam" {
+		t.Errorf("preferred device: %q", got)
+	}
+
+	// An unplugged preferred device falls back to the default.
+	devices = devices[:1]
+	if got := videoLabel(MediaTrackConstraints{Video: &VideoTrackConstraints{}}); got != "Default Cam" {
+		t.Errorf("absent preferred device: %q", got)
+	}
+	if pref, _ := store.PreferredDevice(MediaDeviceKindVideoInput); pref != "cam-2" {
+		t.Errorf("fallback overwrote the preference: %q", pref)
+	}
+}
+
+func TestGetUserMedia_AudioLatency(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{
+		FFmpegPath:     "/bin/sh",
+		LatencyProfile: ProfileArchive,
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: "mic", Kind: MediaDeviceKindAudioInput}}, nil
+		},
+	})
+	latency := func(c *AudioTrackConstraints) time.Duration {
+		t.Helper()
+		stream, err := GetUserMedia(MediaTrackConstraints{Audio: c})
+		if err != nil {
+			t.Fatalf("GetUserMedia: %v", err)
+		}
+		defer stream.Close()
+		return stream.GetAudioTracks()[0].GetSettings().Latency
+	}
+
+	if got := latency(&AudioTrackConstraints{}); got != 40*time.Millisecond {
+		t.Errorf("default latency = %v, want the archive profile's 40ms", got)
+	}
+	if got := latency(&AudioTrackConstraints{Latency: DurationPtr(5 * time.Millisecond), SampleRate: IdealInt(16000)}); got != 5*time.Millisecond {
+		t.Errorf("latency = %v, want 5ms", got)
+	}
+	if _, err := GetUserMedia(MediaTrackConstraints{Audio: &AudioTrackConstraints{SampleSize: ExactInt(24)}}); err == nil {
+		t.Error("24-bit sample size accepted")
+	}
+	if _, err := GetUserMedia(MediaTrackConstraints{Audio: &AudioTrackConstraints{Latency: DurationPtr(time.Microsecond), SampleRate: IdealInt(8000)}}); err == nil {
+		t.Error("latency shorter than a sample accepted")
+	}
+}
+
+func TestGetUserMedia_NotFound(t *testing.T) {
+	devices := []MediaDeviceInfo{{DeviceID: "mic", Kind: MediaDeviceKindAudioInput}}
+	m := NewMediaDevices(Config{
+		FFmpegPath:      "/bin/sh",
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) { return devices, nil },
+	})
+	missing := "gone"
+	for name, c := range map[string]MediaTrackConstraints{
+		"no camera":       {Video: &VideoTrackConstraints{}},
+		"unknown device":  {Audio: &AudioTrackConstraints{DeviceID: &missing}},
+		"no facing match": {Video: &VideoTrackConstraints{FacingMode: IdealFacingMode(FacingModeUser)}},
+	} {
+		if _, err := m.GetUserMedia(c); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s: err = %v, want ErrNotFound", name, err)
+		}
+	}
+	if _, err := m.GetDeviceCapabilities(missing); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetDeviceCapabilities: err = %v, want ErrNotFound", err)
+	}
+}
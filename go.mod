@@ -6,9 +6,7 @@ require (
 	github.com/denisbrodbeck/machineid v1.0.1
 	github.com/google/uuid v1.6.0
 	github.com/pion/rtp v1.10.1
+	golang.org/x/sys v0.41.0
 )
 
-require (
-	github.com/pion/randutil v0.1.0 // indirect
-	golang.org/x/sys v0.41.0 // indirect
-)
+require github.com/pion/randutil v0.1.0 // indirect
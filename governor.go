@@ -0,0 +1,275 @@
+package mediadevices
+
+import (
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// GovernorConfig enables CPU-load-adaptive tuning of an H264 encoder.
+//
+// The governor samples the encode speed FFmpeg reports on stderr (speed=Nx,
+// the ratio of encoded media time to wall-clock time). For a live source a
+// speed below 1 means frames are falling behind real time. When that
+// persists, the encoder is restarted one step down a ladder of faster
+// presets and then smaller resolutions; after a sustained healthy period it
+// probes one step back up. Each change takes effect at the next IDR frame,
+// as with H264VideoReader.SetResolution.
+type GovernorConfig struct {
+	// Interval is the sampling period. Defaults to 2s.
+	Interval time.Duration
+	// Behind is the speed below which the encoder is considered behind real
+	// time. Defaults to 0.95.
+	Behind float64
+	// Presets is the x264 preset ladder, slowest first. Stepping down starts
+	// at the configured preset. Defaults to medium, fast, veryfast,
+	// superfast, ultrafast.
+	Presets []string
+	// MinScale is the smallest fraction of the configured resolution the
+	// governor may scale down to, once the fastest preset is reached.
+	// Defaults to 0.5; 1 disables resolution changes.
+	MinScale float64
+	// RecoverAfter is how long encoding must keep up before the governor
+	// steps back up. It doubles each time a step up falls behind again.
+	// Defaults to 30s.
+	RecoverAfter time.Duration
+	// OnChange, if set, is called from the governor goroutine after every
+	// step.
+	OnChange func(GovernorEvent)
+}
+
+// GovernorEvent describes an encoder setting change made by the governor.
+type GovernorEvent struct {
+	// Down is true when stepping to cheaper settings, false when recovering.
+	Down bool
+	// Speed is the last sampled encode speed.
+	Speed  float64
+	Preset string
+	Width  int
+	Height int
+	// Err is non-nil if the encoder could not be restarted; the previous
+	// settings stay in effect.
+	Err error
+}
+
+var defaultGovernorPresets = []string{"medium", "fast", "veryfast", "superfast", "ultrafast"}
+
+// speedPattern matches the speed field of FFmpeg's progress line.
+var speedPattern = regexp.MustCompile(`speed=\s*([0-9.]+)x`)
+
+// parseEncodeSpeed returns the most recent speed reported in an FFmpeg
+// stderr tail.
+func parseEncodeSpeed(stderr string) (float64, bool) {
+	m := speedPattern.FindAllStringSubmatch(stderr, -1)
+	if len(m) == 0 {
+		return 0, false
+	}
+	v, err := strconv.ParseFloat(m[len(m)-1][1], 64)
+	return v, err == nil
+}
+
+// governorLevel is one rung of the ladder.
+type governorLevel struct {
+	preset string
+	scale  float64
+}
+
+// encoderGovernor adapts the settings of an H264VideoReader to CPU load.
+type encoderGovernor struct {
+	r    *H264VideoReader
+	cfg  GovernorConfig
+	stop chan struct{}
+	once sync.Once
+
+	mu      sync.Mutex
+	base    H264ReaderConfig // settings at level 0
+	ladder  []governorLevel
+	level   int
+	behind  int // consecutive samples behind real time
+	changed time.Time
+	probing bool      // the last change was a step up
+	healthy time.Time // start of the current healthy period
+	recover time.Duration
+}
+
+func newEncoderGovernor(r *H264VideoReader, base H264ReaderConfig, cfg GovernorConfig) *encoderGovernor {
+	if cfg.Interval <= 0 {
+		cfg.Interval = 2 * time.Second
+	}
+	if cfg.Behind <= 0 {
+		cfg.Behind = 0.95
+	}
+	if len(cfg.Presets) == 0 {
+		cfg.Presets = defaultGovernorPresets
+	}
+	if cfg.MinScale <= 0 || cfg.MinScale > 1 {
+		cfg.MinScale = 0.5
+	}
+	if cfg.RecoverAfter <= 0 {
+		cfg.RecoverAfter = 30 * time.Second
+	}
+
+	g := &encoderGovernor{
+		r:       r,
+		cfg:     cfg,
+		stop:    make(chan struct{}),
+		recover: cfg.RecoverAfter,
+	}
+	g.reset(base)
+	return g
+}
+
+// reset makes base the level-0 settings and rebuilds the ladder from it.
+func (g *encoderGovernor) reset(base H264ReaderConfig) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	preset := base.Preset
+	if preset == "" {
+		preset = "ultrafast" // buildH264Args default
+	}
+	start := -1
+	for i, p := range g.cfg.Presets {
+		if p == preset {
+			start = i
+		}
+	}
+	ladder := []governorLevel{{preset: preset, scale: 1}}
+	for i := start + 1; start >= 0 && i < len(g.cfg.Presets); i++ {
+		ladder = append(ladder, governorLevel{preset: g.cfg.Presets[i], scale: 1})
+	}
+	fastest := ladder[len(ladder)-1].preset
+	if base.Width > 0 && base.Height > 0 {
+		for s := 0.75; s >= g.cfg.MinScale-1e-9; s *= 0.75 {
+			ladder = append(ladder, governorLevel{preset: fastest, scale: s})
+		}
+	}
+
+	g.base = base
+	g.ladder = ladder
+	g.level = 0
+	g.behind = 0
+	g.probing = false
+	g.changed = time.Now()
+	g.healthy = time.Now()
+}
+
+// baseConfig returns the level-0 settings.
+func (g *encoderGovernor) baseConfig() H264ReaderConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.base
+}
+
+// setBitRate updates the target bitrate of every level and returns the
+// settings of the current level.
+func (g *encoderGovernor) setBitRate(kbps int) H264ReaderConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.base.BufferSize = scaleBufferSize(g.base.BufferSize, g.base.BitRate, kbps)
+	g.base.BitRate = kbps
+	return g.settings(g.level)
+}
+
+// setROIs updates the regions of interest of every level and returns the
+// settings of the current level.
+func (g *encoderGovernor) setROIs(rois []EncoderROI) H264ReaderConfig {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.base.ROIs = rois
+	return g.settings(g.level)
+}
+
+// settings returns the reader config for level. Caller holds g.mu.
+func (g *encoderGovernor) settings(level int) H264ReaderConfig {
+	cfg := g.base
+	l := g.ladder[level]
+	cfg.Preset = l.preset
+	if l.scale < 1 {
+		// Keep dimensions even for YUV420p.
+		cfg.Width = int(float64(g.base.Width)*l.scale) &^ 1
+		cfg.Height = int(float64(g.base.Height)*l.scale) &^ 1
+	}
+	return cfg
+}
+
+func (g *encoderGovernor) run() {
+	ticker := time.NewTicker(g.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-g.stop:
+			return
+		case <-ticker.C:
+		}
+		g.sample(time.Now())
+	}
+}
+
+// sample evaluates the current encode speed and steps the ladder if needed.
+func (g *encoderGovernor) sample(now time.Time) {
+	speed, ok := parseEncodeSpeed(g.r.encoderStderr())
+	if !ok {
+		return
+	}
+
+	g.mu.Lock()
+	// A restarted encoder needs time before its speed is meaningful.
+	if now.Sub(g.changed) < 2*g.cfg.Interval {
+		g.mu.Unlock()
+		return
+	}
+	step := 0
+	if speed < g.cfg.Behind {
+		g.behind++
+		g.healthy = now
+		if g.behind >= 2 && g.level < len(g.ladder)-1 {
+			step = 1
+			if g.probing && now.Sub(g.changed) < g.recover {
+				// The last step up could not keep up: wait longer before
+				// the next probe.
+				g.recover *= 2
+			}
+		}
+	} else {
+		g.behind = 0
+		if g.level > 0 && now.Sub(g.healthy) >= g.recover {
+			step = -1
+		}
+	}
+	if step == 0 {
+		g.mu.Unlock()
+		return
+	}
+	level := g.level + step
+	cfg := g.settings(level)
+	g.mu.Unlock()
+
+	err := g.r.restartEncoder(cfg)
+
+	g.mu.Lock()
+	if err == nil {
+		g.level = level
+		g.behind = 0
+		g.probing = step < 0
+		g.changed = now
+		g.healthy = now
+	}
+	g.mu.Unlock()
+
+	if g.cfg.OnChange != nil {
+		g.cfg.OnChange(GovernorEvent{
+			Down:   step > 0,
+			Speed:  speed,
+			Preset: cfg.Preset,
+			Width:  cfg.Width,
+			Height: cfg.Height,
+			Err:    err,
+		})
+	}
+}
+
+func (g *encoderGovernor) Stop() {
+	g.once.Do(func() { close(g.stop) })
+}
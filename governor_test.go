@@ -0,0 +1,39 @@
+package mediadevices
+
+import "testing"
+
+func TestParseEncodeSpeed(t *testing.T) {
+	stderr := "frame=  100 fps= 30 q=23.0 size=512kB time=00:00:03.33 bitrate=1258.3kbits/s speed=1.01x\r" +
+		"frame=  115 fps= 28 q=23.0 size=600kB time=00:00:03.83 bitrate=1283.1kbits/s speed=0.87x\r"
+	speed, ok := parseEncodeSpeed(stderr)
+	if !ok || speed != 0.87 {
+		t.Errorf("parseEncodeSpeed = %v, %v; want 0.87, true", speed, ok)
+	}
+	if _, ok := parseEncodeSpeed("Input #0, dshow"); ok {
+		t.Error("parseEncodeSpeed found a speed in output without progress")
+	}
+}
+
+func TestEncoderGovernor_Ladder(t *testing.T) {
+	g := newEncoderGovernor(nil, H264ReaderConfig{Preset: "veryfast", Width: 1280, Height: 720}, GovernorConfig{})
+
+	want := []struct {
+		preset        string
+		width, height int
+	}{
+		{"veryfast", 1280, 720},
+		{"superfast", 1280, 720},
+		{"ultrafast", 1280, 720},
+		{"ultrafast", 960, 540},
+		{"ultrafast", 720, 404},
+	}
+	if len(g.ladder) != len(want) {
+		t.Fatalf("ladder has %d levels, want %d: %+v", len(g.ladder), len(want), g.ladder)
+	}
+	for i, w := range want {
+		cfg := g.settings(i)
+		if cfg.Preset != w.preset || cfg.Width != w.width || cfg.Height != w.height {
+			t.Errorf("level %d = %s %dx%d, want %s %dx%d", i, cfg.Preset, cfg.Width, cfg.Height, w.preset, w.width, w.height)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+//go:build darwin
+
+package mediadevices
+
+import (
+	"context"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// captureDevicesScript lists the AVFoundation capture devices through the
+// JavaScript for Automation ObjC bridge, like tccStatusScript, one per line:
+// media type, name, unique ID and transport type, separated by tabs.
+const captureDevicesScript = `ObjC.import('AVFoundation');
+var out = [];
+[['video', $.AVMediaTypeVideo], ['audio', $.AVMediaTypeAudio]].forEach(function (t) {
+	var devices = $.AVCaptureDevice.devicesWithMediaType(t[1]);
+	for (var i = 0; i < devices.count; i++) {
+		var d = devices.objectAtIndex(i);
+		out.push([t[0], d.localizedName.js, d.uniqueID.js, d.transportType].join('\t'));
+	}
+});
+out.join('\n')`
+
+// transportBuiltIn is the kAudioDeviceTransportTypeBuiltIn FourCC 'bltn'
+// that AVCaptureDevice.transportType reports for built-in devices.
+const transportBuiltIn = 0x626c746e
+
+// builtInGroupID is the group of all built-in cameras and microphones, as
+// Windows puts them in the computer's container.
+const builtInGroupID = "builtin"
+
+// usbVideoUniqueIDRe matches the unique ID of a USB camera: the USB
+// location ID, vendor ID and product ID in hex, e.g. "0x14200000046d0825".
+var usbVideoUniqueIDRe = regexp.MustCompile(`^0x([0-9a-fA-F]{8})[0-9a-fA-F]{8}$`)
+
+// usbAudioUniqueIDRe matches the unique ID of a USB microphone, which
+// contains the same location ID, e.g.
+// "AppleUSBAudioEngine:Logitech:Webcam C270:14200000:3".
+var usbAudioUniqueIDRe = regexp.MustCompile(`^AppleUSBAudioEngine:.*:([0-9a-fA-F]{8}):\d+$`)
+
+// groupDevices sets the GroupID of cameras and microphones from their
+// AVCaptureDevice unique IDs, so that a webcam and its built-in microphone
+// share a group: USB devices are grouped by the USB location ID in their
+// unique IDs, and built-in devices all share one group. Other devices get
+// their unique ID as their own group. If the devices cannot be listed, the
+// GroupIDs are left as they are.
+func groupDevices(ctx context.Context, devices []MediaDeviceInfo) {
+	out, err := exec.CommandContext(ctx, "osascript", "-l", "JavaScript", "-e", captureDevicesScript).Output()
+	if err != nil {
+		return
+	}
+	applyCaptureDeviceGroups(devices, string(out))
+}
+
+// applyCaptureDeviceGroups sets the GroupID of the devices listed in the
+// output of captureDevicesScript, matched by kind and name.
+func applyCaptureDeviceGroups(devices []MediaDeviceInfo, output string) {
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Split(strings.TrimRight(line, "\r"), "\t")
+		if len(fields) != 4 {
+			continue
+		}
+		kind := MediaDeviceKindVideoInput
+		if fields[0] == "audio" {
+			kind = MediaDeviceKindAudioInput
+		}
+		transport, _ := strconv.ParseInt(fields[3], 10, 64)
+		if i := matchDeviceName(devices, kind, fields[1]); i >= 0 {
+			devices[i].GroupID = captureDeviceGroupID(fields[2], transport)
+		}
+	}
+}
+
+// captureDeviceGroupID returns the group of the capture device with the
+// given unique ID and transport type.
+func captureDeviceGroupID(uniqueID string, transport int64) string {
+	if transport == transportBuiltIn {
+		return builtInGroupID
+	}
+	if m := usbVideoUniqueIDRe.FindStringSubmatch(uniqueID); m != nil {
+		return "usb:" + strings.ToLower(m[1])
+	}
+	if m := usbAudioUniqueIDRe.FindStringSubmatch(uniqueID); m != nil {
+		return "usb:" + strings.ToLower(m[1])
+	}
+	return uniqueID
+}
+
+// outputDeviceGroupID returns the group of the Core Audio output device with
+// the given UID, so that the speaker of a USB headset or webcam shares the
+// group of its microphone. The UIDs of built-in devices start with
+// "BuiltIn" ("BuiltInSpeakerDevice"). Devices without a UID keep their name.
+func outputDeviceGroupID(uid, name string) string {
+	switch {
+	case uid == "":
+		return name
+	case strings.HasPrefix(uid, "BuiltIn"):
+		return builtInGroupID
+	}
+	return captureDeviceGroupID(uid, 0)
+}
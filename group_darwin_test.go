@@ -0,0 +1,46 @@
+//go:build darwin
+
+package mediadevices
+
+import "testing"
+
+func TestApplyCaptureDeviceGroups(t *testing.T) {
+	devices := []MediaDeviceInfo{
+		{Kind: MediaDeviceKindVideoInput, Label: "FaceTime HD Camera", GroupID: "FaceTime HD Camera"},
+		{Kind: MediaDeviceKindVideoInput, Label: "Webcam C270", GroupID: "Webcam C270"},
+		{Kind: MediaDeviceKindVideoInput, Label: "OBS Virtual Camera", GroupID: "OBS Virtual Camera"},
+		{Kind: MediaDeviceKindAudioInput, Label: "MacBook Pro Microphone", GroupID: "MacBook Pro Microphone"},
+		{Kind: MediaDeviceKindAudioInput, Label: "Webcam C270", GroupID: "Webcam C270"},
+		{Kind: MediaDeviceKindAudioInput, Label: "BlackHole 2ch", GroupID: "BlackHole 2ch"},
+	}
+	output := "video\tFaceTime HD Camera\t47B4B64B70674B9CAD2BAE273A71F4B5\t1651274862\n" +
+		"video\tWebcam C270\t0x14200000046d0825\t1970496032\n" +
+		"video\tOBS Virtual Camera\t7626645E-4425-469E-9D8B-97E0FA59AC75\t0\n" +
+		"audio\tMacBook Pro Microphone\tBuiltInMicrophoneDevice\t1651274862\n" +
+		"audio\tWebcam C270\tAppleUSBAudioEngine:Unknown Manufacturer:Webcam C270:14200000:3\t1970496032\n"
+
+	applyCaptureDeviceGroups(devices, output)
+
+	want := []string{"builtin", "usb:14200000", "7626645E-4425-469E-9D8B-97E0FA59AC75", "builtin", "usb:14200000", "BlackHole 2ch"}
+	for i, d := range devices {
+		if d.GroupID != want[i] {
+			t.Errorf("%s %q: GroupID = %q, want %q", d.Kind, d.Label, d.GroupID, want[i])
+		}
+	}
+}
+
+func TestOutputDeviceGroupID(t *testing.T) {
+	tests := []struct {
+		uid, name, want string
+	}{
+		{"BuiltInSpeakerDevice", "MacBook Pro Speakers", "builtin"},
+		{"AppleUSBAudioEngine:Logitech:USB Headset:14100000:1", "USB Headset", "usb:14100000"},
+		{"BlackHole2ch_UID", "BlackHole 2ch", "BlackHole2ch_UID"},
+		{"", "External Headphones", "External Headphones"},
+	}
+	for _, tt := range tests {
+		if got := outputDeviceGroupID(tt.uid, tt.name); got != tt.want {
+			t.Errorf("outputDeviceGroupID(%q, %q) = %q, want %q", tt.uid, tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+//go:build linux
+
+package mediadevices
+
+import (
+	"path/filepath"
+	"regexp"
+)
+
+// sysfsRoot is the sysfs mount point; tests point it at a fake tree.
+var sysfsRoot = "/sys"
+
+// usbInterfaceRe matches the sysfs name of a USB interface, e.g. "1-4.2:1.0"
+// (bus-port.port:config.interface).
+var usbInterfaceRe = regexp.MustCompile(`^\d+-[\d.]+:\d+\.\d+$`)
+
+// sysfsGroupID returns a group ID for the device behind /sys/class/<class>/<name>
+// that is shared by all functions of the same physical device. For USB
+// devices this is the sysfs path of the USB device, so the video interface of
+// a webcam and the audio interface of its microphone get the same group.
+// Other devices are grouped by their own device path. It returns "" if the
+// device has no sysfs entry.
+func sysfsGroupID(class, name string) string {
+	dev, err := filepath.EvalSymlinks(filepath.Join(sysfsRoot, "class", class, name, "device"))
+	if err != nil {
+		return ""
+	}
+	if usbInterfaceRe.MatchString(filepath.Base(dev)) {
+		dev = filepath.Dir(dev)
+	}
+	rel, err := filepath.Rel(sysfsRoot, dev)
+	if err != nil {
+		return dev
+	}
+	return "/" + filepath.ToSlash(rel)
+}
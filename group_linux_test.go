@@ -0,0 +1,42 @@
+//go:build linux
+
+package mediadevices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSysfsGroupID(t *testing.T) {
+	root := t.TempDir()
+	orig := sysfsRoot
+	sysfsRoot = root
+	defer func() { sysfsRoot = orig }()
+
+	link := func(class, name, target string) {
+		dir := filepath.Join(root, "class", class, name)
+		os.MkdirAll(dir, 0o755)
+		os.MkdirAll(filepath.Join(root, target), 0o755)
+		if err := os.Symlink(filepath.Join(root, target), filepath.Join(dir, "device")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// A webcam with a microphone: two interfaces of USB device 1-4.2.
+	link("video4linux", "video0", "devices/pci0000:00/0000:00:14.0/usb1/1-4/1-4.2/1-4.2:1.0")
+	link("sound", "card1", "devices/pci0000:00/0000:00:14.0/usb1/1-4/1-4.2/1-4.2:1.3")
+	// The onboard sound card is a PCI device.
+	link("sound", "card0", "devices/pci0000:00/0000:00:1f.3")
+
+	cam := sysfsGroupID("video4linux", "video0")
+	mic := sysfsGroupID("sound", "card1")
+	if cam != "/devices/pci0000:00/0000:00:14.0/usb1/1-4/1-4.2" || cam != mic {
+		t.Errorf("camera group %q, microphone group %q, want the USB device", cam, mic)
+	}
+	if got := sysfsGroupID("sound", "card0"); got != "/devices/pci0000:00/0000:00:1f.3" {
+		t.Errorf("onboard group = %q", got)
+	}
+	if got := sysfsGroupID("sound", "card7"); got != "" {
+		t.Errorf("missing device group = %q, want empty", got)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build windows
+
+package mediadevices
+
+import (
+	"fmt"
+	"strings"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	cfgmgr32              = windows.NewLazySystemDLL("cfgmgr32.dll")
+	cmLocateDevNodeW      = cfgmgr32.NewProc("CM_Locate_DevNodeW")
+	cmGetDevNodePropertyW = cfgmgr32.NewProc("CM_Get_DevNode_PropertyW")
+)
+
+var devpkeyDeviceContainerID = windows.DEVPROPKEY{
+	FmtID: windows.DEVPROPGUID{Data1: 0x8C7ED206, Data2: 0x3F8A, Data3: 0x4827, Data4: [8]byte{0xB3, 0xAB, 0xAE, 0x9E, 0x1F, 0xAE, 0xFC, 0x6C}},
+	PID:   2,
+}
+
+// groupDevices sets the GroupID of cameras and microphones to the Windows
+// container ID of the physical device they belong to, so that a webcam and
+// its built-in microphone share a group. Cameras are looked up by their
+// DirectShow alternative name. Devices whose container cannot be determined
+// keep their GroupID. Built-in devices all share the computer's container.
+func groupDevices(devices []MediaDeviceInfo) {
+	for i := range devices {
+		if devices[i].Kind != MediaDeviceKindVideoInput {
+			continue
+		}
+		if id, ok := dshowInstanceID(devices[i].AlternativeName); ok {
+			if container, err := devNodeContainerID(id); err == nil {
+				devices[i].GroupID = container
+			}
+		}
+	}
+
+	endpoints, _ := captureEndpointContainers()
+	for name, container := range endpoints {
+		if i := matchDeviceName(devices, MediaDeviceKindAudioInput, name); i >= 0 {
+			devices[i].GroupID = container
+		}
+	}
+}
+
+// dshowInstanceID converts the alternative name of a PnP DirectShow device,
+// which embeds its device interface path, to the device instance ID:
+//
+//	@device_pnp_\\?\usb#vid_046d&pid_0825&mi_00#7&1a2b&0&0000#{65e8773d-...}\global
+//	usb\vid_046d&pid_0825&mi_00\7&1a2b&0&0000
+func dshowInstanceID(alt string) (string, bool) {
+	path, ok := strings.CutPrefix(alt, "@device_pnp_")
+	if !ok {
+		return "", false
+	}
+	path = strings.TrimPrefix(path, `\\?\`)
+	i := strings.LastIndex(path, "#{")
+	if i < 0 {
+		return "", false
+	}
+	return strings.ReplaceAll(path[:i], "#", `\`), true
+}
+
+// devNodeContainerID returns the container ID of a device instance.
+func devNodeContainerID(instanceID string) (string, error) {
+	id, err := windows.UTF16PtrFromString(instanceID)
+	if err != nil {
+		return "", err
+	}
+	var inst windows.DEVINST
+	if r, _, _ := cmLocateDevNodeW.Call(uintptr(unsafe.Pointer(&inst)), uintptr(unsafe.Pointer(id)), 0); windows.CONFIGRET(r) != windows.CR_SUCCESS {
+		return "", fmt.Errorf("locate %s: CONFIGRET 0x%x", instanceID, r)
+	}
+	var typ windows.DEVPROPTYPE
+	var container windows.GUID
+	size := uint32(unsafe.Sizeof(container))
+	if r, _, _ := cmGetDevNodePropertyW.Call(uintptr(inst), uintptr(unsafe.Pointer(&devpkeyDeviceContainerID)),
+		uintptr(unsafe.Pointer(&typ)), uintptr(unsafe.Pointer(&container)), uintptr(unsafe.Pointer(&size)), 0); windows.CONFIGRET(r) != windows.CR_SUCCESS {
+		return "", fmt.Errorf("container ID of %s: CONFIGRET 0x%x", instanceID, r)
+	}
+	return container.String(), nil
+}
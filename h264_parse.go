@@ -0,0 +1,301 @@
+package mediadevices
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+)
+
+// tsPacketSize is the size of an MPEG-TS packet.
+const tsPacketSize = 188
+
+// ParseAnomalies counts malformed input seen by the H.264 Annex B and
+// MPEG-TS parsers. The parsers resynchronize on the next start code or sync
+// byte instead of failing, so these counters are the trace of damaged input.
+type ParseAnomalies struct {
+	// Resyncs is the number of times the parser lost sync and searched
+	// for the next start code or TS sync byte.
+	Resyncs int64
+	// SkippedBytes is the number of bytes discarded while resynchronizing.
+	SkippedBytes int64
+	// InvalidNALUnits counts NAL units dropped because their header is
+	// invalid (forbidden_zero_bit set, or nal_unit_type 0).
+	InvalidNALUnits int64
+	// TruncatedNALUnits counts start codes not followed by any NAL data.
+	TruncatedNALUnits int64
+	// EmulationPreventionErrors counts NAL units kept despite a byte
+	// sequence that emulation prevention forbids (0x000000-0x000002, or
+	// 0x000003 followed by a byte above 0x03).
+	EmulationPreventionErrors int64
+	// InvalidTSPackets counts TS packets dropped because of an invalid
+	// header, adaptation field or PES header.
+	InvalidTSPackets int64
+}
+
+// parseDiagnostics accumulates ParseAnomalies. It is safe for concurrent
+// use, and a nil *parseDiagnostics discards everything.
+type parseDiagnostics struct {
+	resyncs, skipped, invalid, truncated, epb, invalidTS atomic.Int64
+}
+
+func (d *parseDiagnostics) resync(skipped int) {
+	if d != nil {
+		d.resyncs.Add(1)
+		d.skipped.Add(int64(skipped))
+	}
+}
+
+func (d *parseDiagnostics) invalidNAL() {
+	if d != nil {
+		d.invalid.Add(1)
+	}
+}
+
+func (d *parseDiagnostics) truncatedNAL() {
+	if d != nil {
+		d.truncated.Add(1)
+	}
+}
+
+func (d *parseDiagnostics) badEmulationPrevention() {
+	if d != nil {
+		d.epb.Add(1)
+	}
+}
+
+func (d *parseDiagnostics) invalidPacket() {
+	if d != nil {
+		d.invalidTS.Add(1)
+	}
+}
+
+func (d *parseDiagnostics) snapshot() ParseAnomalies {
+	if d == nil {
+		return ParseAnomalies{}
+	}
+	return ParseAnomalies{
+		Resyncs:                   d.resyncs.Load(),
+		SkippedBytes:              d.skipped.Load(),
+		InvalidNALUnits:           d.invalid.Load(),
+		TruncatedNALUnits:         d.truncated.Load(),
+		EmulationPreventionErrors: d.epb.Load(),
+		InvalidTSPackets:          d.invalidTS.Load(),
+	}
+}
+
+// newNALUnit validates the data of one NAL unit (start code and trailing
+// zero bytes removed) and wraps it, or returns nil if it must be dropped.
+// Emulation prevention bytes are kept: Data is the NAL unit as transmitted.
+func newNALUnit(data []byte, d *parseDiagnostics) *NALUnit {
+	if len(data) == 0 {
+		d.truncatedNAL()
+		return nil
+	}
+	if data[0]&0x80 != 0 || data[0]&0x1F == 0 {
+		d.invalidNAL()
+		return nil
+	}
+	if !validEmulationPrevention(data) {
+		d.badEmulationPrevention()
+	}
+	nalType := H264NaluType(data[0] & 0x1F)
+	return &NALUnit{
+		Type:     nalType,
+		Data:     data,
+		Keyframe: nalType.IsKeyframe(),
+	}
+}
+
+// validEmulationPrevention reports whether data is free of the three-byte
+// sequences that emulation prevention excludes from a NAL unit.
+func validEmulationPrevention(data []byte) bool {
+	for i := 0; i+2 < len(data); i++ {
+		if data[i] != 0 || data[i+1] != 0 {
+			continue
+		}
+		switch b := data[i+2]; {
+		case b <= 0x02:
+			return false
+		case b == 0x03:
+			if i+3 < len(data) && data[i+3] > 0x03 {
+				return false
+			}
+			i += 2
+		}
+	}
+	return true
+}
+
+// skipToStartCode returns the offset of the first start code in data, or
+// len(data) if there is none, counting any non-zero bytes before it as
+// skipped. Zero bytes are not counted: leading_zero_8bits may precede a
+// start code, and a 4-byte start code begins with one.
+func skipToStartCode(data []byte, d *parseDiagnostics) int {
+	start := bytes.Index(data, annexBStartCode)
+	if start < 0 {
+		start = len(data)
+	}
+	if garbage := len(bytes.Trim(data[:start], "\x00")); garbage > 0 {
+		d.resync(garbage)
+	}
+	return start
+}
+
+// parseH264Bitstream splits a complete H.264 Annex B byte stream into NAL
+// units. Bytes before the first start code and NAL units with an invalid
+// header are skipped and counted in d, which may be nil; parsing continues
+// at the next start code. The returned units share data's memory.
+func parseH264Bitstream(data []byte, d *parseDiagnostics) []*NALUnit {
+	var nalus []*NALUnit
+	start := skipToStartCode(data, d)
+	for start < len(data) {
+		payload := start + len(annexBStartCode)
+		end := len(data)
+		if next := bytes.Index(data[payload:], annexBStartCode); next >= 0 {
+			end = payload + next
+		}
+		// The leading zero of a 4-byte start code and any trailing_zero_8bits
+		// precede the next 3-byte start code; they are not part of this NAL.
+		if nal := newNALUnit(bytes.TrimRight(data[payload:end], "\x00"), d); nal != nil {
+			nalus = append(nalus, nal)
+		}
+		start = end
+	}
+	return nalus
+}
+
+// tsPES returns the elementary stream data carried by one TS packet and
+// whether it starts a new PES packet (payload_unit_start_indicator), with
+// the PES header removed. ok is false for packets without video payload.
+func tsPES(pkt []byte) (pid int, start bool, es []byte, ok bool, err error) {
+	if len(pkt) < tsPacketSize {
+		return 0, false, nil, false, fmt.Errorf("invalid TS packet: too short (%d bytes)", len(pkt))
+	}
+	pkt = pkt[:tsPacketSize]
+	if pkt[0] != 0x47 {
+		return 0, false, nil, false, fmt.Errorf("invalid TS sync byte: 0x%02x", pkt[0])
+	}
+	if pkt[1]&0x80 != 0 {
+		return 0, false, nil, false, fmt.Errorf("TS packet has transport_error_indicator set")
+	}
+	pid = int(pkt[1]&0x1F)<<8 | int(pkt[2])
+	start = pkt[1]&0x40 != 0
+	afc := pkt[3] >> 4 & 0x03
+
+	// PAT, CAT and other tables, and null packets carry no video.
+	if pid < 0x10 || pid == 0x1FFF || afc&0x01 == 0 {
+		return pid, false, nil, false, nil
+	}
+
+	offset := 4
+	if afc&0x02 != 0 {
+		offset += 1 + int(pkt[4])
+		if offset > tsPacketSize {
+			return pid, false, nil, false, fmt.Errorf("TS adaptation field overruns packet (%d bytes)", pkt[4])
+		}
+	}
+	es = pkt[offset:]
+	if !start {
+		return pid, false, es, true, nil
+	}
+
+	// PES header: start code prefix, stream_id, PES_packet_length, two flag
+	// bytes and PES_header_data_length, followed by the header data.
+	if len(es) < 9 || es[0] != 0 || es[1] != 0 || es[2] != 1 {
+		return pid, true, nil, false, fmt.Errorf("invalid PES header")
+	}
+	if es[3]&0xF0 != 0xE0 {
+		return pid, true, nil, false, nil // not a video stream
+	}
+	hdr := 9 + int(es[8])
+	if hdr > len(es) {
+		return pid, true, nil, false, fmt.Errorf("PES header overruns packet (%d bytes)", es[8])
+	}
+	return pid, true, es[hdr:], true, nil
+}
+
+// parseTSPacket parses one MPEG-TS packet and returns the H.264 NAL units
+// that begin in its payload. NAL units continued from or into neighbouring
+// packets are cut at the packet boundary; use parseTSStream to reassemble
+// them. Invalid packets return an error and are counted in d.
+func parseTSPacket(data []byte, d *parseDiagnostics) ([]*NALUnit, error) {
+	_, _, es, ok, err := tsPES(data)
+	if err != nil {
+		d.invalidPacket()
+		return nil, err
+	}
+	if !ok {
+		return nil, nil
+	}
+	return parseH264Bitstream(es, d), nil
+}
+
+// parseTSStream parses a buffer of MPEG-TS packets and returns the H.264
+// NAL units of all video PES packets in it, reassembled across TS packets.
+// When the sync byte is lost it resynchronizes on the next offset where
+// two consecutive packets start with 0x47, so that damaged or misaligned
+// input costs only the packets it touches. A PES packet left incomplete at
+// the end of data is parsed as is.
+func parseTSStream(data []byte, d *parseDiagnostics) []*NALUnit {
+	var nalus []*NALUnit
+	pes := make(map[int][]byte)
+	var order []int // PIDs in order of first payload, for deterministic output
+	flush := func(pid int) {
+		if buf := pes[pid]; len(buf) > 0 {
+			nalus = append(nalus, parseH264Bitstream(buf, d)...)
+		}
+		pes[pid] = nil
+	}
+
+	for i := 0; i+tsPacketSize <= len(data); {
+		if !tsSyncAt(data, i) {
+			next := tsResync(data, i+1)
+			d.resync(next - i)
+			i = next
+			continue
+		}
+		pid, start, es, ok, err := tsPES(data[i : i+tsPacketSize])
+		i += tsPacketSize
+		if err != nil {
+			d.invalidPacket()
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if _, seen := pes[pid]; !seen {
+			order = append(order, pid)
+		}
+		if start {
+			flush(pid)
+		} else if pes[pid] == nil {
+			continue // continuation of a PES packet that started before data
+		}
+		pes[pid] = append(pes[pid], es...)
+	}
+	for _, pid := range order {
+		flush(pid)
+	}
+	return nalus
+}
+
+// tsSyncAt reports whether a TS packet starts at offset i: the sync byte is
+// there and, if the buffer extends that far, at the next packet too.
+func tsSyncAt(data []byte, i int) bool {
+	if data[i] != 0x47 {
+		return false
+	}
+	next := i + tsPacketSize
+	return next >= len(data) || data[next] == 0x47
+}
+
+// tsResync returns the first offset from i at which packets are in sync,
+// or len(data) if there is none.
+func tsResync(data []byte, i int) int {
+	for ; i+tsPacketSize <= len(data); i++ {
+		if tsSyncAt(data, i) {
+			return i
+		}
+	}
+	return len(data)
+}
@@ -0,0 +1,145 @@
+package mediadevices
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseH264Bitstream_Recovery(t *testing.T) {
+	var d parseDiagnostics
+	data := []byte{
+		0xDE, 0xAD, // garbage before the first start code
+		0, 0, 0, 1, 0x67, 0x42, 0x00, 0x1F, // SPS
+		0, 0, 1, // truncated: no NAL data
+		0, 0, 1, 0xE5, 0x01, // forbidden_zero_bit set
+		0, 0, 1, 0x68, 0xCE, 0x00, 0x00, 0x03, 0x01, // PPS with valid emulation prevention
+		0, 0, 0, 1, 0x65, 0x88, 0x00, 0x00, 0x03, 0x07, 0x84, // IDR with invalid emulation prevention, last in buffer
+	}
+	nalus := parseH264Bitstream(data, &d)
+
+	want := [][]byte{
+		{0x67, 0x42, 0x00, 0x1F},
+		{0x68, 0xCE, 0x00, 0x00, 0x03, 0x01},
+		{0x65, 0x88, 0x00, 0x00, 0x03, 0x07, 0x84},
+	}
+	if len(nalus) != len(want) {
+		t.Fatalf("got %d NAL units %v, want %d", len(nalus), nalus, len(want))
+	}
+	for i, nal := range nalus {
+		if !bytes.Equal(nal.Data, want[i]) {
+			t.Errorf("NAL %d = % x, want % x", i, nal.Data, want[i])
+		}
+	}
+	got := d.snapshot()
+	if got != (ParseAnomalies{Resyncs: 1, SkippedBytes: 2, InvalidNALUnits: 1, TruncatedNALUnits: 1, EmulationPreventionErrors: 1}) {
+		t.Errorf("anomalies = %+v", got)
+	}
+}
+
+func TestParseH264Bitstream_NoStartCode(t *testing.T) {
+	var d parseDiagnostics
+	if nalus := parseH264Bitstream([]byte{1, 2, 3, 4, 5}, &d); len(nalus) != 0 {
+		t.Errorf("got %v from garbage", nalus)
+	}
+	if got := d.snapshot(); got.SkippedBytes != 5 {
+		t.Errorf("anomalies = %+v", got)
+	}
+	parseH264Bitstream(nil, nil) // nil diagnostics are allowed
+}
+
+// tsPackets packs a video PES with payload es into 188-byte TS packets of
+// the given PID, padding the last one with an adaptation field.
+func tsPackets(pid int, es []byte) []byte {
+	pes := append([]byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x00, 0}, es...)
+	var out []byte
+	for first := true; len(pes) > 0; first = false {
+		hdr := []byte{0x47, byte(pid >> 8 & 0x1F), byte(pid), 0x10}
+		if first {
+			hdr[1] |= 0x40
+		}
+		n := min(len(pes), tsPacketSize-4)
+		if n < tsPacketSize-4 {
+			// Stuff the remainder with an adaptation field.
+			hdr[3] |= 0x20
+			af := make([]byte, tsPacketSize-4-n)
+			af[0] = byte(len(af) - 1)
+			if len(af) > 1 {
+				af[1] = 0
+				for i := 2; i < len(af); i++ {
+					af[i] = 0xFF
+				}
+			}
+			hdr = append(hdr, af...)
+		}
+		out = append(out, hdr...)
+		out = append(out, pes[:n]...)
+		pes = pes[n:]
+	}
+	return out
+}
+
+func TestParseTSStream_Resync(t *testing.T) {
+	idr := append([]byte{0x65}, bytes.Repeat([]byte{0x88}, 300)...) // spans two TS packets
+	es := append([]byte{0, 0, 0, 1, 0x67, 0x42, 0, 0, 1, 0x68, 0xCE, 0, 0, 1}, idr...)
+
+	var data []byte
+	data = append(data, 0x12, 0x47, 0x00) // misaligned start
+	data = append(data, tsPackets(0x100, es)...)
+	data = append(data, bytes.Repeat([]byte{0x47}, 5)...) // damage between PES packets
+	data = append(data, tsPackets(0x100, []byte{0, 0, 1, 0x41, 0x9A})...)
+
+	var d parseDiagnostics
+	nalus := parseTSStream(data, &d)
+	var types []H264NaluType
+	for _, n := range nalus {
+		types = append(types, n.Type)
+	}
+	if len(nalus) != 4 || types[0] != NALUTypeSPS || types[1] != NALUTypePPS || types[2] != 5 || types[3] != 1 {
+		t.Fatalf("NAL types = %v, want SPS, PPS, IDR, slice", types)
+	}
+	if !bytes.Equal(nalus[2].Data, idr) {
+		t.Errorf("IDR not reassembled across TS packets: %d bytes, want %d", len(nalus[2].Data), len(idr))
+	}
+	if got := d.snapshot(); got.Resyncs != 2 || got.SkippedBytes != 8 {
+		t.Errorf("anomalies = %+v, want 2 resyncs skipping 8 bytes", got)
+	}
+}
+
+func TestParseTSPacket_Invalid(t *testing.T) {
+	var d parseDiagnostics
+	pkt := tsPackets(0x100, []byte{0, 0, 1, 0x67, 0x42})
+	if nalus, err := parseTSPacket(pkt, &d); err != nil || len(nalus) != 1 || nalus[0].Type != NALUTypeSPS {
+		t.Fatalf("parseTSPacket = %v, %v", nalus, err)
+	}
+
+	bad := bytes.Clone(pkt)
+	bad[3] |= 0x20
+	bad[4] = 200 // adaptation field longer than the packet
+	if _, err := parseTSPacket(bad, &d); err == nil {
+		t.Error("adaptation field overrun accepted")
+	}
+	if _, err := parseTSPacket(pkt[1:], &d); err == nil {
+		t.Error("short packet accepted")
+	}
+	if got := d.snapshot(); got.InvalidTSPackets != 2 {
+		t.Errorf("InvalidTSPackets = %d, want 2", got.InvalidTSPackets)
+	}
+}
+
+func TestH264VideoReader_NextNALResync(t *testing.T) {
+	r := &H264VideoReader{}
+	r.pending = []byte{0x13, 0x37, 0, 0} // garbage, then a split start code
+	if nal := r.nextNAL(false); nal != nil {
+		t.Fatalf("NAL from garbage: %v", nal)
+	}
+	r.pending = append(r.pending, 1, 0x67, 0x42, 0, 0, 1, 0xE1, 0, 0, 1, 0x68, 0xCE, 0, 0, 1)
+	if nal := r.nextNAL(false); nal == nil || nal.Type != NALUTypeSPS {
+		t.Fatalf("first NAL = %v, want SPS", nal)
+	}
+	if nal := r.nextNAL(false); nal == nil || nal.Type != NALUTypePPS {
+		t.Fatalf("second NAL = %v, want PPS after dropping the invalid unit", nal)
+	}
+	if got := r.anomalies.snapshot(); got.SkippedBytes != 2 || got.InvalidNALUnits != 1 {
+		t.Errorf("anomalies = %+v", got)
+	}
+}
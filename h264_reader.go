@@ -1,9 +1,19 @@
 package mediadevices
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"net"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/pion/rtp"
 )
@@ -13,14 +23,14 @@ type H264NaluType uint8
 
 const (
 	// NALU types
-	NALUTypeUnknown     H264NaluType = 0
-	NALUTypeSlice      H264NaluType = 1
-	NALUTypeDPA        H264NaluType = 2
-	NALUTypeDPB        H264NaluType = 3
-	NALUTypeIDC        H264NaluType = 4
-	NALUTypeSEI        H264NaluType = 5
-	NALUTypeSPS        H264NaluType = 7
-	NALUTypePPS        H264NaluType = 8
+	NALUTypeUnknown H264NaluType = 0
+	NALUTypeSlice   H264NaluType = 1
+	NALUTypeDPA     H264NaluType = 2
+	NALUTypeDPB     H264NaluType = 3
+	NALUTypeIDC     H264NaluType = 4
+	NALUTypeSEI     H264NaluType = 5
+	NALUTypeSPS     H264NaluType = 7
+	NALUTypePPS     H264NaluType = 8
 )
 
 // IsKeyframe returns true if the NAL unit is a keyframe.
@@ -30,9 +40,15 @@ func (t H264NaluType) IsKeyframe() bool {
 
 // NALUnit represents a single H264 Network Abstraction Layer Unit.
 type NALUnit struct {
-	Type      H264NaluType
-	Data      []byte
-	Keyframe  bool
+	Type     H264NaluType
+	Data     []byte
+	Keyframe bool
+
+	// PTS and DTS are the presentation and decode timestamps of the picture
+	// the unit belongs to, relative to the start of the stream. They differ
+	// only when B-frames are enabled.
+	PTS time.Duration
+	DTS time.Duration
 }
 
 // String returns a string representation of the NAL unit type.
@@ -42,17 +58,62 @@ func (n *NALUnit) String() string {
 
 // H264ReaderConfig holds configuration for creating an H264 video reader.
 type H264ReaderConfig struct {
-	DeviceName  string // Original device name for FFmpeg (e.g., "USB2.0 HD UVC WebCam")
+	DeviceName  string // Device name for FFmpeg (e.g., "USB2.0 HD UVC WebCam"); on Windows prefer MediaDeviceInfo.AlternativeName when set
 	DeviceID    string // UUID (kept for backwards compatibility)
 	Width       int
 	Height      int
 	FrameRate   float64
-	BitRate     int // in kbps, 0 for default
-	KeyInterval int // GOP size, 0 for auto (default 60)
-	Profile     string // "baseline", "main", "high"
-	Preset      string // "ultrafast", "fast", "medium", "slow"
+	BitRate     int           // in kbps, 0 for default
+	KeyInterval int           // GOP size in frames, 0 for auto (default 60); Keyframes overrides it
+	Profile     string        // "baseline", "main", "high"
+	Preset      string        // "ultrafast", "fast", "medium", "slow"
+	StatsWindow time.Duration // sliding window for Stats(), 0 for default (5s)
+	BFrames     int           // max consecutive B-frames, 0 to disable (low latency)
+	BufferSize  int           // rate-control buffer in kbit (-bufsize), 0 for encoder default
+
+	// Keyframes is the IDR frame policy. An interval set here takes
+	// precedence over KeyInterval.
+	Keyframes KeyframePolicy
+
+	// LatencyProfile fills in zero-valued Preset, KeyInterval, BFrames and
+	// BufferSize. Defaults to Config.LatencyProfile.
+	LatencyProfile LatencyProfile
+
+	// Governor, if set, adapts preset and resolution to CPU load.
+	Governor *GovernorConfig
+
+	// VideoFilter is a filter chain applied after scaling, before encoding,
+	// e.g. "drawtext@title=text='Live':x=10:y=10". Filters named with
+	// "filter@name" can be changed at run time with SendCommand.
+	VideoFilter string
+
+	// ZMQControl adds FFmpeg's zmq filter, bound to a free localhost port,
+	// to the encoder's filter chain, and makes SendCommand deliver commands
+	// through it and report FFmpeg's reply. Requires FFmpeg built with
+	// --enable-libzmq.
+	ZMQControl bool
+
+	// ROIs are regions of interest that get a quantizer offset, applied
+	// after VideoFilter; see EncoderROI and SetROIs.
+	ROIs []EncoderROI
+
+	// ArgsHook, if set, can inspect and modify the FFmpeg arguments of
+	// the encoder, including after a restart. It runs after Config.ArgsHook.
+	ArgsHook func(args []string) []string
+
+	// privacyMasks are those of Config.PrivacyMasks for the device,
+	// resolved when the reader is created.
+	privacyMasks []PrivacyMask
+
+	// inputFormat is that of Config.InputFormats for the device, resolved
+	// when the reader is created, or nil for the default.
+	inputFormat *InputFormat
 }
 
+// annexBStartCode is the 3-byte Annex B start code prefix. A 4-byte start
+// code is a zero byte followed by it.
+var annexBStartCode = []byte{0x00, 0x00, 0x01}
+
 // buildH264Args builds FFmpeg arguments for H264 video capture.
 func buildH264Args(cfg H264ReaderConfig) []string {
 	args := []string{}
@@ -63,11 +124,29 @@ func buildH264Args(cfg H264ReaderConfig) []string {
 		deviceName = cfg.DeviceID
 	}
 
-	// Input from DirectShow (Windows)
-	args = append(args, "-f", "dshow")
-	// For MJPEG cameras, increase analyzeduration and probesize to properly detect stream parameters
-	args = append(args, "-analyzeduration", "10000000", "-probesize", "10000000")
-	args = append(args, "-i", fmt.Sprintf("video=%s", deviceName))
+	if isVirtualDevice(deviceName) {
+		// Input from a file or stream registered with RegisterVirtualDevice
+		args = append(args, buildVirtualInputArgs(deviceName, cfg.LatencyProfile, false)...)
+	} else if isSyntheticDevice(deviceName) {
+		// Input from an FFmpeg test source (Config.SyntheticDevices)
+		args = append(args, buildSyntheticVideoInputArgs(VideoCaptureParams{
+			DeviceID:  deviceName,
+			Width:     cfg.Width,
+			Height:    cfg.Height,
+			FrameRate: cfg.FrameRate,
+			Profile:   cfg.LatencyProfile,
+		})...)
+	} else {
+		// Input from DirectShow (Windows)
+		args = append(args, "-f", "dshow")
+		// For MJPEG cameras, increase analyzeduration and probesize to properly detect stream parameters
+		args = append(args, "-analyzeduration", "10000000", "-probesize", "10000000")
+		args = append(args, profileInputArgs(cfg.LatencyProfile)...)
+		args = append(args, "-i", fmt.Sprintf("video=%s", deviceName))
+	}
+	if cfg.inputFormat != nil {
+		args = forceInputFormat(args, *cfg.inputFormat)
+	}
 
 	// Video encoding settings
 	args = append(args, "-c:v", "libx264")
@@ -79,12 +158,31 @@ func buildH264Args(cfg H264ReaderConfig) []string {
 	}
 	args = append(args, "-preset", preset)
 
-	// Tune for low latency streaming
-	args = append(args, "-tune", "zerolatency")
+	if cfg.BFrames > 0 {
+		// B-frames improve compression for recording at the cost of
+		// reordering delay; zerolatency would disable them.
+		args = append(args, "-bf", fmt.Sprintf("%d", cfg.BFrames))
+	} else {
+		// Tune for low latency streaming
+		args = append(args, "-tune", "zerolatency")
+	}
 
-	// Resolution
+	// Privacy masks first, then resolution and the caller's filters
+	var filters []string
+	if mask := privacyMaskFilter(cfg.privacyMasks); mask != "" {
+		filters = append(filters, mask)
+	}
 	if cfg.Width > 0 && cfg.Height > 0 {
-		args = append(args, "-vf", fmt.Sprintf("scale=%d:%d", cfg.Width, cfg.Height))
+		filters = append(filters, fmt.Sprintf("scale=%d:%d", cfg.Width, cfg.Height))
+	}
+	if cfg.VideoFilter != "" {
+		filters = append(filters, cfg.VideoFilter)
+	}
+	if roi := roiFilter(cfg.ROIs); roi != "" {
+		filters = append(filters, roi)
+	}
+	if len(filters) > 0 {
+		args = append(args, "-vf", strings.Join(filters, ","))
 	}
 
 	// Frame rate
@@ -97,16 +195,20 @@ func buildH264Args(cfg H264ReaderConfig) []string {
 		args = append(args, "-b:v", fmt.Sprintf("%dk", cfg.BitRate))
 	}
 
-	// Key frame interval (GOP size)
+	// Rate-control buffer, capping the bitrate over the buffer duration
+	if cfg.BufferSize > 0 && cfg.BitRate > 0 {
+		args = append(args, "-maxrate", fmt.Sprintf("%dk", cfg.BitRate))
+		args = append(args, "-bufsize", fmt.Sprintf("%dk", cfg.BufferSize))
+	}
+
+	// Key frame interval (GOP size) and scene cuts. SPS/PPS are repeated
+	// with every IDR frame (repeatheaders below), so the interval is also
+	// how long a new viewer waits for a decodable picture.
 	keyInt := cfg.KeyInterval
 	if keyInt == 0 {
 		keyInt = 60
 	}
-	args = append(args, "-g", fmt.Sprintf("%d", keyInt))
-
-	// Force IDR frame generation every 30 frames to trigger PPS output
-	// This ensures SPS/PPS are output more frequently for proper stream initialization
-	args = append(args, "-force_key_frames", "expr:not(mod(n,30))")
+	args = append(args, cfg.Keyframes.args(cfg.FrameRate, keyInt)...)
 
 	// Profile
 	profile := cfg.Profile
@@ -118,11 +220,17 @@ func buildH264Args(cfg H264ReaderConfig) []string {
 	// Additional options for low latency
 	args = append(args, "-pix_fmt", "yuv420p")
 	args = append(args, "-an") // no audio
-	args = append(args, "-sn")  // no subtitles
+	args = append(args, "-sn") // no subtitles
 
 	// Ensure SPS/PPS are sent with every IDR frame for proper stream decoding
 	// This is critical for RTSP servers to properly announce the stream
-	args = append(args, "-x264-params", "repeatheaders=1")
+	x264Params := "repeatheaders=1"
+	if len(cfg.ROIs) > 0 {
+		// x264 ignores ROIs without adaptive quantization, which the
+		// ultrafast preset turns off.
+		x264Params += ":aq-mode=1"
+	}
+	args = append(args, "-x264-params", x264Params)
 
 	// Output format: H264 raw bitstream (annexb) - this ensures SPS/PPS are output as NAL units
 	// Using annexb format instead of mpegts to make SPS/PPS extraction easier
@@ -134,13 +242,54 @@ func buildH264Args(cfg H264ReaderConfig) []string {
 
 // H264VideoReader reads H264 encoded video frames from an FFmpeg subprocess.
 type H264VideoReader struct {
+	cfg    H264ReaderConfig
+	owner  *MediaDevices // opened the reader; nil means the default instance
 	proc   *ffmpegProcess
 	width  int
 	height int
+
+	// mu guards next, the replacement encoder started by SetResolution, and
+	// the dimensions reported by Width/Height. awaitIDR drops NAL units of
+	// the replacement stream until its first SPS.
+	mu       sync.Mutex
+	next     *ffmpegProcess
+	awaitIDR bool
+
+	// restartMu serializes encoder restarts with Pause, Resume and Close.
+	// restarting is non-nil while a restart has stopped the running encoder
+	// and not yet started its replacement; it is closed when it has.
+	restartMu  sync.Mutex
+	restarting chan struct{}
+	closed     bool
+
+	// resumec is non-nil while paused and closed by Resume; suspended is
+	// the encoder stopped by Pause(true), if any.
+	resumec   chan struct{}
+	suspended *ffmpegProcess
+
+	// stderr receives the stderr lines of every encoder the reader starts.
+	stderr *stderrFeed
+
+	// pending holds bytes read from FFmpeg that have not yet been split into
+	// complete NAL units; readBuf is the scratch buffer for pipe reads.
+	pending []byte
+	readBuf []byte
+
+	// timing assigns PTS/DTS. held collects non-VCL units waiting for the
+	// first slice of their picture; ready holds stamped units to return.
+	timing *h264Timing
+	held   []*NALUnit
+	ready  []*NALUnit
+
+	stats     *encoderStats
+	anomalies parseDiagnostics
+	governor  *encoderGovernor
+	health    healthMeter
 }
 
-// newH264VideoReader creates a new H264VideoReader.
-func newH264VideoReader(cfg H264ReaderConfig) (*H264VideoReader, error) {
+// newH264VideoReader creates a new H264VideoReader that captures with the
+// configuration of m.
+func (m *MediaDevices) newH264VideoReader(cfg H264ReaderConfig) (*H264VideoReader, error) {
 	// Use DeviceName if available, otherwise use DeviceID
 	deviceName := cfg.DeviceName
 	if deviceName == "" {
@@ -150,252 +299,602 @@ func newH264VideoReader(cfg H264ReaderConfig) (*H264VideoReader, error) {
 		return nil, fmt.Errorf("DeviceName or DeviceID is required")
 	}
 
-	args := buildH264Args(cfg)
-	gcfg := GetConfig()
+	cfg, err := cfg.applyProfile(m.Config().LatencyProfile)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateROIs(cfg.ROIs); err != nil {
+		return nil, err
+	}
+	if err := cfg.Keyframes.validate(); err != nil {
+		return nil, err
+	}
+	if cfg.privacyMasks, err = m.privacyMasksFor(context.Background(), deviceName, cfg.DeviceID); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	if f, ok := m.inputFormatFor(context.Background(), MediaDeviceKindVideoInput, deviceName, cfg.DeviceID); ok {
+		cfg.inputFormat = &f
+	}
+	if err := checkDeviceAccess(MediaDeviceKindVideoInput, deviceName); err != nil {
+		return nil, err
+	}
 
-	proc, err := startProcess(gcfg.FFmpegPath, args)
+	stderr := &stderrFeed{}
+	proc, err := m.startH264Encoder(cfg, stderr)
 	if err != nil {
 		return nil, fmt.Errorf("ffmpeg start H264 capture: %w", err)
 	}
 
-	return &H264VideoReader{
-		proc:  proc,
-		width: cfg.Width,
-		height: cfg.Height,
-	}, nil
+	r := &H264VideoReader{
+		cfg:     cfg,
+		owner:   m,
+		proc:    proc,
+		width:   cfg.Width,
+		height:  cfg.Height,
+		readBuf: make([]byte, 4096),
+		timing:  newH264Timing(cfg.FrameRate, cfg.BFrames),
+		stats:   newEncoderStats(cfg.StatsWindow),
+		health:  healthMeter{window: cfg.StatsWindow},
+		stderr:  stderr,
+	}
+	m.resources.addReader(r)
+	if cfg.Governor != nil {
+		r.governor = newEncoderGovernor(r, cfg, *cfg.Governor)
+		go r.governor.run()
+	}
+	return r, nil
 }
 
 // Read reads the next H264 NAL unit from the stream.
-// Returns nil when the stream ends.
+// NAL units are returned in stream order, each exactly once, stamped with
+// the PTS and DTS of the picture they belong to.
+// Returns io.EOF when the stream ends after Close or when FFmpeg finishes on
+// its own, and a *CaptureError when the encoder fails mid-stream.
 func (r *H264VideoReader) Read() (*NALUnit, error) {
-	// Read H.264 NAL units from raw bitstream (annexb format)
-	// Each NAL unit is preceded by start code: 0x00 0x00 0x00 0x01 or 0x00 0x00 0x01
+	for {
+		if err := r.waitResumed(); err != nil {
+			return nil, err
+		}
+		if len(r.ready) > 0 {
+			nal := r.ready[0]
+			r.ready = r.ready[1:]
+			now := time.Now()
+			r.stats.observe(nal, now)
+			if isFirstSliceOfPicture(nal) {
+				r.health.observe(now, r.cfg.FrameRate, r.proc.Warnings())
+				r.health.observeLag(now, nal.PTS)
+			}
+			return nal, nil
+		}
 
-	// Read a buffer to find NAL units
-	buf := make([]byte, 4096)
-	n, err := io.ReadFull(r.proc, buf)
-	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			return nil, io.EOF
+		nal, err := r.readNAL()
+		if err == io.EOF && len(r.held) > 0 {
+			// Parameter sets without a following picture keep the
+			// timestamps of the last one.
+			r.release(r.timing.pts, r.timing.dts)
+			continue
+		}
+		if err != nil {
+			return nil, err
 		}
-		return nil, fmt.Errorf("failed to read H264 data: %w", err)
-	}
 
-	// Parse NAL units from the buffer
-	nalus := parseH264Bitstream(buf[:n])
-	if len(nalus) == 0 {
-		return nil, nil
+		pts, dts := r.timing.observe(nal)
+		r.held = append(r.held, nal)
+		// Non-VCL units (SPS/PPS/SEI/AUD) precede the first slice of their
+		// picture; hold them until its timestamps are known.
+		if isVCL(nal) {
+			r.release(pts, dts)
+		}
 	}
+}
 
-	// Return the first NAL unit
-	return nalus[0], nil
+// Pause stops delivering NAL units without closing the device: Read blocks
+// until Resume. By default the encoder keeps running and its output is
+// discarded while paused; with suspendEncoder, the FFmpeg process is
+// suspended instead, saving its CPU time.
+//
+// Timestamps continue after the pause as if it had not happened, and
+// delivery resumes at the next IDR access unit. FFmpeg cannot be asked for
+// a keyframe, so the first Read after Resume can block for up to one
+// keyframe interval; shorten it with H264ReaderConfig.Keyframes.
+func (r *H264VideoReader) Pause(suspendEncoder bool) error {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.resumec != nil {
+		return nil
+	}
+	if suspendEncoder {
+		// A replacement encoder that Read has not switched to yet is the
+		// one running; the encoder before it has been stopped.
+		proc := r.proc
+		if r.next != nil {
+			proc = r.next
+		}
+		if err := proc.Suspend(); err != nil {
+			return fmt.Errorf("suspend encoder: %w", err)
+		}
+		r.suspended = proc
+	}
+	r.resumec = make(chan struct{})
+	return nil
 }
 
-// parseH264Bitstream parses H.264 raw bitstream (annexb format) and extracts NAL units.
-func parseH264Bitstream(data []byte) []*NALUnit {
-	var nalus []*NALUnit
-	i := 0
+// Resume continues delivery after Pause, at the next IDR access unit.
+func (r *H264VideoReader) Resume() error {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.resumeLocked()
+}
 
-	for i < len(data) {
-		// Find start code (0x00 0x00 0x00 0x01 or 0x00 0x00 0x01)
-		startCodeLen := 0
-		for i < len(data)-3 {
-			if data[i] == 0x00 && data[i+1] == 0x00 && data[i+2] == 0x01 {
-				startCodeLen = 3
-				break
-			}
-			if i < len(data)-4 && data[i] == 0x00 && data[i+1] == 0x00 && data[i+2] == 0x00 && data[i+3] == 0x01 {
-				startCodeLen = 4
-				break
-			}
-			i++
+func (r *H264VideoReader) resumeLocked() error {
+	if r.resumec == nil {
+		return nil
+	}
+	var err error
+	if r.suspended != nil {
+		if err = r.suspended.Resume(); err != nil {
+			err = fmt.Errorf("resume encoder: %w", err)
 		}
+		r.suspended = nil
+	}
+	close(r.resumec)
+	r.resumec = nil
+	r.health.resetLag()
+	return err
+}
+
+// Paused reports whether the reader is paused.
+func (r *H264VideoReader) Paused() bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.resumec != nil
+}
 
-		if startCodeLen == 0 {
+// waitResumed blocks while the reader is paused, draining and discarding
+// the output of a running encoder. After a pause, units queued from before
+// it are dropped and the stream restarts at the next SPS.
+func (r *H264VideoReader) waitResumed() error {
+	paused := false
+	for {
+		r.mu.Lock()
+		resumec, suspended := r.resumec, r.suspended != nil
+		r.mu.Unlock()
+		if resumec == nil {
 			break
 		}
+		if !paused {
+			paused = true
+			r.held = r.held[:0]
+			r.ready = r.ready[:0]
+		}
+		if suspended {
+			<-resumec
+			continue
+		}
+		if _, err := r.readNAL(); err != nil {
+			return err
+		}
+	}
+	if paused {
+		r.awaitIDR = true
+	}
+	return nil
+}
 
-		i += startCodeLen
-		if i >= len(data) {
-			break
+// release stamps the held NAL units with pts and dts and queues them.
+func (r *H264VideoReader) release(pts, dts time.Duration) {
+	for _, nal := range r.held {
+		nal.PTS, nal.DTS = pts, dts
+	}
+	r.ready = append(r.ready, r.held...)
+	r.held = r.held[:0]
+}
+
+// readNAL reads the next NAL unit from the encoder in stream order.
+func (r *H264VideoReader) readNAL() (*NALUnit, error) {
+	// Read H.264 NAL units from raw bitstream (annexb format)
+	// Each NAL unit is preceded by start code: 0x00 0x00 0x00 0x01 or 0x00 0x00 0x01
+	for {
+		r.swapEncoder()
+
+		if nal := r.nextNAL(false); nal != nil {
+			if r.awaitIDR {
+				if nal.Type != NALUTypeSPS {
+					continue
+				}
+				r.awaitIDR = false
+			}
+			return nal, nil
 		}
 
-		// Find next start code or end of data
-		j := i
-		for j < len(data)-4 {
-			if data[j] == 0x00 && data[j+1] == 0x00 && data[j+2] == 0x00 && data[j+3] == 0x01 {
-				break
+		n, err := r.proc.Read(r.readBuf)
+		if n > 0 {
+			r.pending = append(r.pending, r.readBuf[:n]...)
+		}
+		if err != nil {
+			if r.restarted() {
+				// The old encoder was stopped for a resolution change.
+				continue
 			}
-			if data[j] == 0x00 && data[j+1] == 0x00 && data[j+2] == 0x01 {
-				break
+			if endOfOutput(err) {
+				// Flush the final NAL unit, which has no following start code.
+				if nal := r.nextNAL(true); nal != nil {
+					return nal, nil
+				}
+				return nil, r.streamEnd()
 			}
-			j++
+			return nil, r.captureError(newCaptureError(fmt.Errorf("failed to read H264 data: %w", err), r.proc.LastStderr()))
 		}
+	}
+}
 
-		nalData := data[i:j]
-		if len(nalData) > 0 {
-			nalType := H264NaluType(nalData[0] & 0x1F)
-			nalus = append(nalus, &NALUnit{
-				Type:     nalType,
-				Data:     nalData,
-				Keyframe: nalType.IsKeyframe(),
-			})
-		}
+// SetResolution changes the encoded resolution of a live reader without
+// tearing it down. Capture devices are exclusive on most platforms, so the
+// running encoder is stopped and a new one is started with the updated
+// size; Read switches to it and resumes at its first IDR access unit, which
+// begins with the new SPS/PPS. The stream pauses for the startup time of
+// the new encoder. NAL units already buffered from the old encoder are
+// discarded.
+func (r *H264VideoReader) SetResolution(width, height int) error {
+	if width <= 0 || height <= 0 || width%2 != 0 || height%2 != 0 {
+		return fmt.Errorf("resolution must be positive and even (got %dx%d)", width, height)
+	}
 
-		i = j
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if r.governor != nil {
+		// The requested size becomes the governor's full-quality level.
+		cfg = r.governor.baseConfig()
 	}
+	cfg.Width = width
+	cfg.Height = height
 
-	return nalus
+	if err := r.restartEncoder(cfg); err != nil {
+		return err
+	}
+	if r.governor != nil {
+		r.governor.reset(cfg)
+	}
+	return nil
 }
 
-// parseTSPacket parses an MPEG-TS packet and extracts H264 NAL units.
-func parseTSPacket(data []byte) ([]*NALUnit, error) {
-	if len(data) < 188 {
-		return nil, fmt.Errorf("invalid TS packet: too short (%d bytes)", len(data))
+// SetBitRate changes the target bitrate (in kbps) of a live reader. Like
+// SetResolution, it restarts the encoder behind the scenes and resumes at
+// the next IDR frame. A configured rate-control buffer is scaled to keep
+// its duration.
+func (r *H264VideoReader) SetBitRate(kbps int) error {
+	if kbps <= 0 {
+		return fmt.Errorf("bitrate must be positive (got %d kbps)", kbps)
 	}
 
-	// Check sync byte
-	if data[0] != 0x47 {
-		return nil, fmt.Errorf("invalid TS sync byte: 0x%02x", data[0])
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if r.governor != nil {
+		// Keep the governor's current preset and resolution.
+		cfg = r.governor.setBitRate(kbps)
+	} else {
+		cfg.BufferSize = scaleBufferSize(cfg.BufferSize, cfg.BitRate, kbps)
+		cfg.BitRate = kbps
 	}
+	return r.restartEncoder(cfg)
+}
 
-	var nalus []*NALUnit
-
-	// Parse TS header (first 4 bytes)
-	pid := int(data[1]&0x1F)<<8 | int(data[2])
-	adaptationFieldControl := (data[3] >> 0) & 0x03
-
-	// Debug: print all PIDs
-	_ = pid
-	// fmt.Printf("[DEBUG parseTSPacket] PID: 0x%x, adaptation: %d\n", pid, adaptationFieldControl)
+// BitRate returns the current target bitrate in kbps, 0 for encoder default.
+func (r *H264VideoReader) BitRate() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.cfg.BitRate
+}
 
-	// Skip non-video packets
-	// Note: SPS/PPS may be in different PIDs than video
-	// Let's be more permissive for debugging
-	if pid < 0x10 || pid > 0x1FFE {
-		return nil, nil
+// scaleBufferSize rescales a rate-control buffer from one bitrate to another.
+func scaleBufferSize(bufferSize, from, to int) int {
+	if bufferSize <= 0 || from <= 0 {
+		return bufferSize
 	}
+	return int(int64(bufferSize) * int64(to) / int64(from))
+}
 
-	// Skip adaptation field if present
-	offset := 4
-	if adaptationFieldControl&0x02 != 0 {
-		if offset >= len(data) {
-			return nil, nil
+// startH264Encoder starts an encoder process for cfg whose stderr lines go
+// to stderr. With ZMQControl, each process gets its own zmq port, so that a
+// replacement encoder does not wait for the old one to release its port.
+func (m *MediaDevices) startH264Encoder(cfg H264ReaderConfig, stderr *stderrFeed) (*ffmpegProcess, error) {
+	var addr string
+	if cfg.ZMQControl {
+		port, err := freeLocalPort()
+		if err != nil {
+			return nil, fmt.Errorf("zmq control port: %w", err)
+		}
+		addr = fmt.Sprintf("tcp://127.0.0.1:%d", port)
+		if cfg.VideoFilter != "" {
+			cfg.VideoFilter += ","
 		}
-		adaptationFieldLength := int(data[offset])
-		offset += 1 + adaptationFieldLength
+		cfg.VideoFilter += ZMQFilter(addr)
 	}
 
-	if offset >= len(data) {
-		return nil, nil
+	gcfg := m.Config()
+	gcfg.ArgsHook = chainArgsHooks(gcfg.ArgsHook, cfg.ArgsHook)
+	gcfg.stderrFeed = stderr
+	proc, err := startInteractiveProcess(gcfg, buildH264Args(cfg))
+	if err != nil {
+		return nil, err
 	}
+	if addr != "" {
+		proc.zmq, _ = DialZMQ(addr)
+	}
+	return proc, nil
+}
 
-	// Look for PES header start (0x00 0x00 0x01)
-	pesStart := -1
-	for i := offset; i < len(data)-3; i++ {
-		if data[i] == 0x00 && data[i+1] == 0x00 && data[i+2] == 0x01 {
-			pesStart = i + 3
-			break
-		}
+// freeLocalPort returns a TCP port on the loopback interface that is free
+// at the time of the call.
+func freeLocalPort() (int, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, err
 	}
+	defer l.Close()
+	return l.Addr().(*net.TCPAddr).Port, nil
+}
 
-	if pesStart == -1 {
-		return nil, nil // No PES header found
+// restartEncoder stops the running encoder, starts a new one for cfg and
+// schedules the switch to it. The old encoder must release the device
+// before the new one can open it. If the new encoder fails to start, one
+// with the previous configuration is started instead and the error is
+// returned.
+func (r *H264VideoReader) restartEncoder(cfg H264ReaderConfig) error {
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return errors.New("ffmpeg restart H264 capture: reader is closed")
 	}
+	// A replacement that Read has not switched to yet is the running
+	// encoder; the one before it has been stopped already.
+	old := r.proc
+	if r.next != nil {
+		old = r.next
+	}
+	if r.suspended == old {
+		// Continue the old encoder so that it exits.
+		old.Resume()
+	}
+	prev := r.cfg
+	done := make(chan struct{})
+	r.restarting = done
+	r.mu.Unlock()
+	defer func() {
+		r.mu.Lock()
+		r.restarting = nil
+		r.mu.Unlock()
+		close(done)
+	}()
+
+	old.Stop()
+	proc, err := r.mediaDevices().startH264Encoder(cfg, r.stderr)
+	if err != nil {
+		err = fmt.Errorf("ffmpeg restart H264 capture: %w", err)
+		if proc, perr := r.mediaDevices().startH264Encoder(prev, r.stderr); perr == nil {
+			r.switchTo(proc, old, prev)
+		}
+		return err
+	}
+	r.switchTo(proc, old, cfg)
+	return nil
+}
 
-	// PES header: skip stream_id and PES length
-	if pesStart+2 >= len(data) {
-		return nil, nil
+// streamEnd returns the error for the end of the encoder output: io.EOF
+// after Close, and otherwise that of the running encoder.
+func (r *H264VideoReader) streamEnd() error {
+	r.mu.Lock()
+	closed := r.closed
+	r.mu.Unlock()
+	if closed {
+		return io.EOF
+	}
+	err := r.proc.streamEnd("read H264 data")
+	if ce, ok := err.(*CaptureError); ok {
+		r.captureError(ce)
 	}
-	pesStart += 2
+	return err
+}
 
-	// Skip PES optional header if present
-	if pesStart >= len(data) {
-		return nil, nil
+// captureError describes a busy capture device in ce by the process holding
+// it and returns ce.
+func (r *H264VideoReader) captureError(ce *CaptureError) *CaptureError {
+	device := r.cfg.DeviceName
+	if device == "" {
+		device = r.cfg.DeviceID
 	}
-	pesHeaderLength := int(data[pesStart])
-	pesStart += 1 + pesHeaderLength
+	return describeDeviceHolder(ce, MediaDeviceKindVideoInput, device)
+}
 
-	if pesStart >= len(data) {
-		return nil, nil
+// mediaDevices returns the MediaDevices that opened the reader, whose
+// configuration replacement encoders use.
+func (r *H264VideoReader) mediaDevices() *MediaDevices {
+	if r.owner == nil {
+		return defaultMediaDevices
 	}
+	return r.owner
+}
 
-	// Extract NAL units from PES payload
-	pesPayload := data[pesStart:]
-	nalus = append(nalus, parseNALUnits(pesPayload)...)
+// encoderStderr returns the stderr tail of the newest encoder.
+func (r *H264VideoReader) encoderStderr() string {
+	return r.newestEncoder().LastStderr()
+}
 
-	return nalus, nil
+// newestEncoder returns the replacement encoder if one is waiting, and the
+// running encoder otherwise.
+func (r *H264VideoReader) newestEncoder() *ffmpegProcess {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.next != nil {
+		return r.next
+	}
+	return r.proc
 }
 
-// parseNALUnits parses a slice of PES payload data and extracts NAL units.
-func parseNALUnits(data []byte) []*NALUnit {
-	var nalus []*NALUnit
-	i := 0
+// StderrLines returns up to the last n lines of the encoder's stderr,
+// oldest first, or all lines kept if n <= 0. How much stderr is kept is set
+// by Config.StderrBufferSize. After SetResolution or a governor step, only
+// lines of the new encoder are returned.
+func (r *H264VideoReader) StderrLines(n int) []string {
+	return r.newestEncoder().StderrLines(n)
+}
 
-	for i < len(data) {
-		// Find start code (0x00 0x00 0x00 0x01 or 0x00 0x00 0x01)
-		startCodeLen := 0
-		for i < len(data)-3 {
-			if data[i] == 0x00 && data[i+1] == 0x00 && data[i+2] == 0x01 {
-				startCodeLen = 3
-				break
-			}
-			if data[i] == 0x00 && data[i+1] == 0x00 && data[i+2] == 0x00 && data[i+3] == 0x01 {
-				startCodeLen = 4
-				break
-			}
-			i++
-		}
+// OnStderrLine calls fn with every line the encoder writes to stderr from
+// now on, until cancel is called. Subscriptions carry over encoder
+// restarts. fn runs on the goroutine draining stderr and must not block.
+func (r *H264VideoReader) OnStderrLine(fn func(line string)) (cancel func()) {
+	return r.stderr.subscribe(fn)
+}
 
-		if startCodeLen == 0 {
-			break
+// switchTo schedules proc, an encoder for cfg, to replace old, which has
+// been stopped. A pause that suspended old carries over to proc, which
+// Resume then continues.
+func (r *H264VideoReader) switchTo(proc, old *ffmpegProcess, cfg H264ReaderConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.next = proc
+	r.cfg = cfg
+	if r.suspended == old {
+		r.suspended = nil
+		if proc.Suspend() == nil {
+			r.suspended = proc
 		}
+	}
+}
 
-		i += startCodeLen
-		if i >= len(data) {
-			break
-		}
+// restarted waits for a restart in progress to finish and reports whether a
+// replacement encoder is waiting to be swapped in.
+func (r *H264VideoReader) restarted() bool {
+	r.mu.Lock()
+	done := r.restarting
+	r.mu.Unlock()
+	if done != nil {
+		<-done
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.next != nil
+}
 
-		// Find next start code or end of data
-		j := i
-		for j < len(data)-4 {
-			if data[j] == 0x00 && data[j+1] == 0x00 && data[j+2] == 0x00 && data[j+3] == 0x01 {
-				break
-			}
-			if data[j] == 0x00 && data[j+1] == 0x00 && data[j+2] == 0x01 {
-				break
+// swapEncoder replaces the running encoder with the pending one, if any.
+func (r *H264VideoReader) swapEncoder() {
+	r.mu.Lock()
+	next := r.next
+	if next == nil {
+		r.mu.Unlock()
+		return
+	}
+	// The old encoder was stopped by restartEncoder.
+	r.proc = next
+	r.next = nil
+	r.width = r.cfg.Width
+	r.height = r.cfg.Height
+	r.mu.Unlock()
+
+	r.pending = r.pending[:0]
+	r.awaitIDR = true
+	r.health.resetLag()
+}
+
+// nextNAL extracts the first complete NAL unit from the pending buffer.
+// A NAL unit is complete once the following start code has been received,
+// or at end of stream when final is true. Returns nil if none is complete.
+// Garbage before a start code and invalid NAL units are skipped and
+// counted in r.anomalies.
+func (r *H264VideoReader) nextNAL(final bool) *NALUnit {
+	for {
+		start := bytes.Index(r.pending, annexBStartCode)
+		if start < 0 {
+			if final {
+				skipToStartCode(r.pending, &r.anomalies)
+				r.pending = r.pending[:0]
+			} else if keep := len(annexBStartCode) - 1; len(r.pending) > keep {
+				// Keep what may be the beginning of a split start code.
+				skipToStartCode(r.pending[:len(r.pending)-keep], &r.anomalies)
+				r.pending = append(r.pending[:0], r.pending[len(r.pending)-keep:]...)
 			}
-			j++
+			return nil
+		}
+		if start > 0 {
+			skipToStartCode(r.pending[:start], &r.anomalies)
+			r.pending = r.pending[start:]
 		}
+		payload := len(annexBStartCode)
 
-		nalData := data[i:j]
-		if len(nalData) > 0 {
-			nalType := H264NaluType(nalData[0] & 0x1F)
-			nalus = append(nalus, &NALUnit{
-				Type:     nalType,
-				Data:     nalData,
-				Keyframe: nalType.IsKeyframe(),
-			})
+		end := len(r.pending)
+		if next := bytes.Index(r.pending[payload:], annexBStartCode); next >= 0 {
+			end = payload + next
+		} else if !final {
+			return nil
 		}
 
-		i = j
+		// The leading zero of a 4-byte start code (and any trailing_zero_8bits)
+		// precede the next 3-byte start code; they are not part of this NAL.
+		data := bytes.TrimRight(r.pending[payload:end], "\x00")
+		r.pending = r.pending[end:]
+		if nal := newNALUnit(append([]byte(nil), data...), &r.anomalies); nal != nil {
+			return nal
+		}
 	}
+}
+
+// Stats returns bitrate, frame rate, keyframe interval and frame size
+// statistics of the encoded stream over the configured sliding window.
+func (r *H264VideoReader) Stats() EncoderStats {
+	st := r.stats.snapshot(time.Now())
+	st.Anomalies = r.anomalies.snapshot()
+	return st
+}
 
-	return nalus
+// Health scores the encoded stream over the statistics window: its frame
+// rate against the configured one, frame interval jitter, dropped frames,
+// encoder lag and FFmpeg warnings. See StreamHealth.
+func (r *H264VideoReader) Health() StreamHealth {
+	return r.health.snapshot(time.Now())
 }
 
 // Width returns the video width in pixels.
 func (r *H264VideoReader) Width() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.width
 }
 
 // Height returns the video height in pixels.
 func (r *H264VideoReader) Height() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	return r.height
 }
 
 // Close stops the FFmpeg subprocess and releases resources.
 func (r *H264VideoReader) Close() error {
+	r.mediaDevices().resources.removeReader(r)
+	if r.governor != nil {
+		r.governor.Stop()
+	}
+	r.restartMu.Lock()
+	defer r.restartMu.Unlock()
+	r.mu.Lock()
+	r.closed = true
+	next := r.next
+	r.next = nil
+	// Unblock a paused Read; it then sees the end of the stream.
+	r.resumeLocked()
+	r.mu.Unlock()
+	if next != nil {
+		// The running encoder was stopped when next was started.
+		return next.Stop()
+	}
 	if r.proc != nil {
 		return r.proc.Stop()
 	}
@@ -405,19 +904,53 @@ func (r *H264VideoReader) Close() error {
 // RTPReader reads H264 data and packages it into RTP packets.
 type RTPReader struct {
 	reader *H264VideoReader
-	ssrc   uint32
+	ssrc   atomic.Uint32
 	seq    uint16
 	ts     uint32
 	mtu    int
 
+	// seqCycles counts wraparounds of the 16-bit sequence number, forming
+	// the extended sequence number used by RTCP and SRTP.
+	seqCycles uint32
+
+	// tsBase is the random timestamp offset; inAU reports whether the
+	// current access unit has been started.
+	tsBase uint32
+	inAU   bool
+
+	// lookahead holds NAL units read from the encoder but not yet
+	// packetized, used to find the end of an access unit and by PeekNAL and
+	// PeekAccessUnit; nextErr is returned once it has been drained. queue
+	// holds packets of the current NAL unit not yet returned by Read.
+	lookahead []*NALUnit
+	nextErr   error
+	queue     []*rtp.Packet
+
 	// Cached SPS/PPS for keyframe injection
 	sps []byte
 	pps []byte
 }
 
+// RTP packet overheads. The configured MTU covers the IPv4 and UDP headers,
+// the RTP header and the payload.
+const (
+	ipUDPHeaderSize = 20 + 8
+	rtpHeaderSize   = 12
+	fuAHeaderSize   = 2 // FU indicator + FU header
+)
+
 // NewRTPReader creates a new RTP reader for H264 video streaming.
+// mtu is the maximum IP packet size; every RTP packet, including its IPv4
+// and UDP headers, fits within it. If initialSSRC is 0, a random SSRC is chosen. The initial sequence number
+// and timestamp are always random, as recommended by RFC 3550.
 func NewRTPReader(cfg H264ReaderConfig, initialSSRC uint32, mtu int) (*RTPReader, error) {
-	reader, err := newH264VideoReader(cfg)
+	return defaultMediaDevices.NewRTPReader(cfg, initialSSRC, mtu)
+}
+
+// NewRTPReader is like the package-level NewRTPReader, but captures and
+// encodes with the configuration of m.
+func (m *MediaDevices) NewRTPReader(cfg H264ReaderConfig, initialSSRC uint32, mtu int) (*RTPReader, error) {
+	reader, err := m.newH264VideoReader(cfg)
 	if err != nil {
 		return nil, err
 	}
@@ -426,61 +959,203 @@ func NewRTPReader(cfg H264ReaderConfig, initialSSRC uint32, mtu int) (*RTPReader
 		mtu = 1200 // Safe default for RTP over UDP
 	}
 
-	return &RTPReader{
+	if initialSSRC == 0 {
+		initialSSRC = randomUint32()
+	}
+
+	r := &RTPReader{
 		reader: reader,
-		ssrc:   initialSSRC,
-		seq:    uint16(initialSSRC),
-		ts:     0,
+		seq:    uint16(randomUint32()),
+		tsBase: randomUint32(),
 		mtu:    mtu,
-	}, nil
+	}
+	r.ssrc.Store(initialSSRC)
+	return r, nil
 }
 
-// Read reads the next RTP packet.
-func (r *RTPReader) Read() (*rtp.Packet, error) {
+// randomUint32 returns a cryptographically random 32-bit value.
+func randomUint32() uint32 {
+	var b [4]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		// crypto/rand does not fail on supported platforms; fall back to
+		// the clock rather than a fixed value.
+		return uint32(time.Now().UnixNano())
+	}
+	return binary.BigEndian.Uint32(b[:])
+}
+
+// SSRC returns the synchronization source identifier of outgoing packets.
+func (r *RTPReader) SSRC() uint32 {
+	return r.ssrc.Load()
+}
+
+// CheckSSRCCollision reports whether remoteSSRC, seen from another
+// participant (e.g. in received RTP or RTCP), collides with this reader's
+// SSRC. On a collision a new random SSRC is chosen, per RFC 3550 section
+// 8.2; it applies to all packets read afterwards. It is safe to call
+// concurrently with Read.
+func (r *RTPReader) CheckSSRCCollision(remoteSSRC uint32) bool {
+	if remoteSSRC != r.ssrc.Load() {
+		return false
+	}
 	for {
-		nal, err := r.reader.Read()
+		ssrc := randomUint32()
+		if ssrc != 0 && ssrc != remoteSSRC {
+			r.ssrc.Store(ssrc)
+			return true
+		}
+	}
+}
+
+// ExtendedSequenceNumber returns the sequence number of the last packet
+// together with the count of 16-bit wraparounds in the upper bits.
+func (r *RTPReader) ExtendedSequenceNumber() uint64 {
+	return uint64(r.seqCycles)<<16 | uint64(r.seq)
+}
+
+// Read reads the next RTP packet. Fragments of a large NAL unit are
+// returned by consecutive calls. Unlike ReadMultiple, Read does not wait
+// for the rest of the access unit before returning its first packets.
+func (r *RTPReader) Read() (*rtp.Packet, error) {
+	if len(r.queue) == 0 {
+		packets, _, err := r.readNALPackets()
 		if err != nil {
 			return nil, err
 		}
-		if nal == nil {
-			continue
-		}
-
-		return r.nalToRTP(nal)
+		r.queue = packets
 	}
+	pkt := r.queue[0]
+	r.queue = r.queue[1:]
+	return pkt, nil
 }
 
-// ReadMultiple reads all RTP packets for the current NAL unit.
+// ReadMultiple reads all RTP packets of the next access unit: every NAL
+// unit of one picture, up to the next access unit delimiter, parameter set
+// or first slice of a new picture. The packets share the access unit's
+// timestamp, and the marker bit is set on the last one only. Packets that
+// Read has left over from a partly read access unit are returned first,
+// followed by the rest of it.
 func (r *RTPReader) ReadMultiple() ([]*rtp.Packet, error) {
+	packets := r.queue
+	r.queue = nil
+	if n := len(packets); n > 0 && packets[n-1].Marker {
+		return packets, nil
+	}
 	for {
-		nal, err := r.reader.Read()
+		p, last, err := r.readNALPackets()
 		if err != nil {
+			if len(packets) > 0 && r.nextErr != nil {
+				// The stream ended inside the access unit; the error
+				// is returned by the next call.
+				return packets, nil
+			}
 			return nil, err
 		}
-		if nal == nil {
-			continue
+		packets = append(packets, p...)
+		if last {
+			return packets, nil
 		}
+	}
+}
 
-		// Cache SPS/PPS when found
-		if r.sps == nil && nal.Type == NALUTypeSPS {
-			r.sps = make([]byte, len(nal.Data))
-			copy(r.sps, nal.Data)
-		}
-		if r.pps == nil && nal.Type == NALUTypePPS {
-			r.pps = make([]byte, len(nal.Data))
-			copy(r.pps, nal.Data)
+// readNALPackets reads the RTP packets of the next NAL unit and reports
+// whether it ends its access unit.
+func (r *RTPReader) readNALPackets() ([]*rtp.Packet, bool, error) {
+	nal, last, err := r.readNAL()
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Cache SPS/PPS when found; they change after SetResolution
+	if nal.Type == NALUTypeSPS && !bytes.Equal(r.sps, nal.Data) {
+		r.sps = make([]byte, len(nal.Data))
+		copy(r.sps, nal.Data)
+	}
+	if nal.Type == NALUTypePPS && !bytes.Equal(r.pps, nal.Data) {
+		r.pps = make([]byte, len(nal.Data))
+		copy(r.pps, nal.Data)
+	}
+
+	if !r.inAU {
+		r.ts = r.timestamp(nal.PTS)
+		r.inAU = true
+	}
+	if last {
+		r.inAU = false
+	}
+	packets, err := r.nalToRTPMultiple(nal, last)
+	return packets, last, err
+}
+
+// readNAL returns the next NAL unit and whether it is the last one of its
+// access unit. The end of an access unit is only known once the following
+// NAL unit (or the end of the stream) has been read, so at least one NAL
+// unit of lookahead is kept.
+func (r *RTPReader) readNAL() (*NALUnit, bool, error) {
+	r.fill(2)
+	if len(r.lookahead) == 0 {
+		return nil, false, r.nextErr
+	}
+	nal := r.lookahead[0]
+	r.lookahead[0] = nil
+	r.lookahead = r.lookahead[1:]
+	return nal, r.endsAccessUnit(0, nal), nil
+}
+
+// fill reads NAL units from the encoder until the lookahead holds n of
+// them or reading fails; the error is kept in nextErr.
+func (r *RTPReader) fill(n int) {
+	for len(r.lookahead) < n && r.nextErr == nil {
+		nal, err := r.reader.Read()
+		if err != nil {
+			r.nextErr = err
+			return
 		}
+		r.lookahead = append(r.lookahead, nal)
+	}
+}
 
-		return r.nalToRTPMultiple(nal)
+// endsAccessUnit reports whether nal, which precedes lookahead[i], is the
+// last NAL unit of its access unit. The lookahead must have been filled
+// past i, so that a missing lookahead[i] means the end of the stream.
+func (r *RTPReader) endsAccessUnit(i int, nal *NALUnit) bool {
+	if !isVCL(nal) {
+		return false
 	}
+	return i >= len(r.lookahead) || startsAccessUnit(r.lookahead[i])
 }
 
-// PeekNAL returns the current NAL unit without consuming it.
-// Returns nil if no NAL unit is available.
+// PeekNAL returns the next NAL unit without consuming it: the following
+// Read or ReadMultiple packetizes it. It blocks until the encoder has
+// produced it. At the end of the stream it returns the error Read will
+// return once the packets before it have been read.
 func (r *RTPReader) PeekNAL() (*NALUnit, error) {
-	// Note: This is a simplified implementation that reads and caches the NAL
-	// In a production implementation, you might want to use a buffer
-	return r.reader.Read()
+	r.fill(1)
+	if len(r.lookahead) == 0 {
+		return nil, r.nextErr
+	}
+	return r.lookahead[0], nil
+}
+
+// PeekAccessUnit returns the NAL units up to the end of the next access
+// unit without consuming them, for example to decide whether small NAL
+// units can be aggregated. If an access unit is partly read, the rest of
+// it is returned. The NAL units stay queued for Read and ReadMultiple and
+// must not be modified. At the end of the stream the last, possibly
+// incomplete, access unit is returned, or the error if nothing is left.
+func (r *RTPReader) PeekAccessUnit() ([]*NALUnit, error) {
+	for i := 0; ; i++ {
+		r.fill(i + 2)
+		if i >= len(r.lookahead) {
+			if i == 0 {
+				return nil, r.nextErr
+			}
+			return slices.Clone(r.lookahead[:i]), nil
+		}
+		if r.endsAccessUnit(i+1, r.lookahead[i]) {
+			return slices.Clone(r.lookahead[:i+1]), nil
+		}
+	}
 }
 
 // GetSPSPPS returns the cached SPS and PPS.
@@ -489,96 +1164,49 @@ func (r *RTPReader) GetSPSPPS() ([]byte, []byte) {
 	return r.sps, r.pps
 }
 
-// nalToRTP converts an H264 NAL unit to RTP packet.
-func (r *RTPReader) nalToRTP(nal *NALUnit) (*rtp.Packet, error) {
-	nalLen := len(nal.Data)
-	maxPayloadSize := r.mtu - 20 // Reserve space for IP/UDP headers
-
-	if nalLen <= maxPayloadSize-12 {
-		// Single NAL unit packet
-		return &rtp.Packet{
-			Header: rtp.Header{
-				Version:        2,
-				Marker:         true,
-				PayloadType:    96,
-				SequenceNumber: r.nextSeq(),
-				Timestamp:      r.nextTS(),
-				SSRC:          r.ssrc,
-			},
-			Payload: nal.Data,
-		}, nil
-	}
-
-	// Fragmentation Unit (FU) for large NAL units
-	packets, err := r.nalToRTPMultiple(nal)
-	if err != nil {
-		return nil, err
+// nalToRTPMultiple converts an H264 NAL unit to one RTP packet, or to
+// FU-A fragments if it does not fit the MTU. marker is set on the final
+// packet when the NAL unit ends an access unit.
+func (r *RTPReader) nalToRTPMultiple(nal *NALUnit, marker bool) ([]*rtp.Packet, error) {
+	if len(nal.Data) == 0 {
+		return nil, fmt.Errorf("empty NAL unit")
 	}
+	maxPayloadSize := r.mtu - ipUDPHeaderSize - rtpHeaderSize
 
-	if len(packets) > 0 {
-		return packets[0], nil
+	if len(nal.Data) <= maxPayloadSize {
+		return []*rtp.Packet{r.newPacket(nal.Data, marker)}, nil
 	}
 
-	return nil, fmt.Errorf("failed to create RTP packet")
-}
-
-// nalToRTPMultiple converts an H264 NAL unit to multiple RTP packets.
-func (r *RTPReader) nalToRTPMultiple(nal *NALUnit) ([]*rtp.Packet, error) {
-	nalLen := len(nal.Data)
-	maxPayloadSize := r.mtu - 20
-
-	if nalLen <= maxPayloadSize-12 {
-		return []*rtp.Packet{
-			{
-				Header: rtp.Header{
-					Version:        2,
-					Marker:         true,
-					PayloadType:    96,
-					SequenceNumber: r.nextSeq(),
-					Timestamp:      r.nextTS(),
-					SSRC:          r.ssrc,
-				},
-				Payload: nal.Data,
-			},
-		}, nil
+	// Fragmentation Unit (FU-A). The NAL header is not sent; its F/NRI bits
+	// go into the FU indicator and its type into the FU header.
+	fuIndicator := uint8(28) | (nal.Data[0] & 0xE0)
+	nalType := nal.Data[0] & 0x1F
+	payloadData := nal.Data[1:]
+	maxFragment := maxPayloadSize - fuAHeaderSize
+	if maxFragment <= 0 {
+		return nil, fmt.Errorf("MTU %d too small for FU-A fragmentation", r.mtu)
 	}
 
-	// Fragmentation Unit
-	fuIndicator := uint8(28) | (nal.Data[0] & 0xE0) // NRI from original NAL
-	fuHeader := uint8(0x00)
-	payloadData := nal.Data[1:]
-	offset := 0
 	var packets []*rtp.Packet
+	for offset := 0; offset < len(payloadData); {
+		chunkSize := len(payloadData) - offset
+		if chunkSize > maxFragment {
+			chunkSize = maxFragment
+		}
+		isLast := offset+chunkSize == len(payloadData)
 
-	for offset < len(payloadData) {
-		isLast := offset+maxPayloadSize-14 >= len(payloadData)
-		fuH := fuHeader
+		fuHeader := nalType
 		if offset == 0 {
-			fuH |= 0x80 // S bit (start)
+			fuHeader |= 0x80 // S bit (start)
 		}
 		if isLast {
-			fuH |= 0x40 // E bit (end)
+			fuHeader |= 0x40 // E bit (end)
 		}
 
-		chunkSize := len(payloadData) - offset
-		if chunkSize > maxPayloadSize-14 {
-			chunkSize = maxPayloadSize - 14
-		}
-
-		payload := []byte{fuIndicator, fuH}
+		payload := make([]byte, 0, fuAHeaderSize+chunkSize)
+		payload = append(payload, fuIndicator, fuHeader)
 		payload = append(payload, payloadData[offset:offset+chunkSize]...)
-
-		packets = append(packets, &rtp.Packet{
-			Header: rtp.Header{
-				Version:        2,
-				Marker:         isLast && nal.Keyframe,
-				PayloadType:    96,
-				SequenceNumber: r.nextSeq(),
-				Timestamp:      r.nextTS(),
-				SSRC:          r.ssrc,
-			},
-			Payload: payload,
-		})
+		packets = append(packets, r.newPacket(payload, isLast && marker))
 
 		offset += chunkSize
 	}
@@ -586,15 +1214,70 @@ func (r *RTPReader) nalToRTPMultiple(nal *NALUnit) ([]*rtp.Packet, error) {
 	return packets, nil
 }
 
+// newPacket builds an RTP packet for the current access unit.
+func (r *RTPReader) newPacket(payload []byte, marker bool) *rtp.Packet {
+	return &rtp.Packet{
+		Header: rtp.Header{
+			Version:        2,
+			Marker:         marker,
+			PayloadType:    96,
+			SequenceNumber: r.nextSeq(),
+			Timestamp:      r.ts,
+			SSRC:           r.ssrc.Load(),
+		},
+		Payload: payload,
+	}
+}
+
+// nextSeq returns the next sequence number. It wraps from 65535 to 0,
+// counting the cycle for the extended sequence number.
 func (r *RTPReader) nextSeq() uint16 {
 	r.seq++
+	if r.seq == 0 {
+		r.seqCycles++
+	}
 	return r.seq
 }
 
-func (r *RTPReader) nextTS() uint32 {
+// timestamp converts a presentation time to an RTP timestamp. RTP carries
+// the PTS; unsigned arithmetic wraps modulo 2^32 as required by RFC 3550.
+func (r *RTPReader) timestamp(pts time.Duration) uint32 {
 	// 90kHz timestamp clock (standard for MPEG)
-	r.ts += 3000 // 30fps = 3000 ticks per frame
-	return r.ts
+	return r.tsBase + uint32((uint64(pts)*9+50000)/100000)
+}
+
+// SetResolution changes the encoded resolution without interrupting the RTP
+// stream: SSRC, sequence numbers and timestamps continue, and the first
+// packets after the change carry the new SPS/PPS and an IDR frame.
+func (r *RTPReader) SetResolution(width, height int) error {
+	return r.reader.SetResolution(width, height)
+}
+
+// SetBitRate changes the encoder target bitrate (kbps) without interrupting
+// the RTP stream.
+func (r *RTPReader) SetBitRate(kbps int) error {
+	return r.reader.SetBitRate(kbps)
+}
+
+// BitRate returns the current encoder target bitrate in kbps.
+func (r *RTPReader) BitRate() int {
+	return r.reader.BitRate()
+}
+
+// Pause stops delivering packets; see H264VideoReader.Pause. RTP
+// timestamps continue after the pause without a gap.
+func (r *RTPReader) Pause(suspendEncoder bool) error {
+	return r.reader.Pause(suspendEncoder)
+}
+
+// Resume continues delivery after Pause.
+func (r *RTPReader) Resume() error {
+	return r.reader.Resume()
+}
+
+// Paused reports whether the reader is paused.
+func (r *RTPReader) Paused() bool {
+	return r.reader.Paused()
 }
 
 // Close closes the RTP reader and underlying video reader.
@@ -612,14 +1295,34 @@ func (r *RTPReader) Height() int {
 	return r.reader.Height()
 }
 
+// Stats returns statistics of the underlying encoded stream.
+func (r *RTPReader) Stats() EncoderStats {
+	return r.reader.Stats()
+}
+
+// Health scores the encoded stream; see H264VideoReader.Health.
+func (r *RTPReader) Health() StreamHealth {
+	return r.reader.Health()
+}
+
 // UDPWriter is a helper for writing RTP packets over UDP.
 type UDPWriter struct {
 	conn    *net.UDPConn
 	addr    *net.UDPAddr
 	payload int
+	// connected is set when conn was dialed to addr and must be written
+	// with Write rather than WriteToUDP.
+	connected bool
+
+	// RTCP transport, see UDPWriterConfig.RTCP. rtcpConn is nil unless
+	// rtcpMode is RTCPPortPair.
+	rtcpMode RTCPMode
+	rtcpConn *net.UDPConn
+	rtcpAddr *net.UDPAddr
 }
 
-// NewUDPWriter creates a new UDP writer for RTP streaming.
+// NewUDPWriter creates a new UDP writer for RTP streaming. For multicast
+// destinations and socket options, see NewUDPWriterWithConfig.
 func NewUDPWriter(addr string, mtu int) (*UDPWriter, error) {
 	udpAddr, err := net.ResolveUDPAddr("udp", addr)
 	if err != nil {
@@ -636,9 +1339,10 @@ func NewUDPWriter(addr string, mtu int) (*UDPWriter, error) {
 	}
 
 	return &UDPWriter{
-		conn:    conn,
-		addr:    udpAddr,
-		payload: mtu - 20 - 8, // MTU - IP header - UDP header
+		conn:      conn,
+		addr:      udpAddr,
+		payload:   mtu - 20 - 8, // MTU - IP header - UDP header
+		connected: true,
 	}, nil
 }
 
@@ -648,19 +1352,29 @@ func (w *UDPWriter) WritePacket(pkt *rtp.Packet) error {
 	if err != nil {
 		return err
 	}
-	_, err = w.conn.WriteToUDP(data, w.addr)
-	return err
+	return w.Write(data)
 }
 
 // Write writes raw data over UDP.
 func (w *UDPWriter) Write(data []byte) error {
-	_, err := w.conn.WriteToUDP(data, w.addr)
+	var err error
+	if w.connected {
+		_, err = w.conn.Write(data)
+	} else {
+		_, err = w.conn.WriteToUDP(data, w.addr)
+	}
 	return err
 }
 
 // Close closes the UDP connection.
 func (w *UDPWriter) Close() error {
-	return w.conn.Close()
+	err := w.conn.Close()
+	if w.rtcpConn != nil {
+		if cerr := w.rtcpConn.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
 }
 
 // LocalAddr returns the local UDP address.
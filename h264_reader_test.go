@@ -0,0 +1,442 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// shPrintf returns a /bin/sh script that writes data to stdout and then runs tail.
+func shPrintf(data []byte, tail string) []string {
+	var b strings.Builder
+	for _, c := range data {
+		fmt.Fprintf(&b, "\\%03o", c)
+	}
+	return []string{"-c", fmt.Sprintf("printf '%s'; %s", b.String(), tail)}
+}
+
+func annexB(nals ...[]byte) []byte {
+	var out []byte
+	for _, n := range nals {
+		out = append(out, 0, 0, 0, 1)
+		out = append(out, n...)
+	}
+	return out
+}
+
+func TestH264VideoReader_SwitchResumesAtIDR(t *testing.T) {
+	gcfg := Config{FFmpegPath: "/bin/sh"}
+
+	oldProc, err := startProcess(gcfg, shPrintf(annexB(
+		[]byte{0x67, 0x01}, // SPS
+		[]byte{0x41, 0x80}, // slice
+		[]byte{0x41, 0x80}, // slice, completed only by the switch
+	), "exec sleep 30"))
+	if err != nil {
+		t.Fatalf("start old encoder: %v", err)
+	}
+	r := &H264VideoReader{
+		cfg:     H264ReaderConfig{Width: 640, Height: 480},
+		proc:    oldProc,
+		width:   640,
+		height:  480,
+		readBuf: make([]byte, 4096),
+		timing:  newH264Timing(30, 0),
+		stats:   newEncoderStats(0),
+	}
+	defer r.Close()
+
+	for _, want := range []H264NaluType{NALUTypeSPS, NALUTypeSlice} {
+		nal, err := r.Read()
+		if err != nil || nal.Type != want {
+			t.Fatalf("old stream Read = %v, %v; want type %d", nal, err, want)
+		}
+	}
+
+	newProc, err := startProcess(gcfg, shPrintf(annexB(
+		[]byte{0x41, 0x80}, // leftover slice before the first IDR: dropped
+		[]byte{0x67, 0x02}, // new SPS
+		[]byte{0x68, 0x02}, // new PPS
+		[]byte{0x65, 0x80}, // IDR slice
+	), "exit 0"))
+	if err != nil {
+		t.Fatalf("start new encoder: %v", err)
+	}
+	oldProc.Stop()
+	r.switchTo(newProc, oldProc, H264ReaderConfig{Width: 1280, Height: 720})
+
+	for _, want := range []H264NaluType{NALUTypeSPS, NALUTypePPS, 5} {
+		nal, err := r.Read()
+		if err != nil || nal.Type != want {
+			t.Fatalf("new stream Read = %v, %v; want type %d", nal, err, want)
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Fatalf("Read at end = %v, want io.EOF", err)
+	}
+	if r.Width() != 1280 || r.Height() != 720 {
+		t.Errorf("size = %dx%d, want 1280x720", r.Width(), r.Height())
+	}
+}
+
+func TestH264VideoReader_SetResolutionExclusiveDevice(t *testing.T) {
+	// Like a V4L2 camera, the "device" fails to open while another process
+	// still has it open. The hook runs right before each start.
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	script := shPrintf(annexB(
+		[]byte{0x67, 0x01}, // SPS
+		[]byte{0x68, 0x01}, // PPS
+		[]byte{0x65, 0x80}, // IDR slice
+		[]byte{0x41, 0x80}, // slice, completed only by the restart
+	), "exec sleep 30")
+	script[1] = `echo $$ >"$0"; ` + script[1]
+	script = append(script, pidFile)
+	gcfg := Config{FFmpegPath: "/bin/sh", ArgsHook: func([]string) []string {
+		if b, err := os.ReadFile(pidFile); err == nil {
+			if pid, _ := strconv.Atoi(strings.TrimSpace(string(b))); syscall.Kill(pid, 0) == nil {
+				return []string{"-c", "echo busy >&2; exit 1"}
+			}
+		}
+		return script
+	}}
+	oldProc, err := startProcess(gcfg, nil)
+	if err != nil {
+		t.Fatalf("start old encoder: %v", err)
+	}
+	r := &H264VideoReader{
+		cfg:     H264ReaderConfig{Width: 640, Height: 480},
+		owner:   NewMediaDevices(gcfg),
+		proc:    oldProc,
+		width:   640,
+		height:  480,
+		readBuf: make([]byte, 4096),
+		timing:  newH264Timing(30, 0),
+		stats:   newEncoderStats(0),
+	}
+	defer r.Close()
+
+	for _, want := range []H264NaluType{NALUTypeSPS, NALUTypePPS, 5} {
+		if nal, err := r.Read(); err != nil || nal.Type != want {
+			t.Fatalf("old stream Read = %v, %v; want type %d", nal, err, want)
+		}
+	}
+	if err := r.SetResolution(1280, 720); err != nil {
+		t.Fatalf("SetResolution: %v", err)
+	}
+	for _, want := range []H264NaluType{NALUTypeSPS, NALUTypePPS, 5} {
+		if nal, err := r.Read(); err != nil || nal.Type != want {
+			t.Fatalf("new stream Read = %v, %v; want type %d", nal, err, want)
+		}
+	}
+	if r.Width() != 1280 || r.Height() != 720 {
+		t.Errorf("size = %dx%d, want 1280x720", r.Width(), r.Height())
+	}
+}
+
+func TestH264VideoReader_EncoderFailure(t *testing.T) {
+	proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, shPrintf(annexB(
+		[]byte{0x67, 0x01}, // SPS
+		[]byte{0x65, 0x80}, // IDR slice
+		[]byte{0x41, 0x80}, // slice, flushed at the end of the output
+	), "echo 'Device or resource busy' >&2; exit 1"))
+	if err != nil {
+		t.Fatalf("start encoder: %v", err)
+	}
+	r := &H264VideoReader{proc: proc, readBuf: make([]byte, 4096), timing: newH264Timing(30, 0), stats: newEncoderStats(0)}
+	defer r.Close()
+
+	for _, want := range []H264NaluType{NALUTypeSPS, 5, NALUTypeSlice} {
+		if nal, err := r.Read(); err != nil || nal.Type != want {
+			t.Fatalf("Read = %v, %v; want type %d", nal, err, want)
+		}
+	}
+	if _, err := r.Read(); !errors.Is(err, ErrDeviceBusy) {
+		t.Fatalf("Read after the failure = %v, want a *CaptureError matching ErrDeviceBusy", err)
+	}
+}
+
+func TestH264VideoReader_SetResolutionRejectsOdd(t *testing.T) {
+	r := &H264VideoReader{}
+	if err := r.SetResolution(641, 480); err == nil {
+		t.Fatal("SetResolution(641, 480) succeeded")
+	}
+}
+
+func TestRTPReader_MarkerPerAccessUnit(t *testing.T) {
+	proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, shPrintf(annexB(
+		[]byte{0x67, 0x01}, // SPS
+		[]byte{0x68, 0x01}, // PPS
+		[]byte{0x65, 0x80}, // IDR, first slice
+		[]byte{0x65, 0x40}, // IDR, second slice of the same picture
+		[]byte{0x41, 0x80}, // P frame
+		[]byte{0x41, 0x80}, // P frame
+	), "exit 0"))
+	if err != nil {
+		t.Fatalf("start encoder: %v", err)
+	}
+	r := &RTPReader{
+		reader: &H264VideoReader{
+			proc:    proc,
+			readBuf: make([]byte, 4096),
+			timing:  newH264Timing(30, 0),
+			stats:   newEncoderStats(0),
+		},
+		mtu: 1200,
+	}
+	defer r.Close()
+
+	wantMarker := []bool{false, false, false, true, true, true}
+	wantTS := []uint32{0, 0, 0, 0, 3000, 6000}
+	for i := range wantMarker {
+		pkt, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read %d: %v", i, err)
+		}
+		if pkt.Marker != wantMarker[i] || pkt.Timestamp != wantTS[i] {
+			t.Errorf("packet %d marker=%v ts=%d, want %v %d", i, pkt.Marker, pkt.Timestamp, wantMarker[i], wantTS[i])
+		}
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read at end = %v, want io.EOF", err)
+	}
+}
+
+func TestRTPReader_ReadMultipleGroupsAccessUnit(t *testing.T) {
+	proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, shPrintf(annexB(
+		[]byte{0x09, 0x10}, // AUD
+		[]byte{0x67, 0x01}, // SPS
+		[]byte{0x68, 0x01}, // PPS
+		[]byte{0x65, 0x80}, // IDR, first slice
+		[]byte{0x65, 0x40}, // IDR, second slice of the same picture
+		[]byte{0x09, 0x30}, // AUD
+		[]byte{0x41, 0x80}, // P frame, first slice
+		[]byte{0x41, 0x40}, // P frame, second slice
+		[]byte{0x41, 0x80}, // P frame, cut off by the end of the stream
+	), "exit 0"))
+	if err != nil {
+		t.Fatalf("start encoder: %v", err)
+	}
+	r := &RTPReader{
+		reader: &H264VideoReader{
+			proc:    proc,
+			readBuf: make([]byte, 4096),
+			timing:  newH264Timing(30, 0),
+			stats:   newEncoderStats(0),
+		},
+		mtu: 1200,
+	}
+	defer r.Close()
+
+	// The first packet is read on its own; ReadMultiple returns the rest of its access unit.
+	if pkt, err := r.Read(); err != nil || pkt.Marker {
+		t.Fatalf("Read = %v, %v; want the AUD without marker", pkt, err)
+	}
+	for i, want := range []int{4, 3, 1} {
+		packets, err := r.ReadMultiple()
+		if err != nil {
+			t.Fatalf("ReadMultiple %d: %v", i, err)
+		}
+		if len(packets) != want {
+			t.Fatalf("ReadMultiple %d returned %d packets, want %d", i, len(packets), want)
+		}
+		for j, pkt := range packets {
+			if pkt.Timestamp != packets[0].Timestamp {
+				t.Errorf("access unit %d: packet %d ts=%d, want %d", i, j, pkt.Timestamp, packets[0].Timestamp)
+			}
+			if pkt.Marker != (j == len(packets)-1) {
+				t.Errorf("access unit %d: packet %d marker=%v", i, j, pkt.Marker)
+			}
+		}
+	}
+	if _, err := r.ReadMultiple(); err != io.EOF {
+		t.Errorf("ReadMultiple at end = %v, want io.EOF", err)
+	}
+}
+
+func TestRTPReader_PeekDoesNotConsume(t *testing.T) {
+	proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, shPrintf(annexB(
+		[]byte{0x67, 0x01}, // SPS
+		[]byte{0x68, 0x01}, // PPS
+		[]byte{0x65, 0x80}, // IDR, first slice
+		[]byte{0x65, 0x40}, // IDR, second slice of the same picture
+		[]byte{0x41, 0x80}, // P frame
+	), "exit 0"))
+	if err != nil {
+		t.Fatalf("start encoder: %v", err)
+	}
+	r := &RTPReader{
+		reader: &H264VideoReader{
+			proc:    proc,
+			readBuf: make([]byte, 4096),
+			timing:  newH264Timing(30, 0),
+			stats:   newEncoderStats(0),
+		},
+		mtu: 1200,
+	}
+	defer r.Close()
+
+	types := func(nals []*NALUnit) []H264NaluType {
+		var types []H264NaluType
+		for _, n := range nals {
+			types = append(types, n.Type)
+		}
+		return types
+	}
+	if nal, err := r.PeekNAL(); err != nil || nal.Type != NALUTypeSPS {
+		t.Fatalf("PeekNAL = %v, %v; want the SPS", nal, err)
+	}
+	au, err := r.PeekAccessUnit()
+	if got := types(au); err != nil || fmt.Sprint(got) != "[7 8 5 5]" {
+		t.Fatalf("PeekAccessUnit = %v, %v; want SPS, PPS and both IDR slices", got, err)
+	}
+
+	// Peeking consumed nothing: every NAL unit is still packetized.
+	wantMarker := []bool{false, false, false, true, true}
+	for i := range wantMarker {
+		if i == 2 {
+			if au, err := r.PeekAccessUnit(); fmt.Sprint(types(au), err) != "[5 5] <nil>" {
+				t.Errorf("PeekAccessUnit inside the access unit = %v, %v; want the rest of it", types(au), err)
+			}
+		}
+		pkt, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read %d: %v", i, err)
+		}
+		if pkt.Marker != wantMarker[i] {
+			t.Errorf("packet %d marker=%v, want %v", i, pkt.Marker, wantMarker[i])
+		}
+	}
+	if _, err := r.PeekNAL(); err != io.EOF {
+		t.Errorf("PeekNAL at end = %v, want io.EOF", err)
+	}
+	if _, err := r.PeekAccessUnit(); err != io.EOF {
+		t.Errorf("PeekAccessUnit at end = %v, want io.EOF", err)
+	}
+	if _, err := r.Read(); err != io.EOF {
+		t.Errorf("Read at end = %v, want io.EOF", err)
+	}
+}
+
+func TestH264VideoReader_PauseExcisesTime(t *testing.T) {
+	phase := func(nals ...[]byte) string {
+		return shPrintf(annexB(nals...), "")[1]
+	}
+	script := phase([]byte{0x67, 0x01}, []byte{0x65, 0x80}) + "sleep 0.2; " +
+		phase([]byte{0x41, 0x80}, []byte{0x41, 0x80}) + "sleep 0.8; " + // produced while paused
+		phase([]byte{0x41, 0x80}, []byte{0x67, 0x01}, []byte{0x68, 0x01}, []byte{0x65, 0x80}, []byte{0x41, 0x80})
+	proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, []string{"-c", script})
+	if err != nil {
+		t.Fatalf("start encoder: %v", err)
+	}
+	r := &H264VideoReader{
+		proc:    proc,
+		readBuf: make([]byte, 4096),
+		timing:  newH264Timing(25, 0),
+		stats:   newEncoderStats(0),
+	}
+	defer r.Close()
+
+	for _, want := range []H264NaluType{NALUTypeSPS, 5} {
+		if nal, err := r.Read(); err != nil || nal.Type != want {
+			t.Fatalf("Read = %v, %v; want type %d", nal, err, want)
+		}
+	}
+	if err := r.Pause(false); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if !r.Paused() {
+		t.Error("Paused() = false after Pause")
+	}
+	paused := time.Now()
+	time.AfterFunc(200*time.Millisecond, func() { r.Resume() })
+
+	// Units produced during the pause, and the stale slice before the next
+	// SPS, are dropped; timing continues from the last delivered picture.
+	wantPTS := 40 * time.Millisecond
+	for _, want := range []H264NaluType{NALUTypeSPS, NALUTypePPS, 5, NALUTypeSlice} {
+		nal, err := r.Read()
+		if err != nil || nal.Type != want {
+			t.Fatalf("Read after resume = %v, %v; want type %d", nal, err, want)
+		}
+		if want == 5 && nal.PTS != wantPTS {
+			t.Errorf("IDR PTS after pause = %v, want %v", nal.PTS, wantPTS)
+		}
+	}
+	if d := time.Since(paused); d < 200*time.Millisecond {
+		t.Errorf("Read returned %v after Pause, before Resume", d)
+	}
+}
+
+func TestH264VideoReader_PauseSuspendsEncoder(t *testing.T) {
+	proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, []string{"-c", "exec sleep 30"})
+	if err != nil {
+		t.Fatalf("start encoder: %v", err)
+	}
+	r := &H264VideoReader{proc: proc, readBuf: make([]byte, 4096), timing: newH264Timing(25, 0), stats: newEncoderStats(0)}
+
+	if err := r.Pause(true); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := r.Resume(); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	r.Pause(true)
+
+	// Close unblocks a Read waiting on a suspended encoder.
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.Read()
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	r.Close()
+	select {
+	case err := <-errc:
+		if err == nil {
+			t.Error("Read after Close succeeded")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Read still blocked after Close")
+	}
+}
+
+func TestH264VideoReader_RestartWhileSuspended(t *testing.T) {
+	gcfg := Config{FFmpegPath: "/bin/sh", ArgsHook: func([]string) []string {
+		return []string{"-c", "exec sleep 30"}
+	}}
+	oldProc, err := startProcess(gcfg, nil)
+	if err != nil {
+		t.Fatalf("start old encoder: %v", err)
+	}
+	r := &H264VideoReader{owner: NewMediaDevices(gcfg), proc: oldProc, readBuf: make([]byte, 4096), timing: newH264Timing(25, 0), stats: newEncoderStats(0)}
+	defer r.Close()
+
+	if err := r.Pause(true); err != nil {
+		t.Fatalf("Pause: %v", err)
+	}
+	if err := r.restartEncoder(H264ReaderConfig{}); err != nil {
+		t.Fatalf("restartEncoder: %v", err)
+	}
+
+	select {
+	case <-oldProc.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("suspended encoder still running after the restart")
+	}
+	r.swapEncoder()
+	if r.suspended == nil || r.suspended != r.proc {
+		t.Error("the pause did not carry over to the new encoder")
+	}
+	if err := r.Resume(); err != nil {
+		t.Errorf("Resume: %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// EncoderROI is a region of interest of the encoded picture that the
+// encoder should spend more (or fewer) bits on, such as a detected face
+// or number plate. It is applied with FFmpeg's addroi filter.
+//
+// The region is given as fractions of the picture size, so that it stays
+// in place when the resolution changes (SetResolution, Governor).
+type EncoderROI struct {
+	X, Y, W, H float64
+	// QOffset is the quantizer offset in [-1, 1]. Negative values raise
+	// the quality of the region, positive values lower it. -1/10 to -3/10
+	// is a visible improvement at moderate bitrates.
+	QOffset float64
+}
+
+// ROIFromRect returns the EncoderROI for the pixel rectangle r of a
+// width x height picture, as reported by a detector running on the frames
+// of the same size.
+func ROIFromRect(r image.Rectangle, width, height int, qoffset float64) EncoderROI {
+	w, h := float64(width), float64(height)
+	return EncoderROI{
+		X:       float64(r.Min.X) / w,
+		Y:       float64(r.Min.Y) / h,
+		W:       float64(r.Dx()) / w,
+		H:       float64(r.Dy()) / h,
+		QOffset: qoffset,
+	}
+}
+
+// validateROIs checks that every region lies within the picture and has a
+// valid quantizer offset.
+func validateROIs(rois []EncoderROI) error {
+	for i, roi := range rois {
+		if roi.W <= 0 || roi.H <= 0 || roi.X < 0 || roi.Y < 0 || roi.X+roi.W > 1 || roi.Y+roi.H > 1 {
+			return fmt.Errorf("roi %d: region %v,%v %vx%v is not within the picture", i, roi.X, roi.Y, roi.W, roi.H)
+		}
+		if roi.QOffset < -1 || roi.QOffset > 1 {
+			return fmt.Errorf("roi %d: qoffset %v is outside [-1, 1]", i, roi.QOffset)
+		}
+	}
+	return nil
+}
+
+// roiFilter returns the addroi filter chain for rois, or "" for none.
+// Where regions overlap, the encoder uses the first one.
+func roiFilter(rois []EncoderROI) string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	var chain []string
+	for _, roi := range rois {
+		chain = append(chain, fmt.Sprintf("addroi=x=iw*%s:y=ih*%s:w=iw*%s:h=ih*%s:qoffset=%s",
+			f(roi.X), f(roi.Y), f(roi.W), f(roi.H), f(roi.QOffset)))
+	}
+	return strings.Join(chain, ",")
+}
+
+// SetROIs replaces the regions of interest of a live reader; nil removes
+// them. FFmpeg cannot change addroi while it runs, so like SetResolution
+// this restarts the encoder and resumes at the next IDR frame. Update the
+// regions when they have moved noticeably, not on every frame.
+func (r *H264VideoReader) SetROIs(rois []EncoderROI) error {
+	if err := validateROIs(rois); err != nil {
+		return err
+	}
+	rois = append([]EncoderROI(nil), rois...)
+
+	r.mu.Lock()
+	cfg := r.cfg
+	r.mu.Unlock()
+	if r.governor != nil {
+		// Keep the governor's current preset and resolution.
+		cfg = r.governor.setROIs(rois)
+	} else {
+		cfg.ROIs = rois
+	}
+	return r.restartEncoder(cfg)
+}
+
+// ROIs returns the current regions of interest.
+func (r *H264VideoReader) ROIs() []EncoderROI {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]EncoderROI(nil), r.cfg.ROIs...)
+}
+
+// SetROIs replaces the regions of interest without interrupting the RTP
+// stream; see H264VideoReader.SetROIs.
+func (r *RTPReader) SetROIs(rois []EncoderROI) error {
+	return r.reader.SetROIs(rois)
+}
@@ -0,0 +1,69 @@
+package mediadevices
+
+import (
+	"image"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestBuildH264Args_ROIs(t *testing.T) {
+	cfg := H264ReaderConfig{
+		DeviceName:  "cam",
+		Width:       1280,
+		Height:      720,
+		VideoFilter: "hflip",
+		ROIs: []EncoderROI{
+			{X: 0.25, Y: 0.1, W: 0.5, H: 0.5, QOffset: -0.3},
+			{X: 0, Y: 0, W: 1, H: 1, QOffset: 0.2},
+		},
+	}
+	args := buildH264Args(cfg)
+	i := slices.Index(args, "-vf")
+	if i < 0 {
+		t.Fatalf("no -vf in %v", args)
+	}
+	want := "scale=1280:720,hflip," +
+		"addroi=x=iw*0.25:y=ih*0.1:w=iw*0.5:h=ih*0.5:qoffset=-0.3," +
+		"addroi=x=iw*0:y=ih*0:w=iw*1:h=ih*1:qoffset=0.2"
+	if args[i+1] != want {
+		t.Errorf("-vf %q, want %q", args[i+1], want)
+	}
+	if p := args[slices.Index(args, "-x264-params")+1]; !strings.Contains(p, "aq-mode=1") {
+		t.Errorf("-x264-params %q without aq-mode", p)
+	}
+
+	cfg.ROIs = nil
+	args = buildH264Args(cfg)
+	if p := args[slices.Index(args, "-x264-params")+1]; p != "repeatheaders=1" {
+		t.Errorf("-x264-params %q without ROIs", p)
+	}
+}
+
+func TestValidateROIs(t *testing.T) {
+	if err := validateROIs([]EncoderROI{{X: 0.5, Y: 0.5, W: 0.5, H: 0.5, QOffset: -1}}); err != nil {
+		t.Errorf("valid ROI rejected: %v", err)
+	}
+	for _, bad := range []EncoderROI{
+		{X: 0.6, Y: 0, W: 0.5, H: 0.5},
+		{X: 0, Y: 0, W: 0, H: 0.5},
+		{X: -0.1, Y: 0, W: 0.5, H: 0.5},
+		{X: 0, Y: 0, W: 0.5, H: 0.5, QOffset: 1.5},
+	} {
+		if err := validateROIs([]EncoderROI{bad}); err == nil {
+			t.Errorf("ROI %+v accepted", bad)
+		}
+	}
+	r := &H264VideoReader{}
+	if err := r.SetROIs([]EncoderROI{{W: 2, H: 1}}); err == nil {
+		t.Error("SetROIs accepted an invalid region")
+	}
+}
+
+func TestROIFromRect(t *testing.T) {
+	got := ROIFromRect(image.Rect(320, 180, 960, 540), 1280, 720, -0.2)
+	want := EncoderROI{X: 0.25, Y: 0.25, W: 0.5, H: 0.5, QOffset: -0.2}
+	if got != want {
+		t.Errorf("ROIFromRect = %+v, want %+v", got, want)
+	}
+}
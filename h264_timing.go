@@ -0,0 +1,322 @@
+package mediadevices
+
+import (
+	"errors"
+	"time"
+)
+
+// errBitstreamTruncated is returned when an H264 syntax element extends past
+// the end of a NAL unit.
+var errBitstreamTruncated = errors.New("h264: truncated bitstream")
+
+// bitReader reads H264 syntax elements (fixed-width and Exp-Golomb coded)
+// from an RBSP, i.e. a NAL unit payload with emulation prevention removed.
+type bitReader struct {
+	data []byte
+	pos  int // bit position
+}
+
+// unescapeRBSP removes emulation prevention bytes (0x03 following 0x00 0x00).
+func unescapeRBSP(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	zeros := 0
+	for _, b := range data {
+		if zeros >= 2 && b == 0x03 {
+			zeros = 0
+			continue
+		}
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+		out = append(out, b)
+	}
+	return out
+}
+
+func (r *bitReader) u(n int) (uint32, error) {
+	var v uint32
+	for i := 0; i < n; i++ {
+		if r.pos >= len(r.data)*8 {
+			return 0, errBitstreamTruncated
+		}
+		bit := r.data[r.pos/8] >> (7 - r.pos%8) & 1
+		v = v<<1 | uint32(bit)
+		r.pos++
+	}
+	return v, nil
+}
+
+func (r *bitReader) flag() (bool, error) {
+	v, err := r.u(1)
+	return v == 1, err
+}
+
+// ue reads an unsigned Exp-Golomb code.
+func (r *bitReader) ue() (uint32, error) {
+	zeros := 0
+	for {
+		b, err := r.u(1)
+		if err != nil {
+			return 0, err
+		}
+		if b == 1 {
+			break
+		}
+		zeros++
+		if zeros > 31 {
+			return 0, errBitstreamTruncated
+		}
+	}
+	v, err := r.u(zeros)
+	return 1<<zeros - 1 + v, err
+}
+
+// se reads a signed Exp-Golomb code.
+func (r *bitReader) se() (int32, error) {
+	k, err := r.ue()
+	if k%2 == 1 {
+		return int32(k+1) / 2, err
+	}
+	return -int32(k / 2), err
+}
+
+// spsInfo holds the sequence parameter set fields needed to locate the
+// picture order count in slice headers.
+type spsInfo struct {
+	separateColourPlane bool
+	log2MaxFrameNum     uint32
+	pocType             uint32
+	log2MaxPOCLsb       uint32
+	frameMbsOnly        bool
+}
+
+// parseSPS parses the leading fields of an SPS NAL unit (with header byte).
+func parseSPS(nal []byte) (*spsInfo, error) {
+	if len(nal) < 4 {
+		return nil, errBitstreamTruncated
+	}
+	profile := nal[1]
+	r := &bitReader{data: unescapeRBSP(nal[4:])}
+	sps := &spsInfo{}
+
+	if _, err := r.ue(); err != nil { // seq_parameter_set_id
+		return nil, err
+	}
+	switch profile {
+	case 100, 110, 122, 244, 44, 83, 86, 118, 128, 138, 139, 134, 135:
+		chromaFormat, err := r.ue()
+		if err != nil {
+			return nil, err
+		}
+		if chromaFormat == 3 {
+			if sps.separateColourPlane, err = r.flag(); err != nil {
+				return nil, err
+			}
+		}
+		r.ue() // bit_depth_luma_minus8
+		r.ue() // bit_depth_chroma_minus8
+		r.u(1) // qpprime_y_zero_transform_bypass_flag
+		scaling, err := r.flag()
+		if err != nil {
+			return nil, err
+		}
+		if scaling {
+			lists := 8
+			if chromaFormat == 3 {
+				lists = 12
+			}
+			for i := 0; i < lists; i++ {
+				present, err := r.flag()
+				if err != nil {
+					return nil, err
+				}
+				if !present {
+					continue
+				}
+				size := 16
+				if i >= 6 {
+					size = 64
+				}
+				if err := skipScalingList(r, size); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	v, err := r.ue()
+	if err != nil {
+		return nil, err
+	}
+	sps.log2MaxFrameNum = v + 4
+	if sps.pocType, err = r.ue(); err != nil {
+		return nil, err
+	}
+	switch sps.pocType {
+	case 0:
+		if v, err = r.ue(); err != nil {
+			return nil, err
+		}
+		sps.log2MaxPOCLsb = v + 4
+	case 1:
+		r.u(1) // delta_pic_order_always_zero_flag
+		r.se() // offset_for_non_ref_pic
+		r.se() // offset_for_top_to_bottom_field
+		n, err := r.ue()
+		if err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < n; i++ {
+			if _, err := r.se(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	r.ue() // max_num_ref_frames
+	r.u(1) // gaps_in_frame_num_value_allowed_flag
+	r.ue() // pic_width_in_mbs_minus1
+	r.ue() // pic_height_in_map_units_minus1
+	if sps.frameMbsOnly, err = r.flag(); err != nil {
+		return nil, err
+	}
+	return sps, nil
+}
+
+func skipScalingList(r *bitReader, size int) error {
+	last, next := int32(8), int32(8)
+	for j := 0; j < size; j++ {
+		if next != 0 {
+			delta, err := r.se()
+			if err != nil {
+				return err
+			}
+			next = (last + delta + 256) % 256
+		}
+		if next != 0 {
+			last = next
+		}
+	}
+	return nil
+}
+
+// slicePOCLsb returns pic_order_cnt_lsb from the slice header of nal.
+// It is only present for pic_order_cnt_type 0.
+func slicePOCLsb(nal []byte, sps *spsInfo) (uint32, error) {
+	r := &bitReader{data: unescapeRBSP(nal[1:])}
+	r.ue() // first_mb_in_slice
+	r.ue() // slice_type
+	r.ue() // pic_parameter_set_id
+	if sps.separateColourPlane {
+		r.u(2) // colour_plane_id
+	}
+	r.u(int(sps.log2MaxFrameNum)) // frame_num
+	if !sps.frameMbsOnly {
+		field, err := r.flag()
+		if err != nil {
+			return 0, err
+		}
+		if field {
+			r.u(1) // bottom_field_flag
+		}
+	}
+	if nal[0]&0x1F == 5 {
+		r.ue() // idr_pic_id
+	}
+	return r.u(int(sps.log2MaxPOCLsb))
+}
+
+// h264Timing assigns presentation and decode timestamps to pictures of an
+// encoded stream produced at a constant frame rate.
+//
+// Without B-frames pictures are presented in decode order and PTS equals
+// DTS. With B-frames the presentation order is recovered from the picture
+// order count (POC) in each slice header; x264 advances the POC by 2 per
+// frame. PTS is offset by the reorder delay so that it never precedes DTS.
+type h264Timing struct {
+	frameDur time.Duration
+	delay    int64 // reorder delay in frames
+	reorder  bool  // derive presentation order from the POC
+
+	sps        *spsInfo
+	decoded    int64 // pictures decoded so far
+	idrBase    int64 // presentation index of the last IDR picture
+	prevPOCMsb int64
+	prevPOCLsb int64
+
+	pts, dts time.Duration // timestamps of the current picture
+}
+
+func newH264Timing(frameRate float64, bFrames int) *h264Timing {
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+	t := &h264Timing{frameDur: time.Duration(float64(time.Second) / frameRate)}
+	if bFrames > 0 {
+		t.reorder = true
+		// x264 enables B-pyramid for two or more B-frames, which delays
+		// output by one more frame.
+		t.delay = 1
+		if bFrames >= 2 {
+			t.delay = 2
+		}
+	}
+	return t
+}
+
+// observe updates the timing state with nal and returns the PTS and DTS of
+// the picture it belongs to. Non-VCL units return the timestamps of the
+// most recent picture.
+func (t *h264Timing) observe(nal *NALUnit) (pts, dts time.Duration) {
+	if nal.Type == NALUTypeSPS {
+		if sps, err := parseSPS(nal.Data); err == nil {
+			t.sps = sps
+		}
+		return t.pts, t.dts
+	}
+	if !isFirstSliceOfPicture(nal) {
+		return t.pts, t.dts
+	}
+
+	idx := t.decoded
+	if nal.Type == 5 {
+		// All earlier pictures are presented before an IDR picture.
+		t.idrBase = t.decoded
+		t.prevPOCMsb, t.prevPOCLsb = 0, 0
+	}
+	present := idx
+	if t.reorder && t.sps != nil && t.sps.pocType == 0 {
+		if poc, ok := t.poc(nal); ok {
+			present = t.idrBase + poc/2
+		}
+	}
+	t.decoded++
+
+	t.dts = time.Duration(idx) * t.frameDur
+	t.pts = time.Duration(present+t.delay) * t.frameDur
+	return t.pts, t.dts
+}
+
+// poc computes the picture order count of a frame for pic_order_cnt_type 0
+// (H.264 section 8.2.1.1).
+func (t *h264Timing) poc(nal *NALUnit) (int64, bool) {
+	v, err := slicePOCLsb(nal.Data, t.sps)
+	if err != nil {
+		return 0, false
+	}
+	lsb := int64(v)
+	maxLsb := int64(1) << t.sps.log2MaxPOCLsb
+
+	msb := t.prevPOCMsb
+	switch {
+	case lsb < t.prevPOCLsb && t.prevPOCLsb-lsb >= maxLsb/2:
+		msb += maxLsb
+	case lsb > t.prevPOCLsb && lsb-t.prevPOCLsb > maxLsb/2:
+		msb -= maxLsb
+	}
+	if nal.Data[0]&0x60 != 0 { // reference picture (nal_ref_idc != 0)
+		t.prevPOCMsb, t.prevPOCLsb = msb, lsb
+	}
+	return msb + lsb, true
+}
@@ -0,0 +1,104 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+)
+
+// bitWriter builds H264 bitstreams for tests.
+type bitWriter struct {
+	data []byte
+	bits int
+}
+
+func (w *bitWriter) u(n int, v uint32) {
+	for i := n - 1; i >= 0; i-- {
+		if w.bits%8 == 0 {
+			w.data = append(w.data, 0)
+		}
+		w.data[len(w.data)-1] |= byte(v>>i&1) << (7 - w.bits%8)
+		w.bits++
+	}
+}
+
+func (w *bitWriter) ue(v uint32) {
+	n := 0
+	for x := v + 1; x > 1; x >>= 1 {
+		n++
+	}
+	w.u(n, 0)
+	w.u(n+1, v+1)
+}
+
+// rbsp terminates the bitstream with the stop bit and returns header+payload.
+func (w *bitWriter) rbsp(header ...byte) []byte {
+	w.u(1, 1)
+	return append(header, w.data...)
+}
+
+func testSPS() []byte {
+	w := &bitWriter{}
+	w.ue(0)   // seq_parameter_set_id
+	w.ue(0)   // log2_max_frame_num_minus4
+	w.ue(0)   // pic_order_cnt_type
+	w.ue(2)   // log2_max_pic_order_cnt_lsb_minus4
+	w.ue(1)   // max_num_ref_frames
+	w.u(1, 0) // gaps_in_frame_num_value_allowed_flag
+	w.ue(39)  // pic_width_in_mbs_minus1
+	w.ue(29)  // pic_height_in_map_units_minus1
+	w.u(1, 1) // frame_mbs_only_flag
+	return w.rbsp(0x67, 77, 0, 40)
+}
+
+func testSlice(header byte, sliceType, frameNum, pocLsb uint32) *NALUnit {
+	w := &bitWriter{}
+	w.ue(0) // first_mb_in_slice
+	w.ue(sliceType)
+	w.ue(0) // pic_parameter_set_id
+	w.u(4, frameNum)
+	if header&0x1F == 5 {
+		w.ue(0) // idr_pic_id
+	}
+	w.u(6, pocLsb)
+	return &NALUnit{Type: H264NaluType(header & 0x1F), Data: w.rbsp(header)}
+}
+
+func TestH264Timing_BFrames(t *testing.T) {
+	tm := newH264Timing(25, 2)
+	tm.observe(&NALUnit{Type: NALUTypeSPS, Data: testSPS()})
+
+	// Decode order I0 P3 B1 B2 P6 B4 B5 (POC advances by 2 per frame).
+	pictures := []struct {
+		nal      *NALUnit
+		pts, dts int64 // in frames
+	}{
+		{testSlice(0x65, 7, 0, 0), 2, 0},
+		{testSlice(0x41, 5, 1, 6), 5, 1},
+		{testSlice(0x01, 6, 2, 2), 3, 2},
+		{testSlice(0x01, 6, 2, 4), 4, 3},
+		{testSlice(0x41, 5, 2, 12), 8, 4},
+		{testSlice(0x01, 6, 3, 8), 6, 5},
+		{testSlice(0x01, 6, 3, 10), 7, 6},
+	}
+	frame := 40 * time.Millisecond
+	for i, p := range pictures {
+		pts, dts := tm.observe(p.nal)
+		if pts != time.Duration(p.pts)*frame || dts != time.Duration(p.dts)*frame {
+			t.Errorf("picture %d: pts=%v dts=%v, want %v %v", i, pts, dts, time.Duration(p.pts)*frame, time.Duration(p.dts)*frame)
+		}
+		if pts < dts {
+			t.Errorf("picture %d: pts %v precedes dts %v", i, pts, dts)
+		}
+	}
+}
+
+func TestH264Timing_NoBFrames(t *testing.T) {
+	tm := newH264Timing(30, 0)
+	tm.observe(&NALUnit{Type: NALUTypeSPS, Data: testSPS()})
+	for i := 0; i < 3; i++ {
+		pts, dts := tm.observe(testSlice(0x41, 5, uint32(i), uint32(2*i)))
+		if pts != dts {
+			t.Errorf("frame %d: pts %v != dts %v", i, pts, dts)
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package mediadevices
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HealthLevel is a coarse classification of a StreamHealth score.
+type HealthLevel string
+
+const (
+	// HealthGood means the stream runs as configured (score >= 80).
+	HealthGood HealthLevel = "good"
+	// HealthDegraded means the stream is usable but visibly impaired
+	// (score >= 50): an unsteady or reduced frame rate, some dropped frames.
+	HealthDegraded HealthLevel = "degraded"
+	// HealthPoor means the stream is barely usable or stalled (score < 50).
+	HealthPoor HealthLevel = "poor"
+)
+
+// Score thresholds between the health levels.
+const (
+	healthGoodScore     = 80
+	healthDegradedScore = 50
+)
+
+// healthLevel returns the level of a score.
+func healthLevel(score float64) HealthLevel {
+	switch {
+	case score >= healthGoodScore:
+		return HealthGood
+	case score >= healthDegradedScore:
+		return HealthDegraded
+	default:
+		return HealthPoor
+	}
+}
+
+// StreamHealth combines the frame rate, its stability, dropped frames,
+// encoder lag and FFmpeg warnings of a stream over a sliding window into a
+// single score, so that many streams can be ranked and alerted on.
+type StreamHealth struct {
+	// Score is 100 for a stream that runs exactly as configured and falls
+	// towards 0 as it degrades.
+	Score float64
+	// Level classifies Score.
+	Level HealthLevel
+	// Window is the span of time the fields below cover.
+	Window time.Duration
+
+	// FrameRate is the realized number of frames (or audio chunks) per
+	// second, and TargetFrameRate the configured one, 0 if unknown.
+	FrameRate       float64
+	TargetFrameRate float64
+	// Jitter is the coefficient of variation (standard deviation / mean)
+	// of the intervals between frames: 0 for a perfectly steady stream.
+	Jitter float64
+	// DroppedFrames estimates the frames missing from gaps longer than 1.5
+	// frame intervals. It needs TargetFrameRate.
+	DroppedFrames int
+	// EncoderLag is how much later than at its best the encoder currently
+	// delivers pictures relative to their timestamps. Only encoded streams
+	// (H264VideoReader) report it.
+	EncoderLag time.Duration
+	// Warnings counts FFmpeg stderr lines reporting trouble (buffer
+	// overruns, non-monotonic timestamps, corrupt data).
+	Warnings int
+}
+
+// streamWarningPatterns are substrings of FFmpeg stderr lines, matched
+// case-insensitively, that indicate a problem with a running stream.
+var streamWarningPatterns = []string{
+	"warning",
+	"error",
+	"overrun",
+	"xrun",
+	"buffer too full",
+	"dropping",
+	"dropped",
+	"past duration",
+	"non-monoton",
+	"corrupt",
+	"invalid",
+	"discarding",
+	"queue input is backward",
+}
+
+// isStreamWarning reports whether an FFmpeg stderr line reports trouble.
+func isStreamWarning(line string) bool {
+	lower := strings.ToLower(line)
+	for _, p := range streamWarningPatterns {
+		if strings.Contains(lower, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// healthMeter records the arrival of frames and derives StreamHealth over a
+// sliding window. The zero value is ready to use with defaultStatsWindow.
+type healthMeter struct {
+	window time.Duration
+
+	mu       sync.Mutex
+	start    time.Time // first frame, so a young stream is not judged by the full window
+	frames   []time.Time
+	target   float64
+	warnings []warningSample
+
+	// lag is the current encoder lag: the arrival time minus the timestamp
+	// of the latest picture, relative to its minimum, lagBase.
+	lag        time.Duration
+	lagBase    time.Duration
+	lagBaseSet bool
+}
+
+// warningSample is the cumulative warning count of a process at a time.
+type warningSample struct {
+	at    time.Time
+	count int64
+}
+
+// observe records a frame that arrived at now. target is the configured
+// frame rate (0 if unknown) and warnings the cumulative stderr warning
+// count of the producing process (negative if unknown).
+func (m *healthMeter) observe(now time.Time, target float64, warnings int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.start.IsZero() {
+		m.start = now
+	}
+	m.frames = append(m.frames, now)
+	m.target = target
+	if warnings >= 0 {
+		m.warnings = append(m.warnings, warningSample{now, warnings})
+	}
+	m.prune(now)
+}
+
+// observeLag records that the picture with timestamp pts arrived at now.
+// A picture that arrives earlier relative to its timestamp than any before
+// becomes the new baseline.
+func (m *healthMeter) observeLag(now time.Time, pts time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	offset := time.Duration(now.UnixNano()) - pts
+	if !m.lagBaseSet || offset < m.lagBase {
+		m.lagBase, m.lagBaseSet = offset, true
+	}
+	m.lag = offset - m.lagBase
+}
+
+// resetLag forgets the lag baseline, when timestamps no longer follow the
+// wall clock (after a pause or an encoder restart).
+func (m *healthMeter) resetLag() {
+	m.mu.Lock()
+	m.lag, m.lagBaseSet = 0, false
+	m.mu.Unlock()
+}
+
+// windowSize returns the configured window or the default.
+func (m *healthMeter) windowSize() time.Duration {
+	if m.window > 0 {
+		return m.window
+	}
+	return defaultStatsWindow
+}
+
+// prune drops records older than the window.
+func (m *healthMeter) prune(now time.Time) {
+	cutoff := now.Add(-m.windowSize())
+	i := 0
+	for i < len(m.frames) && m.frames[i].Before(cutoff) {
+		i++
+	}
+	m.frames = append(m.frames[:0], m.frames[i:]...)
+	j := 0
+	// Keep the last sample before the window as the baseline.
+	for j+1 < len(m.warnings) && m.warnings[j+1].at.Before(cutoff) {
+		j++
+	}
+	m.warnings = append(m.warnings[:0], m.warnings[j:]...)
+}
+
+// snapshot returns the health over the window ending at now.
+func (m *healthMeter) snapshot(now time.Time) StreamHealth {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.prune(now)
+
+	window := m.windowSize()
+	if age := now.Sub(m.start); !m.start.IsZero() && age < window {
+		window = max(age, time.Second/10)
+	}
+	h := StreamHealth{Window: window, TargetFrameRate: m.target, EncoderLag: m.lag}
+	h.FrameRate = float64(len(m.frames)) / window.Seconds()
+
+	if n := len(m.frames); n > 2 {
+		var sum, sumSq float64
+		for i := 1; i < n; i++ {
+			d := m.frames[i].Sub(m.frames[i-1]).Seconds()
+			sum += d
+			sumSq += d * d
+		}
+		count := float64(n - 1)
+		mean := sum / count
+		if mean > 0 {
+			h.Jitter = math.Sqrt(math.Max(sumSq/count-mean*mean, 0)) / mean
+		}
+	}
+
+	if m.target > 0 {
+		interval := time.Duration(float64(time.Second) / m.target)
+		prev := now.Add(-window)
+		if len(m.frames) > 0 && m.frames[0].Sub(prev) < interval {
+			prev = m.frames[0]
+		}
+		missing := func(gap time.Duration) int {
+			if gap <= interval*3/2 {
+				return 0
+			}
+			return int(math.Round(float64(gap)/float64(interval))) - 1
+		}
+		for _, at := range m.frames {
+			h.DroppedFrames += missing(at.Sub(prev))
+			prev = at
+		}
+		// The time since the last frame counts too, so that a stalled
+		// stream reports its missing frames.
+		h.DroppedFrames += missing(now.Sub(prev))
+	}
+
+	if n := len(m.warnings); n > 0 {
+		last, first := m.warnings[n-1].count, m.warnings[0].count
+		if n == 1 || last < first {
+			// A single sample, or the process was restarted and the count reset.
+			h.Warnings = int(last)
+		} else {
+			h.Warnings = int(last - first)
+		}
+	}
+
+	h.Score = healthScore(h)
+	h.Level = healthLevel(h.Score)
+	return h
+}
+
+// healthScore weighs the inputs of h into a score from 0 to 100: up to 40
+// points for a missing frame rate, 20 for jitter, 20 for dropped frames and
+// 10 each for encoder lag and warnings.
+func healthScore(h StreamHealth) float64 {
+	clamp := func(v float64) float64 { return math.Max(0, math.Min(v, 1)) }
+	penalty := 0.0
+	if h.TargetFrameRate > 0 {
+		penalty += 40 * clamp(1-h.FrameRate/h.TargetFrameRate)
+		expected := h.TargetFrameRate * h.Window.Seconds()
+		// Losing a quarter of the frames costs all 20 points.
+		penalty += 20 * clamp(4*float64(h.DroppedFrames)/expected)
+	} else if h.FrameRate == 0 {
+		penalty += 60
+	}
+	// A deviation of half the mean interval is very unsteady.
+	penalty += 20 * clamp(h.Jitter/0.5)
+	penalty += 10 * clamp(h.EncoderLag.Seconds())
+	penalty += 10 * clamp(float64(h.Warnings)/10)
+	return math.Round((100-penalty)*10) / 10
+}
@@ -0,0 +1,107 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHealthMeter_Steady(t *testing.T) {
+	var m healthMeter
+	start := time.Unix(1000, 0)
+	for i := range 150 {
+		m.observe(start.Add(time.Duration(i)*time.Second/30), 30, 0)
+	}
+	h := m.snapshot(start.Add(5 * time.Second))
+	if h.Score < 95 || h.Level != HealthGood {
+		t.Errorf("steady stream = %+v, want a good score", h)
+	}
+	if h.DroppedFrames != 0 || h.Jitter > 0.01 {
+		t.Errorf("steady stream dropped %d frames, jitter %v", h.DroppedFrames, h.Jitter)
+	}
+}
+
+func TestHealthMeter_Degraded(t *testing.T) {
+	var m healthMeter
+	start := time.Unix(1000, 0)
+	at := start
+	for i := range 100 {
+		// Every fourth frame is missing.
+		step := time.Second / 30
+		if i%3 == 2 {
+			step *= 2
+		}
+		at = at.Add(step)
+		m.observe(at, 30, int64(i/20))
+	}
+	h := m.snapshot(at)
+	if h.DroppedFrames < 25 || h.Warnings != 4 || h.Jitter == 0 {
+		t.Errorf("health = %+v, want dropped frames, jitter and 4 warnings", h)
+	}
+	if h.Level == HealthGood {
+		t.Errorf("level = %s with a quarter of the frames missing", h.Level)
+	}
+
+	// A stalled stream is poor once the gap fills the window.
+	if h := m.snapshot(at.Add(10 * time.Second)); h.Level != HealthPoor || h.DroppedFrames < 140 {
+		t.Errorf("stalled stream = %+v", h)
+	}
+}
+
+func TestHealthMeter_Lag(t *testing.T) {
+	var m healthMeter
+	start := time.Unix(1000, 0)
+	m.observeLag(start, 0)
+	m.observeLag(start.Add(time.Second+300*time.Millisecond), time.Second)
+	if h := m.snapshot(start.Add(2 * time.Second)); h.EncoderLag != 300*time.Millisecond {
+		t.Errorf("lag = %v, want 300ms", h.EncoderLag)
+	}
+	m.resetLag()
+	m.observeLag(start.Add(10*time.Second), time.Second)
+	if h := m.snapshot(start.Add(10 * time.Second)); h.EncoderLag != 0 {
+		t.Errorf("lag after reset = %v", h.EncoderLag)
+	}
+}
+
+func TestCountWarnings(t *testing.T) {
+	p := &ffmpegProcess{}
+	p.scanStderr([]byte("frame=  10 fps= 30 q=-1.0 size=N/A\r[video4linux2,v4l2 @ 0x5] The v4l2 frame is 4 bytes, but 8 bytes are expected\n[alsa @ 0x6] ALSA buffer xrun.\n[video4linux2,v4l2 @ 0x5] Dequeued v4l2 buffer contains corrupted data (0 by"))
+	if got := p.Warnings(); got != 1 {
+		t.Errorf("warnings = %d, want the xrun only until the corrupt-data line is complete", got)
+	}
+	p.scanStderr([]byte("tes).\n"))
+	if got := p.Warnings(); got != 2 {
+		t.Errorf("warnings = %d, want 2", got)
+	}
+}
+
+func TestTrackOnHealthChange(t *testing.T) {
+	defer func(d time.Duration) { healthCheckInterval = d }(healthCheckInterval)
+	healthCheckInterval = 10 * time.Millisecond
+
+	src := &fakeCameraReader{cam: &fakeCamera{w: 2}, closed: make(chan struct{})}
+	track := newCustomTrack(MediaDeviceKindVideoInput, "cam", fpsSource{src, 500}, nil)
+	defer track.Stop()
+	track.health.window = 200 * time.Millisecond
+
+	events := make(chan HealthEvent, 4)
+	cancel := track.OnHealthChange(func(ev HealthEvent) { events <- ev })
+	defer cancel()
+
+	// Nothing is read, so the track stalls.
+	select {
+	case ev := <-events:
+		if ev.Previous != HealthGood || ev.Health.Level != HealthPoor || ev.Label != "cam" {
+			t.Errorf("event = %+v", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no event for a stalled track")
+	}
+}
+
+// fpsSource reports a frame rate for a video source.
+type fpsSource struct {
+	videoSource
+	fps float64
+}
+
+func (s fpsSource) FrameRate() float64 { return s.fps }
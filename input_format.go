@@ -0,0 +1,96 @@
+package mediadevices
+
+import (
+	"context"
+	"slices"
+)
+
+// InputFormat forces how FFmpeg opens a capture device, for systems where
+// the platform's default input format misbehaves with a specific driver.
+// Input formats are configured per device in Config.InputFormats.
+type InputFormat struct {
+	// Format is the FFmpeg input format (demuxer) to open the device with
+	// instead of the platform's default, such as "vfwcap" instead of
+	// "dshow" on Windows or "pipewire" instead of "v4l2" on Linux. Empty
+	// keeps the default.
+	Format string
+	// Device, if set, is the input passed to -i instead of the one of the
+	// default format, for formats that address devices differently:
+	// vfwcap takes a driver index such as "0" rather than video="<name>".
+	Device string
+	// Options are extra input options placed before -i, such as
+	// {"-input_format", "mjpeg"}.
+	Options []string
+}
+
+// inputFormatFor returns the input format configured for a device of the
+// given kind, looked up by deviceID, else by the name FFmpeg opens it by,
+// else by the DeviceID of the enumerated device with that name.
+func (m *MediaDevices) inputFormatFor(ctx context.Context, kind MediaDeviceKind, name, deviceID string) (InputFormat, bool) {
+	all := m.Config().InputFormats
+	if len(all) == 0 {
+		return InputFormat{}, false
+	}
+	if f, ok := all[deviceID]; ok && deviceID != "" {
+		return f, true
+	}
+	if f, ok := all[name]; ok && name != "" {
+		return f, true
+	}
+	if name == "" {
+		return InputFormat{}, false
+	}
+	devices, _ := m.devicesByKind(ctx, kind)
+	for _, d := range devices {
+		if d.DeviceName == name || ffmpegDeviceName(d) == name {
+			f, ok := all[d.DeviceID]
+			return f, ok
+		}
+	}
+	return InputFormat{}, false
+}
+
+// overrideInput applies the input format configured for a device, as found
+// by inputFormatFor, to the first input of its capture arguments.
+func (m *MediaDevices) overrideInput(ctx context.Context, kind MediaDeviceKind, name, deviceID string, args []string) []string {
+	f, ok := m.inputFormatFor(ctx, kind, name, deviceID)
+	if !ok {
+		return args
+	}
+	return forceInputFormat(args, f)
+}
+
+// forceInputFormat returns args with its first input opened as f
+// describes: the -f before the first -i is replaced (or added), the input
+// name replaced and the options of f added before -i. The other input
+// options are kept. args is not modified.
+func forceInputFormat(args []string, f InputFormat) []string {
+	in, format := -1, -1
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == "-i" {
+			in = i
+			break
+		}
+		if args[i] == "-f" && format < 0 {
+			format = i
+		}
+	}
+	if in < 0 {
+		return args
+	}
+
+	args = slices.Clone(args)
+	if f.Device != "" {
+		args[in+1] = f.Device
+	}
+	var insert []string
+	if f.Format != "" {
+		if format >= 0 {
+			args[format+1] = f.Format
+		} else {
+			insert = append(insert, "-f", f.Format)
+		}
+	}
+	insert = append(insert, f.Options...)
+	return slices.Insert(args, in, insert...)
+}
@@ -0,0 +1,67 @@
+package mediadevices
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestForceInputFormat(t *testing.T) {
+	base := []string{"-y", "-f", "dshow", "-video_size", "640x480", "-i", "video=Cam", "-f", "rawvideo", "pipe:1"}
+	for _, tc := range []struct {
+		name string
+		args []string
+		f    InputFormat
+		want string
+	}{
+		{"format", base, InputFormat{Format: "vfwcap", Device: "0"}, "-y -f vfwcap -video_size 640x480 -i 0 -f rawvideo pipe:1"},
+		{"options", base, InputFormat{Options: []string{"-input_format", "mjpeg"}}, "-y -f dshow -video_size 640x480 -input_format mjpeg -i video=Cam -f rawvideo pipe:1"},
+		{"no format", []string{"-re", "-i", "clip.mp4", "pipe:1"}, InputFormat{Format: "mov"}, "-re -f mov -i clip.mp4 pipe:1"},
+		{"no input", []string{"-version"}, InputFormat{Format: "v4l2"}, "-version"},
+	} {
+		orig := slices.Clone(tc.args)
+		if got := strings.Join(forceInputFormat(tc.args, tc.f), " "); got != tc.want {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+		if !slices.Equal(tc.args, orig) {
+			t.Errorf("%s: args modified", tc.name)
+		}
+	}
+}
+
+func TestInputFormats(t *testing.T) {
+	m := NewMediaDevices(Config{
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{
+				{DeviceID: "cam", DeviceName: "/dev/video0", Label: "cam", Kind: MediaDeviceKindVideoInput},
+				{DeviceID: "mic", DeviceName: "hw:0", Label: "mic", Kind: MediaDeviceKindAudioInput},
+			}, nil
+		},
+		InputFormats: map[string]InputFormat{
+			"cam":  {Format: "pipewire", Device: "42"},
+			"hw:0": {Format: "pulse"},
+		},
+	})
+	input := func(args []string) (format, device string) {
+		i := slices.Index(args, "-i")
+		if f := slices.Index(args[:i], "-f"); f >= 0 {
+			format = args[f+1]
+		}
+		return format, args[i+1]
+	}
+
+	args, err := m.videoCaptureArgs("/dev/video0", 640, 480, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if format, device := input(args); format != "pipewire" || device != "42" {
+		t.Errorf("video input = -f %s -i %s in %q", format, device, args)
+	}
+	if format, _ := input(m.audioCaptureArgs(m.audioCaptureParams("hw:0", 48000, 2))); format != "pulse" {
+		t.Errorf("audio input format = %s", format)
+	}
+	if format, _ := input(m.audioCaptureArgs(m.audioCaptureParams("hw:1", 48000, 2))); format == "pulse" {
+		t.Error("input format applied to another device")
+	}
+}
@@ -0,0 +1,64 @@
+package mediadevices
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// KeyframePolicy controls where the H.264 encoders of H264VideoReader,
+// RTPReader and MediaRecorder place IDR frames. The zero value keeps the
+// encoder's default interval: H264ReaderConfig.KeyInterval for encoded
+// readers and x264's 250 frames for recordings.
+type KeyframePolicy struct {
+	// Interval is the maximum number of frames between IDR frames.
+	Interval int
+	// Duration is the maximum time between IDR frames, converted to frames
+	// at the encoder's frame rate. It is used when Interval is 0.
+	Duration time.Duration
+	// DisableSceneCut stops x264 from inserting extra IDR frames at scene
+	// changes, so that they come exactly every interval. A fixed cadence
+	// keeps HLS segments and recording fragments the same length.
+	DisableSceneCut bool
+}
+
+// validate checks that the interval is not negative.
+func (p KeyframePolicy) validate() error {
+	if p.Interval < 0 || p.Duration < 0 {
+		return fmt.Errorf("keyframe interval must not be negative (got %d frames, %v)", p.Interval, p.Duration)
+	}
+	return nil
+}
+
+// frames returns the maximum number of frames between IDR frames at
+// frameRate (30 if unknown), or 0 if the policy sets no interval.
+func (p KeyframePolicy) frames(frameRate float64) int {
+	if p.Interval > 0 {
+		return p.Interval
+	}
+	if p.Duration > 0 {
+		if frameRate <= 0 {
+			frameRate = 30
+		}
+		return max(1, int(frameRate*p.Duration.Seconds()+0.5))
+	}
+	return 0
+}
+
+// args returns the x264 arguments of the policy at frameRate. Without an
+// interval in the policy, defaultInterval is used, or the encoder's default
+// if it is 0.
+func (p KeyframePolicy) args(frameRate float64, defaultInterval int) []string {
+	var args []string
+	interval := p.frames(frameRate)
+	if interval == 0 {
+		interval = defaultInterval
+	}
+	if interval > 0 {
+		args = append(args, "-g", strconv.Itoa(interval))
+	}
+	if p.DisableSceneCut {
+		args = append(args, "-sc_threshold", "0")
+	}
+	return args
+}
@@ -0,0 +1,54 @@
+package mediadevices
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyframePolicyArgs(t *testing.T) {
+	tests := []struct {
+		name      string
+		policy    KeyframePolicy
+		frameRate float64
+		def       int
+		want      string
+	}{
+		{"default", KeyframePolicy{}, 30, 60, "-g 60"},
+		{"encoder default", KeyframePolicy{}, 30, 0, ""},
+		{"frames", KeyframePolicy{Interval: 48}, 30, 60, "-g 48"},
+		{"seconds", KeyframePolicy{Duration: 2 * time.Second}, 25, 60, "-g 50"},
+		{"fractional rate", KeyframePolicy{Duration: time.Second}, 29.97, 0, "-g 30"},
+		{"unknown rate", KeyframePolicy{Duration: 4 * time.Second}, 0, 0, "-g 120"},
+		{"frames win", KeyframePolicy{Interval: 10, Duration: time.Hour}, 30, 60, "-g 10"},
+		{"no scene cut", KeyframePolicy{Duration: time.Second, DisableSceneCut: true}, 60, 0, "-g 60 -sc_threshold 0"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(tt.policy.args(tt.frameRate, tt.def), " "); got != tt.want {
+			t.Errorf("%s: args = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+	if err := (KeyframePolicy{Duration: -time.Second}).validate(); err == nil {
+		t.Error("negative duration accepted")
+	}
+}
+
+func TestBuildH264Args_Keyframes(t *testing.T) {
+	args := buildH264Args(H264ReaderConfig{DeviceName: "cam", FrameRate: 30})
+	if slices.Contains(args, "-force_key_frames") {
+		t.Errorf("IDR frames forced regardless of the GOP: %v", args)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "-g 60 ") || strings.Contains(got, "-sc_threshold") {
+		t.Errorf("default keyframe args wrong: %s", got)
+	}
+
+	args = buildH264Args(H264ReaderConfig{DeviceName: "cam", FrameRate: 30, KeyInterval: 90, Keyframes: KeyframePolicy{Duration: time.Second, DisableSceneCut: true}})
+	if got := strings.Join(args, " "); !strings.Contains(got, "-g 30 -sc_threshold 0") || strings.Contains(got, "-g 90") {
+		t.Errorf("Keyframes should override KeyInterval: %s", got)
+	}
+	args = buildH264Args(H264ReaderConfig{DeviceName: "cam", KeyInterval: 90, Keyframes: KeyframePolicy{DisableSceneCut: true}})
+	if got := strings.Join(args, " "); !strings.Contains(got, "-g 90 -sc_threshold 0") {
+		t.Errorf("KeyInterval should apply without a Keyframes interval: %s", got)
+	}
+}
@@ -0,0 +1,452 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Matroska codec IDs of the tracks a MatroskaWriter writes.
+const (
+	// MatroskaCodecH264 is H.264 video. WriteFrame takes one Annex B access
+	// unit per frame, as produced by H264VideoReader.
+	MatroskaCodecH264 = "V_MPEG4/ISO/AVC"
+	// MatroskaCodecOpus is Opus audio, one Opus packet per frame.
+	// CodecPrivate must hold the OpusHead header.
+	MatroskaCodecOpus = "A_OPUS"
+	// MatroskaCodecAAC is AAC audio, one raw AAC frame (no ADTS header) per
+	// frame. CodecPrivate must hold the AudioSpecificConfig.
+	MatroskaCodecAAC = "A_AAC"
+	// MatroskaCodecPCM is little-endian signed PCM audio, as delivered in
+	// AudioChunk.Data; see MatroskaWriter.WriteAudioChunk.
+	MatroskaCodecPCM = "A_PCM/INT/LIT"
+)
+
+// matroskaMaxClusterDuration is the longest span of a cluster. A cluster
+// also starts at every video keyframe, so this only matters for audio-only
+// streams and very long GOPs.
+const matroskaMaxClusterDuration = 5 * time.Second
+
+// MatroskaTrack describes one track of a MatroskaWriter. The track kind
+// follows from Codec: IDs starting with "V_" are video, "A_" audio.
+type MatroskaTrack struct {
+	// Codec is the Matroska codec ID, e.g. MatroskaCodecH264.
+	Codec string
+	// CodecPrivate is the codec initialization data. For H.264 it may be
+	// left empty: it is then built from the SPS and PPS of the first
+	// keyframe.
+	CodecPrivate []byte
+
+	// Width and Height are the picture size of a video track.
+	Width, Height int
+
+	// SampleRate and Channels describe an audio track. BitDepth is the
+	// sample size of PCM audio, 16 if zero.
+	SampleRate int
+	Channels   int
+	BitDepth   int
+}
+
+func (t MatroskaTrack) video() bool {
+	return strings.HasPrefix(t.Codec, "V_")
+}
+
+// MatroskaWriter muxes encoded tracks into a live Matroska stream written to
+// any io.Writer, such as a network connection or an http.ResponseWriter. The
+// segment and its clusters have unknown size, so nothing is ever rewritten
+// and the output can be played while it is being written; it has no seek
+// index, which players do not need for a live stream.
+//
+// The stream starts at the first keyframe of the first video track: frames
+// written before it are dropped, and its timestamp becomes time zero. A new
+// cluster begins at every video keyframe. When w has a Flush method (as
+// http.ResponseWriter does through http.Flusher), it is called after each
+// frame so that clients receive it immediately.
+//
+// The methods are safe for concurrent use, so video and audio can be written
+// from their own goroutines. Frames of a track must be written in decode
+// order, and tracks should be interleaved by timestamp.
+type MatroskaWriter struct {
+	w      io.Writer
+	flush  func()
+	tracks []MatroskaTrack
+	video  int // index of the first video track, -1 if none
+
+	mu          sync.Mutex
+	started     bool
+	base        time.Duration
+	cluster     bool
+	clusterTime time.Duration
+	err         error
+}
+
+// NewMatroskaWriter returns a writer of a Matroska stream with the given
+// tracks to w. Frames are written with WriteFrame, addressing a track by its
+// index in tracks. The header is written with the first frame.
+func NewMatroskaWriter(w io.Writer, tracks ...MatroskaTrack) (*MatroskaWriter, error) {
+	if len(tracks) == 0 || len(tracks) > 126 {
+		return nil, fmt.Errorf("matroska: %d tracks, want 1 to 126", len(tracks))
+	}
+	m := &MatroskaWriter{w: w, tracks: append([]MatroskaTrack(nil), tracks...), video: -1}
+	for i, t := range m.tracks {
+		switch {
+		case t.video():
+			if t.Width <= 0 || t.Height <= 0 {
+				return nil, fmt.Errorf("matroska: track %d: video needs Width and Height", i)
+			}
+			if m.video < 0 {
+				m.video = i
+			}
+		case strings.HasPrefix(t.Codec, "A_"):
+			if t.SampleRate <= 0 || t.Channels <= 0 {
+				return nil, fmt.Errorf("matroska: track %d: audio needs SampleRate and Channels", i)
+			}
+			if t.Codec == MatroskaCodecPCM && t.BitDepth == 0 {
+				m.tracks[i].BitDepth = 16
+			}
+		default:
+			return nil, fmt.Errorf("matroska: track %d: unsupported codec %q", i, t.Codec)
+		}
+		if len(t.CodecPrivate) == 0 && t.Codec != MatroskaCodecH264 && t.Codec != MatroskaCodecPCM {
+			return nil, fmt.Errorf("matroska: track %d: %s needs CodecPrivate", i, t.Codec)
+		}
+	}
+	if f, ok := w.(interface{ Flush() }); ok {
+		m.flush = f.Flush
+	}
+	return m, nil
+}
+
+// WriteFrame writes one frame of track, presented at pts. keyframe marks
+// frames that can be decoded on their own; for audio codecs every frame is
+// one. H.264 frames are Annex B access units and are stored with length
+// prefixes as Matroska requires.
+//
+// After a write to the underlying writer fails, WriteFrame returns that
+// error for every further call.
+func (m *MatroskaWriter) WriteFrame(track int, data []byte, pts time.Duration, keyframe bool) error {
+	if track < 0 || track >= len(m.tracks) {
+		return fmt.Errorf("matroska: no track %d", track)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.err != nil {
+		return m.err
+	}
+
+	t := &m.tracks[track]
+	if t.Codec == MatroskaCodecH264 {
+		nals := parseH264Bitstream(data, nil)
+		if len(t.CodecPrivate) == 0 && keyframe {
+			t.CodecPrivate = avcDecoderConfig(nals)
+		}
+		data = lengthPrefixedNALs(nals)
+	}
+
+	var out []byte
+	if !m.started {
+		if !m.ready(track, keyframe) {
+			return nil
+		}
+		m.started = true
+		m.base = pts
+		out = m.header()
+	}
+	pts -= m.base
+	if pts < 0 {
+		// Audio captured just before the first video keyframe.
+		return nil
+	}
+
+	rel := pts - m.clusterTime
+	if !m.cluster || (keyframe && track == m.video) || rel >= matroskaMaxClusterDuration ||
+		rel.Milliseconds() < math.MinInt16 || rel.Milliseconds() > math.MaxInt16 {
+		m.cluster = true
+		m.clusterTime = pts
+		rel = 0
+		out = append(out, ebmlUnknownSize(mkvCluster)...)
+		out = append(out, ebmlUnsigned(mkvTimestamp, uint64(pts.Milliseconds()))...)
+	}
+
+	block := make([]byte, 4, 4+len(data))
+	block[0] = 0x80 | byte(track+1) // track number as a one-byte EBML varint
+	binary.BigEndian.PutUint16(block[1:], uint16(int16(rel.Milliseconds())))
+	if keyframe {
+		block[3] = 0x80
+	}
+	return m.write(append(out, ebmlElement(mkvSimpleBlock, append(block, data...))...))
+}
+
+// WriteAudioChunk writes chunk to a 16-bit MatroskaCodecPCM track, presented
+// at pts.
+func (m *MatroskaWriter) WriteAudioChunk(track int, chunk *AudioChunk, pts time.Duration) error {
+	if track < 0 || track >= len(m.tracks) || m.tracks[track].Codec != MatroskaCodecPCM || m.tracks[track].BitDepth != 16 {
+		return fmt.Errorf("matroska: track %d is not a 16-bit PCM track", track)
+	}
+	data := make([]byte, 2*len(chunk.Data))
+	for i, s := range chunk.Data {
+		binary.LittleEndian.PutUint16(data[2*i:], uint16(s))
+	}
+	return m.WriteFrame(track, data, pts, true)
+}
+
+// ready reports whether the stream can start with this frame: at the first
+// keyframe of the first video track, once its codec private data is known.
+func (m *MatroskaWriter) ready(track int, keyframe bool) bool {
+	if m.video >= 0 && (track != m.video || !keyframe) {
+		return false
+	}
+	for _, t := range m.tracks {
+		if len(t.CodecPrivate) == 0 && t.Codec == MatroskaCodecH264 {
+			return false
+		}
+	}
+	return true
+}
+
+// write writes b and flushes the writer, recording a failure.
+func (m *MatroskaWriter) write(b []byte) error {
+	if _, err := m.w.Write(b); err != nil {
+		m.err = fmt.Errorf("matroska: %w", err)
+		return m.err
+	}
+	if m.flush != nil {
+		m.flush()
+	}
+	return nil
+}
+
+// header returns the EBML header, the start of the segment and its Info
+// and Tracks elements.
+func (m *MatroskaWriter) header() []byte {
+	b := ebmlElement(mkvEBML, concatBytes(
+		ebmlUnsigned(mkvEBMLVersion, 1),
+		ebmlUnsigned(mkvEBMLReadVersion, 1),
+		ebmlUnsigned(mkvEBMLMaxIDLength, 4),
+		ebmlUnsigned(mkvEBMLMaxSizeLength, 8),
+		ebmlString(mkvDocType, "matroska"),
+		ebmlUnsigned(mkvDocTypeVersion, 4),
+		ebmlUnsigned(mkvDocTypeReadVersion, 2),
+	))
+	b = append(b, ebmlUnknownSize(mkvSegment)...)
+	b = append(b, ebmlElement(mkvInfo, concatBytes(
+		ebmlUnsigned(mkvTimestampScale, uint64(time.Millisecond)),
+		ebmlString(mkvMuxingApp, "mediadevices-ffmpeg"),
+		ebmlString(mkvWritingApp, "mediadevices-ffmpeg"),
+	))...)
+
+	var entries []byte
+	for i, t := range m.tracks {
+		entry := concatBytes(
+			ebmlUnsigned(mkvTrackNumber, uint64(i+1)),
+			ebmlUnsigned(mkvTrackUID, uint64(i+1)),
+			ebmlUnsigned(mkvFlagLacing, 0),
+			ebmlString(mkvCodecID, t.Codec),
+		)
+		if len(t.CodecPrivate) > 0 {
+			entry = append(entry, ebmlElement(mkvCodecPrivate, t.CodecPrivate)...)
+		}
+		if t.video() {
+			entry = append(entry, ebmlUnsigned(mkvTrackType, 1)...)
+			entry = append(entry, ebmlElement(mkvVideo, concatBytes(
+				ebmlUnsigned(mkvPixelWidth, uint64(t.Width)),
+				ebmlUnsigned(mkvPixelHeight, uint64(t.Height)),
+			))...)
+		} else {
+			audio := concatBytes(
+				ebmlFloat(mkvSamplingFrequency, float64(t.SampleRate)),
+				ebmlUnsigned(mkvChannels, uint64(t.Channels)),
+			)
+			if t.BitDepth > 0 {
+				audio = append(audio, ebmlUnsigned(mkvBitDepth, uint64(t.BitDepth))...)
+			}
+			entry = append(entry, ebmlUnsigned(mkvTrackType, 2)...)
+			entry = append(entry, ebmlElement(mkvAudio, audio)...)
+		}
+		entries = append(entries, ebmlElement(mkvTrackEntry, entry)...)
+	}
+	return append(b, ebmlElement(mkvTracks, entries)...)
+}
+
+// WriteMatroska writes the stream of r to w as a live Matroska stream with a
+// single H.264 track (see MatroskaWriter) until the stream ends, which
+// returns nil, or reading or writing fails. NAL units are grouped into
+// access units by their timestamps, so each frame is written when the first
+// unit of the next one arrives. WriteMatroska takes over the read loop: do
+// not call Read while it runs.
+func (r *H264VideoReader) WriteMatroska(w io.Writer) error {
+	return writeH264Matroska(w, r.Width(), r.Height(), r.Read)
+}
+
+// WriteMatroska is like H264VideoReader.WriteMatroska. NAL units already
+// peeked are written first; packets of a partly read NAL unit are not.
+func (r *RTPReader) WriteMatroska(w io.Writer) error {
+	return writeH264Matroska(w, r.Width(), r.Height(), func() (*NALUnit, error) {
+		nal, _, err := r.readNAL()
+		return nal, err
+	})
+}
+
+// writeH264Matroska writes the NAL units returned by next to w until next
+// fails; see H264VideoReader.WriteMatroska.
+func writeH264Matroska(w io.Writer, width, height int, next func() (*NALUnit, error)) error {
+	m, err := NewMatroskaWriter(w, MatroskaTrack{Codec: MatroskaCodecH264, Width: width, Height: height})
+	if err != nil {
+		return err
+	}
+	var au []byte
+	var pts, dts time.Duration
+	var keyframe bool
+	for {
+		nal, err := next()
+		if err != nil || (len(au) > 0 && nal.DTS != dts) {
+			if len(au) > 0 {
+				if werr := m.WriteFrame(0, au, pts, keyframe); werr != nil {
+					return werr
+				}
+			}
+			au, keyframe = au[:0], false
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		pts, dts = nal.PTS, nal.DTS
+		keyframe = keyframe || nal.Type == 5
+		au = append(append(au, annexBStartCode...), nal.Data...)
+	}
+}
+
+// avcDecoderConfig builds the AVCDecoderConfigurationRecord (ISO/IEC
+// 14496-15) from the first SPS and PPS among nals, or returns nil if either
+// is missing.
+func avcDecoderConfig(nals []*NALUnit) []byte {
+	var sps, pps []byte
+	for _, nal := range nals {
+		switch {
+		case nal.Type == NALUTypeSPS && sps == nil && len(nal.Data) >= 4:
+			sps = nal.Data
+		case nal.Type == NALUTypePPS && pps == nil:
+			pps = nal.Data
+		}
+	}
+	if sps == nil || pps == nil {
+		return nil
+	}
+	b := []byte{1, sps[1], sps[2], sps[3], 0xFF, 0xE1} // 4-byte lengths, one SPS
+	b = binary.BigEndian.AppendUint16(b, uint16(len(sps)))
+	b = append(append(b, sps...), 1)
+	b = binary.BigEndian.AppendUint16(b, uint16(len(pps)))
+	return append(b, pps...)
+}
+
+// lengthPrefixedNALs returns nals with a 4-byte big-endian length before
+// each unit instead of a start code.
+func lengthPrefixedNALs(nals []*NALUnit) []byte {
+	var b []byte
+	for _, nal := range nals {
+		b = binary.BigEndian.AppendUint32(b, uint32(len(nal.Data)))
+		b = append(b, nal.Data...)
+	}
+	return b
+}
+
+// Matroska element IDs used by MatroskaWriter, in addition to those read
+// by the recording indexer.
+const (
+	mkvEBML               = 0x1A45DFA3
+	mkvEBMLVersion        = 0x4286
+	mkvEBMLReadVersion    = 0x42F7
+	mkvEBMLMaxIDLength    = 0x42F2
+	mkvEBMLMaxSizeLength  = 0x42F3
+	mkvDocType            = 0x4282
+	mkvDocTypeVersion     = 0x4287
+	mkvDocTypeReadVersion = 0x4285
+	mkvInfo               = 0x1549A966
+	mkvTimestampScale     = 0x2AD7B1
+	mkvMuxingApp          = 0x4D80
+	mkvWritingApp         = 0x5741
+	mkvTrackUID           = 0x73C5
+	mkvFlagLacing         = 0x9C
+	mkvCodecID            = 0x86
+	mkvCodecPrivate       = 0x63A2
+	mkvVideo              = 0xE0
+	mkvPixelWidth         = 0xB0
+	mkvPixelHeight        = 0xBA
+	mkvAudio              = 0xE1
+	mkvSamplingFrequency  = 0xB5
+	mkvChannels           = 0x9F
+	mkvBitDepth           = 0x6264
+)
+
+// ebmlID returns the bytes of an element ID.
+func ebmlID(id uint32) []byte {
+	switch {
+	case id >= 1<<24:
+		return []byte{byte(id >> 24), byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 1<<16:
+		return []byte{byte(id >> 16), byte(id >> 8), byte(id)}
+	case id >= 1<<8:
+		return []byte{byte(id >> 8), byte(id)}
+	default:
+		return []byte{byte(id)}
+	}
+}
+
+// ebmlSize returns the shortest EBML varint encoding of an element size.
+// The all-ones value of each length is reserved for "unknown".
+func ebmlSize(n uint64) []byte {
+	length := 1
+	for n >= 1<<(7*length)-1 {
+		length++
+	}
+	b := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		b[i] = byte(n)
+		n >>= 8
+	}
+	b[0] |= 0x80 >> (length - 1)
+	return b
+}
+
+// ebmlElement returns an element with the given payload.
+func ebmlElement(id uint32, payload []byte) []byte {
+	b := append(ebmlID(id), ebmlSize(uint64(len(payload)))...)
+	return append(b, payload...)
+}
+
+// ebmlUnknownSize returns the start of a master element of unknown size,
+// which extends until an element that cannot be its child.
+func ebmlUnknownSize(id uint32) []byte {
+	return append(ebmlID(id), 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+}
+
+func ebmlUnsigned(id uint32, v uint64) []byte {
+	b := binary.BigEndian.AppendUint64(nil, v)
+	for len(b) > 1 && b[0] == 0 {
+		b = b[1:]
+	}
+	return ebmlElement(id, b)
+}
+
+func ebmlFloat(id uint32, v float64) []byte {
+	return ebmlElement(id, binary.BigEndian.AppendUint64(nil, math.Float64bits(v)))
+}
+
+func ebmlString(id uint32, s string) []byte {
+	return ebmlElement(id, []byte(s))
+}
+
+func concatBytes(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
@@ -0,0 +1,229 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+// mkvTestBlock is a SimpleBlock found by mkvBlocks.
+type mkvTestBlock struct {
+	track    int64
+	time     time.Duration
+	keyframe bool
+	data     []byte
+}
+
+// mkvBlocks returns the SimpleBlocks of a Matroska stream with their
+// absolute timestamps, and the number of clusters.
+func mkvBlocks(t *testing.T, b []byte) (blocks []mkvTestBlock, clusters int) {
+	t.Helper()
+	var clusterTS int64
+	for len(b) > 0 {
+		id, idn := readVint(b, true)
+		size, sn := readVint(b[idn:], false)
+		if idn == 0 || sn == 0 {
+			t.Fatalf("truncated element header: % x", b)
+		}
+		b = b[idn+sn:]
+		switch id {
+		case mkvSegment, mkvCluster:
+			if id == mkvCluster {
+				clusters++
+			}
+			continue // descend
+		}
+		if size < 0 || int64(len(b)) < size {
+			t.Fatalf("element %x: bad size %d", id, size)
+		}
+		body := b[:size]
+		b = b[size:]
+		switch id {
+		case mkvTimestamp:
+			clusterTS = int64(ebmlUint(body))
+		case mkvSimpleBlock:
+			track, n := readVint(body, false)
+			rel := int64(int16(binary.BigEndian.Uint16(body[n:])))
+			blocks = append(blocks, mkvTestBlock{
+				track:    track,
+				time:     time.Duration(clusterTS+rel) * time.Millisecond,
+				keyframe: body[n+2]&0x80 != 0,
+				data:     body[n+3:],
+			})
+		}
+	}
+	return blocks, clusters
+}
+
+type flushCounter struct {
+	bytes.Buffer
+	flushes int
+}
+
+func (w *flushCounter) Flush() { w.flushes++ }
+
+func TestMatroskaWriter(t *testing.T) {
+	sps := []byte{0x67, 0x42, 0xC0, 0x1E, 0xDA}
+	pps := []byte{0x68, 0xCE, 0x3C, 0x80}
+	idr := []byte{0x65, 0x88, 0x84}
+	p := []byte{0x41, 0x9A, 0x02}
+
+	var out flushCounter
+	m, err := NewMatroskaWriter(&out,
+		MatroskaTrack{Codec: MatroskaCodecH264, Width: 640, Height: 480},
+		MatroskaTrack{Codec: MatroskaCodecPCM, SampleRate: 48000, Channels: 1},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunk := &AudioChunk{Data: []int16{1, -2}, Channels: 1, SampleRate: 48000, SamplesPerChannel: 2}
+	for _, f := range []struct {
+		track    int
+		data     []byte
+		pts      time.Duration
+		keyframe bool
+	}{
+		{0, annexB(p), 0, false}, // before the first keyframe: dropped
+		{1, nil, 50 * time.Millisecond, true},
+		{0, annexB(sps, pps, idr), 100 * time.Millisecond, true},
+		{1, nil, 110 * time.Millisecond, true},
+		{0, annexB(p), 133 * time.Millisecond, false},
+		{0, annexB(sps, pps, idr), 2100 * time.Millisecond, true},
+	} {
+		if f.track == 1 {
+			err = m.WriteAudioChunk(1, chunk, f.pts)
+		} else {
+			err = m.WriteFrame(f.track, f.data, f.pts, f.keyframe)
+		}
+		if err != nil {
+			t.Fatalf("write at %v: %v", f.pts, err)
+		}
+	}
+
+	if !bytes.HasPrefix(out.Bytes(), []byte{0x1A, 0x45, 0xDF, 0xA3}) {
+		t.Fatalf("no EBML header: % x", out.Bytes()[:8])
+	}
+	avcC := append([]byte{1, 0x42, 0xC0, 0x1E, 0xFF, 0xE1, 0, 5}, sps...)
+	avcC = append(append(avcC, 1, 0, 4), pps...)
+	if !bytes.Contains(out.Bytes(), ebmlElement(mkvCodecPrivate, avcC)) {
+		t.Error("CodecPrivate is not the avcC of the SPS and PPS")
+	}
+	if !bytes.Contains(out.Bytes(), ebmlString(mkvCodecID, MatroskaCodecPCM)) {
+		t.Error("PCM track missing")
+	}
+
+	blocks, clusters := mkvBlocks(t, out.Bytes())
+	want := []mkvTestBlock{
+		{1, 0, true, nil},
+		{2, 10 * time.Millisecond, true, nil},
+		{1, 33 * time.Millisecond, false, nil},
+		{1, 2 * time.Second, true, nil},
+	}
+	if len(blocks) != len(want) || clusters != 2 {
+		t.Fatalf("%d blocks in %d clusters, want %d in 2: %+v", len(blocks), clusters, len(want), blocks)
+	}
+	for i, b := range blocks {
+		if b.track != want[i].track || b.time != want[i].time || b.keyframe != want[i].keyframe {
+			t.Errorf("block %d = track %d at %v key %v, want %+v", i, b.track, b.time, b.keyframe, want[i])
+		}
+	}
+	if wantData := []byte{0, 0, 0, 3, 0x41, 0x9A, 0x02}; !bytes.Equal(blocks[2].data, wantData) {
+		t.Errorf("P frame = % x, want length-prefixed % x", blocks[2].data, wantData)
+	}
+	if wantData := []byte{1, 0, 0xFE, 0xFF}; !bytes.Equal(blocks[1].data, wantData) {
+		t.Errorf("PCM = % x, want % x", blocks[1].data, wantData)
+	}
+	if out.flushes != 4 {
+		t.Errorf("%d flushes, want one per written frame", out.flushes)
+	}
+
+	// The stream is also readable by the recording indexer.
+	x := newRecordingIndexer("matroska")
+	x.Write(out.Bytes())
+	if idx := x.index(); len(idx.Keyframes) != 2 || idx.Keyframes[1].Time != 2*time.Second {
+		t.Errorf("indexed keyframes = %+v", idx.Keyframes)
+	}
+}
+
+func TestMatroskaWriter_Errors(t *testing.T) {
+	for _, tracks := range [][]MatroskaTrack{
+		nil,
+		{{Codec: MatroskaCodecH264}},
+		{{Codec: MatroskaCodecOpus, SampleRate: 48000, Channels: 2}},
+		{{Codec: "S_TEXT/UTF8"}},
+	} {
+		if _, err := NewMatroskaWriter(&bytes.Buffer{}, tracks...); err == nil {
+			t.Errorf("tracks %+v accepted", tracks)
+		}
+	}
+
+	m, err := NewMatroskaWriter(&failingWriter{}, MatroskaTrack{Codec: MatroskaCodecPCM, SampleRate: 8000, Channels: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.WriteFrame(0, []byte{0, 0}, 0, true); err == nil {
+		t.Fatal("write error not reported")
+	}
+	if err := m.WriteFrame(0, []byte{0, 0}, time.Millisecond, true); err == nil {
+		t.Error("write error not kept")
+	}
+	if err := m.WriteFrame(1, nil, 0, true); err == nil {
+		t.Error("unknown track accepted")
+	}
+}
+
+func TestH264VideoReader_WriteMatroska(t *testing.T) {
+	newReader := func() *H264VideoReader {
+		proc, err := startProcess(Config{FFmpegPath: "/bin/sh"}, shPrintf(annexB(
+			[]byte{0x67, 0x42, 0xC0, 0x1E}, // SPS
+			[]byte{0x68, 0xCE},             // PPS
+			[]byte{0x65, 0x80},             // IDR, first slice
+			[]byte{0x65, 0x40},             // IDR, second slice of the same picture
+			[]byte{0x41, 0x80},             // P frame
+			[]byte{0x41, 0x80},             // P frame
+		), "exit 0"))
+		if err != nil {
+			t.Fatalf("start encoder: %v", err)
+		}
+		r := &H264VideoReader{
+			proc:    proc,
+			width:   640,
+			height:  480,
+			readBuf: make([]byte, 4096),
+			timing:  newH264Timing(30, 0),
+			stats:   newEncoderStats(0),
+		}
+		t.Cleanup(func() { r.Close() })
+		return r
+	}
+
+	for _, write := range []func(*bytes.Buffer) error{
+		func(out *bytes.Buffer) error { return newReader().WriteMatroska(out) },
+		func(out *bytes.Buffer) error {
+			r := &RTPReader{reader: newReader(), mtu: 1200}
+			if _, err := r.PeekNAL(); err != nil {
+				return err
+			}
+			return r.WriteMatroska(out)
+		},
+	} {
+		var out bytes.Buffer
+		if err := write(&out); err != nil {
+			t.Fatalf("WriteMatroska: %v", err)
+		}
+		if !bytes.HasPrefix(out.Bytes(), ebmlID(mkvEBML)) {
+			t.Fatalf("no EBML header: % x", out.Bytes()[:8])
+		}
+		blocks, _ := mkvBlocks(t, out.Bytes())
+		if len(blocks) != 3 || !blocks[0].keyframe || blocks[1].keyframe || blocks[2].time != 66*time.Millisecond {
+			t.Fatalf("blocks = %+v, want the IDR picture and two P frames", blocks)
+		}
+		// SPS, PPS and both slices of the IDR picture, each with a 4-byte length.
+		if n := len(blocks[0].data); n != 4+4+4+2+4+2+4+2 {
+			t.Errorf("IDR access unit is %d bytes: % x", n, blocks[0].data)
+		}
+	}
+}
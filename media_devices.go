@@ -0,0 +1,81 @@
+package mediadevices
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MediaDevices is an independent instance of the package: it holds its own
+// Config, device discovery cache, virtual devices, label consent and
+// registry of active resources. One
+// program can use several, for example to capture with two different
+// FFmpeg binaries, or to keep the devices of tests apart from those of
+// production capture.
+//
+// The package-level functions (SetConfig, EnumerateDevices, GetUserMedia,
+// NewVideoReader, ActiveResources, CloseAll, ...) use the default instance,
+// returned by DefaultMediaDevices. Tracks remember the instance that
+// created them, so SwitchDevice and the stall watchdog keep using its
+// configuration, and a MediaRecorder uses the instance of the video track
+// it records.
+type MediaDevices struct {
+	configMu sync.RWMutex
+	config   Config
+
+	devicesMu       sync.Mutex
+	devicesCached   bool
+	cachedDevices   []MediaDeviceInfo
+	devicesCachedAt time.Time
+
+	resources *resourceRegistry
+
+	prewarmMu sync.Mutex
+	prewarmed map[string]*prewarmedCapture // by prewarmKey
+
+	watch deviceWatcher // OnDeviceChange subscribers
+
+	// labelConsent is set once GetUserMedia has succeeded; see
+	// Config.RedactLabels.
+	labelConsent atomic.Bool
+
+	virtualMu      sync.Mutex
+	virtualDevices []virtualDevice // RegisterVirtualDevice, in registration order
+}
+
+// defaultMediaDevices is the instance used by the package-level functions.
+var defaultMediaDevices = NewMediaDevices(Config{})
+
+// NewMediaDevices returns a new instance with the configuration cfg and an
+// empty device cache.
+func NewMediaDevices(cfg Config) *MediaDevices {
+	m := &MediaDevices{}
+	m.resources = newResourceRegistry(m)
+	m.SetConfig(cfg)
+	return m
+}
+
+// DefaultMediaDevices returns the instance used by the package-level
+// functions.
+func DefaultMediaDevices() *MediaDevices {
+	return defaultMediaDevices
+}
+
+// SetConfig updates the configuration of the instance. An empty FFmpegPath
+// means "ffmpeg", resolved via PATH.
+func (m *MediaDevices) SetConfig(cfg Config) {
+	if cfg.FFmpegPath == "" {
+		cfg.FFmpegPath = "ffmpeg"
+	}
+	cfg.owner = m
+	m.configMu.Lock()
+	defer m.configMu.Unlock()
+	m.config = cfg
+}
+
+// Config returns a copy of the configuration of the instance.
+func (m *MediaDevices) Config() Config {
+	m.configMu.RLock()
+	defer m.configMu.RUnlock()
+	return m.config
+}
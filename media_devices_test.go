@@ -0,0 +1,64 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMediaDevicesIsolation(t *testing.T) {
+	discover := func(id, name string) func(context.Context) ([]MediaDeviceInfo, error) {
+		return func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: id, DeviceName: name, Label: id, Kind: MediaDeviceKindVideoInput}}, nil
+		}
+	}
+	a := NewMediaDevices(Config{
+		FFmpegPath:      "/bin/sh",
+		DiscoverDevices: discover("cam-a", "/dev/video0"),
+		ArgsHook:        func([]string) []string { return []string{"-c", "exec sleep 30"} },
+	})
+	b := NewMediaDevices(Config{DiscoverDevices: discover("cam-b", "/dev/video1")})
+
+	if got := b.Config().FFmpegPath; got != "ffmpeg" {
+		t.Errorf("default FFmpegPath = %q", got)
+	}
+	for _, tc := range []struct {
+		m    *MediaDevices
+		want string
+	}{{a, "cam-a"}, {b, "cam-b"}} {
+		devices, err := tc.m.EnumerateDevices()
+		if err != nil || len(devices) != 1 || devices[0].DeviceID != tc.want {
+			t.Errorf("EnumerateDevices = %+v, %v; want only %s", devices, err, tc.want)
+		}
+	}
+	if _, err := a.NewVideoReader(VideoConfig{DeviceID: "cam-b"}); err == nil {
+		t.Error("device of another instance selected")
+	}
+
+	before := ActiveResources()
+	id := "cam-a"
+	stream, err := a.GetUserMedia(MediaTrackConstraints{Video: &VideoTrackConstraints{DeviceID: &id}})
+	if err != nil {
+		t.Fatalf("GetUserMedia: %v", err)
+	}
+	if n := a.ActiveResources(); n.Processes != 1 || n.Tracks != 1 {
+		t.Errorf("a.ActiveResources = %+v, want one process and one track", n)
+	}
+	if n := ActiveResources(); n != before {
+		t.Errorf("default instance counts %+v, want %+v", n, before)
+	}
+	if n := b.ActiveResources(); n != (ResourceCounts{}) {
+		t.Errorf("b.ActiveResources = %+v", n)
+	}
+
+	if err := a.CloseAll(); err != nil {
+		t.Errorf("CloseAll: %v", err)
+	}
+	if n := a.ActiveResources(); n != (ResourceCounts{}) {
+		t.Errorf("after CloseAll: %+v", n)
+	}
+	if stream.GetVideoTracks()[0].ReadyState() != MediaStreamTrackStateEnded {
+		t.Error("track not stopped")
+	}
+}
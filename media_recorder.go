@@ -0,0 +1,787 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// MediaRecorderState 表示录制器的状态。
+// 对应 MDN 的 MediaRecorder.state。
+type MediaRecorderState string
+
+const (
+	// MediaRecorderStateInactive 表示未在录制（尚未开始或已停止）。
+	MediaRecorderStateInactive MediaRecorderState = "inactive"
+	// MediaRecorderStateRecording 表示正在录制。
+	MediaRecorderStateRecording MediaRecorderState = "recording"
+	// MediaRecorderStatePaused 表示录制已暂停。
+	MediaRecorderStatePaused MediaRecorderState = "paused"
+)
+
+// recorderFinishTimeout 是停止录制时等待 FFmpeg 完成文件写入的最长时间。
+const recorderFinishTimeout = 10 * time.Second
+
+// MediaRecorderOptions 配置录制输出。
+type MediaRecorderOptions struct {
+	// Path 输出文件路径。容器格式由扩展名决定：.mp4、.mkv 或 .ts。
+	Path string
+	// VideoBitRate 视频码率（kbps），0 表示使用编码器默认的质量模式。
+	VideoBitRate int
+	// Preset x264 预设，默认 "veryfast"。
+	Preset string
+	// Keyframes 关键帧（IDR）策略，默认使用 x264 的间隔（250 帧）并在场景切换处插入关键帧。
+	// 分片 MP4 的片段、关键帧索引和哈希链都在关键帧处切分，固定的间隔使它们长度一致。
+	Keyframes KeyframePolicy
+	// DisableAudio 为 true 时只录制视频。默认同时录制流的第一个音频轨道（AAC）。
+	// Windows 上无法把音频以第二路管道传给 FFmpeg，流中有音频轨道时须设置 DisableAudio，
+	// 否则 Start 返回错误。
+	DisableAudio bool
+	// AudioBitRate 音频码率（kbps），默认 128。
+	AudioBitRate int
+	// Encryption 启用录制文件加密，nil 表示不加密。
+	Encryption *RecordingEncryption
+	// Index 为 true 时，停止录制后写入关键帧索引边车文件（见 RecordingIndex）。
+	// 此时输出经由录制器写入文件。
+	Index bool
+	// Hash 为 true 时，在写入的同时按关键帧分段计算 SHA-256 哈希链，
+	// 与关键帧索引一起写入边车文件，用 VerifyRecording 可证明导出的录像未被修改。
+	// 隐含 Index。
+	Hash bool
+	// FastStart 为 true 时，MP4 输出使用常规（非分片）格式，并在录制结束后
+	// 将 moov 移到文件开头以便渐进式播放。录制中途断电或崩溃时文件将无法播放。
+	// 默认使用分片 MP4：每个片段自带元数据，意外中断最多丢失最后一个片段。
+	// 与 Index 及 EncryptionAESGCM 不兼容。
+	FastStart bool
+	// FragmentDuration 分片 MP4 的最大片段时长，即意外中断时最多丢失的录制时长。
+	// 片段同时在每个关键帧处切分。默认 1 秒。
+	FragmentDuration time.Duration
+	// Storage 关联的录制存储管理器（可选）。录制期间输出文件受保护不被清理，
+	// 开始和结束录制时按其配额与保留策略清理旧录制。
+	Storage *RecordingStorage
+	// Uploader 关联的上传器（可选）。录制成功结束后，输出文件及其索引
+	// 会加入上传队列。
+	Uploader *RecordingUploader
+	// VideoOffset 和 AudioOffset 是视频、音频输入的时间戳偏移（FFmpeg -itsoffset），
+	// 用于补偿已知的采集链路延迟。例如蓝牙耳机麦克风比画面晚约 200ms 到达时，
+	// 设 AudioOffset 为 -200ms 使声音提前。两者只有差值有意义：录制器会整体平移，
+	// 使较早的输入从 0 开始，避免输出出现负时间戳。
+	VideoOffset time.Duration
+	AudioOffset time.Duration
+}
+
+// defaultFragmentDuration 是分片 MP4 的默认最大片段时长。
+const defaultFragmentDuration = time.Second
+
+// 音视频同步参数。
+const (
+	// videoDriftFrames 是视频帧数与音频时钟允许的最大偏差（帧），
+	// 超出时丢弃或重复视频帧。
+	videoDriftFrames = 2
+	// audioGapThreshold 是音频落后于挂钟的最大时长：音频设备停顿超过该时长时，
+	// 视频改以挂钟为准，音频恢复后以静音补齐空缺。
+	audioGapThreshold = 500 * time.Millisecond
+)
+
+// MediaRecorder 将媒体流录制为文件。
+// 对应 MDN 的 MediaRecorder 接口。
+//
+// 录制器从流的第一个视频轨道读取帧，通过 FFmpeg 编码为 H.264，
+// 并与第一个音频轨道（AAC）复用写入文件。录制期间这些轨道由录制器读取，
+// 应用程序不应同时调用其 Read 或 ReadAudio。
+//
+// 音视频共用同一时间轴：以音频采样数为主时钟，视频按帧率丢弃或重复帧
+// 与之对齐，因此长时间录制不会出现音画逐渐错位。
+type MediaRecorder struct {
+	stream *MediaStream
+	opts   MediaRecorderOptions
+	owner  *MediaDevices // 视频轨道所属的 MediaDevices，提供配置并登记录制器
+
+	mu    sync.Mutex
+	state MediaRecorderState
+	track *MediaStreamTrack
+	audio *MediaStreamTrack
+	clock *recordingClock
+	fps   float64
+	proc  *ffmpegProcess
+	out   *os.File          // 输出文件（FFmpeg 写入 stdout 时由录制器打开）
+	enc   *encryptingWriter // Go 侧加密
+	index *recordingIndexer // 关键帧索引
+	copyc chan error        // 输出复制完成
+	stopc chan struct{}     // 通知录制循环退出
+	done  chan struct{}     // 录制循环已退出
+	adone chan struct{}     // 音频循环已退出
+	err   error             // 录制过程中的第一个错误
+
+	stderr stderrFeed // 编码器 stderr 行的订阅者
+}
+
+// NewMediaRecorder 为 stream 创建录制器。
+// 对应 MDN 的 MediaRecorder() 构造函数。
+func NewMediaRecorder(stream *MediaStream, opts MediaRecorderOptions) (*MediaRecorder, error) {
+	if stream == nil {
+		return nil, errors.New("media recorder: stream is nil")
+	}
+	if opts.Path == "" {
+		return nil, errors.New("media recorder: output path is required")
+	}
+	if _, err := recordingFormat(opts.Path); err != nil {
+		return nil, err
+	}
+	if err := opts.Keyframes.validate(); err != nil {
+		return nil, fmt.Errorf("media recorder: %w", err)
+	}
+	if opts.Hash {
+		opts.Index = true
+	}
+	if opts.FastStart {
+		if ext := strings.ToLower(filepath.Ext(opts.Path)); ext != ".mp4" {
+			return nil, fmt.Errorf("media recorder: faststart requires .mp4 output (got %s)", ext)
+		}
+		if opts.Index || (opts.Encryption != nil && opts.Encryption.Scheme != EncryptionCENC) {
+			return nil, errors.New("media recorder: faststart needs FFmpeg to write the file and cannot be combined with Index or AES-GCM encryption")
+		}
+	}
+	if enc := opts.Encryption; enc != nil {
+		if enc.Keys == nil {
+			return nil, errors.New("media recorder: encryption requires a key provider")
+		}
+		switch enc.Scheme {
+		case "", EncryptionAESGCM:
+		case EncryptionCENC:
+			if ext := strings.ToLower(filepath.Ext(opts.Path)); ext != ".mp4" {
+				return nil, fmt.Errorf("media recorder: %s encryption requires .mp4 output (got %s)", EncryptionCENC, ext)
+			}
+		default:
+			return nil, fmt.Errorf("media recorder: unknown encryption scheme %q", enc.Scheme)
+		}
+	}
+	return &MediaRecorder{
+		stream: stream,
+		opts:   opts,
+		state:  MediaRecorderStateInactive,
+	}, nil
+}
+
+// State 返回录制器的当前状态。
+// 对应 MDN 的 MediaRecorder.state。
+func (r *MediaRecorder) State() MediaRecorderState {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.state
+}
+
+// Stream 返回被录制的媒体流。
+// 对应 MDN 的 MediaRecorder.stream。
+func (r *MediaRecorder) Stream() *MediaStream {
+	return r.stream
+}
+
+// Start 开始录制。
+// 对应 MDN 的 MediaRecorder.start()。
+func (r *MediaRecorder) Start() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.state != MediaRecorderStateInactive || r.proc != nil {
+		return errors.New("media recorder: already started")
+	}
+
+	tracks := r.stream.GetVideoTracks()
+	if len(tracks) == 0 {
+		return errors.New("media recorder: stream has no video track")
+	}
+	if len(r.stream.GetAudioTracks()) > 0 && !r.opts.DisableAudio && !muxPipesSupported {
+		return fmt.Errorf("media recorder: recording audio is not supported on %s; set DisableAudio to record video only", runtime.GOOS)
+	}
+	track := tracks[0]
+	r.owner = track.owner
+	cfg := r.owner.Config()
+	settings := track.GetSettings()
+	if settings.Width <= 0 || settings.Height <= 0 {
+		return errors.New("media recorder: video track has no size (ended?)")
+	}
+
+	output := r.opts.Path
+	var extra []string
+	var key, keyID []byte
+	if enc := r.opts.Encryption; enc != nil {
+		var err error
+		if key, keyID, err = enc.Keys.NewRecordingKey(r.opts.Path); err != nil {
+			return fmt.Errorf("media recorder: get recording key: %w", err)
+		}
+		if enc.Scheme == EncryptionCENC {
+			if len(key) != 16 || len(keyID) != 16 {
+				return fmt.Errorf("media recorder: %s needs a 16-byte key and key ID (got %d and %d)", EncryptionCENC, len(key), len(keyID))
+			}
+			extra = append(extra,
+				"-encryption_scheme", "cenc-aes-ctr",
+				"-encryption_key", hex.EncodeToString(key),
+				"-encryption_kid", hex.EncodeToString(keyID),
+			)
+		} else {
+			// FFmpeg 写入 stdout，由 Go 侧加密后写入文件
+			output = "pipe:1"
+		}
+	}
+	if r.opts.Index {
+		// 索引需要看到输出的每个字节，因此同样经由 stdout 写入
+		output = "pipe:1"
+	}
+
+	// 读取第一段音频以确定采样格式
+	var audio *MediaStreamTrack
+	var first *AudioChunk
+	var ra *recorderAudio
+	at := r.stream.GetAudioTracks()
+	if len(at) > 0 && !r.opts.DisableAudio {
+		audio = at[0]
+		var err error
+		if first, err = audio.ReadAudio(); err != nil {
+			return fmt.Errorf("media recorder: read audio track: %w", err)
+		}
+		ra = &recorderAudio{sampleRate: first.SampleRate, channels: first.Channels}
+	}
+
+	args, err := buildRecorderArgs(settings, r.opts, output, extra, ra)
+	if err != nil {
+		return err
+	}
+
+	var sink io.Writer
+	if output == "pipe:1" {
+		f, err := os.Create(r.opts.Path)
+		if err != nil {
+			return fmt.Errorf("media recorder: %w", err)
+		}
+		r.out, sink = f, f
+		if key != nil && r.opts.Encryption.Scheme != EncryptionCENC {
+			enc, err := newEncryptingWriter(f, key, keyID)
+			if err != nil {
+				r.closeOutput()
+				return fmt.Errorf("media recorder: %w", err)
+			}
+			r.enc, sink = enc, enc
+		}
+		if r.opts.Index {
+			format, _ := recordingFormat(r.opts.Path)
+			r.index = newRecordingIndexer(format)
+			if r.opts.Hash {
+				r.index.hash = &recordingHasher{}
+			}
+			sink = io.MultiWriter(r.index, sink)
+		}
+	}
+
+	if st := r.opts.Storage; st != nil {
+		st.acquire(r.opts.Path)
+		r.prune()
+	}
+
+	cfg.stderrFeed = &r.stderr
+	var proc *ffmpegProcess
+	if audio != nil {
+		proc, err = startMuxProcess(cfg, args, 1)
+	} else {
+		proc, err = startEncodeProcess(cfg, args)
+	}
+	if err != nil {
+		r.closeOutput()
+		if st := r.opts.Storage; st != nil {
+			st.release(r.opts.Path)
+		}
+		return fmt.Errorf("media recorder: start encoder: %w", err)
+	}
+
+	r.track = track
+	r.audio = audio
+	r.fps = recorderFrameRate(settings)
+	r.clock = newRecordingClock(ra)
+	r.proc = proc
+	r.state = MediaRecorderStateRecording
+	r.stopc = make(chan struct{})
+	r.done = make(chan struct{})
+	r.adone = make(chan struct{})
+	if audio != nil {
+		go r.runAudio(first)
+	} else {
+		close(r.adone)
+	}
+	if sink != nil {
+		r.copyc = make(chan error, 1)
+		go func() {
+			_, err := io.Copy(sink, proc)
+			r.copyc <- err
+		}()
+	}
+	go r.run()
+	r.owner.resources.addRecorder(r)
+	return nil
+}
+
+// run 将轨道帧写入编码器，直到轨道结束或 Stop 被调用。
+// 录制音频时，按主时钟丢弃或重复帧，使已写入的帧数与媒体时间一致。
+func (r *MediaRecorder) run() {
+	defer close(r.done)
+	var written int64
+	for {
+		select {
+		case <-r.stopc:
+			return
+		default:
+		}
+
+		img, err := r.track.Read()
+		if err != nil {
+			if err != io.EOF {
+				r.setErr(fmt.Errorf("media recorder: read track: %w", err))
+			}
+			return
+		}
+		if r.clock.paused() {
+			continue
+		}
+
+		copies := int64(1)
+		if r.audio != nil {
+			copies = videoFrameCopies(written, r.clock.mediaTime(time.Now()), r.fps)
+		}
+		if copies == 0 {
+			continue
+		}
+		frame := toYCbCr420(img)
+		for ; copies > 0; copies-- {
+			if err := writeYCbCr(r.proc, frame); err != nil {
+				r.setErr(newCaptureError(fmt.Errorf("media recorder: write frame: %w", err), r.proc.LastStderr()))
+				return
+			}
+			written++
+		}
+	}
+}
+
+// videoFrameCopies 返回新到达的帧应写入的次数：视频超前主时钟时为 0（丢弃），
+// 落后时大于 1（重复），以使写入的帧数跟随媒体时间 now。
+func videoFrameCopies(written int64, now time.Duration, fps float64) int64 {
+	expected := int64(now.Seconds() * fps)
+	switch {
+	case written > expected+videoDriftFrames:
+		return 0
+	case written+1 < expected-videoDriftFrames:
+		return expected - written
+	}
+	return 1
+}
+
+// runAudio 将音频写入编码器的第二个输入，从 first 开始，直到轨道结束或 Stop 被调用。
+// 音频设备停顿造成的空缺以静音补齐，保持音频时间与挂钟一致。
+func (r *MediaRecorder) runAudio(first *AudioChunk) {
+	defer close(r.adone)
+	w := r.proc.Input(0)
+	chunk := first
+	for {
+		if !r.clock.paused() {
+			if gap := r.clock.audioGap(time.Now(), chunk.SamplesPerChannel); gap > 0 {
+				if err := r.writeAudio(w, make([]int16, gap*int64(chunk.Channels))); err != nil {
+					return
+				}
+			}
+			if err := r.writeAudio(w, chunk.Data); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-r.stopc:
+			return
+		default:
+		}
+		var err error
+		if chunk, err = r.audio.ReadAudio(); err != nil {
+			if err != io.EOF {
+				r.setErr(fmt.Errorf("media recorder: read audio track: %w", err))
+			}
+			return
+		}
+	}
+}
+
+// writeAudio 以 S16LE 写入采样，并推进音频时钟。
+func (r *MediaRecorder) writeAudio(w io.Writer, samples []int16) error {
+	buf := make([]byte, 2*len(samples))
+	for i, v := range samples {
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(v))
+	}
+	if _, err := w.Write(buf); err != nil {
+		r.setErr(newCaptureError(fmt.Errorf("media recorder: write audio: %w", err), r.proc.LastStderr()))
+		return err
+	}
+	r.clock.advance(int64(len(samples) / r.clock.channels))
+	return nil
+}
+
+// recordingClock 是录制的主时钟：以已写入的音频采样数计时，
+// 音频停顿超过 audioGapThreshold 时以挂钟为准。
+// 暂停期间的挂钟时间不计入（start 在恢复时后移）。
+type recordingClock struct {
+	sampleRate int
+	channels   int
+	samples    atomic.Int64 // 已写入的每声道采样数
+
+	mu       sync.Mutex
+	start    time.Time
+	pausedAt time.Time // 非零表示已暂停
+}
+
+func newRecordingClock(a *recorderAudio) *recordingClock {
+	c := &recordingClock{start: time.Now(), channels: 1}
+	if a != nil {
+		c.sampleRate, c.channels = a.sampleRate, a.channels
+	}
+	return c
+}
+
+// pause 暂停时钟。
+func (c *recordingClock) pause(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pausedAt.IsZero() {
+		c.pausedAt = now
+	}
+}
+
+// resume 恢复时钟，从时间轴中剔除暂停的时长。
+func (c *recordingClock) resume(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.pausedAt.IsZero() {
+		c.start = c.start.Add(now.Sub(c.pausedAt))
+		c.pausedAt = time.Time{}
+	}
+}
+
+func (c *recordingClock) paused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return !c.pausedAt.IsZero()
+}
+
+// elapsed 返回 now 时刻不含暂停的挂钟时长。
+func (c *recordingClock) elapsed(now time.Time) time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return now.Sub(c.start)
+}
+
+func (c *recordingClock) advance(samples int64) {
+	c.samples.Add(samples)
+}
+
+// audioTime 返回已写入音频的时长。
+func (c *recordingClock) audioTime() time.Duration {
+	return time.Duration(c.samples.Load()) * time.Second / time.Duration(c.sampleRate)
+}
+
+// mediaTime 返回 now 时刻的媒体时间。
+func (c *recordingClock) mediaTime(now time.Time) time.Duration {
+	return max(c.audioTime(), c.elapsed(now)-audioGapThreshold)
+}
+
+// audioGap 返回在写入 next 个采样前需补齐的静音采样数（每声道）：
+// 音频落后挂钟超过 audioGapThreshold 时，补齐到与挂钟一致。
+func (c *recordingClock) audioGap(now time.Time, next int) int64 {
+	behind := c.elapsed(now) - c.audioTime() - time.Duration(next)*time.Second/time.Duration(c.sampleRate)
+	if behind <= audioGapThreshold {
+		return 0
+	}
+	return int64(behind.Seconds() * float64(c.sampleRate))
+}
+
+func (r *MediaRecorder) setErr(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.err == nil {
+		r.err = err
+	}
+}
+
+// StderrLines 返回编码器 stderr 的最后 n 行（从旧到新），n <= 0 时返回保留的全部行。
+// 保留多少 stderr 由 Config.StderrBufferSize 决定。Stop 之后仍可调用，
+// 未开始录制时返回 nil。
+func (r *MediaRecorder) StderrLines(n int) []string {
+	r.mu.Lock()
+	proc := r.proc
+	r.mu.Unlock()
+	if proc == nil {
+		return nil
+	}
+	return proc.StderrLines(n)
+}
+
+// OnStderrLine 订阅编码器此后写入 stderr 的每一行，直到调用 cancel。
+// 可在 Start 之前订阅。fn 在读取 stderr 的 goroutine 中调用，不能阻塞。
+func (r *MediaRecorder) OnStderrLine(fn func(line string)) (cancel func()) {
+	return r.stderr.subscribe(fn)
+}
+
+// Stop 停止录制并完成输出文件的写入。
+// 对应 MDN 的 MediaRecorder.stop()。
+// 返回录制过程中发生的第一个错误。
+func (r *MediaRecorder) Stop() error {
+	r.mu.Lock()
+	if r.state == MediaRecorderStateInactive {
+		r.mu.Unlock()
+		return nil
+	}
+	r.state = MediaRecorderStateInactive
+	proc := r.proc
+	r.mu.Unlock()
+	defer r.owner.resources.removeRecorder(r)
+
+	close(r.stopc)
+	<-r.done
+	<-r.adone
+
+	// 关闭输入后 FFmpeg 写完剩余数据并退出；先读完 stdout 再等待进程
+	proc.CloseInput()
+	if r.copyc != nil {
+		if err := <-r.copyc; err != nil {
+			r.setErr(fmt.Errorf("media recorder: write output: %w", err))
+		}
+	}
+	if err := proc.Finish(recorderFinishTimeout); err != nil {
+		r.setErr(newCaptureError(fmt.Errorf("media recorder: encoder: %w", err), proc.LastStderr()))
+	}
+	if err := r.closeOutput(); err != nil {
+		r.setErr(fmt.Errorf("media recorder: close output: %w", err))
+	}
+	if r.index != nil {
+		if err := writeRecordingIndex(r.opts.Path, r.index.index()); err != nil {
+			r.setErr(fmt.Errorf("media recorder: write index: %w", err))
+		}
+		r.index = nil
+	}
+	if st := r.opts.Storage; st != nil {
+		st.release(r.opts.Path)
+		r.prune()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if up := r.opts.Uploader; up != nil && r.err == nil {
+		if err := up.Enqueue(r.opts.Path); err != nil {
+			r.err = fmt.Errorf("media recorder: %w", err)
+		} else if r.opts.Index {
+			up.Enqueue(RecordingIndexPath(r.opts.Path))
+		}
+	}
+	return r.err
+}
+
+// Pause 暂停录制。设备保持打开，暂停期间的帧被丢弃，
+// 暂停的时长不出现在录制文件的时间轴中。
+// 对应 MDN 的 MediaRecorder.pause()。
+func (r *MediaRecorder) Pause() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.state {
+	case MediaRecorderStateInactive:
+		return errors.New("media recorder: not recording")
+	case MediaRecorderStateRecording:
+		r.clock.pause(time.Now())
+		r.state = MediaRecorderStatePaused
+	}
+	return nil
+}
+
+// Resume 恢复已暂停的录制。
+// 对应 MDN 的 MediaRecorder.resume()。
+func (r *MediaRecorder) Resume() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch r.state {
+	case MediaRecorderStateInactive:
+		return errors.New("media recorder: not recording")
+	case MediaRecorderStatePaused:
+		r.clock.resume(time.Now())
+		r.state = MediaRecorderStateRecording
+	}
+	return nil
+}
+
+// prune 按关联存储的策略清理旧录制。清理失败不影响录制本身，仅在 Config.Verbose 时记录。
+func (r *MediaRecorder) prune() {
+	if _, err := r.opts.Storage.Prune(); err != nil && r.owner.Config().Verbose {
+		log.Printf("ffmpeg: %v", err)
+	}
+}
+
+// closeOutput 结束加密并关闭由录制器打开的输出文件。
+func (r *MediaRecorder) closeOutput() error {
+	var err error
+	if r.enc != nil {
+		err = r.enc.Close()
+		r.enc = nil
+	}
+	if r.out != nil {
+		if cerr := r.out.Close(); err == nil {
+			err = cerr
+		}
+		r.out = nil
+	}
+	return err
+}
+
+// recordingFormat 返回输出路径对应的 FFmpeg 容器格式。
+func recordingFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".mp4":
+		return "mp4", nil
+	case ".mkv":
+		return "matroska", nil
+	case ".ts":
+		return "mpegts", nil
+	default:
+		return "", fmt.Errorf("media recorder: unsupported output format %q (want .mp4, .mkv or .ts)", ext)
+	}
+}
+
+// recorderAudio 描述录制的音频输入格式。
+type recorderAudio struct {
+	sampleRate int
+	channels   int
+}
+
+// recorderFrameRate 返回录制使用的视频帧率，未知时为 30。
+func recorderFrameRate(s MediaTrackSettings) float64 {
+	if s.FrameRate <= 0 {
+		return 30
+	}
+	return s.FrameRate
+}
+
+// buildRecorderArgs 构建录制用的 FFmpeg 参数：从 stdin 读取 YUV420p 原始帧，
+// audio 非 nil 时从 pipe:3 读取 S16LE 音频，编码为 H.264/AAC 后写入
+// output（文件路径或 pipe:1）。
+func buildRecorderArgs(s MediaTrackSettings, opts MediaRecorderOptions, output string, extra []string, audio *recorderAudio) ([]string, error) {
+	format, err := recordingFormat(opts.Path)
+	if err != nil {
+		return nil, err
+	}
+	frameRate := recorderFrameRate(s)
+	preset := opts.Preset
+	if preset == "" {
+		preset = "veryfast"
+	}
+
+	args := []string{
+		"-y",
+		"-f", "rawvideo",
+		"-pix_fmt", "yuv420p",
+		"-video_size", fmt.Sprintf("%dx%d", s.Width, s.Height),
+		"-framerate", fmt.Sprintf("%g", frameRate),
+	}
+	videoOffset, audioOffset := opts.VideoOffset, opts.AudioOffset
+	if audio == nil {
+		videoOffset, audioOffset = 0, 0
+	}
+	if shift := min(videoOffset, audioOffset); shift < 0 {
+		videoOffset -= shift
+		audioOffset -= shift
+	}
+	args = append(args, inputOffsetArgs(videoOffset)...)
+	args = append(args, "-i", "pipe:0")
+	if audio != nil {
+		args = append(args, inputOffsetArgs(audioOffset)...)
+		args = append(args,
+			"-f", "s16le",
+			"-ar", fmt.Sprintf("%d", audio.sampleRate),
+			"-ac", fmt.Sprintf("%d", audio.channels),
+			"-i", "pipe:3",
+			"-map", "0:v", "-map", "1:a",
+		)
+	}
+	args = append(args,
+		"-c:v", "libx264",
+		"-preset", preset,
+		"-pix_fmt", "yuv420p",
+	)
+	args = append(args, opts.Keyframes.args(frameRate, 0)...)
+	if opts.VideoBitRate > 0 {
+		args = append(args, "-b:v", fmt.Sprintf("%dk", opts.VideoBitRate))
+	}
+	if audio != nil {
+		bitRate := opts.AudioBitRate
+		if bitRate <= 0 {
+			bitRate = 128
+		}
+		args = append(args, "-c:a", "aac", "-b:a", fmt.Sprintf("%dk", bitRate))
+	}
+	args = append(args, extra...)
+	if format == "mp4" {
+		if opts.FastStart {
+			args = append(args, "-movflags", "+faststart")
+		} else {
+			// 分片 MP4：元数据随片段写出，中断的录制仍可播放；也支持写入管道
+			frag := opts.FragmentDuration
+			if frag <= 0 {
+				frag = defaultFragmentDuration
+			}
+			args = append(args,
+				"-movflags", "frag_keyframe+empty_moov+default_base_moof",
+				"-frag_duration", fmt.Sprintf("%d", frag.Microseconds()),
+			)
+		}
+	}
+	args = append(args, "-f", format, output)
+	return args, nil
+}
+
+// inputOffsetArgs 返回把下一个输入的时间戳偏移 d 的 FFmpeg 参数，d 为 0 时返回 nil。
+func inputOffsetArgs(d time.Duration) []string {
+	if d == 0 {
+		return nil
+	}
+	return []string{"-itsoffset", strconv.FormatFloat(d.Seconds(), 'f', -1, 64)}
+}
+
+// writeYCbCr 将 YUV420p 图像按平面顺序（Y、Cb、Cr）紧凑写入 w。
+func writeYCbCr(w io.Writer, img *image.YCbCr) error {
+	b := img.Rect
+	cw, ch := (b.Dx()+1)/2, (b.Dy()+1)/2
+	planes := []struct {
+		data         []byte
+		stride, w, h int
+		offset       int
+	}{
+		{img.Y, img.YStride, b.Dx(), b.Dy(), img.YOffset(b.Min.X, b.Min.Y)},
+		{img.Cb, img.CStride, cw, ch, img.COffset(b.Min.X, b.Min.Y)},
+		{img.Cr, img.CStride, cw, ch, img.COffset(b.Min.X, b.Min.Y)},
+	}
+	for _, p := range planes {
+		if p.stride == p.w {
+			if _, err := w.Write(p.data[p.offset : p.offset+p.w*p.h]); err != nil {
+				return err
+			}
+			continue
+		}
+		for y := 0; y < p.h; y++ {
+			row := p.offset + y*p.stride
+			if _, err := w.Write(p.data[row : row+p.w]); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
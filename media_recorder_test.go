@@ -0,0 +1,204 @@
+package mediadevices
+
+import (
+	"bytes"
+	"image"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBuildRecorderArgs(t *testing.T) {
+	s := MediaTrackSettings{Width: 640, Height: 480, FrameRate: 25}
+
+	args, err := buildRecorderArgs(s, MediaRecorderOptions{Path: "out.mp4", VideoBitRate: 1500}, "pipe:1", nil, nil)
+	if err != nil {
+		t.Fatalf("buildRecorderArgs: %v", err)
+	}
+	got := strings.Join(args, " ")
+	for _, want := range []string{
+		"-video_size 640x480 -framerate 25 -i pipe:0",
+		"-preset veryfast",
+		"-b:v 1500k",
+		"-movflags frag_keyframe+empty_moov+default_base_moof -frag_duration 1000000 -f mp4 pipe:1",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("args missing %q:\n%s", want, got)
+		}
+	}
+
+	args, err = buildRecorderArgs(s, MediaRecorderOptions{Path: "out.mp4", FastStart: true}, "out.mp4", nil, nil)
+	if err != nil {
+		t.Fatalf("buildRecorderArgs: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "-movflags +faststart -f mp4 out.mp4") || strings.Contains(got, "frag_") {
+		t.Errorf("faststart args = %s", got)
+	}
+
+	args, err = buildRecorderArgs(s, MediaRecorderOptions{Path: "out.mkv", AudioBitRate: 96}, "out.mkv", nil, &recorderAudio{sampleRate: 48000, channels: 2})
+	if err != nil {
+		t.Fatalf("buildRecorderArgs: %v", err)
+	}
+	got = strings.Join(args, " ")
+	for _, want := range []string{
+		"-i pipe:0 -f s16le -ar 48000 -ac 2 -i pipe:3 -map 0:v -map 1:a",
+		"-c:a aac -b:a 96k",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("audio args missing %q:\n%s", want, got)
+		}
+	}
+
+	args, err = buildRecorderArgs(s, MediaRecorderOptions{Path: "out.mkv", AudioOffset: -200 * time.Millisecond}, "out.mkv", nil, &recorderAudio{sampleRate: 48000, channels: 2})
+	if err != nil {
+		t.Fatalf("buildRecorderArgs: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "-itsoffset 0.2 -i pipe:0 -f s16le") || strings.Contains(got, "-itsoffset -") {
+		t.Errorf("offset args = %s", got)
+	}
+	args, err = buildRecorderArgs(s, MediaRecorderOptions{Path: "out.mkv", VideoOffset: 40 * time.Millisecond, AudioOffset: 100 * time.Millisecond}, "out.mkv", nil, &recorderAudio{sampleRate: 48000, channels: 2})
+	if err != nil {
+		t.Fatalf("buildRecorderArgs: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "-itsoffset 0.04 -i pipe:0 -itsoffset 0.1 -f s16le") {
+		t.Errorf("offset args = %s", got)
+	}
+
+	args, err = buildRecorderArgs(s, MediaRecorderOptions{Path: "out.ts", Keyframes: KeyframePolicy{Duration: 2 * time.Second, DisableSceneCut: true}}, "out.ts", nil, nil)
+	if err != nil {
+		t.Fatalf("buildRecorderArgs: %v", err)
+	}
+	if got := strings.Join(args, " "); !strings.Contains(got, "-g 50 -sc_threshold 0") {
+		t.Errorf("keyframe policy not applied: %s", got)
+	}
+
+	if _, err := buildRecorderArgs(s, MediaRecorderOptions{Path: "out.avi"}, "out.avi", nil, nil); err == nil {
+		t.Error("unsupported container accepted")
+	}
+}
+
+func TestNewMediaRecorder_Validation(t *testing.T) {
+	stream := NewMediaStream()
+	keys := StaticRecordingKey(make([]byte, 16), make([]byte, 16))
+
+	if _, err := NewMediaRecorder(stream, MediaRecorderOptions{Path: "out.mkv", Encryption: &RecordingEncryption{Scheme: EncryptionCENC, Keys: keys}}); err == nil {
+		t.Error("CENC accepted for Matroska output")
+	}
+	if _, err := NewMediaRecorder(stream, MediaRecorderOptions{Path: "out.mp4", FastStart: true, Index: true}); err == nil {
+		t.Error("faststart accepted together with Index")
+	}
+	if _, err := NewMediaRecorder(stream, MediaRecorderOptions{Path: "out.mp4", FastStart: true, Hash: true}); err == nil {
+		t.Error("faststart accepted together with Hash")
+	}
+	if _, err := NewMediaRecorder(stream, MediaRecorderOptions{Path: "out.mp4", Encryption: &RecordingEncryption{}}); err == nil {
+		t.Error("encryption without key provider accepted")
+	}
+	r, err := NewMediaRecorder(stream, MediaRecorderOptions{Path: "out.mp4"})
+	if err != nil {
+		t.Fatalf("NewMediaRecorder: %v", err)
+	}
+	if r.State() != MediaRecorderStateInactive {
+		t.Errorf("State = %q, want inactive", r.State())
+	}
+	if err := r.Start(); err == nil {
+		t.Error("Start succeeded on a stream without video tracks")
+	}
+}
+
+func TestWriteYCbCr_SubImage(t *testing.T) {
+	full := image.NewYCbCr(image.Rect(0, 0, 8, 8), image.YCbCrSubsampleRatio420)
+	for i := range full.Y {
+		full.Y[i] = byte(i)
+	}
+	sub := full.SubImage(image.Rect(2, 2, 6, 6)).(*image.YCbCr)
+
+	var buf bytes.Buffer
+	if err := writeYCbCr(&buf, sub); err != nil {
+		t.Fatalf("writeYCbCr: %v", err)
+	}
+	if buf.Len() != 4*4+2*2*2 {
+		t.Fatalf("wrote %d bytes, want %d", buf.Len(), 4*4+2*2*2)
+	}
+	if got := buf.Bytes()[:4]; !bytes.Equal(got, []byte{18, 19, 20, 21}) {
+		t.Errorf("first luma row = %v, want [18 19 20 21]", got)
+	}
+}
+
+func TestVideoFrameCopies(t *testing.T) {
+	for _, tt := range []struct {
+		written int64
+		now     time.Duration
+		want    int64
+	}{
+		{0, 0, 1},                      // first frame
+		{30, time.Second, 1},           // in sync
+		{32, time.Second, 1},           // within tolerance
+		{33, time.Second, 0},           // video ahead: drop
+		{27, time.Second, 1},           // within tolerance
+		{20, time.Second, 10},          // video behind: repeat
+		{300, 10*time.Second + 1e6, 1}, // long recording in sync
+		{290, 10 * time.Second, 10},    // camera slower than nominal rate
+	} {
+		if got := videoFrameCopies(tt.written, tt.now, 30); got != tt.want {
+			t.Errorf("videoFrameCopies(%d, %v) = %d, want %d", tt.written, tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestRecordingClock(t *testing.T) {
+	c := newRecordingClock(&recorderAudio{sampleRate: 48000, channels: 2})
+	start := c.start
+
+	c.advance(48000)
+	if got := c.mediaTime(start.Add(time.Second)); got != time.Second {
+		t.Errorf("mediaTime = %v, want audio time 1s", got)
+	}
+	if gap := c.audioGap(start.Add(time.Second+20*time.Millisecond), 960); gap != 0 {
+		t.Errorf("audioGap = %d for continuous audio, want 0", gap)
+	}
+
+	// The audio device stalls for 2s: video follows the wall clock and
+	// the gap is filled with silence before the next chunk.
+	now := start.Add(3 * time.Second)
+	if got := c.mediaTime(now); got != 3*time.Second-audioGapThreshold {
+		t.Errorf("mediaTime during stall = %v, want %v", got, 3*time.Second-audioGapThreshold)
+	}
+	if gap := c.audioGap(now, 960); gap != 48000*2-960 {
+		t.Errorf("audioGap = %d, want %d", gap, 48000*2-960)
+	}
+}
+
+func TestRecordingClock_PauseExcised(t *testing.T) {
+	c := newRecordingClock(&recorderAudio{sampleRate: 48000, channels: 1})
+	start := c.start
+	c.advance(48000)
+
+	c.pause(start.Add(time.Second))
+	if !c.paused() {
+		t.Fatal("paused() = false after pause")
+	}
+	c.resume(start.Add(11 * time.Second))
+
+	// Ten paused seconds are not part of the timeline: no silence is
+	// inserted and video does not catch up.
+	now := start.Add(11*time.Second + 20*time.Millisecond)
+	if gap := c.audioGap(now, 960); gap != 0 {
+		t.Errorf("audioGap after resume = %d, want 0", gap)
+	}
+	if got := c.mediaTime(now); got != time.Second {
+		t.Errorf("mediaTime after resume = %v, want 1s", got)
+	}
+}
+
+func TestMediaRecorder_PauseInactive(t *testing.T) {
+	r, err := NewMediaRecorder(NewMediaStream(), MediaRecorderOptions{Path: "out.mkv"})
+	if err != nil {
+		t.Fatalf("NewMediaRecorder: %v", err)
+	}
+	if err := r.Pause(); err == nil {
+		t.Error("Pause succeeded on an inactive recorder")
+	}
+	if err := r.Resume(); err == nil {
+		t.Error("Resume succeeded on an inactive recorder")
+	}
+}
@@ -24,10 +24,17 @@ type MediaDeviceInfo struct {
 
 	// DeviceName 是FFmpeg后端使用的原始设备名称。
 	// Windows (dshow): 设备名称字符串，如 "USB2.0 HD UVC WebCam"
-	// Linux: 设备路径，如 "/dev/video0" 或 ALSA ID 如 "hw:0,0"
+	// Linux: 设备路径，如 "/dev/video0"，ALSA ID 如 "hw:0,0"，
+	// 或 PulseAudio/PipeWire 音源，如 "pulse:alsa_input.usb-046d_0825-00.mono-fallback"
 	// macOS (avfoundation): 设备索引字符串，如 "0", "1"
 	DeviceName string
 
+	// AlternativeName 是 DirectShow 为设备报告的替代名称（设备路径），
+	// 如 "@device_pnp_\\?\usb#vid_046d&pid_0825&mi_00#..."，仅 Windows 上有值。
+	// 与友好名称不同，它在同型号的多个设备之间也是唯一的，
+	// 因此打开设备时优先于 DeviceName 使用。
+	AlternativeName string
+
 	// GroupID 是同属一个物理设备的组 ID。
 	// 相同物理设备的不同捕获点（如同一摄像头的不同焦距）会有相同的 GroupID。
 	GroupID string
@@ -41,12 +48,24 @@ type MediaDeviceInfo struct {
 
 	// IsDefault 表示该设备是否是系统默认设备。
 	IsDefault bool
+
+	// Loopback 表示该音频输入设备采集的是系统播放的声音（"what you hear"），
+	// 而不是麦克风：Linux 上为 PulseAudio/PipeWire 输出设备的 monitor 音源，
+	// Windows 上为 "Stereo Mix" 或 virtual-audio-capturer（WASAPI 回环）等设备，
+	// macOS 上为 BlackHole、Soundflower 等虚拟声卡。回环设备不会被选为默认音频输入。
+	Loopback bool
+
+	// FacingMode 是摄像头相对用户的朝向，仅视频输入设备有值，未知时为空。
+	// Android 上按 Camera2 的摄像头编号报告，macOS 上按 AVFoundation 摄像头的位置报告，
+	// 其他情况按标签推测（如 "Front Camera"、"Integrated Webcam"、"Rear Camera"）。
+	// Config.DiscoverDevices 可以直接设置。
+	FacingMode FacingMode
 }
 
 // ToJSON 将 MediaDeviceInfo 转换为 JSON 兼容的 map。
 // 适用于调试日志或与其他系统集成。
 func (m *MediaDeviceInfo) ToJSON() map[string]interface{} {
-	return map[string]interface{}{
+	v := map[string]interface{}{
 		"deviceId":   m.DeviceID,
 		"deviceName": m.DeviceName,
 		"groupId":    m.GroupID,
@@ -54,9 +73,50 @@ func (m *MediaDeviceInfo) ToJSON() map[string]interface{} {
 		"label":      m.Label,
 		"isDefault":  m.IsDefault,
 	}
+	if m.AlternativeName != "" {
+		v["alternativeName"] = m.AlternativeName
+	}
+	if m.Loopback {
+		v["loopback"] = true
+	}
+	if m.FacingMode != "" {
+		v["facingMode"] = string(m.FacingMode)
+	}
+	return v
 }
 
 // MarshalJSON 实现 json.Marshaler 接口。
 func (m *MediaDeviceInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(m.ToJSON())
 }
+
+// UnmarshalJSON 实现 json.Unmarshaler 接口，接受 MarshalJSON 输出的格式，
+// 使设备信息可以完整往返。
+func (m *MediaDeviceInfo) UnmarshalJSON(data []byte) error {
+	var v struct {
+		DeviceID        string `json:"deviceId"`
+		DeviceName      string `json:"deviceName"`
+		AlternativeName string `json:"alternativeName"`
+		GroupID         string `json:"groupId"`
+		Kind            string `json:"kind"`
+		Label           string `json:"label"`
+		IsDefault       bool   `json:"isDefault"`
+		Loopback        bool   `json:"loopback"`
+		FacingMode      string `json:"facingMode"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*m = MediaDeviceInfo{
+		DeviceID:        v.DeviceID,
+		DeviceName:      v.DeviceName,
+		AlternativeName: v.AlternativeName,
+		GroupID:         v.GroupID,
+		Kind:            MediaDeviceKind(v.Kind),
+		Label:           v.Label,
+		IsDefault:       v.IsDefault,
+		Loopback:        v.Loopback,
+		FacingMode:      FacingMode(v.FacingMode),
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Package mediadevicestest provides fakes for testing code built on
+// mediadevices without cameras, microphones or an FFmpeg installation:
+// fake devices, readers producing deterministic color bars and sine waves,
+// and an FFmpeg stub served by the test binary itself.
+//
+// To capture from the fake devices through GetUserMedia, run the stub from
+// TestMain and install the fakes in the test:
+//
+//	func TestMain(m *testing.M) {
+//		mediadevicestest.RunFFmpegStub()
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestPipeline(t *testing.T) {
+//		mediadevicestest.Install(t)
+//		stream, err := mediadevices.GetUserMedia(mediadevices.MediaTrackConstraints{...})
+//		...
+//	}
+package mediadevicestest
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	mediadevices "github.com/hypercamio/mediadevices-ffmpeg"
+)
+
+var (
+	// FakeCamera is the default fake video input. The stub captures color
+	// bars from it.
+	FakeCamera = mediadevices.MediaDeviceInfo{
+		DeviceID:  "fake-camera",
+		GroupID:   "fake-webcam",
+		Kind:      mediadevices.MediaDeviceKindVideoInput,
+		Label:     "Fake Camera",
+		IsDefault: true,
+	}
+
+	// FakeMicrophone is the default fake audio input, in the same group as
+	// FakeCamera. The stub captures a 440 Hz sine wave from it.
+	FakeMicrophone = mediadevices.MediaDeviceInfo{
+		DeviceID:  "fake-microphone",
+		GroupID:   "fake-webcam",
+		Kind:      mediadevices.MediaDeviceKindAudioInput,
+		Label:     "Fake Microphone",
+		IsDefault: true,
+	}
+)
+
+// Devices returns the default fake devices: FakeCamera and FakeMicrophone.
+func Devices() []mediadevices.MediaDeviceInfo {
+	return []mediadevices.MediaDeviceInfo{FakeCamera, FakeMicrophone}
+}
+
+// Install makes mediadevices enumerate the given devices (Devices() if none
+// are given) and run the FFmpeg stub instead of FFmpeg, until the test ends.
+// Captures from any device then produce color bars or a sine wave. The
+// test binary must call RunFFmpegStub from TestMain.
+//
+// Install changes the global configuration and the environment, so it
+// cannot be used in parallel tests.
+func Install(t testing.TB, devices ...mediadevices.MediaDeviceInfo) {
+	t.Helper()
+	if len(devices) == 0 {
+		devices = Devices()
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("mediadevicestest: locate test binary: %v", err)
+	}
+	t.Setenv(stubEnv, "1")
+
+	orig := mediadevices.GetConfig()
+	t.Cleanup(func() { mediadevices.SetConfig(orig) })
+
+	cfg := orig
+	cfg.FFmpegPath = exe
+	cfg.DiscoverDevices = func(context.Context) ([]mediadevices.MediaDeviceInfo, error) {
+		return slices.Clone(devices), nil
+	}
+	mediadevices.SetConfig(cfg)
+}
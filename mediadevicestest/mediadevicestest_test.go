@@ -0,0 +1,112 @@
+package mediadevicestest
+
+import (
+	"bytes"
+	"image"
+	"io"
+	"os"
+	"testing"
+
+	mediadevices "github.com/hypercamio/mediadevices-ffmpeg"
+)
+
+func TestMain(m *testing.M) {
+	RunFFmpegStub()
+	os.Exit(m.Run())
+}
+
+func TestColorBarsFrame(t *testing.T) {
+	a := ColorBarsFrame(64, 48, 3)
+	if !bytes.Equal(a.Y, ColorBarsFrame(64, 48, 3).Y) {
+		t.Error("frame not deterministic")
+	}
+	if bytes.Equal(a.Y, ColorBarsFrame(64, 48, 4).Y) {
+		t.Error("consecutive frames are identical")
+	}
+	if y := a.YCbCrAt(0, 0).Y; y != 235 {
+		t.Errorf("first bar Y = %d, want white", y)
+	}
+	if c := a.YCbCrAt(63, 0); c.Y != 16 || c.Cb != 128 {
+		t.Errorf("last bar = %v, want black", c)
+	}
+}
+
+func TestSineWave(t *testing.T) {
+	s := NewSineWave(1000, 48000, 2)
+	s.Limit = 2
+	c, err := s.Read()
+	if err != nil || c.SamplesPerChannel != 960 || len(c.Data) != 1920 {
+		t.Fatalf("chunk = %+v, %v", c, err)
+	}
+	// 48 samples per period: a quarter period in is the peak, on both channels.
+	if c.Data[24] != 16383 || c.Data[25] != 16383 {
+		t.Errorf("peak = %d, %d", c.Data[24], c.Data[25])
+	}
+	s.Read()
+	if _, err := s.Read(); err != io.EOF {
+		t.Errorf("read past Limit: %v", err)
+	}
+}
+
+func TestInstall_GetUserMedia(t *testing.T) {
+	Install(t)
+
+	devices, err := mediadevices.EnumerateDevices()
+	if err != nil || len(devices) != 2 || devices[0] != FakeCamera || devices[1] != FakeMicrophone {
+		t.Fatalf("EnumerateDevices = %+v, %v", devices, err)
+	}
+
+	stream, err := mediadevices.GetUserMedia(mediadevices.MediaTrackConstraints{
+		Video: &mediadevices.VideoTrackConstraints{Width: mediadevices.IdealInt(64), Height: mediadevices.IdealInt(48)},
+		Audio: &mediadevices.AudioTrackConstraints{SampleRate: mediadevices.IdealInt(8000), Channels: mediadevices.IdealInt(1)},
+	})
+	if err != nil {
+		t.Fatalf("GetUserMedia: %v", err)
+	}
+	defer stream.Close()
+
+	img, err := stream.GetVideoTracks()[0].Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if y := img.(*image.YCbCr).Y; !bytes.Equal(y, ColorBarsFrame(64, 48, 0).Y) {
+		t.Error("captured frame differs from ColorBarsFrame(64, 48, 0)")
+	}
+
+	chunk, err := stream.GetAudioTracks()[0].ReadAudio()
+	if err != nil {
+		t.Fatalf("ReadAudio: %v", err)
+	}
+	if chunk.SampleRate != 8000 || chunk.Channels != 1 || len(chunk.Data) == 0 {
+		t.Errorf("chunk = %d Hz, %d channels, %d samples", chunk.SampleRate, chunk.Channels, len(chunk.Data))
+	}
+}
+
+func TestInstall_SwitchDevice(t *testing.T) {
+	second := FakeCamera
+	second.DeviceID, second.Label, second.IsDefault = "fake-camera-2", "Fake Camera 2", false
+	Install(t, FakeCamera, second)
+
+	stream, err := mediadevices.GetUserMedia(mediadevices.MediaTrackConstraints{
+		Video: &mediadevices.VideoTrackConstraints{Width: mediadevices.IdealInt(64), Height: mediadevices.IdealInt(48)},
+	})
+	if err != nil {
+		t.Fatalf("GetUserMedia: %v", err)
+	}
+	defer stream.Close()
+	track := stream.GetVideoTracks()[0]
+	if _, err := track.Read(); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+
+	// The stub killed by the switch exits with a signal; that is not an error of the switch.
+	if err := track.SwitchDevice(second.DeviceID); err != nil {
+		t.Fatalf("SwitchDevice: %v", err)
+	}
+	if track.Label() != second.Label {
+		t.Errorf("label = %q, want %q", track.Label(), second.Label)
+	}
+	if _, err := track.Read(); err != nil {
+		t.Errorf("Read after the switch: %v", err)
+	}
+}
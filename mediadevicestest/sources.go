@@ -0,0 +1,174 @@
+package mediadevicestest
+
+import (
+	"image"
+	"io"
+	"math"
+
+	mediadevices "github.com/hypercamio/mediadevices-ffmpeg"
+)
+
+// barColors are the Y, Cb, Cr values (BT.601, limited range) of the eight
+// 100% color bars: white, yellow, cyan, green, magenta, red, blue, black.
+var barColors = [8][3]uint8{
+	{235, 128, 128},
+	{210, 16, 146},
+	{170, 166, 16},
+	{145, 54, 34},
+	{106, 202, 222},
+	{81, 90, 240},
+	{41, 240, 110},
+	{16, 128, 128},
+}
+
+// ColorBarsFrame returns frame n of the color bars test pattern as a
+// YUV420p image, like the frames of a capture. The top seven eighths hold
+// eight vertical bars; the bottom eighth is black with a white marker that
+// moves right with every frame, so that frames can be told apart.
+func ColorBarsFrame(width, height, n int) *image.YCbCr {
+	img := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	stripe := height - height/8
+	markerW := max(width/16, 1)
+	markerX := n * max(width/64, 1) % width
+
+	for y := 0; y < height; y++ {
+		row := img.Y[y*img.YStride : y*img.YStride+width]
+		for x := range row {
+			switch {
+			case y < stripe:
+				row[x] = barColors[x*8/width][0]
+			case x >= markerX && x < markerX+markerW:
+				row[x] = 235
+			default:
+				row[x] = 16
+			}
+		}
+	}
+
+	cw, ch := (width+1)/2, (height+1)/2
+	for cy := 0; cy < ch; cy++ {
+		for cx := 0; cx < cw; cx++ {
+			cb, cr := uint8(128), uint8(128)
+			if 2*cy < stripe {
+				bar := barColors[min(2*cx*8/width, 7)]
+				cb, cr = bar[1], bar[2]
+			}
+			img.Cb[cy*img.CStride+cx] = cb
+			img.Cr[cy*img.CStride+cx] = cr
+		}
+	}
+	return img
+}
+
+// ColorBars is a video source producing ColorBarsFrame frames, numbered
+// from 0. It has the methods of *mediadevices.VideoReader. Read does not
+// wait between frames.
+type ColorBars struct {
+	width, height int
+	fps           float64
+
+	// Limit, if positive, is the number of frames after which Read returns
+	// io.EOF.
+	Limit int
+
+	n      int
+	closed bool
+}
+
+// NewColorBars returns a color bars source of the given size.
+func NewColorBars(width, height int, fps float64) *ColorBars {
+	return &ColorBars{width: width, height: height, fps: fps}
+}
+
+// Read returns the next frame.
+func (c *ColorBars) Read() (image.Image, error) {
+	if c.closed || (c.Limit > 0 && c.n >= c.Limit) {
+		return nil, io.EOF
+	}
+	img := ColorBarsFrame(c.width, c.height, c.n)
+	c.n++
+	return img, nil
+}
+
+// Close makes later reads return io.EOF.
+func (c *ColorBars) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *ColorBars) Width() int         { return c.width }
+func (c *ColorBars) Height() int        { return c.height }
+func (c *ColorBars) FrameRate() float64 { return c.fps }
+
+// NewColorBarsTrack returns a live video track playing color bars at fps.
+func NewColorBarsTrack(width, height int, fps float64) (*mediadevices.MediaStreamTrack, error) {
+	src := NewColorBars(width, height, fps)
+	return mediadevices.NewVideoTrackFromFunc(func() image.Image {
+		img, _ := src.Read()
+		return img
+	}, fps)
+}
+
+// SineWave is an audio source producing a sine tone in 20 ms chunks, the
+// same wave on every channel. It has the methods of
+// *mediadevices.AudioReader. Read does not wait between chunks.
+type SineWave struct {
+	freq       float64
+	sampleRate int
+	channels   int
+
+	// Limit, if positive, is the number of chunks after which Read returns
+	// io.EOF.
+	Limit int
+
+	pos    int
+	chunks int
+	closed bool
+}
+
+// NewSineWave returns a sine wave source of frequency freq Hz at half of
+// full scale.
+func NewSineWave(freq float64, sampleRate, channels int) *SineWave {
+	return &SineWave{freq: freq, sampleRate: sampleRate, channels: channels}
+}
+
+// Read returns the next 20 ms chunk.
+func (s *SineWave) Read() (*mediadevices.AudioChunk, error) {
+	if s.closed || (s.Limit > 0 && s.chunks >= s.Limit) {
+		return nil, io.EOF
+	}
+	samples := max(s.sampleRate/50, 1)
+	data := make([]int16, samples*s.channels)
+	for i := 0; i < samples; i++ {
+		v := int16(math.Round(16383 * math.Sin(2*math.Pi*s.freq*float64(s.pos+i)/float64(s.sampleRate))))
+		for c := 0; c < s.channels; c++ {
+			data[i*s.channels+c] = v
+		}
+	}
+	s.pos += samples
+	s.chunks++
+	return &mediadevices.AudioChunk{
+		Data:              data,
+		Channels:          s.channels,
+		SampleRate:        s.sampleRate,
+		SamplesPerChannel: samples,
+	}, nil
+}
+
+// Close makes later reads return io.EOF.
+func (s *SineWave) Close() error {
+	s.closed = true
+	return nil
+}
+
+func (s *SineWave) SampleRate() int { return s.sampleRate }
+func (s *SineWave) Channels() int   { return s.channels }
+
+// NewSineWaveTrack returns a live audio track playing a sine tone.
+func NewSineWaveTrack(freq float64, sampleRate, channels int) (*mediadevices.MediaStreamTrack, error) {
+	src := NewSineWave(freq, sampleRate, channels)
+	return mediadevices.NewAudioTrackFromFunc(func() *mediadevices.AudioChunk {
+		chunk, _ := src.Read()
+		return chunk
+	}, sampleRate, channels)
+}
@@ -0,0 +1,128 @@
+package mediadevicestest
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stubEnv marks a process started as the FFmpeg stub.
+const stubEnv = "MEDIADEVICESTEST_FFMPEG_STUB"
+
+// RunFFmpegStub turns the current process into the FFmpeg stub if it was
+// started as one by a capture after Install, and returns immediately
+// otherwise. Call it at the start of TestMain, before m.Run.
+//
+// The stub understands raw captures only: "-f rawvideo" (yuv420p) output
+// gets color bars and "-f s16le" output a 440 Hz sine wave, paced at the
+// requested frame or sample rate until the reader closes the pipe. "-version"
+// prints a version banner. Any other command, such as an encoder, fails.
+func RunFFmpegStub() {
+	if os.Getenv(stubEnv) == "" {
+		return
+	}
+	os.Exit(runStub(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// runStub runs the stub with FFmpeg command-line arguments args and returns
+// the exit code.
+func runStub(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 1 && args[0] == "-version" {
+		fmt.Fprintln(stdout, "ffmpeg version 8.0-mediadevicestest Copyright (c) 2000-2025 the FFmpeg developers")
+		return 0
+	}
+
+	switch format := lastArg(args, "-f"); format {
+	case "rawvideo":
+		var w, h int
+		if _, err := fmt.Sscanf(lastArg(args, "-video_size"), "%dx%d", &w, &h); err != nil || w <= 0 || h <= 0 {
+			fmt.Fprintln(stderr, "mediadevicestest: rawvideo output needs -video_size")
+			return 1
+		}
+		if pix := lastArg(args, "-pix_fmt"); pix != "" && pix != "yuv420p" {
+			fmt.Fprintf(stderr, "mediadevicestest: unsupported pixel format %s\n", pix)
+			return 1
+		}
+		fps := 30.0
+		if r := firstArg(args, "-framerate", "-r"); r != "" {
+			if v, err := strconv.ParseFloat(r, 64); err == nil && v > 0 {
+				fps = v
+			}
+		}
+		stubVideo(stdout, w, h, fps)
+		return 0
+	case "s16le":
+		rate, channels := 48000, 2
+		if v, err := strconv.Atoi(lastArg(args, "-ar")); err == nil && v > 0 {
+			rate = v
+		}
+		if v, err := strconv.Atoi(lastArg(args, "-ac")); err == nil && v > 0 {
+			channels = v
+		}
+		stubAudio(stdout, rate, channels)
+		return 0
+	default:
+		fmt.Fprintf(stderr, "mediadevicestest: unsupported command: ffmpeg %s\n", strings.Join(args, " "))
+		return 1
+	}
+}
+
+// stubVideo writes color bars frames until w fails.
+func stubVideo(w io.Writer, width, height int, fps float64) {
+	tick := time.NewTicker(time.Duration(float64(time.Second) / fps))
+	defer tick.Stop()
+	for n := 0; ; n++ {
+		img := ColorBarsFrame(width, height, n)
+		for _, plane := range [][]byte{img.Y, img.Cb, img.Cr} {
+			if _, err := w.Write(plane); err != nil {
+				return
+			}
+		}
+		<-tick.C
+	}
+}
+
+// stubAudio writes a sine wave until w fails.
+func stubAudio(w io.Writer, sampleRate, channels int) {
+	src := NewSineWave(440, sampleRate, channels)
+	tick := time.NewTicker(20 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		chunk, _ := src.Read()
+		buf := make([]byte, 2*len(chunk.Data))
+		for i, v := range chunk.Data {
+			binary.LittleEndian.PutUint16(buf[2*i:], uint16(v))
+		}
+		if _, err := w.Write(buf); err != nil {
+			return
+		}
+		<-tick.C
+	}
+}
+
+// lastArg returns the value of the last occurrence of the option name.
+// Output options follow input options, so this is the output setting.
+func lastArg(args []string, name string) string {
+	for i := len(args) - 2; i >= 0; i-- {
+		if args[i] == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// firstArg returns the value of the first occurrence of any of the options.
+func firstArg(args []string, names ...string) string {
+	for i := 0; i < len(args)-1; i++ {
+		for _, name := range names {
+			if args[i] == name {
+				return args[i+1]
+			}
+		}
+	}
+	return ""
+}
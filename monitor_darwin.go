@@ -0,0 +1,62 @@
+//go:build darwin
+
+package mediadevices
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"strconv"
+	"time"
+)
+
+// enumerateMonitors lists the connected displays using system_profiler.
+// The main display comes first, matching the order of AVFoundation's
+// "Capture screen N" devices. Display positions are not reported, so
+// every monitor's bounds start at (0, 0). system_profiler is killed after
+// timeout.
+func enumerateMonitors(timeout time.Duration) ([]monitor, error) {
+	out, err := runDiscoveryTool(timeout, "system_profiler", "-json", "SPDisplaysDataType")
+	if err != nil {
+		return nil, fmt.Errorf("list monitors: %w", err)
+	}
+	return parseSystemProfilerDisplays(out)
+}
+
+// parseSystemProfilerDisplays parses `system_profiler -json SPDisplaysDataType` output.
+func parseSystemProfilerDisplays(out []byte) ([]monitor, error) {
+	var doc struct {
+		Adapters []struct {
+			Displays []struct {
+				Name       string `json:"_name"`
+				Pixels     string `json:"_spdisplays_pixels"`
+				Resolution string `json:"_spdisplays_resolution"`
+				Main       string `json:"spdisplays_main"`
+			} `json:"spdisplays_ndrvs"`
+		} `json:"SPDisplaysDataType"`
+	}
+	if err := json.Unmarshal(out, &doc); err != nil {
+		return nil, fmt.Errorf("list monitors: %w", err)
+	}
+
+	var monitors []monitor
+	for _, a := range doc.Adapters {
+		for _, d := range a.Displays {
+			// Retina displays capture at their pixel size, not the scaled resolution.
+			var w, h int
+			if _, err := fmt.Sscanf(d.Pixels, "%d x %d", &w, &h); err != nil {
+				fmt.Sscanf(d.Resolution, "%d x %d", &w, &h)
+			}
+			m := monitor{name: d.Name, bounds: image.Rect(0, 0, w, h), primary: d.Main == "spdisplays_yes"}
+			if m.primary {
+				monitors = append([]monitor{m}, monitors...)
+			} else {
+				monitors = append(monitors, m)
+			}
+		}
+	}
+	for i := range monitors {
+		monitors[i].screen = strconv.Itoa(i)
+	}
+	return monitors, nil
+}
@@ -0,0 +1,94 @@
+//go:build windows
+
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	enumDisplayMonitors          = user32.NewProc("EnumDisplayMonitors")
+	getMonitorInfoW              = user32.NewProc("GetMonitorInfoW")
+	enumDisplayDevicesW          = user32.NewProc("EnumDisplayDevicesW")
+	setThreadDpiAwarenessContext = user32.NewProc("SetThreadDpiAwarenessContext")
+)
+
+const monitorInfoPrimary = 0x1 // MONITORINFOF_PRIMARY
+
+// dpiAwarenessPerMonitorV2 is DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2.
+const dpiAwarenessPerMonitorV2 = ^uintptr(3)
+
+// monitorInfoEx mirrors MONITORINFOEXW.
+type monitorInfoEx struct {
+	Size    uint32
+	Monitor windows.Rect
+	Work    windows.Rect
+	Flags   uint32
+	Device  [32]uint16
+}
+
+// displayDevice mirrors DISPLAY_DEVICEW.
+type displayDevice struct {
+	Size         uint32
+	DeviceName   [32]uint16
+	DeviceString [128]uint16
+	StateFlags   uint32
+	DeviceID     [128]uint16
+	DeviceKey    [128]uint16
+}
+
+// enumerateMonitors lists the monitors making up the virtual desktop, with
+// bounds in physical pixels as gdigrab captures them.
+func enumerateMonitors(timeout time.Duration) ([]monitor, error) {
+	// Without per-monitor DPI awareness, scaled monitors report virtualized
+	// coordinates. The call is missing before Windows 10 1703, where bounds
+	// are only exact at 100% scaling.
+	if setThreadDpiAwarenessContext.Find() == nil {
+		prev, _, _ := setThreadDpiAwarenessContext.Call(dpiAwarenessPerMonitorV2)
+		if prev != 0 {
+			defer setThreadDpiAwarenessContext.Call(prev)
+		}
+	}
+
+	var monitors []monitor
+	cb := windows.NewCallback(func(hmon, hdc uintptr, rect *windows.Rect, _ uintptr) uintptr {
+		info := monitorInfoEx{Size: uint32(unsafe.Sizeof(monitorInfoEx{}))}
+		if ok, _, _ := getMonitorInfoW.Call(hmon, uintptr(unsafe.Pointer(&info))); ok == 0 {
+			return 1
+		}
+		device := windows.UTF16ToString(info.Device[:])
+		monitors = append(monitors, monitor{
+			name:    monitorName(device),
+			bounds:  image.Rect(int(info.Monitor.Left), int(info.Monitor.Top), int(info.Monitor.Right), int(info.Monitor.Bottom)),
+			primary: info.Flags&monitorInfoPrimary != 0,
+		})
+		return 1
+	})
+	if ok, _, err := enumDisplayMonitors.Call(0, 0, cb, 0); ok == 0 {
+		return nil, fmt.Errorf("enumerate monitors: %w", errnoErr(err))
+	}
+	return monitors, nil
+}
+
+// monitorName returns the model name of the monitor attached to the
+// display adapter output device (e.g. `\\.\DISPLAY1`), falling back to
+// the device name.
+func monitorName(device string) string {
+	name, err := windows.UTF16PtrFromString(device)
+	if err != nil {
+		return device
+	}
+	dd := displayDevice{Size: uint32(unsafe.Sizeof(displayDevice{}))}
+	if ok, _, _ := enumDisplayDevicesW.Call(uintptr(unsafe.Pointer(name)), 0, uintptr(unsafe.Pointer(&dd)), 0); ok == 0 {
+		return device
+	}
+	if s := windows.UTF16ToString(dd.DeviceString[:]); s != "" {
+		return s
+	}
+	return device
+}
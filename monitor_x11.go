@@ -0,0 +1,49 @@
+//go:build linux || freebsd || openbsd
+
+package mediadevices
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// xrandrMonitorRe matches lines from `xrandr --listmonitors` like:
+// " 1: +*HDMI-1 2560/597x1440/336+1920+0  HDMI-1"
+var xrandrMonitorRe = regexp.MustCompile(`^\s*\d+:\s+\+?(\*?)(\S+)\s+(\d+)/\d+x(\d+)/\d+\+(-?\d+)\+(-?\d+)`)
+
+// enumerateMonitors lists the monitors of the X11 screen using xrandr,
+// which is killed after timeout.
+func enumerateMonitors(timeout time.Duration) ([]monitor, error) {
+	out, err := runDiscoveryTool(timeout, "xrandr", "--listmonitors")
+	if err != nil {
+		return nil, fmt.Errorf("list monitors (is xrandr installed?): %w", err)
+	}
+	return parseXrandrMonitors(string(out)), nil
+}
+
+// parseXrandrMonitors parses `xrandr --listmonitors` output.
+func parseXrandrMonitors(out string) []monitor {
+	var monitors []monitor
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		m := xrandrMonitorRe.FindStringSubmatch(sc.Text())
+		if m == nil {
+			continue
+		}
+		w, _ := strconv.Atoi(m[3])
+		h, _ := strconv.Atoi(m[4])
+		x, _ := strconv.Atoi(m[5])
+		y, _ := strconv.Atoi(m[6])
+		monitors = append(monitors, monitor{
+			name:    m[2],
+			bounds:  image.Rect(x, y, x+w, y+h),
+			primary: m[1] == "*",
+		})
+	}
+	return monitors
+}
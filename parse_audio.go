@@ -3,6 +3,7 @@ package mediadevices
 import (
 	"encoding/binary"
 	"fmt"
+	"time"
 )
 
 // AudioChunk holds a chunk of interleaved PCM audio samples.
@@ -18,6 +19,16 @@ type AudioChunk struct {
 
 	// SamplesPerChannel is the number of samples per channel in this chunk.
 	SamplesPerChannel int
+
+	// Position is the index, per channel, of the first sample of this chunk
+	// among the samples the AudioReader has returned. The silence inserted
+	// when a stalled capture restarts is counted, so Position/SampleRate
+	// is the offset of the chunk on a timeline without jumps.
+	Position int64
+
+	// Timestamp is the wall-clock capture time of the first sample, set
+	// when Config.UseWallclockTimestamps is enabled and zero otherwise.
+	Timestamp time.Time
 }
 
 // parseS16LEChunk converts raw PCM S16LE interleaved bytes into an *AudioChunk.
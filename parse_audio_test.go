@@ -3,6 +3,7 @@ package mediadevices
 import (
 	"encoding/binary"
 	"testing"
+	"time"
 )
 
 func TestParseS16LEChunk_Stereo(t *testing.T) {
@@ -92,3 +93,22 @@ func TestParseS16LEChunk_Empty(t *testing.T) {
 		t.Errorf("expected 0 samplesPerChannel, got %d", chunk.SamplesPerChannel)
 	}
 }
+
+func TestAudioReaderStamp(t *testing.T) {
+	r := &AudioReader{sampleRate: 48000, wallclock: true}
+	t0 := time.Unix(1000, 0)
+
+	// The first chunk is anchored to its arrival time minus its duration.
+	if got := r.stamp(t0, 960); !got.Equal(t0.Add(-20 * time.Millisecond)) {
+		t.Errorf("first chunk = %v", got)
+	}
+	// Arrival jitter does not disturb the sample-count clock.
+	if got := r.stamp(t0.Add(25*time.Millisecond), 960); !got.Equal(t0) {
+		t.Errorf("second chunk = %v, want %v", got, t0)
+	}
+	// A gap longer than audioGapThreshold re-anchors.
+	late := t0.Add(2 * time.Second)
+	if got := r.stamp(late, 960); !got.Equal(late.Add(-20 * time.Millisecond)) {
+		t.Errorf("chunk after gap = %v", got)
+	}
+}
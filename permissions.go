@@ -0,0 +1,52 @@
+package mediadevices
+
+import "fmt"
+
+// PermissionState 表示媒体设备访问权限的状态。
+// 对应 MDN 的 PermissionStatus.state。
+type PermissionState string
+
+const (
+	// PermissionStateGranted 表示已授予访问权限。
+	PermissionStateGranted PermissionState = "granted"
+	// PermissionStateDenied 表示访问权限被操作系统拒绝。
+	PermissionStateDenied PermissionState = "denied"
+	// PermissionStatePrompt 表示尚未决定，首次捕获时系统可能会询问用户，
+	// 或者当前平台无法确定权限状态。
+	PermissionStatePrompt PermissionState = "prompt"
+)
+
+// QueryPermissions 查询当前进程访问指定类型设备的操作系统级权限。
+// 对应 MDN 的 navigator.permissions.query({name: "camera" | "microphone"})。
+//
+// 各平台的判断依据：
+//   - macOS: TCC 授权状态（AVCaptureDevice authorizationStatus）
+//   - Windows: 隐私设置（CapabilityAccessManager ConsentStore）
+//   - Linux: 当前用户对 /dev/video* 或 /dev/snd/pcm*c 设备节点的读写权限
+//   - Android: 运行时权限只能通过 Java API 查询，总是返回 PermissionStatePrompt
+//   - FreeBSD、OpenBSD: 设备节点的读写权限；OpenBSD 上还检查 kern.video.record 和 kern.audio.record
+//
+// 该函数不会触发系统授权弹窗，也不会打开设备。
+// kind 仅支持 MediaDeviceKindVideoInput 和 MediaDeviceKindAudioInput。
+func QueryPermissions(kind MediaDeviceKind) (PermissionState, error) {
+	switch kind {
+	case MediaDeviceKindVideoInput, MediaDeviceKindAudioInput:
+		return queryPermission(kind)
+	default:
+		return "", fmt.Errorf("query permissions: unsupported device kind %q", kind)
+	}
+}
+
+// nodePermission 将逐个设备节点的访问检查归纳为一个状态：任一节点可访问时为 granted，
+// 节点存在但都不可访问时为 denied，没有可检查的节点时为 prompt。
+func nodePermission(nodes []string, accessible func(string) bool) PermissionState {
+	if len(nodes) == 0 {
+		return PermissionStatePrompt
+	}
+	for _, n := range nodes {
+		if accessible(n) {
+			return PermissionStateGranted
+		}
+	}
+	return PermissionStateDenied
+}
@@ -0,0 +1,24 @@
+//go:build android
+
+package mediadevices
+
+// queryPermission reports prompt on Android. Camera and microphone access is
+// governed by the app's CAMERA and RECORD_AUDIO runtime permissions, which
+// can only be checked and requested through the Java API; the device nodes
+// of the Linux kernel are never accessible to apps.
+func queryPermission(kind MediaDeviceKind) (PermissionState, error) {
+	return PermissionStatePrompt, nil
+}
+
+// probeDeviceAccess leaves access to FFmpeg on Android: cameras and
+// microphones are opened through system services, so there is no node to
+// check. A denied runtime permission or a camera held by another app is
+// classified from FFmpeg's stderr.
+func probeDeviceAccess(kind MediaDeviceKind, name string) error {
+	return nil
+}
+
+// deviceHolderHint returns "": apps cannot see which app holds a camera.
+func deviceHolderHint(kind MediaDeviceKind, name string) string {
+	return ""
+}
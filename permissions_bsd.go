@@ -0,0 +1,81 @@
+//go:build freebsd || openbsd
+
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// queryPermission checks read/write access to the device nodes of the given
+// kind, as on Linux. On OpenBSD, recording is also off unless the
+// kern.video.record or kern.audio.record sysctl enables it, and audio goes
+// through sndiod, so only the sysctl decides.
+func queryPermission(kind MediaDeviceKind) (PermissionState, error) {
+	if recordingDisabled(kind) {
+		return PermissionStateDenied, nil
+	}
+	pattern := "/dev/video[0-9]*"
+	if kind == MediaDeviceKindAudioInput {
+		if audioNodePattern == "" {
+			return PermissionStateGranted, nil
+		}
+		pattern = audioNodePattern
+	}
+	nodes, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	return nodePermission(nodes, func(path string) bool {
+		return unix.Access(path, unix.R_OK|unix.W_OK) == nil
+	}), nil
+}
+
+// recordingDisabled reports whether the OpenBSD sysctl kern.video.record
+// or kern.audio.record turns recording of the kind off, in which case the
+// devices open but deliver black frames or silence. The sysctls do not
+// exist on FreeBSD.
+func recordingDisabled(kind MediaDeviceKind) bool {
+	v, err := unix.SysctlUint32(recordSysctl(kind))
+	return err == nil && v == 0
+}
+
+// recordSysctl returns the name of the OpenBSD sysctl that enables
+// recording of the kind.
+func recordSysctl(kind MediaDeviceKind) string {
+	if kind == MediaDeviceKindAudioInput {
+		return "kern.audio.record"
+	}
+	return "kern.video.record"
+}
+
+// probeDeviceAccess checks the device node of a video device or OSS device
+// before FFmpeg opens it, and on OpenBSD whether recording is enabled.
+// sndio devices are left to FFmpeg. Busy devices are reported by FFmpeg,
+// as the BSDs have no /proc to find the process holding them.
+func probeDeviceAccess(kind MediaDeviceKind, name string) error {
+	if recordingDisabled(kind) {
+		return deviceAccessError(CausePermissionDenied, name,
+			fmt.Sprintf("recording is disabled; enable it with 'sysctl %s=1'", recordSysctl(kind)))
+	}
+	if !strings.HasPrefix(name, "/dev/") {
+		return nil
+	}
+	if err := unix.Access(name, unix.R_OK|unix.W_OK); err != nil {
+		if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPERM) {
+			return deviceAccessError(CausePermissionDenied, name,
+				fmt.Sprintf("no read/write access to %s; check its owner and group with devfs.rules or chown", name))
+		}
+	}
+	return nil
+}
+
+// deviceHolderHint returns "": the BSDs have no /proc to find the process
+// holding a device.
+func deviceHolderHint(kind MediaDeviceKind, name string) string {
+	return ""
+}
@@ -0,0 +1,95 @@
+//go:build darwin
+
+package mediadevices
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tccStatusScript queries AVCaptureDevice authorizationStatusForMediaType
+// through the JavaScript for Automation ObjC bridge, avoiding cgo. TCC
+// attributes the query to the responsible process, which is the same one
+// that owns our FFmpeg children.
+const tccStatusScript = `ObjC.import('AVFoundation'); $.AVCaptureDevice.authorizationStatusForMediaType(%s)`
+
+// queryPermission reports the TCC authorization status for the camera or
+// microphone. If the status cannot be queried, it reports prompt.
+func queryPermission(kind MediaDeviceKind) (PermissionState, error) {
+	mediaType := "$.AVMediaTypeVideo"
+	if kind == MediaDeviceKindAudioInput {
+		mediaType = "$.AVMediaTypeAudio"
+	}
+	script := fmt.Sprintf(tccStatusScript, mediaType)
+	out, err := exec.Command("osascript", "-l", "JavaScript", "-e", script).Output()
+	if err != nil {
+		return PermissionStatePrompt, nil
+	}
+	return parseTCCStatus(strings.TrimSpace(string(out))), nil
+}
+
+// parseTCCStatus maps AVAuthorizationStatus values to a PermissionState:
+// 0 notDetermined, 1 restricted, 2 denied, 3 authorized.
+func parseTCCStatus(s string) PermissionState {
+	switch s {
+	case "3":
+		return PermissionStateGranted
+	case "1", "2":
+		return PermissionStateDenied
+	default:
+		return PermissionStatePrompt
+	}
+}
+
+// tccCacheTTL is how long probeDeviceAccess reuses a TCC status, so that
+// devices opened in a row, as by SwitchDevice or stall restarts, run
+// osascript once.
+const tccCacheTTL = 30 * time.Second
+
+// tccStatus is a TCC status queried at.
+type tccStatus struct {
+	state PermissionState
+	at    time.Time
+}
+
+var (
+	tccCacheMu sync.Mutex
+	tccCache   = map[MediaDeviceKind]tccStatus{}
+)
+
+// cachedPermission is queryPermission with the result kept for tccCacheTTL.
+func cachedPermission(kind MediaDeviceKind) PermissionState {
+	tccCacheMu.Lock()
+	defer tccCacheMu.Unlock()
+	if s, ok := tccCache[kind]; ok && time.Since(s.at) < tccCacheTTL {
+		return s.state
+	}
+	state, _ := queryPermission(kind)
+	tccCache[kind] = tccStatus{state: state, at: time.Now()}
+	return state
+}
+
+// probeDeviceAccess reports access as denied before FFmpeg starts if TCC
+// denies this process the camera or microphone; AVFoundation would
+// otherwise fail to open the device with no reason given. Access that has
+// not been decided yet is left to FFmpeg, which triggers the consent
+// prompt. macOS devices can be shared, so they are never busy.
+func probeDeviceAccess(kind MediaDeviceKind, name string) error {
+	if cachedPermission(kind) == PermissionStateDenied {
+		setting := "Camera"
+		if kind == MediaDeviceKindAudioInput {
+			setting = "Microphone"
+		}
+		return deviceAccessError(CausePermissionDenied, name,
+			fmt.Sprintf("access was denied in System Settings > Privacy & Security > %s; allow the application running this program", setting))
+	}
+	return nil
+}
+
+// deviceHolderHint returns "": macOS devices can be shared.
+func deviceHolderHint(kind MediaDeviceKind, name string) string {
+	return ""
+}
@@ -0,0 +1,131 @@
+//go:build linux && !android
+
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// alsaHardwareRe matches the ALSA hardware devices opened directly on a
+// card's PCM node: "hw:1", "hw:1,0" and the same with "plughw:".
+var alsaHardwareRe = regexp.MustCompile(`^(?:plug)?hw:(\d+)(?:,(\d+))?$`)
+
+// queryPermission checks read/write access to the device nodes of the given
+// kind. Linux has no consent prompt: access is governed by node ownership and
+// group membership (usually "video" and "audio").
+func queryPermission(kind MediaDeviceKind) (PermissionState, error) {
+	pattern := "/dev/video*"
+	if kind == MediaDeviceKindAudioInput {
+		// ALSA capture PCM nodes, e.g. /dev/snd/pcmC0D0c.
+		pattern = "/dev/snd/pcmC*D*c"
+	}
+	nodes, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", err
+	}
+	return nodePermission(nodes, func(path string) bool {
+		return unix.Access(path, unix.R_OK|unix.W_OK) == nil
+	}), nil
+}
+
+// probeDeviceAccess checks the device node behind a V4L2 device
+// ("/dev/video0") or an ALSA hardware device ("hw:1,0") before FFmpeg opens
+// it: the node must be readable and writable. Other names, such as the
+// sources of the sound server, and nodes that do not exist are left to
+// FFmpeg, as are busy devices; see deviceHolderHint.
+func probeDeviceAccess(kind MediaDeviceKind, name string) error {
+	node := captureNode(kind, name)
+	if node == "" {
+		return nil
+	}
+	err := unix.Access(node, unix.R_OK|unix.W_OK)
+	if errors.Is(err, unix.EACCES) || errors.Is(err, unix.EPERM) {
+		return deviceAccessError(CausePermissionDenied, name,
+			fmt.Sprintf("no read/write access to %s; add the user to the group owning it (usually 'video' or 'audio')", node))
+	}
+	return nil
+}
+
+// deviceHolderHint names the process holding the device node behind name
+// open, as fuser would report, or returns "" if there is none. It scans the
+// descriptors of every process, so it only runs once FFmpeg has reported
+// the device busy.
+func deviceHolderHint(kind MediaDeviceKind, name string) string {
+	return nodeHolderHint("/proc", captureNode(kind, name))
+}
+
+// nodeHolderHint is deviceHolderHint for node, with processes under proc.
+func nodeHolderHint(proc, node string) string {
+	if node == "" {
+		return ""
+	}
+	pid, command := nodeHolder(proc, node)
+	if pid == 0 {
+		return ""
+	}
+	return fmt.Sprintf("%s is held open by process %d (%s); close it and retry", node, pid, command)
+}
+
+// captureNode returns the device node FFmpeg opens for the device name, or
+// "" if it does not open one directly. V4L2 names may be symlinks such as
+// /dev/v4l/by-id/..., which are resolved. ALSA capture nodes are named
+// /dev/snd/pcmC<card>D<device>c.
+func captureNode(kind MediaDeviceKind, name string) string {
+	if kind == MediaDeviceKindVideoInput {
+		if !strings.HasPrefix(name, "/dev/") {
+			return ""
+		}
+		node, err := filepath.EvalSymlinks(name)
+		if err != nil {
+			return ""
+		}
+		return node
+	}
+	m := alsaHardwareRe.FindStringSubmatch(name)
+	if m == nil {
+		return ""
+	}
+	device := m[2]
+	if device == "" {
+		device = "0"
+	}
+	return fmt.Sprintf("/dev/snd/pcmC%sD%sc", m[1], device)
+}
+
+// nodeHolder scans the open file descriptors of the processes under proc
+// (normally /proc) for node and returns the pid and command name of the
+// first other process holding it, or 0 if there is none. Processes of other
+// users cannot be inspected without privileges and are skipped.
+func nodeHolder(proc, node string) (int, string) {
+	entries, err := os.ReadDir(proc)
+	if err != nil {
+		return 0, ""
+	}
+	self := os.Getpid()
+	for _, e := range entries {
+		pid, err := strconv.Atoi(e.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		dir := filepath.Join(proc, e.Name())
+		fds, err := os.ReadDir(filepath.Join(dir, "fd"))
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			if target, err := os.Readlink(filepath.Join(dir, "fd", fd.Name())); err == nil && target == node {
+				comm, _ := os.ReadFile(filepath.Join(dir, "comm"))
+				return pid, strings.TrimSpace(string(comm))
+			}
+		}
+	}
+	return 0, ""
+}
@@ -0,0 +1,110 @@
+//go:build linux && !android
+
+package mediadevices
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestNodePermission(t *testing.T) {
+	allow := map[string]bool{"/dev/video2": true}
+	accessible := func(p string) bool { return allow[p] }
+
+	tests := []struct {
+		name  string
+		nodes []string
+		want  PermissionState
+	}{
+		{"no nodes", nil, PermissionStatePrompt},
+		{"none accessible", []string{"/dev/video0", "/dev/video1"}, PermissionStateDenied},
+		{"one accessible", []string{"/dev/video0", "/dev/video2"}, PermissionStateGranted},
+	}
+	for _, tt := range tests {
+		if got := nodePermission(tt.nodes, accessible); got != tt.want {
+			t.Errorf("%s: nodePermission() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestQueryPermissions_UnsupportedKind(t *testing.T) {
+	if _, err := QueryPermissions(MediaDeviceKindAudioOutput); err == nil {
+		t.Error("expected error for audiooutput kind")
+	}
+}
+
+func TestCaptureNode(t *testing.T) {
+	tests := []struct {
+		kind MediaDeviceKind
+		name string
+		want string
+	}{
+		{MediaDeviceKindAudioInput, "hw:1,2", "/dev/snd/pcmC1D2c"},
+		{MediaDeviceKindAudioInput, "hw:0", "/dev/snd/pcmC0D0c"},
+		{MediaDeviceKindAudioInput, "plughw:3,0", "/dev/snd/pcmC3D0c"},
+		{MediaDeviceKindAudioInput, "hw:CARD=PCH,DEV=0", ""},
+		{MediaDeviceKindAudioInput, "pulse:alsa_input.usb-046d_0825-00.mono-fallback", ""},
+		{MediaDeviceKindAudioInput, "default", ""},
+		{MediaDeviceKindVideoInput, "/dev/video-does-not-exist", ""},
+		{MediaDeviceKindVideoInput, "USB Camera", ""},
+	}
+	for _, tt := range tests {
+		if got := captureNode(tt.kind, tt.name); got != tt.want {
+			t.Errorf("captureNode(%s, %q) = %q, want %q", tt.kind, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNodeHolder(t *testing.T) {
+	proc := t.TempDir()
+	process := func(pid int, comm string, targets ...string) {
+		dir := filepath.Join(proc, strconv.Itoa(pid))
+		if err := os.MkdirAll(filepath.Join(dir, "fd"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "comm"), []byte(comm+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		for i, target := range targets {
+			if err := os.Symlink(target, filepath.Join(dir, "fd", strconv.Itoa(i))); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	process(os.Getpid(), "self", "/dev/video2")
+	process(100, "bash", "/dev/pts/0", "/dev/null")
+	process(200, "cheese", "/dev/null", "/dev/video0")
+	if err := os.MkdirAll(filepath.Join(proc, "sys"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if pid, comm := nodeHolder(proc, "/dev/video0"); pid != 200 || comm != "cheese" {
+		t.Errorf("nodeHolder(/dev/video0) = %d, %q, want 200, \"cheese\"", pid, comm)
+	}
+	if pid, _ := nodeHolder(proc, "/dev/video2"); pid != 0 {
+		t.Errorf("nodeHolder(/dev/video2) = %d, want 0: our own descriptors do not count", pid)
+	}
+	if pid, _ := nodeHolder(proc, "/dev/video1"); pid != 0 {
+		t.Errorf("nodeHolder(/dev/video1) = %d, want 0", pid)
+	}
+
+	want := "/dev/video0 is held open by process 200 (cheese); close it and retry"
+	if got := nodeHolderHint(proc, "/dev/video0"); got != want {
+		t.Errorf("nodeHolderHint(/dev/video0) = %q, want %q", got, want)
+	}
+	for _, node := range []string{"", "/dev/video1"} {
+		if got := nodeHolderHint(proc, node); got != "" {
+			t.Errorf("nodeHolderHint(%q) = %q, want \"\"", node, got)
+		}
+	}
+}
+
+func TestCheckDeviceAccess_SkipsPortableDevices(t *testing.T) {
+	for _, name := range []string{"", "lavfi:testsrc2", "virtual:/tmp/clip.mp4", "decklink:DeckLink Mini Recorder", "display:0"} {
+		if err := checkDeviceAccess(MediaDeviceKindVideoInput, name); err != nil {
+			t.Errorf("checkDeviceAccess(%q) = %v, want nil", name, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+//go:build windows
+
+package mediadevices
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows/registry"
+)
+
+// consentStoreKey is the registry location of the Windows privacy settings
+// for camera ("webcam") and microphone access.
+const consentStoreKey = `Software\Microsoft\Windows\CurrentVersion\CapabilityAccessManager\ConsentStore\`
+
+// queryPermission reads the Windows privacy settings. Access is denied if the
+// capability is switched off machine-wide, for the current user, or for
+// desktop (non-packaged) apps. Desktop apps are never prompted, so missing
+// settings mean access is granted.
+func queryPermission(kind MediaDeviceKind) (PermissionState, error) {
+	capability := "webcam"
+	if kind == MediaDeviceKindAudioInput {
+		capability = "microphone"
+	}
+	path := consentStoreKey + capability
+
+	checks := []struct {
+		root registry.Key
+		path string
+	}{
+		{registry.LOCAL_MACHINE, path},
+		{registry.CURRENT_USER, path},
+		{registry.CURRENT_USER, path + `\NonPackaged`},
+	}
+	for _, c := range checks {
+		if consentValue(c.root, c.path) == "Deny" {
+			return PermissionStateDenied, nil
+		}
+	}
+	return PermissionStateGranted, nil
+}
+
+// consentValue returns the "Value" string of a ConsentStore key, or "" if the
+// key or value does not exist.
+func consentValue(root registry.Key, path string) string {
+	k, err := registry.OpenKey(root, path, registry.QUERY_VALUE)
+	if err != nil {
+		return ""
+	}
+	defer k.Close()
+	v, _, err := k.GetStringValue("Value")
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// probeDeviceAccess reports access as denied before FFmpeg starts if the
+// Windows privacy settings switch off the camera or microphone; DirectShow
+// would otherwise fail with an unspecific "Could not run graph". Whether
+// another application uses the device cannot be told without opening it,
+// so busy devices are classified from FFmpeg's stderr.
+func probeDeviceAccess(kind MediaDeviceKind, name string) error {
+	if state, _ := queryPermission(kind); state == PermissionStateDenied {
+		setting := "Camera"
+		if kind == MediaDeviceKindAudioInput {
+			setting = "Microphone"
+		}
+		return deviceAccessError(CausePermissionDenied, name,
+			fmt.Sprintf("access is turned off in Settings > Privacy & security > %s; allow desktop apps to use it", setting))
+	}
+	return nil
+}
+
+// deviceHolderHint returns "": Windows does not tell which application
+// uses a camera or microphone.
+func deviceHolderHint(kind MediaDeviceKind, name string) string {
+	return ""
+}
@@ -0,0 +1,244 @@
+package mediadevices
+
+import (
+	"errors"
+	"io"
+	"sync"
+)
+
+// pipeSinkQueue is the number of buffers a sink may fall behind before it
+// is considered too slow and detached.
+const pipeSinkQueue = 256
+
+// ErrSinkTooSlow is reported for a pipeline sink that fell too far behind
+// the encoder and was detached so that it would not stall the others.
+var ErrSinkTooSlow = errors.New("pipe: sink too slow")
+
+// SinkError records the failure of one pipeline sink.
+type SinkError struct {
+	Writer io.Writer
+	Err    error
+}
+
+func (e SinkError) Error() string {
+	return e.Err.Error()
+}
+
+func (e SinkError) Unwrap() error {
+	return e.Err
+}
+
+// Pipeline copies an encoded stream to a set of io.Writers. Each sink is
+// written from its own goroutine through a bounded queue, so a sink that
+// fails or blocks is detached without affecting the others or the encoder.
+// Sinks are not closed by the pipeline.
+type Pipeline struct {
+	read  func() ([]byte, error)
+	close func() error
+
+	mu      sync.Mutex
+	sinks   []*pipeSink
+	errs    []SinkError
+	onError func(SinkError)
+	closed  bool
+	readErr error
+
+	done chan struct{}
+}
+
+type pipeSink struct {
+	w     io.Writer
+	queue chan []byte
+	done  chan struct{}
+}
+
+// Pipe starts writing the stream to the given writers, one Annex B NAL unit
+// (start code included) per Write, until the stream ends or the pipeline is
+// closed. The pipeline takes over the read loop: do not call Read while it
+// runs.
+func (r *H264VideoReader) Pipe(to ...io.Writer) *Pipeline {
+	return newPipeline(func() ([]byte, error) {
+		nal, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+		buf := make([]byte, 0, len(annexBStartCode)+len(nal.Data))
+		return append(append(buf, annexBStartCode...), nal.Data...), nil
+	}, r.Close, to)
+}
+
+// Pipe starts writing the stream to the given writers, one marshaled RTP
+// packet per Write, so that datagram sinks such as a *net.UDPConn receive
+// one packet per datagram. The pipeline takes over the read loop: do not
+// call Read while it runs.
+func (r *RTPReader) Pipe(to ...io.Writer) *Pipeline {
+	return newPipeline(func() ([]byte, error) {
+		pkt, err := r.Read()
+		if err != nil {
+			return nil, err
+		}
+		return pkt.Marshal()
+	}, r.Close, to)
+}
+
+func newPipeline(read func() ([]byte, error), close func() error, to []io.Writer) *Pipeline {
+	p := &Pipeline{read: read, close: close, done: make(chan struct{})}
+	for _, w := range to {
+		p.Add(w)
+	}
+	go p.run()
+	return p
+}
+
+// Add attaches another sink. It receives the stream from the next buffer
+// on; for H.264 it should wait for the next keyframe before decoding.
+func (p *Pipeline) Add(w io.Writer) {
+	s := &pipeSink{w: w, queue: make(chan []byte, pipeSinkQueue), done: make(chan struct{})}
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		close(s.queue)
+		close(s.done)
+		return
+	}
+	p.sinks = append(p.sinks, s)
+	p.mu.Unlock()
+	go p.drain(s)
+}
+
+// Remove detaches a sink. Buffers already queued for it are still written.
+func (p *Pipeline) Remove(w io.Writer) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, s := range p.sinks {
+		if s.w == w {
+			p.sinks = append(p.sinks[:i], p.sinks[i+1:]...)
+			close(s.queue)
+			return
+		}
+	}
+}
+
+// OnSinkError sets a callback invoked whenever a sink is detached because
+// of an error. It is called on a new goroutine and may run after Wait
+// has returned.
+func (p *Pipeline) OnSinkError(fn func(SinkError)) {
+	p.mu.Lock()
+	p.onError = fn
+	p.mu.Unlock()
+}
+
+// Errors returns the errors of all sinks detached so far.
+func (p *Pipeline) Errors() []SinkError {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]SinkError(nil), p.errs...)
+}
+
+// Sinks returns the number of attached sinks.
+func (p *Pipeline) Sinks() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.sinks)
+}
+
+// Wait blocks until the stream has ended and every sink has written its
+// queued buffers. It returns the error that ended the stream, or nil if it
+// ended normally (io.EOF or Close).
+func (p *Pipeline) Wait() error {
+	<-p.done
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.readErr
+}
+
+// Close stops the pipeline, closes the underlying reader and waits for the
+// sinks to finish.
+func (p *Pipeline) Close() error {
+	p.mu.Lock()
+	already := p.closed
+	p.closed = true
+	p.mu.Unlock()
+	var err error
+	if !already {
+		err = p.close()
+	}
+	<-p.done
+	return err
+}
+
+func (p *Pipeline) run() {
+	defer close(p.done)
+	for {
+		buf, err := p.read()
+		if err != nil {
+			p.mu.Lock()
+			if err != io.EOF && !p.closed {
+				p.readErr = err
+			}
+			p.closed = true
+			sinks := p.sinks
+			p.sinks = nil
+			p.mu.Unlock()
+			for _, s := range sinks {
+				close(s.queue)
+			}
+			for _, s := range sinks {
+				<-s.done
+			}
+			return
+		}
+
+		p.mu.Lock()
+		for i := 0; i < len(p.sinks); i++ {
+			s := p.sinks[i]
+			select {
+			case s.queue <- buf:
+			default:
+				p.sinks = append(p.sinks[:i], p.sinks[i+1:]...)
+				i--
+				close(s.queue)
+				p.fail(s, ErrSinkTooSlow)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// drain writes queued buffers to the sink until its queue is closed or a
+// write fails.
+func (p *Pipeline) drain(s *pipeSink) {
+	defer close(s.done)
+	for buf := range s.queue {
+		if _, err := s.w.Write(buf); err != nil {
+			p.mu.Lock()
+			p.detach(s)
+			p.fail(s, err)
+			p.mu.Unlock()
+			// Discard the rest so the reader never blocks on this sink.
+			for range s.queue {
+			}
+			return
+		}
+	}
+}
+
+// detach removes s from the sink list; p.mu must be held.
+func (p *Pipeline) detach(s *pipeSink) {
+	for i, t := range p.sinks {
+		if t == s {
+			p.sinks = append(p.sinks[:i], p.sinks[i+1:]...)
+			close(s.queue)
+			return
+		}
+	}
+}
+
+// fail records a sink error and notifies the callback; p.mu must be held.
+func (p *Pipeline) fail(s *pipeSink, err error) {
+	e := SinkError{Writer: s.w, Err: err}
+	p.errs = append(p.errs, e)
+	if fn := p.onError; fn != nil {
+		go fn(e)
+	}
+}
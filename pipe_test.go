@@ -0,0 +1,116 @@
+package mediadevices
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+type failingWriter struct{ after int }
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if w.after == 0 {
+		return 0, errors.New("connection closed")
+	}
+	w.after--
+	return len(p), nil
+}
+
+// sliceSource returns a pipeline read function yielding bufs, then err.
+func sliceSource(bufs []string, err error) func() ([]byte, error) {
+	var mu sync.Mutex
+	return func() ([]byte, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(bufs) == 0 {
+			return nil, err
+		}
+		b := bufs[0]
+		bufs = bufs[1:]
+		return []byte(b), nil
+	}
+}
+
+func TestPipeline_SinkIsolation(t *testing.T) {
+	var bufs []string
+	for i := 0; i < 10; i++ {
+		bufs = append(bufs, fmt.Sprint(i))
+	}
+	good := &lockedBuffer{}
+	bad := &failingWriter{after: 3}
+	p := newPipeline(sliceSource(bufs, io.EOF), func() error { return nil }, []io.Writer{good, bad})
+
+	if err := p.Wait(); err != nil {
+		t.Fatalf("Wait = %v, want nil at EOF", err)
+	}
+	if got := good.String(); got != "0123456789" {
+		t.Errorf("good sink got %q, want all buffers", got)
+	}
+	errs := p.Errors()
+	if len(errs) != 1 || errs[0].Writer != bad {
+		t.Fatalf("Errors = %v, want one error for the failing sink", errs)
+	}
+}
+
+type blockingWriter struct{ release chan struct{} }
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestPipeline_SlowSinkDetached(t *testing.T) {
+	n := pipeSinkQueue + 10
+	good := &lockedBuffer{}
+	slow := &blockingWriter{release: make(chan struct{})}
+	defer close(slow.release)
+
+	// Produce a buffer only once the good sink has written the previous
+	// one, so only the blocked sink falls behind.
+	i := 0
+	read := func() ([]byte, error) {
+		for len(good.String()) < i {
+			time.Sleep(time.Millisecond)
+		}
+		if i == n {
+			return nil, errors.New("device lost")
+		}
+		i++
+		return []byte("x"), nil
+	}
+	p := newPipeline(read, func() error { return nil }, []io.Writer{good, slow})
+
+	// Neither the encoder nor Wait stalls on the detached sink.
+	err := p.Wait()
+	if err == nil || err.Error() != "device lost" {
+		t.Errorf("Wait = %v, want the read error", err)
+	}
+	if len(good.String()) != n {
+		t.Errorf("good sink got %d buffers, want %d", len(good.String()), n)
+	}
+	errs := p.Errors()
+	if len(errs) != 1 || errs[0].Writer != slow || !errors.Is(errs[0].Err, ErrSinkTooSlow) {
+		t.Errorf("Errors = %v, want ErrSinkTooSlow for the blocked sink", errs)
+	}
+}
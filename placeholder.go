@@ -0,0 +1,84 @@
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+)
+
+// silenceChunkDuration 是静音轨道每个音频块的时长（毫秒），与设备捕获的默认块大小一致。
+const silenceChunkDuration = 20
+
+// NewSolidColorVideoTrack 创建一个持续输出纯色画面的视频轨道。
+// 适用于真实设备暂时不可用时保持会话（如 RTP 推流、录制）不中断。
+// 宽高必须为正偶数，以保证 YUV420p 色度平面完整。
+func NewSolidColorVideoTrack(width, height int, fps float64, c color.Color) (*MediaStreamTrack, error) {
+	if width <= 0 || height <= 0 || width%2 != 0 || height%2 != 0 {
+		return nil, fmt.Errorf("solid color track: width and height must be positive and even (got %dx%d)", width, height)
+	}
+
+	r, g, b, _ := c.RGBA()
+	yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(b>>8))
+	frame := image.NewYCbCr(image.Rect(0, 0, width, height), image.YCbCrSubsampleRatio420)
+	fill(frame.Y, yy)
+	fill(frame.Cb, cb)
+	fill(frame.Cr, cr)
+
+	track, err := NewVideoTrackFromFunc(func() image.Image {
+		// 每帧返回独立副本，与设备捕获一致，调用方可以安全修改
+		return cloneYCbCr(frame)
+	}, fps)
+	if err != nil {
+		return nil, err
+	}
+	track.label = "Solid color video"
+	return track, nil
+}
+
+// NewBlackVideoTrack 创建一个持续输出黑帧的视频轨道。
+func NewBlackVideoTrack(width, height int, fps float64) (*MediaStreamTrack, error) {
+	track, err := NewSolidColorVideoTrack(width, height, fps, color.Black)
+	if err != nil {
+		return nil, err
+	}
+	track.label = "Black video"
+	return track, nil
+}
+
+// NewSilentAudioTrack 创建一个持续输出静音的音频轨道，每块 20ms。
+func NewSilentAudioTrack(sampleRate, channels int) (*MediaStreamTrack, error) {
+	if sampleRate <= 0 || channels <= 0 {
+		return nil, fmt.Errorf("silent track: sample rate and channels must be positive (got %d Hz, %d channels)", sampleRate, channels)
+	}
+	samplesPerChannel := sampleRate * silenceChunkDuration / 1000
+
+	track, err := NewAudioTrackFromFunc(func() *AudioChunk {
+		return &AudioChunk{
+			Data:              make([]int16, samplesPerChannel*channels),
+			Channels:          channels,
+			SampleRate:        sampleRate,
+			SamplesPerChannel: samplesPerChannel,
+		}
+	}, sampleRate, channels)
+	if err != nil {
+		return nil, err
+	}
+	track.label = "Silence"
+	return track, nil
+}
+
+// fill 将切片的所有元素设置为 v。
+func fill(b []byte, v byte) {
+	for i := range b {
+		b[i] = v
+	}
+}
+
+// cloneYCbCr 返回图像的深拷贝。
+func cloneYCbCr(src *image.YCbCr) *image.YCbCr {
+	dst := *src
+	dst.Y = append([]byte(nil), src.Y...)
+	dst.Cb = append([]byte(nil), src.Cb...)
+	dst.Cr = append([]byte(nil), src.Cr...)
+	return &dst
+}
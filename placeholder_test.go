@@ -0,0 +1,57 @@
+package mediadevices
+
+import (
+	"image"
+	"testing"
+)
+
+func TestNewBlackVideoTrack(t *testing.T) {
+	track, err := NewBlackVideoTrack(4, 2, 1000)
+	if err != nil {
+		t.Fatalf("NewBlackVideoTrack: %v", err)
+	}
+	defer track.Stop()
+
+	img, err := track.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	ycc := img.(*image.YCbCr)
+	if ycc.Y[0] != 0 || ycc.Cb[0] != 128 || ycc.Cr[0] != 128 {
+		t.Errorf("pixel = (%d,%d,%d), want black (0,128,128)", ycc.Y[0], ycc.Cb[0], ycc.Cr[0])
+	}
+
+	// Frames must not share memory.
+	ycc.Y[0] = 99
+	next, _ := track.Read()
+	if next.(*image.YCbCr).Y[0] != 0 {
+		t.Error("modifying one frame affected the next")
+	}
+}
+
+func TestNewSolidColorVideoTrack_OddSize(t *testing.T) {
+	if _, err := NewBlackVideoTrack(3, 2, 30); err == nil {
+		t.Error("expected error for odd width")
+	}
+}
+
+func TestNewSilentAudioTrack(t *testing.T) {
+	track, err := NewSilentAudioTrack(48000, 2)
+	if err != nil {
+		t.Fatalf("NewSilentAudioTrack: %v", err)
+	}
+	defer track.Stop()
+
+	chunk, err := track.ReadAudio()
+	if err != nil {
+		t.Fatalf("ReadAudio: %v", err)
+	}
+	if chunk.SamplesPerChannel != 960 || len(chunk.Data) != 1920 {
+		t.Errorf("chunk = %d samples/ch, %d total; want 960, 1920", chunk.SamplesPerChannel, len(chunk.Data))
+	}
+	for i, v := range chunk.Data {
+		if v != 0 {
+			t.Fatalf("Data[%d] = %d, want 0", i, v)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+//go:build !linux && !windows && !darwin && !android && !freebsd && !openbsd
+
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"runtime"
+	"time"
+)
+
+// errPlatformUnsupported is returned on operating systems without a
+// capture backend, such as NetBSD, DragonFly BSD, Solaris and illumos.
+// Devices opened the same way on every platform (virtual devices, synthetic
+// test sources and DeckLink inputs) still work there.
+var errPlatformUnsupported = fmt.Errorf("capture devices are not supported on %s", runtime.GOOS)
+
+// buildVideoCaptureArgs builds FFmpeg arguments for capturing video from a
+// device that is opened the same way on every platform.
+func buildVideoCaptureArgs(p VideoCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildVideoInputArgs(p)...)
+
+	// Output: raw YUV420p to stdout
+	args = append(args, videoOutputArgs(p)...)
+
+	return args
+}
+
+// buildVideoInputArgs builds the FFmpeg input arguments of a portable video
+// device. Other devices are passed to -i as they are and fail to open in
+// FFmpeg; checkDeviceAccess reports errPlatformUnsupported for them first.
+func buildVideoInputArgs(p VideoCaptureParams) []string {
+	if args, ok := portableVideoInputArgs(p); ok {
+		return args
+	}
+	return []string{"-i", p.DeviceID}
+}
+
+// buildAudioCaptureArgs builds FFmpeg arguments for capturing audio from a
+// device that is opened the same way on every platform.
+func buildAudioCaptureArgs(p AudioCaptureParams) []string {
+	args := []string{"-y"}
+	args = append(args, buildAudioInputArgs(p)...)
+
+	// Output: raw PCM S16LE to stdout
+	args = append(args, audioOutputArgs(p)...)
+
+	return args
+}
+
+// buildAudioInputArgs is buildVideoInputArgs for audio devices.
+func buildAudioInputArgs(p AudioCaptureParams) []string {
+	if args, ok := portableAudioInputArgs(p); ok {
+		return args
+	}
+	return []string{"-i", p.DeviceID}
+}
+
+// buildDisplayCaptureArgs builds FFmpeg arguments for screen capture, which
+// has no input here. GetDisplaySources lists no screens, so only an explicit
+// source ID gets this far, and FFmpeg fails to open it.
+func buildDisplayCaptureArgs(p DisplayCaptureParams) []string {
+	args := []string{"-y", "-i", p.Display}
+	return append(args, videoOutputArgs(VideoCaptureParams{Width: p.Width, Height: p.Height, UseWallclockTimestamps: p.UseWallclockTimestamps})...)
+}
+
+// buildLoopbackInputArgs builds the FFmpeg input arguments for the render
+// reference of an echo canceller, an audio input given by ReferenceDeviceID.
+func buildLoopbackInputArgs(p AudioCaptureParams) []string {
+	return buildAudioInputArgs(p)
+}
+
+// buildAudioPlaybackArgs returns errPlatformUnsupported: there is no audio
+// output device to play to.
+func buildAudioPlaybackArgs(p AudioPlaybackParams) ([]string, error) {
+	return nil, errPlatformUnsupported
+}
+
+// discoverDevices lists no devices. Virtual and synthetic devices are added
+// by the caller as on every platform.
+func discoverDevices(ctx context.Context, ffmpegPath string) ([]MediaDeviceInfo, error) {
+	return nil, nil
+}
+
+// probeDeviceModes reports no modes.
+func probeDeviceModes(ctx context.Context, ffmpegPath string, kind MediaDeviceKind, name string) (string, error) {
+	return "", nil
+}
+
+// queryPermission reports errPlatformUnsupported.
+func queryPermission(kind MediaDeviceKind) (PermissionState, error) {
+	return "", errPlatformUnsupported
+}
+
+// probeDeviceAccess rejects every device of the platform; portable devices
+// are not probed.
+func probeDeviceAccess(kind MediaDeviceKind, name string) error {
+	return fmt.Errorf("ffmpeg: open %s: %w", name, errPlatformUnsupported)
+}
+
+// deviceHolderHint returns "".
+func deviceHolderHint(kind MediaDeviceKind, name string) string {
+	return ""
+}
+
+// enumerateMonitors lists no monitors.
+func enumerateMonitors(timeout time.Duration) ([]monitor, error) {
+	return nil, nil
+}
+
+// enumerateWindows lists no windows.
+func enumerateWindows(timeout time.Duration) ([]DisplaySource, error) {
+	return nil, nil
+}
+
+func windowBounds(timeout time.Duration, handle uintptr) (image.Rectangle, error) {
+	return image.Rectangle{}, errPlatformUnsupported
+}
@@ -0,0 +1,177 @@
+package mediadevices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// DevicePreferenceStore 保存用户偏好的摄像头和麦克风，供 GetUserMediaPreferred 使用。
+// 设备以稳定的 DeviceID 记录。实现须可被并发调用。
+type DevicePreferenceStore interface {
+	// PreferredDevice 返回 kind 类型的偏好设备 ID，没有记录时返回空字符串。
+	PreferredDevice(kind MediaDeviceKind) (string, error)
+	// SetPreferredDevice 记录 kind 类型的偏好设备 ID，空字符串清除记录。
+	SetPreferredDevice(kind MediaDeviceKind, deviceID string) error
+}
+
+// FilePreferenceStore 是把偏好保存在 JSON 文件中的 DevicePreferenceStore，
+// 文件内容形如 {"videoinput": "<DeviceID>", "audioinput": "<DeviceID>"}。
+type FilePreferenceStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+// NewFilePreferenceStore 返回使用 path 文件的偏好存储。文件不存在时视为没有偏好，
+// 首次记录时创建（包括所在目录）。
+func NewFilePreferenceStore(path string) *FilePreferenceStore {
+	return &FilePreferenceStore{path: path}
+}
+
+// PreferredDevice 实现 DevicePreferenceStore。
+func (s *FilePreferenceStore) PreferredDevice(kind MediaDeviceKind) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefs, err := s.load()
+	return prefs[kind], err
+}
+
+// SetPreferredDevice 实现 DevicePreferenceStore。文件先写入临时文件再重命名，
+// 中途崩溃不会留下损坏的文件。
+func (s *FilePreferenceStore) SetPreferredDevice(kind MediaDeviceKind, deviceID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prefs, err := s.load()
+	if err != nil {
+		return err
+	}
+	if deviceID == "" {
+		delete(prefs, kind)
+	} else {
+		prefs[kind] = deviceID
+	}
+
+	data, err := json.MarshalIndent(prefs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(s.path, append(data, '\n')); err != nil {
+		return fmt.Errorf("device preferences: %w", err)
+	}
+	return nil
+}
+
+// load 读取偏好文件，文件不存在时返回空映射。
+func (s *FilePreferenceStore) load() (map[MediaDeviceKind]string, error) {
+	prefs := make(map[MediaDeviceKind]string)
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, fmt.Errorf("device preferences: %w", err)
+	}
+	if err := json.Unmarshal(data, &prefs); err != nil {
+		return prefs, fmt.Errorf("device preferences %s: %w", s.path, err)
+	}
+	return prefs, nil
+}
+
+// GetUserMediaPreferred 与 GetUserMedia 相同，但未指定 DeviceID 的轨道优先使用
+// Config.DevicePreferences 中记住的设备。
+//
+// 记住的设备当前不存在（已拔出）或偏好无法读取时，回退到系统默认设备，不返回错误。
+// 约束中显式指定了 DeviceID 且获取成功时，该设备被记为新的偏好，
+// 因此应用只需把用户的选择传给本函数即可。未设置 Config.DevicePreferences 时等同于 GetUserMedia。
+func GetUserMediaPreferred(constraints MediaTrackConstraints) (*MediaStream, error) {
+	return defaultMediaDevices.GetUserMediaPreferredContext(context.Background(), constraints)
+}
+
+// GetUserMediaPreferred 与包级函数 GetUserMediaPreferred 相同，但使用 m 的配置、
+// 设备和 Config.DevicePreferences。
+func (m *MediaDevices) GetUserMediaPreferred(constraints MediaTrackConstraints) (*MediaStream, error) {
+	return m.GetUserMediaPreferredContext(context.Background(), constraints)
+}
+
+// GetUserMediaPreferredContext 与 GetUserMediaPreferred 相同，但受 ctx 控制，
+// 参见 GetUserMediaContext。
+func GetUserMediaPreferredContext(ctx context.Context, constraints MediaTrackConstraints) (*MediaStream, error) {
+	return defaultMediaDevices.GetUserMediaPreferredContext(ctx, constraints)
+}
+
+// GetUserMediaPreferredContext 与包级函数 GetUserMediaPreferredContext 相同，
+// 但使用 m 的配置、设备和 Config.DevicePreferences。
+func (m *MediaDevices) GetUserMediaPreferredContext(ctx context.Context, constraints MediaTrackConstraints) (*MediaStream, error) {
+	cfg := m.Config()
+	store := cfg.DevicePreferences
+	if store == nil {
+		return m.GetUserMediaContext(ctx, constraints)
+	}
+
+	var chosenVideo, chosenAudio *string
+	if v := constraints.Video; v != nil {
+		c := *v
+		chosenVideo = c.DeviceID
+		if c.DeviceID == nil {
+			c.DeviceID = m.preferredDeviceID(ctx, store, MediaDeviceKindVideoInput, cfg.Verbose)
+		}
+		constraints.Video = &c
+	}
+	if a := constraints.Audio; a != nil {
+		c := *a
+		chosenAudio = c.DeviceID
+		if c.DeviceID == nil {
+			c.DeviceID = m.preferredDeviceID(ctx, store, MediaDeviceKindAudioInput, cfg.Verbose)
+		}
+		constraints.Audio = &c
+	}
+
+	stream, err := m.GetUserMediaContext(ctx, constraints)
+	if err != nil {
+		return nil, err
+	}
+
+	// 记住显式选择的设备；保存失败不影响已获取的流。
+	remember := func(kind MediaDeviceKind, id *string) {
+		if id == nil {
+			return
+		}
+		// 别名保存为其指向的 DeviceID，修改别名不影响已记住的设备。
+		if err := store.SetPreferredDevice(kind, cfg.DeviceFilter.resolve(*id)); err != nil && cfg.Verbose {
+			log.Printf("ffmpeg: saving preferred %s: %v", kind, err)
+		}
+	}
+	remember(MediaDeviceKindVideoInput, chosenVideo)
+	remember(MediaDeviceKindAudioInput, chosenAudio)
+	return stream, nil
+}
+
+// preferredDeviceID 返回记住的 kind 类型设备的 ID；没有偏好、偏好无法读取或设备不在 m 中时返回 nil，
+// 由 GetUserMedia 使用默认设备。
+func (m *MediaDevices) preferredDeviceID(ctx context.Context, store DevicePreferenceStore, kind MediaDeviceKind, verbose bool) *string {
+	id, err := store.PreferredDevice(kind)
+	if err != nil {
+		if verbose {
+			log.Printf("ffmpeg: reading preferred %s: %v", kind, err)
+		}
+		return nil
+	}
+	if id == "" {
+		return nil
+	}
+	devices, err := m.devicesByKind(ctx, kind)
+	if err != nil {
+		return nil
+	}
+	if _, found := m.findDevice(devices, id); found {
+		return &id
+	}
+	if verbose {
+		log.Printf("ffmpeg: preferred %s %s is absent, using the default", kind, id)
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package mediadevices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFilePreferenceStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sub", "devices.json")
+	s := NewFilePreferenceStore(path)
+
+	if id, err := s.PreferredDevice(MediaDeviceKindVideoInput); err != nil || id != "" {
+		t.Fatalf("missing file: %q, %v", id, err)
+	}
+	if err := s.SetPreferredDevice(MediaDeviceKindVideoInput, "cam-2"); err != nil {
+		t.Fatalf("SetPreferredDevice: %v", err)
+	}
+	if err := s.SetPreferredDevice(MediaDeviceKindAudioInput, "mic-1"); err != nil {
+		t.Fatalf("SetPreferredDevice: %v", err)
+	}
+
+	// A new store on the same file sees the saved preferences.
+	s = NewFilePreferenceStore(path)
+	if id, _ := s.PreferredDevice(MediaDeviceKindVideoInput); id != "cam-2" {
+		t.Errorf("video preference = %q, want cam-2", id)
+	}
+	if err := s.SetPreferredDevice(MediaDeviceKindAudioInput, ""); err != nil {
+		t.Fatal(err)
+	}
+	if id, _ := s.PreferredDevice(MediaDeviceKindAudioInput); id != "" {
+		t.Errorf("cleared audio preference = %q", id)
+	}
+
+	if err := os.WriteFile(path, []byte("{broken"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PreferredDevice(MediaDeviceKindVideoInput); err == nil {
+		t.Error("corrupt file accepted")
+	}
+}
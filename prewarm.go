@@ -0,0 +1,397 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPrewarmTimeout 是未设置 Config.PrewarmTimeout 时预热的设备保持打开的时间。
+	defaultPrewarmTimeout = 30 * time.Second
+	// prewarmClaimTimeout 是接管预热的捕获时等待其交付完当前帧的最长时间。
+	prewarmClaimTimeout = time.Second
+	// prewarmAudioUnit 是预热的音频捕获每次丢弃的数据时长。
+	prewarmAudioUnit = 20 * time.Millisecond
+)
+
+// prewarmedCapture 是 Prewarm 启动、等待被轨道或读取器接管的捕获。
+// 接管前由 drain 读取并丢弃其输出，每次读取整帧（或整块音频），
+// 因此移交时管道中的数据总是从帧边界开始。
+type prewarmedCapture struct {
+	key      string
+	deviceID string // MediaDeviceInfo.DeviceID
+	args     []string
+	caps     *DeviceCapabilities // 预热时查询到的能力，查询失败时为 nil
+	src      *captureSource
+	timer    *time.Timer
+
+	claim chan chan *captureSource // 接管请求，容量为 1
+	done  chan struct{}            // drain 退出时关闭
+}
+
+// prewarmKey 返回预热的捕获在 MediaDevices.prewarmed 中的键。
+func prewarmKey(kind MediaDeviceKind, name string) string {
+	return string(kind) + "\x00" + name
+}
+
+// Prewarm 提前打开设备 deviceID 并保持打开，使之后请求该设备的 GetUserMedia 在几十毫秒内
+// 开始交付帧，而不必等待摄像头或麦克风数秒的冷启动。
+//
+// 设备以 GetUserMedia 对只指定了该设备的约束会选择的设置打开；要以其他设置预热，
+// 使用 PrewarmConstraints。Prewarm 在设备交付第一帧后返回，此后 FFmpeg 在后台继续捕获并丢弃帧。
+// 之后 GetUserMedia、NewVideoReader、NewAudioReader 以相同设置打开该设备时直接接管这个进程；
+// 设置不同时预热的进程先被停止，设备照常冷启动。
+//
+// 超过 Config.PrewarmTimeout（默认 30 秒）没有被接管的预热自动关闭。对已预热的设备再次调用
+// 会重新打开设备并重新计时。预热的进程计入 ActiveResources，并由 CloseAll 关闭。
+// 屏幕捕获源不能预热。
+func Prewarm(deviceID string) error {
+	return defaultMediaDevices.Prewarm(deviceID)
+}
+
+// Prewarm 与包级函数 Prewarm 相同，但在 m 的设备中查找设备并使用 m 的配置捕获。
+func (m *MediaDevices) Prewarm(deviceID string) error {
+	ctx := context.Background()
+	for _, kind := range []MediaDeviceKind{MediaDeviceKindVideoInput, MediaDeviceKindAudioInput} {
+		devices, err := m.devicesByKind(ctx, kind)
+		if err != nil {
+			return fmt.Errorf("prewarm: %w", err)
+		}
+		d, found := m.findDevice(devices, deviceID)
+		if !found {
+			continue
+		}
+		if kind == MediaDeviceKindVideoInput {
+			return m.PrewarmConstraints(MediaTrackConstraints{Video: &VideoTrackConstraints{DeviceID: &d.DeviceID}})
+		}
+		return m.PrewarmConstraints(MediaTrackConstraints{Audio: &AudioTrackConstraints{DeviceID: &d.DeviceID}})
+	}
+	return fmt.Errorf("prewarm: %w: %s", ErrNotFound, deviceID)
+}
+
+// PrewarmConstraints 预热 GetUserMedia 对 constraints 会打开的设备，设备和设置的选择与之相同，
+// 因此之后以同样的约束调用 GetUserMedia 总能接管预热的进程。其余行为见 Prewarm。
+//
+// 示例：
+//
+//	constraints := mediadevices.MediaTrackConstraints{
+//	    Video: &mediadevices.VideoTrackConstraints{Width: mediadevices.IdealInt(1280)},
+//	}
+//	if err := mediadevices.PrewarmConstraints(constraints); err != nil {
+//	    log.Printf("prewarm: %v", err)
+//	}
+//	// ……稍后，用户点击“开始”时：
+//	stream, err := mediadevices.GetUserMedia(constraints)
+func PrewarmConstraints(constraints MediaTrackConstraints) error {
+	return defaultMediaDevices.PrewarmConstraints(constraints)
+}
+
+// PrewarmConstraints 与包级函数 PrewarmConstraints 相同，但在 m 的设备中选择设备并使用 m 的配置捕获。
+func (m *MediaDevices) PrewarmConstraints(constraints MediaTrackConstraints) error {
+	if constraints.Video == nil && constraints.Audio == nil {
+		return fmt.Errorf("prewarm: no constraints specified (neither video nor audio)")
+	}
+	ctx := context.Background()
+	if constraints.Video != nil {
+		if err := m.prewarmVideo(ctx, constraints.Video); err != nil {
+			return fmt.Errorf("prewarm video: %w", err)
+		}
+	}
+	if constraints.Audio != nil {
+		if err := m.prewarmAudio(ctx, constraints.Audio); err != nil {
+			return fmt.Errorf("prewarm audio: %w", err)
+		}
+	}
+	return nil
+}
+
+// CancelPrewarm 关闭 Prewarm 为设备 deviceID 打开的捕获。设备没有预热时什么都不做。
+func CancelPrewarm(deviceID string) {
+	defaultMediaDevices.CancelPrewarm(deviceID)
+}
+
+// CancelPrewarm 与包级函数 CancelPrewarm 相同，但只关闭 m 的预热。
+func (m *MediaDevices) CancelPrewarm(deviceID string) {
+	deviceID = m.Config().DeviceFilter.resolve(deviceID)
+	m.prewarmMu.Lock()
+	var stop []*prewarmedCapture
+	for _, p := range m.prewarmed {
+		if p.deviceID == deviceID {
+			stop = append(stop, p)
+		}
+	}
+	m.prewarmMu.Unlock()
+	for _, p := range stop {
+		m.stopPrewarmed(p)
+	}
+}
+
+// cancelAllPrewarms 关闭 m 的所有预热。
+func (m *MediaDevices) cancelAllPrewarms() {
+	m.prewarmMu.Lock()
+	var stop []*prewarmedCapture
+	for _, p := range m.prewarmed {
+		stop = append(stop, p)
+	}
+	m.prewarmMu.Unlock()
+	for _, p := range stop {
+		m.stopPrewarmed(p)
+	}
+}
+
+// prewarmVideo 按约束 c 选择设备和设置并预热。
+func (m *MediaDevices) prewarmVideo(ctx context.Context, c *VideoTrackConstraints) error {
+	d, err := m.videoInputDevice(ctx, c)
+	if err != nil {
+		return err
+	}
+	name := ffmpegDeviceName(d)
+	if strings.HasPrefix(name, displayDevicePrefix) {
+		return fmt.Errorf("screen capture source %s cannot be prewarmed", d.DeviceID)
+	}
+
+	// 查询模式前释放已预热的进程，使设备可被探测
+	m.stopPrewarmedDevice(MediaDeviceKindVideoInput, name)
+	caps := m.prewarmCapabilities(ctx, d)
+	var modes []VideoMode
+	if caps != nil {
+		modes = caps.VideoModes
+	}
+	s, err := selectVideoSettings(c, modes)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDeviceAccess(MediaDeviceKindVideoInput, name); err != nil {
+		return err
+	}
+	args, err := m.videoCaptureArgs(name, s.width, s.height, s.frameRate)
+	if err != nil {
+		return err
+	}
+	return m.startPrewarm(d, name, args, caps, s.width*s.height*3/2)
+}
+
+// prewarmAudio 按约束 c 选择设备和设置并预热。
+func (m *MediaDevices) prewarmAudio(ctx context.Context, c *AudioTrackConstraints) error {
+	d, err := m.inputDevice(ctx, MediaDeviceKindAudioInput, c.DeviceID)
+	if err != nil {
+		return err
+	}
+	name := ffmpegDeviceName(d)
+
+	m.stopPrewarmedDevice(MediaDeviceKindAudioInput, name)
+	caps := m.prewarmCapabilities(ctx, d)
+	var modes []AudioMode
+	if caps != nil {
+		modes = caps.AudioModes
+	}
+	s, err := selectAudioSettings(c, modes)
+	if err != nil {
+		return err
+	}
+
+	if err := checkDeviceAccess(MediaDeviceKindAudioInput, name); err != nil {
+		return err
+	}
+	args := m.audioCaptureArgs(m.audioCaptureParams(name, s.sampleRate, s.channels))
+	samples := int(int64(s.sampleRate) * int64(prewarmAudioUnit) / int64(time.Second))
+	return m.startPrewarm(d, name, args, caps, max(samples, 1)*s.channels*2)
+}
+
+// prewarmCapabilities 查询设备 d 的能力，查询失败时返回 nil。
+func (m *MediaDevices) prewarmCapabilities(ctx context.Context, d MediaDeviceInfo) *DeviceCapabilities {
+	caps, err := m.GetDeviceCapabilitiesContext(ctx, d.DeviceID)
+	if err != nil {
+		return nil
+	}
+	return &caps
+}
+
+// startPrewarm 以 args 启动设备 d 的捕获，等待第一块 unit 字节的数据后登记为预热的捕获。
+func (m *MediaDevices) startPrewarm(d MediaDeviceInfo, name string, args []string, caps *DeviceCapabilities, unit int) error {
+	gcfg := m.Config()
+	src, err := startCapture(gcfg, d.Kind, name, args, nil)
+	if err != nil {
+		return fmt.Errorf("ffmpeg: start capture: %w", err)
+	}
+
+	p := &prewarmedCapture{
+		key:      prewarmKey(d.Kind, name),
+		deviceID: d.DeviceID,
+		args:     args,
+		caps:     caps,
+		src:      src,
+		claim:    make(chan chan *captureSource, 1),
+		done:     make(chan struct{}),
+	}
+	ready := make(chan error, 1)
+	go m.drainPrewarmed(p, unit, ready)
+
+	select {
+	case err = <-ready:
+		if err != nil {
+			err = fmt.Errorf("ffmpeg: read first frame: %w", err)
+		}
+	case <-time.After(firstFrameTimeout):
+		err = errors.New("ffmpeg: timeout waiting for first frame")
+	}
+	if err != nil {
+		src.Stop()
+		<-p.done
+		return src.captureError(err)
+	}
+
+	timeout := gcfg.PrewarmTimeout
+	if timeout <= 0 {
+		timeout = defaultPrewarmTimeout
+	}
+	m.prewarmMu.Lock()
+	if m.prewarmed == nil {
+		m.prewarmed = make(map[string]*prewarmedCapture)
+	}
+	prev := m.prewarmed[p.key]
+	m.prewarmed[p.key] = p
+	p.timer = time.AfterFunc(timeout, func() { m.stopPrewarmed(p) })
+	m.prewarmMu.Unlock()
+	if prev != nil {
+		// 并发的 Prewarm 预热了同一设备
+		m.stopPrewarmed(prev)
+	}
+	select {
+	case <-p.done:
+		// 进程在登记前已退出
+		m.stopPrewarmed(p)
+		return src.captureError(errors.New("ffmpeg: capture ended"))
+	default:
+	}
+	return nil
+}
+
+// drainPrewarmed 读取并丢弃 p 的输出直到被接管。第一次读取的结果发送到 ready。
+// 进程退出时 p 被注销。
+func (m *MediaDevices) drainPrewarmed(p *prewarmedCapture, unit int, ready chan<- error) {
+	defer close(p.done)
+	buf := make([]byte, unit)
+	for {
+		_, err := io.ReadFull(p.src, buf)
+		if errors.Is(err, errCaptureRestarted) {
+			continue
+		}
+		if ready != nil {
+			ready <- err
+			ready = nil
+		}
+		if err != nil {
+			m.stopPrewarmed(p)
+			return
+		}
+		select {
+		case reply := <-p.claim:
+			reply <- p.src
+			return
+		default:
+		}
+	}
+}
+
+// stopPrewarmed 注销并停止 p。
+func (m *MediaDevices) stopPrewarmed(p *prewarmedCapture) {
+	m.prewarmMu.Lock()
+	if m.prewarmed[p.key] == p {
+		delete(m.prewarmed, p.key)
+	}
+	timer := p.timer
+	m.prewarmMu.Unlock()
+	if timer != nil {
+		timer.Stop()
+	}
+	p.src.Stop()
+}
+
+// stopPrewarmedDevice 停止设备 name 的预热（如果有）。
+func (m *MediaDevices) stopPrewarmedDevice(kind MediaDeviceKind, name string) {
+	m.prewarmMu.Lock()
+	p := m.prewarmed[prewarmKey(kind, name)]
+	m.prewarmMu.Unlock()
+	if p != nil {
+		m.stopPrewarmed(p)
+	}
+}
+
+// isPrewarmed 报告设备 name 是否已被预热，此时设备被 m 自己的进程占用。
+func (m *MediaDevices) isPrewarmed(kind MediaDeviceKind, name string) bool {
+	m.prewarmMu.Lock()
+	defer m.prewarmMu.Unlock()
+	return m.prewarmed[prewarmKey(kind, name)] != nil
+}
+
+// prewarmedCapabilities 返回设备 d 预热时查询到的能力。
+func (m *MediaDevices) prewarmedCapabilities(d MediaDeviceInfo) (DeviceCapabilities, bool) {
+	m.prewarmMu.Lock()
+	defer m.prewarmMu.Unlock()
+	p := m.prewarmed[prewarmKey(d.Kind, ffmpegDeviceName(d))]
+	if p == nil || p.caps == nil {
+		return DeviceCapabilities{}, false
+	}
+	return *p.caps, true
+}
+
+// openCapture 与 startCapture 相同，但设备 deviceID 以相同参数预热过时接管预热的进程。
+// 参数不同或 hook 不为 nil 时预热的进程先被停止，以释放设备。
+func (m *MediaDevices) openCapture(gcfg Config, kind MediaDeviceKind, deviceID string, args []string, hook func([]string) []string) (*captureSource, error) {
+	if src := m.claimPrewarmed(kind, deviceID, args, hook); src != nil {
+		return src, nil
+	}
+	return startCapture(gcfg, kind, deviceID, args, hook)
+}
+
+// claimPrewarmed 注销设备 name 的预热并在参数匹配时返回其捕获，否则停止它并返回 nil。
+func (m *MediaDevices) claimPrewarmed(kind MediaDeviceKind, name string, args []string, hook func([]string) []string) *captureSource {
+	m.prewarmMu.Lock()
+	p := m.prewarmed[prewarmKey(kind, name)]
+	m.prewarmMu.Unlock()
+	if p == nil {
+		return nil
+	}
+	if hook != nil || !slices.Equal(p.args, args) {
+		m.stopPrewarmed(p)
+		return nil
+	}
+
+	m.prewarmMu.Lock()
+	owned := m.prewarmed[p.key] == p
+	if owned {
+		delete(m.prewarmed, p.key)
+		p.timer.Stop()
+	}
+	m.prewarmMu.Unlock()
+	if !owned {
+		// 已超时或被取消
+		return nil
+	}
+
+	reply := make(chan *captureSource, 1)
+	p.claim <- reply
+	select {
+	case src := <-reply:
+		return src
+	case <-p.done:
+		// drain 在移交后退出，或进程已结束
+		select {
+		case src := <-reply:
+			return src
+		default:
+			return nil
+		}
+	case <-time.After(prewarmClaimTimeout):
+		// 预热的进程不再交付数据
+		p.src.Stop()
+		return nil
+	}
+}
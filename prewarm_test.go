@@ -0,0 +1,84 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrewarm(t *testing.T) {
+	var starts atomic.Int32
+	m := NewMediaDevices(Config{
+		FFmpegPath: "/bin/sh",
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: "cam", DeviceName: "/dev/video0", Label: "cam", Kind: MediaDeviceKindVideoInput}}, nil
+		},
+		ArgsHook: func([]string) []string {
+			starts.Add(1)
+			// A 4x2 YUV420p frame is 12 bytes.
+			return []string{"-c", "while :; do printf 0123456789ab || exit; sleep 0.01; done"}
+		},
+	})
+	defer m.CloseAll()
+
+	id := "cam"
+	small := MediaTrackConstraints{Video: &VideoTrackConstraints{DeviceID: &id, Width: ExactInt(4), Height: ExactInt(2)}}
+	if err := m.PrewarmConstraints(small); err != nil {
+		t.Fatalf("PrewarmConstraints: %v", err)
+	}
+	if n := m.ActiveResources(); n.Processes != 1 {
+		t.Errorf("ActiveResources after Prewarm = %+v, want one process", n)
+	}
+
+	stream, err := m.GetUserMedia(small)
+	if err != nil {
+		t.Fatalf("GetUserMedia: %v", err)
+	}
+	track := stream.GetVideoTracks()[0]
+	img, err := track.Read()
+	if err != nil || img.Bounds().Dx() != 4 {
+		t.Fatalf("Read = %v, %v", img, err)
+	}
+	if n := starts.Load(); n != 1 {
+		t.Errorf("%d processes started, want the prewarmed one taken over", n)
+	}
+	if n := m.ActiveResources(); n.Processes != 1 || n.Tracks != 1 {
+		t.Errorf("ActiveResources = %+v, want one process and one track", n)
+	}
+	track.Stop()
+
+	// Different settings: the prewarmed process is replaced.
+	if err := m.PrewarmConstraints(small); err != nil {
+		t.Fatalf("PrewarmConstraints: %v", err)
+	}
+	stream, err = m.GetUserMedia(MediaTrackConstraints{Video: &VideoTrackConstraints{DeviceID: &id, Width: ExactInt(8), Height: ExactInt(2)}})
+	if err != nil {
+		t.Fatalf("GetUserMedia: %v", err)
+	}
+	if n := m.ActiveResources(); n.Processes != 1 {
+		t.Errorf("ActiveResources = %+v, want only the new capture", n)
+	}
+	stream.GetVideoTracks()[0].Stop()
+
+	// Unclaimed prewarms close after PrewarmTimeout.
+	cfg := m.Config()
+	cfg.PrewarmTimeout = 50 * time.Millisecond
+	m.SetConfig(cfg)
+	if err := m.PrewarmConstraints(small); err != nil {
+		t.Fatalf("PrewarmConstraints: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for m.ActiveResources().Processes != 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := m.ActiveResources(); n.Processes != 0 {
+		t.Errorf("prewarm not closed after timeout: %+v", n)
+	}
+
+	if err := m.Prewarm("missing"); err == nil {
+		t.Error("Prewarm of a missing device succeeded")
+	}
+}
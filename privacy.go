@@ -0,0 +1,45 @@
+package mediadevices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// ResetLabelConsent 撤销已记录的捕获授权，使启用 Config.RedactLabels 时
+// EnumerateDevices 重新返回不含标签的设备，直到下一次 GetUserMedia 成功。
+// 授权记录在 MediaDevices 实例中（GetUserMedia 至少成功过一次），
+// 对应浏览器在授权前隐藏 MediaDeviceInfo.label 的行为。
+func ResetLabelConsent() {
+	defaultMediaDevices.ResetLabelConsent()
+}
+
+// ResetLabelConsent 与包级函数 ResetLabelConsent 相同，但撤销 m 记录的授权。
+func (m *MediaDevices) ResetLabelConsent() {
+	m.labelConsent.Store(false)
+}
+
+// redactDevices 在隐私模式下返回去除可识别信息的设备副本。
+// 未启用 Config.RedactLabels 或已获得授权时原样返回。
+func (m *MediaDevices) redactDevices(devices []MediaDeviceInfo) []MediaDeviceInfo {
+	if !m.Config().RedactLabels || m.labelConsent.Load() {
+		return devices
+	}
+	redacted := make([]MediaDeviceInfo, len(devices))
+	for i, d := range devices {
+		d.Label = ""
+		d.DeviceName = ""
+		d.AlternativeName = ""
+		d.FacingMode = ""
+		if d.GroupID != "" {
+			d.GroupID = opaqueID(d.GroupID)
+		}
+		redacted[i] = d
+	}
+	return redacted
+}
+
+// opaqueID 将可能包含设备名称的标识转换为不可逆的稳定标识。
+func opaqueID(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:16])
+}
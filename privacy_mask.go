@@ -0,0 +1,168 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+)
+
+// PrivacyMask is a region of the picture that FFmpeg blacks out before the
+// frames reach Go code, an encoder or a file, such as a neighbour's window
+// in the view of a CCTV camera. Masks are configured per device in
+// Config.PrivacyMasks.
+//
+// Like EncoderROI, the region is given as fractions of the picture size, so
+// that it stays in place at any resolution.
+type PrivacyMask struct {
+	// X, Y, W, H is the masked rectangle.
+	X, Y, W, H float64
+	// Polygon, if set, is masked instead of the rectangle. It needs at
+	// least three vertices; the edge from the last back to the first is
+	// implied. Polygons are filled by a per-pixel expression, which costs
+	// noticeably more CPU than rectangles.
+	Polygon []MaskPoint
+}
+
+// MaskPoint is a polygon vertex in fractions of the picture size.
+type MaskPoint struct {
+	X, Y float64
+}
+
+// PrivacyMaskFromRect returns the PrivacyMask for the pixel rectangle r of
+// a width x height picture.
+func PrivacyMaskFromRect(r image.Rectangle, width, height int) PrivacyMask {
+	w, h := float64(width), float64(height)
+	return PrivacyMask{
+		X: float64(r.Min.X) / w,
+		Y: float64(r.Min.Y) / h,
+		W: float64(r.Dx()) / w,
+		H: float64(r.Dy()) / h,
+	}
+}
+
+// PrivacyMaskFromPolygon returns the PrivacyMask for the pixel polygon
+// points of a width x height picture.
+func PrivacyMaskFromPolygon(points []image.Point, width, height int) PrivacyMask {
+	m := PrivacyMask{Polygon: make([]MaskPoint, len(points))}
+	for i, p := range points {
+		m.Polygon[i] = MaskPoint{X: float64(p.X) / float64(width), Y: float64(p.Y) / float64(height)}
+	}
+	return m
+}
+
+// validatePrivacyMasks checks that every mask lies within the picture.
+func validatePrivacyMasks(masks []PrivacyMask) error {
+	in := func(v float64) bool { return v >= 0 && v <= 1 }
+	for i, m := range masks {
+		if m.Polygon == nil {
+			if m.W <= 0 || m.H <= 0 || !in(m.X) || !in(m.Y) || m.X+m.W > 1 || m.Y+m.H > 1 {
+				return fmt.Errorf("privacy mask %d: region %v,%v %vx%v is not within the picture", i, m.X, m.Y, m.W, m.H)
+			}
+			continue
+		}
+		if len(m.Polygon) < 3 {
+			return fmt.Errorf("privacy mask %d: polygon has %d vertices, want at least 3", i, len(m.Polygon))
+		}
+		for _, p := range m.Polygon {
+			if !in(p.X) || !in(p.Y) {
+				return fmt.Errorf("privacy mask %d: vertex %v,%v is not within the picture", i, p.X, p.Y)
+			}
+		}
+	}
+	return nil
+}
+
+// privacyMaskFilter returns the filter chain that blacks out masks, or ""
+// for none. Rectangles are drawn with drawbox, rounded outwards so that no
+// partially covered pixel stays visible. All polygons are filled by one geq
+// filter with an even-odd point-in-polygon test, in limited-range black.
+func privacyMaskFilter(masks []PrivacyMask) string {
+	f := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	var chain, polygons []string
+	for _, m := range masks {
+		if m.Polygon == nil {
+			chain = append(chain, fmt.Sprintf("drawbox=x=floor(iw*%[1]s):y=floor(ih*%[2]s):w=ceil(iw*%[3]s)-floor(iw*%[1]s):h=ceil(ih*%[4]s)-floor(ih*%[2]s):color=black:t=fill",
+				f(m.X), f(m.Y), f(m.X+m.W), f(m.Y+m.H)))
+			continue
+		}
+		// A pixel is inside if a ray to its left crosses an odd number of
+		// edges. Horizontal edges never cross and would divide by zero.
+		var crossings []string
+		for i, a := range m.Polygon {
+			b := m.Polygon[(i+1)%len(m.Polygon)]
+			if a.Y == b.Y {
+				continue
+			}
+			crossings = append(crossings, fmt.Sprintf("ne(gt(Y,SH*H*%[2]s),gt(Y,SH*H*%[4]s))*lt(X,SW*W*(%[1]s+(%[3]s-%[1]s)*(Y-SH*H*%[2]s)/(SH*H*(%[4]s-%[2]s))))",
+				f(a.X), f(a.Y), f(b.X), f(b.Y)))
+		}
+		if len(crossings) > 0 {
+			polygons = append(polygons, fmt.Sprintf("mod(%s,2)", strings.Join(crossings, "+")))
+		}
+	}
+	if len(polygons) > 0 {
+		// X and Y are coordinates in the plane being filtered, and SW and
+		// SH scale W and H to it, so the mask also covers the subsampled
+		// chroma planes.
+		inside := fmt.Sprintf("gt(%s,0)", strings.Join(polygons, "+"))
+		chain = append(chain, fmt.Sprintf("format=yuv420p,geq=lum='if(%[1]s,16,lum(X,Y))':cb='if(%[1]s,128,cb(X,Y))':cr='if(%[1]s,128,cr(X,Y))'", inside))
+	}
+	return strings.Join(chain, ",")
+}
+
+// privacyMasksFor returns the masks configured for a device, looked up by
+// deviceID, else by the name FFmpeg opens it by, else by the DeviceID of
+// the enumerated device with that name. It fails if the masks are invalid,
+// so that a capture never starts unmasked by mistake.
+func (m *MediaDevices) privacyMasksFor(ctx context.Context, name, deviceID string) ([]PrivacyMask, error) {
+	all := m.Config().PrivacyMasks
+	if len(all) == 0 {
+		return nil, nil
+	}
+	masks, ok := all[deviceID]
+	if !ok {
+		masks, ok = all[name]
+	}
+	if !ok && name != "" {
+		devices, _ := m.devicesByKind(ctx, MediaDeviceKindVideoInput)
+		for _, d := range devices {
+			if d.DeviceName == name || ffmpegDeviceName(d) == name {
+				masks = all[d.DeviceID]
+				break
+			}
+		}
+	}
+	if err := validatePrivacyMasks(masks); err != nil {
+		return nil, err
+	}
+	return masks, nil
+}
+
+// maskVideoArgs adds the privacy masks of a device, as found by
+// privacyMasksFor, to the -vf chain of its capture arguments.
+func (m *MediaDevices) maskVideoArgs(ctx context.Context, name, deviceID string, args []string) ([]string, error) {
+	masks, err := m.privacyMasksFor(ctx, name, deviceID)
+	if err != nil {
+		return nil, err
+	}
+	return appendVideoFilter(args, privacyMaskFilter(masks)), nil
+}
+
+// appendVideoFilter adds filter to the end of the -vf chain of args, or
+// adds a -vf option before the output when there is none.
+func appendVideoFilter(args []string, filter string) []string {
+	if filter == "" {
+		return args
+	}
+	args = append([]string(nil), args...)
+	for i := len(args) - 2; i >= 0; i-- {
+		if args[i] == "-vf" {
+			args[i+1] += "," + filter
+			return args
+		}
+	}
+	n := len(args) - 1 // the output, e.g. pipe:1
+	return append(args[:n], "-vf", filter, args[n])
+}
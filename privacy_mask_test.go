@@ -0,0 +1,158 @@
+package mediadevices
+
+import (
+	"context"
+	"image"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestPrivacyMaskFilter_Rect(t *testing.T) {
+	got := privacyMaskFilter([]PrivacyMask{PrivacyMaskFromRect(image.Rect(0, 0, 320, 180), 1280, 720)})
+	want := "drawbox=x=floor(iw*0):y=floor(ih*0):w=ceil(iw*0.25)-floor(iw*0):h=ceil(ih*0.25)-floor(ih*0):color=black:t=fill"
+	if got != want {
+		t.Errorf("filter =\n%s\nwant\n%s", got, want)
+	}
+	if got := privacyMaskFilter(nil); got != "" {
+		t.Errorf("filter without masks = %q, want empty", got)
+	}
+}
+
+func TestPrivacyMaskFilter_Polygon(t *testing.T) {
+	got := privacyMaskFilter([]PrivacyMask{
+		{X: 0.5, Y: 0.5, W: 0.5, H: 0.5},
+		{Polygon: []MaskPoint{{0, 0}, {0.5, 0}, {0, 0.5}}},
+	})
+	chain := strings.Split(got, ",format=yuv420p,geq=")
+	if len(chain) != 2 || !strings.HasPrefix(chain[0], "drawbox=") {
+		t.Fatalf("want drawbox then geq, got %s", got)
+	}
+	// The horizontal top edge is skipped, leaving two crossing terms.
+	if n := strings.Count(chain[1], "ne(gt(Y,"); n != 3*2 {
+		t.Errorf("got %d crossing terms over the three planes, want 6: %s", n, chain[1])
+	}
+	for _, want := range []string{"lum='if(", ",16,lum(X,Y))'", ",128,cb(X,Y))'", ",128,cr(X,Y))'"} {
+		if !strings.Contains(chain[1], want) {
+			t.Errorf("geq missing %q: %s", want, chain[1])
+		}
+	}
+}
+
+func TestValidatePrivacyMasks(t *testing.T) {
+	valid := [][]PrivacyMask{
+		nil,
+		{{X: 0, Y: 0, W: 1, H: 1}},
+		{{Polygon: []MaskPoint{{0, 0}, {1, 0}, {1, 1}}}},
+	}
+	for _, masks := range valid {
+		if err := validatePrivacyMasks(masks); err != nil {
+			t.Errorf("validatePrivacyMasks(%v): %v", masks, err)
+		}
+	}
+	invalid := [][]PrivacyMask{
+		{{X: 0.5, Y: 0, W: 0.6, H: 1}},
+		{{X: 0, Y: 0, W: 0, H: 1}},
+		{{X: -0.1, Y: 0, W: 0.1, H: 1}},
+		{{Polygon: []MaskPoint{{0, 0}, {1, 1}}}},
+		{{Polygon: []MaskPoint{{0, 0}, {1, 0}, {1, 1.5}}}},
+	}
+	for _, masks := range invalid {
+		if err := validatePrivacyMasks(masks); err == nil {
+			t.Errorf("validatePrivacyMasks(%v) accepted", masks)
+		}
+	}
+}
+
+func TestAppendVideoFilter(t *testing.T) {
+	args := []string{"-i", "in", "-vf", "scale=640:480", "pipe:1"}
+	got := appendVideoFilter(args, "drawbox")
+	if want := []string{"-i", "in", "-vf", "scale=640:480,drawbox", "pipe:1"}; !slices.Equal(got, want) {
+		t.Errorf("with -vf: got %v, want %v", got, want)
+	}
+	if args[3] != "scale=640:480" {
+		t.Errorf("args modified: %v", args)
+	}
+
+	got = appendVideoFilter([]string{"-i", "in", "pipe:1"}, "drawbox")
+	if want := []string{"-i", "in", "-vf", "drawbox", "pipe:1"}; !slices.Equal(got, want) {
+		t.Errorf("without -vf: got %v, want %v", got, want)
+	}
+}
+
+func TestPrivacyMasksFor(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	mask := []PrivacyMask{{X: 0, Y: 0, W: 0.5, H: 0.5}}
+	cfg := orig
+	cfg.PrivacyMasks = map[string][]PrivacyMask{
+		"cam-1":   mask,
+		"/dev/v2": mask,
+		"broken":  {{X: 1, Y: 1, W: 1, H: 1}},
+	}
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{{DeviceID: "cam-1", DeviceName: "/dev/video0", Kind: MediaDeviceKindVideoInput}}, nil
+	}
+	SetConfig(cfg)
+
+	ctx := context.Background()
+	for _, tc := range []struct{ name, id string }{
+		{"", "cam-1"},
+		{"/dev/v2", ""},
+		{"/dev/video0", ""}, // by the DeviceID of the enumerated device
+	} {
+		got, err := defaultMediaDevices.privacyMasksFor(ctx, tc.name, tc.id)
+		if err != nil || len(got) != 1 {
+			t.Errorf("defaultMediaDevices.privacyMasksFor(%q, %q) = %v, %v; want the mask", tc.name, tc.id, got, err)
+		}
+	}
+	if got, err := defaultMediaDevices.privacyMasksFor(ctx, "/dev/video9", ""); err != nil || got != nil {
+		t.Errorf("unmasked device: got %v, %v", got, err)
+	}
+	if _, err := defaultMediaDevices.privacyMasksFor(ctx, "", "broken"); err == nil {
+		t.Error("invalid masks accepted")
+	}
+}
+
+func TestBuildCompositeArgs_PrivacyMask(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.PrivacyMasks = map[string][]PrivacyMask{"cam-b": {{X: 0, Y: 0, W: 0.5, H: 1}}}
+	SetConfig(cfg)
+
+	args, err := defaultMediaDevices.buildCompositeArgs(CompositeConfig{
+		Layout: CompositeLayoutGrid,
+		Width:  1280,
+		Height: 360,
+		Sources: []CompositeSource{
+			{Device: MediaDeviceInfo{DeviceID: "cam-a", DeviceName: "Front"}},
+			{Device: MediaDeviceInfo{DeviceID: "cam-b", DeviceName: "Rear"}},
+		},
+	})
+	if err != nil {
+		t.Fatalf("buildCompositeArgs: %v", err)
+	}
+	graph := args[slices.Index(args, "-filter_complex")+1]
+	if !strings.HasPrefix(graph, "[1:v]drawbox=") || !strings.Contains(graph, "[masked1];") {
+		t.Errorf("masking chain missing: %s", graph)
+	}
+	if strings.Count(graph, "[1:v]") != 1 || !strings.Contains(graph, "[0:v]") {
+		t.Errorf("masked source still used unmasked: %s", graph)
+	}
+}
+
+func TestBuildH264Args_PrivacyMask(t *testing.T) {
+	cfg := H264ReaderConfig{
+		DeviceName:   "cam",
+		Width:        1280,
+		Height:       720,
+		VideoFilter:  "hflip",
+		privacyMasks: []PrivacyMask{{X: 0.5, Y: 0, W: 0.5, H: 0.5}},
+	}
+	args := buildH264Args(cfg)
+	vf := args[slices.Index(args, "-vf")+1]
+	if !strings.HasPrefix(vf, "drawbox=") || !strings.HasSuffix(vf, ",scale=1280:720,hflip") {
+		t.Errorf("-vf %q, want the mask before scaling and the caller's filter", vf)
+	}
+}
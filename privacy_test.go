@@ -0,0 +1,47 @@
+package mediadevices
+
+import "testing"
+
+func TestRedactDevices(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	defer ResetLabelConsent()
+
+	devices := []MediaDeviceInfo{{
+		DeviceID:        "0b7c9a1e-0000-4000-8000-000000000001",
+		DeviceName:      "Integrated Camera",
+		AlternativeName: `@device_pnp_\\?\usb#vid_04f2&pid_b604&mi_00#6&2b8b1b1b&0&0000#{65e8773d-8f56-11d0-a3b9-00a0c9223196}\global`,
+		GroupID:         "Integrated Camera",
+		Kind:            MediaDeviceKindVideoInput,
+		Label:           "Integrated Camera",
+	}}
+
+	// Disabled: returned unchanged.
+	SetConfig(Config{})
+	if got := defaultMediaDevices.redactDevices(devices); got[0].Label != "Integrated Camera" {
+		t.Errorf("redaction disabled: Label = %q", got[0].Label)
+	}
+
+	// Enabled without consent: identifying fields removed, input untouched.
+	SetConfig(Config{RedactLabels: true})
+	ResetLabelConsent()
+	got := defaultMediaDevices.redactDevices(devices)
+	if got[0].Label != "" || got[0].DeviceName != "" || got[0].AlternativeName != "" {
+		t.Errorf("redacted device still has Label=%q DeviceName=%q AlternativeName=%q", got[0].Label, got[0].DeviceName, got[0].AlternativeName)
+	}
+	if got[0].GroupID == "" || got[0].GroupID == "Integrated Camera" {
+		t.Errorf("redacted GroupID = %q, want opaque non-empty value", got[0].GroupID)
+	}
+	if got[0].DeviceID != devices[0].DeviceID {
+		t.Errorf("DeviceID changed to %q", got[0].DeviceID)
+	}
+	if devices[0].Label != "Integrated Camera" {
+		t.Error("redactDevices modified its input")
+	}
+
+	// Consent obtained: labels visible again.
+	defaultMediaDevices.labelConsent.Store(true)
+	if got := defaultMediaDevices.redactDevices(devices); got[0].Label != "Integrated Camera" {
+		t.Errorf("after consent: Label = %q", got[0].Label)
+	}
+}
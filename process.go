@@ -1,32 +1,151 @@
 package mediadevices
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"os"
 	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
-const stderrBufSize = 4096
+// defaultStderrBufSize is the stderr tail kept per process when
+// Config.StderrBufferSize is not set.
+const defaultStderrBufSize = 4096
 
 // ffmpegProcess manages a running FFmpeg subprocess.
 type ffmpegProcess struct {
 	cmd    *exec.Cmd
 	stdout io.ReadCloser
+	stdin  io.WriteCloser // nil unless started by startEncodeProcess or startInteractiveProcess
+	inputs []*os.File     // extra input pipes, see startMuxProcess
 	cancel context.CancelFunc
 
-	stderrMu  sync.Mutex
-	stderrBuf []byte
-	done      chan struct{}
+	// resources is the registry of the MediaDevices whose Config started
+	// the process.
+	resources *resourceRegistry
+
+	// interactive is set when stdin is FFmpeg's command channel rather
+	// than media input; stdinMu serializes the commands.
+	interactive bool
+	stdinMu     sync.Mutex
+	zmq         *ZMQClient // set when commands go to a zmq filter instead
+
+	stderrMu   sync.Mutex
+	stderrBuf  []byte
+	stderrCut  bool           // stderrBuf lost bytes from its front
+	stderrSize int            // capacity of stderrBuf
+	stderrLog  io.WriteCloser // full stderr copy, nil unless Config.LogDir is set
+	stderrFeed *stderrFeed    // receives complete stderr lines, may be nil
+	done       chan struct{}
+
+	// waitOnce runs cmd.Wait, whose result waitErr is shared by Stop,
+	// Finish and readers that ask for the exit status.
+	waitOnce sync.Once
+	waitErr  error
+
+	// warnings counts stderr lines that report trouble; see isStreamWarning.
+	// stderrLine is the incomplete last line, used only by drainStderr.
+	warnings   atomic.Int64
+	stderrLine []byte
 }
 
 // startProcess launches an FFmpeg subprocess with the given arguments.
 // Stdout is available for reading via Read(). Stderr is drained into a
-// circular buffer accessible via LastStderr().
-func startProcess(ffmpegPath string, args []string) (*ffmpegProcess, error) {
+// circular buffer accessible via LastStderr(), and additionally teed to a
+// rotating log file in cfg.LogDir when set.
+func startProcess(cfg Config, args []string) (*ffmpegProcess, error) {
+	return launchProcess(cfg, args, false, 0)
+}
+
+// startEncodeProcess is like startProcess, but also connects a pipe to the
+// subprocess stdin (available via Write) for feeding it input data.
+func startEncodeProcess(cfg Config, args []string) (*ffmpegProcess, error) {
+	return launchProcess(cfg, args, true, 0)
+}
+
+// startInteractiveProcess is like startProcess, but also connects a pipe
+// to the subprocess stdin that carries FFmpeg's interactive commands; see
+// SendCommand. The arguments must not read media from stdin.
+func startInteractiveProcess(cfg Config, args []string) (*ffmpegProcess, error) {
+	p, err := launchProcess(cfg, args, true, 0)
+	if err != nil {
+		return nil, err
+	}
+	p.interactive = true
+	return p, nil
+}
+
+// muxPipesSupported reports whether startMuxProcess can pass extra input
+// pipes. Windows cannot pass extra descriptors to a child process.
+var muxPipesSupported = runtime.GOOS != "windows"
+
+// startMuxProcess is like startEncodeProcess, but additionally passes
+// extraInputs pipes to the subprocess as file descriptors 3, 4, ...
+// (FFmpeg inputs "pipe:3", "pipe:4", ...), writable via Input. Check
+// muxPipesSupported first.
+func startMuxProcess(cfg Config, args []string, extraInputs int) (*ffmpegProcess, error) {
+	if extraInputs > 0 && !muxPipesSupported {
+		return nil, errors.New("ffmpeg: extra input pipes are not supported on windows")
+	}
+	return launchProcess(cfg, args, true, extraInputs)
+}
+
+// chainArgsHooks returns a hook that runs first and then second; either
+// may be nil.
+func chainArgsHooks(first, second func([]string) []string) func([]string) []string {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	return func(args []string) []string { return second(first(args)) }
+}
+
+func launchProcess(cfg Config, args []string, withStdin bool, extraInputs int) (*ffmpegProcess, error) {
+	if cfg.ArgsHook != nil {
+		// The hook gets a copy, since callers keep args for restarts.
+		args = cfg.ArgsHook(slices.Clone(args))
+	}
 	ctx, cancel := context.WithCancel(context.Background())
-	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	cmd := exec.CommandContext(ctx, cfg.FFmpegPath, args...)
+
+	var inputs, childEnds []*os.File
+	closeAll := func(files []*os.File) {
+		for _, f := range files {
+			f.Close()
+		}
+	}
+	for i := 0; i < extraInputs; i++ {
+		pr, pw, err := os.Pipe()
+		if err != nil {
+			closeAll(inputs)
+			closeAll(childEnds)
+			cancel()
+			return nil, fmt.Errorf("ffmpeg input pipe: %w", err)
+		}
+		inputs = append(inputs, pw)
+		childEnds = append(childEnds, pr)
+	}
+	cmd.ExtraFiles = childEnds
+
+	var stdin io.WriteCloser
+	if withStdin {
+		var err error
+		if stdin, err = cmd.StdinPipe(); err != nil {
+			cancel()
+			return nil, fmt.Errorf("ffmpeg stdin pipe: %w", err)
+		}
+	}
 
 	stdout, err := cmd.StdoutPipe()
 	if err != nil {
@@ -40,7 +159,11 @@ func startProcess(ffmpegPath string, args []string) (*ffmpegProcess, error) {
 		return nil, fmt.Errorf("ffmpeg stderr pipe: %w", err)
 	}
 
-	if err := cmd.Start(); err != nil {
+	err = cmd.Start()
+	// The child has its own copies of the read ends now.
+	closeAll(childEnds)
+	if err != nil {
+		closeAll(inputs)
 		cancel()
 		return nil, fmt.Errorf("ffmpeg start: %w", err)
 	}
@@ -48,30 +171,62 @@ func startProcess(ffmpegPath string, args []string) (*ffmpegProcess, error) {
 	p := &ffmpegProcess{
 		cmd:    cmd,
 		stdout: stdout,
+		stdin:  stdin,
+		inputs: inputs,
 		cancel: cancel,
 		done:   make(chan struct{}),
+
+		stderrSize: cfg.StderrBufferSize,
+		stderrFeed: cfg.stderrFeed,
+		resources:  cfg.mediaDevices().resources,
+	}
+	if p.stderrSize <= 0 {
+		p.stderrSize = defaultStderrBufSize
+	}
+
+	// A log file that cannot be created must not prevent capture.
+	if lf, err := openProcessLog(cfg, cmd.Process.Pid, args); err != nil {
+		if cfg.Verbose {
+			log.Printf("ffmpeg: stderr log disabled: %v", err)
+		}
+	} else if lf != nil {
+		p.stderrLog = lf
 	}
 
-	// Drain stderr in background, keeping the last stderrBufSize bytes.
+	// Drain stderr in background, keeping the last stderrSize bytes.
 	go p.drainStderr(stderr)
 
+	p.resources.addProcess(p)
 	return p, nil
 }
 
 func (p *ffmpegProcess) drainStderr(r io.Reader) {
 	defer close(p.done)
+	if p.stderrLog != nil {
+		defer p.stderrLog.Close()
+	}
 	buf := make([]byte, 1024)
 	for {
 		n, err := r.Read(buf)
 		if n > 0 {
+			if p.stderrLog != nil {
+				p.stderrLog.Write(buf[:n])
+			}
 			p.stderrMu.Lock()
 			p.stderrBuf = append(p.stderrBuf, buf[:n]...)
-			if len(p.stderrBuf) > stderrBufSize {
-				p.stderrBuf = p.stderrBuf[len(p.stderrBuf)-stderrBufSize:]
+			if len(p.stderrBuf) > p.stderrSize {
+				p.stderrBuf = p.stderrBuf[len(p.stderrBuf)-p.stderrSize:]
+				p.stderrCut = true
 			}
 			p.stderrMu.Unlock()
+			p.scanStderr(buf[:n])
 		}
 		if err != nil {
+			if len(p.stderrLine) > 0 {
+				// The last line was not terminated.
+				p.stderrLineDone(string(p.stderrLine))
+				p.stderrLine = nil
+			}
 			return
 		}
 	}
@@ -82,12 +237,137 @@ func (p *ffmpegProcess) Read(buf []byte) (int, error) {
 	return p.stdout.Read(buf)
 }
 
+// Write writes input data to the FFmpeg subprocess stdin.
+func (p *ffmpegProcess) Write(data []byte) (int, error) {
+	return p.stdin.Write(data)
+}
+
+// Input returns the i-th extra input pipe (FFmpeg "pipe:<3+i>").
+func (p *ffmpegProcess) Input(i int) io.Writer {
+	return p.inputs[i]
+}
+
+// CloseInput closes stdin and the extra input pipes, signalling FFmpeg the
+// end of its input.
+func (p *ffmpegProcess) CloseInput() error {
+	var err error
+	for _, f := range p.inputs {
+		if cerr := f.Close(); cerr != nil && !errors.Is(cerr, os.ErrClosed) && err == nil {
+			err = cerr
+		}
+	}
+	if p.stdin == nil {
+		return err
+	}
+	if cerr := p.stdin.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Finish closes stdin so that FFmpeg drains its input, finalizes the output
+// and exits on its own. If it has not exited within timeout, it is killed.
+// Reads from stdout must be complete before Finish is called.
+func (p *ffmpegProcess) Finish(timeout time.Duration) error {
+	p.CloseInput()
+	select {
+	case <-p.done:
+	case <-time.After(timeout):
+		p.cancel()
+		<-p.done
+	}
+	err := p.wait()
+	p.cancel()
+	p.resources.removeProcess(p)
+	return err
+}
+
 // Stop terminates the FFmpeg subprocess.
 func (p *ffmpegProcess) Stop() error {
 	p.cancel()
+	if p.zmq != nil {
+		p.zmq.Close()
+	}
 	// Wait for stderr drain to finish so we capture final output.
 	<-p.done
-	return p.cmd.Wait()
+	err := p.wait()
+	p.CloseInput()
+	p.resources.removeProcess(p)
+	return err
+}
+
+// wait waits for the subprocess to exit and returns its exit status, like
+// exec.Cmd.Wait, which it calls only once.
+func (p *ffmpegProcess) wait() error {
+	p.waitOnce.Do(func() { p.waitErr = p.cmd.Wait() })
+	return p.waitErr
+}
+
+// endOfOutput reports whether err from reading stdout means that the output
+// ended: FFmpeg closed it, or Stop closed the pipe under a blocked read.
+func endOfOutput(err error) bool {
+	return err == io.EOF || err == io.ErrUnexpectedEOF || errors.Is(err, os.ErrClosed)
+}
+
+// streamEnd returns the error for the end of stdout, which what was
+// reading: io.EOF if FFmpeg exited with status 0, and otherwise a
+// *CaptureError for the exit status, classified from the stderr tail.
+func (p *ffmpegProcess) streamEnd(what string) error {
+	<-p.done
+	if err := p.wait(); err != nil {
+		return newCaptureError(fmt.Errorf("ffmpeg: %s: %w", what, err), p.LastStderr())
+	}
+	return io.EOF
+}
+
+// Suspend pauses the subprocess without terminating it, so that it keeps
+// its devices open but consumes no CPU.
+func (p *ffmpegProcess) Suspend() error {
+	return suspendProcess(p.cmd.Process.Pid)
+}
+
+// Resume continues a subprocess paused by Suspend.
+func (p *ffmpegProcess) Resume() error {
+	return resumeProcess(p.cmd.Process.Pid)
+}
+
+// scanStderr splits data into stderr lines, counting those that report
+// trouble and passing non-empty ones to the stderr feed. Progress lines end
+// in '\r' and are split there too.
+func (p *ffmpegProcess) scanStderr(data []byte) {
+	p.stderrLine = append(p.stderrLine, data...)
+	for {
+		i := bytes.IndexAny(p.stderrLine, "\r\n")
+		if i < 0 {
+			break
+		}
+		p.stderrLineDone(string(p.stderrLine[:i]))
+		p.stderrLine = p.stderrLine[i+1:]
+	}
+	limit := p.stderrSize
+	if limit <= 0 {
+		limit = defaultStderrBufSize
+	}
+	if len(p.stderrLine) > limit {
+		// An endless line; judge what there is and start over.
+		p.stderrLineDone(string(p.stderrLine))
+		p.stderrLine = nil
+	}
+}
+
+// stderrLineDone handles one complete stderr line.
+func (p *ffmpegProcess) stderrLineDone(line string) {
+	if isStreamWarning(line) {
+		p.warnings.Add(1)
+	}
+	if line != "" && p.stderrFeed != nil {
+		p.stderrFeed.publish(line)
+	}
+}
+
+// Warnings returns how many stderr lines reported trouble so far.
+func (p *ffmpegProcess) Warnings() int64 {
+	return p.warnings.Load()
 }
 
 // LastStderr returns the last portion of FFmpeg's stderr output,
@@ -97,3 +377,71 @@ func (p *ffmpegProcess) LastStderr() string {
 	defer p.stderrMu.Unlock()
 	return string(p.stderrBuf)
 }
+
+// StderrLines returns up to the last n non-empty lines of the stderr tail,
+// oldest first, or all of them if n <= 0. A line cut off at the front of
+// the tail is left out; the last line may still be incomplete.
+func (p *ffmpegProcess) StderrLines(n int) []string {
+	p.stderrMu.Lock()
+	tail := string(p.stderrBuf)
+	cut := p.stderrCut
+	p.stderrMu.Unlock()
+	return stderrTailLines(tail, cut, n)
+}
+
+// stderrTailLines splits a stderr tail into its last n non-empty lines.
+// If cut is set, the tail starts in the middle of a line, which is dropped.
+func stderrTailLines(tail string, cut bool, n int) []string {
+	if cut {
+		i := strings.IndexAny(tail, "\r\n")
+		if i < 0 {
+			return nil
+		}
+		tail = tail[i+1:]
+	}
+	lines := strings.FieldsFunc(tail, func(r rune) bool { return r == '\r' || r == '\n' })
+	if n > 0 && len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines
+}
+
+// stderrFeed fans complete stderr lines out to subscribers. A reader or
+// recorder owns one feed for all the processes it starts, so that
+// subscriptions survive restarts. The zero value is ready to use.
+type stderrFeed struct {
+	mu   sync.Mutex
+	subs map[int]func(string)
+	next int
+}
+
+// subscribe registers fn for every line published from now on and returns
+// a function that removes it.
+func (f *stderrFeed) subscribe(fn func(string)) (cancel func()) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subs == nil {
+		f.subs = make(map[int]func(string))
+	}
+	id := f.next
+	f.next++
+	f.subs[id] = fn
+	return func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		delete(f.subs, id)
+	}
+}
+
+// publish passes line to all subscribers, in the calling goroutine.
+func (f *stderrFeed) publish(line string) {
+	f.mu.Lock()
+	subs := make([]func(string), 0, len(f.subs))
+	for _, fn := range f.subs {
+		subs = append(subs, fn)
+	}
+	f.mu.Unlock()
+	for _, fn := range subs {
+		fn(line)
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package mediadevices
+
+import "syscall"
+
+// suspendProcess stops the process with the given pid (SIGSTOP).
+func suspendProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGSTOP)
+}
+
+// resumeProcess continues a process stopped by suspendProcess (SIGCONT).
+func resumeProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGCONT)
+}
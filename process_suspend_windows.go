@@ -0,0 +1,37 @@
+//go:build windows
+
+package mediadevices
+
+import (
+	"fmt"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	ntdll            = windows.NewLazySystemDLL("ntdll.dll")
+	ntSuspendProcess = ntdll.NewProc("NtSuspendProcess")
+	ntResumeProcess  = ntdll.NewProc("NtResumeProcess")
+)
+
+// suspendProcess suspends all threads of the process with the given pid.
+func suspendProcess(pid int) error {
+	return callProcessProc(ntSuspendProcess, pid)
+}
+
+// resumeProcess resumes a process suspended by suspendProcess.
+func resumeProcess(pid int) error {
+	return callProcessProc(ntResumeProcess, pid)
+}
+
+func callProcessProc(proc *windows.LazyProc, pid int) error {
+	h, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(pid))
+	if err != nil {
+		return fmt.Errorf("open process %d: %w", pid, err)
+	}
+	defer windows.CloseHandle(h)
+	if status, _, _ := proc.Call(uintptr(h)); status != 0 {
+		return fmt.Errorf("%s(%d): NTSTATUS 0x%08x", proc.Name, pid, status)
+	}
+	return nil
+}
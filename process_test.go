@@ -0,0 +1,227 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStartMuxProcess_ExtraInput(t *testing.T) {
+	// The child copies stdin, then fd 3, to stdout.
+	proc, err := startMuxProcess(Config{FFmpegPath: "/bin/sh"}, []string{"-c", "cat; cat <&3"}, 1)
+	if err != nil {
+		t.Fatalf("startMuxProcess: %v", err)
+	}
+	proc.Write([]byte("video|"))
+	proc.Input(0).Write([]byte("audio"))
+	proc.CloseInput()
+
+	out, err := io.ReadAll(proc)
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if string(out) != "video|audio" {
+		t.Errorf("output = %q, want %q", out, "video|audio")
+	}
+	if err := proc.Finish(time.Second); err != nil {
+		t.Errorf("Finish: %v", err)
+	}
+}
+
+func TestSendCommand(t *testing.T) {
+	// The child echoes the first command line it receives on stdin.
+	proc, err := startInteractiveProcess(Config{FFmpegPath: "/bin/sh"}, []string{"-c", "head -n 1"})
+	if err != nil {
+		t.Fatalf("startInteractiveProcess: %v", err)
+	}
+	defer proc.Stop()
+	if err := proc.SendCommand(FilterCommand{Target: "drawtext@title", Command: "reinit", Arg: "text='On air'"}); err != nil {
+		t.Fatalf("SendCommand: %v", err)
+	}
+	out, _ := io.ReadAll(proc)
+	if string(out) != "cdrawtext@title -1 reinit text='On air'\n" {
+		t.Errorf("stdin = %q", out)
+	}
+
+	enc, err := startEncodeProcess(Config{FFmpegPath: "/bin/sh"}, []string{"-c", "exit 0"})
+	if err != nil {
+		t.Fatalf("startEncodeProcess: %v", err)
+	}
+	defer enc.Stop()
+	if err := enc.SendCommand(FilterCommand{Target: "all", Command: "x"}); err != ErrCommandsUnsupported {
+		t.Errorf("SendCommand to encoder input = %v, want ErrCommandsUnsupported", err)
+	}
+}
+
+func TestStderrLines(t *testing.T) {
+	// 20 lines of 8 bytes and an unterminated "tail": the last 64 bytes
+	// start in the middle of line 13.
+	var got []string
+	feed := &stderrFeed{}
+	cancel := feed.subscribe(func(line string) { got = append(got, line) })
+	defer cancel()
+	cfg := Config{FFmpegPath: "/bin/sh", StderrBufferSize: 64, stderrFeed: feed}
+	proc, err := startProcess(cfg, []string{"-c", `for i in $(seq 1 20); do printf 'line %02d\n' $i >&2; done; printf '\rtail' >&2`})
+	if err != nil {
+		t.Fatalf("startProcess: %v", err)
+	}
+	<-proc.done
+	defer proc.Stop()
+
+	if s := proc.LastStderr(); len(s) != 64 {
+		t.Errorf("LastStderr() has %d bytes, want 64", len(s))
+	}
+	lines := proc.StderrLines(0)
+	if len(lines) != 8 || lines[0] != "line 14" || lines[7] != "tail" {
+		t.Errorf("StderrLines(0) = %q, want line 14 ... line 20, tail", lines)
+	}
+	if lines := proc.StderrLines(2); !slices.Equal(lines, []string{"line 20", "tail"}) {
+		t.Errorf("StderrLines(2) = %q", lines)
+	}
+
+	// The feed sees every line, not only those still in the buffer.
+	if len(got) != 21 || got[0] != "line 01" || got[20] != "tail" {
+		t.Errorf("feed got %d lines %q ... , want line 01 ... line 20, tail", len(got), got[:min(len(got), 2)])
+	}
+}
+
+func TestStderrTailLines(t *testing.T) {
+	tests := []struct {
+		tail string
+		cut  bool
+		n    int
+		want []string
+	}{
+		{"a\nb\n", false, 0, []string{"a", "b"}},
+		{"a\nb\n", true, 0, []string{"b"}},
+		{"frame=1\rframe=2\r\n\nerror\n", false, 2, []string{"frame=2", "error"}},
+		{"no newline", true, 0, nil},
+		{"", false, 5, nil},
+	}
+	for _, tt := range tests {
+		got := stderrTailLines(tt.tail, tt.cut, tt.n)
+		if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+			t.Errorf("stderrTailLines(%q, %v, %d) = %q, want %q", tt.tail, tt.cut, tt.n, got, tt.want)
+		}
+	}
+}
+
+// unreadAudioSource fails the test if it is read.
+type unreadAudioSource struct{ t *testing.T }
+
+func (s unreadAudioSource) Read() (*AudioChunk, error) {
+	s.t.Error("audio track read although the recorder cannot mux audio")
+	return nil, io.EOF
+}
+
+func (s unreadAudioSource) Close() error    { return nil }
+func (s unreadAudioSource) SampleRate() int { return 48000 }
+func (s unreadAudioSource) Channels() int   { return 1 }
+
+func TestMediaRecorder_AudioWithoutMuxPipes(t *testing.T) {
+	defer func(orig bool) { muxPipesSupported = orig }(muxPipesSupported)
+	muxPipesSupported = false
+	orig := GetConfig()
+	defer SetConfig(orig)
+	var args []string
+	SetConfig(Config{FFmpegPath: "/bin/sh", ArgsHook: func(a []string) []string {
+		args = a
+		return []string{"-c", "cat >/dev/null"}
+	}})
+
+	s := NewMediaStream()
+	s.AddTrack(newCustomTrack(MediaDeviceKindVideoInput, "video", &stampedVideoSource{start: time.Now(), step: 40 * time.Millisecond, n: 3}, nil))
+	time.Sleep(time.Microsecond) // distinct track IDs
+	s.AddTrack(newCustomTrack(MediaDeviceKindAudioInput, "audio", nil, unreadAudioSource{t}))
+	defer s.Close()
+
+	dir := t.TempDir()
+	r, err := NewMediaRecorder(s, MediaRecorderOptions{Path: filepath.Join(dir, "out.mkv")})
+	if err != nil {
+		t.Fatalf("NewMediaRecorder: %v", err)
+	}
+	if err := r.Start(); err == nil {
+		r.Stop()
+		t.Fatal("Start recorded a stream with audio without mux pipes")
+	}
+
+	// Video only must be asked for.
+	r, err = NewMediaRecorder(s, MediaRecorderOptions{Path: filepath.Join(dir, "video.mkv"), DisableAudio: true})
+	if err != nil {
+		t.Fatalf("NewMediaRecorder: %v", err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start with DisableAudio: %v", err)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	if slices.Contains(args, "pipe:3") {
+		t.Errorf("encoder args %q read audio from pipe:3", args)
+	}
+}
+
+func TestMediaDevices_InstanceConfigUsed(t *testing.T) {
+	// The default instance cannot start FFmpeg; everything below runs with md.
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{FFmpegPath: "/nonexistent/ffmpeg"})
+	var mu sync.Mutex
+	var runs [][]string
+	md := NewMediaDevices(Config{FFmpegPath: "/bin/sh", ArgsHook: func(a []string) []string {
+		mu.Lock()
+		runs = append(runs, a)
+		mu.Unlock()
+		return []string{"-c", "cat >/dev/null"}
+	}})
+	dir := t.TempDir()
+
+	s := NewMediaStream()
+	s.AddTrack(md.newCustomTrack(MediaDeviceKindVideoInput, "video", &stampedVideoSource{start: time.Now(), step: 40 * time.Millisecond, n: 3}, nil))
+	defer s.Close()
+	r, err := NewMediaRecorder(s, MediaRecorderOptions{Path: filepath.Join(dir, "out.mkv")})
+	if err != nil {
+		t.Fatalf("NewMediaRecorder: %v", err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if got := md.ActiveResources().Recorders; got != 1 {
+		t.Errorf("md has %d recorders, want 1", got)
+	}
+	if got := ActiveResources().Recorders; got != 0 {
+		t.Errorf("default instance has %d recorders, want 0", got)
+	}
+	if err := r.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	w, err := md.CreateAudioFile(filepath.Join(dir, "out.flac"), 48000, 1)
+	if err != nil {
+		t.Fatalf("CreateAudioFile: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	rec := filepath.Join(dir, "rec.mkv")
+	if err := os.WriteFile(rec, []byte("recording"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := md.ExtractClip(rec, 0, time.Second, filepath.Join(dir, "clip.mkv")); err != nil {
+		t.Fatalf("ExtractClip: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(runs) != 3 {
+		t.Errorf("md ran FFmpeg %d times, want 3 (recorder, audio file, clip)", len(runs))
+	}
+}
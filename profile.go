@@ -0,0 +1,125 @@
+package mediadevices
+
+import (
+	"fmt"
+	"time"
+)
+
+// LatencyProfile names a bundle of capture and encoder settings that trades
+// end-to-end latency against quality and robustness, so applications do not
+// need to tune x264 and FFmpeg input buffering themselves.
+//
+// A profile only fills in settings that are left at their zero value;
+// explicitly configured fields always take precedence.
+type LatencyProfile string
+
+const (
+	// ProfileRealtime minimizes latency for interactive use (video calls,
+	// remote control): no input buffering, fastest encoder preset, short GOP
+	// and a small rate-control buffer.
+	ProfileRealtime LatencyProfile = "realtime"
+	// ProfileBalanced suits live streaming to viewers: modest buffering and
+	// a faster-than-default preset with low-latency tuning.
+	ProfileBalanced LatencyProfile = "balanced"
+	// ProfileArchive favors quality and drop-free capture for recording:
+	// deep input buffers, a slower preset, B-frames and a long GOP.
+	ProfileArchive LatencyProfile = "archive"
+)
+
+// profileSettings are the concrete settings bundled by a LatencyProfile.
+type profileSettings struct {
+	// Encoder
+	preset     string
+	gopSeconds float64 // keyframe interval
+	bFrames    int
+	rcBuffer   float64 // rate-control buffer (-bufsize) in seconds of BitRate
+
+	// Capture
+	threadQueueSize int  // packets buffered between input demuxer and pipeline
+	noBuffer        bool // disable input probing buffers (-fflags nobuffer)
+	audioChunk      time.Duration
+}
+
+var latencyProfiles = map[LatencyProfile]profileSettings{
+	ProfileRealtime: {
+		preset:          "ultrafast",
+		gopSeconds:      1,
+		rcBuffer:        0.5,
+		threadQueueSize: 8,
+		noBuffer:        true,
+		audioChunk:      10 * time.Millisecond,
+	},
+	ProfileBalanced: {
+		preset:          "veryfast",
+		gopSeconds:      2,
+		rcBuffer:        1,
+		threadQueueSize: 64,
+		audioChunk:      20 * time.Millisecond,
+	},
+	ProfileArchive: {
+		preset:          "medium",
+		gopSeconds:      4,
+		bFrames:         3,
+		rcBuffer:        2,
+		threadQueueSize: 512,
+		audioChunk:      40 * time.Millisecond,
+	},
+}
+
+// settings returns the settings of p. The empty profile has no settings.
+func (p LatencyProfile) settings() (profileSettings, error) {
+	if p == "" {
+		return profileSettings{}, nil
+	}
+	s, ok := latencyProfiles[p]
+	if !ok {
+		return profileSettings{}, fmt.Errorf("unknown latency profile %q (want %q, %q or %q)", p, ProfileRealtime, ProfileBalanced, ProfileArchive)
+	}
+	return s, nil
+}
+
+// profileInputArgs returns the FFmpeg input options (placed before -i) that
+// control capture buffering for profile p.
+func profileInputArgs(p LatencyProfile) []string {
+	s, err := p.settings()
+	if err != nil || p == "" {
+		return nil
+	}
+	var args []string
+	if s.noBuffer {
+		args = append(args, "-fflags", "nobuffer")
+	}
+	args = append(args, "-thread_queue_size", fmt.Sprintf("%d", s.threadQueueSize))
+	return args
+}
+
+// applyProfile returns cfg with the zero-valued encoder settings filled in
+// from its latency profile, or from profile, the Config.LatencyProfile of the
+// MediaDevices opening the reader, if it has none.
+func (cfg H264ReaderConfig) applyProfile(profile LatencyProfile) (H264ReaderConfig, error) {
+	if cfg.LatencyProfile == "" {
+		cfg.LatencyProfile = profile
+	}
+	s, err := cfg.LatencyProfile.settings()
+	if err != nil || cfg.LatencyProfile == "" {
+		return cfg, err
+	}
+
+	if cfg.Preset == "" {
+		cfg.Preset = s.preset
+	}
+	if cfg.KeyInterval == 0 {
+		frameRate := cfg.FrameRate
+		if frameRate <= 0 {
+			frameRate = 30
+		}
+		cfg.KeyInterval = int(frameRate*s.gopSeconds + 0.5)
+	}
+	if cfg.BFrames == 0 {
+		cfg.BFrames = s.bFrames
+	}
+	if cfg.BufferSize == 0 && cfg.BitRate > 0 {
+		cfg.BufferSize = int(float64(cfg.BitRate)*s.rcBuffer + 0.5)
+	}
+	return cfg, nil
+}
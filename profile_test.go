@@ -0,0 +1,45 @@
+package mediadevices
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestH264ReaderConfig_ApplyProfile(t *testing.T) {
+	cfg, err := H264ReaderConfig{
+		FrameRate:      25,
+		BitRate:        2000,
+		LatencyProfile: ProfileArchive,
+	}.applyProfile("")
+	if err != nil {
+		t.Fatalf("applyProfile: %v", err)
+	}
+	if cfg.Preset != "medium" || cfg.KeyInterval != 100 || cfg.BFrames != 3 || cfg.BufferSize != 4000 {
+		t.Errorf("archive settings = preset %q, GOP %d, B-frames %d, bufsize %d; want medium, 100, 3, 4000",
+			cfg.Preset, cfg.KeyInterval, cfg.BFrames, cfg.BufferSize)
+	}
+
+	// Explicit settings win over the profile.
+	cfg, _ = H264ReaderConfig{Preset: "fast", KeyInterval: 10, LatencyProfile: ProfileRealtime}.applyProfile("")
+	if cfg.Preset != "fast" || cfg.KeyInterval != 10 {
+		t.Errorf("explicit settings overridden: preset %q, GOP %d", cfg.Preset, cfg.KeyInterval)
+	}
+
+	if _, err := (H264ReaderConfig{LatencyProfile: "turbo"}).applyProfile(""); err == nil {
+		t.Error("unknown profile accepted")
+	}
+}
+
+func TestProfileInputArgs(t *testing.T) {
+	if args := profileInputArgs(""); args != nil {
+		t.Errorf("no profile: got %v, want none", args)
+	}
+	got := strings.Join(profileInputArgs(ProfileRealtime), " ")
+	if got != "-fflags nobuffer -thread_queue_size 8" {
+		t.Errorf("realtime input args = %q", got)
+	}
+	args := strings.Join(buildH264Args(H264ReaderConfig{DeviceName: "cam", LatencyProfile: ProfileArchive, BFrames: 3}), " ")
+	if strings.Contains(args, "zerolatency") || strings.Contains(args, "-force_key_frames") {
+		t.Errorf("archive encoder args should not force low latency: %s", args)
+	}
+}
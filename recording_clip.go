@@ -0,0 +1,190 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ClipOptions 配置 ExtractClipContext。
+type ClipOptions struct {
+	// Precise 为 true 时片段精确地从 start 开始：start 到其后第一个关键帧之间的画面
+	// 重新编码为 H.264，其余部分仍直接复制。默认从 start 处或之前最近的关键帧开始复制，
+	// 片段可能比请求的早最多一个关键帧间隔，但不需要编码。
+	Precise bool
+	// Preset 重新编码时使用的 x264 预设，默认 "veryfast"。
+	Preset string
+}
+
+// ExtractClip 从录制文件 recording 中提取 start 到 end 之间的片段写入 dest，
+// 容器格式由 dest 的扩展名决定。片段从 start 处或之前最近的关键帧开始，
+// 音视频直接复制而不重新编码，即使很长的录制也能很快完成。
+//
+// 等同于 ExtractClipContext(context.Background(), recording, start, end, dest, ClipOptions{})。
+func ExtractClip(recording string, start, end time.Duration, dest string) error {
+	return defaultMediaDevices.ExtractClipContext(context.Background(), recording, start, end, dest, ClipOptions{})
+}
+
+// ExtractClip 与包级函数 ExtractClip 相同，但使用 m 的配置运行 FFmpeg。
+func (m *MediaDevices) ExtractClip(recording string, start, end time.Duration, dest string) error {
+	return m.ExtractClipContext(context.Background(), recording, start, end, dest, ClipOptions{})
+}
+
+// ExtractClipContext 与 ExtractClip 相同，但 FFmpeg 的运行受 ctx 控制，
+// 并可用 opts 要求精确剪切。
+//
+// 录制有关键帧索引（见 MediaRecorderOptions.Index）时，由索引确定起始关键帧，
+// 精确剪切只需重新编码开头不足一个关键帧间隔的部分；没有索引时由 FFmpeg 定位关键帧，
+// 精确剪切需重新编码整个片段。EncryptionAESGCM 加密的录制需先用 NewDecryptingReader 解密。
+func ExtractClipContext(ctx context.Context, recording string, start, end time.Duration, dest string, opts ClipOptions) error {
+	return defaultMediaDevices.ExtractClipContext(ctx, recording, start, end, dest, opts)
+}
+
+// ExtractClipContext 与包级函数 ExtractClipContext 相同，但使用 m 的配置运行 FFmpeg。
+func (m *MediaDevices) ExtractClipContext(ctx context.Context, recording string, start, end time.Duration, dest string, opts ClipOptions) error {
+	if start < 0 || end <= start {
+		return fmt.Errorf("extract clip: invalid range %v-%v", start, end)
+	}
+	if err := checkUnencrypted(recording); err != nil {
+		return fmt.Errorf("extract clip: %w", err)
+	}
+	idx, err := ReadRecordingIndex(recording)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("extract clip: %w", err)
+	}
+
+	cfg := m.Config()
+	plan := planClip(idx, start, end, opts.Precise)
+	if plan.copyFrom < 0 || plan.copyFrom == plan.encodeFrom {
+		// 整个片段直接复制，或整个片段重新编码
+		args := clipArgs(recording, plan.encodeFrom, end, dest, opts, plan.copyFrom < 0)
+		return runClipProcess(ctx, cfg, args)
+	}
+
+	// 开头重新编码、其余复制，两部分写入 MPEG-TS 临时文件后无损拼接
+	tmp, err := os.MkdirTemp(filepath.Dir(dest), ".clip-*")
+	if err != nil {
+		return fmt.Errorf("extract clip: %w", err)
+	}
+	defer os.RemoveAll(tmp)
+	head := filepath.Join(tmp, "head.ts")
+	tail := filepath.Join(tmp, "tail.ts")
+	list := filepath.Join(tmp, "list.txt")
+	if err := runClipProcess(ctx, cfg, clipArgs(recording, plan.encodeFrom, plan.copyFrom, head, opts, true)); err != nil {
+		return err
+	}
+	if err := runClipProcess(ctx, cfg, clipArgs(recording, plan.copyFrom, end, tail, opts, false)); err != nil {
+		return err
+	}
+	if err := os.WriteFile(list, []byte(concatList(head, tail)), 0o644); err != nil {
+		return fmt.Errorf("extract clip: %w", err)
+	}
+	return runClipProcess(ctx, cfg, []string{"-y", "-f", "concat", "-safe", "0", "-i", list, "-c", "copy", dest})
+}
+
+// clipPlan 描述片段的剪切方式：[encodeFrom, copyFrom) 重新编码，[copyFrom, end) 直接复制。
+// 两者相等时整个片段直接复制；copyFrom 为负时整个片段从 encodeFrom 开始重新编码。
+type clipPlan struct {
+	encodeFrom time.Duration
+	copyFrom   time.Duration
+}
+
+// planClip 根据关键帧索引 idx（可为 nil）决定如何剪切 [start, end)。
+func planClip(idx *RecordingIndex, start, end time.Duration, precise bool) clipPlan {
+	var kf KeyframeEntry
+	var ok bool
+	if idx != nil {
+		kf, ok = idx.KeyframeAt(start)
+	}
+	switch {
+	case !ok && precise:
+		return clipPlan{encodeFrom: start, copyFrom: -1}
+	case !ok:
+		// 没有索引：由 FFmpeg 寻找 start 之前的关键帧
+		return clipPlan{encodeFrom: start, copyFrom: start}
+	case !precise || kf.Time >= start:
+		return clipPlan{encodeFrom: kf.Time, copyFrom: kf.Time}
+	}
+	for _, k := range idx.Keyframes {
+		if k.Time > start {
+			if k.Time >= end {
+				break
+			}
+			return clipPlan{encodeFrom: start, copyFrom: k.Time}
+		}
+	}
+	// 片段内没有关键帧，只能整体重新编码
+	return clipPlan{encodeFrom: start, copyFrom: -1}
+}
+
+// clipArgs 构建提取 recording 中 [from, to) 写入 dest 的 FFmpeg 参数。
+// encode 为 true 时视频重新编码为 H.264，否则直接复制；音频总是直接复制。
+func clipArgs(recording string, from, to time.Duration, dest string, opts ClipOptions, encode bool) []string {
+	args := []string{"-y",
+		"-ss", strconv.FormatFloat(from.Seconds(), 'f', -1, 64),
+		"-i", recording,
+		"-t", strconv.FormatFloat((to - from).Seconds(), 'f', -1, 64),
+		"-map", "0:v?", "-map", "0:a?",
+	}
+	if encode {
+		preset := opts.Preset
+		if preset == "" {
+			preset = "veryfast"
+		}
+		args = append(args, "-c:v", "libx264", "-preset", preset, "-pix_fmt", "yuv420p", "-c:a", "copy")
+	} else {
+		args = append(args, "-c", "copy", "-avoid_negative_ts", "make_zero")
+	}
+	return append(args, dest)
+}
+
+// concatList 返回 FFmpeg concat 分离器依次读取 files 的列表文件内容。
+func concatList(files ...string) string {
+	var b strings.Builder
+	for _, f := range files {
+		fmt.Fprintf(&b, "file '%s'\n", strings.ReplaceAll(f, "'", `'\''`))
+	}
+	return b.String()
+}
+
+// checkUnencrypted 确认 recording 不是 Go 侧加密的录制，FFmpeg 无法直接读取后者。
+func checkUnencrypted(recording string) error {
+	f, err := os.Open(recording)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(f, magic); err == nil && string(magic) == encryptedMagic {
+		return errors.New("recording is encrypted; decrypt it with NewDecryptingReader first")
+	}
+	return nil
+}
+
+// runClipProcess 以 cfg 运行一次 FFmpeg 并等待其退出；ctx 结束时终止它。
+func runClipProcess(ctx context.Context, cfg Config, args []string) error {
+	proc, err := startProcess(cfg, args)
+	if err != nil {
+		return fmt.Errorf("extract clip: %w", err)
+	}
+	select {
+	case <-proc.done:
+	case <-ctx.Done():
+		proc.Stop()
+		return fmt.Errorf("extract clip: %w", ctx.Err())
+	}
+	err = proc.wait()
+	proc.cancel()
+	proc.resources.removeProcess(proc)
+	if err != nil {
+		return newCaptureError(fmt.Errorf("extract clip: %w", err), proc.LastStderr())
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPlanClip(t *testing.T) {
+	idx := &RecordingIndex{Keyframes: []KeyframeEntry{
+		{Offset: 0, Time: 0},
+		{Offset: 1000, Time: 2 * time.Second},
+		{Offset: 2000, Time: 4 * time.Second},
+	}}
+	for _, tc := range []struct {
+		name       string
+		idx        *RecordingIndex
+		start, end time.Duration
+		precise    bool
+		want       clipPlan
+	}{
+		{"copy from previous keyframe", idx, 3 * time.Second, 5 * time.Second, false, clipPlan{2 * time.Second, 2 * time.Second}},
+		{"precise on keyframe", idx, 2 * time.Second, 5 * time.Second, true, clipPlan{2 * time.Second, 2 * time.Second}},
+		{"precise head", idx, 3 * time.Second, 5 * time.Second, true, clipPlan{3 * time.Second, 4 * time.Second}},
+		{"precise without keyframe in range", idx, 2500 * time.Millisecond, 3500 * time.Millisecond, true, clipPlan{2500 * time.Millisecond, -1}},
+		{"no index", nil, 3 * time.Second, 5 * time.Second, false, clipPlan{3 * time.Second, 3 * time.Second}},
+		{"precise without index", nil, 3 * time.Second, 5 * time.Second, true, clipPlan{3 * time.Second, -1}},
+	} {
+		if got := planClip(tc.idx, tc.start, tc.end, tc.precise); got != tc.want {
+			t.Errorf("%s: plan = %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestExtractClip(t *testing.T) {
+	dir := t.TempDir()
+	rec := filepath.Join(dir, "rec.mkv")
+	if err := os.WriteFile(rec, []byte("recording"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	idx := &RecordingIndex{Version: recordingIndexVersion, Format: "matroska", Keyframes: []KeyframeEntry{
+		{Offset: 0, Time: 0},
+		{Offset: 1000, Time: 2 * time.Second},
+		{Offset: 2000, Time: 4 * time.Second},
+	}}
+	if err := writeRecordingIndex(rec, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	orig := GetConfig()
+	defer SetConfig(orig)
+	var mu sync.Mutex
+	var runs [][]string
+	cfg := orig
+	cfg.FFmpegPath = "/bin/sh"
+	cfg.ArgsHook = func(args []string) []string {
+		mu.Lock()
+		runs = append(runs, args)
+		mu.Unlock()
+		if slices.Contains(args, "concat") {
+			list, _ := os.ReadFile(args[slices.Index(args, "-i")+1])
+			if n := strings.Count(string(list), "file '"); n != 2 {
+				t.Errorf("concat list has %d files:\n%s", n, list)
+			}
+		}
+		return []string{"-c", "exit 0"}
+	}
+	SetConfig(cfg)
+
+	clip := filepath.Join(dir, "clip.mp4")
+	if err := ExtractClip(rec, 3*time.Second, 5*time.Second, clip); err != nil {
+		t.Fatalf("ExtractClip: %v", err)
+	}
+	want := []string{"-y", "-ss", "2", "-i", rec, "-t", "3", "-map", "0:v?", "-map", "0:a?", "-c", "copy", "-avoid_negative_ts", "make_zero", clip}
+	if len(runs) != 1 || !slices.Equal(runs[0], want) {
+		t.Fatalf("runs = %q, want %q", runs, want)
+	}
+
+	// Precise: the head up to the keyframe at 4s is encoded, the rest copied.
+	runs = nil
+	if err := ExtractClipContext(context.Background(), rec, 3*time.Second, 5*time.Second, clip, ClipOptions{Precise: true}); err != nil {
+		t.Fatalf("precise ExtractClip: %v", err)
+	}
+	if len(runs) != 3 {
+		t.Fatalf("%d FFmpeg runs, want 3: %q", len(runs), runs)
+	}
+	if !slices.Contains(runs[0], "libx264") || runs[0][2] != "3" || runs[0][6] != "1" {
+		t.Errorf("head = %q", runs[0])
+	}
+	if !slices.Contains(runs[1], "copy") || runs[1][2] != "4" || runs[1][6] != "1" {
+		t.Errorf("tail = %q", runs[1])
+	}
+	if runs[2][len(runs[2])-1] != clip {
+		t.Errorf("concat = %q", runs[2])
+	}
+	if matches, _ := filepath.Glob(filepath.Join(dir, ".clip-*")); len(matches) != 0 {
+		t.Errorf("temporary files left: %v", matches)
+	}
+
+	if err := ExtractClip(rec, 5*time.Second, 3*time.Second, clip); err == nil {
+		t.Error("reversed range accepted")
+	}
+	if err := os.WriteFile(rec, []byte(encryptedMagic+"rest"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExtractClip(rec, 0, time.Second, clip); err == nil || !strings.Contains(err.Error(), "encrypted") {
+		t.Errorf("encrypted recording: err = %v", err)
+	}
+}
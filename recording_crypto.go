@@ -0,0 +1,273 @@
+package mediadevices
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RecordingEncryptionScheme 选择录制文件的加密方式。
+type RecordingEncryptionScheme string
+
+const (
+	// EncryptionCENC 使用 FFmpeg 的 Common Encryption（cenc-aes-ctr）加密 MP4 中的媒体样本。
+	// 容器结构保持可读，支持 CENC 的播放器持有密钥即可直接播放。
+	// 仅适用于 .mp4 输出，要求 16 字节密钥和 16 字节密钥 ID。
+	EncryptionCENC RecordingEncryptionScheme = "cenc"
+	// EncryptionAESGCM 在 Go 侧以 AES-GCM 分块加密整个输出文件（任意容器），
+	// 同时提供完整性校验和截断检测。使用 NewDecryptingReader 解密。
+	EncryptionAESGCM RecordingEncryptionScheme = "aes-gcm"
+)
+
+// RecordingKeyProvider 是录制加密的密钥管理钩子，
+// 可对接 KMS、硬件密钥库或应用自己的密钥存储。
+type RecordingKeyProvider interface {
+	// NewRecordingKey 为即将写入 path 的录制返回数据密钥及其 ID。
+	// 密钥长度为 16、24 或 32 字节（AES-128/192/256）；密钥 ID 最长 255 字节，
+	// 会以明文形式写入文件，用于解密时查找密钥。
+	NewRecordingKey(path string) (key, keyID []byte, err error)
+	// RecordingKey 返回 keyID 对应的密钥，用于解密。
+	RecordingKey(keyID []byte) ([]byte, error)
+}
+
+// RecordingEncryption 配置录制文件加密。
+type RecordingEncryption struct {
+	// Scheme 加密方式，默认 EncryptionAESGCM。
+	Scheme RecordingEncryptionScheme
+	// Keys 提供每个录制文件的密钥。
+	Keys RecordingKeyProvider
+}
+
+// StaticRecordingKey 返回一个对所有录制使用同一密钥的 RecordingKeyProvider。
+func StaticRecordingKey(key, keyID []byte) RecordingKeyProvider {
+	return staticKey{key: key, keyID: keyID}
+}
+
+type staticKey struct {
+	key, keyID []byte
+}
+
+func (k staticKey) NewRecordingKey(string) ([]byte, []byte, error) {
+	return k.key, k.keyID, nil
+}
+
+func (k staticKey) RecordingKey(keyID []byte) ([]byte, error) {
+	if !bytes.Equal(keyID, k.keyID) {
+		return nil, fmt.Errorf("unknown recording key ID %x", keyID)
+	}
+	return k.key, nil
+}
+
+// AES-GCM 加密文件格式：
+//
+//	header: "MDAE" | version(1) | keyIDLen(1) | keyID | salt(32)
+//	chunk:  flags(1) | ciphertextLen(4, big endian) | ciphertext
+//
+// 块以文件密钥加密，文件密钥由数据密钥与随机 salt 经 HKDF-SHA256 派生，
+// 因此即使 StaticRecordingKey 对所有文件使用同一数据密钥，各文件的 GCM 密钥也互不相同。
+// 每个块的 nonce 为 4 字节零加 8 字节块序号，附加认证数据为 flags 与块序号，
+// 因此块的重排、删除都会导致认证失败；最后一个块带 chunkFinal 标志，用于检测截断。
+const (
+	encryptedMagic   = "MDAE"
+	encryptedVersion = 1
+	encryptSaltSize  = 32
+	encryptChunkSize = 64 << 10
+	chunkFinal       = 0x01
+	fileKeyInfo      = "mediadevices-ffmpeg recording file key"
+)
+
+// ErrRecordingTruncated 表示加密录制文件在最后一个块之前结束，
+// 通常是录制过程中断电或进程崩溃所致。已读出的数据均已通过认证。
+var ErrRecordingTruncated = errors.New("encrypted recording is truncated")
+
+// encryptingWriter 将写入的数据按块加密后写入底层 Writer。
+type encryptingWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	index  uint64
+	buf    []byte
+	closed bool
+}
+
+// newEncryptingWriter 写入文件头并返回加密 Writer。Close 写入最后一个块，
+// 但不关闭底层 Writer。
+func newEncryptingWriter(w io.Writer, key, keyID []byte) (*encryptingWriter, error) {
+	if len(keyID) > 255 {
+		return nil, fmt.Errorf("recording key ID too long (%d bytes)", len(keyID))
+	}
+	salt := make([]byte, encryptSaltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("generate salt: %w", err)
+	}
+	aead, err := newFileGCM(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	ew := &encryptingWriter{w: w, aead: aead, buf: make([]byte, 0, encryptChunkSize)}
+
+	header := append([]byte(encryptedMagic), encryptedVersion, byte(len(keyID)))
+	header = append(header, keyID...)
+	header = append(header, salt...)
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return ew, nil
+}
+
+// newFileGCM 返回以 key 和 salt 派生的文件密钥加密的 AES-GCM，文件密钥与 key 等长。
+func newFileGCM(key, salt []byte) (cipher.AEAD, error) {
+	fileKey, err := hkdf.Key(sha256.New, key, salt, fileKeyInfo, len(key))
+	if err != nil {
+		return nil, fmt.Errorf("recording key: %w", err)
+	}
+	block, err := aes.NewCipher(fileKey)
+	if err != nil {
+		return nil, fmt.Errorf("recording key: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+func (w *encryptingWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypting writer")
+	}
+	n := 0
+	for len(p) > 0 {
+		c := copy(w.buf[len(w.buf):cap(w.buf)], p)
+		w.buf = w.buf[:len(w.buf)+c]
+		p = p[c:]
+		n += c
+		if len(w.buf) == cap(w.buf) {
+			if err := w.flush(0); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close 加密剩余数据并写入最后一个块。
+func (w *encryptingWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(chunkFinal)
+}
+
+func (w *encryptingWriter) flush(flags byte) error {
+	nonce, ad := chunkNonce(w.index, flags)
+	sealed := w.aead.Seal(nil, nonce, w.buf, ad)
+	w.index++
+	w.buf = w.buf[:0]
+
+	var hdr [5]byte
+	hdr[0] = flags
+	binary.BigEndian.PutUint32(hdr[1:], uint32(len(sealed)))
+	if _, err := w.w.Write(hdr[:]); err != nil {
+		return err
+	}
+	_, err := w.w.Write(sealed)
+	return err
+}
+
+func chunkNonce(index uint64, flags byte) (nonce, ad []byte) {
+	nonce = make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce[4:], index)
+	ad = make([]byte, 9)
+	ad[0] = flags
+	binary.BigEndian.PutUint64(ad[1:], index)
+	return nonce, ad
+}
+
+// NewDecryptingReader 返回一个解密 EncryptionAESGCM 录制文件的 Reader。
+// 密钥通过文件头中的密钥 ID 从 keys 获取。
+// 数据块被篡改时 Read 返回错误；文件不完整时，在读完所有完整块后返回 ErrRecordingTruncated。
+func NewDecryptingReader(r io.Reader, keys RecordingKeyProvider) (io.Reader, error) {
+	var head [6]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return nil, fmt.Errorf("read encrypted recording header: %w", err)
+	}
+	if string(head[:4]) != encryptedMagic {
+		return nil, errors.New("not an encrypted recording")
+	}
+	if head[4] != encryptedVersion {
+		return nil, fmt.Errorf("unsupported encrypted recording version %d", head[4])
+	}
+	rest := make([]byte, int(head[5])+encryptSaltSize)
+	if _, err := io.ReadFull(r, rest); err != nil {
+		return nil, fmt.Errorf("read encrypted recording header: %w", err)
+	}
+	keyID := rest[:head[5]]
+
+	key, err := keys.RecordingKey(keyID)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := newFileGCM(key, rest[head[5]:])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptingReader{r: r, aead: aead}, nil
+}
+
+type decryptingReader struct {
+	r     io.Reader
+	aead  cipher.AEAD
+	index uint64
+	buf   []byte
+	final bool
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.final {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+// next 读取并解密下一个块。
+func (d *decryptingReader) next() error {
+	var hdr [5]byte
+	if _, err := io.ReadFull(d.r, hdr[:]); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrRecordingTruncated
+		}
+		return err
+	}
+	size := binary.BigEndian.Uint32(hdr[1:])
+	if size > encryptChunkSize+uint32(d.aead.Overhead()) {
+		return fmt.Errorf("encrypted recording chunk %d too large (%d bytes)", d.index, size)
+	}
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.r, sealed); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return ErrRecordingTruncated
+		}
+		return err
+	}
+
+	nonce, ad := chunkNonce(d.index, hdr[0])
+	plain, err := d.aead.Open(sealed[:0], nonce, sealed, ad)
+	if err != nil {
+		return fmt.Errorf("encrypted recording chunk %d failed authentication", d.index)
+	}
+	d.index++
+	d.buf = plain
+	d.final = hdr[0]&chunkFinal != 0
+	return nil
+}
@@ -0,0 +1,159 @@
+package mediadevices
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// RecordingSegment 是录制哈希链中的一段：文件中从 Offset 开始、长 Size 字节的数据。
+// 段在关键帧处切分，因此校验失败时可以定位到被修改的时间范围。
+type RecordingSegment struct {
+	// Offset 段在文件中的字节偏移。
+	Offset int64 `json:"offset"`
+	// Size 段的字节数。
+	Size int64 `json:"size"`
+	// Time 段开始处或之前最近的关键帧相对于录制开始的时间。
+	Time time.Duration `json:"time_ns"`
+	// SHA256 段数据的 SHA-256（十六进制）。
+	SHA256 string `json:"sha256"`
+	// Chain 是 SHA-256(前一段的 Chain || 本段的 SHA256)，均为原始字节；
+	// 第一段的前值为 32 个零字节。任一段被修改、删除或调换都会改变其后所有的 Chain。
+	Chain string `json:"chain"`
+}
+
+// RecordingTamperedError 表示录制文件与索引中的哈希链不一致：
+// 从第 Segment 段起数据被修改或截断，Segment 等于段数时表示文件末尾被追加了数据。
+type RecordingTamperedError struct {
+	Segment int
+	Offset  int64
+	Time    time.Duration
+}
+
+func (e *RecordingTamperedError) Error() string {
+	return fmt.Sprintf("recording modified in segment %d (offset %d, time %v)", e.Segment, e.Offset, e.Time)
+}
+
+// ErrRecordingNotHashed 表示录制索引中没有哈希链（录制时未设置 MediaRecorderOptions.Hash）。
+var ErrRecordingNotHashed = errors.New("recording index has no hash chain")
+
+// maxHashSegment 是哈希段的最大字节数。关键帧位置要在其后的数据写入后才能确定，
+// 尚未切分的数据暂存在内存中；超过该大小（如关键帧间隔很长或索引已停止）时强制切分。
+const maxHashSegment = 64 << 20
+
+// VerifyRecording 按索引边车文件中的哈希链校验录制文件 path 是否未被修改，
+// 不一致时返回 *RecordingTamperedError。
+//
+// 哈希链只能证明文件与边车文件一致：能同时改写两者的人也能重算哈希链。
+// 因此应将 RecordingIndex.Digest 另行保存（例如签名、上传或写入审计日志），
+// 校验前与边车文件中的值比较。
+//
+// Go 侧加密（EncryptionAESGCM）的录制按解密后的数据计算哈希，
+// 应使用 RecordingIndex.Verify 校验 NewDecryptingReader 的输出。
+func VerifyRecording(path string) error {
+	idx, err := ReadRecordingIndex(path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return idx.Verify(f)
+}
+
+// Verify 按哈希链校验 r 中的录制数据，不一致时返回 *RecordingTamperedError。
+func (idx *RecordingIndex) Verify(r io.Reader) error {
+	if len(idx.Segments) == 0 {
+		return ErrRecordingNotHashed
+	}
+	var chain [sha256.Size]byte
+	var off int64
+	for i, s := range idx.Segments {
+		if s.Offset != off || s.Size <= 0 {
+			return fmt.Errorf("recording index: segment %d does not follow the previous one", i)
+		}
+		h := sha256.New()
+		if _, err := io.CopyN(h, r, s.Size); err != nil {
+			if err == io.EOF {
+				return &RecordingTamperedError{Segment: i, Offset: s.Offset, Time: s.Time}
+			}
+			return err
+		}
+		sum := h.Sum(nil)
+		chain = sha256.Sum256(append(chain[:], sum...))
+		if hex.EncodeToString(sum) != s.SHA256 || hex.EncodeToString(chain[:]) != s.Chain {
+			return &RecordingTamperedError{Segment: i, Offset: s.Offset, Time: s.Time}
+		}
+		off += s.Size
+	}
+	last := idx.Segments[len(idx.Segments)-1]
+	if idx.Digest != last.Chain {
+		return &RecordingTamperedError{Segment: len(idx.Segments) - 1, Offset: last.Offset, Time: last.Time}
+	}
+	if n, err := io.Copy(io.Discard, r); err != nil {
+		return err
+	} else if n > 0 {
+		return &RecordingTamperedError{Segment: len(idx.Segments), Offset: off, Time: last.Time}
+	}
+	return nil
+}
+
+// recordingHasher 把录制数据在关键帧处切分成段并计算哈希链。
+type recordingHasher struct {
+	buf      []byte // 尚未切分的数据
+	base     int64  // buf[0] 的文件偏移
+	baseTime time.Duration
+	chain    [sha256.Size]byte
+	segments []RecordingSegment
+}
+
+func (h *recordingHasher) write(p []byte) {
+	h.buf = append(h.buf, p...)
+	if len(h.buf) >= maxHashSegment {
+		h.cut(h.base+int64(len(h.buf)), h.baseTime)
+	}
+}
+
+// cut 在偏移 off 处（时间 t 的关键帧）结束当前段。off 不晚于已写入的数据；
+// 早于当前段的开始时（已被强制切分越过）只更新时间。
+func (h *recordingHasher) cut(off int64, t time.Duration) {
+	if n := off - h.base; n > 0 {
+		seg, chain := hashSegment(h.chain, h.buf[:n], h.base, h.baseTime)
+		h.segments = append(h.segments, seg)
+		h.chain = chain
+		h.buf = append(h.buf[:0], h.buf[n:]...)
+		h.base = off
+	}
+	h.baseTime = t
+}
+
+// result 返回到目前为止的段（含尚未切分的数据）和最终摘要，不改变状态。
+func (h *recordingHasher) result() ([]RecordingSegment, string) {
+	segments := append([]RecordingSegment{}, h.segments...)
+	if len(h.buf) > 0 {
+		seg, _ := hashSegment(h.chain, h.buf, h.base, h.baseTime)
+		segments = append(segments, seg)
+	}
+	if len(segments) == 0 {
+		return nil, ""
+	}
+	return segments, segments[len(segments)-1].Chain
+}
+
+func hashSegment(prev [sha256.Size]byte, data []byte, off int64, t time.Duration) (RecordingSegment, [sha256.Size]byte) {
+	sum := sha256.Sum256(data)
+	chain := sha256.Sum256(append(prev[:], sum[:]...))
+	return RecordingSegment{
+		Offset: off,
+		Size:   int64(len(data)),
+		Time:   t,
+		SHA256: hex.EncodeToString(sum[:]),
+		Chain:  hex.EncodeToString(chain[:]),
+	}, chain
+}
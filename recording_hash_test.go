@@ -0,0 +1,100 @@
+package mediadevices
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// hashedTS returns a TS recording with a header packet and keyframes at
+// 0 s and 2 s, and its index with a hash chain.
+func hashedTS() ([]byte, *RecordingIndex) {
+	var data []byte
+	data = append(data, make([]byte, 188)...) // PAT
+	data = append(data, tsPacket(true, 90000)...)
+	data = append(data, tsPacket(false, 93000)...)
+	data = append(data, tsPacket(true, 270000)...)
+	data = append(data, make([]byte, 188)...)
+
+	x := newRecordingIndexer("mpegts")
+	x.hash = &recordingHasher{}
+	feed(x, data)
+	return data, x.index()
+}
+
+func TestRecordingHashChain(t *testing.T) {
+	data, idx := hashedTS()
+	want := []struct {
+		off, size int64
+		time      time.Duration
+	}{{0, 188, 0}, {188, 2 * 188, 0}, {3 * 188, 2 * 188, 2 * time.Second}}
+	if len(idx.Segments) != len(want) {
+		t.Fatalf("segments = %+v", idx.Segments)
+	}
+	for i, w := range want {
+		s := idx.Segments[i]
+		if s.Offset != w.off || s.Size != w.size || s.Time != w.time {
+			t.Errorf("segment %d = %d+%d at %v, want %d+%d at %v", i, s.Offset, s.Size, s.Time, w.off, w.size, w.time)
+		}
+	}
+	if idx.Digest != idx.Segments[2].Chain {
+		t.Errorf("digest %s is not the last chain value", idx.Digest)
+	}
+	if err := idx.Verify(bytes.NewReader(data)); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerifyRecording_Tampered(t *testing.T) {
+	data, idx := hashedTS()
+	path := filepath.Join(t.TempDir(), "rec.ts")
+	if err := writeRecordingIndex(path, idx); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := bytes.Clone(data)
+	modified[3*188+100] ^= 1
+	for name, tc := range map[string]struct {
+		data    []byte
+		segment int
+	}{
+		"modified":  {modified, 2},
+		"truncated": {data[:len(data)-1], 2},
+		"appended":  {append(bytes.Clone(data), 0), 3},
+		"removed":   {data[188:], 0},
+	} {
+		if err := os.WriteFile(path, tc.data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		var tampered *RecordingTamperedError
+		if err := VerifyRecording(path); !errors.As(err, &tampered) || tampered.Segment != tc.segment {
+			t.Errorf("%s: err = %v, want tampered segment %d", name, err, tc.segment)
+		}
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyRecording(path); err != nil {
+		t.Errorf("VerifyRecording: %v", err)
+	}
+	idx.Segments, idx.Digest = nil, ""
+	if err := idx.Verify(bytes.NewReader(data)); !errors.Is(err, ErrRecordingNotHashed) {
+		t.Errorf("without chain: err = %v", err)
+	}
+}
+
+func TestRecordingHasher_LateCut(t *testing.T) {
+	h := &recordingHasher{}
+	h.write(make([]byte, 100))
+	h.cut(60, time.Second)
+	h.cut(40, 2*time.Second) // behind the last cut: only the time moves
+	h.write(make([]byte, 10))
+	segments, _ := h.result()
+	if len(segments) != 2 || segments[1].Offset != 60 || segments[1].Size != 50 || segments[1].Time != 2*time.Second {
+		t.Errorf("segments = %+v", segments)
+	}
+}
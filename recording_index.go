@@ -0,0 +1,595 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// RecordingIndex 是录制文件的关键帧索引，以 JSON 边车文件（<录制文件>.idx.json）保存。
+// 借助它可以直接定位到某一时刻之前最近的关键帧，用于快速跳转和片段提取，
+// 无需重新解析整个文件。
+//
+// 偏移量指向容器中可独立解码的起点：MPEG-TS 为关键帧所在 TS 包，
+// 分片 MP4 为以关键帧开始的片段的 moof 盒，Matroska 为包含关键帧的 Cluster。
+// 对于 Go 侧加密的录制，偏移量是解密后数据中的位置。
+type RecordingIndex struct {
+	// Version 索引格式版本。
+	Version int `json:"version"`
+	// Format 容器格式（"mpegts"、"mp4"、"matroska"）。
+	Format string `json:"format"`
+	// Keyframes 按时间顺序排列的关键帧。
+	Keyframes []KeyframeEntry `json:"keyframes"`
+	// Segments 录制数据的哈希链（见 VerifyRecording），未启用时为空。
+	Segments []RecordingSegment `json:"segments,omitempty"`
+	// Digest 最后一段的 Chain，即整个录制的摘要。
+	Digest string `json:"digest,omitempty"`
+}
+
+// KeyframeEntry 是索引中的一个关键帧。
+type KeyframeEntry struct {
+	// Offset 关键帧在文件中的字节偏移。
+	Offset int64 `json:"offset"`
+	// Time 关键帧相对于录制开始的时间。
+	Time time.Duration `json:"time_ns"`
+}
+
+const recordingIndexVersion = 1
+
+// RecordingIndexPath 返回录制文件 path 的索引边车文件路径。
+func RecordingIndexPath(path string) string {
+	return path + ".idx.json"
+}
+
+// ReadRecordingIndex 读取录制文件 path 的索引边车文件。
+func ReadRecordingIndex(path string) (*RecordingIndex, error) {
+	data, err := os.ReadFile(RecordingIndexPath(path))
+	if err != nil {
+		return nil, err
+	}
+	var idx RecordingIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("parse recording index: %w", err)
+	}
+	if idx.Version != recordingIndexVersion {
+		return nil, fmt.Errorf("unsupported recording index version %d", idx.Version)
+	}
+	return &idx, nil
+}
+
+// KeyframeAt 返回时间 t 处或之前最近的关键帧，
+// 即从 t 开始播放或剪辑时应开始读取的位置。t 早于第一个关键帧时返回第一个关键帧。
+func (idx *RecordingIndex) KeyframeAt(t time.Duration) (KeyframeEntry, bool) {
+	if len(idx.Keyframes) == 0 {
+		return KeyframeEntry{}, false
+	}
+	i := sort.Search(len(idx.Keyframes), func(i int) bool { return idx.Keyframes[i].Time > t })
+	if i > 0 {
+		i--
+	}
+	return idx.Keyframes[i], true
+}
+
+// writeRecordingIndex 原子地写入索引边车文件（先写临时文件再重命名）。
+func writeRecordingIndex(path string, idx *RecordingIndex) error {
+	data, err := json.MarshalIndent(idx, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := RecordingIndexPath(path) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, RecordingIndexPath(path))
+}
+
+// recordingIndexer 在录制数据流经时解析容器结构并记录关键帧位置。
+// 它实现 io.Writer，写入的数据必须是完整输出文件的连续字节。
+type recordingIndexer struct {
+	format string
+
+	mu        sync.Mutex
+	keyframes []KeyframeEntry
+	offset    int64  // 已写入的字节数
+	pending   []byte // 尚未解析完的数据
+	skip      int64  // 需要跳过的字节（大型负载）
+	base      int64  // pending[0] 的文件偏移
+
+	// mp4、matroska
+	videoTrack uint64
+	timescale  uint32 // mp4
+	// matroska
+	cluster    int64 // 当前 Cluster 的偏移，-1 表示不在 Cluster 中
+	clusterEnd int64
+	clusterTS  int64
+	clusterKey bool
+	// mpegts
+	firstPTS int64
+
+	hash *recordingHasher // 哈希链，nil 表示不计算
+}
+
+func newRecordingIndexer(format string) *recordingIndexer {
+	return &recordingIndexer{format: format, cluster: -1, firstPTS: -1}
+}
+
+func (x *recordingIndexer) Write(p []byte) (int, error) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	n := len(p)
+	x.offset += int64(n)
+	if x.hash != nil {
+		x.hash.write(p)
+	}
+	if x.format == "" {
+		// 已停止索引，不再缓存数据
+		return n, nil
+	}
+	if x.skip > 0 {
+		s := x.skip
+		if s > int64(len(p)) {
+			s = int64(len(p))
+		}
+		x.skip -= s
+		x.base += s
+		p = p[s:]
+	}
+	x.pending = append(x.pending, p...)
+
+	switch x.format {
+	case "mpegts":
+		x.parseTS()
+	case "mp4":
+		x.parseMP4()
+	case "matroska":
+		x.parseMKV()
+	}
+	return n, nil
+}
+
+// stop 停止索引：已记录的关键帧保留，之后的写入只计入偏移和哈希。
+func (x *recordingIndexer) stop() {
+	x.format = ""
+	x.pending = nil
+}
+
+// consume 丢弃 pending 的前 n 字节；n 超过 pending 时，剩余部分记入 skip。
+func (x *recordingIndexer) consume(n int64) {
+	if n <= int64(len(x.pending)) {
+		x.pending = x.pending[n:]
+		x.base += n
+		return
+	}
+	x.skip = n - int64(len(x.pending))
+	x.base += int64(len(x.pending))
+	x.pending = x.pending[:0]
+}
+
+// index 返回当前的索引快照。
+func (x *recordingIndexer) index() *RecordingIndex {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.flushCluster()
+	idx := &RecordingIndex{
+		Version:   recordingIndexVersion,
+		Format:    x.format,
+		Keyframes: append([]KeyframeEntry{}, x.keyframes...),
+	}
+	if x.hash != nil {
+		idx.Segments, idx.Digest = x.hash.result()
+	}
+	return idx
+}
+
+func (x *recordingIndexer) add(offset int64, t time.Duration) {
+	x.keyframes = append(x.keyframes, KeyframeEntry{Offset: offset, Time: t})
+	if x.hash != nil {
+		x.hash.cut(offset, t)
+	}
+}
+
+// parseTS 识别带 random_access_indicator 且以视频 PES 开始的 TS 包。
+func (x *recordingIndexer) parseTS() {
+	const size = 188
+	for len(x.pending) >= size {
+		pkt := x.pending[:size]
+		off := x.base
+		x.consume(size)
+		if pkt[0] != 0x47 || pkt[1]&0x40 == 0 { // 同步字节、payload_unit_start_indicator
+			continue
+		}
+		afc := pkt[3] >> 4 & 0x03
+		payload := 4
+		random := false
+		if afc&0x02 != 0 {
+			afLen := int(pkt[4])
+			if afLen > 0 && pkt[5]&0x40 != 0 {
+				random = true
+			}
+			payload += 1 + afLen
+		}
+		if !random || afc&0x01 == 0 || payload+14 > size {
+			continue
+		}
+		pes := pkt[payload:]
+		if pes[0] != 0 || pes[1] != 0 || pes[2] != 1 || pes[3]&0xF0 != 0xE0 { // 视频 PES
+			continue
+		}
+		if pes[7]&0x80 == 0 { // 无 PTS
+			continue
+		}
+		pts := parsePESTimestamp(pes[9:14])
+		if x.firstPTS < 0 {
+			x.firstPTS = pts
+		}
+		x.add(off, time.Duration((pts-x.firstPTS)&(1<<33-1))*time.Second/90000)
+	}
+}
+
+func parsePESTimestamp(b []byte) int64 {
+	return int64(b[0]>>1&0x07)<<30 | int64(b[1])<<22 | int64(b[2]>>1)<<15 | int64(b[3])<<7 | int64(b[4]>>1)
+}
+
+// parseMP4 处理分片 MP4 的顶层盒：从 moov 读取视频轨道及其时间刻度，
+// 视频轨道首个样本为同步样本（关键帧）的 moof 记为一个关键帧。
+func (x *recordingIndexer) parseMP4() {
+	for len(x.pending) >= 8 {
+		size := int64(binary.BigEndian.Uint32(x.pending[:4]))
+		typ := string(x.pending[4:8])
+		hdr := int64(8)
+		if size == 1 {
+			if len(x.pending) < 16 {
+				return
+			}
+			size = int64(binary.BigEndian.Uint64(x.pending[8:16]))
+			hdr = 16
+		}
+		if size < hdr {
+			// size 0（延续到文件末尾）或无效：停止索引
+			x.stop()
+			return
+		}
+		switch typ {
+		case "moov", "moof":
+			if int64(len(x.pending)) < size {
+				return // 等待完整的盒
+			}
+			box := x.pending[hdr:size]
+			if typ == "moov" {
+				x.videoTrack, x.timescale = mp4VideoTrack(box)
+			} else if x.timescale > 0 {
+				if t, ok := mp4FragmentKeyframe(box, uint32(x.videoTrack)); ok {
+					x.add(x.base, time.Duration(float64(t)/float64(x.timescale)*float64(time.Second)))
+				}
+			}
+		}
+		x.consume(size)
+	}
+}
+
+// mp4Children 遍历盒内容中的子盒。
+func mp4Children(data []byte, fn func(typ string, body []byte) bool) {
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		hdr := 8
+		if size == 1 && len(data) >= 16 {
+			size = int(binary.BigEndian.Uint64(data[8:16]))
+			hdr = 16
+		}
+		if size < hdr || size > len(data) {
+			return
+		}
+		if !fn(string(data[4:8]), data[hdr:size]) {
+			return
+		}
+		data = data[size:]
+	}
+}
+
+// mp4VideoTrack 返回 moov 中第一个视频轨道的 ID（tkhd）和时间刻度（mdhd）。
+func mp4VideoTrack(moov []byte) (id uint64, timescale uint32) {
+	mp4Children(moov, func(typ string, trak []byte) bool {
+		if typ != "trak" {
+			return true
+		}
+		var tid uint64
+		var ts uint32
+		video := false
+		mp4Children(trak, func(typ string, body []byte) bool {
+			switch typ {
+			case "tkhd":
+				if len(body) >= 24 && body[0] == 1 {
+					tid = uint64(binary.BigEndian.Uint32(body[20:24]))
+				} else if len(body) >= 16 {
+					tid = uint64(binary.BigEndian.Uint32(body[12:16]))
+				}
+			case "mdia":
+				mp4Children(body, func(typ string, body []byte) bool {
+					switch typ {
+					case "mdhd":
+						if len(body) >= 24 && body[0] == 1 {
+							ts = binary.BigEndian.Uint32(body[20:24])
+						} else if len(body) >= 16 {
+							ts = binary.BigEndian.Uint32(body[12:16])
+						}
+					case "hdlr":
+						video = len(body) >= 12 && string(body[8:12]) == "vide"
+					}
+					return true
+				})
+			}
+			return true
+		})
+		if video {
+			id, timescale = tid, ts
+			return false
+		}
+		return true
+	})
+	return id, timescale
+}
+
+// mp4NonSyncSample 是样本标志中的 sample_is_non_sync_sample 位。
+const mp4NonSyncSample = 0x00010000
+
+// mp4FragmentKeyframe 检查 moof 中轨道 track 的片段：首个样本为同步样本时，
+// 返回其 tfdt 基准解码时间。track 为 0 时使用第一个 traf。
+// 无法确定样本标志时视为同步样本。
+func mp4FragmentKeyframe(moof []byte, track uint32) (uint64, bool) {
+	var t uint64
+	found, key := false, true
+	mp4Children(moof, func(typ string, traf []byte) bool {
+		if typ != "traf" {
+			return true
+		}
+		var defaultFlags, firstFlags *uint32
+		match := track == 0
+		mp4Children(traf, func(typ string, body []byte) bool {
+			if len(body) < 8 {
+				return true
+			}
+			flags := binary.BigEndian.Uint32(body[:4]) & 0xFFFFFF
+			switch typ {
+			case "tfhd":
+				match = match || binary.BigEndian.Uint32(body[4:8]) == track
+				off := 8
+				for _, f := range []struct {
+					bit  uint32
+					size int
+				}{{0x01, 8}, {0x02, 4}, {0x08, 4}, {0x10, 4}} {
+					if flags&f.bit != 0 {
+						off += f.size
+					}
+				}
+				if flags&0x20 != 0 && len(body) >= off+4 {
+					v := binary.BigEndian.Uint32(body[off:])
+					defaultFlags = &v
+				}
+			case "tfdt":
+				if body[0] == 1 && len(body) >= 12 {
+					t = binary.BigEndian.Uint64(body[4:12])
+				} else {
+					t = uint64(binary.BigEndian.Uint32(body[4:8]))
+				}
+				found = true
+			case "trun":
+				off := 8
+				if flags&0x01 != 0 {
+					off += 4
+				}
+				if flags&0x04 != 0 {
+					if len(body) >= off+4 {
+						v := binary.BigEndian.Uint32(body[off:])
+						firstFlags = &v
+					}
+				} else if flags&0x400 != 0 {
+					// 逐样本标志：跳过首个样本的时长和大小
+					if flags&0x100 != 0 {
+						off += 4
+					}
+					if flags&0x200 != 0 {
+						off += 4
+					}
+					if len(body) >= off+4 {
+						v := binary.BigEndian.Uint32(body[off:])
+						firstFlags = &v
+					}
+				}
+			}
+			return true
+		})
+		if !match {
+			found = false
+			return true
+		}
+		if firstFlags == nil {
+			firstFlags = defaultFlags
+		}
+		key = firstFlags == nil || *firstFlags&mp4NonSyncSample == 0
+		return false
+	})
+	return t, found && key
+}
+
+// Matroska 元素 ID。
+const (
+	mkvSegment     = 0x18538067
+	mkvCluster     = 0x1F43B675
+	mkvTracks      = 0x1654AE6B
+	mkvTrackEntry  = 0xAE
+	mkvTrackNumber = 0xD7
+	mkvTrackType   = 0x83
+	mkvTimestamp   = 0xE7
+	mkvSimpleBlock = 0xA3
+	mkvBlockGroup  = 0xA0
+	mkvBlock       = 0xA1
+)
+
+// readVint 读取 EBML 变长整数。id 为 true 时保留长度标记位（元素 ID），
+// 否则去掉标记位（元素大小）。未知大小返回 -1。数据不足时 n 为 0。
+func readVint(b []byte, id bool) (v int64, n int) {
+	if len(b) == 0 || b[0] == 0 {
+		return 0, 0
+	}
+	n = 1
+	for mask := byte(0x80); b[0]&mask == 0; mask >>= 1 {
+		n++
+	}
+	if len(b) < n {
+		return 0, 0
+	}
+	v = int64(b[0])
+	if !id {
+		v &= int64(0xFF >> n)
+	}
+	unknown := v == int64(0xFF>>n)
+	for i := 1; i < n; i++ {
+		v = v<<8 | int64(b[i])
+		unknown = unknown && b[i] == 0xFF
+	}
+	if !id && unknown {
+		return -1, n
+	}
+	return v, n
+}
+
+// parseMKV 扫描 Segment 下的 Tracks 与 Cluster：包含视频关键帧的 Cluster 记为关键帧位置，
+// 时间为 Cluster 时间戳加关键帧的相对时间（默认 1ms 时间单位）。
+func (x *recordingIndexer) parseMKV() {
+	for {
+		if x.cluster >= 0 && x.clusterEnd >= 0 && x.base >= x.clusterEnd {
+			x.flushCluster()
+		}
+		id, idn := readVint(x.pending, true)
+		if idn == 0 {
+			return
+		}
+		size, sn := readVint(x.pending[idn:], false)
+		if sn == 0 {
+			return
+		}
+		hdr := int64(idn + sn)
+
+		switch id {
+		case mkvSegment:
+			// 进入 Segment，逐个解析其子元素
+			x.consume(hdr)
+			continue
+		case mkvCluster:
+			x.flushCluster()
+			x.cluster = x.base
+			x.clusterEnd = -1
+			if size >= 0 {
+				x.clusterEnd = x.base + hdr + size
+			}
+			x.clusterKey = false
+			x.consume(hdr)
+			continue
+		case mkvBlockGroup:
+			x.consume(hdr)
+			continue
+		case mkvTracks, mkvTimestamp, mkvSimpleBlock, mkvBlock:
+			if size < 0 {
+				// 无法确定结尾，同样停止索引
+				x.stop()
+				return
+			}
+			need := hdr + size
+			if id == mkvSimpleBlock || id == mkvBlock {
+				need = hdr + min(size, 12) // 只需块头
+			}
+			if int64(len(x.pending)) < need {
+				return
+			}
+			body := x.pending[hdr:need]
+			switch id {
+			case mkvTracks:
+				x.videoTrack = mkvVideoTrack(body)
+			case mkvTimestamp:
+				x.clusterTS = int64(ebmlUint(body))
+			default:
+				x.mkvBlock(body, id == mkvSimpleBlock)
+			}
+			x.consume(hdr + size)
+			continue
+		}
+		if size < 0 {
+			// 未知大小的其他元素无法跳过
+			x.stop()
+			return
+		}
+		x.consume(hdr + size)
+	}
+}
+
+func (x *recordingIndexer) mkvBlock(b []byte, simple bool) {
+	if x.cluster < 0 || x.clusterKey {
+		return
+	}
+	track, n := readVint(b, false)
+	if n == 0 || len(b) < n+3 || (x.videoTrack != 0 && uint64(track) != x.videoTrack) {
+		return
+	}
+	// SimpleBlock 的 flags 带关键帧位；BlockGroup 中的 Block 不带，
+	// FFmpeg 仅将非关键帧写为 BlockGroup（附 ReferenceBlock），此处不计入。
+	if !simple || b[n+2]&0x80 == 0 {
+		return
+	}
+	rel := int64(int16(binary.BigEndian.Uint16(b[n : n+2])))
+	x.clusterKey = true
+	x.add(x.cluster, time.Duration(x.clusterTS+rel)*time.Millisecond)
+}
+
+// flushCluster 结束当前 Cluster。
+func (x *recordingIndexer) flushCluster() {
+	x.cluster = -1
+}
+
+// mkvVideoTrack 返回 Tracks 中第一个视频轨道的编号。
+func mkvVideoTrack(tracks []byte) uint64 {
+	for len(tracks) > 0 {
+		id, idn := readVint(tracks, true)
+		size, sn := readVint(tracks[idn:], false)
+		if idn == 0 || sn == 0 || size < 0 || int64(len(tracks)) < int64(idn+sn)+size {
+			return 0
+		}
+		body := tracks[idn+sn : int64(idn+sn)+size]
+		tracks = tracks[int64(idn+sn)+size:]
+		if id != mkvTrackEntry {
+			continue
+		}
+		var number, typ uint64
+		for len(body) > 0 {
+			cid, cn := readVint(body, true)
+			csize, csn := readVint(body[cn:], false)
+			if cn == 0 || csn == 0 || csize < 0 || int64(len(body)) < int64(cn+csn)+csize {
+				break
+			}
+			val := body[cn+csn : int64(cn+csn)+csize]
+			switch cid {
+			case mkvTrackNumber:
+				number = ebmlUint(val)
+			case mkvTrackType:
+				typ = ebmlUint(val)
+			}
+			body = body[int64(cn+csn)+csize:]
+		}
+		if typ == 1 {
+			return number
+		}
+	}
+	return 0
+}
+
+func ebmlUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
@@ -0,0 +1,220 @@
+package mediadevices
+
+import (
+	"encoding/binary"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// feed writes data to the indexer in small pieces, like a pipe would.
+func feed(x *recordingIndexer, data []byte) {
+	for len(data) > 0 {
+		n := min(len(data), 7)
+		x.Write(data[:n])
+		data = data[n:]
+	}
+}
+
+func tsPacket(keyframe bool, pts int64) []byte {
+	pkt := make([]byte, 188)
+	pkt[0], pkt[1], pkt[2] = 0x47, 0x41, 0x00 // PUSI, PID 0x100
+	pkt[3] = 0x30                             // adaptation field + payload
+	pkt[4] = 1
+	if keyframe {
+		pkt[5] = 0x40
+	}
+	pes := pkt[6:]
+	copy(pes, []byte{0, 0, 1, 0xE0, 0, 0, 0x80, 0x80, 5})
+	pes[9] = 0x21 | byte(pts>>29)&0x0E
+	pes[10] = byte(pts >> 22)
+	pes[11] = byte(pts>>14) | 1
+	pes[12] = byte(pts >> 7)
+	pes[13] = byte(pts<<1) | 1
+	return pkt
+}
+
+func TestRecordingIndexer_TS(t *testing.T) {
+	var data []byte
+	data = append(data, tsPacket(true, 90000)...)
+	data = append(data, tsPacket(false, 93000)...)
+	data = append(data, make([]byte, 188)...) // continuation packet
+	data = append(data, tsPacket(true, 270000)...)
+
+	x := newRecordingIndexer("mpegts")
+	feed(x, data)
+	idx := x.index()
+	want := []KeyframeEntry{{0, 0}, {3 * 188, 2 * time.Second}}
+	if len(idx.Keyframes) != len(want) {
+		t.Fatalf("keyframes = %+v, want %+v", idx.Keyframes, want)
+	}
+	for i := range want {
+		if idx.Keyframes[i] != want[i] {
+			t.Errorf("keyframe %d = %+v, want %+v", i, idx.Keyframes[i], want[i])
+		}
+	}
+}
+
+func box(typ string, body ...[]byte) []byte {
+	size := 8
+	for _, b := range body {
+		size += len(b)
+	}
+	out := binary.BigEndian.AppendUint32(nil, uint32(size))
+	out = append(out, typ...)
+	for _, b := range body {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestRecordingIndexer_MP4(t *testing.T) {
+	mdhd := make([]byte, 24)
+	binary.BigEndian.PutUint32(mdhd[12:], 12800)
+	hdlr := make([]byte, 24)
+	copy(hdlr[8:], "vide")
+	tkhd := make([]byte, 84)
+	tkhd[15] = 1
+	moov := box("moov", box("mvhd", make([]byte, 100)), box("trak", box("tkhd", tkhd), box("mdia", box("mdhd", mdhd), box("hdlr", hdlr))))
+
+	// moof with default sample flags "non-sync" in tfhd, overridden for
+	// the first sample in trun when key is set.
+	moof := func(t uint32, key bool) []byte {
+		tfhd := []byte{0, 0, 0, 0x20, 0, 0, 0, 1, 0, 0x01, 0, 0}
+		tfdt := binary.BigEndian.AppendUint32([]byte{0, 0, 0, 0}, t)
+		trun := []byte{0, 0, 0, 0x01, 0, 0, 0, 1, 0, 0, 0, 0}
+		if key {
+			trun = []byte{0, 0, 0, 0x05, 0, 0, 0, 1, 0, 0, 0, 0, 0x02, 0, 0, 0}
+		}
+		return box("moof", box("mfhd", make([]byte, 8)), box("traf", box("tfhd", tfhd), box("tfdt", tfdt), box("trun", trun)))
+	}
+
+	var data []byte
+	data = append(data, box("ftyp", make([]byte, 16))...)
+	data = append(data, moov...)
+	off1 := int64(len(data))
+	data = append(data, moof(0, true)...)
+	data = append(data, box("mdat", make([]byte, 1000))...)
+	data = append(data, moof(12800, false)...) // fragment split by duration
+	data = append(data, box("mdat", make([]byte, 1000))...)
+	off2 := int64(len(data))
+	data = append(data, moof(12800*3/2, true)...)
+	data = append(data, box("mdat", make([]byte, 500))...)
+
+	x := newRecordingIndexer("mp4")
+	feed(x, data)
+	idx := x.index()
+	want := []KeyframeEntry{{off1, 0}, {off2, 1500 * time.Millisecond}}
+	if len(idx.Keyframes) != len(want) || idx.Keyframes[0] != want[0] || idx.Keyframes[1] != want[1] {
+		t.Fatalf("keyframes = %+v, want %+v", idx.Keyframes, want)
+	}
+}
+
+func ebml(id uint32, body ...[]byte) []byte {
+	var out []byte
+	for shift := 24; shift >= 0; shift -= 8 {
+		if b := byte(id >> shift); b != 0 || len(out) > 0 {
+			out = append(out, b)
+		}
+	}
+	size := 0
+	for _, b := range body {
+		size += len(b)
+	}
+	out = append(out, 0x08, 0, byte(size>>16), byte(size>>8), byte(size)) // 5-byte size
+	for _, b := range body {
+		out = append(out, b...)
+	}
+	return out
+}
+
+func TestRecordingIndexer_Matroska(t *testing.T) {
+	block := func(track byte, rel int16, key bool) []byte {
+		flags := byte(0)
+		if key {
+			flags = 0x80
+		}
+		return ebml(mkvSimpleBlock, []byte{0x80 | track, byte(rel >> 8), byte(rel), flags}, make([]byte, 50))
+	}
+	tracks := ebml(mkvTracks,
+		ebml(mkvTrackEntry, ebml(mkvTrackNumber, []byte{1}), ebml(mkvTrackType, []byte{2})),
+		ebml(mkvTrackEntry, ebml(mkvTrackNumber, []byte{2}), ebml(mkvTrackType, []byte{1})),
+	)
+
+	var data []byte
+	data = append(data, ebml(0x1A45DFA3, make([]byte, 20))...) // EBML header
+	// Segment of unknown size, as written to a pipe
+	data = append(data, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	data = append(data, ebml(0x1549A966, make([]byte, 10))...) // Info
+	data = append(data, tracks...)
+	off1 := int64(len(data))
+	data = append(data, ebml(mkvCluster, ebml(mkvTimestamp, []byte{0}), block(1, 0, true), block(2, 5, true), block(2, 38, false))...)
+	data = append(data, ebml(mkvCluster, ebml(mkvTimestamp, []byte{0x03, 0xE8}), block(1, 0, true), block(2, 10, false))...)
+	off3 := int64(len(data))
+	data = append(data, ebml(mkvCluster, ebml(mkvTimestamp, []byte{0x07, 0xD0}), block(2, 0, true))...)
+
+	x := newRecordingIndexer("matroska")
+	feed(x, data)
+	idx := x.index()
+	want := []KeyframeEntry{{off1, 5 * time.Millisecond}, {off3, 2 * time.Second}}
+	if len(idx.Keyframes) != len(want) || idx.Keyframes[0] != want[0] || idx.Keyframes[1] != want[1] {
+		t.Fatalf("keyframes = %+v, want %+v", idx.Keyframes, want)
+	}
+}
+
+func TestRecordingIndexer_MatroskaUnknownSizeStops(t *testing.T) {
+	var data []byte
+	data = append(data, ebml(0x1A45DFA3, make([]byte, 20))...) // EBML header
+	data = append(data, 0x18, 0x53, 0x80, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	// Cluster of unknown size holding a keyframe, then a Tags element of
+	// unknown size that cannot be skipped.
+	off := int64(len(data))
+	data = append(data, 0x1F, 0x43, 0xB6, 0x75, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+	data = append(data, ebml(mkvTimestamp, []byte{0})...)
+	data = append(data, ebml(mkvSimpleBlock, []byte{0x81, 0, 0, 0x80}, make([]byte, 50))...)
+	data = append(data, 0x12, 0x54, 0xC3, 0x67, 0x01, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF)
+
+	x := newRecordingIndexer("matroska")
+	x.Write(data)
+	for range 1000 {
+		x.Write(make([]byte, 4096))
+	}
+	if len(x.pending) > 4096 {
+		t.Errorf("pending holds %d bytes after indexing stopped", len(x.pending))
+	}
+	idx := x.index()
+	if len(idx.Keyframes) != 1 || idx.Keyframes[0].Offset != off {
+		t.Errorf("keyframes = %+v, want one at %d", idx.Keyframes, off)
+	}
+}
+
+func TestRecordingIndex_KeyframeAt(t *testing.T) {
+	idx := &RecordingIndex{Version: recordingIndexVersion, Format: "mpegts", Keyframes: []KeyframeEntry{
+		{0, 0}, {1000, 2 * time.Second}, {2000, 4 * time.Second},
+	}}
+	for _, tt := range []struct {
+		t    time.Duration
+		want int64
+	}{
+		{0, 0}, {time.Second, 0}, {2 * time.Second, 1000}, {3 * time.Second, 1000}, {time.Hour, 2000},
+	} {
+		if k, ok := idx.KeyframeAt(tt.t); !ok || k.Offset != tt.want {
+			t.Errorf("KeyframeAt(%v) = %+v, want offset %d", tt.t, k, tt.want)
+		}
+	}
+	if _, ok := (&RecordingIndex{}).KeyframeAt(0); ok {
+		t.Error("KeyframeAt on empty index reported a keyframe")
+	}
+
+	path := filepath.Join(t.TempDir(), "rec.ts")
+	if err := writeRecordingIndex(path, idx); err != nil {
+		t.Fatalf("writeRecordingIndex: %v", err)
+	}
+	got, err := ReadRecordingIndex(path)
+	if err != nil {
+		t.Fatalf("ReadRecordingIndex: %v", err)
+	}
+	if got.Format != "mpegts" || len(got.Keyframes) != 3 || got.Keyframes[2] != idx.Keyframes[2] {
+		t.Errorf("round trip = %+v, want %+v", got, idx)
+	}
+}
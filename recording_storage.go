@@ -0,0 +1,234 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RecordingStorageConfig 配置录制存储的配额与保留策略。
+type RecordingStorageConfig struct {
+	// Dir 录制文件所在目录。目录中扩展名为 .mp4、.mkv、.ts 的文件视为录制，
+	// 连同其索引边车文件一起计入用量和清理。
+	Dir string
+	// MaxBytes 录制总大小上限，超出时从最旧的录制开始删除。0 表示不限。
+	MaxBytes int64
+	// MaxAge 录制的最长保留时间（按修改时间），0 表示不限。
+	MaxAge time.Duration
+	// Interval 后台定期清理的间隔，0 表示仅在录制结束和调用 Prune 时清理。
+	Interval time.Duration
+	// OnPrune 在每个录制被删除后调用（可选）。
+	OnPrune func(path string, size int64)
+}
+
+// RecordingStorage 管理一个录制目录的存储配额：按总大小上限和保留时间
+// 自动删除最旧的录制。正在录制的文件不会被删除。
+//
+// 通过 MediaRecorderOptions.Storage 关联到录制器；多个录制器可共享同一个
+// RecordingStorage，也可各自使用不同的配置。
+type RecordingStorage struct {
+	cfg RecordingStorageConfig
+
+	mu     sync.Mutex
+	active map[string]bool // 正在录制的文件（绝对路径）
+	stopc  chan struct{}
+	done   chan struct{}
+}
+
+// NewRecordingStorage 创建录制存储管理器。Interval 非零时启动后台清理，
+// 需调用 Close 停止。
+func NewRecordingStorage(cfg RecordingStorageConfig) (*RecordingStorage, error) {
+	if cfg.Dir == "" {
+		return nil, errors.New("recording storage: directory is required")
+	}
+	if cfg.MaxBytes < 0 || cfg.MaxAge < 0 {
+		return nil, errors.New("recording storage: limits must not be negative")
+	}
+	dir, err := filepath.Abs(cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("recording storage: %w", err)
+	}
+	cfg.Dir = dir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("recording storage: %w", err)
+	}
+
+	s := &RecordingStorage{cfg: cfg, active: make(map[string]bool)}
+	if cfg.Interval > 0 {
+		s.stopc = make(chan struct{})
+		s.done = make(chan struct{})
+		go s.run()
+	}
+	return s, nil
+}
+
+// Dir 返回录制目录的绝对路径。
+func (s *RecordingStorage) Dir() string {
+	return s.cfg.Dir
+}
+
+// Path 返回录制目录中名为 name 的文件路径，便于设置 MediaRecorderOptions.Path。
+func (s *RecordingStorage) Path(name string) string {
+	return filepath.Join(s.cfg.Dir, name)
+}
+
+// Close 停止后台清理。
+func (s *RecordingStorage) Close() {
+	if s.stopc == nil {
+		return
+	}
+	s.mu.Lock()
+	select {
+	case <-s.stopc:
+		s.mu.Unlock()
+		return
+	default:
+	}
+	close(s.stopc)
+	s.mu.Unlock()
+	<-s.done
+}
+
+func (s *RecordingStorage) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopc:
+			return
+		case <-ticker.C:
+			s.Prune()
+		}
+	}
+}
+
+// acquire 将 path 标记为正在录制，使其不被清理。
+func (s *RecordingStorage) acquire(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	s.mu.Lock()
+	s.active[abs] = true
+	s.mu.Unlock()
+}
+
+// release 取消 path 的录制标记。
+func (s *RecordingStorage) release(path string) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		abs = path
+	}
+	s.mu.Lock()
+	delete(s.active, abs)
+	s.mu.Unlock()
+}
+
+// storedRecording 是目录中的一个录制文件。
+type storedRecording struct {
+	path    string
+	size    int64 // 含边车文件
+	modTime time.Time
+	active  bool
+}
+
+// isRecordingFile 判断文件名是否为录制文件。
+func isRecordingFile(name string) bool {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp4", ".mkv", ".ts":
+		return true
+	}
+	return false
+}
+
+// list 返回目录中的录制，按修改时间从旧到新排序。
+func (s *RecordingStorage) list() ([]storedRecording, error) {
+	entries, err := os.ReadDir(s.cfg.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("recording storage: %w", err)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var recs []storedRecording
+	for _, e := range entries {
+		if !e.Type().IsRegular() || !isRecordingFile(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue // 已被删除
+		}
+		path := filepath.Join(s.cfg.Dir, e.Name())
+		size := info.Size()
+		if fi, err := os.Stat(RecordingIndexPath(path)); err == nil {
+			size += fi.Size()
+		}
+		recs = append(recs, storedRecording{
+			path:    path,
+			size:    size,
+			modTime: info.ModTime(),
+			active:  s.active[path],
+		})
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].modTime.Before(recs[j].modTime) })
+	return recs, nil
+}
+
+// Usage 返回录制目录当前的总用量（字节，含索引边车文件）。
+func (s *RecordingStorage) Usage() (int64, error) {
+	recs, err := s.list()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, r := range recs {
+		total += r.size
+	}
+	return total, nil
+}
+
+// Prune 立即执行一次清理：先删除超过 MaxAge 的录制，再从最旧的录制开始删除，
+// 直到总用量不超过 MaxBytes。正在录制的文件计入用量但不会被删除。
+// 返回被删除的录制路径。
+func (s *RecordingStorage) Prune() ([]string, error) {
+	recs, err := s.list()
+	if err != nil {
+		return nil, err
+	}
+	var total int64
+	for _, r := range recs {
+		total += r.size
+	}
+
+	cutoff := time.Time{}
+	if s.cfg.MaxAge > 0 {
+		cutoff = time.Now().Add(-s.cfg.MaxAge)
+	}
+	var pruned []string
+	var errs []error
+	for _, r := range recs {
+		expired := !cutoff.IsZero() && r.modTime.Before(cutoff)
+		over := s.cfg.MaxBytes > 0 && total > s.cfg.MaxBytes
+		if r.active || (!expired && !over) {
+			continue
+		}
+		if err := os.Remove(r.path); err != nil && !os.IsNotExist(err) {
+			errs = append(errs, fmt.Errorf("recording storage: %w", err))
+			continue
+		}
+		os.Remove(RecordingIndexPath(r.path))
+		total -= r.size
+		pruned = append(pruned, r.path)
+		if s.cfg.OnPrune != nil {
+			s.cfg.OnPrune(r.path, r.size)
+		}
+	}
+	return pruned, errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package mediadevices
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeAged(t *testing.T, path string, size int, age time.Duration) {
+	t.Helper()
+	if err := os.WriteFile(path, make([]byte, size), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	mt := time.Now().Add(-age)
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestRecordingStorage_MaxBytes(t *testing.T) {
+	dir := t.TempDir()
+	var prunedBytes int64
+	s, err := NewRecordingStorage(RecordingStorageConfig{
+		Dir:      dir,
+		MaxBytes: 2500,
+		OnPrune:  func(_ string, size int64) { prunedBytes += size },
+	})
+	if err != nil {
+		t.Fatalf("NewRecordingStorage: %v", err)
+	}
+	defer s.Close()
+
+	oldest := s.Path("a.mp4")
+	writeAged(t, oldest, 1000, 4*time.Hour)
+	writeAged(t, RecordingIndexPath(oldest), 100, 4*time.Hour)
+	active := s.Path("b.ts")
+	writeAged(t, active, 1000, 3*time.Hour)
+	middle := s.Path("c.mkv")
+	writeAged(t, middle, 1000, 2*time.Hour)
+	newest := s.Path("d.mp4")
+	writeAged(t, newest, 1000, time.Hour)
+	other := s.Path("notes.txt")
+	writeAged(t, other, 5000, 5*time.Hour)
+
+	if got, _ := s.Usage(); got != 4100 {
+		t.Errorf("Usage = %d, want 4100", got)
+	}
+
+	s.acquire(active)
+	pruned, err := s.Prune()
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if len(pruned) != 2 || pruned[0] != oldest || pruned[1] != middle {
+		t.Errorf("pruned = %v, want [%s %s]", pruned, oldest, middle)
+	}
+	if exists(oldest) || exists(RecordingIndexPath(oldest)) || exists(middle) {
+		t.Error("pruned recording or its index still exists")
+	}
+	if !exists(active) || !exists(newest) || !exists(other) {
+		t.Error("active, newest or non-recording file was removed")
+	}
+	if prunedBytes != 2100 {
+		t.Errorf("OnPrune reported %d bytes, want 2100", prunedBytes)
+	}
+}
+
+func TestRecordingStorage_MaxAge(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewRecordingStorage(RecordingStorageConfig{Dir: dir, MaxAge: 24 * time.Hour, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewRecordingStorage: %v", err)
+	}
+	defer s.Close()
+
+	old := filepath.Join(dir, "old.ts")
+	writeAged(t, old, 10, 48*time.Hour)
+	recent := filepath.Join(dir, "recent.ts")
+	writeAged(t, recent, 10, time.Hour)
+
+	// removed by the background pruner
+	deadline := time.Now().Add(2 * time.Second)
+	for exists(old) && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if exists(old) {
+		t.Error("expired recording not pruned")
+	}
+	if !exists(recent) {
+		t.Error("recent recording pruned")
+	}
+	s.Close()
+}
+
+func TestNewRecordingStorage_Validation(t *testing.T) {
+	if _, err := NewRecordingStorage(RecordingStorageConfig{}); err == nil {
+		t.Error("empty directory accepted")
+	}
+	if _, err := NewRecordingStorage(RecordingStorageConfig{Dir: t.TempDir(), MaxBytes: -1}); err == nil {
+		t.Error("negative MaxBytes accepted")
+	}
+}
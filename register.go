@@ -1,15 +1,29 @@
 package mediadevices
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"sync"
+	"time"
 )
 
-var (
-	initOnce       sync.Once
-	cachedDevices  []MediaDeviceInfo
-	cachedDevErr   error
-)
+// defaultEnumerateTimeout 是未设置 Config.DiscoveryTimeout 时，
+// 没有截止时间的 context 下设备枚举的超时时间。
+const defaultEnumerateTimeout = 10 * time.Second
+
+// enumerateTimeout 返回没有截止时间的 context 下 m 的设备枚举的超时时间。
+func (m *MediaDevices) enumerateTimeout() time.Duration {
+	return m.Config().discoveryTimeout()
+}
+
+// discoveryTimeout 返回设备枚举和发现工具的超时时间：
+// Config.DiscoveryTimeout，未设置时为 defaultEnumerateTimeout。
+func (cfg Config) discoveryTimeout() time.Duration {
+	if cfg.DiscoveryTimeout > 0 {
+		return cfg.DiscoveryTimeout
+	}
+	return defaultEnumerateTimeout
+}
 
 // EnumerateDevices 返回系统中所有可用的媒体设备。
 // 对应 MDN 的 navigator.mediaDevices.enumerateDevices()。
@@ -19,66 +33,250 @@ var (
 // - macOS: 使用 avfoundation 列出 AVFoundation 设备
 // - Linux: 使用 v4l2 列出视频设备，ALSA 列出音频设备
 //
+// 结果按设备类型（videoinput、audioinput、audiooutput）、标签、设备 ID 稳定排序，
+// 重复条目已去除，因此 devices[0] 在多次运行间保持一致。
+//
 // 如果 FFmpeg 未找到或没有检测到设备，返回空切片而非错误。
+// 完整发现的结果被缓存，默认一直有效；Config.DeviceCacheTTL 设置有效期，
+// RefreshDevices 立即重新发现，OnDeviceChange 在订阅期间持续更新缓存。
+// 启用 Config.RedactLabels 后，在获得捕获授权前返回的设备不含标签。
+//
+// 等同于 EnumerateDevicesContext(context.Background())，发现过程最多持续
+// Config.DiscoveryTimeout（默认 10 秒）。
 func EnumerateDevices() ([]MediaDeviceInfo, error) {
-	initOnce.Do(func() {
-		cfg := GetConfig()
-		cachedDevices, cachedDevErr = discoverDevices(cfg.FFmpegPath)
-		if cachedDevErr != nil && cfg.Verbose {
-			log.Printf("ffmpeg: device discovery failed: %v", cachedDevErr)
+	return defaultMediaDevices.EnumerateDevicesContext(context.Background())
+}
+
+// EnumerateDevices 与包级函数 EnumerateDevices 相同，但使用 m 的配置和设备缓存。
+func (m *MediaDevices) EnumerateDevices() ([]MediaDeviceInfo, error) {
+	return m.EnumerateDevicesContext(context.Background())
+}
+
+// EnumerateDevicesContext 与 EnumerateDevices 相同，但发现过程受 ctx 控制，
+// 避免 FFmpeg 在故障驱动上卡住时无限等待。ctx 没有截止时间时使用
+// Config.DiscoveryTimeout（默认 10 秒）。
+//
+// 超时或取消时返回已发现的部分设备以及包装了 ctx.Err() 的错误，
+// 可用 errors.Is(err, context.DeadlineExceeded) 判断。只有完整的结果会被缓存，
+// 下次调用会重新发现。
+func EnumerateDevicesContext(ctx context.Context) ([]MediaDeviceInfo, error) {
+	return defaultMediaDevices.EnumerateDevicesContext(ctx)
+}
+
+// EnumerateDevicesContext 与包级函数 EnumerateDevicesContext 相同，
+// 但使用 m 的配置和设备缓存。
+func (m *MediaDevices) EnumerateDevicesContext(ctx context.Context) ([]MediaDeviceInfo, error) {
+	devices, err := m.enumerateDevicesRaw(ctx)
+	return m.redactDevices(devices), err
+}
+
+// enumerateDevicesRaw 返回未经隐私处理的设备列表，供内部选择设备使用。
+// 完整发现的结果在 Config.DeviceCacheTTL 内被缓存；设置了 Config.DiscoverDevices 时
+// 每次调用它，不使用缓存。
+func (m *MediaDevices) enumerateDevicesRaw(ctx context.Context) ([]MediaDeviceInfo, error) {
+	devices, err := m.enumerateCaptureDevices(ctx)
+	return m.withExtraDevices(devices), err
+}
+
+// enumerateCaptureDevices 返回摄像头和麦克风，完整发现的结果在 Config.DeviceCacheTTL 内被缓存。
+func (m *MediaDevices) enumerateCaptureDevices(ctx context.Context) ([]MediaDeviceInfo, error) {
+	cfg := m.Config()
+	if cfg.DiscoverDevices == nil {
+		m.devicesMu.Lock()
+		defer m.devicesMu.Unlock()
+		if m.devicesCached && (cfg.DeviceCacheTTL <= 0 || time.Since(m.devicesCachedAt) < cfg.DeviceCacheTTL) {
+			return m.cachedDevices, nil
 		}
-		if cfg.Verbose && cachedDevErr == nil {
-			log.Printf("ffmpeg: discovered %d devices", len(cachedDevices))
-			for _, d := range cachedDevices {
-				log.Printf("ffmpeg:   [%s] %s (id=%s, default=%v)", d.Kind, d.Label, d.DeviceID, d.IsDefault)
-			}
+	}
+	return m.discoverAllDevices(ctx)
+}
+
+// RefreshDevices 丢弃缓存并重新发现设备，返回值与 EnumerateDevices 相同。
+// 长时间运行的服务可在设备可能变化时（例如收到系统通知后）调用，无需重启进程。
+// 发现不完整（某个后端失败或超时）时返回部分设备和错误，缓存保持不变。
+func RefreshDevices() ([]MediaDeviceInfo, error) {
+	return defaultMediaDevices.RefreshDevicesContext(context.Background())
+}
+
+// RefreshDevices 与包级函数 RefreshDevices 相同，但刷新 m 的设备缓存。
+func (m *MediaDevices) RefreshDevices() ([]MediaDeviceInfo, error) {
+	return m.RefreshDevicesContext(context.Background())
+}
+
+// RefreshDevicesContext 与 RefreshDevices 相同，但发现过程受 ctx 控制，
+// 参见 EnumerateDevicesContext。
+func RefreshDevicesContext(ctx context.Context) ([]MediaDeviceInfo, error) {
+	return defaultMediaDevices.RefreshDevicesContext(ctx)
+}
+
+// RefreshDevicesContext 与包级函数 RefreshDevicesContext 相同，但刷新 m 的设备缓存。
+func (m *MediaDevices) RefreshDevicesContext(ctx context.Context) ([]MediaDeviceInfo, error) {
+	devices, err := m.refreshDevices(ctx)
+	return m.redactDevices(devices), err
+}
+
+// refreshDevices 绕过缓存重新发现设备，完整的结果写入缓存。
+func (m *MediaDevices) refreshDevices(ctx context.Context) ([]MediaDeviceInfo, error) {
+	if m.Config().DiscoverDevices == nil {
+		m.devicesMu.Lock()
+		defer m.devicesMu.Unlock()
+	}
+	devices, err := m.discoverAllDevices(ctx)
+	return m.withExtraDevices(devices), err
+}
+
+// discoverAllDevices 重新发现设备，完整的结果写入缓存。
+// 使用内置发现时调用方须持有 m.devicesMu。
+func (m *MediaDevices) discoverAllDevices(ctx context.Context) ([]MediaDeviceInfo, error) {
+	cfg := m.Config()
+	if cfg.DiscoverDevices != nil {
+		devices, err := cfg.DiscoverDevices(ctx)
+		return normalizeDevices(devices), err
+	}
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, m.enumerateTimeout())
+		defer cancel()
+	}
+
+	devices, err := discoverDevices(withBackendTimeout(ctx, cfg.DiscoveryTimeout), cfg.FFmpegPath)
+	devices = normalizeDevices(devices)
+	markLoopbackDevices(devices)
+	if err != nil {
+		err = fmt.Errorf("ffmpeg: device discovery: %w", err)
+		if cfg.Verbose {
+			log.Printf("%v (%d devices found)", err, len(devices))
 		}
-	})
-	return cachedDevices, cachedDevErr
+		return devices, err
+	}
+	if cfg.Verbose {
+		log.Printf("ffmpeg: discovered %d devices", len(devices))
+		for _, d := range devices {
+			log.Printf("ffmpeg:   [%s] %s (id=%s, default=%v)", d.Kind, d.Label, d.DeviceID, d.IsDefault)
+		}
+	}
+	m.cachedDevices, m.devicesCached, m.devicesCachedAt = devices, true, time.Now()
+	return devices, nil
 }
 
-// VideoInputDevices 返回所有可用的视频输入设备。
-func VideoInputDevices() ([]MediaDeviceInfo, error) {
-	all, err := EnumerateDevices()
+// withExtraDevices 在发现的设备之后追加不经过发现、不被缓存的设备：
+// 屏幕捕获来源、虚拟设备和合成设备，再按 Config.DeviceFilter 过滤。
+func (m *MediaDevices) withExtraDevices(devices []MediaDeviceInfo) []MediaDeviceInfo {
+	cfg := m.Config()
+	devices = withSyntheticDevices(cfg, m.withVirtualDevices(withDisplayDevices(cfg, devices)))
+	return cfg.DeviceFilter.apply(devices)
+}
+
+// devicesByKind 返回指定类型的设备（未经隐私处理）。
+func (m *MediaDevices) devicesByKind(ctx context.Context, kind MediaDeviceKind) ([]MediaDeviceInfo, error) {
+	all, err := m.enumerateDevicesRaw(ctx)
 	if err != nil {
 		return nil, err
 	}
 	var result []MediaDeviceInfo
 	for _, d := range all {
-		if d.Kind == MediaDeviceKindVideoInput {
+		if d.Kind == kind {
 			result = append(result, d)
 		}
 	}
 	return result, nil
 }
 
+// VideoInputDevices 返回所有可用的视频输入设备。
+func VideoInputDevices() ([]MediaDeviceInfo, error) {
+	return defaultMediaDevices.VideoInputDevices()
+}
+
+// VideoInputDevices 与包级函数 VideoInputDevices 相同，但使用 m 的配置和设备缓存。
+func (m *MediaDevices) VideoInputDevices() ([]MediaDeviceInfo, error) {
+	devices, err := m.devicesByKind(context.Background(), MediaDeviceKindVideoInput)
+	if err != nil {
+		return nil, err
+	}
+	return m.redactDevices(devices), nil
+}
+
 // AudioInputDevices 返回所有可用的音频输入设备。
 func AudioInputDevices() ([]MediaDeviceInfo, error) {
-	all, err := EnumerateDevices()
+	return defaultMediaDevices.AudioInputDevices()
+}
+
+// AudioInputDevices 与包级函数 AudioInputDevices 相同，但使用 m 的配置和设备缓存。
+func (m *MediaDevices) AudioInputDevices() ([]MediaDeviceInfo, error) {
+	devices, err := m.devicesByKind(context.Background(), MediaDeviceKindAudioInput)
 	if err != nil {
 		return nil, err
 	}
-	var result []MediaDeviceInfo
-	for _, d := range all {
-		if d.Kind == MediaDeviceKindAudioInput {
-			result = append(result, d)
+	return m.redactDevices(devices), nil
+}
+
+// LoopbackAudioDevices 返回采集系统播放声音（"what you hear"）的音频输入设备，
+// 即 AudioInputDevices 中 Loopback 为 true 的设备。其 DeviceID 可用于
+// GetUserMedia 的音频约束或 NewAudioReader，例如录制屏幕时一并录制系统声音。
+func LoopbackAudioDevices() ([]MediaDeviceInfo, error) {
+	return defaultMediaDevices.LoopbackAudioDevices()
+}
+
+// LoopbackAudioDevices 与包级函数 LoopbackAudioDevices 相同，但使用 m 的配置和设备缓存。
+func (m *MediaDevices) LoopbackAudioDevices() ([]MediaDeviceInfo, error) {
+	devices, err := m.devicesByKind(context.Background(), MediaDeviceKindAudioInput)
+	if err != nil {
+		return nil, err
+	}
+	var loopback []MediaDeviceInfo
+	for _, d := range devices {
+		if d.Loopback {
+			loopback = append(loopback, d)
 		}
 	}
-	return result, nil
+	return m.redactDevices(loopback), nil
 }
 
 // AudioOutputDevices 返回所有可用的音频输出设备。
-// 注意：当前实现中 FFmpeg 不支持列出音频输出设备，此函数可能返回空切片。
+// Windows 通过 Core Audio（WASAPI）列出播放终结点，macOS 列出 AudioToolbox 可播放的设备，
+// Linux 列出带播放 PCM 的 ALSA 声卡和 PulseAudio/PipeWire 的 sink，
+// FreeBSD 列出可播放的 OSS 设备，OpenBSD 列出 sndiod 的默认设备；Android 没有输出设备。
+// 输出设备与同一物理设备（如 USB 耳机）的麦克风共享 GroupID。
+// 可用 NewAudioWriter 向其播放音频。
 func AudioOutputDevices() ([]MediaDeviceInfo, error) {
-	all, err := EnumerateDevices()
+	return defaultMediaDevices.AudioOutputDevices()
+}
+
+// AudioOutputDevices 与包级函数 AudioOutputDevices 相同，但使用 m 的配置和设备缓存。
+func (m *MediaDevices) AudioOutputDevices() ([]MediaDeviceInfo, error) {
+	devices, err := m.devicesByKind(context.Background(), MediaDeviceKindAudioOutput)
 	if err != nil {
 		return nil, err
 	}
-	var result []MediaDeviceInfo
-	for _, d := range all {
-		if d.Kind == MediaDeviceKindAudioOutput {
-			result = append(result, d)
+	return m.redactDevices(devices), nil
+}
+
+// AudioInputForVideo 返回与视频输入设备属于同一物理设备（GroupID 相同）的音频输入设备，
+// 如摄像头内置的麦克风。有多个匹配时优先返回默认设备。
+//
+// GroupID 在 Windows 上为设备容器 ID（内置设备共享计算机的容器），
+// 在 Linux 上为 USB 设备的 sysfs 路径，在 macOS 上为 AVCaptureDevice uniqueID 中的
+// USB 位置 ID（内置设备共享 "builtin"）。
+func AudioInputForVideo(video MediaDeviceInfo) (MediaDeviceInfo, error) {
+	return defaultMediaDevices.AudioInputForVideo(video)
+}
+
+// AudioInputForVideo 与包级函数 AudioInputForVideo 相同，但在 m 的设备中查找。
+func (m *MediaDevices) AudioInputForVideo(video MediaDeviceInfo) (MediaDeviceInfo, error) {
+	devices, err := m.AudioInputDevices()
+	if err != nil {
+		return MediaDeviceInfo{}, err
+	}
+	var group []MediaDeviceInfo
+	for _, d := range devices {
+		if d.GroupID != "" && d.GroupID == video.GroupID {
+			group = append(group, d)
 		}
 	}
-	return result, nil
+	d, ok := pickDefaultDevice(group)
+	if !ok {
+		return MediaDeviceInfo{}, fmt.Errorf("no audio input device in the group of %s", video.DeviceID)
+	}
+	return d, nil
 }
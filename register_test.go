@@ -0,0 +1,83 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEnumerateDevicesContext_Canceled(t *testing.T) {
+	defaultMediaDevices.devicesMu.Lock()
+	saved, savedOK := defaultMediaDevices.cachedDevices, defaultMediaDevices.devicesCached
+	defaultMediaDevices.devicesCached = false
+	defaultMediaDevices.devicesMu.Unlock()
+	defer func() {
+		defaultMediaDevices.devicesMu.Lock()
+		defaultMediaDevices.cachedDevices, defaultMediaDevices.devicesCached = saved, savedOK
+		defaultMediaDevices.devicesMu.Unlock()
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := EnumerateDevicesContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	defaultMediaDevices.devicesMu.Lock()
+	defer defaultMediaDevices.devicesMu.Unlock()
+	if defaultMediaDevices.devicesCached {
+		t.Error("partial result was cached")
+	}
+}
+
+func TestRefreshDevicesAndCacheTTL(t *testing.T) {
+	defaultMediaDevices.devicesMu.Lock()
+	saved, savedOK, savedAt := defaultMediaDevices.cachedDevices, defaultMediaDevices.devicesCached, defaultMediaDevices.devicesCachedAt
+	defaultMediaDevices.devicesMu.Unlock()
+	orig := GetConfig()
+	defer func() {
+		SetConfig(orig)
+		defaultMediaDevices.devicesMu.Lock()
+		defaultMediaDevices.cachedDevices, defaultMediaDevices.devicesCached, defaultMediaDevices.devicesCachedAt = saved, savedOK, savedAt
+		defaultMediaDevices.devicesMu.Unlock()
+	}()
+
+	stale := []MediaDeviceInfo{{DeviceID: "unplugged-cam", Kind: MediaDeviceKindVideoInput}}
+	setStale := func(at time.Time) {
+		defaultMediaDevices.devicesMu.Lock()
+		defaultMediaDevices.cachedDevices, defaultMediaDevices.devicesCached, defaultMediaDevices.devicesCachedAt = stale, true, at
+		defaultMediaDevices.devicesMu.Unlock()
+	}
+	hasStale := func(devices []MediaDeviceInfo) bool {
+		return slices.ContainsFunc(devices, func(d MediaDeviceInfo) bool { return d.DeviceID == "unplugged-cam" })
+	}
+
+	// Without a TTL the cache never expires.
+	setStale(time.Now().Add(-time.Hour))
+	if devices, _ := EnumerateDevices(); !hasStale(devices) {
+		t.Fatal("cache not used without a TTL")
+	}
+
+	cfg := orig
+	cfg.DeviceCacheTTL = time.Minute
+	SetConfig(cfg)
+	if devices, _ := EnumerateDevices(); hasStale(devices) {
+		t.Error("expired cache used")
+	}
+	setStale(time.Now())
+	if devices, _ := EnumerateDevices(); !hasStale(devices) {
+		t.Error("fresh cache not used")
+	}
+
+	devices, err := RefreshDevices()
+	if err != nil {
+		t.Skipf("discovery failed on this host: %v", err)
+	}
+	if hasStale(devices) {
+		t.Error("RefreshDevices returned the cached devices")
+	}
+	if devices, _ := EnumerateDevices(); hasStale(devices) {
+		t.Error("RefreshDevices did not replace the cache")
+	}
+}
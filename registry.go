@@ -0,0 +1,169 @@
+package mediadevices
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"sync"
+)
+
+// ResourceCounts reports the resources that are currently active, as
+// returned by ActiveResources.
+type ResourceCounts struct {
+	// Processes is the number of FFmpeg subprocesses that have been started
+	// and not yet stopped and reaped.
+	Processes int
+	// Tracks is the number of tracks that have not been stopped.
+	Tracks int
+	// Readers is the number of encoded readers (H.264 and RTP) that have
+	// not been closed.
+	Readers int
+	// Recorders is the number of started MediaRecorders that have not been
+	// stopped.
+	Recorders int
+}
+
+// resourceRegistry is the registry of everything of a MediaDevices that
+// owns an FFmpeg subprocess, directly or indirectly.
+type resourceRegistry struct {
+	owner *MediaDevices
+
+	mu        sync.Mutex
+	procs     map[*ffmpegProcess]struct{}
+	tracks    map[*MediaStreamTrack]struct{}
+	readers   map[*H264VideoReader]struct{}
+	recorders map[*MediaRecorder]struct{}
+}
+
+func newResourceRegistry(owner *MediaDevices) *resourceRegistry {
+	return &resourceRegistry{
+		owner:     owner,
+		procs:     make(map[*ffmpegProcess]struct{}),
+		tracks:    make(map[*MediaStreamTrack]struct{}),
+		readers:   make(map[*H264VideoReader]struct{}),
+		recorders: make(map[*MediaRecorder]struct{}),
+	}
+}
+
+// ActiveResources returns the number of active processes, tracks, readers
+// and recorders. Tests can use it to check that everything they started was
+// released:
+//
+//	defer func() {
+//		if n := mediadevices.ActiveResources(); n != (mediadevices.ResourceCounts{}) {
+//			t.Errorf("leaked resources: %+v", n)
+//		}
+//	}()
+func ActiveResources() ResourceCounts {
+	return defaultMediaDevices.ActiveResources()
+}
+
+// ActiveResources is like the package-level ActiveResources, but counts
+// the resources of m only.
+func (m *MediaDevices) ActiveResources() ResourceCounts {
+	reg := m.resources
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	return ResourceCounts{
+		Processes: len(reg.procs),
+		Tracks:    len(reg.tracks),
+		Readers:   len(reg.readers),
+		Recorders: len(reg.recorders),
+	}
+}
+
+// CloseAll releases every active resource of the package, so that a
+// service can guarantee on shutdown that no FFmpeg child outlives it.
+// Recorders are stopped first so that their files are finalized, then
+// encoded readers are closed and tracks stopped; any FFmpeg process still
+// running after that is terminated. It returns the errors of the recorders
+// and readers joined.
+//
+// Resources created concurrently with CloseAll may survive it.
+func CloseAll() error {
+	return defaultMediaDevices.CloseAll()
+}
+
+// CloseAll is like the package-level CloseAll, but releases the resources
+// of m only.
+func (m *MediaDevices) CloseAll() error {
+	m.cancelAllPrewarms()
+
+	reg := m.resources
+	reg.mu.Lock()
+	recorders := slices.Collect(maps.Keys(reg.recorders))
+	readers := slices.Collect(maps.Keys(reg.readers))
+	tracks := slices.Collect(maps.Keys(reg.tracks))
+	reg.mu.Unlock()
+
+	var errs []error
+	for _, r := range recorders {
+		errs = append(errs, r.Stop())
+	}
+	for _, r := range readers {
+		errs = append(errs, r.Close())
+	}
+	for _, t := range tracks {
+		t.Stop()
+	}
+
+	reg.mu.Lock()
+	procs := slices.Collect(maps.Keys(reg.procs))
+	reg.mu.Unlock()
+	for _, p := range procs {
+		p.Stop()
+	}
+	return errors.Join(errs...)
+}
+
+func (reg *resourceRegistry) addProcess(p *ffmpegProcess) {
+	reg.mu.Lock()
+	reg.procs[p] = struct{}{}
+	reg.mu.Unlock()
+}
+
+func (reg *resourceRegistry) removeProcess(p *ffmpegProcess) {
+	reg.mu.Lock()
+	delete(reg.procs, p)
+	reg.mu.Unlock()
+}
+
+// addTrack registers t, makes the owner of the registry the owner of t and
+// returns it.
+func (reg *resourceRegistry) addTrack(t *MediaStreamTrack) *MediaStreamTrack {
+	t.owner = reg.owner
+	reg.mu.Lock()
+	reg.tracks[t] = struct{}{}
+	reg.mu.Unlock()
+	return t
+}
+
+func (reg *resourceRegistry) removeTrack(t *MediaStreamTrack) {
+	reg.mu.Lock()
+	delete(reg.tracks, t)
+	reg.mu.Unlock()
+}
+
+func (reg *resourceRegistry) addReader(r *H264VideoReader) {
+	reg.mu.Lock()
+	reg.readers[r] = struct{}{}
+	reg.mu.Unlock()
+}
+
+func (reg *resourceRegistry) removeReader(r *H264VideoReader) {
+	reg.mu.Lock()
+	delete(reg.readers, r)
+	reg.mu.Unlock()
+}
+
+func (reg *resourceRegistry) addRecorder(r *MediaRecorder) {
+	reg.mu.Lock()
+	reg.recorders[r] = struct{}{}
+	reg.mu.Unlock()
+}
+
+func (reg *resourceRegistry) removeRecorder(r *MediaRecorder) {
+	reg.mu.Lock()
+	delete(reg.recorders, r)
+	reg.mu.Unlock()
+}
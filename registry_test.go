@@ -0,0 +1,42 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"image"
+	"testing"
+)
+
+func TestCloseAll(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{FFmpegPath: "/bin/sh"})
+
+	proc, err := startProcess(GetConfig(), []string{"-c", "exec sleep 30"})
+	if err != nil {
+		t.Fatalf("startProcess: %v", err)
+	}
+	track, err := NewVideoTrackFromFunc(func() image.Image { return image.NewGray(image.Rect(0, 0, 2, 2)) }, 30)
+	if err != nil {
+		t.Fatalf("NewVideoTrackFromFunc: %v", err)
+	}
+	n := ActiveResources()
+	if n.Processes < 1 || n.Tracks < 1 {
+		t.Fatalf("ActiveResources = %+v, want the process and track counted", n)
+	}
+
+	if err := CloseAll(); err != nil {
+		t.Errorf("CloseAll: %v", err)
+	}
+	if n := ActiveResources(); n != (ResourceCounts{}) {
+		t.Errorf("after CloseAll: %+v", n)
+	}
+	if track.ReadyState() != MediaStreamTrackStateEnded {
+		t.Error("track not stopped")
+	}
+	select {
+	case <-proc.done:
+	default:
+		t.Error("process still running")
+	}
+}
@@ -0,0 +1,126 @@
+package mediadevices
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DeviceReportDocument 是 DeviceReport 输出的 JSON 文档，可用 json.Unmarshal 读回。
+type DeviceReportDocument struct {
+	// GeneratedAt 报告生成时间（UTC）。
+	GeneratedAt time.Time `json:"generatedAt"`
+	// OS、Arch 运行平台（runtime.GOOS、runtime.GOARCH）。
+	OS   string `json:"os"`
+	Arch string `json:"arch"`
+	// FFmpeg 所用 FFmpeg 的信息。
+	FFmpeg FFmpegInfo `json:"ffmpeg"`
+	// Devices 枚举到的设备，遵循 Config.RedactLabels 的隐私处理。
+	Devices []MediaDeviceInfo `json:"devices"`
+	// DevicesError 设备枚举失败时的错误信息。
+	DevicesError string `json:"devicesError,omitempty"`
+	// SupportedConstraints 支持的轨道约束。
+	SupportedConstraints MediaTrackSupportedConstraints `json:"supportedConstraints"`
+	// Permissions 各设备类型的操作系统级访问权限，无法查询的类型不出现。
+	Permissions map[MediaDeviceKind]PermissionState `json:"permissions"`
+	// Monitors 屏幕捕获可用的显示器（不含窗口，以免泄露窗口标题）。
+	Monitors []DisplaySource `json:"monitors,omitempty"`
+}
+
+// FFmpegInfo 描述 FFmpeg 可执行文件。
+type FFmpegInfo struct {
+	// Path 配置的路径（Config.FFmpegPath）。
+	Path string `json:"path"`
+	// Version 版本号，如 "8.0"；无法运行时为空。
+	Version string `json:"version,omitempty"`
+	// Configuration 编译配置（./configure 参数）。
+	Configuration string `json:"configuration,omitempty"`
+	// Error 运行 ffmpeg -version 失败时的错误信息。
+	Error string `json:"error,omitempty"`
+}
+
+// DeviceReport 生成一份完整的、机器可读的 JSON 文档，包含设备列表、
+// 支持的约束、权限状态、显示器和 FFmpeg 版本，用于支持包和远程设备清点。
+// 单项信息获取失败时记录在文档中，不会使整个报告失败。
+func DeviceReport() ([]byte, error) {
+	return defaultMediaDevices.DeviceReport()
+}
+
+// DeviceReport 与包级函数 DeviceReport 相同，但报告 m 的设备和 FFmpeg。
+func (m *MediaDevices) DeviceReport() ([]byte, error) {
+	doc := m.buildDeviceReport()
+	return json.MarshalIndent(&doc, "", "  ")
+}
+
+// buildDeviceReport 收集 m 的报告内容。
+func (m *MediaDevices) buildDeviceReport() DeviceReportDocument {
+	cfg := m.Config()
+	doc := DeviceReportDocument{
+		GeneratedAt:          time.Now().UTC(),
+		OS:                   runtime.GOOS,
+		Arch:                 runtime.GOARCH,
+		FFmpeg:               probeFFmpeg(cfg.FFmpegPath),
+		SupportedConstraints: GetSupportedConstraints(),
+		Permissions:          make(map[MediaDeviceKind]PermissionState),
+	}
+
+	devices, err := m.EnumerateDevices()
+	if err != nil {
+		doc.DevicesError = err.Error()
+	}
+	doc.Devices = append([]MediaDeviceInfo{}, devices...)
+
+	for _, kind := range []MediaDeviceKind{MediaDeviceKindVideoInput, MediaDeviceKindAudioInput} {
+		if state, err := QueryPermissions(kind); err == nil {
+			doc.Permissions[kind] = state
+		}
+	}
+
+	if sources, err := monitorSources(cfg); err == nil {
+		doc.Monitors = sources[1:]
+	}
+	return doc
+}
+
+// probeFFmpeg 运行 ffmpeg -version 获取版本和编译配置。
+func probeFFmpeg(path string) FFmpegInfo {
+	info := FFmpegInfo{Path: path}
+	out, err := exec.Command(path, "-version").Output()
+	if err != nil {
+		info.Error = err.Error()
+		return info
+	}
+	info.Version, info.Configuration = parseFFmpegVersion(out)
+	return info
+}
+
+// parseFFmpegVersion 解析 ffmpeg -version 的输出：
+//
+//	ffmpeg version 8.0 Copyright (c) 2000-2025 the FFmpeg developers
+//	built with gcc 14.2.0
+//	configuration: --enable-gpl --enable-libx264 ...
+func parseFFmpegVersion(out []byte) (version, configuration string) {
+	sc := bufio.NewScanner(bytes.NewReader(out))
+	for sc.Scan() {
+		line := sc.Text()
+		if rest, ok := strings.CutPrefix(line, "ffmpeg version "); ok {
+			version, _, _ = strings.Cut(rest, " ")
+		} else if rest, ok := strings.CutPrefix(line, "configuration:"); ok {
+			configuration = strings.TrimSpace(rest)
+		}
+	}
+	return version, configuration
+}
+
+// String 返回 "ffmpeg <版本>"，便于日志输出。
+func (f FFmpegInfo) String() string {
+	if f.Version == "" {
+		return fmt.Sprintf("ffmpeg (%s): unavailable", f.Path)
+	}
+	return "ffmpeg " + f.Version
+}
@@ -0,0 +1,70 @@
+package mediadevices
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMediaDeviceInfo_JSONRoundTrip(t *testing.T) {
+	in := MediaDeviceInfo{
+		DeviceID:   "0b7c9a1e-0000-4000-8000-000000000001",
+		DeviceName: "/dev/video0",
+		GroupID:    "/devices/pci0000:00/usb1/1-4",
+		Kind:       MediaDeviceKindVideoInput,
+		Label:      "Integrated Camera",
+		IsDefault:  true,
+	}
+	data, err := json.Marshal(&in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out MediaDeviceInfo
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if out != in {
+		t.Errorf("round trip = %+v, want %+v", out, in)
+	}
+}
+
+func TestDeviceReportDocument_RoundTrip(t *testing.T) {
+	in := DeviceReportDocument{
+		OS:                   "linux",
+		Arch:                 "amd64",
+		FFmpeg:               FFmpegInfo{Path: "ffmpeg", Version: "8.0"},
+		Devices:              []MediaDeviceInfo{{DeviceID: "a", Kind: MediaDeviceKindAudioInput, Label: "Monitor of Speakers", Loopback: true}},
+		SupportedConstraints: GetSupportedConstraints(),
+		Permissions:          map[MediaDeviceKind]PermissionState{MediaDeviceKindVideoInput: PermissionStateGranted},
+	}
+	data, err := json.Marshal(&in)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	var out DeviceReportDocument
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(out.Devices) != 1 || out.Devices[0] != in.Devices[0] {
+		t.Errorf("Devices = %+v", out.Devices)
+	}
+	if out.FFmpeg != in.FFmpeg || out.SupportedConstraints != in.SupportedConstraints {
+		t.Errorf("report = %+v", out)
+	}
+	if out.Permissions[MediaDeviceKindVideoInput] != PermissionStateGranted {
+		t.Errorf("Permissions = %v", out.Permissions)
+	}
+}
+
+func TestParseFFmpegVersion(t *testing.T) {
+	out := []byte("ffmpeg version 8.0 Copyright (c) 2000-2025 the FFmpeg developers\n" +
+		"built with gcc 14.2.0 (GCC)\n" +
+		"configuration: --prefix=/usr --enable-gpl --enable-libx264\n" +
+		"libavutil      60.  8.100 / 60.  8.100\n")
+	version, conf := parseFFmpegVersion(out)
+	if version != "8.0" {
+		t.Errorf("version = %q", version)
+	}
+	if conf != "--prefix=/usr --enable-gpl --enable-libx264" {
+		t.Errorf("configuration = %q", conf)
+	}
+}
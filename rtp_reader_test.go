@@ -0,0 +1,67 @@
+package mediadevices
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRTPReader_SequenceRollover(t *testing.T) {
+	r := &RTPReader{seq: 65534, tsBase: 0xFFFFFFFF - 1000}
+
+	if got := r.nextSeq(); got != 65535 {
+		t.Fatalf("nextSeq = %d, want 65535", got)
+	}
+	if got := r.nextSeq(); got != 0 {
+		t.Fatalf("nextSeq after 65535 = %d, want 0", got)
+	}
+	if got := r.ExtendedSequenceNumber(); got != 1<<16 {
+		t.Errorf("ExtendedSequenceNumber = %d, want %d", got, 1<<16)
+	}
+	if got := r.timestamp(time.Second / 30); got != 1999 {
+		t.Errorf("timestamp across 2^32 = %d, want 1999", got)
+	}
+}
+
+func TestRTPReader_SSRCCollision(t *testing.T) {
+	r := &RTPReader{}
+	r.ssrc.Store(0x1234)
+
+	if r.CheckSSRCCollision(0x5678) {
+		t.Fatal("reported collision for a different SSRC")
+	}
+	if r.SSRC() != 0x1234 {
+		t.Fatalf("SSRC changed without collision: %#x", r.SSRC())
+	}
+	if !r.CheckSSRCCollision(0x1234) {
+		t.Fatal("collision not detected")
+	}
+	if ssrc := r.SSRC(); ssrc == 0x1234 || ssrc == 0 {
+		t.Errorf("SSRC after collision = %#x, want a new non-zero value", ssrc)
+	}
+}
+
+func TestRTPReader_FragmentsFitMTU(t *testing.T) {
+	r := &RTPReader{mtu: 100}
+	nal := &NALUnit{Type: 5, Data: make([]byte, 500)}
+	nal.Data[0] = 0x65
+
+	packets, err := r.nalToRTPMultiple(nal, true)
+	if err != nil {
+		t.Fatalf("nalToRTPMultiple: %v", err)
+	}
+	if len(packets) < 2 {
+		t.Fatalf("got %d packets, want FU-A fragments", len(packets))
+	}
+	for i, pkt := range packets {
+		data, _ := pkt.Marshal()
+		if len(data)+ipUDPHeaderSize > r.mtu {
+			t.Errorf("packet %d is %d bytes with IP/UDP headers, exceeds MTU %d", i, len(data)+ipUDPHeaderSize, r.mtu)
+		}
+		if pkt.Payload[1]&0x1F != 5 {
+			t.Errorf("packet %d FU header type = %d, want 5", i, pkt.Payload[1]&0x1F)
+		}
+		if last := i == len(packets)-1; pkt.Marker != last {
+			t.Errorf("packet %d marker = %v, want %v", i, pkt.Marker, last)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package mediadevices
+
+import (
+	"image"
+	"sync"
+	"time"
+)
+
+// sampleReorderWindow 是 OnSample 为排序等待其他轨道的最长时间。最早的样本等待超过该时长后，
+// 即使其他轨道还没有样本也会被交付，因此停顿的轨道不会阻塞整个流。
+const sampleReorderWindow = 100 * time.Millisecond
+
+// Sample 是 OnSample 交付的一个视频帧或一段音频。
+type Sample struct {
+	// Track 是样本所属的轨道。
+	Track *MediaStreamTrack
+	// Kind 是样本类型，MediaDeviceKindVideoInput 或 MediaDeviceKindAudioInput。
+	Kind MediaDeviceKind
+	// Timestamp 是样本的捕获时间：启用 Config.UseWallclockTimestamps 时为 FFmpeg 的挂钟时间戳
+	// （FrameTimestamp、AudioChunk.Timestamp），否则为读取到样本的时间。
+	Timestamp time.Time
+	// Video 是视频帧，仅视频样本有效。
+	Video image.Image
+	// Audio 是音频块，仅音频样本有效。
+	Audio *AudioChunk
+}
+
+// sampleFeed 从流的轨道读取样本并按时间戳顺序交付给 OnSample 的订阅者。
+type sampleFeed struct {
+	mu     sync.Mutex
+	subs   map[int]func(Sample)
+	nextID int
+	stop   chan struct{}
+}
+
+// pendingSample 是读取 goroutine 交给合并 goroutine 的样本或轨道结束通知。
+type pendingSample struct {
+	track   int
+	sample  Sample
+	arrived time.Time
+	ended   bool
+}
+
+// OnSample 订阅流中所有轨道的样本：视频帧和音频块交错成一个按 Timestamp 排序的序列，
+// 便于只接受单一有序输入的复用器和 WebRTC 发送端使用。
+//
+// 订阅时流中的轨道由 OnSample 读取，应用程序不应同时调用它们的 Read 或 ReadAudio；
+// 之后添加的轨道不包括在内。fn 在同一个 goroutine 中依次调用，不应长时间阻塞，
+// 否则读取也随之停顿。多个订阅者收到相同的样本。
+//
+// 为了排序，样本最多被延迟 sampleReorderWindow（100 毫秒）以等待其他轨道；
+// 落后超过该时长的轨道的样本按到达顺序交付，可能早于已交付样本的时间戳。
+// 同一轨道的样本总是按读取顺序交付。轨道结束后不再有它的样本。
+//
+// 返回的 cancel 取消订阅，可重复调用；最后一个订阅者取消后停止读取，
+// 正在进行的读取结束后其结果被丢弃。
+func (s *MediaStream) OnSample(fn func(Sample)) (cancel func()) {
+	f := &s.samples
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.subs == nil {
+		f.subs = make(map[int]func(Sample))
+	}
+	id := f.nextID
+	f.nextID++
+	f.subs[id] = fn
+	if f.stop == nil {
+		f.stop = make(chan struct{})
+		go f.run(s.GetTracks(), f.stop)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			f.mu.Lock()
+			defer f.mu.Unlock()
+			delete(f.subs, id)
+			if len(f.subs) == 0 && f.stop != nil {
+				close(f.stop)
+				f.stop = nil
+			}
+		})
+	}
+}
+
+// run 读取 tracks 并合并它们的样本，直到 stop 关闭或所有轨道结束。
+func (f *sampleFeed) run(tracks []*MediaStreamTrack, stop chan struct{}) {
+	in := make(chan pendingSample)
+	for i, t := range tracks {
+		go readSamples(i, t, in, stop)
+	}
+
+	queues := make([][]pendingSample, len(tracks))
+	open := len(tracks)
+	ended := make([]bool, len(tracks))
+	var timer *time.Timer
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+	for open > 0 || queuedSamples(queues) > 0 {
+		// 交付所有可以确定顺序的样本
+		for {
+			i := earliestSample(queues)
+			if i < 0 {
+				break
+			}
+			ready := time.Since(queues[i][0].arrived) >= sampleReorderWindow
+			if !ready {
+				ready = true
+				for j, q := range queues {
+					if len(q) == 0 && !ended[j] {
+						ready = false
+						break
+					}
+				}
+			}
+			if !ready {
+				break
+			}
+			f.deliver(queues[i][0].sample)
+			queues[i] = queues[i][1:]
+		}
+		if open == 0 {
+			continue
+		}
+
+		var expire <-chan time.Time
+		if i := earliestSample(queues); i >= 0 {
+			wait := sampleReorderWindow - time.Since(queues[i][0].arrived)
+			if timer == nil {
+				timer = time.NewTimer(wait)
+			} else {
+				timer.Reset(wait)
+			}
+			expire = timer.C
+		}
+		select {
+		case <-stop:
+			return
+		case p := <-in:
+			if p.ended {
+				ended[p.track] = true
+				open--
+			} else {
+				queues[p.track] = append(queues[p.track], p)
+			}
+		case <-expire:
+		}
+	}
+}
+
+// deliver 将样本交给当前所有订阅者。
+func (f *sampleFeed) deliver(s Sample) {
+	f.mu.Lock()
+	subs := make([]func(Sample), 0, len(f.subs))
+	for _, fn := range f.subs {
+		subs = append(subs, fn)
+	}
+	f.mu.Unlock()
+	for _, fn := range subs {
+		fn(s)
+	}
+}
+
+// readSamples 读取轨道 t 的样本发送到 in，轨道结束时发送结束通知。
+func readSamples(i int, t *MediaStreamTrack, in chan<- pendingSample, stop <-chan struct{}) {
+	for {
+		p := pendingSample{track: i, sample: Sample{Track: t, Kind: t.Kind()}}
+		var err error
+		if t.Kind() == MediaDeviceKindVideoInput {
+			if p.sample.Video, err = t.Read(); err == nil {
+				p.sample.Timestamp = t.FrameTimestamp()
+			}
+		} else {
+			if p.sample.Audio, err = t.ReadAudio(); err == nil {
+				p.sample.Timestamp = p.sample.Audio.Timestamp
+			}
+		}
+		p.arrived = time.Now()
+		if err != nil {
+			p = pendingSample{track: i, ended: true}
+		} else if p.sample.Timestamp.IsZero() {
+			p.sample.Timestamp = p.arrived
+		}
+		select {
+		case in <- p:
+		case <-stop:
+			return
+		}
+		if p.ended {
+			return
+		}
+	}
+}
+
+// earliestSample 返回队首样本时间戳最早的队列，所有队列为空时返回 -1。
+func earliestSample(queues [][]pendingSample) int {
+	best := -1
+	for i, q := range queues {
+		if len(q) > 0 && (best < 0 || q[0].sample.Timestamp.Before(queues[best][0].sample.Timestamp)) {
+			best = i
+		}
+	}
+	return best
+}
+
+// queuedSamples 返回所有队列中的样本数。
+func queuedSamples(queues [][]pendingSample) int {
+	n := 0
+	for _, q := range queues {
+		n += len(q)
+	}
+	return n
+}
@@ -0,0 +1,136 @@
+package mediadevices
+
+import (
+	"image"
+	"io"
+	"testing"
+	"time"
+)
+
+// stampedVideoSource returns n frames stamped at start + i*step.
+type stampedVideoSource struct {
+	start time.Time
+	step  time.Duration
+	n, i  int
+	ts    time.Time
+}
+
+func (s *stampedVideoSource) Read() (image.Image, error) {
+	if s.i == s.n {
+		return nil, io.EOF
+	}
+	s.ts = s.start.Add(time.Duration(s.i) * s.step)
+	s.i++
+	return image.NewGray(image.Rect(0, 0, 2, 2)), nil
+}
+
+func (s *stampedVideoSource) Timestamp() time.Time { return s.ts }
+func (s *stampedVideoSource) Close() error         { return nil }
+func (s *stampedVideoSource) Width() int           { return 2 }
+func (s *stampedVideoSource) Height() int          { return 2 }
+
+// stampedAudioSource returns n chunks stamped at start + i*step, and blocks
+// before the first one until release is closed.
+type stampedAudioSource struct {
+	start   time.Time
+	step    time.Duration
+	n, i    int
+	release chan struct{}
+}
+
+func (s *stampedAudioSource) Read() (*AudioChunk, error) {
+	<-s.release
+	if s.i == s.n {
+		return nil, io.EOF
+	}
+	c := &AudioChunk{Data: make([]int16, 2), Channels: 1, SampleRate: 100, SamplesPerChannel: 2, Timestamp: s.start.Add(time.Duration(s.i) * s.step)}
+	s.i++
+	return c, nil
+}
+
+func (s *stampedAudioSource) Close() error    { return nil }
+func (s *stampedAudioSource) SampleRate() int { return 100 }
+func (s *stampedAudioSource) Channels() int   { return 1 }
+
+func sampleStream(t *testing.T, video *stampedVideoSource, audio *stampedAudioSource) *MediaStream {
+	t.Helper()
+	s := NewMediaStream()
+	v := newCustomTrack(MediaDeviceKindVideoInput, "video", video, nil)
+	time.Sleep(time.Microsecond) // distinct track IDs
+	a := newCustomTrack(MediaDeviceKindAudioInput, "audio", nil, audio)
+	s.AddTrack(v)
+	s.AddTrack(a)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func collectSamples(fn func(func(Sample)) func(), n int, timeout time.Duration) []Sample {
+	got := make(chan Sample, n)
+	cancel := fn(func(s Sample) { got <- s })
+	defer cancel()
+	var samples []Sample
+	deadline := time.After(timeout)
+	for len(samples) < n {
+		select {
+		case s := <-got:
+			samples = append(samples, s)
+		case <-deadline:
+			return samples
+		}
+	}
+	return samples
+}
+
+func TestOnSample_Interleaved(t *testing.T) {
+	start := time.Now()
+	release := make(chan struct{})
+	close(release)
+	s := sampleStream(t,
+		&stampedVideoSource{start: start, step: 40 * time.Millisecond, n: 5},
+		&stampedAudioSource{start: start.Add(10 * time.Millisecond), step: 20 * time.Millisecond, n: 10, release: release})
+
+	samples := collectSamples(s.OnSample, 15, 5*time.Second)
+	if len(samples) != 15 {
+		t.Fatalf("got %d samples, want 15", len(samples))
+	}
+	video := 0
+	for i, smp := range samples {
+		if i > 0 && smp.Timestamp.Before(samples[i-1].Timestamp) {
+			t.Errorf("sample %d at %v is before sample %d at %v", i, smp.Timestamp.Sub(start), i-1, samples[i-1].Timestamp.Sub(start))
+		}
+		switch smp.Kind {
+		case MediaDeviceKindVideoInput:
+			video++
+			if smp.Video == nil || smp.Audio != nil {
+				t.Errorf("sample %d: video sample without frame", i)
+			}
+		case MediaDeviceKindAudioInput:
+			if smp.Audio == nil || smp.Video != nil || smp.Track.Kind() != MediaDeviceKindAudioInput {
+				t.Errorf("sample %d: audio sample without chunk", i)
+			}
+		}
+	}
+	if video != 5 {
+		t.Errorf("got %d video samples, want 5", video)
+	}
+}
+
+func TestOnSample_StalledTrack(t *testing.T) {
+	start := time.Now()
+	release := make(chan struct{})
+	defer close(release)
+	s := sampleStream(t,
+		&stampedVideoSource{start: start, step: 40 * time.Millisecond, n: 3},
+		&stampedAudioSource{start: start, step: 20 * time.Millisecond, n: 1, release: release})
+
+	// The audio track never delivers: the video is released after the
+	// reorder window.
+	begin := time.Now()
+	samples := collectSamples(s.OnSample, 3, 5*time.Second)
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want the 3 video frames", len(samples))
+	}
+	if d := time.Since(begin); d < sampleReorderWindow {
+		t.Errorf("delivered after %v, before the reorder window", d)
+	}
+}
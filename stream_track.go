@@ -30,54 +30,98 @@ type MediaStreamTrack struct {
 	enabled     atomic.Bool
 	readyState  MediaStreamTrackState
 
-	// 内部：实际读取器
-	videoReader *VideoReader
-	audioReader *AudioReader
+	// 内部：实际读取器（FFmpeg 捕获或应用程序提供的数据源）
+	videoReader videoSource
+	audioReader audioSource
+
+	// pending 是上一次 ReadBatch 提前返回时仍在进行的读取，下一次读取先取其结果
+	pending chan frameResult
+	// analysis 是由 AnalysisStream 派生的低分辨率轨道的数据源
+	analysis []*analysisSource
+	// health 记录帧的到达，供 Health 评分
+	health healthMeter
+	// owner 是创建轨道的 MediaDevices，轨道登记在其中，SwitchDevice 使用其配置和设备
+	owner *MediaDevices
+	// device 是轨道当前捕获的设备，不是从设备捕获的轨道为零值
+	device MediaDeviceInfo
+	// caps 是 GetCapabilities 查询到的 device 的能力，尚未查询时为 nil
+	caps *DeviceCapabilities
+	// reconfigMu 串行化 SwitchDevice 和 ApplyConstraints
+	reconfigMu sync.Mutex
+	// settingsListeners 是 OnSettingsChange 的订阅者
+	settingsListeners map[int]func(SettingsChangeEvent)
+	settingsNext      int
 
 	// 用于同步访问
 	mu sync.Mutex
 }
 
-// newVideoTrack 创建一个新的视频轨道。
-func newVideoTrack(deviceInfo MediaDeviceInfo, width, height int, frameRate float64) (*MediaStreamTrack, error) {
-	// Use DeviceName if available (for FFmpeg), otherwise fallback to DeviceID
-	deviceName := deviceInfo.DeviceName
-	if deviceName == "" {
-		deviceName = deviceInfo.DeviceID
+// videoSource 是视频轨道的数据来源。
+// *VideoReader 从 FFmpeg 读取，也可以由应用程序代码提供。
+type videoSource interface {
+	Read() (image.Image, error)
+	Close() error
+	Width() int
+	Height() int
+}
+
+// audioSource 是音频轨道的数据来源。
+// *AudioReader 从 FFmpeg 读取，也可以由应用程序代码提供。
+type audioSource interface {
+	Read() (*AudioChunk, error)
+	Close() error
+	SampleRate() int
+	Channels() int
+}
+
+// sourceFrameRate 返回视频数据源的帧率，数据源未提供帧率时返回 0。
+func sourceFrameRate(src videoSource) float64 {
+	if fr, ok := src.(interface{ FrameRate() float64 }); ok {
+		return fr.FrameRate()
 	}
-	reader, err := newVideoReaderInternal(deviceName, width, height, frameRate)
+	return 0
+}
+
+// sourceLatency 返回音频数据源每段音频的时长，数据源未提供时返回 0。
+func sourceLatency(src audioSource) time.Duration {
+	if l, ok := src.(interface{ Latency() time.Duration }); ok {
+		return l.Latency()
+	}
+	return 0
+}
+
+// newVideoTrack 创建一个新的视频轨道。
+func (m *MediaDevices) newVideoTrack(deviceInfo MediaDeviceInfo, width, height int, frameRate float64) (*MediaStreamTrack, error) {
+	reader, err := m.newVideoReaderInternal(ffmpegDeviceName(deviceInfo), width, height, frameRate, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create video reader: %w", err)
 	}
 
-	return &MediaStreamTrack{
+	return m.resources.addTrack(&MediaStreamTrack{
 		id:          generateTrackID(),
 		kind:        MediaDeviceKindVideoInput,
 		label:       deviceInfo.Label,
 		readyState:  MediaStreamTrackStateLive,
 		videoReader:  reader,
-	}, nil
+		device:      deviceInfo,
+	}), nil
 }
 
-// newAudioTrack 创建一个新的音频轨道。
-func newAudioTrack(deviceInfo MediaDeviceInfo, sampleRate, channels int) (*MediaStreamTrack, error) {
-	// Use DeviceName if available (for FFmpeg), otherwise fallback to DeviceID
-	deviceName := deviceInfo.DeviceName
-	if deviceName == "" {
-		deviceName = deviceInfo.DeviceID
-	}
-	reader, err := newAudioReaderInternal(deviceName, sampleRate, channels)
+// newAudioTrack 以 cfg 的格式和延迟创建一个新的音频轨道，cfg 的设备字段被忽略。
+func (m *MediaDevices) newAudioTrack(deviceInfo MediaDeviceInfo, cfg AudioConfig) (*MediaStreamTrack, error) {
+	reader, err := m.newAudioReaderInternal(ffmpegDeviceName(deviceInfo), cfg)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create audio reader: %w", err)
 	}
 
-	return &MediaStreamTrack{
+	return m.resources.addTrack(&MediaStreamTrack{
 		id:          generateTrackID(),
 		kind:        MediaDeviceKindAudioInput,
 		label:       deviceInfo.Label,
 		readyState:  MediaStreamTrackStateLive,
 		audioReader: reader,
-	}, nil
+		device:      deviceInfo,
+	}), nil
 }
 
 // ID 返回轨道的唯一标识符。
@@ -95,6 +139,8 @@ func (t *MediaStreamTrack) Kind() MediaDeviceKind {
 // Label 返回轨道的标签。
 // 对应 MDN 的 MediaStreamTrack.label。
 func (t *MediaStreamTrack) Label() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	return t.label
 }
 
@@ -136,8 +182,13 @@ func (t *MediaStreamTrack) Stop() {
 		t.audioReader.Close()
 		t.audioReader = nil
 	}
+	for _, a := range t.analysis {
+		a.end()
+	}
+	t.analysis = nil
 
 	t.readyState = MediaStreamTrackStateEnded
+	t.owner.resources.removeTrack(t)
 }
 
 // Close 是 Stop 的别名，用于与 io.Closer 接口兼容。
@@ -149,27 +200,89 @@ func (t *MediaStreamTrack) Close() error {
 // Read 读取一帧视频数据。
 // 仅在视频轨道上有效。
 // 返回 io.EOF 当流结束时。
+// 若读取期间数据源被 SwitchDevice 替换，会自动从新数据源继续读取。
 func (t *MediaStreamTrack) Read() (image.Image, error) {
 	if t.kind != MediaDeviceKindVideoInput {
 		return nil, fmt.Errorf("cannot read video from non-video track")
 	}
-	if t.videoReader == nil {
-		return nil, io.EOF
+	if c := t.takePending(); c != nil {
+		res := <-c
+		return res.img, res.err
+	}
+	return t.readVideo()
+}
+
+// readVideo 从当前视频数据源读取一帧。
+func (t *MediaStreamTrack) readVideo() (image.Image, error) {
+	for {
+		t.mu.Lock()
+		r := t.videoReader
+		t.mu.Unlock()
+		if r == nil {
+			return nil, io.EOF
+		}
+
+		img, err := r.Read()
+		if err != nil && t.sourceReplaced(r, nil) {
+			continue
+		}
+		if err == nil {
+			t.health.observe(time.Now(), sourceFrameRate(r), sourceWarnings(r))
+			t.offerAnalysis(img)
+		}
+		return img, err
+	}
+}
+
+// FrameTimestamp 返回最近一次 Read 返回的帧的捕获时间（挂钟时间）。
+// 仅在启用 Config.UseWallclockTimestamps 时有效，否则或数据源不提供时间戳时返回零值。
+// 与 AudioChunk.Timestamp 使用同一时钟，可用于对齐来自不同设备的音视频。
+func (t *MediaStreamTrack) FrameTimestamp() time.Time {
+	t.mu.Lock()
+	r := t.videoReader
+	t.mu.Unlock()
+	if ts, ok := r.(interface{ Timestamp() time.Time }); ok {
+		return ts.Timestamp()
 	}
-	return t.videoReader.Read()
+	return time.Time{}
 }
 
 // ReadAudio 读取一段音频数据。
 // 仅在音频轨道上有效。
 // 返回 io.EOF 当流结束时。
+// 若读取期间数据源被 SwitchDevice 替换，会自动从新数据源继续读取。
 func (t *MediaStreamTrack) ReadAudio() (*AudioChunk, error) {
 	if t.kind != MediaDeviceKindAudioInput {
 		return nil, fmt.Errorf("cannot read audio from non-audio track")
 	}
-	if t.audioReader == nil {
-		return nil, io.EOF
+	for {
+		t.mu.Lock()
+		r := t.audioReader
+		t.mu.Unlock()
+		if r == nil {
+			return nil, io.EOF
+		}
+
+		chunk, err := r.Read()
+		if err != nil && t.sourceReplaced(nil, r) {
+			continue
+		}
+		if err == nil && chunk.SamplesPerChannel > 0 {
+			chunkRate := float64(chunk.SampleRate) / float64(chunk.SamplesPerChannel)
+			t.health.observe(time.Now(), chunkRate, sourceWarnings(r))
+		}
+		return chunk, err
+	}
+}
+
+// sourceReplaced 判断读取所用的数据源是否已被替换为另一个仍然有效的数据源。
+func (t *MediaStreamTrack) sourceReplaced(video videoSource, audio audioSource) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if video != nil {
+		return t.videoReader != nil && t.videoReader != video
 	}
-	return t.audioReader.Read()
+	return t.audioReader != nil && t.audioReader != audio
 }
 
 // GetSettings 返回轨道的当前设置。
@@ -183,13 +296,16 @@ func (t *MediaStreamTrack) GetSettings() MediaTrackSettings {
 	if t.videoReader != nil {
 		settings.Width = t.videoReader.Width()
 		settings.Height = t.videoReader.Height()
-		// FrameRate 需要额外计算或存储
+		settings.FrameRate = sourceFrameRate(t.videoReader)
 		settings.AspectRatio = float64(settings.Width) / float64(settings.Height)
+		settings.FacingMode = t.device.FacingMode
 	}
 	if t.audioReader != nil {
 		settings.SampleRate = t.audioReader.SampleRate()
+		settings.ChannelCount = t.audioReader.Channels()
 		// SampleSize 固定为 16 (S16LE)
 		settings.SampleSize = 16
+		settings.Latency = sourceLatency(t.audioReader)
 	}
 
 	return settings
@@ -202,6 +318,9 @@ type MediaStream struct {
 	tracks map[string]*MediaStreamTrack
 	active atomic.Bool
 	mu     sync.RWMutex
+
+	// samples 是 OnSample 的订阅与读取状态
+	samples sampleFeed
 }
 
 // NewMediaStream 创建一个新的空媒体流。
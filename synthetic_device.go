@@ -0,0 +1,77 @@
+package mediadevices
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// syntheticDevicePrefix 是合成设备的 DeviceID 和 FFmpeg 设备名前缀，其后为 lavfi 源的名称，
+// 如 "lavfi:testsrc2"。
+const syntheticDevicePrefix = "lavfi:"
+
+// syntheticDevices 是启用 Config.SyntheticDevices 时列出的 FFmpeg lavfi 测试源。
+var syntheticDevices = []MediaDeviceInfo{
+	{DeviceID: "lavfi:testsrc2", Kind: MediaDeviceKindVideoInput, Label: "Test Pattern (testsrc2)"},
+	{DeviceID: "lavfi:smptebars", Kind: MediaDeviceKindVideoInput, Label: "SMPTE Color Bars (smptebars)"},
+	{DeviceID: "lavfi:sine", Kind: MediaDeviceKindAudioInput, Label: "440 Hz Sine Wave (sine)"},
+	{DeviceID: "lavfi:anullsrc", Kind: MediaDeviceKindAudioInput, Label: "Silence (anullsrc)"},
+}
+
+// isSyntheticDevice 判断 FFmpeg 设备名是否为合成设备。
+func isSyntheticDevice(name string) bool {
+	return strings.HasPrefix(name, syntheticDevicePrefix)
+}
+
+// withSyntheticDevices 在 cfg 启用 Config.SyntheticDevices 时，在设备列表末尾追加合成设备。
+// 不修改 devices（可能是缓存）的底层数组。
+func withSyntheticDevices(cfg Config, devices []MediaDeviceInfo) []MediaDeviceInfo {
+	if !cfg.SyntheticDevices {
+		return devices
+	}
+	devices = slices.Clip(devices)
+	for _, d := range syntheticDevices {
+		d.DeviceName = d.DeviceID
+		d.GroupID = syntheticDevicePrefix + "synthetic"
+		devices = append(devices, d)
+	}
+	return devices
+}
+
+// buildSyntheticVideoInputArgs 构建合成视频设备的 FFmpeg 输入参数：以请求的尺寸和帧率
+// 生成测试图案。lavfi 源生成的速度不受限制，-re 使其按实时速度输出，与摄像头一致。
+func buildSyntheticVideoInputArgs(p VideoCaptureParams) []string {
+	src := strings.TrimPrefix(p.DeviceID, syntheticDevicePrefix)
+	frameRate := p.FrameRate
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+	if p.Width > 0 && p.Height > 0 {
+		src += fmt.Sprintf("=size=%dx%d:rate=%g", p.Width, p.Height, frameRate)
+	} else {
+		src += fmt.Sprintf("=rate=%g", frameRate)
+	}
+	args := []string{"-re", "-f", "lavfi"}
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+	return append(args, "-i", src)
+}
+
+// buildSyntheticAudioInputArgs 构建合成音频设备的 FFmpeg 输入参数，采样率为请求的采样率，
+// 声道数由输出参数转换。
+func buildSyntheticAudioInputArgs(p AudioCaptureParams) []string {
+	src := strings.TrimPrefix(p.DeviceID, syntheticDevicePrefix)
+	sampleRate := p.SampleRate
+	if sampleRate <= 0 {
+		sampleRate = 48000
+	}
+	if src == "sine" {
+		src += fmt.Sprintf("=frequency=440:sample_rate=%d", sampleRate)
+	} else {
+		src += fmt.Sprintf("=sample_rate=%d", sampleRate)
+	}
+	args := []string{"-re", "-f", "lavfi"}
+	args = append(args, profileInputArgs(p.Profile)...)
+	args = append(args, wallclockInputArgs(p.UseWallclockTimestamps)...)
+	return append(args, "-i", src)
+}
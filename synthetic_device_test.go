@@ -0,0 +1,56 @@
+package mediadevices
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestSyntheticDevices(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) { return nil, nil }
+	SetConfig(cfg)
+
+	if devices, _ := EnumerateDevices(); len(devices) != 0 {
+		t.Fatalf("synthetic devices listed without Config.SyntheticDevices: %+v", devices)
+	}
+	cfg.SyntheticDevices = true
+	SetConfig(cfg)
+	devices, err := EnumerateDevices()
+	if err != nil || len(devices) != 4 {
+		t.Fatalf("devices = %+v, %v", devices, err)
+	}
+	cam, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindVideoInput)
+	if err != nil || cam.DeviceID != "lavfi:testsrc2" {
+		t.Errorf("default camera = %+v, %v", cam, err)
+	}
+	mic, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindAudioInput)
+	if err != nil || mic.DeviceID != "lavfi:sine" {
+		t.Errorf("default microphone = %+v, %v", mic, err)
+	}
+	if caps, err := GetDeviceCapabilities("lavfi:smptebars"); err != nil || caps.VideoModes != nil {
+		t.Errorf("capabilities = %+v, %v; want no modes", caps, err)
+	}
+}
+
+func TestBuildSyntheticInputArgs(t *testing.T) {
+	got := buildVideoInputArgs(VideoCaptureParams{DeviceID: "lavfi:smptebars", Width: 1280, Height: 720, FrameRate: 25})
+	if want := []string{"-re", "-f", "lavfi", "-i", "smptebars=size=1280x720:rate=25"}; !slices.Equal(got, want) {
+		t.Errorf("video: got %v, want %v", got, want)
+	}
+	got = buildAudioInputArgs(AudioCaptureParams{DeviceID: "lavfi:sine", SampleRate: 16000, Channels: 2})
+	if want := []string{"-re", "-f", "lavfi", "-i", "sine=frequency=440:sample_rate=16000"}; !slices.Equal(got, want) {
+		t.Errorf("audio: got %v, want %v", got, want)
+	}
+	got = buildAudioInputArgs(AudioCaptureParams{DeviceID: "lavfi:anullsrc"})
+	if want := []string{"-re", "-f", "lavfi", "-i", "anullsrc=sample_rate=48000"}; !slices.Equal(got, want) {
+		t.Errorf("silence: got %v, want %v", got, want)
+	}
+	args := strings.Join(buildH264Args(H264ReaderConfig{DeviceName: "lavfi:testsrc2", Width: 640, Height: 360, FrameRate: 30}), " ")
+	if !strings.HasPrefix(args, "-re -f lavfi -i testsrc2=size=640x360:rate=30 -c:v libx264") {
+		t.Errorf("H.264 args: %s", args)
+	}
+}
@@ -0,0 +1,157 @@
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+	"io"
+	"slices"
+	"sync"
+	"time"
+)
+
+// AnalysisStream 返回一个从本轨道派生的低分辨率视频轨道，供人脸检测等分析任务使用，
+// 例如在 320x240@5fps 上运行检测器，同时录制保持原始质量。
+//
+// 派生轨道不另开设备或 FFmpeg 进程：本轨道每读出一帧，按 fps 抽帧并在 Go 中
+// 以最近邻采样缩放到 width x height 后交给派生轨道。因此本轨道必须持续被读取
+// （例如由 MediaRecorder 或应用程序），派生轨道才有数据。fps <= 0 表示不抽帧。
+//
+// 派生轨道只保留最新的一帧：分析跟不上时旧帧被丢弃，不会阻塞本轨道的读取。
+// 停止本轨道时派生轨道随之结束；停止派生轨道不影响本轨道。
+func (t *MediaStreamTrack) AnalysisStream(width, height int, fps float64) (*MediaStreamTrack, error) {
+	if t.kind != MediaDeviceKindVideoInput {
+		return nil, fmt.Errorf("analysis stream requires a video track")
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("analysis stream: width and height must be positive (got %dx%d)", width, height)
+	}
+
+	src := &analysisSource{
+		parent: t,
+		width:  width,
+		height: height,
+		fps:    fps,
+		frames: make(chan *image.YCbCr, 1),
+		done:   make(chan struct{}),
+	}
+	if fps > 0 {
+		src.interval = time.Duration(float64(time.Second) / fps)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.readyState == MediaStreamTrackStateEnded {
+		return nil, fmt.Errorf("analysis stream: track has ended")
+	}
+	t.analysis = append(t.analysis, src)
+	return t.owner.newCustomTrack(MediaDeviceKindVideoInput, t.label, src, nil), nil
+}
+
+// offerAnalysis 将刚读出的帧交给所有派生的分析轨道。
+func (t *MediaStreamTrack) offerAnalysis(img image.Image) {
+	t.mu.Lock()
+	taps := slices.Clone(t.analysis)
+	t.mu.Unlock()
+
+	now := time.Now()
+	for _, a := range taps {
+		a.offer(img, now)
+	}
+}
+
+// analysisSource 是 AnalysisStream 派生轨道的数据源。
+type analysisSource struct {
+	parent        *MediaStreamTrack
+	width, height int
+	fps           float64
+	interval      time.Duration
+
+	mu   sync.Mutex
+	next time.Time // 下一帧最早被采用的时间
+
+	frames  chan *image.YCbCr // 最新的一帧
+	done    chan struct{}
+	endOnce sync.Once
+}
+
+// offer 按帧率抽帧，采用的帧缩放后替换尚未读取的旧帧。
+func (s *analysisSource) offer(img image.Image, now time.Time) {
+	s.mu.Lock()
+	if now.Before(s.next) {
+		s.mu.Unlock()
+		return
+	}
+	// 按固定间隔推进，抖动不影响平均帧率；落后超过一个间隔时重新对齐
+	s.next = s.next.Add(s.interval)
+	if !s.next.After(now) {
+		s.next = now.Add(s.interval)
+	}
+	s.mu.Unlock()
+
+	frame := scaleYCbCr420(toYCbCr420(img), s.width, s.height)
+	for {
+		select {
+		case <-s.done:
+			return
+		case s.frames <- frame:
+			return
+		default:
+		}
+		select {
+		case <-s.frames:
+		default:
+		}
+	}
+}
+
+func (s *analysisSource) Read() (image.Image, error) {
+	select {
+	case img := <-s.frames:
+		return img, nil
+	case <-s.done:
+		return nil, io.EOF
+	}
+}
+
+// Close 将数据源从本轨道上摘下。
+func (s *analysisSource) Close() error {
+	t := s.parent
+	t.mu.Lock()
+	if i := slices.Index(t.analysis, s); i >= 0 {
+		t.analysis = slices.Delete(t.analysis, i, i+1)
+	}
+	t.mu.Unlock()
+	s.end()
+	return nil
+}
+
+// end 结束数据源，阻塞中的 Read 返回 io.EOF。
+func (s *analysisSource) end() {
+	s.endOnce.Do(func() { close(s.done) })
+}
+
+func (s *analysisSource) Width() int         { return s.width }
+func (s *analysisSource) Height() int        { return s.height }
+func (s *analysisSource) FrameRate() float64 { return s.fps }
+
+// scaleYCbCr420 以最近邻采样将 4:2:0 图像缩放为 w x h。
+func scaleYCbCr420(src *image.YCbCr, w, h int) *image.YCbCr {
+	b := src.Rect
+	if b.Dx() == w && b.Dy() == h {
+		return cloneYCbCr(src)
+	}
+	dst := image.NewYCbCr(image.Rect(0, 0, w, h), image.YCbCrSubsampleRatio420)
+	for y := 0; y < h; y++ {
+		sy := b.Min.Y + y*b.Dy()/h
+		for x := 0; x < w; x++ {
+			sx := b.Min.X + x*b.Dx()/w
+			dst.Y[dst.YOffset(x, y)] = src.Y[src.YOffset(sx, sy)]
+			if x%2 == 0 && y%2 == 0 {
+				si, di := src.COffset(sx, sy), dst.COffset(x, y)
+				dst.Cb[di] = src.Cb[si]
+				dst.Cr[di] = src.Cr[si]
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,77 @@
+package mediadevices
+
+import (
+	"image"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestAnalysisStream(t *testing.T) {
+	src := make(chanVideoSource, 8)
+	track := newCustomTrack(MediaDeviceKindVideoInput, "main", src, nil)
+
+	analysis, err := track.AnalysisStream(4, 2, 0)
+	if err != nil {
+		t.Fatalf("AnalysisStream: %v", err)
+	}
+	if s := analysis.GetSettings(); s.Width != 4 || s.Height != 2 {
+		t.Errorf("analysis settings = %dx%d, want 4x2", s.Width, s.Height)
+	}
+
+	full := image.NewYCbCr(image.Rect(0, 0, 8, 4), image.YCbCrSubsampleRatio420)
+	for i := range full.Y {
+		full.Y[i] = byte(i)
+	}
+	src <- full
+	src <- full
+	for range 2 {
+		if _, err := track.Read(); err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+	}
+
+	// Only the latest frame is kept.
+	img, err := analysis.Read()
+	if err != nil {
+		t.Fatalf("analysis Read: %v", err)
+	}
+	small := img.(*image.YCbCr)
+	if small.Rect.Dx() != 4 || small.Rect.Dy() != 2 {
+		t.Fatalf("analysis frame = %v", small.Rect)
+	}
+	// Nearest neighbour: (1,1) samples (2,2) of the source.
+	if got, want := small.Y[small.YOffset(1, 1)], full.Y[full.YOffset(2, 2)]; got != want {
+		t.Errorf("Y(1,1) = %d, want %d", got, want)
+	}
+	select {
+	case <-analysis.videoReader.(*analysisSource).frames:
+		t.Error("stale frame queued")
+	default:
+	}
+
+	track.Stop()
+	if _, err := analysis.Read(); err != io.EOF {
+		t.Errorf("analysis Read after Stop: err = %v", err)
+	}
+}
+
+func TestAnalysisSourceDecimation(t *testing.T) {
+	s := &analysisSource{width: 2, height: 2, interval: 200 * time.Millisecond, frames: make(chan *image.YCbCr, 1), done: make(chan struct{})}
+	frame := image.NewYCbCr(image.Rect(0, 0, 4, 4), image.YCbCrSubsampleRatio420)
+
+	// 30 fps in, 5 fps out: one frame in six.
+	start := time.Unix(0, 0)
+	taken := 0
+	for i := range 60 {
+		s.offer(frame, start.Add(time.Duration(i)*time.Second/30))
+		select {
+		case <-s.frames:
+			taken++
+		default:
+		}
+	}
+	if taken != 10 {
+		t.Errorf("took %d of 60 frames, want 10", taken)
+	}
+}
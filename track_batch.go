@@ -0,0 +1,85 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"time"
+)
+
+// frameResult 是一次视频帧读取的结果。
+type frameResult struct {
+	img image.Image
+	err error
+}
+
+// ReadN 读取 n 帧视频，全部读到或出错时返回。
+// 等同于 ReadBatch(context.Background(), n, 0)。
+func (t *MediaStreamTrack) ReadN(n int) ([]image.Image, error) {
+	return t.ReadBatch(context.Background(), n, 0)
+}
+
+// ReadBatch 一次读取最多 n 帧视频，供 GPU 推理等批处理场景组批使用。
+//
+// 调用先阻塞到第一帧到达，之后最多再等待 maxWait 凑满 n 帧；maxWait <= 0
+// 表示一直等到 n 帧。返回的帧各自拥有独立内存，可以在后续读取后继续使用。
+//
+// ctx 被取消或读取出错时，返回已读到的帧以及错误。提前返回时仍在进行的
+// 读取不会丢帧：它读到的帧由下一次 Read 或 ReadBatch 返回。
+func (t *MediaStreamTrack) ReadBatch(ctx context.Context, n int, maxWait time.Duration) ([]image.Image, error) {
+	if t.kind != MediaDeviceKindVideoInput {
+		return nil, fmt.Errorf("cannot read video from non-video track")
+	}
+	if n <= 0 {
+		return nil, fmt.Errorf("batch size must be positive (got %d)", n)
+	}
+
+	frames := make([]image.Image, 0, n)
+	var deadline <-chan time.Time
+	for len(frames) < n {
+		c := t.takePending()
+		if c == nil {
+			c = make(chan frameResult, 1)
+			go func() {
+				img, err := t.readVideo()
+				c <- frameResult{img, err}
+			}()
+		}
+
+		select {
+		case res := <-c:
+			if res.err != nil {
+				return frames, res.err
+			}
+			frames = append(frames, res.img)
+			if len(frames) == 1 && maxWait > 0 {
+				timer := time.NewTimer(maxWait)
+				defer timer.Stop()
+				deadline = timer.C
+			}
+		case <-deadline:
+			t.setPending(c)
+			return frames, nil
+		case <-ctx.Done():
+			t.setPending(c)
+			return frames, ctx.Err()
+		}
+	}
+	return frames, nil
+}
+
+// takePending 取出上一次 ReadBatch 遗留的读取，没有时返回 nil。
+func (t *MediaStreamTrack) takePending() chan frameResult {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	c := t.pending
+	t.pending = nil
+	return c
+}
+
+// setPending 保存仍在进行的读取，供下一次读取取用。
+func (t *MediaStreamTrack) setPending(c chan frameResult) {
+	t.mu.Lock()
+	t.pending = c
+	t.mu.Unlock()
+}
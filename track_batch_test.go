@@ -0,0 +1,75 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"image"
+	"io"
+	"testing"
+	"time"
+)
+
+// chanVideoSource returns the frames sent on its channel, and io.EOF once
+// the channel is closed.
+type chanVideoSource chan image.Image
+
+func (c chanVideoSource) Read() (image.Image, error) {
+	img, ok := <-c
+	if !ok {
+		return nil, io.EOF
+	}
+	return img, nil
+}
+func (c chanVideoSource) Close() error { return nil }
+func (c chanVideoSource) Width() int   { return 2 }
+func (c chanVideoSource) Height() int  { return 2 }
+
+func frameN(n int) image.Image {
+	return image.NewGray(image.Rect(0, 0, n, 1))
+}
+
+func TestReadBatch(t *testing.T) {
+	src := make(chanVideoSource, 8)
+	track := newCustomTrack(MediaDeviceKindVideoInput, "batch", src, nil)
+
+	for i := 1; i <= 3; i++ {
+		src <- frameN(i)
+	}
+	frames, err := track.ReadN(3)
+	if err != nil || len(frames) != 3 || frames[2].Bounds().Dx() != 3 {
+		t.Fatalf("ReadN = %d frames, %v", len(frames), err)
+	}
+
+	// Only two frames arrive: the batch is cut short after maxWait.
+	src <- frameN(4)
+	src <- frameN(5)
+	start := time.Now()
+	frames, err = track.ReadBatch(context.Background(), 4, 50*time.Millisecond)
+	if err != nil || len(frames) != 2 {
+		t.Fatalf("ReadBatch = %d frames, %v", len(frames), err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Errorf("ReadBatch took %v", d)
+	}
+
+	// The read left in flight is not lost.
+	src <- frameN(6)
+	img, err := track.Read()
+	if err != nil || img.Bounds().Dx() != 6 {
+		t.Fatalf("Read after batch = %v, %v", img, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := track.ReadBatch(ctx, 2, 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled ReadBatch: err = %v", err)
+	}
+
+	close(src)
+	if _, err := track.ReadBatch(context.Background(), 2, 0); err != io.EOF {
+		t.Errorf("ReadBatch at end of stream: err = %v", err)
+	}
+	if _, err := track.ReadBatch(context.Background(), 0, 0); err == nil {
+		t.Error("zero batch size accepted")
+	}
+}
@@ -0,0 +1,131 @@
+package mediadevices
+
+import "context"
+
+// ULongRange 是整数设置的取值范围。
+// 对应 W3C Media Capture 规范的 ULongRange。
+type ULongRange struct {
+	Min, Max int
+}
+
+// DoubleRange 是浮点数设置的取值范围。
+// 对应 W3C Media Capture 规范的 DoubleRange。
+type DoubleRange struct {
+	Min, Max float64
+}
+
+// MediaTrackCapabilities 表示轨道的设备支持的设置范围。
+// 对应 MDN 的 MediaTrackCapabilities 接口。
+// 不适用于轨道类型的字段为零值。
+type MediaTrackCapabilities struct {
+	// DeviceID 是轨道的设备，不是从设备捕获的轨道为空。
+	DeviceID string
+	// Width、Height 视频尺寸的范围。
+	Width, Height ULongRange
+	// AspectRatio 视频宽高比的范围。
+	AspectRatio DoubleRange
+	// FrameRate 视频帧率的范围。
+	FrameRate DoubleRange
+	// FacingMode 摄像头的朝向，朝向未知时为空。
+	FacingMode []FacingMode
+	// SampleRate 音频采样率的范围。
+	SampleRate ULongRange
+	// ChannelCount 音频声道数的范围。
+	ChannelCount ULongRange
+	// SampleSize 音频采样大小（位）的范围，固定为 16。
+	SampleSize ULongRange
+}
+
+// GetCapabilities 返回轨道的设备支持的设置范围，即 ApplyConstraints 可以选择的设置。
+// 对应 MDN 的 MediaStreamTrack.getCapabilities()。
+//
+// 范围由 GetDeviceCapabilities 查询到的设备捕获模式得出，第一次调用时查询设备，
+// 之后使用缓存的结果（SwitchDevice 后重新查询）。设备不报告某项设置（如 V4L2 的帧率、
+// ALSA 麦克风的格式）、查询失败或轨道不是从设备捕获时，该项的范围只包含当前设置。
+func (t *MediaStreamTrack) GetCapabilities() MediaTrackCapabilities {
+	t.mu.Lock()
+	device, caps := t.device, t.caps
+	t.mu.Unlock()
+
+	if caps == nil && device.DeviceID != "" && t.owner != nil {
+		if c, err := t.owner.GetDeviceCapabilitiesContext(context.Background(), device.DeviceID); err == nil {
+			caps = &c
+			t.mu.Lock()
+			if t.device.DeviceID == device.DeviceID {
+				t.caps = caps
+			}
+			t.mu.Unlock()
+		}
+	}
+
+	var c DeviceCapabilities
+	if caps != nil {
+		c = *caps
+	}
+	capabilities := trackCapabilities(t.kind, c, t.GetSettings())
+	capabilities.DeviceID = device.DeviceID
+	return capabilities
+}
+
+// trackCapabilities 由设备的捕获模式 caps 得出 kind 类型轨道的设置范围，
+// caps 没有报告的设置取当前设置 s。
+func trackCapabilities(kind MediaDeviceKind, caps DeviceCapabilities, s MediaTrackSettings) MediaTrackCapabilities {
+	var c MediaTrackCapabilities
+	switch kind {
+	case MediaDeviceKindVideoInput:
+		for _, m := range caps.VideoModes {
+			if m.Width <= 0 || m.Height <= 0 {
+				continue
+			}
+			c.Width = c.Width.extend(m.Width)
+			c.Height = c.Height.extend(m.Height)
+			c.AspectRatio = c.AspectRatio.extend(float64(m.Width) / float64(m.Height))
+			if m.MaxFrameRate > 0 {
+				c.FrameRate = c.FrameRate.extend(m.MinFrameRate).extend(m.MaxFrameRate)
+			}
+		}
+		if c.Width == (ULongRange{}) && s.Width > 0 && s.Height > 0 {
+			c.Width = c.Width.extend(s.Width)
+			c.Height = c.Height.extend(s.Height)
+			c.AspectRatio = c.AspectRatio.extend(float64(s.Width) / float64(s.Height))
+		}
+		if c.FrameRate == (DoubleRange{}) && s.FrameRate > 0 {
+			c.FrameRate = c.FrameRate.extend(s.FrameRate)
+		}
+		if s.FacingMode != "" {
+			c.FacingMode = []FacingMode{s.FacingMode}
+		}
+
+	case MediaDeviceKindAudioInput:
+		for _, m := range caps.AudioModes {
+			if m.SampleRate > 0 && m.Channels > 0 {
+				c.SampleRate = c.SampleRate.extend(m.SampleRate)
+				c.ChannelCount = c.ChannelCount.extend(m.Channels)
+			}
+		}
+		if c.SampleRate == (ULongRange{}) && s.SampleRate > 0 {
+			c.SampleRate = c.SampleRate.extend(s.SampleRate)
+		}
+		if c.ChannelCount == (ULongRange{}) && s.ChannelCount > 0 {
+			c.ChannelCount = c.ChannelCount.extend(s.ChannelCount)
+		}
+		c.SampleSize = ULongRange{Min: 16, Max: 16}
+	}
+	return c
+}
+
+// extend 返回包含 r 和 v 的最小范围，r 为零值时返回只包含 v 的范围。
+func (r ULongRange) extend(v int) ULongRange {
+	if r == (ULongRange{}) {
+		return ULongRange{Min: v, Max: v}
+	}
+	return ULongRange{Min: min(r.Min, v), Max: max(r.Max, v)}
+}
+
+// extend 返回包含 r 和 v 的最小范围，r 为零值时返回只包含 v 的范围。
+func (r DoubleRange) extend(v float64) DoubleRange {
+	if r == (DoubleRange{}) {
+		return DoubleRange{Min: v, Max: v}
+	}
+	return DoubleRange{Min: min(r.Min, v), Max: max(r.Max, v)}
+}
@@ -0,0 +1,56 @@
+package mediadevices
+
+import "testing"
+
+func TestTrackCapabilities(t *testing.T) {
+	caps := DeviceCapabilities{
+		VideoModes: []VideoMode{
+			{Width: 640, Height: 480, MinFrameRate: 5, MaxFrameRate: 30},
+			{Width: 1920, Height: 1080, MinFrameRate: 15, MaxFrameRate: 60},
+			{Width: 320, Height: 240}, // no frame rates (V4L2)
+		},
+		AudioModes: []AudioMode{{SampleRate: 44100, Channels: 2}, {SampleRate: 8000, Channels: 1}},
+	}
+	current := MediaTrackSettings{Width: 640, Height: 480, FrameRate: 25, FacingMode: FacingModeUser, SampleRate: 48000, ChannelCount: 2}
+
+	v := trackCapabilities(MediaDeviceKindVideoInput, caps, current)
+	if v.Width != (ULongRange{320, 1920}) || v.Height != (ULongRange{240, 1080}) || v.FrameRate != (DoubleRange{5, 60}) {
+		t.Errorf("video capabilities = %+v", v)
+	}
+	if v.AspectRatio.Min != 4.0/3 || v.AspectRatio.Max != 16.0/9 {
+		t.Errorf("aspect ratio = %+v", v.AspectRatio)
+	}
+	if len(v.FacingMode) != 1 || v.FacingMode[0] != FacingModeUser {
+		t.Errorf("facing mode = %v", v.FacingMode)
+	}
+	if v.SampleRate != (ULongRange{}) {
+		t.Errorf("video track has audio capabilities: %+v", v)
+	}
+
+	a := trackCapabilities(MediaDeviceKindAudioInput, caps, current)
+	if a.SampleRate != (ULongRange{8000, 44100}) || a.ChannelCount != (ULongRange{1, 2}) || a.SampleSize != (ULongRange{16, 16}) {
+		t.Errorf("audio capabilities = %+v", a)
+	}
+
+	// Without modes, the ranges hold the current settings.
+	v = trackCapabilities(MediaDeviceKindVideoInput, DeviceCapabilities{}, current)
+	if v.Width != (ULongRange{640, 640}) || v.FrameRate != (DoubleRange{25, 25}) {
+		t.Errorf("video capabilities without modes = %+v", v)
+	}
+	a = trackCapabilities(MediaDeviceKindAudioInput, DeviceCapabilities{}, current)
+	if a.SampleRate != (ULongRange{48000, 48000}) || a.ChannelCount != (ULongRange{2, 2}) {
+		t.Errorf("audio capabilities without modes = %+v", a)
+	}
+}
+
+func TestGetCapabilities_CustomTrack(t *testing.T) {
+	track, err := NewBlackVideoTrack(16, 8, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer track.Stop()
+	c := track.GetCapabilities()
+	if c.DeviceID != "" || c.Width != (ULongRange{16, 16}) || c.Height != (ULongRange{8, 8}) || c.FrameRate != (DoubleRange{10, 10}) {
+		t.Errorf("GetCapabilities = %+v", c)
+	}
+}
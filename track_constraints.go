@@ -0,0 +1,248 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+)
+
+// SettingsChangeEvent 描述 ApplyConstraints 引起的一次轨道设置变化，参见 OnSettingsChange。
+type SettingsChangeEvent struct {
+	TrackID string
+	// Previous 是变化前的设置。
+	Previous MediaTrackSettings
+	// Settings 是变化后的设置，与此时 GetSettings 的返回值相同。
+	Settings MediaTrackSettings
+}
+
+// ApplyConstraints 按新的约束重新配置轨道，如改变分辨率、帧率或采样率、声道数。
+// 对应 MDN 的 MediaStreamTrack.applyConstraints()。
+//
+// 视频轨道使用 constraints.Video，音频轨道使用 constraints.Audio；为 nil 时按空约束处理，
+// 与 MDN 一致，轨道恢复默认设置。设置按 GetUserMedia 的规则选择（见 VideoTrackConstraints）；
+// 无法满足必需约束时返回包装了 *OverconstrainedError 的错误，轨道不变。
+// ApplyConstraints 不能更换设备：DeviceID 与当前设备不同或 FacingMode.Exact 与设备朝向不符时
+// 同样返回 *OverconstrainedError，更换设备请使用 SwitchDevice。
+//
+// 选出的设置与当前设置不同时，FFmpeg 进程以新设置重启。摄像头和麦克风通常不能被同时打开两次，
+// 因此旧进程先被停止，重启期间 Read、ReadAudio 等待新进程的第一帧，不会返回错误。
+// 轨道 ID 保持不变。新设置启动失败时轨道以原设置重新打开并返回错误；原设置也无法打开时轨道结束。
+// 设置改变后，OnSettingsChange 的订阅者在 ApplyConstraints 返回前被调用。
+//
+// 只有 GetUserMedia 创建的轨道可以重新配置。
+func (t *MediaStreamTrack) ApplyConstraints(constraints MediaTrackConstraints) error {
+	t.reconfigMu.Lock()
+	defer t.reconfigMu.Unlock()
+
+	t.mu.Lock()
+	ended := t.readyState == MediaStreamTrackStateEnded
+	device := t.device
+	t.mu.Unlock()
+	if ended {
+		return fmt.Errorf("apply constraints: track has ended")
+	}
+	if device.DeviceID == "" || t.owner == nil {
+		return fmt.Errorf("apply constraints: track is not captured from a device")
+	}
+
+	ctx := context.Background()
+	var err error
+	switch t.kind {
+	case MediaDeviceKindVideoInput:
+		c := constraints.Video
+		if c == nil {
+			c = &VideoTrackConstraints{}
+		}
+		if err = t.checkDeviceConstraint(c.DeviceID, device); err == nil && !c.FacingMode.admits(device.FacingMode) {
+			err = &OverconstrainedError{Constraint: "facingMode", Message: fmt.Sprintf("device %s does not face %s; use SwitchDevice", device.DeviceID, *c.FacingMode.Exact)}
+		}
+		if err == nil {
+			var s videoSettings
+			if s, err = t.owner.videoSettingsFor(ctx, device, c); err == nil {
+				err = t.restartVideo(device, s)
+			}
+		}
+
+	case MediaDeviceKindAudioInput:
+		c := constraints.Audio
+		if c == nil {
+			c = &AudioTrackConstraints{}
+		}
+		if err = t.checkDeviceConstraint(c.DeviceID, device); err == nil {
+			var s audioSettings
+			if s, err = t.owner.audioSettingsFor(ctx, device, c); err == nil {
+				cfg := AudioConfig{SampleRate: s.sampleRate, Channels: s.channels}
+				if c.Latency != nil {
+					cfg.Latency = *c.Latency
+				}
+				err = t.restartAudio(device, cfg)
+			}
+		}
+
+	default:
+		err = fmt.Errorf("unsupported track kind %q", t.kind)
+	}
+	if err != nil {
+		return fmt.Errorf("apply constraints: %w", err)
+	}
+	return nil
+}
+
+// OnSettingsChange 在 ApplyConstraints 改变轨道设置后调用 fn。
+// fn 在调用 ApplyConstraints 的 goroutine 中、ApplyConstraints 返回前调用。
+// 返回的 cancel 取消订阅。
+func (t *MediaStreamTrack) OnSettingsChange(fn func(SettingsChangeEvent)) (cancel func()) {
+	t.mu.Lock()
+	if t.settingsListeners == nil {
+		t.settingsListeners = make(map[int]func(SettingsChangeEvent))
+	}
+	id := t.settingsNext
+	t.settingsNext++
+	t.settingsListeners[id] = fn
+	t.mu.Unlock()
+
+	return sync.OnceFunc(func() {
+		t.mu.Lock()
+		delete(t.settingsListeners, id)
+		t.mu.Unlock()
+	})
+}
+
+// checkDeviceConstraint 检查约束中的 DeviceID 是否指向轨道当前的设备 device。
+func (t *MediaStreamTrack) checkDeviceConstraint(deviceID *string, device MediaDeviceInfo) error {
+	if deviceID == nil || t.owner.Config().DeviceFilter.resolve(*deviceID) == device.DeviceID {
+		return nil
+	}
+	return &OverconstrainedError{Constraint: "deviceId", Message: "the device of a track cannot be changed; use SwitchDevice"}
+}
+
+// restartVideo 以设置 s 重启视频轨道的捕获，设置未变时什么都不做。
+func (t *MediaStreamTrack) restartVideo(device MediaDeviceInfo, s videoSettings) error {
+	prev := t.GetSettings()
+	if prev.Width == s.width && prev.Height == s.height && prev.FrameRate == s.frameRate {
+		return nil
+	}
+
+	// 先停止旧进程以释放设备，期间读取方阻塞在占位数据源上
+	pending := &pendingVideoSource{width: s.width, height: s.height, frameRate: s.frameRate, closed: make(chan struct{})}
+	t.replaceSource(device, pending, nil) // 轨道已结束时由下面的检查处理
+	if t.ReadyState() == MediaStreamTrackStateEnded {
+		return fmt.Errorf("track has ended")
+	}
+
+	src, err := t.openPrimedVideo(device, s.width, s.height, s.frameRate)
+	if err != nil {
+		frameRate := prev.FrameRate
+		if frameRate <= 0 {
+			frameRate = defaultVideoFrameRate
+		}
+		restored, rerr := t.openPrimedVideo(device, prev.Width, prev.Height, frameRate)
+		if rerr != nil {
+			t.Stop()
+			return fmt.Errorf("%w (restoring the previous settings: %v)", err, rerr)
+		}
+		t.replaceSource(device, restored, nil)
+		return err
+	}
+	if err := t.replaceSource(device, src, nil); err != nil {
+		return err
+	}
+	t.notifySettingsChange(prev)
+	return nil
+}
+
+// restartAudio 以 cfg 的格式重启音频轨道的捕获，格式未变时什么都不做。
+// cfg.Latency 为零时保持当前的音频块时长。
+func (t *MediaStreamTrack) restartAudio(device MediaDeviceInfo, cfg AudioConfig) error {
+	prev := t.GetSettings()
+	if cfg.Latency <= 0 {
+		cfg.Latency = prev.Latency
+	}
+	if prev.SampleRate == cfg.SampleRate && prev.ChannelCount == cfg.Channels && prev.Latency == cfg.Latency {
+		return nil
+	}
+
+	pending := &pendingAudioSource{sampleRate: cfg.SampleRate, channels: cfg.Channels, latency: cfg.Latency, closed: make(chan struct{})}
+	t.replaceSource(device, nil, pending) // 轨道已结束时由下面的检查处理
+	if t.ReadyState() == MediaStreamTrackStateEnded {
+		return fmt.Errorf("track has ended")
+	}
+
+	src, err := t.openPrimedAudio(device, cfg)
+	if err != nil {
+		restored, rerr := t.openPrimedAudio(device, AudioConfig{SampleRate: prev.SampleRate, Channels: prev.ChannelCount, Latency: prev.Latency})
+		if rerr != nil {
+			t.Stop()
+			return fmt.Errorf("%w (restoring the previous settings: %v)", err, rerr)
+		}
+		t.replaceSource(device, nil, restored)
+		return err
+	}
+	if err := t.replaceSource(device, nil, src); err != nil {
+		return err
+	}
+	t.notifySettingsChange(prev)
+	return nil
+}
+
+// notifySettingsChange 以变化前的设置 prev 和当前设置调用 OnSettingsChange 的订阅者。
+func (t *MediaStreamTrack) notifySettingsChange(prev MediaTrackSettings) {
+	ev := SettingsChangeEvent{TrackID: t.ID(), Previous: prev, Settings: t.GetSettings()}
+	t.mu.Lock()
+	listeners := slices.Collect(maps.Values(t.settingsListeners))
+	t.mu.Unlock()
+	for _, fn := range listeners {
+		fn(ev)
+	}
+}
+
+// pendingVideoSource 在 ApplyConstraints 重启捕获期间代替视频数据源：
+// Read 阻塞到它被新数据源替换（或轨道停止）时关闭，读取方随后转向新数据源。
+// 其尺寸和帧率是正在应用的设置。
+type pendingVideoSource struct {
+	width, height int
+	frameRate     float64
+	closed        chan struct{}
+	once          sync.Once
+}
+
+func (s *pendingVideoSource) Read() (image.Image, error) {
+	<-s.closed
+	return nil, io.EOF
+}
+
+func (s *pendingVideoSource) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func (s *pendingVideoSource) Width() int         { return s.width }
+func (s *pendingVideoSource) Height() int        { return s.height }
+func (s *pendingVideoSource) FrameRate() float64 { return s.frameRate }
+
+// pendingAudioSource 是音频轨道的 pendingVideoSource。
+type pendingAudioSource struct {
+	sampleRate, channels int
+	latency              time.Duration
+	closed               chan struct{}
+	once                 sync.Once
+}
+
+func (s *pendingAudioSource) Read() (*AudioChunk, error) {
+	<-s.closed
+	return nil, io.EOF
+}
+
+func (s *pendingAudioSource) Close() error {
+	s.once.Do(func() { close(s.closed) })
+	return nil
+}
+
+func (s *pendingAudioSource) SampleRate() int        { return s.sampleRate }
+func (s *pendingAudioSource) Channels() int          { return s.channels }
+func (s *pendingAudioSource) Latency() time.Duration { return s.latency }
@@ -0,0 +1,120 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestApplyConstraints(t *testing.T) {
+	var mu sync.Mutex
+	var started [][]string
+	m := NewMediaDevices(Config{
+		FFmpegPath: "/bin/sh",
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{
+				{DeviceID: "cam", DeviceName: "/dev/video0", Label: "cam", Kind: MediaDeviceKindVideoInput},
+				{DeviceID: "mic", DeviceName: "hw:0", Label: "mic", Kind: MediaDeviceKindAudioInput},
+			}, nil
+		},
+		ArgsHook: func(args []string) []string {
+			mu.Lock()
+			started = append(started, args)
+			mu.Unlock()
+			return []string{"-c", "exec cat /dev/zero"}
+		},
+	})
+	defer m.CloseAll()
+
+	cam, mic := "cam", "mic"
+	stream, err := m.GetUserMedia(MediaTrackConstraints{
+		Video: &VideoTrackConstraints{DeviceID: &cam, Width: ExactInt(64), Height: ExactInt(48)},
+		Audio: &AudioTrackConstraints{DeviceID: &mic},
+	})
+	if err != nil {
+		t.Fatalf("GetUserMedia: %v", err)
+	}
+	video, audio := stream.GetVideoTracks()[0], stream.GetAudioTracks()[0]
+	id := video.ID()
+
+	var events []SettingsChangeEvent
+	cancel := video.OnSettingsChange(func(ev SettingsChangeEvent) { events = append(events, ev) })
+	defer cancel()
+
+	// A reader blocked across the restart continues on the new process.
+	frames := make(chan error, 1)
+	go func() {
+		for {
+			img, err := video.Read()
+			if err != nil || img.Bounds().Dx() == 32 {
+				frames <- err
+				return
+			}
+		}
+	}()
+
+	if err := video.ApplyConstraints(MediaTrackConstraints{Video: &VideoTrackConstraints{Width: ExactInt(32), Height: ExactInt(24), FrameRate: ExactFloat64(15)}}); err != nil {
+		t.Fatalf("ApplyConstraints: %v", err)
+	}
+	if err := <-frames; err != nil {
+		t.Fatalf("Read across the restart: %v", err)
+	}
+	if s := video.GetSettings(); s.Width != 32 || s.Height != 24 || s.FrameRate != 15 || video.ID() != id {
+		t.Errorf("settings = %+v, ID %s; want 32x24@15 with ID %s", s, video.ID(), id)
+	}
+	if len(events) != 1 || events[0].TrackID != id || events[0].Previous.Width != 64 || events[0].Settings.Width != 32 {
+		t.Errorf("settings change events = %+v", events)
+	}
+	mu.Lock()
+	last := started[len(started)-1]
+	mu.Unlock()
+	if i := slices.Index(last, "-video_size"); i < 0 || last[i+1] != "32x24" {
+		t.Errorf("restarted with %q", last)
+	}
+	if n := m.ActiveResources(); n.Processes != 2 || n.Tracks != 2 {
+		t.Errorf("ActiveResources = %+v, want the two captures", n)
+	}
+
+	// Unchanged settings do not restart the capture.
+	if err := video.ApplyConstraints(MediaTrackConstraints{Video: &VideoTrackConstraints{Width: IdealInt(32), Height: IdealInt(24), FrameRate: IdealFloat64(15)}}); err != nil || len(events) != 1 {
+		t.Errorf("ApplyConstraints with the current settings = %v, %d events", err, len(events))
+	}
+
+	var oc *OverconstrainedError
+	if err := video.ApplyConstraints(MediaTrackConstraints{Video: &VideoTrackConstraints{Width: IntRange(100, 50)}}); !errors.As(err, &oc) || oc.Constraint != "width" {
+		t.Errorf("unsatisfiable width: %v", err)
+	}
+	if err := video.ApplyConstraints(MediaTrackConstraints{Video: &VideoTrackConstraints{DeviceID: &mic}}); !errors.As(err, &oc) || oc.Constraint != "deviceId" {
+		t.Errorf("other device: %v", err)
+	}
+	if err := video.ApplyConstraints(MediaTrackConstraints{Video: &VideoTrackConstraints{FacingMode: ExactFacingMode(FacingModeEnvironment)}}); !errors.As(err, &oc) || oc.Constraint != "facingMode" {
+		t.Errorf("other facing mode: %v", err)
+	}
+	if s := video.GetSettings(); s.Width != 32 {
+		t.Errorf("failed ApplyConstraints changed the settings: %+v", s)
+	}
+
+	if err := audio.ApplyConstraints(MediaTrackConstraints{Audio: &AudioTrackConstraints{SampleRate: ExactInt(16000), Channels: ExactInt(1)}}); err != nil {
+		t.Fatalf("audio ApplyConstraints: %v", err)
+	}
+	chunk, err := audio.ReadAudio()
+	if err != nil || chunk.SampleRate != 16000 || chunk.Channels != 1 {
+		t.Fatalf("ReadAudio = %+v, %v; want 16 kHz mono", chunk, err)
+	}
+	if s := audio.GetSettings(); s.SampleRate != 16000 || s.ChannelCount != 1 {
+		t.Errorf("audio settings = %+v", s)
+	}
+
+	custom, err := NewBlackVideoTrack(16, 16, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer custom.Stop()
+	if err := custom.ApplyConstraints(MediaTrackConstraints{}); err == nil {
+		t.Error("ApplyConstraints on a custom track succeeded")
+	}
+}
@@ -0,0 +1,288 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FailoverConfig 配置主备摄像头热备轨道，参见 NewFailoverTrack。
+type FailoverConfig struct {
+	// PrimaryDeviceID 和 BackupDeviceID 是主、备摄像头的 DeviceID（EnumerateDevices 返回值）。
+	PrimaryDeviceID string
+	BackupDeviceID  string
+	// Width、Height 和 FrameRate 是两个摄像头共同的捕获参数，默认 640x480、30 fps。
+	Width     int
+	Height    int
+	FrameRate float64
+	// StallTimeout 是当前摄像头多久没有产出帧即切换到另一个，默认 2 秒。
+	StallTimeout time.Duration
+	// RetryInterval 是摄像头打开失败、出错或持续这么久没有产出帧后重新打开的间隔，默认 5 秒。
+	RetryInterval time.Duration
+	// OnFailover 在轨道每次切换摄像头时调用，在调用 Read 的 goroutine 中执行。
+	OnFailover func(FailoverEvent)
+}
+
+// FailoverEvent 描述热备轨道的一次摄像头切换。
+type FailoverEvent struct {
+	// From 和 To 是切换前后的 DeviceID。
+	From string
+	To   string
+	// Backup 表示 To 是否为备用摄像头；为 false 时表示已切回主摄像头。
+	Backup bool
+}
+
+// NewFailoverTrack 创建主备摄像头热备的视频轨道，用于无人值守的监控、自助终端等场景。
+//
+// 两个摄像头同时打开（热备），轨道正常时输出主摄像头的帧。主摄像头超过
+// StallTimeout 没有产出帧（拔出、驱动卡死、FFmpeg 退出）时，轨道立即改为输出
+// 备用摄像头的帧；主摄像头恢复产出后自动切回。出错的摄像头每隔 RetryInterval
+// 重新打开，轨道 ID 保持不变，Label 随当前摄像头变化。
+//
+// 两个摄像头都不可用时 Read 阻塞，直到其中之一恢复或轨道被停止。
+func NewFailoverTrack(cfg FailoverConfig) (*MediaStreamTrack, error) {
+	return defaultMediaDevices.NewFailoverTrack(cfg)
+}
+
+// NewFailoverTrack 与包级函数 NewFailoverTrack 相同，但在 m 的设备中选择摄像头并使用 m 的配置捕获。
+func (m *MediaDevices) NewFailoverTrack(cfg FailoverConfig) (*MediaStreamTrack, error) {
+	filter := m.Config().DeviceFilter
+	cfg.PrimaryDeviceID = filter.resolve(cfg.PrimaryDeviceID)
+	cfg.BackupDeviceID = filter.resolve(cfg.BackupDeviceID)
+	if cfg.PrimaryDeviceID == "" || cfg.BackupDeviceID == "" {
+		return nil, fmt.Errorf("failover track: primary and backup device IDs are required")
+	}
+	if cfg.PrimaryDeviceID == cfg.BackupDeviceID {
+		return nil, fmt.Errorf("failover track: primary and backup are the same device")
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 {
+		cfg.Width, cfg.Height = 640, 480
+	}
+	if cfg.FrameRate <= 0 {
+		cfg.FrameRate = 30
+	}
+
+	devices, err := m.devicesByKind(context.Background(), MediaDeviceKindVideoInput)
+	if err != nil {
+		return nil, fmt.Errorf("failover track: %w", err)
+	}
+	var primary, backup MediaDeviceInfo
+	for _, d := range devices {
+		switch d.DeviceID {
+		case cfg.PrimaryDeviceID:
+			primary = d
+		case cfg.BackupDeviceID:
+			backup = d
+		}
+	}
+	if primary.DeviceID == "" {
+		return nil, fmt.Errorf("failover track: video %w: %s", ErrNotFound, cfg.PrimaryDeviceID)
+	}
+	if backup.DeviceID == "" {
+		return nil, fmt.Errorf("failover track: video %w: %s", ErrNotFound, cfg.BackupDeviceID)
+	}
+
+	open := func(d MediaDeviceInfo) (videoSource, error) {
+		return m.newVideoReaderInternal(ffmpegDeviceName(d), cfg.Width, cfg.Height, cfg.FrameRate, nil)
+	}
+	return m.newFailoverTrack(cfg, primary, backup, open), nil
+}
+
+// newFailoverTrack 创建登记在 m 中的热备轨道，open 打开一个摄像头。
+func (m *MediaDevices) newFailoverTrack(cfg FailoverConfig, primary, backup MediaDeviceInfo, open func(MediaDeviceInfo) (videoSource, error)) *MediaStreamTrack {
+	if cfg.StallTimeout <= 0 {
+		cfg.StallTimeout = 2 * time.Second
+	}
+	if cfg.RetryInterval <= 0 {
+		cfg.RetryInterval = 5 * time.Second
+	}
+	s := &failoverSource{cfg: cfg, stop: make(chan struct{})}
+	for i, d := range []MediaDeviceInfo{primary, backup} {
+		leg := &failoverLeg{
+			info:   d,
+			open:   func() (videoSource, error) { return open(d) },
+			frames: make(chan failoverFrame, 1),
+		}
+		s.legs[i] = leg
+		if i == 0 {
+			// 给主摄像头 StallTimeout 的启动时间，避免备用摄像头先产出帧时误切换。
+			leg.lastFrame.Store(time.Now().UnixNano())
+		}
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			leg.run(s.stop, cfg.RetryInterval)
+		}()
+	}
+	s.track = m.newCustomTrack(MediaDeviceKindVideoInput, primary.Label, s, nil)
+	return s.track
+}
+
+// failoverSource 是热备轨道的数据源，在主（legs[0]）备（legs[1]）摄像头间选择。
+type failoverSource struct {
+	cfg   FailoverConfig
+	legs  [2]*failoverLeg
+	track *MediaStreamTrack
+
+	mu     sync.Mutex
+	active int
+
+	stop      chan struct{}
+	closeOnce sync.Once
+	wg        sync.WaitGroup
+}
+
+// Read 返回当前摄像头的下一帧，当前摄像头停止产出时先切换。
+func (s *failoverSource) Read() (image.Image, error) {
+	// 定期重新评估，以便在当前摄像头卡住时及时切换。
+	tick := time.NewTicker(s.cfg.StallTimeout / 4)
+	defer tick.Stop()
+	for {
+		active := s.choose()
+		select {
+		case f := <-s.legs[active].frames:
+			// 摄像头停止产出后缓冲中留下的帧已过时，丢弃，避免切换或恢复时输出旧画面。
+			if time.Since(f.at) >= s.cfg.StallTimeout {
+				continue
+			}
+			return f.img, nil
+		case <-tick.C:
+		case <-s.stop:
+			return nil, io.EOF
+		}
+	}
+}
+
+// choose 返回应输出的摄像头：主摄像头正常时优先，其次是正常的备用摄像头，
+// 都不正常时保持当前摄像头。
+func (s *failoverSource) choose() int {
+	want := -1
+	for i, leg := range s.legs {
+		if leg.healthy(s.cfg.StallTimeout) {
+			want = i
+			break
+		}
+	}
+
+	s.mu.Lock()
+	prev := s.active
+	if want < 0 || want == prev {
+		s.mu.Unlock()
+		return prev
+	}
+	s.active = want
+	s.mu.Unlock()
+
+	to := s.legs[want].info
+	s.track.mu.Lock()
+	s.track.label = to.Label
+	s.track.mu.Unlock()
+	if s.cfg.OnFailover != nil {
+		s.cfg.OnFailover(FailoverEvent{From: s.legs[prev].info.DeviceID, To: to.DeviceID, Backup: want == 1})
+	}
+	return want
+}
+
+// Close 停止两个摄像头。
+func (s *failoverSource) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.wg.Wait()
+	})
+	return nil
+}
+
+func (s *failoverSource) Width() int  { return s.cfg.Width }
+func (s *failoverSource) Height() int { return s.cfg.Height }
+
+// FrameRate 返回配置的帧率。
+func (s *failoverSource) FrameRate() float64 {
+	return s.cfg.FrameRate
+}
+
+// failoverFrame 是摄像头产出的一帧及其产出时间。
+type failoverFrame struct {
+	img image.Image
+	at  time.Time
+}
+
+// failoverLeg 持续读取一个摄像头，只保留最新一帧。
+type failoverLeg struct {
+	info      MediaDeviceInfo
+	open      func() (videoSource, error)
+	frames    chan failoverFrame // 最新一帧，容量 1
+	lastFrame atomic.Int64       // 最近一帧的时间（UnixNano），0 表示尚无帧
+}
+
+// healthy 判断摄像头在 stall 时间内是否产出过帧。
+func (l *failoverLeg) healthy(stall time.Duration) bool {
+	last := l.lastFrame.Load()
+	return last != 0 && time.Since(time.Unix(0, last)) < stall
+}
+
+// run 打开并读取摄像头，出错后每隔 retry 重新打开，直到 stop 关闭。
+func (l *failoverLeg) run(stop <-chan struct{}, retry time.Duration) {
+	for {
+		if r, err := l.open(); err == nil {
+			l.pump(r, stop, retry)
+		}
+		select {
+		case <-stop:
+			return
+		case <-time.After(retry):
+		}
+	}
+}
+
+// pump 读取 r 直到出错、stop 关闭或 retry 时间内没有产出帧，然后关闭 r。
+func (l *failoverLeg) pump(r videoSource, stop <-chan struct{}, retry time.Duration) {
+	closeReader := sync.OnceFunc(func() { r.Close() })
+	defer closeReader()
+
+	var last atomic.Int64
+	last.Store(time.Now().UnixNano())
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		tick := time.NewTicker(retry / 4)
+		defer tick.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-stop:
+				closeReader()
+				return
+			case <-tick.C:
+				// 卡住的读取不会返回错误，关闭数据源使其返回。
+				if time.Since(time.Unix(0, last.Load())) > retry {
+					closeReader()
+					return
+				}
+			}
+		}
+	}()
+
+	for {
+		img, err := r.Read()
+		if err != nil {
+			return
+		}
+		now := time.Now()
+		last.Store(now.UnixNano())
+		l.lastFrame.Store(now.UnixNano())
+		// 丢弃未被取走的旧帧，只保留最新一帧。
+		select {
+		case <-l.frames:
+		default:
+		}
+		select {
+		case l.frames <- failoverFrame{img: img, at: now}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package mediadevices
+
+import (
+	"image"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// fakeCamera produces frames of width w every few milliseconds while on is
+// set, and blocks like a stalled device otherwise.
+type fakeCamera struct {
+	w      int
+	on     atomic.Bool
+	opened atomic.Int32
+}
+
+func (c *fakeCamera) open() (videoSource, error) {
+	c.opened.Add(1)
+	return &fakeCameraReader{cam: c, closed: make(chan struct{})}, nil
+}
+
+type fakeCameraReader struct {
+	cam       *fakeCamera
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *fakeCameraReader) Read() (image.Image, error) {
+	for {
+		select {
+		case <-r.closed:
+			return nil, io.EOF
+		case <-time.After(2 * time.Millisecond):
+		}
+		if r.cam.on.Load() {
+			return frameN(r.cam.w), nil
+		}
+	}
+}
+
+func (r *fakeCameraReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return nil
+}
+func (r *fakeCameraReader) Width() int  { return r.cam.w }
+func (r *fakeCameraReader) Height() int { return 1 }
+
+func TestFailoverTrack(t *testing.T) {
+	primary, backup := &fakeCamera{w: 1}, &fakeCamera{w: 2}
+	primary.on.Store(true)
+	backup.on.Store(true)
+	cams := map[string]*fakeCamera{"cam-1": primary, "cam-2": backup}
+
+	events := make(chan FailoverEvent, 4)
+	track := defaultMediaDevices.newFailoverTrack(FailoverConfig{
+		Width: 2, Height: 1, FrameRate: 30,
+		StallTimeout:  40 * time.Millisecond,
+		RetryInterval: 100 * time.Millisecond,
+		OnFailover:    func(ev FailoverEvent) { events <- ev },
+	},
+		MediaDeviceInfo{DeviceID: "cam-1", Label: "Front"},
+		MediaDeviceInfo{DeviceID: "cam-2", Label: "Back"},
+		func(d MediaDeviceInfo) (videoSource, error) { return cams[d.DeviceID].open() },
+	)
+	defer track.Stop()
+
+	// readFrom reads until a frame of the camera with width w arrives.
+	readFrom := func(w int) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			img, err := track.Read()
+			if err != nil {
+				t.Fatalf("Read: %v", err)
+			}
+			if img.Bounds().Dx() == w {
+				return
+			}
+		}
+		t.Fatalf("no frame from camera %d", w)
+	}
+
+	readFrom(1)
+	if track.Label() != "Front" {
+		t.Errorf("Label = %q, want Front", track.Label())
+	}
+
+	primary.on.Store(false)
+	readFrom(2)
+	if ev := <-events; ev.From != "cam-1" || ev.To != "cam-2" || !ev.Backup {
+		t.Errorf("failover event = %+v", ev)
+	}
+	if track.Label() != "Back" {
+		t.Errorf("Label = %q, want Back", track.Label())
+	}
+
+	// The stalled primary is reopened and taken back once it delivers.
+	time.Sleep(250 * time.Millisecond)
+	primary.on.Store(true)
+	readFrom(1)
+	if ev := <-events; ev.To != "cam-1" || ev.Backup {
+		t.Errorf("failback event = %+v", ev)
+	}
+	if primary.opened.Load() < 2 {
+		t.Errorf("primary opened %d times, want it reopened after the stall", primary.opened.Load())
+	}
+
+	track.Stop()
+	if _, err := track.Read(); err != io.EOF {
+		t.Errorf("Read after Stop: %v", err)
+	}
+}
+
+func TestFailoverTrack_DropsStaleFrame(t *testing.T) {
+	primary, backup := &fakeCamera{w: 1}, &fakeCamera{w: 2}
+	primary.on.Store(true)
+	cams := map[string]*fakeCamera{"cam-1": primary, "cam-2": backup}
+	track := defaultMediaDevices.newFailoverTrack(FailoverConfig{
+		Width: 2, Height: 1, FrameRate: 30,
+		StallTimeout:  40 * time.Millisecond,
+		RetryInterval: time.Second,
+	},
+		MediaDeviceInfo{DeviceID: "cam-1", Label: "Front"},
+		MediaDeviceInfo{DeviceID: "cam-2", Label: "Back"},
+		func(d MediaDeviceInfo) (videoSource, error) { return cams[d.DeviceID].open() },
+	)
+	defer track.Stop()
+
+	if _, err := track.Read(); err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	// The primary stalls with its last frame unread and no backup to take over.
+	time.Sleep(20 * time.Millisecond)
+	primary.on.Store(false)
+	time.Sleep(150 * time.Millisecond)
+
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		backup.on.Store(true)
+	}()
+	img, err := track.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if w := img.Bounds().Dx(); w != 2 {
+		t.Errorf("Read returned a frame of camera %d, want the backup's: the stalled primary's frame is stale", w)
+	}
+}
@@ -0,0 +1,71 @@
+package mediadevices
+
+import (
+	"sync"
+	"time"
+)
+
+// healthCheckInterval 是 OnHealthChange 重新评估轨道健康度的间隔。
+var healthCheckInterval = time.Second
+
+// HealthEvent 描述轨道健康等级的一次变化，参见 OnHealthChange。
+type HealthEvent struct {
+	TrackID string
+	Label   string
+	// Previous 是变化前的等级。
+	Previous HealthLevel
+	// Health 是变化后的健康度，Health.Level 为新等级。
+	Health StreamHealth
+}
+
+// Health 返回轨道最近 5 秒的健康度：实际帧率与配置帧率之比、帧间隔抖动、丢帧、
+// FFmpeg 警告数，合成 0–100 的评分，用于在大量摄像头中找出有问题的设备。
+// 音频轨道按音频块计算。只有被 Read/ReadAudio 取走的帧计入统计，
+// 因此读取过慢的应用也会看到评分下降。
+func (t *MediaStreamTrack) Health() StreamHealth {
+	return t.health.snapshot(time.Now())
+}
+
+// OnHealthChange 在轨道健康等级（HealthGood、HealthDegraded、HealthPoor）变化时调用 fn，
+// 初始等级视为 HealthGood。健康度每秒评估一次，因此完全停止产出帧的轨道也会触发事件。
+// fn 在单独的 goroutine 中调用。返回的 cancel 取消订阅；轨道停止后订阅自动结束。
+func (t *MediaStreamTrack) OnHealthChange(fn func(HealthEvent)) (cancel func()) {
+	stop := make(chan struct{})
+	go t.watchHealth(fn, stop)
+	return sync.OnceFunc(func() { close(stop) })
+}
+
+// watchHealth 定期评估健康度，等级变化时调用 fn，直到 stop 关闭或轨道停止。
+func (t *MediaStreamTrack) watchHealth(fn func(HealthEvent), stop <-chan struct{}) {
+	tick := time.NewTicker(healthCheckInterval)
+	defer tick.Stop()
+	subscribed := time.Now()
+	level := HealthGood
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-tick.C:
+			if t.ReadyState() == MediaStreamTrackStateEnded {
+				return
+			}
+			h := t.health.snapshot(now)
+			// 给尚未产出第一帧的轨道一个完整窗口的启动时间。
+			if h.FrameRate == 0 && now.Sub(subscribed) < h.Window {
+				continue
+			}
+			if h.Level != level {
+				fn(HealthEvent{TrackID: t.ID(), Label: t.Label(), Previous: level, Health: h})
+				level = h.Level
+			}
+		}
+	}
+}
+
+// sourceWarnings 返回数据源 FFmpeg 进程的 stderr 警告数，数据源不提供时返回 -1。
+func sourceWarnings(src any) int64 {
+	if w, ok := src.(interface{ warnings() int64 }); ok {
+		return w.warnings()
+	}
+	return -1
+}
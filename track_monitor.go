@@ -0,0 +1,149 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// monitorMaxLatency 是监听允许积压的最大时长。输入设备的时钟快于输出设备时，
+// 写入的音频会逐渐超前于播放，超前超过该时长的块被丢弃，使延迟不会随时间增长。
+const monitorMaxLatency = 150 * time.Millisecond
+
+// AudioMonitor 将音频轨道实时播放到输出设备，由 Monitor 创建。
+type AudioMonitor struct {
+	track  *MediaStreamTrack
+	writer *AudioWriter
+	gain   atomic.Uint64 // math.Float64bits
+
+	stopc    chan struct{}
+	stopOnce sync.Once
+	done     chan struct{}
+	err      error // 监听循环的第一个错误，done 关闭后可读
+}
+
+// Monitor 以低延迟将音频轨道 input 播放到输出设备 outputDeviceID
+// （AudioOutputDevices 返回的 DeviceID，为空时使用默认输出设备），
+// 用于通过耳机监听麦克风。gain 是线性增益，1 为原始音量，0 为静音。
+//
+// 监听基于 AudioWriter（LowLatency），因此同样不支持 Windows。
+// 监听期间轨道由 Monitor 读取，应用程序不应同时调用其 ReadAudio。
+// 轨道结束时监听随之结束；调用 Stop 停止监听，轨道本身不会被停止。
+func Monitor(input *MediaStreamTrack, outputDeviceID string, gain float64) (*AudioMonitor, error) {
+	if input == nil || input.Kind() != MediaDeviceKindAudioInput {
+		return nil, errors.New("monitor: input is not an audio track")
+	}
+	if gain < 0 || math.IsNaN(gain) || math.IsInf(gain, 0) {
+		return nil, fmt.Errorf("monitor: invalid gain %v", gain)
+	}
+
+	// 读取第一段音频以确定采样格式
+	first, err := input.ReadAudio()
+	if err != nil {
+		return nil, fmt.Errorf("monitor: read audio track: %w", err)
+	}
+	w, err := NewAudioWriter(AudioWriterConfig{
+		DeviceID:   outputDeviceID,
+		SampleRate: first.SampleRate,
+		Channels:   first.Channels,
+		LowLatency: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("monitor: %w", err)
+	}
+
+	m := &AudioMonitor{
+		track:  input,
+		writer: w,
+		stopc:  make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	m.SetGain(gain)
+	go m.run(first)
+	return m, nil
+}
+
+// SetGain 设置线性增益，监听期间即时生效。负值按 0 处理。
+func (m *AudioMonitor) SetGain(gain float64) {
+	m.gain.Store(math.Float64bits(max(gain, 0)))
+}
+
+// Gain 返回当前的线性增益。
+func (m *AudioMonitor) Gain() float64 {
+	return math.Float64frombits(m.gain.Load())
+}
+
+// Done 返回在监听结束（调用 Stop、轨道结束或播放出错）时关闭的通道。
+func (m *AudioMonitor) Done() <-chan struct{} {
+	return m.done
+}
+
+// Stop 停止监听并关闭输出设备，返回监听过程中发生的第一个错误。
+// 轨道正常结束不视为错误。
+func (m *AudioMonitor) Stop() error {
+	m.stopOnce.Do(func() { close(m.stopc) })
+	<-m.done
+	return m.err
+}
+
+// run 从 first 开始将轨道的音频写入输出设备，直到轨道结束或 Stop 被调用。
+// 写入的音频时长以挂钟为准：超前超过 monitorMaxLatency 时丢弃，
+// 输入停顿后从当前时刻重新计时，不补齐空缺。
+func (m *AudioMonitor) run(first *AudioChunk) {
+	defer close(m.done)
+	defer func() {
+		if err := m.writer.Close(); m.err == nil {
+			m.err = err
+		}
+	}()
+
+	start := time.Now()
+	var written time.Duration // 已写入音频的时长
+	var buf []int16
+	chunk := first
+	for {
+		elapsed := time.Since(start)
+		if elapsed-written > monitorMaxLatency {
+			written = elapsed
+		}
+		if written-elapsed <= monitorMaxLatency {
+			buf = applyGain(buf[:0], chunk.Data, m.Gain())
+			out := *chunk
+			out.Data = buf
+			if err := m.writer.Write(&out); err != nil {
+				m.err = fmt.Errorf("monitor: %w", err)
+				return
+			}
+			written += time.Duration(chunk.SamplesPerChannel) * time.Second / time.Duration(chunk.SampleRate)
+		}
+
+		select {
+		case <-m.stopc:
+			return
+		default:
+		}
+		var err error
+		if chunk, err = m.track.ReadAudio(); err != nil {
+			if err != io.EOF {
+				m.err = fmt.Errorf("monitor: read audio track: %w", err)
+			}
+			return
+		}
+	}
+}
+
+// applyGain 将 src 乘以 gain 后追加到 dst，超出 int16 范围的采样被削波。
+func applyGain(dst, src []int16, gain float64) []int16 {
+	if gain == 1 {
+		return append(dst, src...)
+	}
+	for _, s := range src {
+		v := math.Round(float64(s) * gain)
+		dst = append(dst, int16(max(math.MinInt16, min(math.MaxInt16, v))))
+	}
+	return dst
+}
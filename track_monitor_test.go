@@ -0,0 +1,72 @@
+//go:build !windows && !android
+
+package mediadevices
+
+import (
+	"context"
+	"encoding/binary"
+	"image"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMonitor(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	// sh stands in for ffmpeg and stores what would be played.
+	out := filepath.Join(t.TempDir(), "played.raw")
+	SetConfig(Config{
+		FFmpegPath: "/bin/sh",
+		ArgsHook:   func([]string) []string { return []string{"-c", "cat > " + out} },
+		DiscoverDevices: func(context.Context) ([]MediaDeviceInfo, error) {
+			return []MediaDeviceInfo{{DeviceID: "headset", DeviceName: "hw:1", Kind: MediaDeviceKindAudioOutput}}, nil
+		},
+	})
+
+	chunks := [][]int16{{100, -100}, {20000, -20000}}
+	track, err := NewAudioTrackFromFunc(func() *AudioChunk {
+		if len(chunks) == 0 {
+			return nil
+		}
+		data := chunks[0]
+		chunks = chunks[1:]
+		return &AudioChunk{Data: data, Channels: 2, SampleRate: 48000, SamplesPerChannel: 1}
+	}, 48000, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := Monitor(track, "headset", 2)
+	if err != nil {
+		t.Fatalf("Monitor: %v", err)
+	}
+	<-m.Done() // the track ends after two chunks
+	if err := m.Stop(); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]int16, len(data)/2)
+	for i := range got {
+		got[i] = int16(binary.LittleEndian.Uint16(data[2*i:]))
+	}
+	if want := []int16{200, -200, 32767, -32768}; !slices.Equal(got, want) {
+		t.Errorf("played %v, want %v (doubled and clipped)", got, want)
+	}
+}
+
+func TestMonitor_Invalid(t *testing.T) {
+	video, _ := NewVideoTrackFromFunc(func() image.Image { return nil }, 30)
+	if _, err := Monitor(video, "", 1); err == nil {
+		t.Error("Monitor accepted a video track")
+	}
+	audio, _ := NewAudioTrackFromFunc(func() *AudioChunk { return nil }, 48000, 1)
+	if _, err := Monitor(audio, "", -1); err == nil {
+		t.Error("Monitor accepted a negative gain")
+	}
+}
@@ -0,0 +1,219 @@
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"sync"
+	"time"
+)
+
+// NewVideoTrackFromFunc 创建一个由应用程序代码生成画面的视频轨道。
+// 每次 Read 按 fps 节奏调用一次 fn，适用于渲染画面、图表等生成内容，
+// 使其与摄像头轨道一样通过 MediaStream 等机制传递。
+//
+// fn 返回的图像会被转换为 YUV420p 的 *image.YCbCr（已是该格式时直接使用），
+// 轨道尺寸取自首帧。fn 返回 nil 表示数据结束，此后 Read 返回 io.EOF。
+func NewVideoTrackFromFunc(fn func() image.Image, fps float64) (*MediaStreamTrack, error) {
+	if fn == nil {
+		return nil, fmt.Errorf("video source function is nil")
+	}
+	if fps <= 0 {
+		return nil, fmt.Errorf("frame rate must be positive (got %g)", fps)
+	}
+
+	// 预先生成首帧以确定轨道尺寸，首次 Read 时返回它
+	first := fn()
+	if first == nil {
+		return nil, fmt.Errorf("video source produced no frames")
+	}
+	b := first.Bounds()
+
+	src := &funcVideoSource{
+		fn:      fn,
+		pending: toYCbCr420(first),
+		width:   b.Dx(),
+		height:  b.Dy(),
+		pacer:   &pacer{},
+		fps:     fps,
+		period:  time.Duration(float64(time.Second) / fps),
+	}
+
+	return newCustomTrack(MediaDeviceKindVideoInput, "Custom video source", src, nil), nil
+}
+
+// NewAudioTrackFromFunc 创建一个由应用程序代码生成音频的音频轨道。
+// 每次 ReadAudio 调用一次 fn，并按返回块的时长（SamplesPerChannel / sampleRate）控制节奏，
+// 适用于语音合成等生成内容。
+//
+// fn 返回的 AudioChunk 必须与 sampleRate、channels 一致；
+// 返回 nil 表示数据结束，此后 ReadAudio 返回 io.EOF。
+func NewAudioTrackFromFunc(fn func() *AudioChunk, sampleRate, channels int) (*MediaStreamTrack, error) {
+	if fn == nil {
+		return nil, fmt.Errorf("audio source function is nil")
+	}
+	if sampleRate <= 0 || channels <= 0 {
+		return nil, fmt.Errorf("sample rate and channels must be positive (got %d Hz, %d channels)", sampleRate, channels)
+	}
+
+	src := &funcAudioSource{
+		fn:         fn,
+		sampleRate: sampleRate,
+		channels:   channels,
+		pacer:      &pacer{},
+	}
+
+	return newCustomTrack(MediaDeviceKindAudioInput, "Custom audio source", nil, src), nil
+}
+
+// newCustomTrack 使用给定的数据源创建一个处于 live 状态、登记在默认实例中的轨道。
+func newCustomTrack(kind MediaDeviceKind, label string, video videoSource, audio audioSource) *MediaStreamTrack {
+	return defaultMediaDevices.newCustomTrack(kind, label, video, audio)
+}
+
+// newCustomTrack 与包级函数 newCustomTrack 相同，但轨道登记在 m 中。
+func (m *MediaDevices) newCustomTrack(kind MediaDeviceKind, label string, video videoSource, audio audioSource) *MediaStreamTrack {
+	return m.resources.addTrack(&MediaStreamTrack{
+		id:          generateTrackID(),
+		kind:        kind,
+		label:       label,
+		readyState:  MediaStreamTrackStateLive,
+		videoReader: video,
+		audioReader: audio,
+	})
+}
+
+// pacer 将读取节奏限制为实时速率。
+// 当调用方落后超过一个周期时重新对齐，避免追赶式的突发输出。
+type pacer struct {
+	start   time.Time
+	elapsed time.Duration // 已输出数据的媒体时长
+}
+
+// wait 阻塞直到已输出的媒体时长与实际经过时间对齐，然后记入 d。
+func (p *pacer) wait(d time.Duration) {
+	now := time.Now()
+	if p.start.IsZero() {
+		p.start = now
+	}
+	due := p.start.Add(p.elapsed)
+	if lag := now.Sub(due); lag > d {
+		// 落后太多：从当前时刻重新计时
+		p.start = now.Add(-p.elapsed)
+	} else if lag < 0 {
+		time.Sleep(-lag)
+	}
+	p.elapsed += d
+}
+
+// funcVideoSource 按固定帧率调用函数生成视频帧。
+type funcVideoSource struct {
+	mu      sync.Mutex
+	fn      func() image.Image
+	pending *image.YCbCr
+	width   int
+	height  int
+	pacer   *pacer
+	fps     float64
+	period  time.Duration
+	done    bool
+}
+
+func (s *funcVideoSource) Read() (image.Image, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return nil, io.EOF
+	}
+	s.pacer.wait(s.period)
+
+	if s.pending != nil {
+		img := s.pending
+		s.pending = nil
+		return img, nil
+	}
+	img := s.fn()
+	if img == nil {
+		s.done = true
+		return nil, io.EOF
+	}
+	return toYCbCr420(img), nil
+}
+
+func (s *funcVideoSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	return nil
+}
+
+func (s *funcVideoSource) Width() int         { return s.width }
+func (s *funcVideoSource) Height() int        { return s.height }
+func (s *funcVideoSource) FrameRate() float64 { return s.fps }
+
+// funcAudioSource 调用函数生成音频块，并按块时长控制节奏。
+type funcAudioSource struct {
+	mu         sync.Mutex
+	fn         func() *AudioChunk
+	sampleRate int
+	channels   int
+	pacer      *pacer
+	done       bool
+}
+
+func (s *funcAudioSource) Read() (*AudioChunk, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done {
+		return nil, io.EOF
+	}
+	chunk := s.fn()
+	if chunk == nil {
+		s.done = true
+		return nil, io.EOF
+	}
+	if chunk.Channels != s.channels || chunk.SampleRate != s.sampleRate {
+		return nil, fmt.Errorf("audio source chunk is %d Hz/%d ch, track is %d Hz/%d ch",
+			chunk.SampleRate, chunk.Channels, s.sampleRate, s.channels)
+	}
+	s.pacer.wait(time.Duration(chunk.SamplesPerChannel) * time.Second / time.Duration(s.sampleRate))
+	return chunk, nil
+}
+
+func (s *funcAudioSource) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.done = true
+	return nil
+}
+
+func (s *funcAudioSource) SampleRate() int { return s.sampleRate }
+func (s *funcAudioSource) Channels() int   { return s.channels }
+
+// toYCbCr420 将任意图像转换为与 FFmpeg 捕获相同格式的 YUV420p *image.YCbCr。
+// 已是 4:2:0 的 *image.YCbCr 直接返回。
+func toYCbCr420(img image.Image) *image.YCbCr {
+	if y, ok := img.(*image.YCbCr); ok && y.SubsampleRatio == image.YCbCrSubsampleRatio420 {
+		return y
+	}
+
+	b := img.Bounds()
+	out := image.NewYCbCr(image.Rect(0, 0, b.Dx(), b.Dy()), image.YCbCrSubsampleRatio420)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			yy, cb, cr := color.RGBToYCbCr(uint8(r>>8), uint8(g>>8), uint8(bl>>8))
+			out.Y[out.YOffset(x, y)] = yy
+			// 每个 2x2 块取左上角像素的色度
+			if x%2 == 0 && y%2 == 0 {
+				ci := out.COffset(x, y)
+				out.Cb[ci] = cb
+				out.Cr[ci] = cr
+			}
+		}
+	}
+	return out
+}
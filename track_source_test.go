@@ -0,0 +1,95 @@
+package mediadevices
+
+import (
+	"image"
+	"image/color"
+	"io"
+	"testing"
+)
+
+func TestNewVideoTrackFromFunc(t *testing.T) {
+	frames := 0
+	fn := func() image.Image {
+		if frames == 3 {
+			return nil
+		}
+		frames++
+		img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+		for i := range img.Pix {
+			img.Pix[i] = 0xff // white
+		}
+		return img
+	}
+
+	track, err := NewVideoTrackFromFunc(fn, 1000)
+	if err != nil {
+		t.Fatalf("NewVideoTrackFromFunc: %v", err)
+	}
+	defer track.Stop()
+
+	if s := track.GetSettings(); s.Width != 4 || s.Height != 2 {
+		t.Errorf("settings = %dx%d, want 4x2", s.Width, s.Height)
+	}
+
+	for i := 0; i < 3; i++ {
+		img, err := track.Read()
+		if err != nil {
+			t.Fatalf("Read %d: %v", i, err)
+		}
+		ycc, ok := img.(*image.YCbCr)
+		if !ok || ycc.SubsampleRatio != image.YCbCrSubsampleRatio420 {
+			t.Fatalf("Read %d returned %T, want YUV420p *image.YCbCr", i, img)
+		}
+		if ycc.Y[0] != 255 {
+			t.Errorf("Y[0] = %d, want 255 for white", ycc.Y[0])
+		}
+	}
+	if _, err := track.Read(); err != io.EOF {
+		t.Errorf("Read after source end = %v, want io.EOF", err)
+	}
+}
+
+func TestNewAudioTrackFromFunc(t *testing.T) {
+	chunk := &AudioChunk{Data: make([]int16, 96), Channels: 2, SampleRate: 48000, SamplesPerChannel: 48}
+	calls := 0
+	fn := func() *AudioChunk {
+		if calls == 2 {
+			return nil
+		}
+		calls++
+		return chunk
+	}
+
+	track, err := NewAudioTrackFromFunc(fn, 48000, 2)
+	if err != nil {
+		t.Fatalf("NewAudioTrackFromFunc: %v", err)
+	}
+	if track.Kind() != MediaDeviceKindAudioInput {
+		t.Errorf("Kind() = %q", track.Kind())
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := track.ReadAudio(); err != nil {
+			t.Fatalf("ReadAudio %d: %v", i, err)
+		}
+	}
+	if _, err := track.ReadAudio(); err != io.EOF {
+		t.Errorf("ReadAudio after source end = %v, want io.EOF", err)
+	}
+}
+
+func TestToYCbCr420(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 10, 12, 12))
+	for y := 10; y < 12; y++ {
+		for x := 10; x < 12; x++ {
+			img.Set(x, y, color.RGBA{R: 255, A: 255})
+		}
+	}
+	out := toYCbCr420(img)
+	if out.Rect != image.Rect(0, 0, 2, 2) {
+		t.Errorf("Rect = %v, want origin-based 2x2", out.Rect)
+	}
+	wy, wcb, wcr := color.RGBToYCbCr(255, 0, 0)
+	if out.Y[0] != wy || out.Cb[0] != wcb || out.Cr[0] != wcr {
+		t.Errorf("pixel = (%d,%d,%d), want (%d,%d,%d)", out.Y[0], out.Cb[0], out.Cr[0], wy, wcb, wcr)
+	}
+}
@@ -0,0 +1,175 @@
+package mediadevices
+
+import (
+	"context"
+	"fmt"
+	"image"
+	"time"
+)
+
+// SwitchDevice 将轨道的数据源无缝切换到另一个设备（如前后摄像头切换）。
+// 新设备以当前轨道设置（分辨率/帧率或采样率/声道数）打开，
+// 在其产出第一帧（或第一个音频块）后才原子地替换旧数据源并关闭旧设备，
+// 因此读取方看到的是连续的数据流，轨道 ID 保持不变。
+//
+// deviceID 为 EnumerateDevices 返回的 DeviceID，且必须与轨道类型一致。
+// 新设备在创建轨道的 MediaDevices 的设备中查找，并以其配置打开。
+// 切换失败时旧数据源保持不变。
+func (t *MediaStreamTrack) SwitchDevice(deviceID string) error {
+	t.reconfigMu.Lock()
+	defer t.reconfigMu.Unlock()
+
+	t.mu.Lock()
+	ended := t.readyState == MediaStreamTrackStateEnded
+	video, audio := t.videoReader, t.audioReader
+	t.mu.Unlock()
+	if ended {
+		return fmt.Errorf("switch device: track has ended")
+	}
+
+	devices, err := t.owner.devicesByKind(context.Background(), t.kind)
+	if err != nil {
+		return fmt.Errorf("switch device: %w", err)
+	}
+	info, found := t.owner.findDevice(devices, deviceID)
+	if !found {
+		return fmt.Errorf("switch device: %s %w: %s", t.kind, ErrNotFound, deviceID)
+	}
+
+	switch t.kind {
+	case MediaDeviceKindVideoInput:
+		frameRate := sourceFrameRate(video)
+		if frameRate <= 0 {
+			frameRate = 30
+		}
+		src, err := t.openPrimedVideo(info, video.Width(), video.Height(), frameRate)
+		if err != nil {
+			return fmt.Errorf("switch device: %w", err)
+		}
+		if err := t.replaceSource(info, src, nil); err != nil {
+			return fmt.Errorf("switch device: %w", err)
+		}
+		return nil
+
+	case MediaDeviceKindAudioInput:
+		src, err := t.openPrimedAudio(info, AudioConfig{
+			SampleRate: audio.SampleRate(),
+			Channels:   audio.Channels(),
+			Latency:    sourceLatency(audio),
+		})
+		if err != nil {
+			return fmt.Errorf("switch device: %w", err)
+		}
+		if err := t.replaceSource(info, nil, src); err != nil {
+			return fmt.Errorf("switch device: %w", err)
+		}
+		return nil
+	}
+	return fmt.Errorf("switch device: unsupported track kind %q", t.kind)
+}
+
+// openPrimedVideo 以给定设置打开设备 info，并等待其产出第一帧，
+// 确保替换数据源后读取方不会遇到冷启动空档。
+func (t *MediaStreamTrack) openPrimedVideo(info MediaDeviceInfo, width, height int, frameRate float64) (videoSource, error) {
+	reader, err := t.owner.newVideoReaderInternal(ffmpegDeviceName(info), width, height, frameRate, nil)
+	if err != nil {
+		return nil, err
+	}
+	first, err := reader.Read()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &primedVideoSource{videoSource: reader, first: first}, nil
+}
+
+// openPrimedAudio 以 cfg 的格式打开设备 info，并等待其产出第一个音频块。
+func (t *MediaStreamTrack) openPrimedAudio(info MediaDeviceInfo, cfg AudioConfig) (audioSource, error) {
+	reader, err := t.owner.newAudioReaderInternal(ffmpegDeviceName(info), cfg)
+	if err != nil {
+		return nil, err
+	}
+	first, err := reader.Read()
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return &primedAudioSource{audioSource: reader, first: first}, nil
+}
+
+// replaceSource 原子地替换轨道的数据源并关闭旧数据源，轨道随后属于设备 info。
+// 若轨道在新数据源准备期间被停止，则关闭新数据源并返回错误。
+// 旧数据源的关闭错误（如被终止的 FFmpeg 的退出状态）被忽略，替换本身已成功。
+func (t *MediaStreamTrack) replaceSource(info MediaDeviceInfo, video videoSource, audio audioSource) error {
+	t.mu.Lock()
+	if t.readyState == MediaStreamTrackStateEnded {
+		t.mu.Unlock()
+		if video != nil {
+			video.Close()
+		}
+		if audio != nil {
+			audio.Close()
+		}
+		return fmt.Errorf("track has ended")
+	}
+
+	var old interface{ Close() error }
+	if video != nil {
+		old = t.videoReader
+		t.videoReader = video
+	} else {
+		old = t.audioReader
+		t.audioReader = audio
+	}
+	t.label = info.Label
+	if info.DeviceID != t.device.DeviceID {
+		t.caps = nil
+	}
+	t.device = info
+	t.mu.Unlock()
+
+	// 在锁外关闭旧数据源：阻塞在旧数据源上的 Read 会返回错误，
+	// 并在检测到数据源已替换后转向新数据源。
+	old.Close()
+	return nil
+}
+
+// primedVideoSource 在首次 Read 时先返回预读的第一帧。
+type primedVideoSource struct {
+	videoSource
+	first image.Image
+}
+
+func (s *primedVideoSource) Read() (image.Image, error) {
+	if s.first != nil {
+		img := s.first
+		s.first = nil
+		return img, nil
+	}
+	return s.videoSource.Read()
+}
+
+// FrameRate 返回底层数据源的帧率。
+func (s *primedVideoSource) FrameRate() float64 {
+	return sourceFrameRate(s.videoSource)
+}
+
+// primedAudioSource 在首次 Read 时先返回预读的第一个音频块。
+type primedAudioSource struct {
+	audioSource
+	first *AudioChunk
+}
+
+func (s *primedAudioSource) Read() (*AudioChunk, error) {
+	if s.first != nil {
+		chunk := s.first
+		s.first = nil
+		return chunk, nil
+	}
+	return s.audioSource.Read()
+}
+
+// Latency 返回底层数据源每段音频的时长。
+func (s *primedAudioSource) Latency() time.Duration {
+	return sourceLatency(s.audioSource)
+}
@@ -0,0 +1,64 @@
+package mediadevices
+
+import (
+	"image"
+	"testing"
+)
+
+func TestPrimedVideoSource_ReturnsFirstFrame(t *testing.T) {
+	first := image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio420)
+	next := image.NewYCbCr(image.Rect(0, 0, 2, 2), image.YCbCrSubsampleRatio420)
+	calls := 0
+	inner := &funcVideoSource{
+		fn:     func() image.Image { calls++; return next },
+		width:  2,
+		height: 2,
+		pacer:  &pacer{},
+		fps:    25,
+	}
+	src := &primedVideoSource{videoSource: inner, first: first}
+
+	if img, err := src.Read(); err != nil || img != first {
+		t.Fatalf("first Read = %p, %v; want prefetched frame", img, err)
+	}
+	if calls != 0 {
+		t.Fatalf("underlying source called %d times before prefetched frame was consumed", calls)
+	}
+	if img, err := src.Read(); err != nil || img != next {
+		t.Fatalf("second Read = %p, %v; want frame from underlying source", img, err)
+	}
+	if got := src.FrameRate(); got != 25 {
+		t.Errorf("FrameRate = %g, want 25", got)
+	}
+}
+
+func TestSwitchDevice_EndedTrack(t *testing.T) {
+	track, err := NewBlackVideoTrack(16, 16, 10)
+	if err != nil {
+		t.Fatalf("NewBlackVideoTrack: %v", err)
+	}
+	track.Stop()
+	if err := track.SwitchDevice("any"); err == nil {
+		t.Fatal("SwitchDevice on an ended track succeeded")
+	}
+}
+
+func TestReplaceSource_LabelRace(t *testing.T) {
+	track := newCustomTrack(MediaDeviceKindVideoInput, "Front", &stampedVideoSource{n: 1}, nil)
+	defer track.Stop()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 100 {
+			track.Label()
+		}
+	}()
+	if err := track.replaceSource(MediaDeviceInfo{Label: "Rear"}, &stampedVideoSource{n: 1}, nil); err != nil {
+		t.Fatalf("replaceSource: %v", err)
+	}
+	<-done
+	if got := track.Label(); got != "Rear" {
+		t.Errorf("Label() = %q, want Rear", got)
+	}
+}
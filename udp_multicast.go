@@ -0,0 +1,125 @@
+package mediadevices
+
+import (
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// UDPWriterConfig configures a UDPWriter created by NewUDPWriterWithConfig.
+// The Multicast fields apply only when the destination is a multicast
+// group.
+type UDPWriterConfig struct {
+	// MTU is the maximum IP packet size. Defaults to 1500.
+	MTU int
+	// LocalAddr is the local "host:port" to send from. Empty uses an
+	// ephemeral port on all interfaces.
+	LocalAddr string
+	// ReuseAddr sets SO_REUSEADDR, so that other sockets, such as a local
+	// receiver, can bind the same address.
+	ReuseAddr bool
+
+	// RTCP selects how RTCP travels next to the RTP stream. The default,
+	// RTCPDisabled, opens only the RTP socket.
+	RTCP RTCPMode
+	// RemoteRTCPAddr is the "host:port" RTCP is sent to. Empty means the
+	// RTP destination with RTCPMux and the next port with RTCPPortPair.
+	RemoteRTCPAddr string
+	// PortMin and PortMax restrict the local port to a range, for
+	// firewalls that open only these ports. With RTCPPortPair both ports of
+	// the pair lie in the range. The port of LocalAddr must be 0 then.
+	PortMin, PortMax int
+
+	// MulticastTTL is the TTL (IPv6 hop limit) of multicast packets. Zero
+	// keeps the system default of 1, which confines them to the local
+	// network.
+	MulticastTTL int
+	// MulticastInterface is the interface multicast packets leave from.
+	// Nil leaves the choice to the routing table.
+	MulticastInterface *net.Interface
+	// DisableMulticastLoopback stops multicast packets from being
+	// delivered to receivers on this host.
+	DisableMulticastLoopback bool
+}
+
+// udpSocketOptions are the socket options applied by setUDPSocketOptions.
+type udpSocketOptions struct {
+	ipv6      bool
+	reuseAddr bool
+	multicast bool
+	ttl       int
+	ifIndex   int     // IPv6 multicast interface, 0 for the default
+	ifAddr    [4]byte // IPv4 multicast interface address, zero for the default
+	noLoop    bool
+}
+
+// NewUDPWriterWithConfig creates a UDP writer for RTP streaming to addr,
+// which may be a unicast address or a multicast group such as
+// "239.1.1.1:5004" or "[ff15::1]:5004", with the socket options of cfg.
+func NewUDPWriterWithConfig(addr string, cfg UDPWriterConfig) (*UDPWriter, error) {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("resolve UDP addr: %w", err)
+	}
+
+	opts := udpSocketOptions{
+		ipv6:      udpAddr.IP.To4() == nil,
+		reuseAddr: cfg.ReuseAddr,
+		multicast: udpAddr.IP.IsMulticast(),
+		ttl:       cfg.MulticastTTL,
+		noLoop:    cfg.DisableMulticastLoopback,
+	}
+	if opts.multicast && cfg.MulticastInterface != nil {
+		if opts.ipv6 {
+			opts.ifIndex = cfg.MulticastInterface.Index
+		} else if opts.ifAddr, err = interfaceIPv4(cfg.MulticastInterface); err != nil {
+			return nil, err
+		}
+	}
+
+	network := "udp4"
+	if opts.ipv6 {
+		network = "udp6"
+	}
+	lc := net.ListenConfig{
+		Control: func(_, _ string, c syscall.RawConn) error {
+			var serr error
+			if err := c.Control(func(fd uintptr) { serr = setUDPSocketOptions(fd, opts) }); err != nil {
+				return err
+			}
+			return serr
+		},
+	}
+
+	w := &UDPWriter{addr: udpAddr, rtcpMode: cfg.RTCP}
+	if w.rtcpAddr, err = remoteRTCPAddr(udpAddr, cfg); err != nil {
+		return nil, err
+	}
+	if w.conn, w.rtcpConn, err = listenRTPPorts(lc, network, cfg); err != nil {
+		return nil, err
+	}
+
+	mtu := cfg.MTU
+	if mtu <= 0 {
+		mtu = 1500
+	}
+	w.payload = mtu - 20 - 8 // MTU - IP header - UDP header
+	return w, nil
+}
+
+// interfaceIPv4 returns the first IPv4 address of ifi, which identifies
+// the interface in IP_MULTICAST_IF.
+func interfaceIPv4(ifi *net.Interface) ([4]byte, error) {
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return [4]byte{}, fmt.Errorf("interface %s: %w", ifi.Name, err)
+	}
+	for _, a := range addrs {
+		if ipn, ok := a.(*net.IPNet); ok {
+			if ip4 := ipn.IP.To4(); ip4 != nil {
+				return [4]byte(ip4), nil
+			}
+		}
+	}
+	return [4]byte{}, fmt.Errorf("interface %s has no IPv4 address", ifi.Name)
+}
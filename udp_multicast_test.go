@@ -0,0 +1,82 @@
+package mediadevices
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestUDPWriterWithConfig_Unicast(t *testing.T) {
+	rx, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+
+	w, err := NewUDPWriterWithConfig(rx.LocalAddr().String(), UDPWriterConfig{ReuseAddr: true, MulticastTTL: 8})
+	if err != nil {
+		t.Fatalf("NewUDPWriterWithConfig: %v", err)
+	}
+	defer w.Close()
+	if err := w.Write([]byte("hello")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	buf := make([]byte, 16)
+	rx.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := rx.ReadFromUDP(buf)
+	if err != nil || string(buf[:n]) != "hello" {
+		t.Fatalf("received %q, %v", buf[:n], err)
+	}
+}
+
+func TestUDPWriter_Connected(t *testing.T) {
+	rx, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rx.Close()
+
+	w, err := NewUDPWriter(rx.LocalAddr().String(), 0)
+	if err != nil {
+		t.Fatalf("NewUDPWriter: %v", err)
+	}
+	defer w.Close()
+	if err := w.Write([]byte("x")); err != nil {
+		t.Errorf("Write on dialed socket: %v", err)
+	}
+}
+
+func TestUDPWriterWithConfig_Multicast(t *testing.T) {
+	lo := loopbackInterface(t)
+	w, err := NewUDPWriterWithConfig("239.255.42.1:5004", UDPWriterConfig{
+		ReuseAddr:          true,
+		MulticastTTL:       4,
+		MulticastInterface: lo,
+	})
+	if err != nil {
+		t.Fatalf("NewUDPWriterWithConfig: %v", err)
+	}
+	w.Close()
+
+	if _, err := NewUDPWriterWithConfig("239.255.42.1:5004", UDPWriterConfig{MulticastInterface: &net.Interface{Name: "none", Index: 999999}}); err == nil {
+		t.Error("interface without addresses accepted")
+	}
+}
+
+func loopbackInterface(t *testing.T) *net.Interface {
+	t.Helper()
+	ifs, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for i := range ifs {
+		if ifs[i].Flags&net.FlagLoopback != 0 {
+			if _, err := interfaceIPv4(&ifs[i]); err == nil {
+				return &ifs[i]
+			}
+		}
+	}
+	t.Skip("no IPv4 loopback interface")
+	return nil
+}
@@ -0,0 +1,201 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strconv"
+)
+
+// RTCPMode selects how a UDPWriter carries RTCP next to RTP.
+type RTCPMode string
+
+const (
+	// RTCPDisabled opens only the RTP socket.
+	RTCPDisabled RTCPMode = ""
+	// RTCPMux sends and receives RTCP on the RTP socket (rtcp-mux, RFC
+	// 5761). It needs one firewall port and is what WebRTC peers expect.
+	RTCPMux RTCPMode = "mux"
+	// RTCPPortPair uses a separate RTCP socket on the odd port following
+	// the even RTP port (RFC 3550), locally and at the destination.
+	RTCPPortPair RTCPMode = "pair"
+)
+
+// errRTCPDisabled is returned by the RTCP methods of a UDPWriter without
+// an RTCP transport.
+var errRTCPDisabled = errors.New("udp writer: RTCP is disabled")
+
+// portPairAttempts bounds the search for a free ephemeral port pair.
+const portPairAttempts = 32
+
+// WriteRTCP sends a compound RTCP packet, such as a sender report, to the
+// RTCP destination. It fails unless UDPWriterConfig.RTCP was set.
+func (w *UDPWriter) WriteRTCP(data []byte) error {
+	var err error
+	switch w.rtcpMode {
+	case RTCPMux:
+		_, err = w.conn.WriteToUDP(data, w.rtcpAddr)
+	case RTCPPortPair:
+		_, err = w.rtcpConn.WriteToUDP(data, w.rtcpAddr)
+	default:
+		err = errRTCPDisabled
+	}
+	return err
+}
+
+// ReadRTCP reads the next RTCP packet sent to this writer, such as a
+// receiver report or a keyframe request, into buf. With RTCPMux, RTP
+// packets arriving on the shared socket are skipped. It blocks until a
+// packet arrives or the writer is closed.
+func (w *UDPWriter) ReadRTCP(buf []byte) (int, error) {
+	switch w.rtcpMode {
+	case RTCPMux:
+		for {
+			n, _, err := w.conn.ReadFromUDP(buf)
+			if err != nil || isRTCPPacket(buf[:n]) {
+				return n, err
+			}
+		}
+	case RTCPPortPair:
+		n, _, err := w.rtcpConn.ReadFromUDP(buf)
+		return n, err
+	default:
+		return 0, errRTCPDisabled
+	}
+}
+
+// LocalRTCPAddr returns the local address RTCP is sent from, or nil when
+// RTCP is disabled.
+func (w *UDPWriter) LocalRTCPAddr() *net.UDPAddr {
+	switch w.rtcpMode {
+	case RTCPMux:
+		return w.LocalAddr()
+	case RTCPPortPair:
+		return w.rtcpConn.LocalAddr().(*net.UDPAddr)
+	default:
+		return nil
+	}
+}
+
+// isRTCPPacket reports whether a packet on a multiplexed socket is RTCP:
+// its second byte, the RTP marker and payload type, lies in 192-223
+// (RFC 5761, section 4).
+func isRTCPPacket(b []byte) bool {
+	return len(b) >= 2 && b[1] >= 192 && b[1] <= 223
+}
+
+// remoteRTCPAddr returns the RTCP destination for the RTP destination
+// rtpAddr under cfg, or nil when RTCP is disabled.
+func remoteRTCPAddr(rtpAddr *net.UDPAddr, cfg UDPWriterConfig) (*net.UDPAddr, error) {
+	switch cfg.RTCP {
+	case RTCPDisabled:
+		return nil, nil
+	case RTCPMux, RTCPPortPair:
+	default:
+		return nil, fmt.Errorf("udp writer: unknown RTCP mode %q", cfg.RTCP)
+	}
+	if cfg.RemoteRTCPAddr != "" {
+		addr, err := net.ResolveUDPAddr("udp", cfg.RemoteRTCPAddr)
+		if err != nil {
+			return nil, fmt.Errorf("resolve RTCP addr: %w", err)
+		}
+		return addr, nil
+	}
+	addr := *rtpAddr
+	if cfg.RTCP == RTCPPortPair {
+		addr.Port++
+	}
+	return &addr, nil
+}
+
+// listenRTPPorts opens the RTP socket and, with RTCPPortPair, the RTCP
+// socket on the next port, honouring cfg.LocalAddr and the port range.
+func listenRTPPorts(lc net.ListenConfig, network string, cfg UDPWriterConfig) (rtpConn, rtcpConn *net.UDPConn, err error) {
+	host, port := "", 0
+	if cfg.LocalAddr != "" {
+		h, p, err := net.SplitHostPort(cfg.LocalAddr)
+		if err != nil {
+			return nil, nil, fmt.Errorf("local addr: %w", err)
+		}
+		if host, port = h, 0; p != "" {
+			if port, err = strconv.Atoi(p); err != nil {
+				return nil, nil, fmt.Errorf("local addr %q: bad port", cfg.LocalAddr)
+			}
+		}
+	}
+	pair := cfg.RTCP == RTCPPortPair
+	listen := func(port int) (*net.UDPConn, error) {
+		pc, err := lc.ListenPacket(context.Background(), network, net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			return nil, err
+		}
+		return pc.(*net.UDPConn), nil
+	}
+	// listenPair binds port and, for a pair, port+1.
+	listenPair := func(port int) (*net.UDPConn, *net.UDPConn, error) {
+		rtp, err := listen(port)
+		if err != nil || !pair {
+			return rtp, nil, err
+		}
+		if port == 0 {
+			port = rtp.LocalAddr().(*net.UDPAddr).Port
+			if port%2 != 0 || port == 65535 {
+				rtp.Close()
+				return nil, nil, fmt.Errorf("ephemeral port %d cannot start a pair", port)
+			}
+		}
+		rtcp, err := listen(port + 1)
+		if err != nil {
+			rtp.Close()
+			return nil, nil, err
+		}
+		return rtp, rtcp, nil
+	}
+
+	if cfg.PortMin == 0 && cfg.PortMax == 0 {
+		if port != 0 || !pair {
+			if pair && port%2 != 0 {
+				return nil, nil, fmt.Errorf("udp writer: RTP port %d of a port pair must be even", port)
+			}
+			rtpConn, rtcpConn, err = listenPair(port)
+			if err != nil {
+				return nil, nil, fmt.Errorf("open UDP socket: %w", err)
+			}
+			return rtpConn, rtcpConn, nil
+		}
+		// Ephemeral pair: retry until the system hands out an even port
+		// whose successor is free.
+		for range portPairAttempts {
+			if rtpConn, rtcpConn, err = listenPair(0); err == nil {
+				return rtpConn, rtcpConn, nil
+			}
+		}
+		return nil, nil, fmt.Errorf("open UDP port pair: %w", err)
+	}
+
+	if port != 0 {
+		return nil, nil, fmt.Errorf("udp writer: local addr %q names a port and a port range is set", cfg.LocalAddr)
+	}
+	lo, hi, step := cfg.PortMin, cfg.PortMax, 1
+	if pair {
+		lo += lo % 2
+		hi--
+		step = 2
+	}
+	if cfg.PortMin <= 0 || cfg.PortMax > 65535 || lo > hi {
+		return nil, nil, fmt.Errorf("udp writer: invalid port range %d-%d", cfg.PortMin, cfg.PortMax)
+	}
+	// Start at a random port so that writers do not all race for the
+	// bottom of the range.
+	n := (hi-lo)/step + 1
+	start := rand.IntN(n)
+	for i := range n {
+		p := lo + (start+i)%n*step
+		if rtpConn, rtcpConn, err = listenPair(p); err == nil {
+			return rtpConn, rtcpConn, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("udp writer: no free port in range %d-%d: %w", cfg.PortMin, cfg.PortMax, err)
+}
@@ -0,0 +1,114 @@
+package mediadevices
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+// rtcpRR is a minimal RTCP receiver report without report blocks.
+var rtcpRR = []byte{0x80, 201, 0x00, 0x01, 0x12, 0x34, 0x56, 0x78}
+
+func TestUDPWriter_RTCPMux(t *testing.T) {
+	peer, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer peer.Close()
+
+	w, err := NewUDPWriterWithConfig(peer.LocalAddr().String(), UDPWriterConfig{RTCP: RTCPMux})
+	if err != nil {
+		t.Fatalf("NewUDPWriterWithConfig: %v", err)
+	}
+	defer w.Close()
+	if !w.LocalRTCPAddr().IP.Equal(w.LocalAddr().IP) || w.LocalRTCPAddr().Port != w.LocalAddr().Port {
+		t.Errorf("LocalRTCPAddr = %v, want %v", w.LocalRTCPAddr(), w.LocalAddr())
+	}
+
+	if err := w.WriteRTCP(rtcpRR); err != nil {
+		t.Fatalf("WriteRTCP: %v", err)
+	}
+	buf := make([]byte, 64)
+	peer.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := peer.ReadFromUDP(buf)
+	if err != nil || n != len(rtcpRR) {
+		t.Fatalf("peer received %d bytes, %v", n, err)
+	}
+
+	// An RTP packet on the shared socket is skipped by ReadRTCP.
+	peer.WriteToUDP([]byte{0x80, 96, 0, 1}, w.LocalAddr())
+	peer.WriteToUDP(rtcpRR, w.LocalAddr())
+	w.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err = w.ReadRTCP(buf)
+	if err != nil || buf[1] != 201 {
+		t.Fatalf("ReadRTCP = % x, %v", buf[:n], err)
+	}
+}
+
+func TestUDPWriter_RTCPPortPair(t *testing.T) {
+	w, err := NewUDPWriterWithConfig("127.0.0.1:5004", UDPWriterConfig{RTCP: RTCPPortPair, LocalAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatalf("NewUDPWriterWithConfig: %v", err)
+	}
+	defer w.Close()
+	rtp, rtcp := w.LocalAddr().Port, w.LocalRTCPAddr().Port
+	if rtp%2 != 0 || rtcp != rtp+1 {
+		t.Errorf("local ports %d/%d, want even/odd pair", rtp, rtcp)
+	}
+	if w.rtcpAddr.Port != 5005 {
+		t.Errorf("remote RTCP port = %d, want 5005", w.rtcpAddr.Port)
+	}
+}
+
+func TestUDPWriter_PortRange(t *testing.T) {
+	// Find a free pair to build a narrow range around.
+	probe, err := NewUDPWriterWithConfig("127.0.0.1:5004", UDPWriterConfig{RTCP: RTCPPortPair, LocalAddr: "127.0.0.1:0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lo := probe.LocalAddr().Port
+	probe.Close()
+
+	cfg := UDPWriterConfig{RTCP: RTCPPortPair, LocalAddr: "127.0.0.1:0", PortMin: lo, PortMax: lo + 1}
+	w, err := NewUDPWriterWithConfig("127.0.0.1:5004", cfg)
+	if err != nil {
+		t.Fatalf("NewUDPWriterWithConfig: %v", err)
+	}
+	defer w.Close()
+	if w.LocalAddr().Port != lo || w.LocalRTCPAddr().Port != lo+1 {
+		t.Errorf("ports %v/%v, want %d/%d", w.LocalAddr(), w.LocalRTCPAddr(), lo, lo+1)
+	}
+
+	// The only pair of the range is taken now.
+	if w2, err := NewUDPWriterWithConfig("127.0.0.1:5004", cfg); err == nil {
+		w2.Close()
+		t.Error("second writer bound a taken range")
+	}
+
+	for _, bad := range []UDPWriterConfig{
+		{PortMin: 6000, PortMax: 5000},
+		{RTCP: RTCPPortPair, PortMin: 6000, PortMax: 6000},
+		{PortMin: 6000, PortMax: 6001, LocalAddr: "127.0.0.1:6000"},
+		{RTCP: RTCPPortPair, LocalAddr: "127.0.0.1:6001"},
+		{RTCP: "bogus"},
+	} {
+		if w, err := NewUDPWriterWithConfig("127.0.0.1:5004", bad); err == nil {
+			w.Close()
+			t.Errorf("config %+v accepted", bad)
+		}
+	}
+}
+
+func TestUDPWriter_RTCPDisabled(t *testing.T) {
+	w, err := NewUDPWriter("127.0.0.1:5004", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+	if err := w.WriteRTCP(rtcpRR); err == nil {
+		t.Error("WriteRTCP succeeded without RTCP")
+	}
+	if w.LocalRTCPAddr() != nil {
+		t.Error("LocalRTCPAddr not nil without RTCP")
+	}
+}
@@ -0,0 +1,59 @@
+//go:build !windows
+
+package mediadevices
+
+import "golang.org/x/sys/unix"
+
+// setUDPSocketOptions applies o to the socket fd before it is bound.
+func setUDPSocketOptions(fd uintptr, o udpSocketOptions) error {
+	s := int(fd)
+	if o.reuseAddr {
+		if err := unix.SetsockoptInt(s, unix.SOL_SOCKET, unix.SO_REUSEADDR, 1); err != nil {
+			return err
+		}
+	}
+	if !o.multicast {
+		return nil
+	}
+
+	if o.ipv6 {
+		if o.ttl > 0 {
+			if err := unix.SetsockoptInt(s, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_HOPS, o.ttl); err != nil {
+				return err
+			}
+		}
+		if o.ifIndex > 0 {
+			if err := unix.SetsockoptInt(s, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_IF, o.ifIndex); err != nil {
+				return err
+			}
+		}
+		if o.noLoop {
+			return unix.SetsockoptInt(s, unix.IPPROTO_IPV6, unix.IPV6_MULTICAST_LOOP, 0)
+		}
+		return nil
+	}
+
+	if o.ttl > 0 {
+		if err := setsockoptSmallInt(s, unix.IPPROTO_IP, unix.IP_MULTICAST_TTL, o.ttl); err != nil {
+			return err
+		}
+	}
+	if o.ifAddr != [4]byte{} {
+		if err := unix.SetsockoptInet4Addr(s, unix.IPPROTO_IP, unix.IP_MULTICAST_IF, o.ifAddr); err != nil {
+			return err
+		}
+	}
+	if o.noLoop {
+		return setsockoptSmallInt(s, unix.IPPROTO_IP, unix.IP_MULTICAST_LOOP, 0)
+	}
+	return nil
+}
+
+// setsockoptSmallInt sets an IPv4 multicast option that Linux takes as an
+// int but the BSDs (including macOS) only accept as a single byte.
+func setsockoptSmallInt(s, level, opt, v int) error {
+	if err := unix.SetsockoptInt(s, level, opt, v); err == nil {
+		return nil
+	}
+	return unix.SetsockoptByte(s, level, opt, byte(v))
+}
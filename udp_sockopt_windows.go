@@ -0,0 +1,41 @@
+//go:build windows
+
+package mediadevices
+
+import "golang.org/x/sys/windows"
+
+// setUDPSocketOptions applies o to the socket fd before it is bound.
+func setUDPSocketOptions(fd uintptr, o udpSocketOptions) error {
+	s := windows.Handle(fd)
+	if o.reuseAddr {
+		if err := windows.SetsockoptInt(s, windows.SOL_SOCKET, windows.SO_REUSEADDR, 1); err != nil {
+			return err
+		}
+	}
+	if !o.multicast {
+		return nil
+	}
+
+	level, ttlOpt, ifOpt, loopOpt := windows.IPPROTO_IP, windows.IP_MULTICAST_TTL, windows.IP_MULTICAST_IF, windows.IP_MULTICAST_LOOP
+	if o.ipv6 {
+		level, ttlOpt, ifOpt, loopOpt = windows.IPPROTO_IPV6, windows.IPV6_MULTICAST_HOPS, windows.IPV6_MULTICAST_IF, windows.IPV6_MULTICAST_LOOP
+	}
+	if o.ttl > 0 {
+		if err := windows.SetsockoptInt(s, level, ttlOpt, o.ttl); err != nil {
+			return err
+		}
+	}
+	if o.ipv6 && o.ifIndex > 0 {
+		if err := windows.SetsockoptInt(s, level, ifOpt, o.ifIndex); err != nil {
+			return err
+		}
+	} else if !o.ipv6 && o.ifAddr != [4]byte{} {
+		if err := windows.SetsockoptInet4Addr(s, level, ifOpt, o.ifAddr); err != nil {
+			return err
+		}
+	}
+	if o.noLoop {
+		return windows.SetsockoptInt(s, level, loopOpt, 0)
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package mediadevices
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UploadSink 将完成的录制文件上传到设备之外的存储。
+// 实现需可被多个 goroutine 并发调用；失败的上传会由 RecordingUploader 重试，
+// 因此每次调用都应从头上传整个文件。
+type UploadSink interface {
+	// Upload 上传 path 处的文件，name 为目标对象名（文件名）。
+	Upload(ctx context.Context, path, name string) error
+}
+
+// UploadStatusError 表示上传目标返回了非成功的 HTTP 状态码。
+type UploadStatusError struct {
+	StatusCode int
+	Body       string // 响应体开头部分
+}
+
+func (e *UploadStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("upload: HTTP %d", e.StatusCode)
+	}
+	return fmt.Sprintf("upload: HTTP %d: %s", e.StatusCode, e.Body)
+}
+
+// Temporary 报告该错误是否值得重试（5xx、408 和 429）。
+func (e *UploadStatusError) Temporary() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// isRetryableUpload 判断上传错误是否可重试。未声明 Temporary 的错误（如网络错误）均重试。
+func isRetryableUpload(err error) bool {
+	var t interface{ Temporary() bool }
+	if errors.As(err, &t) {
+		return t.Temporary()
+	}
+	return !errors.Is(err, os.ErrNotExist)
+}
+
+// HTTPUploadSink 以 HTTP PUT 将录制上传到 URL + "/" + 文件名。
+type HTTPUploadSink struct {
+	// URL 上传基础地址，如 "https://example.com/recordings"。
+	URL string
+	// Header 附加到每个请求的头部（如 Authorization）。
+	Header http.Header
+	// Client 使用的 HTTP 客户端，nil 表示 http.DefaultClient。
+	Client *http.Client
+}
+
+// Upload 实现 UploadSink。
+func (s *HTTPUploadSink) Upload(ctx context.Context, path, name string) error {
+	f, size, err := openUpload(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, strings.TrimRight(s.URL, "/")+"/"+escapeObjectName(name), f)
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", recordingContentType(name))
+	for k, v := range s.Header {
+		req.Header[k] = v
+	}
+	return doUpload(s.Client, req)
+}
+
+// openUpload 打开待上传文件并返回其大小。
+func openUpload(path string) (*os.File, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("upload: %w", err)
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, fmt.Errorf("upload: %w", err)
+	}
+	return f, info.Size(), nil
+}
+
+// doUpload 发送上传请求，非 2xx 响应返回 *UploadStatusError。
+func doUpload(client *http.Client, req *http.Request) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode/100 != 2 {
+		return &UploadStatusError{StatusCode: resp.StatusCode, Body: strings.TrimSpace(string(body))}
+	}
+	return nil
+}
+
+// escapeObjectName 对对象名逐段进行 URL 转义，保留 "/"。
+func escapeObjectName(name string) string {
+	parts := strings.Split(name, "/")
+	for i, p := range parts {
+		parts[i] = uriEncode(p)
+	}
+	return strings.Join(parts, "/")
+}
+
+// uriEncode 按 RFC 3986 转义（仅保留非保留字符），与 S3 签名要求一致。
+func uriEncode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// recordingContentType 返回录制相关文件的 MIME 类型。
+func recordingContentType(name string) string {
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".mp4":
+		return "video/mp4"
+	case ".mkv":
+		return "video/x-matroska"
+	case ".ts":
+		return "video/mp2t"
+	case ".json":
+		return "application/json"
+	}
+	return "application/octet-stream"
+}
+
+// UploaderConfig 配置 RecordingUploader。
+type UploaderConfig struct {
+	// Sink 上传目标。
+	Sink UploadSink
+	// MaxAttempts 每个文件的最多尝试次数，默认 5。
+	MaxAttempts int
+	// Backoff 首次重试前的等待时间，之后每次翻倍（最长 1 分钟），默认 1 秒。
+	Backoff time.Duration
+	// Workers 并发上传数，默认 1。
+	Workers int
+	// DeleteAfterUpload 为 true 时，上传成功后删除本地文件。
+	DeleteAfterUpload bool
+	// OnComplete 在每个文件上传完成（成功或放弃）后调用（可选）。
+	OnComplete func(UploadResult)
+}
+
+// UploadResult 描述一个文件的上传结果。
+type UploadResult struct {
+	Path     string
+	Attempts int
+	Duration time.Duration
+	// Err 为最后一次尝试的错误，nil 表示上传成功。
+	Err error
+}
+
+// RecordingUploader 在后台将完成的录制上传到 UploadSink，失败时按指数退避重试。
+// 通过 MediaRecorderOptions.Uploader 关联到录制器后，每次录制结束时
+// 输出文件（及其索引）会自动加入上传队列。
+type RecordingUploader struct {
+	cfg UploaderConfig
+
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	mu     sync.Mutex
+	queue  []string
+	closed bool
+	wake   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewRecordingUploader 创建上传器并启动其工作 goroutine。
+func NewRecordingUploader(cfg UploaderConfig) (*RecordingUploader, error) {
+	if cfg.Sink == nil {
+		return nil, errors.New("recording uploader: sink is required")
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = 5
+	}
+	if cfg.Backoff <= 0 {
+		cfg.Backoff = time.Second
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	u := &RecordingUploader{
+		cfg:    cfg,
+		ctx:    ctx,
+		cancel: cancel,
+		wake:   make(chan struct{}, 1),
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		u.wg.Add(1)
+		go u.worker()
+	}
+	return u, nil
+}
+
+// Enqueue 将 path 加入上传队列。
+func (u *RecordingUploader) Enqueue(path string) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.closed {
+		return errors.New("recording uploader: closed")
+	}
+	u.queue = append(u.queue, path)
+	select {
+	case u.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Pending 返回尚未开始上传的文件数。
+func (u *RecordingUploader) Pending() int {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return len(u.queue)
+}
+
+// Close 停止接受新文件，并等待队列中的文件上传完成。
+// ctx 结束时中止进行中的上传并立即返回 ctx 的错误，未上传的文件保留在本地。
+func (u *RecordingUploader) Close(ctx context.Context) error {
+	u.mu.Lock()
+	if !u.closed {
+		u.closed = true
+		close(u.wake)
+	}
+	u.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		u.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		u.cancel()
+		return nil
+	case <-ctx.Done():
+		u.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// next 取出队首文件；队列为空且已关闭时返回 false。
+func (u *RecordingUploader) next() (string, bool) {
+	for {
+		u.mu.Lock()
+		if len(u.queue) > 0 {
+			path := u.queue[0]
+			u.queue = u.queue[1:]
+			u.mu.Unlock()
+			return path, true
+		}
+		closed := u.closed
+		u.mu.Unlock()
+		if closed {
+			return "", false
+		}
+		select {
+		case <-u.wake:
+		case <-u.ctx.Done():
+			return "", false
+		}
+	}
+}
+
+func (u *RecordingUploader) worker() {
+	defer u.wg.Done()
+	for {
+		path, ok := u.next()
+		if !ok {
+			return
+		}
+		if u.ctx.Err() != nil {
+			return
+		}
+		res := u.upload(path)
+		if res.Err == nil && u.cfg.DeleteAfterUpload {
+			if err := os.Remove(path); err != nil {
+				res.Err = fmt.Errorf("recording uploader: remove after upload: %w", err)
+			}
+		}
+		if u.cfg.OnComplete != nil {
+			u.cfg.OnComplete(res)
+		}
+	}
+}
+
+// upload 上传一个文件，按指数退避重试可重试的错误。
+func (u *RecordingUploader) upload(path string) UploadResult {
+	start := time.Now()
+	res := UploadResult{Path: path}
+	backoff := u.cfg.Backoff
+	for res.Attempts < u.cfg.MaxAttempts {
+		res.Attempts++
+		res.Err = u.cfg.Sink.Upload(u.ctx, path, filepath.Base(path))
+		if res.Err == nil || !isRetryableUpload(res.Err) || res.Attempts == u.cfg.MaxAttempts {
+			break
+		}
+		select {
+		case <-time.After(backoff):
+		case <-u.ctx.Done():
+			res.Err = u.ctx.Err()
+			res.Duration = time.Since(start)
+			return res
+		}
+		if backoff *= 2; backoff > time.Minute {
+			backoff = time.Minute
+		}
+	}
+	res.Duration = time.Since(start)
+	return res
+}
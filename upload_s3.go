@@ -0,0 +1,169 @@
+package mediadevices
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3UploadSink 以单次 PUT Object 请求将录制上传到 Amazon S3 或兼容 S3 的存储
+// （MinIO、Ceph 等），使用 AWS Signature Version 4 签名。
+// 单个对象最大 5 GiB，更大的录制应配合分段录制使用。
+type S3UploadSink struct {
+	// Bucket 存储桶名。
+	Bucket string
+	// Region 区域，如 "us-east-1"。
+	Region string
+	// Prefix 对象键前缀，如 "cam1/"。
+	Prefix string
+	// Endpoint 兼容 S3 的服务地址（如 "http://minio:9000"），使用路径风格访问。
+	// 为空时使用 AWS 的虚拟主机风格地址。
+	Endpoint string
+	// AccessKeyID、SecretAccessKey 和可选的 SessionToken 为访问凭证。
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	// Client 使用的 HTTP 客户端，nil 表示 http.DefaultClient。
+	Client *http.Client
+
+	now func() time.Time // 测试用
+}
+
+// Upload 实现 UploadSink。
+func (s *S3UploadSink) Upload(ctx context.Context, path, name string) error {
+	if s.Bucket == "" || s.Region == "" {
+		return errors.New("upload: S3 bucket and region are required")
+	}
+	f, size, err := openUpload(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// 签名需要负载的 SHA-256，先完整读一遍文件
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	payloadHash := hex.EncodeToString(h.Sum(nil))
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, s.objectURL(s.Prefix+name), f)
+	if err != nil {
+		return fmt.Errorf("upload: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", recordingContentType(name))
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	now := time.Now
+	if s.now != nil {
+		now = s.now
+	}
+	signV4(req, payloadHash, s.AccessKeyID, s.SecretAccessKey, s.Region, "s3", now())
+	return doUpload(s.Client, req)
+}
+
+// objectURL 返回对象 key 的 URL。
+func (s *S3UploadSink) objectURL(key string) string {
+	if s.Endpoint != "" {
+		return strings.TrimRight(s.Endpoint, "/") + "/" + uriEncode(s.Bucket) + "/" + escapeObjectName(key)
+	}
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", s.Bucket, s.Region, escapeObjectName(key))
+}
+
+// signV4 为请求添加 AWS Signature Version 4 的 Authorization 头。
+// 签名覆盖 Host、Content-Type、Range 及所有 X-Amz-* 头。
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region, service string, t time.Time) {
+	t = t.UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || lk == "range" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonHeaders strings.Builder
+	for _, k := range names {
+		canonHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	canonical := strings.Join([]string{
+		req.Method,
+		canonicalURIPath(req.URL),
+		canonicalQuery(req.URL.Query()),
+		canonHeaders.String(),
+		signed,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	sum := sha256.Sum256([]byte(canonical))
+	toSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(sum[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	sig := hex.EncodeToString(hmacSHA256(key, toSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", accessKey, scope, signed, sig))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// canonicalURIPath 返回 S3 规范请求中的 URI 路径（各段仅转义一次）。
+func canonicalURIPath(u *url.URL) string {
+	p := u.EscapedPath()
+	if p == "" {
+		return "/"
+	}
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = escapeObjectName(unescaped)
+	}
+	return p
+}
+
+func canonicalQuery(q url.Values) string {
+	keys := make([]string, 0, len(q))
+	for k := range q {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		vs := append([]string{}, q[k]...)
+		sort.Strings(vs)
+		for _, v := range vs {
+			parts = append(parts, uriEncode(k)+"="+uriEncode(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
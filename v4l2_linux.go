@@ -0,0 +1,69 @@
+//go:build linux
+
+package mediadevices
+
+import (
+	"os"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// v4l2Capability mirrors struct v4l2_capability from <linux/videodev2.h>.
+type v4l2Capability struct {
+	Driver       [16]byte
+	Card         [32]byte
+	BusInfo      [32]byte
+	Version      uint32
+	Capabilities uint32
+	DeviceCaps   uint32
+	Reserved     [3]uint32
+}
+
+// vidiocQueryCap is VIDIOC_QUERYCAP, _IOR('V', 0, struct v4l2_capability).
+const vidiocQueryCap = 0x80685600
+
+// Capability flags of struct v4l2_capability.
+const (
+	v4l2CapVideoCapture       = 0x00000001
+	v4l2CapVideoCaptureMplane = 0x00001000
+	v4l2CapDeviceCaps         = 0x80000000
+)
+
+// queryV4L2Capability issues VIDIOC_QUERYCAP on an open V4L2 device node.
+func queryV4L2Capability(f *os.File) (v4l2Capability, error) {
+	var c v4l2Capability
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return c, err
+	}
+	var errno unix.Errno
+	err = conn.Control(func(fd uintptr) {
+		_, _, errno = unix.Syscall(unix.SYS_IOCTL, fd, vidiocQueryCap, uintptr(unsafe.Pointer(&c)))
+	})
+	if err != nil {
+		return c, err
+	}
+	if errno != 0 {
+		return c, errno
+	}
+	return c, nil
+}
+
+// card returns the name of the device, e.g. "HD Pro Webcam C920".
+func (c *v4l2Capability) card() string { return unix.ByteSliceToString(c.Card[:]) }
+
+// busInfo returns the location of the device, e.g. "usb-0000:00:14.0-1".
+func (c *v4l2Capability) busInfo() string { return unix.ByteSliceToString(c.BusInfo[:]) }
+
+// canCapture reports whether the node captures video frames. A UVC camera
+// usually has a second node that only delivers metadata, and codecs and
+// output devices have nodes that take frames instead of delivering them.
+func (c *v4l2Capability) canCapture() bool {
+	caps := c.Capabilities
+	if caps&v4l2CapDeviceCaps != 0 {
+		// Capabilities covers the whole physical device, DeviceCaps this node.
+		caps = c.DeviceCaps
+	}
+	return caps&(v4l2CapVideoCapture|v4l2CapVideoCaptureMplane) != 0
+}
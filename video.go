@@ -1,9 +1,12 @@
 package mediadevices
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"image"
 	"io"
+	"strings"
 	"time"
 )
 
@@ -17,32 +20,122 @@ const (
 // VideoReader reads raw video frames from an FFmpeg subprocess.
 // Each call to Read() returns one YUV420p frame as an *image.YCbCr.
 type VideoReader struct {
-	proc       *ffmpegProcess
+	proc       *captureSource
 	buf        []byte
 	width      int
 	height     int
+	frameRate  float64
 	frameSize  int
 	firstFrame bool
+
+	// wallclock enables timestamp, the capture time of the last frame read.
+	wallclock bool
+	timestamp time.Time
+}
+
+// VideoConfig configures a VideoReader created by NewVideoReader.
+type VideoConfig struct {
+	// Device is the device to capture, as returned by EnumerateDevices or
+	// VideoInputDevices. If it is the zero value, DeviceID is used.
+	Device MediaDeviceInfo
+	// DeviceID selects the device by its MediaDeviceInfo.DeviceID. If both
+	// Device and DeviceID are empty, the default video input is used.
+	DeviceID string
+	// Width and Height are the frame size in pixels. Defaults to 640x480.
+	Width  int
+	Height int
+	// FrameRate is the capture frame rate. Defaults to 30.
+	FrameRate float64
+	// ArgsHook, if set, can inspect and modify the FFmpeg arguments of
+	// this reader. It runs after Config.ArgsHook.
+	ArgsHook func(args []string) []string
+}
+
+// NewVideoReader opens a video input device and returns a reader of its
+// raw frames. It is the low-level counterpart of GetUserMedia for code that
+// does not need tracks and streams. The caller must Close the reader.
+func NewVideoReader(cfg VideoConfig) (*VideoReader, error) {
+	return defaultMediaDevices.NewVideoReader(cfg)
+}
+
+// NewVideoReader is like the package-level NewVideoReader, but selects the
+// device among those of m and captures with the configuration of m.
+func (m *MediaDevices) NewVideoReader(cfg VideoConfig) (*VideoReader, error) {
+	name, err := m.resolveCaptureDevice(context.Background(), MediaDeviceKindVideoInput, cfg.Device, cfg.DeviceID)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	width, height, frameRate := cfg.Width, cfg.Height, cfg.FrameRate
+	if width == 0 && height == 0 {
+		width, height = 640, 480
+	}
+	if frameRate <= 0 {
+		frameRate = 30
+	}
+	return m.newVideoReaderInternal(name, width, height, frameRate, cfg.ArgsHook)
 }
 
 // newVideoReaderInternal starts an FFmpeg subprocess to capture video from the given device.
-// This is an internal function used by MediaStreamTrack.
-func newVideoReaderInternal(deviceID string, width, height int, frameRate float64) (*VideoReader, error) {
+// This is an internal function used by MediaStreamTrack. hook may be nil.
+func (m *MediaDevices) newVideoReaderInternal(deviceID string, width, height int, frameRate float64, hook func([]string) []string) (*VideoReader, error) {
 	if width <= 0 || height <= 0 {
 		return nil, fmt.Errorf("ffmpeg: video width and height must be positive (got %dx%d)", width, height)
 	}
+	if sourceID, ok := strings.CutPrefix(deviceID, displayDevicePrefix); ok {
+		return m.newDisplayVideoReader(sourceID, width, height, frameRate, hook)
+	}
+
+	if !m.isPrewarmed(MediaDeviceKindVideoInput, deviceID) {
+		if err := checkDeviceAccess(MediaDeviceKindVideoInput, deviceID); err != nil {
+			return nil, err
+		}
+	}
+
+	args, err := m.videoCaptureArgs(deviceID, width, height, frameRate)
+	if err != nil {
+		return nil, err
+	}
+	r, err := m.newVideoReaderFromArgs(deviceID, args, width, height, hook)
+	if err != nil {
+		return nil, err
+	}
+	r.frameRate = frameRate
+	return r, nil
+}
+
+// videoCaptureArgs returns the FFmpeg arguments of a raw capture of the
+// device at the given size and rate, with the privacy masks of the device
+// applied.
+func (m *MediaDevices) videoCaptureArgs(deviceID string, width, height int, frameRate float64) ([]string, error) {
+	gcfg := m.Config()
+	profile := gcfg.LatencyProfile
+	if _, err := profile.settings(); err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
 
 	params := VideoCaptureParams{
-		DeviceID:  deviceID,
-		Width:     width,
-		Height:    height,
-		FrameRate: frameRate,
+		DeviceID:               deviceID,
+		Width:                  width,
+		Height:                 height,
+		FrameRate:              frameRate,
+		Profile:                profile,
+		UseWallclockTimestamps: gcfg.UseWallclockTimestamps,
 	}
 
-	args := buildVideoCaptureArgs(params)
-	gcfg := GetConfig()
+	args := m.overrideInput(context.Background(), MediaDeviceKindVideoInput, deviceID, "", buildVideoCaptureArgs(params))
+	args, err := m.maskVideoArgs(context.Background(), deviceID, "", args)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg: %w", err)
+	}
+	return args, nil
+}
 
-	proc, err := startProcess(gcfg.FFmpegPath, args)
+// newVideoReaderFromArgs starts an FFmpeg subprocess with prebuilt arguments
+// whose stdout is raw YUV420p video of the given size. The arguments are
+// expected to follow Config.UseWallclockTimestamps. hook may be nil.
+func (m *MediaDevices) newVideoReaderFromArgs(deviceID string, args []string, width, height int, hook func([]string) []string) (*VideoReader, error) {
+	gcfg := m.Config()
+	proc, err := m.openCapture(gcfg, MediaDeviceKindVideoInput, deviceID, args, hook)
 	if err != nil {
 		return nil, fmt.Errorf("ffmpeg: start video capture: %w", err)
 	}
@@ -56,14 +149,35 @@ func newVideoReaderInternal(deviceID string, width, height int, frameRate float6
 		height:     height,
 		frameSize:  frameSize,
 		firstFrame: true,
+		wallclock:  gcfg.UseWallclockTimestamps,
 	}, nil
 }
 
 // Read reads one video frame from the capture.
 // Returns an *image.YCbCr with YUV420p data.
-// Returns io.EOF when the stream ends.
+// Returns io.EOF when the stream ends after Close or when FFmpeg finishes
+// on its own, and a *CaptureError when FFmpeg fails mid-stream.
 // For the first frame, it will retry with a timeout while FFmpeg initializes.
+// If the stall watchdog restarts the capture, the partial frame is dropped
+// and the read continues on the new process.
 func (r *VideoReader) Read() (image.Image, error) {
+	for {
+		img, err := r.readFrame()
+		if errors.Is(err, errCaptureRestarted) {
+			r.firstFrame = true
+			continue
+		}
+		if err == nil && r.wallclock {
+			// Raw video carries no timestamps; with passthrough output the
+			// frame left FFmpeg as soon as it was captured.
+			r.timestamp = time.Now()
+		}
+		return img, err
+	}
+}
+
+// readFrame performs a single frame read attempt.
+func (r *VideoReader) readFrame() (image.Image, error) {
 	var lastErr error
 
 	// For the first frame, use retry logic to wait for FFmpeg to initialize
@@ -80,24 +194,30 @@ func (r *VideoReader) Read() (image.Image, error) {
 				return img, nil
 			}
 			lastErr = err
+			if errors.Is(err, errCaptureRestarted) {
+				return nil, err
+			}
 			if err != io.EOF && err != io.ErrUnexpectedEOF {
 				// Real error, not just "no data yet"
-				return nil, fmt.Errorf("ffmpeg: read video frame: %w\nstderr: %s", err, r.proc.LastStderr())
+				return nil, r.proc.captureError(fmt.Errorf("ffmpeg: read video frame: %w", err))
 			}
 			// FFmpeg hasn't produced a frame yet, wait and retry
 			time.Sleep(firstFrameRetryInterval)
 		}
 		// Timeout reached
-		return nil, fmt.Errorf("ffmpeg: timeout waiting for first frame: %w\nstderr: %s", lastErr, r.proc.LastStderr())
+		return nil, r.proc.captureError(fmt.Errorf("ffmpeg: timeout waiting for first frame: %w", lastErr))
 	}
 
 	// Normal read for subsequent frames
 	_, err := io.ReadFull(r.proc, r.buf)
 	if err != nil {
-		if err == io.EOF || err == io.ErrUnexpectedEOF {
-			return nil, io.EOF
+		if errors.Is(err, errCaptureRestarted) {
+			return nil, err
+		}
+		if endOfOutput(err) {
+			return nil, r.proc.streamEnd("read video frame")
 		}
-		return nil, fmt.Errorf("ffmpeg: read video frame: %w\nstderr: %s", err, r.proc.LastStderr())
+		return nil, r.proc.captureError(fmt.Errorf("ffmpeg: read video frame: %w", err))
 	}
 
 	img, err := parseYUV420pFrame(r.buf, r.width, r.height)
@@ -124,3 +244,40 @@ func (r *VideoReader) Width() int {
 func (r *VideoReader) Height() int {
 	return r.height
 }
+
+// FrameRate returns the requested capture frame rate, or 0 if unknown.
+func (r *VideoReader) FrameRate() float64 {
+	return r.frameRate
+}
+
+// Timestamp returns the wall-clock capture time of the frame last returned
+// by Read, or the zero time if Config.UseWallclockTimestamps was disabled
+// when the reader was created.
+func (r *VideoReader) Timestamp() time.Time {
+	return r.timestamp
+}
+
+// Restarts returns how many times the stall watchdog restarted the capture.
+func (r *VideoReader) Restarts() int {
+	return r.proc.Restarts()
+}
+
+// StderrLines returns up to the last n lines of FFmpeg's stderr, oldest
+// first, or all lines kept if n <= 0. How much stderr is kept is set by
+// Config.StderrBufferSize. After a watchdog restart, only lines of the new
+// process are returned.
+func (r *VideoReader) StderrLines(n int) []string {
+	return r.proc.StderrLines(n)
+}
+
+// OnStderrLine calls fn with every line FFmpeg writes to stderr from now
+// on, until cancel is called. Subscriptions carry over watchdog restarts.
+// fn runs on the goroutine draining stderr and must not block.
+func (r *VideoReader) OnStderrLine(fn func(line string)) (cancel func()) {
+	return r.proc.OnStderrLine(fn)
+}
+
+// warnings returns the stderr warning count of the capture, for health scoring.
+func (r *VideoReader) warnings() int64 {
+	return r.proc.Warnings()
+}
@@ -0,0 +1,127 @@
+package mediadevices
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+// virtualDevicePrefix 是虚拟设备的 FFmpeg 设备名（MediaDeviceInfo.DeviceName）前缀，
+// 其后为注册的文件路径或 URL，如 "virtual:rtsp://192.168.1.10/stream1"。
+const virtualDevicePrefix = "virtual:"
+
+// virtualDevice 是 RegisterVirtualDevice 注册的一个输入。
+type virtualDevice struct {
+	name string
+	url  string
+}
+
+// RegisterVirtualDevice 将媒体文件（如 MP4）、RTSP URL 或 HTTP 流注册为名为 name 的虚拟设备，
+// 使 GetUserMedia、NewVideoReader、NewAudioReader 和 H264VideoReader 能像使用摄像头一样
+// 使用非摄像头输入，用于测试、回放和接入 IP 摄像头。
+//
+// 虚拟设备出现在 EnumerateDevices 的末尾：一个 videoinput 和一个 audioinput（文件或流中的音频），
+// 两者 GroupID 相同，因此可以用 AudioInputForVideo 找到音频。返回的是视频输入设备。
+// 只有在没有其他设备时虚拟设备才会被选为默认设备。
+//
+// url 没有 "scheme://" 时视为本地文件路径，文件必须存在；本地文件按原始速度（-re）循环播放，
+// 网络流按其自身的节奏读取，RTSP 使用 TCP 传输。帧缩放到请求的尺寸，帧率为源的帧率。
+// 再次注册同一 name 会替换其 URL。
+func RegisterVirtualDevice(name, url string) (MediaDeviceInfo, error) {
+	return defaultMediaDevices.RegisterVirtualDevice(name, url)
+}
+
+// RegisterVirtualDevice 与包级函数 RegisterVirtualDevice 相同，但设备只出现在 m 的设备列表中。
+func (m *MediaDevices) RegisterVirtualDevice(name, url string) (MediaDeviceInfo, error) {
+	if name == "" || url == "" {
+		return MediaDeviceInfo{}, errors.New("virtual device: name and url are required")
+	}
+	if isVirtualFile(url) {
+		if _, err := os.Stat(strings.TrimPrefix(url, "file:")); err != nil {
+			return MediaDeviceInfo{}, fmt.Errorf("virtual device %s: %w", name, err)
+		}
+	}
+
+	m.virtualMu.Lock()
+	defer m.virtualMu.Unlock()
+	i := slices.IndexFunc(m.virtualDevices, func(d virtualDevice) bool { return d.name == name })
+	if i >= 0 {
+		m.virtualDevices[i].url = url
+	} else {
+		m.virtualDevices = append(m.virtualDevices, virtualDevice{name: name, url: url})
+	}
+	return virtualDeviceInfos(virtualDevice{name: name, url: url})[0], nil
+}
+
+// UnregisterVirtualDevice 移除名为 name 的虚拟设备，返回是否存在。
+// 已打开的读取器和轨道不受影响。
+func UnregisterVirtualDevice(name string) bool {
+	return defaultMediaDevices.UnregisterVirtualDevice(name)
+}
+
+// UnregisterVirtualDevice 与包级函数 UnregisterVirtualDevice 相同，但移除 m 的虚拟设备。
+func (m *MediaDevices) UnregisterVirtualDevice(name string) bool {
+	m.virtualMu.Lock()
+	defer m.virtualMu.Unlock()
+	n := len(m.virtualDevices)
+	m.virtualDevices = slices.DeleteFunc(m.virtualDevices, func(d virtualDevice) bool { return d.name == name })
+	return len(m.virtualDevices) != n
+}
+
+// isVirtualDevice 判断 FFmpeg 设备名是否为虚拟设备。
+func isVirtualDevice(name string) bool {
+	return strings.HasPrefix(name, virtualDevicePrefix)
+}
+
+// isVirtualFile 判断虚拟设备的 url 是否为本地文件：没有 "scheme://" 或以 "file:" 开头。
+func isVirtualFile(url string) bool {
+	return strings.HasPrefix(url, "file:") || !strings.Contains(url, "://")
+}
+
+// virtualDeviceInfos 返回虚拟设备的视频和音频输入设备。
+func virtualDeviceInfos(d virtualDevice) []MediaDeviceInfo {
+	var ids deviceIDs
+	var devices []MediaDeviceInfo
+	for _, kind := range []MediaDeviceKind{MediaDeviceKindVideoInput, MediaDeviceKindAudioInput} {
+		devices = append(devices, MediaDeviceInfo{
+			DeviceID:   ids.id(virtualDevicePrefix+d.name, kind),
+			DeviceName: virtualDevicePrefix + d.url,
+			GroupID:    virtualDevicePrefix + d.name,
+			Kind:       kind,
+			Label:      d.name,
+		})
+	}
+	return devices
+}
+
+// withVirtualDevices 在设备列表末尾追加 m 已注册的虚拟设备，不修改 devices（可能是缓存）的底层数组。
+func (m *MediaDevices) withVirtualDevices(devices []MediaDeviceInfo) []MediaDeviceInfo {
+	m.virtualMu.Lock()
+	defer m.virtualMu.Unlock()
+	if len(m.virtualDevices) == 0 {
+		return devices
+	}
+	devices = slices.Clip(devices)
+	for _, d := range m.virtualDevices {
+		devices = append(devices, virtualDeviceInfos(d)...)
+	}
+	return devices
+}
+
+// buildVirtualInputArgs 构建虚拟设备 name（"virtual:<url>"）的 FFmpeg 输入参数。
+func buildVirtualInputArgs(name string, profile LatencyProfile, wallclock bool) []string {
+	url := strings.TrimPrefix(name, virtualDevicePrefix)
+	var args []string
+	switch {
+	case isVirtualFile(url):
+		// 按原始速度循环播放，像摄像头一样持续输出
+		args = append(args, "-re", "-stream_loop", "-1")
+	case strings.HasPrefix(url, "rtsp://") || strings.HasPrefix(url, "rtsps://"):
+		args = append(args, "-rtsp_transport", "tcp")
+	}
+	args = append(args, profileInputArgs(profile)...)
+	args = append(args, wallclockInputArgs(wallclock)...)
+	return append(args, "-i", url)
+}
@@ -0,0 +1,128 @@
+package mediadevices
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegisterVirtualDevice(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	cfg := orig
+	cfg.DiscoverDevices = func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{{DeviceID: "cam-1", DeviceName: "/dev/video0", Kind: MediaDeviceKindVideoInput}}, nil
+	}
+	SetConfig(cfg)
+
+	file := filepath.Join(t.TempDir(), "clip.mp4")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RegisterVirtualDevice("missing", filepath.Join(t.TempDir(), "none.mp4")); err == nil {
+		t.Error("missing file accepted")
+	}
+	cam, err := RegisterVirtualDevice("lobby", "rtsp://192.0.2.1/stream1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterVirtualDevice("lobby")
+	if _, err := RegisterVirtualDevice("clip", file); err != nil {
+		t.Fatal(err)
+	}
+	defer UnregisterVirtualDevice("clip")
+	if cam.Kind != MediaDeviceKindVideoInput || cam.DeviceName != "virtual:rtsp://192.0.2.1/stream1" || cam.Label != "lobby" {
+		t.Errorf("device = %+v", cam)
+	}
+
+	devices, err := EnumerateDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 5 || devices[0].DeviceID != "cam-1" {
+		t.Fatalf("devices = %+v, want the camera followed by two per virtual device", devices)
+	}
+	if d, err := defaultMediaDevices.defaultDevice(context.Background(), MediaDeviceKindVideoInput); err != nil || d.DeviceID != "cam-1" {
+		t.Errorf("default = %+v, %v; want the camera", d, err)
+	}
+	mic, err := AudioInputForVideo(cam)
+	if err != nil || mic.DeviceName != cam.DeviceName {
+		t.Errorf("AudioInputForVideo = %+v, %v", mic, err)
+	}
+	name, err := defaultMediaDevices.resolveCaptureDevice(context.Background(), MediaDeviceKindVideoInput, MediaDeviceInfo{}, cam.DeviceID)
+	if err != nil || name != cam.DeviceName {
+		t.Errorf("resolveCaptureDevice = %q, %v", name, err)
+	}
+
+	if !UnregisterVirtualDevice("lobby") || UnregisterVirtualDevice("lobby") {
+		t.Error("UnregisterVirtualDevice did not report the removal once")
+	}
+	if devices, _ := EnumerateDevices(); len(devices) != 3 {
+		t.Errorf("after unregistering: %+v", devices)
+	}
+}
+
+func TestMediaDevices_VirtualDevicesAndConsentPerInstance(t *testing.T) {
+	discover := func(context.Context) ([]MediaDeviceInfo, error) {
+		return []MediaDeviceInfo{{DeviceID: "cam-1", DeviceName: "/dev/video0", Label: "Camera", Kind: MediaDeviceKindVideoInput}}, nil
+	}
+	a := NewMediaDevices(Config{DiscoverDevices: discover, RedactLabels: true})
+	b := NewMediaDevices(Config{DiscoverDevices: discover, RedactLabels: true})
+
+	cam, err := a.RegisterVirtualDevice("lobby", "rtsp://192.0.2.1/stream1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devices, _ := a.VideoInputDevices(); len(devices) != 2 {
+		t.Errorf("registering instance has video inputs %+v, want the camera and the virtual device", devices)
+	}
+	if devices, _ := b.VideoInputDevices(); len(devices) != 1 {
+		t.Errorf("other instance has video inputs %+v, want only the camera", devices)
+	}
+	if _, err := b.AudioInputForVideo(cam); err == nil {
+		t.Error("other instance found the audio of a virtual device it does not have")
+	}
+
+	a.labelConsent.Store(true)
+	if devices, _ := a.VideoInputDevices(); devices[0].Label != "Camera" {
+		t.Errorf("instance with consent: Label = %q", devices[0].Label)
+	}
+	if devices, _ := b.VideoInputDevices(); devices[0].Label != "" {
+		t.Errorf("instance without consent: Label = %q", devices[0].Label)
+	}
+	a.ResetLabelConsent()
+	if devices, _ := a.VideoInputDevices(); devices[0].Label != "" {
+		t.Errorf("after ResetLabelConsent: Label = %q", devices[0].Label)
+	}
+	if !a.UnregisterVirtualDevice("lobby") {
+		t.Error("UnregisterVirtualDevice did not find the device")
+	}
+}
+
+func TestBuildVirtualInputArgs(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		want []string
+	}{
+		{"virtual:/media/clip.mp4", []string{"-re", "-stream_loop", "-1", "-i", "/media/clip.mp4"}},
+		{"virtual:file:C:\\clip.mp4", []string{"-re", "-stream_loop", "-1", "-i", "file:C:\\clip.mp4"}},
+		{"virtual:rtsp://192.0.2.1/stream1", []string{"-rtsp_transport", "tcp", "-i", "rtsp://192.0.2.1/stream1"}},
+		{"virtual:http://192.0.2.1/live.ts", []string{"-i", "http://192.0.2.1/live.ts"}},
+	} {
+		if got := buildVirtualInputArgs(tc.name, "", false); !slices.Equal(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+
+	args := strings.Join(buildVideoCaptureArgs(VideoCaptureParams{DeviceID: "virtual:/media/clip.mp4", Width: 640, Height: 360, FrameRate: 30}), " ")
+	if !strings.HasPrefix(args, "-y -re -stream_loop -1 -i /media/clip.mp4 ") || !strings.Contains(args, "-video_size 640x360 pipe:1") {
+		t.Errorf("video capture args: %s", args)
+	}
+	args = strings.Join(buildH264Args(H264ReaderConfig{DeviceName: "virtual:rtsp://192.0.2.1/stream1"}), " ")
+	if !strings.HasPrefix(args, "-rtsp_transport tcp -i rtsp://192.0.2.1/stream1 -c:v libx264") {
+		t.Errorf("H.264 args: %s", args)
+	}
+}
@@ -0,0 +1,324 @@
+package mediadevices
+
+import (
+	"errors"
+	"io"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// errCaptureRestarted is returned by captureSource.Read when the watchdog
+// replaced the FFmpeg process while a read was in flight. Any partially read
+// frame or chunk must be discarded and the read restarted.
+var errCaptureRestarted = errors.New("ffmpeg: capture restarted")
+
+// StallEvent describes a capture stall detected by the watchdog.
+type StallEvent struct {
+	// Kind is the kind of the stalled capture (video or audio input).
+	Kind MediaDeviceKind
+	// DeviceID is the FFmpeg device name the capture was opened with.
+	DeviceID string
+	// Idle is how long the process had been silent when the stall was detected.
+	Idle time.Duration
+	// Restarts is the total number of restarts of this capture, including this one.
+	Restarts int
+	// Stderr is the stderr tail of the stalled process.
+	Stderr string
+	// Err is non-nil if the replacement process could not be started.
+	// The stalled process has already been stopped, so the capture ends.
+	Err error
+}
+
+// captureSource owns the FFmpeg process behind a raw capture reader.
+// When a stall timeout is configured, a watchdog goroutine restarts the
+// process if it stays alive but stops producing data.
+type captureSource struct {
+	cfg      Config
+	args     []string
+	kind     MediaDeviceKind
+	deviceID string
+	stderr   *stderrFeed // stderr lines of every process, see cfg.stderrFeed
+
+	mu        sync.Mutex
+	proc      *ffmpegProcess
+	reopening chan struct{} // closed once a reopen in progress has started the new process
+	restarts  int
+	closed    bool
+	reopenMu  sync.Mutex // serializes reopen
+
+	lastData atomic.Int64 // unix nanoseconds of the last successful read or the start of a read
+	reading  atomic.Int32 // Read calls in progress
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// startCapture launches an FFmpeg capture process using the config gcfg,
+// arming the stall watchdog if Config.StallTimeout is set. hook, if not nil,
+// runs after Config.ArgsHook on every start of the process.
+func startCapture(gcfg Config, kind MediaDeviceKind, deviceID string, args []string, hook func([]string) []string) (*captureSource, error) {
+	gcfg.ArgsHook = chainArgsHooks(gcfg.ArgsHook, hook)
+	gcfg.stderrFeed = &stderrFeed{}
+
+	proc, err := startProcess(gcfg, args)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &captureSource{
+		cfg:      gcfg,
+		args:     args,
+		kind:     kind,
+		deviceID: deviceID,
+		stderr:   gcfg.stderrFeed,
+		proc:     proc,
+		stop:     make(chan struct{}),
+	}
+	s.lastData.Store(time.Now().UnixNano())
+
+	if gcfg.StallTimeout > 0 {
+		go s.watch()
+	}
+	return s, nil
+}
+
+// current returns the process currently backing the source.
+func (s *captureSource) current() *ffmpegProcess {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.proc
+}
+
+// Read reads from the current FFmpeg process. It returns errCaptureRestarted
+// if the process was replaced by the watchdog during the read.
+func (s *captureSource) Read(buf []byte) (int, error) {
+	// A stall is only measured while the consumer waits for data, so that
+	// a slow or paused consumer is not mistaken for a stalled device.
+	// lastData is stored first so that the watchdog never sees the read
+	// with an old timestamp.
+	s.lastData.Store(time.Now().UnixNano())
+	s.reading.Add(1)
+	defer s.reading.Add(-1)
+
+	p := s.current()
+	n, err := p.Read(buf)
+	if n > 0 {
+		s.lastData.Store(time.Now().UnixNano())
+	}
+	if err != nil && s.replaced(p) {
+		return n, errCaptureRestarted
+	}
+	return n, err
+}
+
+// replaced reports whether p is no longer the current process. A read that
+// failed because a reopen stopped p waits for the replacement to start.
+func (s *captureSource) replaced(p *ffmpegProcess) bool {
+	s.mu.Lock()
+	wait := s.reopening
+	s.mu.Unlock()
+	if wait != nil {
+		<-wait
+	}
+	return s.current() != p
+}
+
+// streamEnd returns the error for the end of the capture, which what was
+// reading: io.EOF after Stop, and otherwise that of the current process,
+// whose exit is unexpected unless FFmpeg finished on its own.
+func (s *captureSource) streamEnd(what string) error {
+	s.mu.Lock()
+	closed, p := s.closed, s.proc
+	s.mu.Unlock()
+	if closed {
+		return io.EOF
+	}
+	err := p.streamEnd(what)
+	if ce, ok := err.(*CaptureError); ok {
+		describeDeviceHolder(ce, s.kind, s.deviceID)
+	}
+	return err
+}
+
+// captureError wraps err with a classification of the stderr tail of the
+// current process; a busy device is described by the process holding it.
+func (s *captureSource) captureError(err error) *CaptureError {
+	return describeDeviceHolder(newCaptureError(err, s.LastStderr()), s.kind, s.deviceID)
+}
+
+// LastStderr returns the stderr tail of the current FFmpeg process.
+func (s *captureSource) LastStderr() string {
+	return s.current().LastStderr()
+}
+
+// StderrLines returns the last n stderr lines of the current FFmpeg process.
+func (s *captureSource) StderrLines(n int) []string {
+	return s.current().StderrLines(n)
+}
+
+// OnStderrLine calls fn with every stderr line of the capture, including
+// those of processes started by later restarts.
+func (s *captureSource) OnStderrLine(fn func(line string)) (cancel func()) {
+	return s.stderr.subscribe(fn)
+}
+
+// Warnings returns the stderr warning count of the current FFmpeg process.
+// It starts over from 0 when the watchdog restarts the capture.
+func (s *captureSource) Warnings() int64 {
+	return s.current().Warnings()
+}
+
+// Restarts returns how many times the watchdog restarted the capture.
+func (s *captureSource) Restarts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.restarts
+}
+
+// Stop stops the watchdog and terminates the current FFmpeg process.
+func (s *captureSource) Stop() error {
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	s.closed = true
+	p, wait := s.proc, s.reopening
+	s.mu.Unlock()
+
+	if wait != nil {
+		// The reopen in progress stops the old process and the new one.
+		<-wait
+		return nil
+	}
+	return p.Stop()
+}
+
+// reopenWith replaces the current process with one started from args,
+// which are also used by later watchdog restarts. If that fails, the
+// capture is restarted with the previous arguments, since the old process
+// has already been stopped.
+func (s *captureSource) reopenWith(args []string) error {
+	s.mu.Lock()
+	prev := s.args
+	s.args = args
+	s.mu.Unlock()
+	s.lastData.Store(time.Now().UnixNano())
+	if err := s.reopen(); err != nil {
+		s.mu.Lock()
+		s.args = prev
+		s.mu.Unlock()
+		s.reopen()
+		return err
+	}
+	return nil
+}
+
+// watch polls the time a pending read has waited for data and restarts the
+// process once it exceeds the stall timeout. It does nothing while no read
+// is pending.
+func (s *captureSource) watch() {
+	timeout := s.cfg.StallTimeout
+	interval := timeout / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+
+		if s.reading.Load() == 0 {
+			continue
+		}
+		idle := time.Since(time.Unix(0, s.lastData.Load()))
+		if idle < timeout {
+			continue
+		}
+		s.restart(idle)
+	}
+}
+
+// restart replaces the stalled process with a fresh one started from the
+// same arguments and reports the stall.
+func (s *captureSource) restart(idle time.Duration) {
+	ev := StallEvent{
+		Kind:     s.kind,
+		DeviceID: s.deviceID,
+		Idle:     idle,
+		Stderr:   s.current().LastStderr(),
+	}
+
+	// Give the replacement (or the next attempt) a full timeout window.
+	s.lastData.Store(time.Now().UnixNano())
+
+	err := s.reopen()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return
+	}
+	s.restarts++
+	ev.Restarts = s.restarts
+	s.mu.Unlock()
+	ev.Err = err
+
+	if s.cfg.Verbose {
+		log.Printf("ffmpeg: %s capture %q stalled for %v, restart #%d (err=%v)", s.kind, s.deviceID, idle.Round(time.Millisecond), ev.Restarts, ev.Err)
+	}
+	if s.cfg.OnStall != nil {
+		s.cfg.OnStall(ev)
+	}
+}
+
+// reopen replaces the current process with a fresh one started from the
+// same arguments. The old process is stopped first, because most capture
+// devices (V4L2, ALSA hw:, DirectShow cameras) cannot be opened twice; a
+// reader blocked on it waits for the new process and then observes
+// errCaptureRestarted. If the new process cannot be started, the stopped
+// one stays current and reads return its end of stream.
+func (s *captureSource) reopen() error {
+	s.reopenMu.Lock()
+	defer s.reopenMu.Unlock()
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil
+	}
+	args, old := s.args, s.proc
+	done := make(chan struct{})
+	s.reopening = done
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		s.reopening = nil
+		s.mu.Unlock()
+		close(done)
+	}()
+
+	old.Stop()
+	proc, err := startProcess(s.cfg, args)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		proc.Stop()
+		return nil
+	}
+	s.proc = proc
+	s.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,457 @@
+//go:build !windows
+
+package mediadevices
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestCaptureSource_RestartsStalledProcess(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+
+	events := make(chan StallEvent, 4)
+	SetConfig(Config{
+		// /bin/sh stands in for ffmpeg: emit one chunk, then hang.
+		FFmpegPath:   "/bin/sh",
+		StallTimeout: 200 * time.Millisecond,
+		OnStall:      func(ev StallEvent) { events <- ev },
+	})
+
+	src, err := startCapture(GetConfig(), MediaDeviceKindVideoInput, "/dev/video9", []string{"-c", "printf abcd; exec sleep 30"}, nil)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(src, buf); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+
+	// The next read blocks on the hung process until the watchdog swaps it.
+	_, err = io.ReadFull(src, buf)
+	if !errors.Is(err, errCaptureRestarted) {
+		t.Fatalf("second read err = %v, want errCaptureRestarted", err)
+	}
+
+	select {
+	case ev := <-events:
+		if ev.Kind != MediaDeviceKindVideoInput || ev.DeviceID != "/dev/video9" {
+			t.Errorf("event = %+v, want videoinput /dev/video9", ev)
+		}
+		if ev.Restarts != 1 || ev.Err != nil {
+			t.Errorf("event restarts=%d err=%v, want 1 and nil", ev.Restarts, ev.Err)
+		}
+		if ev.Idle < 200*time.Millisecond {
+			t.Errorf("event idle = %v, want >= stall timeout", ev.Idle)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no stall event delivered")
+	}
+
+	// The replacement process delivers data again.
+	if _, err := io.ReadFull(src, buf); err != nil || string(buf) != "abcd" {
+		t.Fatalf("read after restart = %q, %v", buf, err)
+	}
+}
+
+func TestCaptureSource_SlowConsumerNotRestarted(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	stalls := make(chan StallEvent, 4)
+	SetConfig(Config{
+		FFmpegPath:   "/bin/sh",
+		StallTimeout: 100 * time.Millisecond,
+		OnStall:      func(ev StallEvent) { stalls <- ev },
+	})
+
+	// The process always has data ready; the consumer takes longer than
+	// the stall timeout between reads.
+	src, err := startCapture(GetConfig(), MediaDeviceKindVideoInput, "/dev/video9", []string{"-c", "while :; do printf abcd; done"}, nil)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	buf := make([]byte, 4)
+	for range 3 {
+		if _, err := io.ReadFull(src, buf); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		time.Sleep(300 * time.Millisecond)
+	}
+	select {
+	case ev := <-stalls:
+		t.Errorf("slow consumer restarted the capture: %+v", ev)
+	default:
+	}
+	if n := src.Restarts(); n != 0 {
+		t.Errorf("Restarts() = %d, want 0", n)
+	}
+}
+
+func TestCaptureSource_NoWatchdogByDefault(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{FFmpegPath: "/bin/sh"})
+
+	src, err := startCapture(GetConfig(), MediaDeviceKindAudioInput, "hw:0", []string{"-c", "exec sleep 30"}, nil)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	time.Sleep(100 * time.Millisecond)
+	if n := src.Restarts(); n != 0 {
+		t.Errorf("Restarts() = %d, want 0", n)
+	}
+}
+
+func TestCaptureSource_Reopen(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{FFmpegPath: "/bin/sh"})
+
+	src, err := startCapture(GetConfig(), MediaDeviceKindVideoInput, "window", []string{"-c", "printf abcd; exec sleep 30"}, nil)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(src, buf); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := src.reopen(); err != nil {
+			t.Errorf("reopen: %v", err)
+		}
+	}()
+	if _, err := io.ReadFull(src, buf); !errors.Is(err, errCaptureRestarted) {
+		t.Fatalf("read during reopen err = %v, want errCaptureRestarted", err)
+	}
+	if _, err := io.ReadFull(src, buf); err != nil || string(buf) != "abcd" {
+		t.Fatalf("read after reopen = %q, %v", buf, err)
+	}
+	if n := src.Restarts(); n != 0 {
+		t.Errorf("Restarts() = %d, want 0 (reopen is not a stall restart)", n)
+	}
+}
+
+func TestCaptureSource_ReopenExclusiveDevice(t *testing.T) {
+	// Like a V4L2 camera, the "device" fails to open while another process
+	// still has it open. The hook runs right before each start.
+	pidFile := filepath.Join(t.TempDir(), "pid")
+	busy := func(args []string) []string {
+		if b, err := os.ReadFile(pidFile); err == nil {
+			if pid, _ := strconv.Atoi(strings.TrimSpace(string(b))); syscall.Kill(pid, 0) == nil {
+				return []string{"-c", "echo busy >&2; exit 1"}
+			}
+		}
+		return args
+	}
+	src, err := startCapture(Config{FFmpegPath: "/bin/sh"}, MediaDeviceKindVideoInput, "/dev/video9", []string{"-c", `echo $$ >"$0"; printf abcd; exec sleep 30`, pidFile}, busy)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(src, buf); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		if err := src.reopen(); err != nil {
+			t.Errorf("reopen: %v", err)
+		}
+	}()
+	if _, err := io.ReadFull(src, buf); !errors.Is(err, errCaptureRestarted) {
+		t.Fatalf("read during reopen err = %v, want errCaptureRestarted", err)
+	}
+	if _, err := io.ReadFull(src, buf); err != nil || string(buf) != "abcd" {
+		t.Fatalf("read after reopen = %q, %v (stderr %q)", buf, err, src.LastStderr())
+	}
+}
+
+func TestCaptureSource_StderrAcrossReopen(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+	SetConfig(Config{FFmpegPath: "/bin/sh"})
+
+	src, err := startCapture(GetConfig(), MediaDeviceKindVideoInput, "/dev/video9", []string{"-c", "echo opened >&2; printf abcd; exec sleep 30"}, nil)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	lines := make(chan string, 4)
+	cancel := src.OnStderrLine(func(line string) { lines <- line })
+	defer cancel()
+
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(src, buf); err != nil {
+		t.Fatalf("first read: %v", err)
+	}
+	if err := src.reopen(); err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	// The subscription may have missed the first process's line, but must
+	// see the one of the replacement.
+	select {
+	case line := <-lines:
+		if line != "opened" {
+			t.Errorf("stderr line = %q, want opened", line)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no stderr line after reopen")
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for len(src.StderrLines(1)) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got := src.StderrLines(1); len(got) != 1 || got[0] != "opened" {
+		t.Errorf("StderrLines(1) = %q, want [opened]", got)
+	}
+}
+
+func TestCaptureSource_ArgsHooks(t *testing.T) {
+	orig := GetConfig()
+	defer SetConfig(orig)
+
+	// The global hook runs first, then the reader's; sh prints $0.
+	SetConfig(Config{
+		FFmpegPath: "/bin/sh",
+		ArgsHook:   func(args []string) []string { return append(args, "global") },
+	})
+	reader := func(args []string) []string {
+		args[len(args)-1] += "+reader"
+		return args
+	}
+	args := []string{"-c", `printf %s "$0"`}
+	src, err := startCapture(GetConfig(), MediaDeviceKindVideoInput, "/dev/video9", args, reader)
+	if err != nil {
+		t.Fatalf("startCapture: %v", err)
+	}
+	defer src.Stop()
+
+	for i := range 2 {
+		buf := make([]byte, len("global+reader"))
+		if _, err := io.ReadFull(src, buf); err != nil || string(buf) != "global+reader" {
+			t.Fatalf("start %d: output = %q, %v", i, buf, err)
+		}
+		// A restart runs the hooks again on the original arguments.
+		if err := src.reopen(); err != nil {
+			t.Fatalf("reopen: %v", err)
+		}
+	}
+	if len(args) != 2 || args[1] != `printf %s "$0"` {
+		t.Errorf("hooks modified the caller's arguments: %q", args)
+	}
+}
+
+func TestAudioReader_ContinuousAcrossRestart(t *testing.T) {
+	// The first process delivers two 20ms chunks of 8 kHz mono samples of
+	// value 0x0101 and hangs; the restarted one delivers them continuously.
+	started := filepath.Join(t.TempDir(), "started")
+	m := NewMediaDevices(Config{
+		FFmpegPath:             "/bin/sh",
+		StallTimeout:           200 * time.Millisecond,
+		UseWallclockTimestamps: true,
+		ArgsHook: func([]string) []string {
+			return []string{"-c", `[ -e "$0" ] && exec tr '\0' '\1' </dev/zero; touch "$0"; head -c 640 /dev/zero | tr '\0' '\1'; exec sleep 30`, started}
+		},
+	})
+	r, err := m.newAudioReaderInternal("hw:0", AudioConfig{SampleRate: 8000, Channels: 1, Latency: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	var first *AudioChunk
+	var before, silence, after int
+	for after < 320 {
+		chunk, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if first == nil {
+			first = chunk
+		}
+		if want := int64(before + silence + after); chunk.Position != want {
+			t.Fatalf("chunk position = %d, want %d", chunk.Position, want)
+		}
+		if d := chunk.Timestamp.Sub(first.Timestamp); d != time.Duration(chunk.Position)*time.Second/8000 {
+			t.Fatalf("chunk at position %d stamped %v after the first", chunk.Position, d)
+		}
+		for _, s := range chunk.Data {
+			switch {
+			case s == 0 && before == 320 && after == 0:
+				silence++
+			case s == 0x0101 && silence == 0:
+				before++
+			case s == 0x0101:
+				after++
+			default:
+				t.Fatalf("unexpected sample %#x after %d, %d, %d samples", s, before, silence, after)
+			}
+		}
+	}
+	// The stall lasted at least the stall timeout and was filled with
+	// silence; no sample of either process was lost.
+	if gap := time.Duration(silence) * time.Second / 8000; gap < 150*time.Millisecond || gap > audioGapThreshold {
+		t.Errorf("%v of silence across the restart, want about the stall", gap)
+	}
+	if before != 320 {
+		t.Errorf("%d samples before the restart, want 320", before)
+	}
+}
+
+func TestAudioReader_TwoRestartsInOneChunk(t *testing.T) {
+	// 8 kHz mono in 20ms chunks of 160 samples. The first process delivers
+	// one chunk of 0x0101, the second 50 samples of 0x0202 and the third
+	// 0x0303 continuously; each restart interrupts a chunk.
+	var starts atomic.Int32
+	m := NewMediaDevices(Config{
+		FFmpegPath:             "/bin/sh",
+		UseWallclockTimestamps: true,
+		ArgsHook: func([]string) []string {
+			switch starts.Add(1) {
+			case 1:
+				return []string{"-c", `head -c 320 /dev/zero | tr '\0' '\1'; exec sleep 30`}
+			case 2:
+				return []string{"-c", `head -c 100 /dev/zero | tr '\0' '\2'; exec sleep 30`}
+			}
+			return []string{"-c", `exec tr '\0' '\3' </dev/zero`}
+		},
+	})
+	r, err := m.newAudioReaderInternal("hw:0", AudioConfig{SampleRate: 8000, Channels: 1, Latency: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	first, err := r.Read()
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		r.proc.reopen()
+		time.Sleep(200 * time.Millisecond)
+		r.proc.reopen()
+	}()
+
+	var order []uint16 // distinct sample values in stream order
+	counts := map[uint16]int{}
+	for counts[0x0303] < 320 {
+		chunk, err := r.Read()
+		if err != nil {
+			t.Fatalf("Read: %v", err)
+		}
+		if want := int64(160 + counts[0] + counts[0x0202] + counts[0x0303]); chunk.Position != want {
+			t.Fatalf("chunk position = %d, want %d", chunk.Position, want)
+		}
+		if d := chunk.Timestamp.Sub(first.Timestamp); d != time.Duration(chunk.Position)*time.Second/8000 {
+			t.Fatalf("chunk at position %d stamped %v after the first", chunk.Position, d)
+		}
+		for _, s := range chunk.Data {
+			if uint16(s) != 0 && uint16(s) != 0x0202 && uint16(s) != 0x0303 {
+				t.Fatalf("unexpected sample %#x", s)
+			}
+			if len(order) == 0 || order[len(order)-1] != uint16(s) {
+				order = append(order, uint16(s))
+			}
+			counts[uint16(s)]++
+		}
+	}
+	// Silence, the samples of the second process, silence for the second
+	// stall, and the third process.
+	if !slices.Equal(order, []uint16{0, 0x0202, 0, 0x0303}) {
+		t.Errorf("sample runs %#x, want silence, 0x0202, silence, 0x0303", order)
+	}
+	if counts[0x0202] != 50 {
+		t.Errorf("%d samples of the second process, want 50", counts[0x0202])
+	}
+}
+
+func TestAudioReader_Realign(t *testing.T) {
+	now := time.Unix(1000, 0)
+	data := make([]byte, 160*2) // 20ms at 8 kHz mono
+	for _, tc := range []struct {
+		name                  string
+		next                  time.Time
+		silence, skip, queued int
+		filled                int
+	}{
+		{"gap", now.Add(-120 * time.Millisecond), 800, 0, 160, 0},
+		{"gap after filled samples", now.Add(-120 * time.Millisecond), 720, 0, 160, 80},
+		{"overlap", now.Add(-10 * time.Millisecond), 0, 0, 80, 0},
+		{"long overlap", now.Add(30 * time.Millisecond), 0, 240, 0, 0},
+		{"re-anchor", now.Add(time.Second), 0, 0, 160, 0},
+		{"re-anchor after a long gap", now.Add(-time.Second), 0, 0, 160, 0},
+		{"first chunk", time.Time{}, 0, 0, 160, 0},
+	} {
+		r := &AudioReader{channels: 1, sampleRate: 8000, next: tc.next, silence: 5, skip: 7}
+		r.realign(now, data, tc.filled)
+		if r.silence != tc.silence || r.skip != tc.skip || len(r.pending)/2 != tc.queued {
+			t.Errorf("%s: silence %d, skip %d, pending %d; want %d, %d, %d", tc.name, r.silence, r.skip, len(r.pending)/2, tc.silence, tc.skip, tc.queued)
+		}
+	}
+}
+
+func TestVideoReader_FailureMidStream(t *testing.T) {
+	// One 4x2 YUV420p frame (12 bytes), then the device fails.
+	m := NewMediaDevices(Config{FFmpegPath: "/bin/sh", ArgsHook: func([]string) []string {
+		return []string{"-c", "head -c 12 /dev/zero; echo '/dev/video9: Input/output error' >&2; exit 1"}
+	}})
+	r, err := m.newVideoReaderInternal("/dev/video9", 4, 2, 30, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("first Read: %v", err)
+	}
+	_, err = r.Read()
+	var ce *CaptureError
+	if !errors.As(err, &ce) || ce.Cause != CauseIOError {
+		t.Fatalf("Read after the failure = %v, want a *CaptureError of %s", err, CauseIOError)
+	}
+}
+
+func TestAudioReader_EOFAfterClose(t *testing.T) {
+	m := NewMediaDevices(Config{FFmpegPath: "/bin/sh", ArgsHook: func([]string) []string {
+		return []string{"-c", "head -c 320 /dev/zero; exec sleep 30"}
+	}})
+	r, err := m.newAudioReaderInternal("hw:0", AudioConfig{SampleRate: 8000, Channels: 1, Latency: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Read(); err != nil {
+		t.Fatalf("first Read: %v", err)
+	}
+	errc := make(chan error, 1)
+	go func() {
+		_, err := r.Read()
+		errc <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+	r.Close()
+	if err := <-errc; err != io.EOF {
+		t.Fatalf("Read during Close = %v, want io.EOF", err)
+	}
+}
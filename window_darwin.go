@@ -0,0 +1,22 @@
+//go:build darwin
+
+package mediadevices
+
+import (
+	"errors"
+	"image"
+	"time"
+)
+
+// errWindowCaptureUnsupported is returned for window capture on macOS,
+// where AVFoundation can only capture whole screens.
+var errWindowCaptureUnsupported = errors.New("window capture is not supported on macOS")
+
+// enumerateWindows returns no windows on macOS; see errWindowCaptureUnsupported.
+func enumerateWindows(timeout time.Duration) ([]DisplaySource, error) {
+	return nil, nil
+}
+
+func windowBounds(timeout time.Duration, handle uintptr) (image.Rectangle, error) {
+	return image.Rectangle{}, errWindowCaptureUnsupported
+}
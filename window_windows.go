@@ -0,0 +1,86 @@
+//go:build windows
+
+package mediadevices
+
+import (
+	"fmt"
+	"image"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var (
+	user32               = windows.NewLazySystemDLL("user32.dll")
+	getWindowTextW       = user32.NewProc("GetWindowTextW")
+	getWindowTextLengthW = user32.NewProc("GetWindowTextLengthW")
+	getClientRect        = user32.NewProc("GetClientRect")
+	clientToScreen       = user32.NewProc("ClientToScreen")
+)
+
+// enumerateWindows lists the visible, titled top-level windows.
+func enumerateWindows(timeout time.Duration) ([]DisplaySource, error) {
+	var sources []DisplaySource
+	cb := windows.NewCallback(func(hwnd windows.HWND, _ uintptr) uintptr {
+		if !windows.IsWindowVisible(hwnd) || hwnd == windows.GetShellWindow() || windowCloaked(hwnd) {
+			return 1
+		}
+		title := windowTitle(hwnd)
+		if title == "" {
+			return 1
+		}
+		bounds, err := windowBounds(timeout, uintptr(hwnd))
+		if err != nil || bounds.Empty() {
+			return 1
+		}
+		sources = append(sources, windowSource(uintptr(hwnd), title, bounds))
+		return 1
+	})
+	if err := windows.EnumWindows(cb, nil); err != nil {
+		return nil, fmt.Errorf("enumerate windows: %w", err)
+	}
+	return sources, nil
+}
+
+// windowCloaked reports whether DWM hides the window (suspended UWP apps,
+// windows on other virtual desktops) even though it is marked visible.
+func windowCloaked(hwnd windows.HWND) bool {
+	var cloaked uint32
+	err := windows.DwmGetWindowAttribute(hwnd, windows.DWMWA_CLOAKED, unsafe.Pointer(&cloaked), uint32(unsafe.Sizeof(cloaked)))
+	return err == nil && cloaked != 0
+}
+
+func windowTitle(hwnd windows.HWND) string {
+	n, _, _ := getWindowTextLengthW.Call(uintptr(hwnd))
+	if n == 0 {
+		return ""
+	}
+	buf := make([]uint16, n+1)
+	getWindowTextW.Call(uintptr(hwnd), uintptr(unsafe.Pointer(&buf[0])), uintptr(len(buf)))
+	return windows.UTF16ToString(buf)
+}
+
+// windowBounds returns the client area of a window in screen coordinates,
+// which is the area gdigrab captures.
+func windowBounds(timeout time.Duration, handle uintptr) (image.Rectangle, error) {
+	var r windows.Rect
+	if ok, _, err := getClientRect.Call(handle, uintptr(unsafe.Pointer(&r))); ok == 0 {
+		return image.Rectangle{}, fmt.Errorf("window %#x: %w", handle, errnoErr(err))
+	}
+	var origin struct{ X, Y int32 }
+	if ok, _, err := clientToScreen.Call(handle, uintptr(unsafe.Pointer(&origin))); ok == 0 {
+		return image.Rectangle{}, fmt.Errorf("window %#x: %w", handle, errnoErr(err))
+	}
+	x, y := int(origin.X), int(origin.Y)
+	return image.Rect(x, y, x+int(r.Right-r.Left), y+int(r.Bottom-r.Top)), nil
+}
+
+// errnoErr turns the error of a failed LazyProc call into a non-nil error.
+func errnoErr(err error) error {
+	if errno, ok := err.(syscall.Errno); ok && errno == 0 {
+		return syscall.EINVAL
+	}
+	return err
+}
@@ -0,0 +1,88 @@
+//go:build linux || freebsd || openbsd
+
+package mediadevices
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// enumerateWindows lists the top-level windows managed by the X11 window
+// manager using wmctrl, which is killed after timeout.
+func enumerateWindows(timeout time.Duration) ([]DisplaySource, error) {
+	out, err := runDiscoveryTool(timeout, "wmctrl", "-lG")
+	if err != nil {
+		return nil, fmt.Errorf("list windows (is wmctrl installed?): %w", err)
+	}
+	return parseWmctrl(string(out)), nil
+}
+
+// parseWmctrl parses `wmctrl -lG` output, one window per line:
+//
+//	0x04400003  0 1920 24   1280 720  host Window Title
+func parseWmctrl(out string) []DisplaySource {
+	var sources []DisplaySource
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		rest := sc.Text()
+		var f [7]string
+		for i := range f {
+			rest = strings.TrimLeft(rest, " \t")
+			end := strings.IndexAny(rest, " \t")
+			if end < 0 {
+				end = len(rest)
+			}
+			f[i], rest = rest[:end], rest[end:]
+		}
+		title := strings.TrimSpace(rest)
+		id, err := strconv.ParseUint(f[0], 0, 64)
+		if err != nil || title == "" {
+			continue
+		}
+		x, _ := strconv.Atoi(f[2])
+		y, _ := strconv.Atoi(f[3])
+		w, _ := strconv.Atoi(f[4])
+		h, _ := strconv.Atoi(f[5])
+		sources = append(sources, windowSource(uintptr(id), title, image.Rect(x, y, x+w, y+h)))
+	}
+	return sources
+}
+
+// windowBounds returns the current position and size of an X11 window
+// using xwininfo, which is killed after timeout.
+func windowBounds(timeout time.Duration, handle uintptr) (image.Rectangle, error) {
+	out, err := runDiscoveryTool(timeout, "xwininfo", "-id", fmt.Sprintf("%#x", handle))
+	if err != nil {
+		return image.Rectangle{}, fmt.Errorf("window %#x: %w", handle, err)
+	}
+	return parseXwininfo(string(out))
+}
+
+// parseXwininfo extracts the window geometry from `xwininfo -id` output.
+func parseXwininfo(out string) (image.Rectangle, error) {
+	vals := map[string]int{}
+	sc := bufio.NewScanner(strings.NewReader(out))
+	for sc.Scan() {
+		key, val, ok := strings.Cut(strings.TrimSpace(sc.Text()), ":")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "Absolute upper-left X", "Absolute upper-left Y", "Width", "Height":
+			if n, err := strconv.Atoi(strings.TrimSpace(val)); err == nil {
+				vals[key] = n
+			}
+		}
+	}
+	w, okW := vals["Width"]
+	h, okH := vals["Height"]
+	if !okW || !okH {
+		return image.Rectangle{}, fmt.Errorf("xwininfo: no window geometry in output")
+	}
+	x, y := vals["Absolute upper-left X"], vals["Absolute upper-left Y"]
+	return image.Rect(x, y, x+w, y+h), nil
+}
@@ -0,0 +1,259 @@
+package mediadevices
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// zmqTimeout bounds connecting to a zmq filter and each command exchange.
+const zmqTimeout = 5 * time.Second
+
+// FilterCommandError is returned when FFmpeg's zmq filter rejects a
+// command, for example because the target filter does not exist or does not
+// support the command.
+type FilterCommandError struct {
+	Command FilterCommand
+	// Code is FFmpeg's (positive) error code, e.g. 38 for ENOSYS.
+	Code int
+	// Message is FFmpeg's description of the error.
+	Message string
+}
+
+func (e *FilterCommandError) Error() string {
+	return fmt.Sprintf("ffmpeg: filter command %s %s: %s (%d)", e.Command.Target, e.Command.Command, e.Message, e.Code)
+}
+
+// ZMQFilter returns the FFmpeg filter that receives commands on addr, such
+// as "tcp://127.0.0.1:5555", for use in a filter chain. Append it to a video
+// chain ("...,zmq=...") or, with the "a" prefix, to an audio chain (azmq).
+// FFmpeg must be built with --enable-libzmq.
+func ZMQFilter(addr string) string {
+	return "zmq=bind_address='" + addr + "'"
+}
+
+// ZMQClient sends filter commands to the zmq filter of a running FFmpeg
+// process over ZeroMQ (a minimal ZMTP 3.0 REQ socket, no libzmq needed).
+// It is safe for concurrent use; commands are sent one at a time.
+type ZMQClient struct {
+	addr string
+
+	mu   sync.Mutex
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// DialZMQ returns a client for the zmq filter bound to addr, given as
+// "tcp://host:port" or "host:port". The connection is made on the first
+// command and re-established after an error; the filter binds its address
+// only once the filter graph is configured, shortly after FFmpeg starts.
+func DialZMQ(addr string) (*ZMQClient, error) {
+	hostport := strings.TrimPrefix(addr, "tcp://")
+	if _, _, err := net.SplitHostPort(hostport); err != nil {
+		return nil, fmt.Errorf("ffmpeg: invalid zmq address %q: %w", addr, err)
+	}
+	return &ZMQClient{addr: hostport}, nil
+}
+
+// Send sends a command and waits for FFmpeg's reply. It returns the
+// filter's response text, if any, or a *FilterCommandError if the command
+// was rejected.
+func (c *ZMQClient) Send(cmd FilterCommand) (string, error) {
+	if _, err := cmd.line(); err != nil {
+		return "", err
+	}
+	msg := cmd.Target + " " + cmd.Command
+	if cmd.Arg != "" {
+		// The filter splits the message with av_get_token, which removes
+		// quotes and backslashes; escape them so that Arg arrives verbatim.
+		msg += " " + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(cmd.Arg)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	reply, err := c.exchange([]byte(msg))
+	if err != nil {
+		c.closeLocked()
+		return "", fmt.Errorf("ffmpeg: zmq %s: %w", c.addr, err)
+	}
+	return parseZMQReply(cmd, reply)
+}
+
+// Close closes the connection.
+func (c *ZMQClient) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.closeLocked()
+}
+
+func (c *ZMQClient) closeLocked() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn, c.rd = nil, nil
+	return err
+}
+
+// exchange sends one request and returns the reply body.
+func (c *ZMQClient) exchange(msg []byte) ([]byte, error) {
+	if c.conn == nil {
+		if err := c.connect(); err != nil {
+			return nil, err
+		}
+	}
+	c.conn.SetDeadline(time.Now().Add(zmqTimeout))
+
+	// A REQ message is an empty delimiter frame followed by the body.
+	var req bytes.Buffer
+	writeZMTPFrame(&req, 0x01, nil)
+	writeZMTPFrame(&req, 0, msg)
+	if _, err := c.conn.Write(req.Bytes()); err != nil {
+		return nil, err
+	}
+
+	var reply []byte
+	delimiter := true
+	for {
+		flags, body, err := readZMTPFrame(c.rd)
+		if err != nil {
+			return nil, err
+		}
+		if flags&zmtpCommand != 0 {
+			continue // e.g. heartbeats
+		}
+		if delimiter {
+			if len(body) != 0 {
+				return nil, errors.New("reply without delimiter frame")
+			}
+			delimiter = false
+		} else {
+			reply = append(reply, body...)
+		}
+		if flags&zmtpMore == 0 {
+			return reply, nil
+		}
+	}
+}
+
+// connect opens the connection and performs the ZMTP 3.0 handshake with the
+// NULL security mechanism.
+func (c *ZMQClient) connect() error {
+	conn, err := net.DialTimeout("tcp", c.addr, zmqTimeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(zmqTimeout))
+	rd := bufio.NewReader(conn)
+
+	greeting := make([]byte, 64)
+	greeting[0], greeting[9] = 0xFF, 0x7F // signature
+	greeting[10], greeting[11] = 3, 0     // version
+	copy(greeting[12:32], "NULL")         // mechanism; as-server and filler stay zero
+	if _, err := conn.Write(greeting); err != nil {
+		conn.Close()
+		return err
+	}
+	peer := make([]byte, 64)
+	if _, err := io.ReadFull(rd, peer); err != nil {
+		conn.Close()
+		return err
+	}
+	if peer[0] != 0xFF || peer[9] != 0x7F || peer[10] < 3 || string(bytes.TrimRight(peer[12:32], "\x00")) != "NULL" {
+		conn.Close()
+		return errors.New("peer is not a ZMTP 3 NULL-mechanism socket")
+	}
+
+	var ready bytes.Buffer
+	ready.WriteByte(5)
+	ready.WriteString("READY")
+	ready.WriteByte(11)
+	ready.WriteString("Socket-Type")
+	binary.Write(&ready, binary.BigEndian, uint32(3))
+	ready.WriteString("REQ")
+	var frame bytes.Buffer
+	writeZMTPFrame(&frame, zmtpCommand, ready.Bytes())
+	if _, err := conn.Write(frame.Bytes()); err != nil {
+		conn.Close()
+		return err
+	}
+	flags, body, err := readZMTPFrame(rd)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	if flags&zmtpCommand == 0 || len(body) < 6 || string(body[1:6]) != "READY" {
+		conn.Close()
+		return fmt.Errorf("handshake failed: %q", body)
+	}
+
+	c.conn, c.rd = conn, rd
+	return nil
+}
+
+// ZMTP frame flags.
+const (
+	zmtpMore    = 0x01
+	zmtpLong    = 0x02
+	zmtpCommand = 0x04
+)
+
+func writeZMTPFrame(w *bytes.Buffer, flags byte, body []byte) {
+	if len(body) > 255 {
+		w.WriteByte(flags | zmtpLong)
+		binary.Write(w, binary.BigEndian, uint64(len(body)))
+	} else {
+		w.WriteByte(flags)
+		w.WriteByte(byte(len(body)))
+	}
+	w.Write(body)
+}
+
+// maxZMTPFrame bounds the replies accepted from FFmpeg.
+const maxZMTPFrame = 1 << 20
+
+func readZMTPFrame(r *bufio.Reader) (flags byte, body []byte, err error) {
+	if flags, err = r.ReadByte(); err != nil {
+		return 0, nil, err
+	}
+	var size uint64
+	if flags&zmtpLong != 0 {
+		err = binary.Read(r, binary.BigEndian, &size)
+	} else {
+		var b byte
+		b, err = r.ReadByte()
+		size = uint64(b)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	if size > maxZMTPFrame {
+		return 0, nil, fmt.Errorf("frame too large (%d bytes)", size)
+	}
+	body = make([]byte, size)
+	_, err = io.ReadFull(r, body)
+	return flags, body, err
+}
+
+// parseZMQReply parses the zmq filter's reply, "<code> <message>" followed
+// by "\n<response>" if the filter produced one.
+func parseZMQReply(cmd FilterCommand, reply []byte) (string, error) {
+	status, response, _ := strings.Cut(string(reply), "\n")
+	codeStr, message, _ := strings.Cut(status, " ")
+	code, err := strconv.Atoi(codeStr)
+	if err != nil {
+		return "", fmt.Errorf("ffmpeg: invalid zmq reply %q", reply)
+	}
+	if code != 0 {
+		return response, &FilterCommandError{Command: cmd, Code: code, Message: message}
+	}
+	return response, nil
+}
@@ -0,0 +1,100 @@
+package mediadevices
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// fakeZMQFilter accepts one connection as a ZMTP 3.0 REP socket and answers
+// every request with reply(request).
+func fakeZMQFilter(t *testing.T, reply func(req string) string) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		rd := bufio.NewReader(conn)
+		greeting := make([]byte, 64)
+		if _, err := io.ReadFull(rd, greeting); err != nil {
+			return
+		}
+		greeting[32] = 1 // as-server
+		conn.Write(greeting)
+		if _, _, err := readZMTPFrame(rd); err != nil { // READY
+			return
+		}
+		var ready bytes.Buffer
+		writeZMTPFrame(&ready, zmtpCommand, []byte("\x05READY\x0bSocket-Type\x00\x00\x00\x03REP"))
+		conn.Write(ready.Bytes())
+
+		for {
+			var body []byte
+			for {
+				flags, b, err := readZMTPFrame(rd)
+				if err != nil {
+					return
+				}
+				body = b
+				if flags&zmtpMore == 0 {
+					break
+				}
+			}
+			var out bytes.Buffer
+			writeZMTPFrame(&out, zmtpMore, nil)
+			writeZMTPFrame(&out, 0, []byte(reply(string(body))))
+			conn.Write(out.Bytes())
+		}
+	}()
+	return "tcp://" + l.Addr().String()
+}
+
+func TestZMQClient(t *testing.T) {
+	var got []string
+	addr := fakeZMQFilter(t, func(req string) string {
+		got = append(got, req)
+		if len(got) == 1 {
+			return "0 Success"
+		}
+		return "38 Function not implemented"
+	})
+	c, err := DialZMQ(addr)
+	if err != nil {
+		t.Fatalf("DialZMQ: %v", err)
+	}
+	defer c.Close()
+
+	if _, err := c.Send(FilterCommand{Target: "drawtext@title", Command: "reinit", Arg: `text='It\'s live'`}); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if want := `drawtext@title reinit text=\'It\\\'s live\'`; got[0] != want {
+		t.Errorf("request = %q, want %q", got[0], want)
+	}
+
+	_, err = c.Send(FilterCommand{Target: "crop@pan", Command: "nope"})
+	var fe *FilterCommandError
+	if !errors.As(err, &fe) || fe.Code != 38 || fe.Message != "Function not implemented" {
+		t.Errorf("rejected command: err = %v", err)
+	}
+}
+
+func TestParseZMQReply(t *testing.T) {
+	resp, err := parseZMQReply(FilterCommand{}, []byte("0 Success\nvolume:0.5"))
+	if err != nil || resp != "volume:0.5" {
+		t.Errorf("reply = %q, %v", resp, err)
+	}
+	if _, err := parseZMQReply(FilterCommand{}, []byte("garbage")); err == nil {
+		t.Error("invalid reply accepted")
+	}
+}